@@ -0,0 +1,453 @@
+// lpmp-bench 上行处理链路的压测工具：按给定帧率生成合成的 +DRX 流量，经
+// 串口监听 → 分片拼接 → 报文解析 → 值表写入 完整走一遍，报告每帧分配次数和端到端时延分位数。
+//
+//	lpmp-bench                                  # 依次以 100/1000/5000 帧每秒各跑 5 秒
+//	lpmp-bench -rates 5000 -duration 30s -frag 0.2
+//	go run -race ./cmd/lpmp-bench -stress       # 竞态压测：多链路并发写入，同时并发读取共享表
+//	lpmp-bench -store-devices 256               # 只压测值表在多设备下的并发读写吞吐
+//
+// 时延从帧写入模拟串口开始，到读数交给 Sink（值表）为止；分片帧从写入首片开始计时，
+// 各片段同样以 +DRX 行写入，由流水线的拼接器拼接后解析。
+//
+// 性能预算（单链路、解析器日志级别 WARN、未设置追踪导出器）：
+//
+//	帧率 5000/s 时 P99 时延 ≤ 5ms，每帧堆分配 ≤ 50 次
+//
+// 超出 -budget-p99 / -budget-allocs 时以退出码 1 结束，可接入 CI；
+// 分配次数包含监听、拼接、解析、写值全链路，不含预先生成的流量。-stress 时只检查是否丢帧，
+// 共享表的竞争由 -race 报告（发现竞争时进程以非零码退出）。
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+	"github.com/linjuya-lu/device-lpmp-go/internal/frameparser"
+	"github.com/linjuya-lu/device-lpmp-go/internal/serial"
+	"github.com/linjuya-lu/device-lpmp-go/internal/simulator"
+)
+
+// seqParamType 合成帧携带的参量：参数表中的 float32 "长度"，值为帧序号，用于在 Sink 端找回发送时刻
+const seqParamType uint16 = 0x0001
+
+// maxSeq float32 可精确表示的最大整数，单轮帧数不得超过
+const maxSeq = 1 << 24
+
+// benchConfig 合成传感器的设备配置：每个传感器绑定一个同名设备，不做范围校验
+type benchConfig struct {
+	bindings map[string][]config.SensorBinding
+}
+
+func (c benchConfig) LookupSensorBindings(sensorID string) []config.SensorBinding {
+	return c.bindings[sensorID]
+}
+
+func (benchConfig) LookupParamInfo(sensorID string, paramType uint16) (config.ParamInfo, bool) {
+	return config.LookupSensorParamInfo(sensorID, paramType)
+}
+
+func (benchConfig) LookupTLVTable(uint16) (*config.TLVTable, bool) {
+	return nil, false
+}
+
+func (benchConfig) CheckParamRange(uint16, any) (config.RangeResult, string) {
+	return config.RangeOK, ""
+}
+
+func (benchConfig) ResolveResourceName(_ string, _ uint16, fallback string) string {
+	return fallback
+}
+
+func (benchConfig) HasResource(string, string) bool {
+	return true
+}
+
+func (benchConfig) TransformValue(_, _ string, value any) (any, error) {
+	return value, nil
+}
+
+func (benchConfig) ResourceUnit(_, _, fallback string) (config.Unit, bool) {
+	return config.NormalizeUnit(fallback)
+}
+
+// sensor 一个合成传感器
+type sensor struct {
+	id         [6]byte
+	hexID      string
+	link       int
+	fragmented bool
+}
+
+// item 预先生成的一帧流量：未分片帧为一条 +DRX 行，分片帧为按发送顺序拼接的各片段的 +DRX 行
+type item struct {
+	seq  int
+	link int
+	line []byte
+}
+
+// recorder 作为 Sink 记录每帧的端到端时延
+type recorder struct {
+	sent []atomic.Int64 // 帧序号 → 发送时刻（UnixNano）
+
+	mu        sync.Mutex
+	latencies []time.Duration
+	received  atomic.Int64
+}
+
+// SetValue 实现 frameparser.ValueSink
+func (r *recorder) SetValue(_, _ string, value any, _ time.Time, _ map[string]string) {
+	v, ok := value.(float32)
+	if !ok {
+		return
+	}
+	seq := int(v)
+	if seq < 0 || seq >= len(r.sent) {
+		return
+	}
+	at := r.sent[seq].Load()
+	if at == 0 {
+		return
+	}
+	lat := time.Duration(time.Now().UnixNano() - at)
+	r.mu.Lock()
+	r.latencies = append(r.latencies, lat)
+	r.mu.Unlock()
+	r.received.Add(1)
+}
+
+// percentile 返回已排序时延的 p 分位
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(i, 0)]
+}
+
+// options 命令行参数
+type options struct {
+	duration     time.Duration
+	sensors      int
+	links        int
+	frag         float64
+	fragments    int
+	stress       bool
+	budgetP99    time.Duration
+	budgetAllocs float64
+}
+
+// result 一轮压测的结果
+type result struct {
+	rate     int
+	sent     int
+	received int
+	allocs   float64
+	bytes    float64
+	p50      time.Duration
+	p99      time.Duration
+	max      time.Duration
+	elapsed  time.Duration
+}
+
+// makeSensors 生成合成传感器：按序号轮流分到各链路，前 frag 比例的传感器发送分片帧
+func makeSensors(o options) []sensor {
+	ss := make([]sensor, o.sensors)
+	nFrag := int(math.Round(o.frag * float64(o.sensors)))
+	for i := range ss {
+		id := [6]byte{0xBE, 0x0C, 0x00, 0x00, byte(i >> 8), byte(i)}
+		ss[i] = sensor{
+			id:         id,
+			hexID:      strings.ToUpper(hex.EncodeToString(id[:])),
+			link:       i % o.links,
+			fragmented: i < nFrag,
+		}
+	}
+	return ss
+}
+
+// generate 预先生成 n 帧流量，避免生成开销计入分配统计
+func generate(ss []sensor, n, fragments int) ([]item, error) {
+	items := make([]item, n)
+	sims := make([]*simulator.Sensor, len(ss))
+	for i, s := range ss {
+		sims[i] = simulator.SensorFromID(s.id)
+	}
+	for seq := range items {
+		s, sim := ss[seq%len(ss)], sims[seq%len(ss)]
+		pv := frameparser.ParamValue{Type: seqParamType, Value: float32(seq)}
+		it := item{seq: seq, link: s.link}
+		if !s.fragmented {
+			frame, err := sim.Monitoring(pv)
+			if err != nil {
+				return nil, err
+			}
+			it.line = sim.Line(frame)
+		} else {
+			// 分片：报文内容按 PSEQ 0.. 顺序切成最多 fragments 片分片帧
+			frames, err := sim.MonitoringFragments(fragments, pv)
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range frames {
+				it.line = append(it.line, sim.Line(f)...)
+			}
+		}
+		items[seq] = it
+	}
+	return items, nil
+}
+
+// link 一条模拟链路：模拟串口 → DRX 监听 → 解析流水线（含拼接器）
+type link struct {
+	w        *io.PipeWriter
+	r        *io.PipeReader
+	frameCh  chan serial.RxFrame
+	pipeline *frameparser.Pipeline
+}
+
+// newLink 创建并启动一条链路
+func newLink(ctx context.Context, name string, cfg frameparser.ConfigAccessor, sink frameparser.ValueSink) *link {
+	l := &link{
+		frameCh: make(chan serial.RxFrame, 100),
+	}
+	l.r, l.w = io.Pipe()
+	serial.ListenDRX(ctx, l.r, l.frameCh)
+	l.pipeline = frameparser.NewPipeline(frameparser.PipelineOptions{
+		Name:   name,
+		Input:  l.frameCh,
+		Config: cfg,
+		Sink:   sink,
+	})
+	l.pipeline.Start(ctx)
+	return l
+}
+
+// run 以 rate 帧每秒发送一轮流量，等待全部处理完成（或超时）后统计
+func run(o options, ss []sensor, rate int) (result, error) {
+	n := int(float64(rate) * o.duration.Seconds())
+	if n <= 0 || n > maxSeq {
+		return result{}, fmt.Errorf("帧率 %d × 时长 %s 得到 %d 帧，须在 1~%d 之间", rate, o.duration, n, maxSeq)
+	}
+	items, err := generate(ss, n, o.fragments)
+	if err != nil {
+		return result{}, fmt.Errorf("生成流量失败: %w", err)
+	}
+	cfg := benchConfig{bindings: make(map[string][]config.SensorBinding, len(ss))}
+	for _, s := range ss {
+		cfg.bindings[s.hexID] = []config.SensorBinding{{DeviceName: "bench-" + s.hexID}}
+	}
+	rec := &recorder{sent: make([]atomic.Int64, n), latencies: make([]time.Duration, 0, n)}
+	sink := frameparser.MultiSink{frameparser.ConfigSink{}, rec}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	links := make([]*link, o.links)
+	for i := range links {
+		links[i] = newLink(ctx, "bench-"+strconv.Itoa(i), cfg, sink)
+	}
+	stopStress := func() {}
+	if o.stress {
+		stopStress = startStress(ss)
+	}
+
+	runtime.GC()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+
+	// 按帧率匀速发送：每毫秒补齐到 elapsed×rate 帧
+	ticker := time.NewTicker(time.Millisecond)
+	next := 0
+	for next < n {
+		<-ticker.C
+		due := min(int(time.Since(start).Seconds()*float64(rate)), n)
+		for ; next < due; next++ {
+			it := items[next]
+			rec.sent[it.seq].Store(time.Now().UnixNano())
+			if _, err := links[it.link].w.Write(it.line); err != nil {
+				ticker.Stop()
+				return result{}, fmt.Errorf("写入模拟串口失败: %w", err)
+			}
+		}
+	}
+	ticker.Stop()
+
+	// 等待在途帧处理完成，最多等一个发送时长
+	deadline := time.Now().Add(max(o.duration, time.Second))
+	for rec.received.Load() < int64(n) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+	stopStress()
+
+	for _, l := range links {
+		l.w.Close()
+		l.pipeline.Stop()
+	}
+
+	rec.mu.Lock()
+	lat := rec.latencies
+	rec.mu.Unlock()
+	sort.Slice(lat, func(i, j int) bool { return lat[i] < lat[j] })
+	res := result{
+		rate:     rate,
+		sent:     n,
+		received: len(lat),
+		allocs:   float64(after.Mallocs-before.Mallocs) / float64(n),
+		bytes:    float64(after.TotalAlloc-before.TotalAlloc) / float64(n),
+		p50:      percentile(lat, 0.50),
+		p99:      percentile(lat, 0.99),
+		elapsed:  elapsed,
+	}
+	if len(lat) > 0 {
+		res.max = lat[len(lat)-1]
+	}
+	return res, nil
+}
+
+// startStress 在压测期间并发读写与解析流水线共享的包级表，配合 -race 发现未加锁的访问；
+// 返回的函数停止并等待这些协程
+func startStress(ss []sensor) func() {
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	loop := func(f func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				f(i)
+			}
+		}()
+	}
+	for g := 0; g < 4; g++ {
+		loop(func(i int) {
+			s := ss[i%len(ss)]
+			config.GetDeviceValues("bench-" + s.hexID)
+			config.LookupSensorBindings(s.hexID)
+			frameparser.ErrorCounts()
+			frameparser.BufferedFragments()
+			frameparser.DropCounts()
+			runtime.Gosched()
+		})
+	}
+	// 模拟南向命令写入同一批设备
+	loop(func(i int) {
+		s := ss[i%len(ss)]
+		config.SetDeviceValue("bench-"+s.hexID, "stress", i)
+		runtime.Gosched()
+	})
+	return func() {
+		close(stop)
+		wg.Wait()
+	}
+}
+
+// parseRates 解析逗号分隔的帧率列表
+func parseRates(s string) ([]int, error) {
+	var rates []int
+	for _, item := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("帧率须为正整数，得到 %q", item)
+		}
+		rates = append(rates, n)
+	}
+	return rates, nil
+}
+
+func main() {
+	rateList := flag.String("rates", "100,1000,5000", "逗号分隔的帧率（帧/秒），依次各跑一轮")
+	duration := flag.Duration("duration", 5*time.Second, "每轮发送时长")
+	sensors := flag.Int("sensors", 64, "合成传感器个数")
+	links := flag.Int("links", 1, "模拟链路数，每条链路独立的监听、拼接器和解析流水线")
+	frag := flag.Float64("frag", 0.1, "发送分片帧的传感器比例（0~1）")
+	fragments := flag.Int("fragments", 4, "每条分片 SDU 的片段数")
+	stress := flag.Bool("stress", false, "并发读写共享表，配合 go run -race 使用")
+	budgetP99 := flag.Duration("budget-p99", 5*time.Millisecond, "P99 时延预算，0 表示不检查")
+	budgetAllocs := flag.Float64("budget-allocs", 50, "每帧堆分配次数预算，0 表示不检查")
+	storeDevices := flag.Int("store-devices", 0, "大于 0 时只压测值表的并发读写（设备数），不走解析链路")
+	storeWorkers := flag.Int("store-workers", 0, "值表压测的读、写协程各自的个数，0 表示 GOMAXPROCS")
+	flag.Parse()
+
+	if *storeDevices > 0 {
+		printStore(*storeDevices, *storeWorkers, *duration)
+		return
+	}
+
+	rates, err := parseRates(*rateList)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if *sensors <= 0 || *links <= 0 || *links > *sensors || *fragments < 2 || *frag < 0 || *frag > 1 {
+		fmt.Fprintln(os.Stderr, "参数无效：sensors、links 须为正且 links ≤ sensors，fragments ≥ 2，frag 在 0~1 之间")
+		os.Exit(2)
+	}
+	o := options{
+		duration:     *duration,
+		sensors:      *sensors,
+		links:        *links,
+		frag:         *frag,
+		fragments:    *fragments,
+		stress:       *stress,
+		budgetP99:    *budgetP99,
+		budgetAllocs: *budgetAllocs,
+	}
+	// 逐值日志会主导耗时，压测只保留警告
+	frameparser.SetLogLevel(frameparser.LevelWarn)
+	log.SetOutput(io.Discard)
+
+	ss := makeSensors(o)
+	fmt.Printf("传感器 %d 个（分片 %.0f%%，每 SDU %d 片），链路 %d 条，每轮 %s\n",
+		o.sensors, o.frag*100, o.fragments, o.links, o.duration)
+	fmt.Printf("%8s %8s %8s %10s %10s %10s %10s %10s\n", "帧/秒", "发送", "收到", "分配/帧", "字节/帧", "P50", "P99", "最大")
+	overBudget := false
+	for _, rate := range rates {
+		res, err := run(o, ss, rate)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("%8d %8d %8d %10.1f %10.0f %10s %10s %10s\n", res.rate, res.sent, res.received,
+			res.allocs, res.bytes, res.p50.Round(time.Microsecond), res.p99.Round(time.Microsecond), res.max.Round(time.Microsecond))
+		if res.received < res.sent {
+			fmt.Printf("  ✗ %d 帧未在 %s 内处理完\n", res.sent-res.received, res.elapsed.Round(time.Millisecond))
+			overBudget = true
+		}
+		// -stress 通常配合 -race 运行，时延和分配都不具参考意义，只检查是否丢帧
+		if o.stress {
+			continue
+		}
+		if o.budgetP99 > 0 && res.p99 > o.budgetP99 {
+			fmt.Printf("  ✗ P99 %s 超出预算 %s\n", res.p99.Round(time.Microsecond), o.budgetP99)
+			overBudget = true
+		}
+		if o.budgetAllocs > 0 && res.allocs > o.budgetAllocs {
+			fmt.Printf("  ✗ 每帧分配 %.1f 次超出预算 %.0f\n", res.allocs, o.budgetAllocs)
+			overBudget = true
+		}
+	}
+	if overBudget {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+)
+
+// storeResult 值表并发压测结果
+type storeResult struct {
+	writes int64
+	reads  int64
+	dur    time.Duration
+}
+
+// runStore 对值表做并发读写压测：writers 个协程模拟解析流水线写入读数和质量标签，
+// readers 个协程模拟 HandleReadCommands 读取整台设备的值和质量，设备数为 devices
+func runStore(devices, writers, readers int, d time.Duration) storeResult {
+	names := make([]string, devices)
+	for i := range names {
+		names[i] = "store-" + strconv.Itoa(i)
+		for r := 0; r < 8; r++ {
+			config.SetDeviceValue(names[i], "r"+strconv.Itoa(r), float32(0))
+		}
+	}
+	resources := [8]string{"r0", "r1", "r2", "r3", "r4", "r5", "r6", "r7"}
+
+	var (
+		writes, reads atomic.Int64
+		stop          atomic.Bool
+		wg            sync.WaitGroup
+	)
+	worker := func(seed int, f func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := seed; !stop.Load(); i += 7919 {
+				f(i)
+			}
+		}()
+	}
+	for w := 0; w < writers; w++ {
+		worker(w, func(i int) {
+			dev := names[i%devices]
+			config.SetDeviceValue(dev, resources[i%len(resources)], float32(i))
+			config.SetValueQuality(dev, resources[i%len(resources)], config.QualityGood)
+			writes.Add(1)
+		})
+	}
+	for r := 0; r < readers; r++ {
+		worker(r*31, func(i int) {
+			dev := names[i%devices]
+			config.GetDeviceValues(dev)
+			config.ValueQuality(dev, resources[i%len(resources)], 0)
+			reads.Add(1)
+		})
+	}
+	start := time.Now()
+	time.Sleep(d)
+	stop.Store(true)
+	wg.Wait()
+	return storeResult{writes: writes.Load(), reads: reads.Load(), dur: time.Since(start)}
+}
+
+// printStore 输出值表压测结果，workers 为读、写协程各自的个数，0 表示 GOMAXPROCS
+func printStore(devices, workers int, d time.Duration) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	fmt.Printf("值表并发读写：设备 %d 台，写协程 %d，读协程 %d，%s\n", devices, workers, workers, d)
+	res := runStore(devices, workers, workers, d)
+	secs := res.dur.Seconds()
+	fmt.Printf("  写 %.0f 次/秒，读 %.0f 次/秒\n", float64(res.writes)/secs, float64(res.reads)/secs)
+}
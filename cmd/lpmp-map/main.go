@@ -0,0 +1,146 @@
+// lpmp-map 传感器映射批量导入导出工具：调用设备服务的 /api/v3/lpmp/sensor-mappings 接口，
+// 导出当前 SensorID ↔ 设备映射，或在调试阶段一次导入数百条映射。
+//
+//	lpmp-map -export csv > mappings.csv
+//	lpmp-map -export yaml -device WaterLevel-01
+//	lpmp-map -import mappings.csv -dry-run        # 只校验，打印将要修改的设备
+//	lpmp-map -import mappings.yaml -url http://edgex-device-lpmp:59905 -token "$TOKEN"
+//
+// CSV 首行为表头 sensorId,device,prefix,resources（prefix、resources 可省略，resources 内以分号分隔）；
+// YAML 为同名字段的列表。导入只替换文件中出现的设备的映射，校验失败时不做任何修改，退出码 1。
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// mappingsPath 设备服务上的映射接口
+const mappingsPath = "/api/v3/lpmp/sensor-mappings"
+
+// importReport 导入接口返回的结果
+type importReport struct {
+	DryRun  bool `json:"dryRun"`
+	Rows    int  `json:"rows"`
+	Changes []struct {
+		Device    string `json:"device"`
+		SensorIDs string `json:"sensorIds"`
+		Resources string `json:"resources"`
+		Previous  string `json:"previous"`
+	} `json:"changes"`
+	Errors []string `json:"errors"`
+}
+
+func main() {
+	base := flag.String("url", "http://localhost:59905", "设备服务地址")
+	token := flag.String("token", "", "访问令牌（开启安全模式时需要），以 Bearer 方式发送")
+	export := flag.String("export", "", "导出当前映射到标准输出，格式 csv 或 yaml")
+	device := flag.String("device", "", "导出时只导出该设备的映射")
+	importFile := flag.String("import", "", "导入映射文件（.csv / .yaml）")
+	format := flag.String("format", "", "导入文件的格式 csv 或 yaml，默认按扩展名判断")
+	dryRun := flag.Bool("dry-run", false, "导入时只校验，不修改设备")
+	flag.Parse()
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	switch {
+	case *export != "":
+		q := url.Values{"format": {*export}}
+		if *device != "" {
+			q.Set("device", *device)
+		}
+		body, status, err := call(client, http.MethodGet, *base, *token, q, nil)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if status != http.StatusOK {
+			fmt.Fprintf(os.Stderr, "导出失败（HTTP %d）: %s\n", status, body)
+			os.Exit(1)
+		}
+		os.Stdout.Write(body)
+	case *importFile != "":
+		data, err := os.ReadFile(*importFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		f := *format
+		if f == "" {
+			f = strings.TrimPrefix(strings.ToLower(filepath.Ext(*importFile)), ".")
+			if f == "yml" {
+				f = "yaml"
+			}
+		}
+		q := url.Values{"format": {f}, "dryRun": {fmt.Sprint(*dryRun)}}
+		body, status, err := call(client, http.MethodPost, *base, *token, q, data)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		var rep importReport
+		if err := json.Unmarshal(body, &rep); err != nil {
+			fmt.Fprintf(os.Stderr, "导入失败（HTTP %d）: %s\n", status, body)
+			os.Exit(1)
+		}
+		printReport(rep)
+		if status != http.StatusOK {
+			os.Exit(1)
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// call 调用映射接口，返回响应体和状态码
+func call(client *http.Client, method, base, token string, q url.Values, body []byte) ([]byte, int, error) {
+	req, err := http.NewRequest(method, strings.TrimRight(base, "/")+mappingsPath+"?"+q.Encode(), bytes.NewReader(body))
+	if err != nil {
+		return nil, 0, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("请求设备服务失败: %w", err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	return b, resp.StatusCode, err
+}
+
+// printReport 输出导入结果：错误逐条列出，否则列出各设备的修改
+func printReport(rep importReport) {
+	if len(rep.Errors) > 0 {
+		fmt.Fprintf(os.Stderr, "%d 条映射校验失败，未做任何修改:\n", rep.Rows)
+		for _, e := range rep.Errors {
+			fmt.Fprintln(os.Stderr, "  "+e)
+		}
+		return
+	}
+	verb := "已更新"
+	if rep.DryRun {
+		verb = "将更新"
+	}
+	fmt.Printf("%d 条映射，%s %d 个设备:\n", rep.Rows, verb, len(rep.Changes))
+	for _, c := range rep.Changes {
+		fmt.Printf("  %s: %s", c.Device, c.SensorIDs)
+		if c.Resources != "" {
+			fmt.Printf("（resources=%s）", c.Resources)
+		}
+		if c.Previous != "" && c.Previous != c.SensorIDs {
+			fmt.Printf("，原为 %s", c.Previous)
+		}
+		fmt.Println()
+	}
+}
@@ -0,0 +1,170 @@
+// lpmp-tail 现场调试工具：只读打开模组串口（或 ser2net 的 TCP 透传），
+// 把收到的每一帧解码为一行彩色摘要输出，不向模组发送任何指令。
+//
+//	lpmp-tail -port /dev/ttyUSB0
+//	lpmp-tail -port tcp://192.168.1.10:4001 -dialect "crc=ccitt;header=1" -hex
+//	lpmp-tail -port /dev/ttyUSB0 -capture captures/outage.cap   # 同时记录抓包文件，供网关 replayCapture 回补
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/frameparser"
+	"github.com/linjuya-lu/device-lpmp-go/internal/serial"
+)
+
+// ANSI 颜色
+const (
+	colorReset  = "\033[0m"
+	colorGray   = "\033[90m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorCyan   = "\033[36m"
+)
+
+// printer 按选项格式化输出
+type printer struct {
+	color bool
+	hex   bool
+	out   io.Writer
+}
+
+// paint 开启颜色时给 s 加上 ANSI 颜色
+func (p printer) paint(color, s string) string {
+	if !p.color {
+		return s
+	}
+	return color + s + colorReset
+}
+
+// print 输出一帧：时间 SensorID 报文类型 参量个数 结果
+func (p printer) print(at time.Time, raw []byte, s frameparser.FrameSummary) {
+	var b strings.Builder
+	b.WriteString(p.paint(colorGray, at.Format("15:04:05.000")))
+	b.WriteByte(' ')
+	sid := s.SensorID
+	if sid == "" {
+		sid = "------------"
+	}
+	b.WriteString(p.paint(colorCyan, sid))
+	if s.CRCOK {
+		kind := frameparser.PacketTypeName(s.PacketType)
+		if s.FragInd == 1 {
+			kind += "/frag"
+		}
+		if s.Compressed {
+			kind += "/z"
+		}
+		fmt.Fprintf(&b, " %-14s n=%-2d", kind, s.DataLen)
+	}
+
+	switch {
+	case s.Control != nil:
+		c := s.Control
+		op := "query"
+		if c.RequestSet {
+			op = "set"
+		}
+		fmt.Fprintf(&b, " %s", p.paint(colorYellow, fmt.Sprintf("ctrl=%d %s", c.CtrlType, op)))
+		if c.HasStatus {
+			st := p.paint(colorGreen, "ok")
+			if !c.Succeeded() {
+				st = p.paint(colorRed, "failed")
+			}
+			fmt.Fprintf(&b, " status=%s", st)
+		} else if len(c.Data) > 0 {
+			fmt.Fprintf(&b, " data=% X", c.Data)
+		}
+	case s.CRCOK && s.DataLen == 0 && s.PacketType == frameparser.PacketTypeMonitoring:
+		b.WriteString(p.paint(colorGray, " heartbeat"))
+	}
+	for _, ps := range s.Params {
+		name := ps.Name
+		if name == "" {
+			name = fmt.Sprintf("0x%04X", ps.Type)
+		}
+		if ps.Err != nil {
+			fmt.Fprintf(&b, " %s=%s", name, p.paint(colorRed, fmt.Sprintf("% X(%v)", ps.Raw, ps.Err)))
+			continue
+		}
+		fmt.Fprintf(&b, " %s=%s", name, p.paint(colorGreen, fmt.Sprintf("%v%s", ps.Value, displayUnit(ps.Unit))))
+	}
+	if s.Err != nil {
+		fmt.Fprintf(&b, " %s", p.paint(colorRed, "✗ "+s.Err.Error()))
+	}
+	if p.hex {
+		fmt.Fprintf(&b, " %s", p.paint(colorGray, fmt.Sprintf("[% X]", raw)))
+	}
+	fmt.Fprintln(p.out, b.String())
+}
+
+// displayUnit 参数表中的单位有的是枚举说明（如 "0:其它,1:正常"），单行输出时省略
+func displayUnit(u string) string {
+	if strings.ContainsAny(u, ":,\\") {
+		return ""
+	}
+	return u
+}
+
+func main() {
+	port := flag.String("port", "/dev/ttyUSB0", "串口名，或 tcp://host:port（ser2net 透传）")
+	baud := flag.Int("baud", 115200, "串口波特率")
+	dialectSpec := flag.String("dialect", "", "报文方言，格式同设备协议属性 lpmp.dialect，默认标准格式")
+	noColor := flag.Bool("no-color", false, "不输出 ANSI 颜色")
+	showHex := flag.Bool("hex", false, "在每行末尾附上原始帧")
+	capture := flag.String("capture", "", "把串口输出逐行加上接收时间追加到该抓包文件")
+	flag.Parse()
+
+	dialect, err := frameparser.ParseDialect(*dialectSpec)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "方言无效:", err)
+		os.Exit(2)
+	}
+	tr, err := serial.ParseTransport(*port, *baud)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	conn, addr, err := tr.Open()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer conn.Close()
+	// 参数表中的解析函数会输出日志，这里只保留逐帧摘要
+	log.SetOutput(io.Discard)
+
+	var in io.Reader = conn
+	if *capture != "" {
+		f, err := os.OpenFile(*capture, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "打开抓包文件失败:", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		in = io.TeeReader(conn, serial.NewCaptureWriter(f))
+		fmt.Fprintf(os.Stderr, "同时记录到抓包文件 %s\n", *capture)
+	}
+
+	p := printer{color: !*noColor, hex: *showHex, out: os.Stdout}
+	fmt.Fprintf(os.Stderr, "正在监听 %s（只读，Ctrl+C 退出）\n", addr)
+	r := serial.NewDRXReader(in)
+	for {
+		frame, err := r.ReadFrame()
+		if err != nil {
+			if err != io.EOF {
+				fmt.Fprintln(os.Stderr, "读取失败:", err)
+				os.Exit(1)
+			}
+			return
+		}
+		p.print(time.Now(), frame, frameparser.DescribeFrame(frame, dialect))
+	}
+}
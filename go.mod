@@ -3,9 +3,11 @@ module github.com/linjuya-lu/device-lpmp-go
 go 1.23
 
 require (
+	github.com/eclipse/paho.mqtt.golang v1.5.0
 	github.com/edgexfoundry/device-sdk-go/v4 v4.0.0
 	github.com/edgexfoundry/device-virtual-go v1.3.1
 	github.com/edgexfoundry/go-mod-core-contracts/v4 v4.0.1
+	github.com/labstack/echo/v4 v4.13.3
 	go.bug.st/serial.v1 v0.0.0-20191202182710-24a6610f0541
 	gopkg.in/yaml.v3 v3.0.1
 )
@@ -20,7 +22,6 @@ require (
 	github.com/cenkalti/backoff v2.2.1+incompatible // indirect
 	github.com/cenkalti/backoff/v4 v4.3.0 // indirect
 	github.com/creack/goselect v0.1.3 // indirect
-	github.com/eclipse/paho.mqtt.golang v1.5.0 // indirect
 	github.com/edgexfoundry/device-sdk-go v1.4.0 // indirect
 	github.com/edgexfoundry/go-mod-bootstrap v0.0.60 // indirect
 	github.com/edgexfoundry/go-mod-bootstrap/v4 v4.0.3 // indirect
@@ -75,7 +76,6 @@ require (
 	github.com/josharian/intern v1.0.0 // indirect
 	github.com/kataras/go-events v0.0.3 // indirect
 	github.com/klauspost/compress v1.17.9 // indirect
-	github.com/labstack/echo/v4 v4.13.3 // indirect
 	github.com/labstack/gommon v0.4.2 // indirect
 	github.com/leodido/go-urn v1.4.0 // indirect
 	github.com/lufia/plan9stats v0.0.0-20211012122336-39d0f177ccd0 // indirect
@@ -0,0 +1,320 @@
+// Package accesslog 记录每一帧下发的控制报文（触发的 EdgeX 命令、目标传感器、参数和结果），
+// 以 JSON Lines 只追加写入本地文件，按大小或跨天滚动，供变电站变更管理审计；
+// 最近的记录同时保留在内存中，可经 REST 接口查询。
+package accesslog
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Driver 配置段中与访问审计日志相关的键名
+const (
+	keyEnabled       = "AccessLogEnabled"
+	keyDir           = "AccessLogDir"
+	keyMaxFileSizeMB = "AccessLogMaxFileSizeMB"
+	keyRetentionDays = "AccessLogRetentionDays"
+	keyRecentSize    = "AccessLogRecentSize"
+	keyTimeZone      = "AccessLogTimeZone"
+)
+
+const (
+	filePrefix = "access-"
+	fileSuffix = ".jsonl"
+)
+
+// 下发结果
+const (
+	ResultAcked  = "acked"
+	ResultFailed = "failed"
+)
+
+// Config 访问审计日志配置
+type Config struct {
+	// Enabled 为 false 时只保留内存中的最近记录，不写文件
+	Enabled       bool
+	Dir           string
+	MaxFileSize   int64 // 单个文件最大字节数，超过后滚动
+	RetentionDays int   // 文件保留天数，0 表示不清理
+	RecentSize    int   // 内存中保留的最近记录条数
+	// Location 按该时区的日期跨天滚动，默认本地时区
+	Location *time.Location
+}
+
+// ConfigFromDriver 从 Driver 配置段读取访问审计日志配置，未配置项使用默认值
+func ConfigFromDriver(driverCfg map[string]string) (Config, error) {
+	cfg := Config{
+		Dir:         "./access-log",
+		MaxFileSize: 16 << 20,
+		RecentSize:  200,
+		Location:    time.Local,
+	}
+	if v := driverCfg[keyEnabled]; v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return cfg, fmt.Errorf("%s 配置无效 %q：%w", keyEnabled, v, err)
+		}
+		cfg.Enabled = b
+	}
+	if v := driverCfg[keyDir]; v != "" {
+		cfg.Dir = v
+	}
+	if v := driverCfg[keyMaxFileSizeMB]; v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			return cfg, fmt.Errorf("%s 配置无效 %q", keyMaxFileSizeMB, v)
+		}
+		cfg.MaxFileSize = n << 20
+	}
+	if v := driverCfg[keyRetentionDays]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return cfg, fmt.Errorf("%s 配置无效 %q", keyRetentionDays, v)
+		}
+		cfg.RetentionDays = n
+	}
+	if v := driverCfg[keyRecentSize]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return cfg, fmt.Errorf("%s 配置无效 %q", keyRecentSize, v)
+		}
+		cfg.RecentSize = n
+	}
+	if v := driverCfg[keyTimeZone]; v != "" {
+		loc, err := time.LoadLocation(v)
+		if err != nil {
+			return cfg, fmt.Errorf("%s 配置无效 %q：%w", keyTimeZone, v, err)
+		}
+		cfg.Location = loc
+	}
+	return cfg, nil
+}
+
+// Entry 一帧控制报文的审计记录
+type Entry struct {
+	Time time.Time `json:"time"`
+	// Source 触发方式：write（EdgeX 写命令）、alarm-ack、group、write-verify、audit 等
+	Source string `json:"source"`
+	// WriteID 参数写入队列中的写入 ID，与 write-status 资源中的记录对应
+	WriteID string `json:"writeId,omitempty"`
+	// Device / Resources 触发下发的 EdgeX 命令的设备和资源
+	Device    string         `json:"device,omitempty"`
+	Resources []string       `json:"resources,omitempty"`
+	Values    map[string]any `json:"values,omitempty"`
+	// SensorID 目标传感器；广播时每个目标传感器各记一条，Broadcast 为 true
+	SensorID   string `json:"sensorId"`
+	Broadcast  bool   `json:"broadcast,omitempty"`
+	CtrlType   uint8  `json:"ctrlType"`
+	RequestSet bool   `json:"requestSet"`
+	// Frame 下发的报文（十六进制）
+	Frame      string `json:"frame"`
+	Result     string `json:"result"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// Log 访问审计日志，并发安全
+type Log struct {
+	cfg Config
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+	recent []Entry
+	// now 当前时间，测试时替换
+	now func() time.Time
+}
+
+// New 创建访问审计日志；启用文件时创建目录、读回最近的记录并打开新文件
+func New(cfg Config) (*Log, error) {
+	if cfg.Location == nil {
+		cfg.Location = time.Local
+	}
+	l := &Log{cfg: cfg, now: time.Now}
+	if !cfg.Enabled {
+		return l, nil
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o750); err != nil {
+		return nil, fmt.Errorf("创建访问审计日志目录 %s 失败：%w", cfg.Dir, err)
+	}
+	l.recent = l.loadRecent()
+	if err := l.rotate(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Record 追加一条记录；写文件失败时返回错误，内存中的记录仍然保留。
+// 文件超过大小上限或跨天（按 Config.Location 的日期）时滚动到新文件
+func (l *Log) Record(e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = l.now()
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("序列化访问审计记录失败：%w", err)
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.recent = append(l.recent, e)
+	if len(l.recent) > l.cfg.RecentSize {
+		l.recent = append([]Entry(nil), l.recent[len(l.recent)-l.cfg.RecentSize:]...)
+	}
+	if !l.cfg.Enabled {
+		return nil
+	}
+	if l.file == nil {
+		return fmt.Errorf("访问审计日志已关闭")
+	}
+	if l.size+int64(len(line)) > l.cfg.MaxFileSize || !l.sameDay(l.now(), l.opened) {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("写访问审计日志失败：%w", err)
+	}
+	// 审计记录不能因进程异常退出而丢失
+	return l.file.Sync()
+}
+
+// Filter 查询条件，空字段不过滤
+type Filter struct {
+	Device   string
+	SensorID string
+	Since    time.Time
+	Limit    int
+}
+
+// Recent 返回内存中满足条件的最近记录，按时间先后排列；Limit 大于 0 时只返回最后 Limit 条
+func (l *Log) Recent(f Filter) []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make([]Entry, 0, len(l.recent))
+	for _, e := range l.recent {
+		if f.Device != "" && e.Device != f.Device {
+			continue
+		}
+		if f.SensorID != "" && e.SensorID != f.SensorID {
+			continue
+		}
+		if !f.Since.IsZero() && e.Time.Before(f.Since) {
+			continue
+		}
+		out = append(out, e)
+	}
+	if f.Limit > 0 && len(out) > f.Limit {
+		out = out[len(out)-f.Limit:]
+	}
+	return out
+}
+
+// Close 关闭当前日志文件
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.closeCurrent()
+}
+
+// rotate 关闭当前文件、清理过期文件并打开新文件，调用方需持有锁
+func (l *Log) rotate() error {
+	if err := l.closeCurrent(); err != nil {
+		return err
+	}
+	l.purgeExpired()
+
+	now := l.now()
+	name := filepath.Join(l.cfg.Dir, filePrefix+now.In(l.cfg.Location).Format("20060102-150405.000")+fileSuffix)
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("打开访问审计日志 %s 失败：%w", name, err)
+	}
+	l.file = f
+	l.size = 0
+	l.opened = now
+	return nil
+}
+
+// sameDay 判断两个时间在 Config.Location 中是否为同一天
+func (l *Log) sameDay(t1, t2 time.Time) bool {
+	y1, m1, d1 := t1.In(l.cfg.Location).Date()
+	y2, m2, d2 := t2.In(l.cfg.Location).Date()
+	return y1 == y2 && m1 == m2 && d1 == d2
+}
+
+func (l *Log) closeCurrent() error {
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// files 返回目录中的日志文件，按文件名（即创建时间）排序
+func (l *Log) files() []string {
+	files, _ := filepath.Glob(filepath.Join(l.cfg.Dir, filePrefix+"*"+fileSuffix))
+	sort.Strings(files)
+	return files
+}
+
+// purgeExpired 删除超过保留天数的日志文件
+func (l *Log) purgeExpired() {
+	if l.cfg.RetentionDays <= 0 {
+		return
+	}
+	deadline := l.now().AddDate(0, 0, -l.cfg.RetentionDays)
+	for _, f := range l.files() {
+		if st, err := os.Stat(f); err == nil && st.ModTime().Before(deadline) {
+			_ = os.Remove(f)
+		}
+	}
+}
+
+// loadRecent 从最新的日志文件往前读回最近 RecentSize 条记录，重启后 REST 查询不至于为空
+func (l *Log) loadRecent() []Entry {
+	files := l.files()
+	var out []Entry
+	for i := len(files) - 1; i >= 0 && len(out) < l.cfg.RecentSize; i-- {
+		entries, err := readFile(files[i])
+		if err != nil {
+			continue
+		}
+		out = append(entries, out...)
+	}
+	if len(out) > l.cfg.RecentSize {
+		out = out[len(out)-l.cfg.RecentSize:]
+	}
+	return out
+}
+
+// readFile 读取一个日志文件，跳过无法解析的行（如写入中途断电留下的半行）
+func readFile(name string) ([]Entry, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var out []Entry
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	for sc.Scan() {
+		var e Entry
+		if json.Unmarshal(sc.Bytes(), &e) == nil {
+			out = append(out, e)
+		}
+	}
+	return out, sc.Err()
+}
@@ -0,0 +1,239 @@
+package accesslog
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestConfigFromDriver(t *testing.T) {
+	cfg, err := ConfigFromDriver(map[string]string{})
+	if err != nil || cfg.Enabled || cfg.Dir != "./access-log" || cfg.MaxFileSize != 16<<20 || cfg.RetentionDays != 0 ||
+		cfg.RecentSize != 200 || cfg.Location != time.Local {
+		t.Errorf("默认配置 %+v, %v", cfg, err)
+	}
+	cfg, err = ConfigFromDriver(map[string]string{
+		keyEnabled: "true", keyDir: "/var/lpmp/access", keyMaxFileSizeMB: "2", keyRetentionDays: "90", keyRecentSize: "50", keyTimeZone: "Asia/Shanghai",
+	})
+	if err != nil || !cfg.Enabled || cfg.Dir != "/var/lpmp/access" || cfg.MaxFileSize != 2<<20 || cfg.RetentionDays != 90 ||
+		cfg.RecentSize != 50 || cfg.Location.String() != "Asia/Shanghai" {
+		t.Errorf("配置 %+v, %v", cfg, err)
+	}
+	for _, bad := range []map[string]string{
+		{keyEnabled: "enabled"},
+		{keyMaxFileSizeMB: "0"},
+		{keyRetentionDays: "-1"},
+		{keyRecentSize: "0"},
+		{keyTimeZone: "Mars/Olympus"},
+	} {
+		if _, err := ConfigFromDriver(bad); err == nil {
+			t.Errorf("%v 未报错", bad)
+		}
+	}
+}
+
+func logFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, filePrefix+"*"+fileSuffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// TestRecent 内存中保留最近 RecentSize 条，按设备、传感器、时间过滤，Limit 取最后几条；未启用文件时不写文件
+func TestRecent(t *testing.T) {
+	dir := t.TempDir()
+	l, err := New(Config{Dir: dir, RecentSize: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t0 := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	for i := 0; i < 6; i++ {
+		dev := "tank-" + strconv.Itoa(i%2)
+		if err := l.Record(Entry{Time: t0.Add(time.Duration(i) * time.Minute), Device: dev, SensorID: "S" + strconv.Itoa(i), Result: ResultAcked}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sensors := func(entries []Entry) []string {
+		var out []string
+		for _, e := range entries {
+			out = append(out, e.SensorID)
+		}
+		return out
+	}
+	for _, c := range []struct {
+		f    Filter
+		want []string
+	}{
+		{Filter{}, []string{"S2", "S3", "S4", "S5"}},
+		{Filter{Device: "tank-1"}, []string{"S3", "S5"}},
+		{Filter{SensorID: "S4"}, []string{"S4"}},
+		{Filter{Since: t0.Add(4 * time.Minute)}, []string{"S4", "S5"}},
+		{Filter{Limit: 3}, []string{"S3", "S4", "S5"}},
+		{Filter{Device: "tank-0", Limit: 1}, []string{"S4"}},
+		{Filter{Device: "absent"}, nil},
+	} {
+		if got := sensors(l.Recent(c.f)); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Recent(%+v)=%v，期望 %v", c.f, got, c.want)
+		}
+	}
+	if files := logFiles(t, dir); len(files) != 0 {
+		t.Errorf("未启用文件时写了 %v", files)
+	}
+
+	// 未指定时间的记录取当前时间
+	before := time.Now()
+	l.Record(Entry{SensorID: "S6"})
+	if got := l.Recent(Filter{SensorID: "S6"}); len(got) != 1 || got[0].Time.Before(before) {
+		t.Errorf("记录时间 %+v", got)
+	}
+}
+
+// TestPersistAndReload 启用文件时每条记录写入 JSON Lines；重启后从最新的文件往前读回最近的记录，跳过半行；
+// 超过大小上限时滚动；关闭后写入报错但内存记录保留
+func TestPersistAndReload(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{Enabled: true, Dir: dir, MaxFileSize: 1 << 20, RecentSize: 3}
+	l, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t0 := time.Now()
+	l.now = func() time.Time { return t0 }
+	want := Entry{
+		Time: t0.UTC().Round(0), Source: "write", WriteID: "w-1", Device: "tank", Resources: []string{"alarmHigh"},
+		Values: map[string]any{"alarmHigh": 3.5}, SensorID: "238A0821BEF2", CtrlType: 0x05, RequestSet: true,
+		Frame: "238a0821bef2", Result: ResultFailed, Error: "timeout", DurationMs: 1200,
+	}
+	for i := 0; i < 4; i++ {
+		e := want
+		e.WriteID = "w-" + strconv.Itoa(i)
+		if err := l.Record(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Record(want); err == nil {
+		t.Error("关闭后写入未报错")
+	}
+	// 模拟写入中途断电留下的半行
+	files := logFiles(t, dir)
+	if len(files) != 1 {
+		t.Fatalf("日志文件 %v，期望 1 个", files)
+	}
+	f, err := os.OpenFile(files[0], os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"time":"2026-10-16T08:00:00Z","sour`)
+	f.Close()
+
+	l, err = New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	got := l.Recent(Filter{})
+	var ids []string
+	for _, e := range got {
+		ids = append(ids, e.WriteID)
+	}
+	if !reflect.DeepEqual(ids, []string{"w-1", "w-2", "w-3"}) {
+		t.Fatalf("重启后读回 %v，期望最近 3 条", ids)
+	}
+	want.WriteID = "w-3"
+	if !reflect.DeepEqual(got[2], want) {
+		t.Errorf("读回的记录 %+v，期望 %+v", got[2], want)
+	}
+
+	// 大小滚动：每条记录约 300 字节
+	l.cfg.MaxFileSize = 1000
+	before := len(logFiles(t, dir))
+	for i := 0; i < 4; i++ {
+		l.now = func() time.Time { return t0.Add(time.Duration(i+1) * time.Second) }
+		if err := l.Record(want); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(logFiles(t, dir)) - before; n != 1 {
+		t.Errorf("超过大小上限后新增 %d 个文件，期望 1", n)
+	}
+}
+
+// TestRotateByDay 按配置时区的完整日期滚动：一年后的同一天、UTC 未跨天但本地已跨天时都滚动
+func TestRotateByDay(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skip(err)
+	}
+	dir := t.TempDir()
+	l, err := New(Config{Enabled: true, Dir: dir, MaxFileSize: 1 << 20, RecentSize: 10, Location: shanghai})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	t0 := time.Date(2026, 10, 16, 15, 0, 0, 0, time.UTC) // 上海 23:00
+	steps := []struct {
+		at     time.Time
+		rotate bool
+	}{
+		{t0.Add(30 * time.Minute), false},
+		{t0.Add(time.Hour), true}, // UTC 仍是 10-16，上海已是 10-17
+		{t0.Add(time.Hour).AddDate(1, 0, 0), true},
+	}
+	// 从 t0 打开的文件开始，New 按真实时间打开的文件不一定与 t0 同一天
+	l.now = func() time.Time { return t0 }
+	l.mu.Lock()
+	err = l.rotate()
+	l.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, st := range steps {
+		before := len(logFiles(t, dir))
+		l.now = func() time.Time { return st.at }
+		if err := l.Record(Entry{SensorID: "238A0821BEF2"}); err != nil {
+			t.Fatal(err)
+		}
+		if rotated := len(logFiles(t, dir)) > before; rotated != st.rotate {
+			t.Errorf("第 %d 次写入（%s）滚动=%v，期望 %v", i+1, st.at, rotated, st.rotate)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, filePrefix+"20271017-000000.000"+fileSuffix)); err != nil {
+		t.Errorf("未按上海日期命名: %v", err)
+	}
+}
+
+// TestPurgeExpired 滚动时删除修改时间超过保留天数的文件
+func TestPurgeExpired(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, filePrefix+"20250101-000000.000"+fileSuffix)
+	recent := filepath.Join(dir, filePrefix+"20261010-000000.000"+fileSuffix)
+	for _, f := range []string{old, recent} {
+		if err := os.WriteFile(f, nil, 0o640); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+	os.Chtimes(old, now.AddDate(0, 0, -31), now.AddDate(0, 0, -31))
+	os.Chtimes(recent, now.AddDate(0, 0, -29), now.AddDate(0, 0, -29))
+
+	l, err := New(Config{Enabled: true, Dir: dir, MaxFileSize: 1 << 20, RetentionDays: 30, RecentSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("超过保留天数的文件未删除: %v", err)
+	}
+	if _, err := os.Stat(recent); err != nil {
+		t.Errorf("保留天数内的文件被删除: %v", err)
+	}
+}
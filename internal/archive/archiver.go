@@ -0,0 +1,258 @@
+// Package archive 将解析后的读数以 InfluxDB 行协议追加写入本地文件，
+// 支持按大小滚动和按天数清理，供长时间离线运行的网关事后补录数据。
+package archive
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Driver 配置段中与本地归档相关的键名
+const (
+	keyEnabled       = "ArchiveEnabled"
+	keyFormat        = "ArchiveFormat"
+	keyDir           = "ArchiveDir"
+	keyMaxFileSizeMB = "ArchiveMaxFileSizeMB"
+	keyRetentionDays = "ArchiveRetentionDays"
+	keyTimeZone      = "ArchiveTimeZone"
+)
+
+// FormatInflux 为 InfluxDB 行协议格式
+const FormatInflux = "influx"
+
+const (
+	measurement = "lpmp"
+	filePrefix  = "readings-"
+	fileSuffix  = ".lp"
+)
+
+// Config 保存本地归档配置
+type Config struct {
+	Enabled       bool
+	Format        string
+	Dir           string
+	MaxFileSize   int64 // 单个文件最大字节数，超过后滚动
+	RetentionDays int   // 归档文件保留天数，0 表示不清理
+	// Location 按该时区的日期跨天滚动，默认本地时区
+	Location *time.Location
+}
+
+// ConfigFromDriver 从 Driver 配置段读取归档配置，未配置项使用默认值
+func ConfigFromDriver(driverCfg map[string]string) (Config, error) {
+	cfg := Config{
+		Format:        FormatInflux,
+		Dir:           "./archive",
+		MaxFileSize:   64 << 20,
+		RetentionDays: 30,
+		Location:      time.Local,
+	}
+	if v := driverCfg[keyEnabled]; v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return cfg, fmt.Errorf("%s 配置无效 %q：%w", keyEnabled, v, err)
+		}
+		cfg.Enabled = b
+	}
+	if v := driverCfg[keyFormat]; v != "" {
+		cfg.Format = strings.ToLower(v)
+	}
+	if cfg.Format != FormatInflux {
+		return cfg, fmt.Errorf("不支持的归档格式 %q（当前仅支持 %s）", cfg.Format, FormatInflux)
+	}
+	if v := driverCfg[keyDir]; v != "" {
+		cfg.Dir = v
+	}
+	if v := driverCfg[keyMaxFileSizeMB]; v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			return cfg, fmt.Errorf("%s 配置无效 %q", keyMaxFileSizeMB, v)
+		}
+		cfg.MaxFileSize = n << 20
+	}
+	if v := driverCfg[keyRetentionDays]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return cfg, fmt.Errorf("%s 配置无效 %q", keyRetentionDays, v)
+		}
+		cfg.RetentionDays = n
+	}
+	if v := driverCfg[keyTimeZone]; v != "" {
+		loc, err := time.LoadLocation(v)
+		if err != nil {
+			return cfg, fmt.Errorf("%s 配置无效 %q：%w", keyTimeZone, v, err)
+		}
+		cfg.Location = loc
+	}
+	return cfg, nil
+}
+
+// Archiver 以追加方式写归档文件，并发安全
+type Archiver struct {
+	cfg Config
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+	// now 当前时间，测试时替换
+	now func() time.Time
+}
+
+// New 创建归档目录并打开一个新的归档文件
+func New(cfg Config) (*Archiver, error) {
+	if err := os.MkdirAll(cfg.Dir, 0o750); err != nil {
+		return nil, fmt.Errorf("创建归档目录 %s 失败：%w", cfg.Dir, err)
+	}
+	if cfg.Location == nil {
+		cfg.Location = time.Local
+	}
+	a := &Archiver{cfg: cfg, now: time.Now}
+	if err := a.rotate(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Append 追加一条读数，origin 为纳秒时间戳。
+// 文件超过大小上限或跨天（按 Config.Location 的日期）时滚动到新文件。
+func (a *Archiver) Append(deviceName, resourceName string, value any, origin int64, tags map[string]string) error {
+	line := FormatLine(deviceName, resourceName, value, origin, tags)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file == nil {
+		return fmt.Errorf("归档文件已关闭")
+	}
+	if a.size+int64(len(line)) > a.cfg.MaxFileSize || !a.sameDay(a.now(), a.opened) {
+		if err := a.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := a.file.WriteString(line)
+	a.size += int64(n)
+	return err
+}
+
+// Close 关闭当前归档文件
+func (a *Archiver) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.closeCurrent()
+}
+
+// rotate 关闭当前文件、清理过期文件并打开新文件，调用方需持有锁
+func (a *Archiver) rotate() error {
+	if err := a.closeCurrent(); err != nil {
+		return err
+	}
+	a.purgeExpired()
+
+	now := a.now()
+	name := filepath.Join(a.cfg.Dir, filePrefix+now.In(a.cfg.Location).Format("20060102-150405.000")+fileSuffix)
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("打开归档文件 %s 失败：%w", name, err)
+	}
+	a.file = f
+	a.size = 0
+	a.opened = now
+	return nil
+}
+
+// sameDay 判断两个时间在 Config.Location 中是否为同一天
+func (a *Archiver) sameDay(t1, t2 time.Time) bool {
+	y1, m1, d1 := t1.In(a.cfg.Location).Date()
+	y2, m2, d2 := t2.In(a.cfg.Location).Date()
+	return y1 == y2 && m1 == m2 && d1 == d2
+}
+
+func (a *Archiver) closeCurrent() error {
+	if a.file == nil {
+		return nil
+	}
+	err := a.file.Close()
+	a.file = nil
+	return err
+}
+
+// purgeExpired 删除超过保留天数的归档文件
+func (a *Archiver) purgeExpired() {
+	if a.cfg.RetentionDays <= 0 {
+		return
+	}
+	files, _ := filepath.Glob(filepath.Join(a.cfg.Dir, filePrefix+"*"+fileSuffix))
+	deadline := a.now().AddDate(0, 0, -a.cfg.RetentionDays)
+	for _, f := range files {
+		if st, err := os.Stat(f); err == nil && st.ModTime().Before(deadline) {
+			_ = os.Remove(f)
+		}
+	}
+}
+
+// FormatLine 按 InfluxDB 行协议格式化一条读数：
+// lpmp,device=<设备>,resource=<资源>[,<tag>=<值>...] value=<值> <纳秒时间戳>；
+// 行协议不允许空的标签值，值为空的标签不写入
+func FormatLine(deviceName, resourceName string, value any, origin int64, tags map[string]string) string {
+	var b strings.Builder
+	b.WriteString(measurement)
+	b.WriteString(",device=")
+	b.WriteString(escapeTag(deviceName))
+	b.WriteString(",resource=")
+	b.WriteString(escapeTag(resourceName))
+
+	keys := make([]string, 0, len(tags))
+	for k, v := range tags {
+		if k != "" && v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b.WriteByte(',')
+		b.WriteString(escapeTag(k))
+		b.WriteByte('=')
+		b.WriteString(escapeTag(tags[k]))
+	}
+
+	b.WriteString(" value=")
+	b.WriteString(formatField(value))
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatInt(origin, 10))
+	b.WriteByte('\n')
+	return b.String()
+}
+
+var tagEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+func escapeTag(s string) string {
+	return tagEscaper.Replace(s)
+}
+
+// formatField 按行协议字段类型格式化值：有符号整数加 i 后缀、无符号整数加 u 后缀，字符串加引号
+func formatField(v any) string {
+	switch x := v.(type) {
+	case float32:
+		return strconv.FormatFloat(float64(x), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(x, 'g', -1, 64)
+	case int, int8, int16, int32, int64:
+		return fmt.Sprintf("%di", x)
+	case uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%du", x)
+	case bool:
+		return strconv.FormatBool(x)
+	case []byte:
+		// 原始帧等二进制值按十六进制字符串归档
+		return `"` + hex.EncodeToString(x) + `"`
+	default:
+		s := fmt.Sprint(x)
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+	}
+}
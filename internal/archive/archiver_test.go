@@ -0,0 +1,95 @@
+package archive
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatLine(t *testing.T) {
+	cases := []struct {
+		value any
+		tags  map[string]string
+		want  string
+	}{
+		{float32(2.5), nil, `lpmp,device=dev\ 1,resource=长度 value=2.5 1`},
+		{int16(-3), map[string]string{"quality": "good", "unit": ""}, `lpmp,device=dev\ 1,resource=长度,quality=good value=-3i 1`},
+		{uint8(7), map[string]string{"b": "x=y", "a": "1,2"}, `lpmp,device=dev\ 1,resource=长度,a=1\,2,b=x\=y value=7u 1`},
+		{[]byte{0xAB, 0x01}, nil, `lpmp,device=dev\ 1,resource=长度 value="ab01" 1`},
+		{`say "hi"`, nil, `lpmp,device=dev\ 1,resource=长度 value="say \"hi\"" 1`},
+	}
+	for _, c := range cases {
+		if got := FormatLine("dev 1", "长度", c.value, 1, c.tags); got != c.want+"\n" {
+			t.Errorf("FormatLine(%v, %v) = %q，期望 %q", c.value, c.tags, got, c.want)
+		}
+	}
+}
+
+func TestConfigFromDriver(t *testing.T) {
+	cfg, err := ConfigFromDriver(map[string]string{keyTimeZone: "Asia/Shanghai"})
+	if err != nil || cfg.Location.String() != "Asia/Shanghai" {
+		t.Errorf("时区配置 %v, %v", cfg.Location, err)
+	}
+	for _, bad := range []map[string]string{
+		{keyFormat: "sqlite"},
+		{keyTimeZone: "Mars/Olympus"},
+		{keyMaxFileSizeMB: "0"},
+	} {
+		if _, err := ConfigFromDriver(bad); err == nil {
+			t.Errorf("%v 未报错", bad)
+		}
+	}
+}
+
+// TestRotateByDay 按配置时区的完整日期滚动：一年后的同一天、UTC 未跨天但本地已跨天时都滚动
+func TestRotateByDay(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skip(err)
+	}
+	dir := t.TempDir()
+	a, err := New(Config{Dir: dir, MaxFileSize: 1 << 20, Location: shanghai})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	count := func() int {
+		files, _ := filepath.Glob(filepath.Join(dir, filePrefix+"*"+fileSuffix))
+		return len(files)
+	}
+	t0 := time.Date(2026, 10, 16, 15, 0, 0, 0, time.UTC) // 上海 23:00
+	steps := []struct {
+		at     time.Time
+		rotate bool
+	}{
+		{t0.Add(30 * time.Minute), false},
+		{t0.Add(time.Hour), true}, // UTC 仍是 10-16，上海已是 10-17
+		{t0.Add(time.Hour).AddDate(1, 0, 0), true},
+	}
+	// 从 t0 打开的文件开始，New 按真实时间打开的文件不一定与 t0 同一天
+	a.now = func() time.Time { return t0 }
+	a.mu.Lock()
+	err = a.rotate()
+	a.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, st := range steps {
+		before := count()
+		a.now = func() time.Time { return st.at }
+		if err := a.Append("dev", "长度", 1.0, st.at.UnixNano(), nil); err != nil {
+			t.Fatal(err)
+		}
+		if rotated := count() > before; rotated != st.rotate {
+			t.Errorf("第 %d 次写入（%s）滚动=%v，期望 %v", i+1, st.at, rotated, st.rotate)
+		}
+	}
+	name := filepath.Join(dir, filePrefix+"20271017-000000.000"+fileSuffix)
+	b, err := os.ReadFile(name)
+	if err != nil || strings.Count(string(b), "\n") != 1 {
+		t.Errorf("按上海日期命名的文件 %s: %q, %v", name, b, err)
+	}
+}
@@ -0,0 +1,230 @@
+// Package battery 按传感器上报的电池电量和电压估算放电速率与剩余天数：
+// 窗口内的电量读数做最小二乘拟合得到每天下降的百分点，按当前电量外推到 0；
+// 电量跌破配置的各级门限或电压低于下限时各报一次低电量，电池更换（电量回升）后重新计算。
+package battery
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Driver 配置项
+const (
+	// KeyLowLevels 低电量门限（%），逗号分隔，如 "20,10,5"；电量每跌破一级报一次，空串不按电量报
+	KeyLowLevels = "BatteryLowLevels"
+	// KeyLowVoltage 低电压门限（V），电压低于此值时报一次，空串不按电压报
+	KeyLowVoltage = "BatteryLowVoltage"
+	// KeyTrendWindow 参与放电速率拟合的读数时间范围，如 "168h"
+	KeyTrendWindow = "BatteryTrendWindow"
+
+	defaultLowLevels   = "20,10"
+	defaultTrendWindow = 7 * 24 * time.Hour
+)
+
+const (
+	// minTrendSpan 窗口内读数跨度不足时不估算，避免几分钟内的抖动被外推为放电速率
+	minTrendSpan = time.Hour
+	// replacedJump 电量比上一读数回升超过此值视为更换了电池，清空历史
+	replacedJump = 20
+	// levelRearm / voltageRearm 回升超过门限此值后，该门限可再次报低电量
+	levelRearm   = 5
+	voltageRearm = 0.1
+	// maxSamples 每台设备保留的读数上限
+	maxSamples = 1024
+)
+
+// 低电量的触发来源
+const (
+	SourceLevel   = "level"
+	SourceVoltage = "voltage"
+)
+
+// Config 低电量门限和拟合窗口
+type Config struct {
+	// LowLevels 从高到低排列
+	LowLevels   []float64
+	LowVoltage  float64
+	HasVoltage  bool
+	TrendWindow time.Duration
+}
+
+// ConfigFromDriver 读取低电量门限和拟合窗口
+func ConfigFromDriver(driverCfg map[string]string) (Config, error) {
+	cfg := Config{TrendWindow: defaultTrendWindow}
+	levels, ok := driverCfg[KeyLowLevels]
+	if !ok {
+		levels = defaultLowLevels
+	}
+	for _, item := range strings.Split(levels, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(item, 64)
+		if err != nil || f <= 0 || f >= 100 {
+			return cfg, fmt.Errorf("%s 配置无效 %q，门限应在 0~100 之间", KeyLowLevels, item)
+		}
+		cfg.LowLevels = append(cfg.LowLevels, f)
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(cfg.LowLevels)))
+	if v := strings.TrimSpace(driverCfg[KeyLowVoltage]); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f <= 0 {
+			return cfg, fmt.Errorf("%s 配置无效 %q", KeyLowVoltage, v)
+		}
+		cfg.LowVoltage, cfg.HasVoltage = f, true
+	}
+	if v := driverCfg[KeyTrendWindow]; v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < minTrendSpan {
+			return cfg, fmt.Errorf("%s 配置无效 %q，至少 %s", KeyTrendWindow, v, minTrendSpan)
+		}
+		cfg.TrendWindow = d
+	}
+	return cfg, nil
+}
+
+// Estimate 一台设备当前的放电估算
+type Estimate struct {
+	// SlopePerDay 电量每天的变化（百分点，放电为负），读数不足时为 0
+	SlopePerDay float64
+	// DaysRemaining 按当前速率降到 0 的天数，读数不足或未在放电时为 -1
+	DaysRemaining float64
+	Level         float64
+}
+
+// LowBattery 一次低电量：电量跌破 Threshold（%）或电压低于 Threshold（V）
+type LowBattery struct {
+	Source    string
+	Value     float64
+	Threshold float64
+}
+
+type sample struct {
+	at    time.Time
+	level float64
+}
+
+// deviceState 一台设备的电量历史和已报过的门限
+type deviceState struct {
+	samples []sample
+	// reported 已报过低电量的电量门限
+	reported   map[float64]bool
+	voltageLow bool
+}
+
+// Monitor 按设备跟踪电量读数，并发安全
+type Monitor struct {
+	cfg Config
+
+	mu      sync.Mutex
+	devices map[string]*deviceState
+}
+
+// New 创建 Monitor
+func New(cfg Config) *Monitor {
+	return &Monitor{cfg: cfg, devices: make(map[string]*deviceState)}
+}
+
+func (m *Monitor) state(deviceName string) *deviceState {
+	st, ok := m.devices[deviceName]
+	if !ok {
+		st = &deviceState{reported: make(map[float64]bool)}
+		m.devices[deviceName] = st
+	}
+	return st
+}
+
+// ObserveLevel 记录一个电量读数（%），返回更新后的估算；跌破尚未报过的门限时 low 非空（同时跌破多级只报最低一级）
+func (m *Monitor) ObserveLevel(deviceName string, level float64, at time.Time) (est Estimate, low *LowBattery) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st := m.state(deviceName)
+
+	// 1. 电池更换：清空历史和已报门限
+	if n := len(st.samples); n > 0 && level-st.samples[n-1].level > replacedJump {
+		st.samples = st.samples[:0]
+		st.reported = make(map[float64]bool)
+	}
+
+	// 2. 追加读数，去掉窗口外的和超出上限的
+	st.samples = append(st.samples, sample{at: at, level: level})
+	cut := 0
+	for cut < len(st.samples)-1 && (at.Sub(st.samples[cut].at) > m.cfg.TrendWindow || len(st.samples)-cut > maxSamples) {
+		cut++
+	}
+	st.samples = append(st.samples[:0], st.samples[cut:]...)
+
+	// 3. 门限：回升超过回差后重新可报
+	for _, t := range m.cfg.LowLevels {
+		if st.reported[t] && level > t+levelRearm {
+			delete(st.reported, t)
+		}
+	}
+	for _, t := range m.cfg.LowLevels {
+		if level <= t && !st.reported[t] {
+			st.reported[t] = true
+			low = &LowBattery{Source: SourceLevel, Value: level, Threshold: t}
+		}
+	}
+	return st.estimate(), low
+}
+
+// ObserveVoltage 记录一个电压读数（V），低于 BatteryLowVoltage 且尚未报过时返回低电量
+func (m *Monitor) ObserveVoltage(deviceName string, voltage float64) *LowBattery {
+	if !m.cfg.HasVoltage {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st := m.state(deviceName)
+	switch {
+	case voltage < m.cfg.LowVoltage && !st.voltageLow:
+		st.voltageLow = true
+		return &LowBattery{Source: SourceVoltage, Value: voltage, Threshold: m.cfg.LowVoltage}
+	case voltage > m.cfg.LowVoltage+voltageRearm:
+		st.voltageLow = false
+	}
+	return nil
+}
+
+// Forget 删除设备的电量历史
+func (m *Monitor) Forget(deviceName string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.devices, deviceName)
+}
+
+// estimate 对窗口内的读数做最小二乘拟合，调用方持有锁
+func (st *deviceState) estimate() Estimate {
+	n := len(st.samples)
+	last := st.samples[n-1]
+	est := Estimate{DaysRemaining: -1, Level: last.level}
+	first := st.samples[0]
+	if n < 2 || last.at.Sub(first.at) < minTrendSpan {
+		return est
+	}
+	// x 为距第一个读数的天数
+	var sx, sy, sxx, sxy float64
+	for _, s := range st.samples {
+		x := s.at.Sub(first.at).Hours() / 24
+		sx += x
+		sy += s.level
+		sxx += x * x
+		sxy += x * s.level
+	}
+	fn := float64(n)
+	den := fn*sxx - sx*sx
+	if den == 0 {
+		return est
+	}
+	est.SlopePerDay = (fn*sxy - sx*sy) / den
+	if est.SlopePerDay < 0 {
+		est.DaysRemaining = last.level / -est.SlopePerDay
+	}
+	return est
+}
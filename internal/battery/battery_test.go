@@ -0,0 +1,157 @@
+package battery
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const day = 24 * time.Hour
+
+func TestConfigFromDriver(t *testing.T) {
+	cfg, err := ConfigFromDriver(map[string]string{})
+	if err != nil || !reflect.DeepEqual(cfg.LowLevels, []float64{20, 10}) || cfg.HasVoltage || cfg.TrendWindow != defaultTrendWindow {
+		t.Errorf("默认配置 %+v, %v", cfg, err)
+	}
+	cfg, err = ConfigFromDriver(map[string]string{KeyLowLevels: " 5, 30 ,15,", KeyLowVoltage: "3.3", KeyTrendWindow: "48h"})
+	if err != nil || !reflect.DeepEqual(cfg.LowLevels, []float64{30, 15, 5}) || !cfg.HasVoltage || cfg.LowVoltage != 3.3 || cfg.TrendWindow != 48*time.Hour {
+		t.Errorf("配置 %+v, %v", cfg, err)
+	}
+	// 显式配置为空串时不按电量报
+	if cfg, err := ConfigFromDriver(map[string]string{KeyLowLevels: ""}); err != nil || len(cfg.LowLevels) != 0 {
+		t.Errorf("空门限 %+v, %v", cfg, err)
+	}
+	for _, bad := range []map[string]string{
+		{KeyLowLevels: "0"},
+		{KeyLowLevels: "100"},
+		{KeyLowLevels: "20,low"},
+		{KeyLowVoltage: "-1"},
+		{KeyTrendWindow: "10m"},
+		{KeyTrendWindow: "week"},
+	} {
+		if _, err := ConfigFromDriver(bad); err == nil {
+			t.Errorf("%v 未报错", bad)
+		}
+	}
+}
+
+// TestEstimate 按最小二乘拟合放电速率并外推剩余天数；读数跨度不足 minTrendSpan、未在放电时不外推
+func TestEstimate(t *testing.T) {
+	m := New(Config{TrendWindow: 30 * day})
+	t0 := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	est, _ := m.ObserveLevel("dev", 90, t0)
+	if est.SlopePerDay != 0 || est.DaysRemaining != -1 || est.Level != 90 {
+		t.Errorf("单个读数 %+v", est)
+	}
+	if est, _ = m.ObserveLevel("dev", 89.9, t0.Add(30*time.Minute)); est.DaysRemaining != -1 {
+		t.Errorf("跨度不足 1h 时外推 %+v", est)
+	}
+	// 每天 2 个百分点，带 ±0.5 的抖动
+	m = New(Config{TrendWindow: 30 * day})
+	for i := 0; i <= 10; i++ {
+		jitter := 0.5 * float64(i%2*2-1)
+		est, _ = m.ObserveLevel("dev", 90-2*float64(i)+jitter, t0.Add(time.Duration(i)*day))
+	}
+	if math.Abs(est.SlopePerDay+2) > 0.1 || math.Abs(est.DaysRemaining-est.Level/2) > 1 {
+		t.Errorf("放电估算 %+v，期望约 -2/天、剩余约 %.1f 天", est, est.Level/2)
+	}
+
+	// 电量不变或上升时不外推
+	m = New(Config{TrendWindow: 30 * day})
+	m.ObserveLevel("dev", 50, t0)
+	if est, _ = m.ObserveLevel("dev", 51, t0.Add(day)); est.SlopePerDay <= 0 || est.DaysRemaining != -1 {
+		t.Errorf("电量上升 %+v", est)
+	}
+}
+
+// TestTrendWindow 只用窗口内的读数拟合：窗口外的旧速率不影响当前估算
+func TestTrendWindow(t *testing.T) {
+	m := New(Config{TrendWindow: 2 * day})
+	t0 := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	var est Estimate
+	// 前 5 天每天降 5 个百分点，之后每天降 1 个
+	level := 100.0
+	for i := 0; i <= 10; i++ {
+		est, _ = m.ObserveLevel("dev", level, t0.Add(time.Duration(i)*day))
+		if i < 5 {
+			level -= 5
+		} else {
+			level--
+		}
+	}
+	if math.Abs(est.SlopePerDay+1) > 1e-9 {
+		t.Errorf("斜率 %v，期望只按窗口内的 -1/天", est.SlopePerDay)
+	}
+	if n := len(m.devices["dev"].samples); n != 3 {
+		t.Errorf("保留 %d 个读数，期望窗口内的 3 个", n)
+	}
+}
+
+// TestLowLevels 电量跌破每级门限各报一次，同时跌破多级只报最低一级；回升超过回差后可再报；
+// 电量大幅回升视为更换电池，清空历史和已报门限
+func TestLowLevels(t *testing.T) {
+	m := New(Config{LowLevels: []float64{20, 10, 5}, TrendWindow: 30 * day})
+	t0 := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	steps := []struct {
+		level float64
+		want  float64 // 期望报出的门限，0 表示不报
+	}{
+		{50, 0},
+		{20, 20},
+		{19, 0},
+		{8, 10},
+		{26, 0}, // 超过 20+5，20 和 10 重新可报（回升 18 不算更换电池）
+		{19, 20},
+		{3, 5}, // 同时跌破 10 和 5，只报 5
+		{4, 0},
+		{90, 0}, // 更换电池
+		{18, 20},
+	}
+	for i, s := range steps {
+		est, low := m.ObserveLevel("dev", s.level, t0.Add(time.Duration(i)*day))
+		switch {
+		case s.want == 0 && low != nil:
+			t.Errorf("电量 %v 报了 %+v", s.level, low)
+		case s.want != 0 && (low == nil || *low != LowBattery{Source: SourceLevel, Value: s.level, Threshold: s.want}):
+			t.Errorf("电量 %v 报 %+v，期望门限 %v", s.level, low, s.want)
+		}
+		if s.level == 90 && (len(m.devices["dev"].samples) != 1 || est.DaysRemaining != -1) {
+			t.Errorf("更换电池后历史 %d 个、估算 %+v", len(m.devices["dev"].samples), est)
+		}
+	}
+	// 设备之间互不影响
+	if _, low := m.ObserveLevel("other", 19, t0); low == nil || low.Threshold != 20 {
+		t.Errorf("另一台设备 %+v", low)
+	}
+	m.Forget("dev")
+	if _, low := m.ObserveLevel("dev", 17, t0); low == nil || low.Threshold != 20 {
+		t.Errorf("Forget 后 %+v，期望重新报 20", low)
+	}
+}
+
+// TestLowVoltage 电压低于门限报一次，回升超过回差后可再报；未配置时不报
+func TestLowVoltage(t *testing.T) {
+	if low := New(Config{}).ObserveVoltage("dev", 0.1); low != nil {
+		t.Errorf("未配置电压门限时报了 %+v", low)
+	}
+	m := New(Config{LowVoltage: 3.3, HasVoltage: true})
+	for _, s := range []struct {
+		v    float64
+		want bool
+	}{
+		{3.6, false},
+		{3.2, true},
+		{3.1, false},
+		{3.35, false}, // 回差内
+		{3.2, false},
+		{3.5, false},
+		{3.25, true},
+	} {
+		low := m.ObserveVoltage("dev", s.v)
+		if (low != nil) != s.want || low != nil && *low != (LowBattery{Source: SourceVoltage, Value: s.v, Threshold: 3.3}) {
+			t.Errorf("电压 %v 报 %+v，期望 %v", s.v, low, s.want)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+// Package clockguard 检查网关系统时钟是否可信：没有 RTC 的网关开机时常从 1970 年起计时，
+// 时钟未同步前产生的读数按配置打上 clock=unsynced 标签或暂存，时钟同步后按单调时钟
+// 经过的时长修正 Origin 再发布；配置了 NTP 服务器时，读数的 Origin 还会按测得的偏差修正，
+// 不依赖系统时钟本身被校准。
+package clockguard
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Driver 配置段中与时钟检查相关的键名
+const (
+	keyPolicy    = "ClockPolicy"
+	keyMinEpoch  = "ClockMinEpoch"
+	keyNTPServer = "ClockNTPServer"
+	keyInterval  = "ClockCheckInterval"
+	keyHoldLimit = "ClockHoldLimit"
+)
+
+// 时钟未同步时对读数的处理方式
+const (
+	// PolicyOff 不检查时钟
+	PolicyOff = ""
+	// PolicyTag 照常发布，打上 clock=unsynced 标签
+	PolicyTag = "tag"
+	// PolicyHold 暂存，时钟同步后修正 Origin 再发布（标签 clock=corrected）
+	PolicyHold = "hold"
+)
+
+// 读数上的时钟标签
+const (
+	Tag          = "clock"
+	TagUnsynced  = "unsynced"
+	TagCorrected = "corrected"
+)
+
+// Config 保存时钟检查配置
+type Config struct {
+	// Policy 时钟未同步时的处理方式，PolicyOff 表示不检查
+	Policy string
+	// MinEpoch 系统时钟早于此时刻视为未同步
+	MinEpoch time.Time
+	// NTPServer 可选的 NTP 服务器（host 或 host:port），可达时以其时间为准
+	NTPServer string
+	// Interval 检查间隔
+	Interval time.Duration
+	// HoldLimit PolicyHold 下最多暂存的读数，超出时丢弃最早的
+	HoldLimit int
+}
+
+// Enabled 是否启用时钟检查
+func (c Config) Enabled() bool {
+	return c.Policy != PolicyOff
+}
+
+// ConfigFromDriver 从 Driver 配置段读取时钟检查配置，未配置项使用默认值
+func ConfigFromDriver(driverCfg map[string]string) (Config, error) {
+	cfg := Config{
+		Policy:    driverCfg[keyPolicy],
+		MinEpoch:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		NTPServer: driverCfg[keyNTPServer],
+		Interval:  time.Minute,
+		HoldLimit: 10000,
+	}
+	switch cfg.Policy {
+	case PolicyOff, PolicyTag, PolicyHold:
+	default:
+		return cfg, fmt.Errorf("%s 配置无效 %q，应为 tag / hold 或留空", keyPolicy, cfg.Policy)
+	}
+	if v := driverCfg[keyMinEpoch]; v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return cfg, fmt.Errorf("%s 配置无效 %q，应为 YYYY-MM-DD", keyMinEpoch, v)
+		}
+		cfg.MinEpoch = t
+	}
+	if v := driverCfg[keyInterval]; v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return cfg, fmt.Errorf("%s 配置无效 %q", keyInterval, v)
+		}
+		cfg.Interval = d
+	}
+	if v := driverCfg[keyHoldLimit]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return cfg, fmt.Errorf("%s 配置无效 %q", keyHoldLimit, v)
+		}
+		cfg.HoldLimit = n
+	}
+	return cfg, nil
+}
+
+// Sink 读数的下游，与 frameparser.ValueSink 相同
+type Sink interface {
+	SetValue(deviceName, resourceName string, value any, origin time.Time, tags map[string]string)
+}
+
+// Logger 日志输出
+type Logger interface {
+	Printf(format string, args ...any)
+}
+
+// Stats 时钟检查状态
+type Stats struct {
+	Synced bool `json:"synced"`
+	// OffsetMs NTP 服务器相对本机时钟的偏差，未配置或不可达时为 0
+	OffsetMs float64 `json:"offsetMs"`
+	Held     int     `json:"held"`
+	Dropped  uint64  `json:"dropped"`
+}
+
+// reading 暂存的读数
+type reading struct {
+	device, resource string
+	value            any
+	origin           time.Time
+	tags             map[string]string
+}
+
+// Guard 作为 Sink 包装下游：按时钟状态修正 Origin、打标签或暂存读数
+type Guard struct {
+	cfg  Config
+	next Sink
+	log  Logger
+
+	mu      sync.Mutex
+	synced  bool
+	offset  time.Duration
+	held    []reading
+	dropped uint64
+}
+
+// New 创建时钟检查，初始状态只按 MinEpoch 判断；需调用 Run 查询 NTP 并周期检查
+func New(cfg Config, next Sink, log Logger) *Guard {
+	return &Guard{cfg: cfg, next: next, log: log, synced: !time.Now().Before(cfg.MinEpoch)}
+}
+
+// Run 立即检查一次，之后周期检查时钟，直到 stop 关闭
+func (g *Guard) Run(stop <-chan struct{}) {
+	g.check()
+	t := time.NewTicker(g.cfg.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			g.check()
+		}
+	}
+}
+
+// check 检查一次时钟：NTP 可达或系统时钟不早于 MinEpoch 即视为已同步，刚同步时发布暂存的读数
+func (g *Guard) check() {
+	// 1. NTP 偏差（可选）
+	var offset time.Duration
+	ntpOK := false
+	if g.cfg.NTPServer != "" {
+		off, err := queryNTP(g.cfg.NTPServer, 5*time.Second)
+		if err != nil {
+			g.log.Printf("查询 NTP 服务器 %s 失败: %v", g.cfg.NTPServer, err)
+		} else {
+			offset, ntpOK = off, true
+		}
+	}
+	synced := ntpOK || !time.Now().Before(g.cfg.MinEpoch)
+
+	// 2. 更新状态，刚同步时取出暂存的读数
+	g.mu.Lock()
+	if ntpOK {
+		g.offset = offset
+	}
+	wasSynced := g.synced
+	g.synced = synced
+	var held []reading
+	if synced && !wasSynced {
+		held, g.held = g.held, nil
+	}
+	g.mu.Unlock()
+
+	switch {
+	case synced && !wasSynced:
+		g.log.Printf("系统时钟已同步（NTP 偏差 %s），发布暂存的 %d 个读数", offset, len(held))
+		for _, r := range held {
+			r.tags[Tag] = TagCorrected
+			g.next.SetValue(r.device, r.resource, r.value, g.correct(r.origin), r.tags)
+		}
+	case !synced && wasSynced:
+		g.log.Printf("系统时钟早于 %s，视为未同步", g.cfg.MinEpoch.Format("2006-01-02"))
+	}
+}
+
+// correct 修正 Origin：以当前（加 NTP 偏差后的）时间减去单调时钟经过的时长，
+// 时钟在读数之后被校准（跳变）时同样正确
+func (g *Guard) correct(origin time.Time) time.Time {
+	g.mu.Lock()
+	offset := g.offset
+	g.mu.Unlock()
+	now := time.Now()
+	return now.Add(offset).Add(-now.Sub(origin)).Round(0)
+}
+
+// SetValue 实现 Sink：时钟已同步时修正 Origin 后转发；未同步时按 Policy 打标签转发或暂存
+func (g *Guard) SetValue(deviceName, resourceName string, value any, origin time.Time, tags map[string]string) {
+	g.mu.Lock()
+	synced := g.synced
+	if !synced && g.cfg.Policy == PolicyHold {
+		if len(g.held) >= g.cfg.HoldLimit {
+			g.held = g.held[1:]
+			g.dropped++
+		}
+		g.held = append(g.held, reading{device: deviceName, resource: resourceName, value: value, origin: origin, tags: copyTags(tags)})
+		g.mu.Unlock()
+		return
+	}
+	g.mu.Unlock()
+
+	if !synced {
+		// tags 在同一帧的多个绑定之间共用，复制后再加标签
+		tags = copyTags(tags)
+		tags[Tag] = TagUnsynced
+		g.next.SetValue(deviceName, resourceName, value, origin, tags)
+		return
+	}
+	g.next.SetValue(deviceName, resourceName, value, g.correct(origin), tags)
+}
+
+// Stats 返回当前状态
+func (g *Guard) Stats() Stats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return Stats{
+		Synced:   g.synced,
+		OffsetMs: float64(g.offset) / float64(time.Millisecond),
+		Held:     len(g.held),
+		Dropped:  g.dropped,
+	}
+}
+
+// copyTags 复制标签
+func copyTags(tags map[string]string) map[string]string {
+	out := make(map[string]string, len(tags)+1)
+	for k, v := range tags {
+		out[k] = v
+	}
+	return out
+}
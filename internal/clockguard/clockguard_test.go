@@ -0,0 +1,210 @@
+package clockguard
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type sample struct {
+	resource string
+	value    any
+	origin   time.Time
+	tags     map[string]string
+}
+
+type recorder struct{ got []sample }
+
+func (r *recorder) SetValue(_, resourceName string, value any, origin time.Time, tags map[string]string) {
+	r.got = append(r.got, sample{resourceName, value, origin, tags})
+}
+
+type logs struct{ lines []string }
+
+func (l *logs) Printf(format string, args ...any) {
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+// future 晚于当前时间的 MinEpoch，使系统时钟被视为未同步
+var future = time.Now().AddDate(1, 0, 0)
+
+func TestConfigFromDriver(t *testing.T) {
+	cfg, err := ConfigFromDriver(map[string]string{})
+	if err != nil || cfg.Enabled() || cfg.Interval != time.Minute || cfg.HoldLimit != 10000 ||
+		!cfg.MinEpoch.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("默认配置 %+v, %v", cfg, err)
+	}
+	cfg, err = ConfigFromDriver(map[string]string{
+		keyPolicy: PolicyHold, keyMinEpoch: "2025-06-01", keyNTPServer: "ntp.example", keyInterval: "10s", keyHoldLimit: "5",
+	})
+	if err != nil || !cfg.Enabled() || cfg.Policy != PolicyHold || cfg.NTPServer != "ntp.example" || cfg.Interval != 10*time.Second ||
+		cfg.HoldLimit != 5 || !cfg.MinEpoch.Equal(time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("配置 %+v, %v", cfg, err)
+	}
+	for _, bad := range []map[string]string{
+		{keyPolicy: "drop"},
+		{keyMinEpoch: "2025/06/01"},
+		{keyInterval: "0s"},
+		{keyHoldLimit: "-1"},
+	} {
+		if _, err := ConfigFromDriver(bad); err == nil {
+			t.Errorf("%v 未报错", bad)
+		}
+	}
+}
+
+// TestPolicyTag 时钟未同步时读数照常转发并打 clock=unsynced 标签，不修改调用方共用的标签；同步后不打标签
+func TestPolicyTag(t *testing.T) {
+	next, log := &recorder{}, &logs{}
+	g := New(Config{Policy: PolicyTag, MinEpoch: future}, next, log)
+	if g.Stats().Synced {
+		t.Fatal("MinEpoch 晚于当前时间时视为已同步")
+	}
+	shared := map[string]string{"sensorId": "238A0821BEF2"}
+	origin := time.Now()
+	g.SetValue("dev", "level", 1.5, origin, shared)
+	if len(next.got) != 1 || next.got[0].tags[Tag] != TagUnsynced || next.got[0].tags["sensorId"] != "238A0821BEF2" || !next.got[0].origin.Equal(origin) {
+		t.Fatalf("未同步时转发 %+v", next.got)
+	}
+	if _, ok := shared[Tag]; ok {
+		t.Error("修改了调用方的标签")
+	}
+
+	g.cfg.MinEpoch = time.Time{}
+	g.check()
+	g.SetValue("dev", "level", 2.5, origin, shared)
+	if len(next.got) != 2 || next.got[1].tags[Tag] != "" {
+		t.Errorf("同步后转发 %+v", next.got[1])
+	}
+	if len(log.lines) != 1 || !strings.Contains(log.lines[0], "已同步") {
+		t.Errorf("日志 %q", log.lines)
+	}
+
+	// 时钟回到 MinEpoch 之前
+	g.cfg.MinEpoch = future
+	g.check()
+	if g.Stats().Synced || len(log.lines) != 2 || !strings.Contains(log.lines[1], "未同步") {
+		t.Errorf("时钟回退后状态 %+v，日志 %q", g.Stats(), log.lines)
+	}
+}
+
+// TestPolicyHold 时钟未同步时暂存读数，超出 HoldLimit 丢弃最早的；同步后按顺序发布并打 clock=corrected 标签，
+// Origin 按单调时钟修正
+func TestPolicyHold(t *testing.T) {
+	next := &recorder{}
+	g := New(Config{Policy: PolicyHold, MinEpoch: future, HoldLimit: 3}, next, &logs{})
+	origin := time.Now()
+	for i := range 5 {
+		g.SetValue("dev", "level", i, origin, map[string]string{"n": fmt.Sprint(i)})
+	}
+	if st := g.Stats(); len(next.got) != 0 || st.Held != 3 || st.Dropped != 2 {
+		t.Fatalf("暂存期间下游收到 %d 个，状态 %+v", len(next.got), st)
+	}
+
+	g.cfg.MinEpoch = time.Time{}
+	g.check()
+	var values []any
+	for _, r := range next.got {
+		values = append(values, r.value)
+		if r.tags[Tag] != TagCorrected || r.tags["n"] != fmt.Sprint(r.value) {
+			t.Errorf("发布的标签 %v", r.tags)
+		}
+		// 无 NTP 偏差时修正后的 Origin 与原 Origin 相同（去掉单调时钟读数）
+		if d := r.origin.Sub(origin); d < -time.Millisecond || d > time.Millisecond {
+			t.Errorf("修正后 Origin 相差 %s", d)
+		}
+	}
+	if want := []any{2, 3, 4}; !reflect.DeepEqual(values, want) {
+		t.Errorf("发布 %v，期望 %v", values, want)
+	}
+	if st := g.Stats(); !st.Synced || st.Held != 0 {
+		t.Errorf("同步后状态 %+v", st)
+	}
+	g.check()
+	if len(next.got) != 3 {
+		t.Errorf("再次检查重复发布 %d 个", len(next.got)-3)
+	}
+}
+
+// fakeNTP 在本机 UDP 端口上按 offset 应答 SNTP 请求；reply 可改写应答
+func fakeNTP(t *testing.T, offset time.Duration, reply func(resp []byte) []byte) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("无法监听 UDP: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 48)
+		for {
+			_, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			resp := make([]byte, 48)
+			resp[0] = 0x24 // VN=4, Mode=4（服务器）
+			resp[1] = 2
+			now := time.Now().Add(offset)
+			putNTPTime(resp[32:40], now)
+			putNTPTime(resp[40:48], now)
+			if reply != nil {
+				resp = reply(resp)
+			}
+			_, _ = conn.WriteTo(resp, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func putNTPTime(b []byte, t time.Time) {
+	binary.BigEndian.PutUint32(b[0:4], uint32(t.Unix()+ntpEpochOffset))
+	binary.BigEndian.PutUint32(b[4:8], uint32((int64(t.Nanosecond())<<32)/1e9))
+}
+
+func TestNTPTime(t *testing.T) {
+	want := time.Date(2026, 10, 16, 8, 30, 0, 250_000_000, time.UTC)
+	b := make([]byte, 8)
+	putNTPTime(b, want)
+	if got := ntpTime(b); got.Sub(want).Abs() > time.Microsecond {
+		t.Errorf("ntpTime=%v，期望 %v", got, want)
+	}
+}
+
+func TestQueryNTP(t *testing.T) {
+	off, err := queryNTP(fakeNTP(t, time.Hour, nil), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := off - time.Hour; d.Abs() > 100*time.Millisecond {
+		t.Errorf("偏差 %s，期望约 1h", off)
+	}
+	for name, reply := range map[string]func([]byte) []byte{
+		"不足 48 字节": func(resp []byte) []byte { return resp[:40] },
+		"模式":       func(resp []byte) []byte { resp[0] = 0x23; return resp },
+		"stratum":  func(resp []byte) []byte { resp[1] = 0; return resp },
+	} {
+		if _, err := queryNTP(fakeNTP(t, 0, reply), time.Second); err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("%s: err=%v", name, err)
+		}
+	}
+}
+
+// TestNTPOffset NTP 可达时即使系统时钟早于 MinEpoch 也视为已同步，读数 Origin 加上测得的偏差
+func TestNTPOffset(t *testing.T) {
+	next := &recorder{}
+	g := New(Config{Policy: PolicyTag, MinEpoch: future, NTPServer: fakeNTP(t, time.Hour, nil)}, next, &logs{})
+	g.check()
+	st := g.Stats()
+	if !st.Synced || (st.OffsetMs-3.6e6) > 100 || (st.OffsetMs-3.6e6) < -100 {
+		t.Fatalf("状态 %+v，期望已同步、偏差约 1h", st)
+	}
+	origin := time.Now()
+	g.SetValue("dev", "level", 1, origin, nil)
+	if d := next.got[0].origin.Sub(origin) - time.Hour; d.Abs() > 100*time.Millisecond || next.got[0].tags[Tag] != "" {
+		t.Errorf("Origin 修正 %s、标签 %v，期望 +1h、无标签", next.got[0].origin.Sub(origin), next.got[0].tags)
+	}
+}
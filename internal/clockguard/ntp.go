@@ -0,0 +1,63 @@
+package clockguard
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+)
+
+// ntpEpochOffset NTP 纪元（1900-01-01）到 Unix 纪元的秒数
+const ntpEpochOffset = 2208988800
+
+// queryNTP 向 server 发送一次 SNTP 请求（RFC 4330），返回服务器时间相对本机时钟的偏差：
+// 服务器时间 ≈ 本机时间 + offset。server 未带端口时使用 123
+func queryNTP(server string, timeout time.Duration) (time.Duration, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "123")
+	}
+	conn, err := net.DialTimeout("udp", server, timeout)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return 0, err
+	}
+
+	// 1. 请求：LI=0, VN=4, Mode=3（客户端）
+	req := make([]byte, 48)
+	req[0] = 0x23
+	t0 := time.Now()
+	if _, err := conn.Write(req); err != nil {
+		return 0, err
+	}
+	resp := make([]byte, 48)
+	n, err := conn.Read(resp)
+	t3 := t0.Add(time.Since(t0))
+	if err != nil {
+		return 0, err
+	}
+	if n < 48 {
+		return 0, fmt.Errorf("NTP 应答长度 %d 不足 48 字节", n)
+	}
+	if mode := resp[0] & 0x07; mode != 4 {
+		return 0, fmt.Errorf("NTP 应答模式 %d 不是服务器", mode)
+	}
+	if stratum := resp[1]; stratum == 0 || stratum > 15 {
+		return 0, fmt.Errorf("NTP 服务器未同步（stratum=%d）", stratum)
+	}
+
+	// 2. 偏差 = ((t1 - t0) + (t2 - t3)) / 2，t1/t2 为服务器接收/发送时间
+	t1 := ntpTime(resp[32:40])
+	t2 := ntpTime(resp[40:48])
+	return (t1.Sub(t0) + t2.Sub(t3)) / 2, nil
+}
+
+// ntpTime 解析 64 位 NTP 时间戳（32 位秒 + 32 位小数）
+func ntpTime(b []byte) time.Time {
+	sec := binary.BigEndian.Uint32(b[0:4])
+	frac := binary.BigEndian.Uint32(b[4:8])
+	nsec := (int64(frac) * 1e9) >> 32
+	return time.Unix(int64(sec)-ntpEpochOffset, nsec)
+}
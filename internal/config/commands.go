@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ResourceOperation 命令中的单个资源操作
+type ResourceOperation struct {
+	DeviceResource string `yaml:"deviceResource"`
+	// DefaultValue 请求中未给出该资源时使用的值，也用于资源本身未声明默认值时的初始值
+	DefaultValue string `yaml:"defaultValue"`
+	// Mappings 读数到显示值的映射，由 SDK 处理，这里只保留定义
+	Mappings map[string]string `yaml:"mappings"`
+}
+
+// DeviceCommand 对应 Profile 文件中的单个命令条目
+type DeviceCommand struct {
+	Name               string              `yaml:"name"`
+	IsHidden           bool                `yaml:"isHidden"`
+	ReadWrite          string              `yaml:"readWrite"`
+	ResourceOperations []ResourceOperation `yaml:"resourceOperations"`
+}
+
+// commandsMap 各设备的命令定义，由 resourcesMu 保护
+var commandsMap = make(map[string][]DeviceCommand)
+
+// GetDeviceCommands 并发安全地获取指定设备的命令列表
+func GetDeviceCommands(deviceName string) ([]DeviceCommand, bool) {
+	resourcesMu.RLock()
+	defer resourcesMu.RUnlock()
+	cmds, ok := commandsMap[deviceName]
+	return cmds, ok
+}
+
+// validateDeviceCommands 检查命令：名称非空且不与资源或其它命令重名，
+// 资源操作引用的资源存在，且资源的读写权限覆盖命令的 readWrite
+func validateDeviceCommands(deviceName string, resources []DeviceResource, commands []DeviceCommand) error {
+	byName := make(map[string]DeviceResource, len(resources))
+	for _, dr := range resources {
+		byName[dr.Name] = dr
+	}
+	seen := make(map[string]bool, len(commands))
+	for _, cmd := range commands {
+		if cmd.Name == "" {
+			return fmt.Errorf("设备 %s 存在未命名的命令", deviceName)
+		}
+		if _, ok := byName[cmd.Name]; ok || seen[cmd.Name] {
+			return fmt.Errorf("设备 %s 命令 %s 与已有资源或命令重名", deviceName, cmd.Name)
+		}
+		seen[cmd.Name] = true
+		if len(cmd.ResourceOperations) == 0 {
+			return fmt.Errorf("设备 %s 命令 %s 没有资源操作", deviceName, cmd.Name)
+		}
+		rw := strings.ToUpper(cmd.ReadWrite)
+		for _, op := range cmd.ResourceOperations {
+			dr, ok := byName[op.DeviceResource]
+			if !ok {
+				return fmt.Errorf("设备 %s 命令 %s 引用了未定义的资源 %q", deviceName, cmd.Name, op.DeviceResource)
+			}
+			have := strings.ToUpper(dr.Properties.ReadWrite)
+			for _, p := range []string{"R", "W"} {
+				if strings.Contains(rw, p) && !strings.Contains(have, p) {
+					return fmt.Errorf("设备 %s 命令 %s（readWrite=%q）引用的资源 %s 的 readWrite 为 %q",
+						deviceName, cmd.Name, cmd.ReadWrite, dr.Name, dr.Properties.ReadWrite)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// applyCommandDefaults 命令引用的资源未声明 defaultValue 时，以资源操作的 defaultValue 作为初始值，
+// 避免值槽中是空字符串而不是资源类型的值；调用前须已通过 validateDeviceCommands
+func applyCommandDefaults(deviceName string, values map[string]any, resources []DeviceResource, commands []DeviceCommand) error {
+	byName := make(map[string]DeviceResource, len(resources))
+	for _, dr := range resources {
+		byName[dr.Name] = dr
+	}
+	for _, cmd := range commands {
+		for _, op := range cmd.ResourceOperations {
+			dr := byName[op.DeviceResource]
+			if dr.Properties.DefaultValue != "" || op.DefaultValue == "" {
+				continue
+			}
+			v, err := parseDefaultValue(op.DefaultValue, dr.Properties.ValueType)
+			if err != nil {
+				return fmt.Errorf("设备 %s 命令 %s 资源 %s 的 defaultValue %q 无效：%w", deviceName, cmd.Name, dr.Name, op.DefaultValue, err)
+			}
+			values[dr.Name] = v
+		}
+	}
+	return nil
+}
@@ -1,10 +1,12 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"strconv"
+	"sort"
+	"strings"
 	"sync"
 
 	"gopkg.in/yaml.v3"
@@ -32,56 +34,36 @@ type ResourceProperty struct {
 }
 
 // DeviceResource 对应 Profile 文件中的单个资源条目
-// 包含名称、隐藏标志、描述和属性字段
+// 包含名称、隐藏标志、描述、属性字段和自定义 attributes
 type DeviceResource struct {
 	Name        string           `yaml:"name"`
 	IsHidden    bool             `yaml:"isHidden"`
 	Description string           `yaml:"description"`
 	Properties  ResourceProperty `yaml:"properties"`
+	Attributes  map[string]any   `yaml:"attributes"`
 }
 
-// profileYAML 对应 Profile 文件顶层，仅解析 deviceResources 列表
+// profileYAML 对应 Profile 文件顶层，解析 deviceResources 和 deviceCommands 列表
 type profileYAML struct {
 	DeviceResources []DeviceResource `yaml:"deviceResources"`
+	DeviceCommands  []DeviceCommand  `yaml:"deviceCommands"`
 }
 
 var (
-	// mu 保护下面的静态资源表和运行时值表
-	mu sync.RWMutex
+	// resourcesMu 保护静态资源表 resourcesMap 和参量类型 → 资源名映射 paramResourceMap；
+	// 运行时值表按设备分片，见 values.go
+	resourcesMu sync.RWMutex
 	// resourcesMap 存储所有设备的静态资源定义，key 为设备逻辑名称
 	resourcesMap = make(map[string][]DeviceResource)
-	// valuesMap 存储所有设备的运行时资源值，key: 设备名称 → (资源名称 → value)
-	valuesMap = make(map[string]map[string]interface{})
 )
 
-// parseDefaultValue 根据 ValueType 将 DefaultValue 字符串转换为对应类型
-func parseDefaultValue(valStr, vt string) interface{} {
-	switch vt {
-	case "Float32":
-		if f, err := strconv.ParseFloat(valStr, 32); err == nil {
-			return float32(f)
-		}
-	case "Uint16":
-		if u, err := strconv.ParseUint(valStr, 10, 16); err == nil {
-			return uint16(u)
-		}
-	case "Uint8":
-		if u, err := strconv.ParseUint(valStr, 10, 8); err == nil {
-			return uint8(u)
-		}
-	case "Bool":
-		if b, err := strconv.ParseBool(valStr); err == nil {
-			return b
-		}
-	}
-	// 其它类型保留字符串
-	return valStr
-}
-
 // InitDeviceResources 初始化静态资源定义及默认运行时值：
 // 1. 读取并解析 devices.yaml，获取所有设备条目
-// 2. 遍历每个 entry，根据 ProfileName 加载 Profile 文件，解析 deviceResources
-// 3. 填充全局 maps，并将 DefaultValue 作为初始值写入 valuesMap
+// 2. 遍历每个 entry，根据 ProfileName 加载 Profile 文件，解析 deviceResources 和 deviceCommands，
+// 检查命令引用的资源存在且读写权限匹配
+// 3. 填充全局 maps，并将 DefaultValue（资源未声明时取命令资源操作的 defaultValue）作为初始值写入运行时值表
+// 4. 根据资源的 parameterType 属性建立参量类型 → 资源名映射，并编译输出变换属性和派生资源表达式
+// 5. 检查映射到协议参量的资源 valueType 与参数表一致，全部设备加载完后一次报告所有不一致项
 func InitDeviceResources(devicesPath, profilesDir string) error {
 	// 读取 devices.yaml
 	raw, err := os.ReadFile(devicesPath)
@@ -94,84 +76,220 @@ func InitDeviceResources(devicesPath, profilesDir string) error {
 		return fmt.Errorf("解析 devices.yaml 失败：%w", err)
 	}
 
-	mu.Lock()
-	defer mu.Unlock()
+	resourcesMu.Lock()
+	defer resourcesMu.Unlock()
+	var mismatches []string
 	// 加载并写入静态资源和默认值表
 	for _, entry := range devs.DeviceList {
-		profileFile := filepath.Join(profilesDir, entry.ProfileName+".yaml")
-		rawProfile, err := os.ReadFile(profileFile)
+		m, err := loadDeviceEntry(entry, profilesDir)
 		if err != nil {
-			return fmt.Errorf("无法读取 Profile 文件 %s：%w", profileFile, err)
-		}
-		var prof profileYAML
-		if err := yaml.Unmarshal(rawProfile, &prof); err != nil {
-			return fmt.Errorf("解析 Profile 文件 %s 失败：%w", profileFile, err)
+			return err
 		}
-		// 保存静态定义
-		resourcesMap[entry.Name] = prof.DeviceResources
-		// 初始化运行时值为 DefaultValue
-		valuesMap[entry.Name] = make(map[string]interface{}, len(prof.DeviceResources))
-		for _, dr := range prof.DeviceResources {
-			valuesMap[entry.Name][dr.Name] = parseDefaultValue(dr.Properties.DefaultValue, dr.Properties.ValueType)
+		mismatches = append(mismatches, m...)
+	}
+	return valueTypeError(mismatches)
+}
+
+// LoadDeviceProfile 为不在 devices.yaml 中的设备（如按模板自动创建的设备）加载 Profile，
+// 与 InitDeviceResources 中的单个条目相同；设备已加载时重新加载
+func LoadDeviceProfile(deviceName, profileName, profilesDir string) error {
+	resourcesMu.Lock()
+	defer resourcesMu.Unlock()
+	mismatches, err := loadDeviceEntry(DeviceEntry{Name: deviceName, ProfileName: profileName}, profilesDir)
+	if err != nil {
+		return err
+	}
+	return valueTypeError(mismatches)
+}
+
+// loadDeviceEntry 加载单个设备条目的 Profile，返回 valueType 与参数表不一致的项；调用方持有 resourcesMu
+func loadDeviceEntry(entry DeviceEntry, profilesDir string) ([]string, error) {
+	profileFile := filepath.Join(profilesDir, entry.ProfileName+".yaml")
+	rawProfile, err := os.ReadFile(profileFile)
+	if err != nil {
+		return nil, fmt.Errorf("无法读取 Profile 文件 %s：%w", profileFile, err)
+	}
+	var prof profileYAML
+	if err := yaml.Unmarshal(rawProfile, &prof); err != nil {
+		return nil, fmt.Errorf("解析 Profile 文件 %s 失败：%w", profileFile, err)
+	}
+	if err := validateDeviceCommands(entry.Name, prof.DeviceResources, prof.DeviceCommands); err != nil {
+		return nil, fmt.Errorf("Profile 文件 %s：%w", profileFile, err)
+	}
+	// 保存静态定义
+	resourcesMap[entry.Name] = prof.DeviceResources
+	commandsMap[entry.Name] = prof.DeviceCommands
+	// 按 parameterType 属性建立参量类型到资源的映射
+	if err := buildParamResourceIndex(entry.Name, prof.DeviceResources); err != nil {
+		return nil, err
+	}
+	mismatches := checkParamValueTypes(entry.Name, prof.DeviceResources)
+	// 编译 multiply / offset / round / enumMap 输出变换
+	if err := buildTransformIndex(entry.Name, prof.DeviceResources); err != nil {
+		return nil, err
+	}
+	// 编译 expression 派生资源
+	if err := buildDerivedIndex(entry.Name, prof.DeviceResources); err != nil {
+		return nil, err
+	}
+	// 初始化运行时值为 DefaultValue
+	values := make(map[string]interface{}, len(prof.DeviceResources))
+	for _, dr := range prof.DeviceResources {
+		v, err := parseDefaultValue(dr.Properties.DefaultValue, dr.Properties.ValueType)
+		if err != nil {
+			return nil, fmt.Errorf("设备 %s 资源 %s 的 defaultValue %q 无效：%w", entry.Name, dr.Name, dr.Properties.DefaultValue, err)
 		}
+		values[dr.Name] = v
 	}
-	return nil
+	if err := applyCommandDefaults(entry.Name, values, prof.DeviceResources, prof.DeviceCommands); err != nil {
+		return nil, err
+	}
+	replaceDeviceValues(entry.Name, values)
+	return mismatches, nil
+}
+
+// DeviceNames 返回已加载资源定义的设备名（devices.yaml 中的设备及按 profile 加载的设备），按名称排序
+func DeviceNames() []string {
+	resourcesMu.RLock()
+	defer resourcesMu.RUnlock()
+	names := make([]string, 0, len(resourcesMap))
+	for name := range resourcesMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
 // GetDeviceResources 并发安全地获取指定设备的静态资源列表
 // 返回值: []DeviceResource, bool(是否存在)
 func GetDeviceResources(deviceName string) ([]DeviceResource, bool) {
-	mu.RLock()
-	defer mu.RUnlock()
+	resourcesMu.RLock()
+	defer resourcesMu.RUnlock()
 	res, ok := resourcesMap[deviceName]
 	return res, ok
 }
 
-// SetDeviceValue 并发安全地写入解析后的单个资源值
+// HasDeviceResource 判断设备的 Profile 是否定义了指定资源
+func HasDeviceResource(deviceName, resourceName string) bool {
+	resourcesMu.RLock()
+	defer resourcesMu.RUnlock()
+	for _, r := range resourcesMap[deviceName] {
+		if r.Name == resourceName {
+			return true
+		}
+	}
+	return false
+}
+
+// ResourceValueType 返回设备资源在 Profile 中声明的 valueType，资源不存在时返回 false
+func ResourceValueType(deviceName, resourceName string) (string, bool) {
+	resourcesMu.RLock()
+	defer resourcesMu.RUnlock()
+	for _, r := range resourcesMap[deviceName] {
+		if r.Name == resourceName {
+			return r.Properties.ValueType, true
+		}
+	}
+	return "", false
+}
+
+// ErrReadOnlyResource 资源在 Profile 中声明为只读（readWrite 不含 W）
+var ErrReadOnlyResource = errors.New("资源为只读")
+
+// ErrUnknownResource 设备的 Profile 中没有该资源
+var ErrUnknownResource = errors.New("资源未定义")
+
+// Writable 判断资源的 readWrite 属性是否允许外部写入（"W" 或 "RW"）
+func (p ResourceProperty) Writable() bool {
+	return strings.Contains(strings.ToUpper(p.ReadWrite), "W")
+}
+
+// CheckWritable 检查外部（REST/命令）写入是否被 Profile 允许；
+// 设备未从 devices.yaml 加载静态定义时无法判断，不做限制
+func CheckWritable(deviceName, resourceName string) error {
+	resourcesMu.RLock()
+	defer resourcesMu.RUnlock()
+	resources, ok := resourcesMap[deviceName]
+	if !ok {
+		return nil
+	}
+	for _, r := range resources {
+		if r.Name != resourceName {
+			continue
+		}
+		if !r.Properties.Writable() {
+			return fmt.Errorf("%w: %s.%s（readWrite=%q）", ErrReadOnlyResource, deviceName, resourceName, r.Properties.ReadWrite)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: %s.%s", ErrUnknownResource, deviceName, resourceName)
+}
+
+// WriteDeviceValue 外部写入单个资源值：先按 Profile 检查读写权限，只读资源返回 ErrReadOnlyResource。
+// 解析器和驱动内部维护的状态（含只读资源）使用 SetDeviceValue
+func WriteDeviceValue(deviceName, resourceName string, value interface{}) error {
+	if err := CheckWritable(deviceName, resourceName); err != nil {
+		return err
+	}
+	SetDeviceValue(deviceName, resourceName, value)
+	return nil
+}
+
+// SetDeviceValue 并发安全地写入解析后的单个资源值，不检查读写权限
 func SetDeviceValue(deviceName, resourceName string, value interface{}) {
-	mu.Lock()
-	defer mu.Unlock()
-	if _, ok := valuesMap[deviceName]; !ok {
-		valuesMap[deviceName] = make(map[string]interface{})
+	s := shardFor(deviceName)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.device(deviceName).values[resourceName] = value
+}
+
+// IncDeviceCounter 并发安全地将 Uint32 计数资源加一，返回新值；
+// 设备未定义该资源时返回 false，不会新建资源
+func IncDeviceCounter(deviceName, resourceName string) (uint32, bool) {
+	s := shardFor(deviceName)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.devices[deviceName]
+	if !ok {
+		return 0, false
+	}
+	cur, ok := d.values[resourceName]
+	if !ok {
+		return 0, false
 	}
-	valuesMap[deviceName][resourceName] = value
+	n, _ := cur.(uint32)
+	n++
+	d.values[resourceName] = n
+	return n, true
 }
 
 // GetDeviceValues 并发安全地获取指定设备的所有运行时资源值
 // 返回值: map[resourceName]value, bool(是否存在)
 func GetDeviceValues(deviceName string) (map[string]interface{}, bool) {
-	mu.RLock()
-	defer mu.RUnlock()
-	vals, ok := valuesMap[deviceName]
+	s := shardFor(deviceName)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	d, ok := s.devices[deviceName]
 	if !ok {
 		return nil, false
 	}
 	// 返回副本防止外部修改原表
-	copyMap := make(map[string]interface{}, len(vals))
-	for k, v := range vals {
+	copyMap := make(map[string]interface{}, len(d.values))
+	for k, v := range d.values {
 		copyMap[k] = v
 	}
 	return copyMap, true
 }
 
-// CopyDeviceValues 复制 valuesMap 中 srcDevice 的所有资源值到 dstDevice
+// CopyDeviceValues 复制 srcDevice 的所有资源值到 dstDevice（整体替换 dstDevice 的值）
 func CopyDeviceValues(srcDevice, dstDevice string) error {
-	mu.Lock() // 如果你在并发场景下要保护全局 map
-	defer mu.Unlock()
-
-	// 1. 检查源设备是否存在
-	srcMap, ok := valuesMap[srcDevice]
+	// 1. 复制源设备的值（浅拷贝）；两台设备可能在不同分片，先读后写，不同时持有两把锁
+	newMap, ok := GetDeviceValues(srcDevice)
 	if !ok {
 		return fmt.Errorf("源设备 %s 不存在", srcDevice)
 	}
 
-	// 2. 创建并填充新的内层 map（浅拷贝）
-	newMap := make(map[string]interface{}, len(srcMap))
-	for resource, val := range srcMap {
-		newMap[resource] = val
-	}
-
-	// 3. 把新 map 挂到 dstDevice
-	valuesMap[dstDevice] = newMap
+	// 2. 把新 map 挂到 dstDevice
+	replaceDeviceValues(dstDevice, newMap)
 	return nil
 }
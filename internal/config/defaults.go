@@ -0,0 +1,164 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseDefaultValue 根据 ValueType 将 DefaultValue 字符串转换为 CommandValue 要求的 Go 类型：
+// 数值和 Bool 为空时取零值，数组为 JSON 数组（如 "[1, 2, 3]"），Object 为 JSON 对象，Binary 为原始字节；
+// 无法解析或 ValueType 不支持时返回错误
+func parseDefaultValue(valStr, vt string) (any, error) {
+	s := strings.TrimSpace(valStr)
+	switch vt {
+	case "String":
+		return valStr, nil
+	case "Binary":
+		return []byte(valStr), nil
+	case "Object":
+		obj := map[string]any{}
+		if s == "" {
+			return obj, nil
+		}
+		if err := json.Unmarshal([]byte(s), &obj); err != nil {
+			return nil, fmt.Errorf("不是合法的 JSON 对象：%w", err)
+		}
+		return obj, nil
+	case "Bool", "Float32", "Float64",
+		"Int8", "Int16", "Int32", "Int64",
+		"Uint8", "Uint16", "Uint32", "Uint64":
+		if s == "" {
+			s = "0"
+			if vt == "Bool" {
+				s = "false"
+			}
+		}
+		return parseScalar(s, vt)
+	case "StringArray":
+		return parseArray(s, func(raw json.RawMessage) (string, error) {
+			var v string
+			err := json.Unmarshal(raw, &v)
+			return v, err
+		})
+	case "BoolArray":
+		return parseArrayOf[bool](s, "Bool")
+	case "Float32Array":
+		return parseArrayOf[float32](s, "Float32")
+	case "Float64Array":
+		return parseArrayOf[float64](s, "Float64")
+	case "Int8Array":
+		return parseArrayOf[int8](s, "Int8")
+	case "Int16Array":
+		return parseArrayOf[int16](s, "Int16")
+	case "Int32Array":
+		return parseArrayOf[int32](s, "Int32")
+	case "Int64Array":
+		return parseArrayOf[int64](s, "Int64")
+	case "Uint8Array":
+		return parseArrayOf[uint8](s, "Uint8")
+	case "Uint16Array":
+		return parseArrayOf[uint16](s, "Uint16")
+	case "Uint32Array":
+		return parseArrayOf[uint32](s, "Uint32")
+	case "Uint64Array":
+		return parseArrayOf[uint64](s, "Uint64")
+	}
+	return nil, fmt.Errorf("不支持的 valueType %q", vt)
+}
+
+// ParseJSONValue 把 json.Marshal 得到的读数值按 ValueType 还原为 CommandValue 要求的 Go 类型
+// （JSON 解码后数值一律为 float64，需要按类型重新解析）；Binary 为 base64 字符串
+func ParseJSONValue(raw []byte, vt string) (any, error) {
+	switch vt {
+	case "String":
+		var v string
+		err := json.Unmarshal(raw, &v)
+		return v, err
+	case "Binary":
+		var v []byte
+		err := json.Unmarshal(raw, &v)
+		return v, err
+	case "Object":
+		var v map[string]any
+		err := json.Unmarshal(raw, &v)
+		return v, err
+	case "Bool", "Float32", "Float64",
+		"Int8", "Int16", "Int32", "Int64",
+		"Uint8", "Uint16", "Uint32", "Uint64":
+		return parseScalar(string(raw), vt)
+	}
+	if strings.HasSuffix(vt, "Array") {
+		return parseDefaultValue(string(raw), vt)
+	}
+	return nil, fmt.Errorf("不支持的 valueType %q", vt)
+}
+
+// parseScalar 解析单个数值或布尔值
+func parseScalar(s, vt string) (any, error) {
+	switch vt {
+	case "Bool":
+		return strconv.ParseBool(s)
+	case "Float32":
+		f, err := strconv.ParseFloat(s, 32)
+		return float32(f), err
+	case "Float64":
+		return strconv.ParseFloat(s, 64)
+	case "Int8":
+		n, err := strconv.ParseInt(s, 10, 8)
+		return int8(n), err
+	case "Int16":
+		n, err := strconv.ParseInt(s, 10, 16)
+		return int16(n), err
+	case "Int32":
+		n, err := strconv.ParseInt(s, 10, 32)
+		return int32(n), err
+	case "Int64":
+		return strconv.ParseInt(s, 10, 64)
+	case "Uint8":
+		n, err := strconv.ParseUint(s, 10, 8)
+		return uint8(n), err
+	case "Uint16":
+		n, err := strconv.ParseUint(s, 10, 16)
+		return uint16(n), err
+	case "Uint32":
+		n, err := strconv.ParseUint(s, 10, 32)
+		return uint32(n), err
+	case "Uint64":
+		return strconv.ParseUint(s, 10, 64)
+	}
+	return nil, fmt.Errorf("不支持的 valueType %q", vt)
+}
+
+// parseArrayOf 解析元素为数值或布尔值的 JSON 数组
+func parseArrayOf[T any](s, elemType string) ([]T, error) {
+	return parseArray(s, func(raw json.RawMessage) (T, error) {
+		var zero T
+		v, err := parseScalar(string(raw), elemType)
+		if err != nil {
+			return zero, err
+		}
+		return v.(T), nil
+	})
+}
+
+// parseArray 解析 JSON 数组，逐个元素用 parse 转换；空字符串为空数组
+func parseArray[T any](s string, parse func(json.RawMessage) (T, error)) ([]T, error) {
+	out := []T{}
+	if s == "" {
+		return out, nil
+	}
+	var raws []json.RawMessage
+	if err := json.Unmarshal([]byte(s), &raws); err != nil {
+		return nil, fmt.Errorf("不是合法的 JSON 数组：%w", err)
+	}
+	for i, raw := range raws {
+		v, err := parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 个元素 %s 无效：%w", i, raw, err)
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
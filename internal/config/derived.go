@@ -0,0 +1,197 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/expr"
+)
+
+// ExpressionAttr 派生资源属性：由同一设备其它资源计算得到的表达式，如 "voltage * current"；
+// 含 "-" 的资源名写在花括号中，如 "{water-level} / 100"。任一输入更新后重新计算
+const ExpressionAttr = "expression"
+
+// derived 一个派生资源
+type derived struct {
+	name      string
+	expr      *expr.Expr
+	valueType string
+}
+
+// derivedIndex 一个设备的派生资源，dependents 为输入资源 → 直接依赖它的派生资源，
+// order 为全部派生资源按依赖排序（输入在前）
+type derivedIndex struct {
+	dependents map[string][]*derived
+	order      []*derived
+}
+
+// derivedMap 各设备的派生资源，由 resourcesMu 保护
+var derivedMap = make(map[string]*derivedIndex)
+
+// DerivedValue 一次计算出的派生资源值
+type DerivedValue struct {
+	Resource string
+	Value    any
+}
+
+// buildDerivedIndex 编译设备派生资源的表达式并检查输入和循环依赖，调用方需持有 resourcesMu 写锁
+func buildDerivedIndex(deviceName string, resources []DeviceResource) error {
+	// 1. 编译表达式
+	known := make(map[string]bool, len(resources))
+	for _, dr := range resources {
+		known[dr.Name] = true
+	}
+	byName := make(map[string]*derived)
+	for _, dr := range resources {
+		v, ok := dr.Attributes[ExpressionAttr]
+		if !ok {
+			continue
+		}
+		src, ok := v.(string)
+		if !ok || src == "" {
+			return fmt.Errorf("设备 %s 资源 %s 的 %s 属性须为非空字符串", deviceName, dr.Name, ExpressionAttr)
+		}
+		e, err := expr.Parse(src)
+		if err != nil {
+			return fmt.Errorf("设备 %s 资源 %s: %w", deviceName, dr.Name, err)
+		}
+		if _, err := fromFloat(0, dr.Properties.ValueType); err != nil {
+			return fmt.Errorf("设备 %s 派生资源 %s: %w", deviceName, dr.Name, err)
+		}
+		for _, in := range e.Vars() {
+			if !known[in] {
+				return fmt.Errorf("设备 %s 派生资源 %s 的表达式引用了未定义的资源 %s", deviceName, dr.Name, in)
+			}
+		}
+		byName[dr.Name] = &derived{name: dr.Name, expr: e, valueType: dr.Properties.ValueType}
+	}
+	if len(byName) == 0 {
+		delete(derivedMap, deviceName)
+		return nil
+	}
+
+	// 2. 循环依赖检查并按依赖排序（深度优先，visiting 为当前路径）
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int)
+	idx := &derivedIndex{dependents: make(map[string][]*derived)}
+	var visit func(name string) error
+	visit = func(name string) error {
+		d, ok := byName[name]
+		if !ok || state[name] == done {
+			return nil
+		}
+		if state[name] == visiting {
+			return fmt.Errorf("设备 %s 派生资源 %s 存在循环依赖", deviceName, name)
+		}
+		state[name] = visiting
+		for _, in := range d.expr.Vars() {
+			if err := visit(in); err != nil {
+				return err
+			}
+		}
+		state[name] = done
+		idx.order = append(idx.order, d)
+		return nil
+	}
+	for _, dr := range resources {
+		d, ok := byName[dr.Name]
+		if !ok {
+			continue
+		}
+		if err := visit(d.name); err != nil {
+			return err
+		}
+		for _, in := range d.expr.Vars() {
+			idx.dependents[in] = append(idx.dependents[in], d)
+		}
+	}
+	derivedMap[deviceName] = idx
+	return nil
+}
+
+// IsDerived 判断资源是否为设备的派生资源
+func IsDerived(deviceName, resourceName string) bool {
+	resourcesMu.RLock()
+	idx := derivedMap[deviceName]
+	resourcesMu.RUnlock()
+	if idx == nil {
+		return false
+	}
+	for _, d := range idx.order {
+		if d.name == resourceName {
+			return true
+		}
+	}
+	return false
+}
+
+// EvaluateDerived 资源 resourceName 更新后，按依赖顺序计算直接或间接依赖它的派生资源，
+// 先算出的派生值作为后面表达式的输入，调用方无需对派生结果再次调用；派生资源的全部输入都收到过
+// 传感器读数（见 ValueOrigin）才计算，本次应重算但未算出的派生资源不作为输入，计算失败的派生资源在 errs 中返回
+func EvaluateDerived(deviceName, resourceName string) (out []DerivedValue, errs []error) {
+	resourcesMu.RLock()
+	idx := derivedMap[deviceName]
+	resourcesMu.RUnlock()
+	if idx == nil || len(idx.dependents[resourceName]) == 0 {
+		return nil, nil
+	}
+	// 受影响的派生资源：从 resourceName 沿 dependents 可达的全部派生资源
+	affected := make(map[string]bool)
+	queue := []string{resourceName}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, d := range idx.dependents[name] {
+			if !affected[d.name] {
+				affected[d.name] = true
+				queue = append(queue, d.name)
+			}
+		}
+	}
+
+	values, _ := GetDeviceValues(deviceName)
+	computed := make(map[string]float64)
+	lookup := func(name string) (float64, bool) {
+		if f, ok := computed[name]; ok {
+			return f, true
+		}
+		if affected[name] {
+			return 0, false
+		}
+		if _, ok := ValueOrigin(deviceName, name); !ok {
+			return 0, false
+		}
+		return toFloat(values[name])
+	}
+	for _, d := range idx.order {
+		if !affected[d.name] {
+			continue
+		}
+		ready := true
+		for _, in := range d.expr.Vars() {
+			if _, ok := lookup(in); !ok {
+				ready = false
+				break
+			}
+		}
+		if !ready {
+			continue
+		}
+		f, err := d.expr.Eval(lookup)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("设备 %s 派生资源 %s: %w", deviceName, d.name, err))
+			continue
+		}
+		v, err := fromFloat(f, d.valueType)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("设备 %s 派生资源 %s: %w", deviceName, d.name, err))
+			continue
+		}
+		// 后续表达式使用按 valueType 转换后的值，与经值表读取时一致
+		computed[d.name], _ = toFloat(v)
+		out = append(out, DerivedValue{Resource: d.name, Value: v})
+	}
+	return out, errs
+}
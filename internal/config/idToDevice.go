@@ -1,13 +1,160 @@
 package config
 
-// sensorIDToDeviceName 是传感器 6 字节 ID（大写十六进制）到本地逻辑设备名的映射
-var sensorIDToDeviceName = map[string]string{
-	"238A0821BEF2": "Friendcom-Water-Level-Sensor",
-	// 在此处继续添加： "<SensorID>": "<DeviceName>",
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SensorBinding 描述一个 SensorID 的数据归属：写入哪个逻辑设备，以及资源名前缀和资源过滤。
+// 复合设备（一个设备包含多个传感器）通过不同前缀区分各传感器的同名参数；
+// 同一个传感器也可以绑定到多个设备（如原始设备 + 派生设备），各自只接收 Resources 中列出的资源。
+type SensorBinding struct {
+	DeviceName string
+	Prefix     string
+	Resources  []string // 允许写入的资源名（含前缀），为空表示全部
+}
+
+// Accepts 判断该绑定是否接收指定资源
+func (b SensorBinding) Accepts(resourceName string) bool {
+	if len(b.Resources) == 0 {
+		return true
+	}
+	for _, r := range b.Resources {
+		if r == resourceName {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	// sensorMu 保护 sensorBindings
+	sensorMu sync.RWMutex
+	// sensorBindings 是传感器 6 字节 ID（大写十六进制）到本地逻辑设备列表的映射
+	sensorBindings = map[string][]SensorBinding{
+		"238A0821BEF2": {{DeviceName: "Friendcom-Water-Level-Sensor"}},
+		// 在此处继续添加： "<SensorID>": {{DeviceName: "<DeviceName>"}},
+		// 或在设备协议属性 lpmp.sensorIds 中声明
+	}
+)
+
+// LookupDeviceName 根据大写十六进制的 SensorID 返回其绑定的全部逻辑设备名
+func LookupDeviceName(sensorID string) (deviceNames []string, ok bool) {
+	for _, b := range LookupSensorBindings(sensorID) {
+		deviceNames = append(deviceNames, b.DeviceName)
+	}
+	return deviceNames, len(deviceNames) > 0
+}
+
+// LookupSensorBindings 根据大写十六进制的 SensorID 返回其全部设备绑定（副本）
+func LookupSensorBindings(sensorID string) []SensorBinding {
+	sensorMu.RLock()
+	defer sensorMu.RUnlock()
+	bs := sensorBindings[sensorID]
+	out := make([]SensorBinding, len(bs))
+	copy(out, bs)
+	return out
+}
+
+// AllSensorBindings 返回全部 SensorID 的设备绑定（副本）
+func AllSensorBindings() map[string][]SensorBinding {
+	sensorMu.RLock()
+	defer sensorMu.RUnlock()
+	out := make(map[string][]SensorBinding, len(sensorBindings))
+	for id, bs := range sensorBindings {
+		out[id] = append([]SensorBinding(nil), bs...)
+	}
+	return out
+}
+
+// LookupSensorID 根据逻辑设备名反查大写十六进制的 SensorID，
+// 复合设备返回排序后的第一个
+func LookupSensorID(deviceName string) (sensorID string, ok bool) {
+	ids := LookupSensorIDs(deviceName)
+	if len(ids) == 0 {
+		return "", false
+	}
+	return ids[0], true
+}
+
+// LookupSensorIDs 返回绑定到指定设备的全部 SensorID（已排序）
+func LookupSensorIDs(deviceName string) []string {
+	sensorMu.RLock()
+	defer sensorMu.RUnlock()
+	var ids []string
+	for id, bs := range sensorBindings {
+		for _, b := range bs {
+			if b.DeviceName == deviceName {
+				ids = append(ids, id)
+				break
+			}
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// BindSensors 用 sensors（SensorID → 资源名前缀）替换设备当前的全部传感器绑定，
+// resources 为该设备接收的资源过滤列表，为空表示全部
+func BindSensors(deviceName string, sensors map[string]string, resources []string) {
+	sensorMu.Lock()
+	defer sensorMu.Unlock()
+	unbindLocked(deviceName)
+	for id, prefix := range sensors {
+		sensorBindings[id] = append(sensorBindings[id], SensorBinding{
+			DeviceName: deviceName,
+			Prefix:     prefix,
+			Resources:  resources,
+		})
+	}
+}
+
+// UnbindDevice 删除设备的全部传感器绑定
+func UnbindDevice(deviceName string) {
+	sensorMu.Lock()
+	defer sensorMu.Unlock()
+	unbindLocked(deviceName)
+}
+
+// unbindLocked 删除设备的全部绑定，调用方需持有 sensorMu 写锁
+func unbindLocked(deviceName string) {
+	for id, bs := range sensorBindings {
+		kept := bs[:0]
+		for _, b := range bs {
+			if b.DeviceName != deviceName {
+				kept = append(kept, b)
+			}
+		}
+		if len(kept) == 0 {
+			delete(sensorBindings, id)
+		} else {
+			sensorBindings[id] = kept
+		}
+	}
 }
 
-// LookupDeviceName 根据大写十六进制的 SensorID 返回逻辑设备名
-func LookupDeviceName(sensorID string) (deviceName string, ok bool) {
-	deviceName, ok = sensorIDToDeviceName[sensorID]
-	return
+// ParseSensorIDList 解析协议属性中的传感器列表，格式为逗号分隔的
+// "<SensorID>[:<资源名前缀>]"，例如 "238A0821BEF2:oil-,238A0821BEF3:winding-"。
+// 返回 SensorID（大写）→ 前缀。
+func ParseSensorIDList(s string) (map[string]string, error) {
+	out := make(map[string]string)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		id, prefix, _ := strings.Cut(item, ":")
+		id = strings.ToUpper(strings.TrimSpace(id))
+		if raw, err := hex.DecodeString(id); err != nil || len(raw) != 6 {
+			return nil, fmt.Errorf("SensorID %q 必须为 12 位十六进制", id)
+		}
+		if _, dup := out[id]; dup {
+			return nil, fmt.Errorf("SensorID %s 重复", id)
+		}
+		out[id] = strings.TrimSpace(prefix)
+	}
+	return out, nil
 }
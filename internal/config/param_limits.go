@@ -0,0 +1,127 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 超出范围时的处理方式
+const (
+	// LimitActionReject 丢弃该读数，不写入值表
+	LimitActionReject = "reject"
+	// LimitActionFlag 照常写入，但打上 quality=suspect 标签
+	LimitActionFlag = "flag"
+)
+
+// ParamLimit 单个参量的合理取值范围，Min / Max 为空表示该侧不限制
+type ParamLimit struct {
+	ParameterType uint16   `yaml:"parameterType"`
+	Min           *float64 `yaml:"min"`
+	Max           *float64 `yaml:"max"`
+	// Action 超出范围时的处理方式：reject（默认）或 flag
+	Action string `yaml:"action"`
+}
+
+// paramLimitsYAML 对应外部范围表文件的顶层结构
+type paramLimitsYAML struct {
+	ParamLimits []ParamLimit `yaml:"paramLimits"`
+}
+
+// RangeResult 范围校验结果
+type RangeResult int
+
+const (
+	// RangeOK 在范围内或未配置范围
+	RangeOK RangeResult = iota
+	// RangeSuspect 超出范围，按 flag 处理
+	RangeSuspect
+	// RangeRejected 超出范围，按 reject 处理
+	RangeRejected
+)
+
+var (
+	limitsMu sync.RWMutex
+	// paramLimits 类型码 → 取值范围，由 LoadParamLimits 整体替换
+	paramLimits = make(map[uint16]ParamLimit)
+)
+
+// LoadParamLimits 读取外部参量范围表（YAML，paramLimits 列表）；
+// 文件不存在时清空范围表，不做范围校验
+func LoadParamLimits(path string) error {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		SetParamLimits(nil)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("无法读取参量范围表 %s：%w", path, err)
+	}
+	var doc paramLimitsYAML
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		return fmt.Errorf("解析参量范围表 %s 失败：%w", path, err)
+	}
+	return SetParamLimits(doc.ParamLimits)
+}
+
+// SetParamLimits 校验并整体替换参量范围表
+func SetParamLimits(limits []ParamLimit) error {
+	m := make(map[uint16]ParamLimit, len(limits))
+	for _, l := range limits {
+		t := l.ParameterType & 0x3FFF
+		if _, ok := paramsByType[t]; !ok {
+			return fmt.Errorf("参量范围表：类型 0x%04X 不在参数表中", t)
+		}
+		if _, dup := m[t]; dup {
+			return fmt.Errorf("参量范围表：类型 0x%04X 重复定义", t)
+		}
+		if l.Min != nil && l.Max != nil && *l.Min > *l.Max {
+			return fmt.Errorf("参量范围表：类型 0x%04X 的 min %v 大于 max %v", t, *l.Min, *l.Max)
+		}
+		switch l.Action {
+		case "":
+			l.Action = LimitActionReject
+		case LimitActionReject, LimitActionFlag:
+		default:
+			return fmt.Errorf("参量范围表：类型 0x%04X 的 action %q 无效（reject / flag）", t, l.Action)
+		}
+		m[t] = l
+	}
+	limitsMu.Lock()
+	paramLimits = m
+	limitsMu.Unlock()
+	return nil
+}
+
+// CheckParamRange 按范围表校验解析后的数值；未配置范围或非数值类型时返回 RangeOK
+func CheckParamRange(paramType uint16, value any) (RangeResult, string) {
+	limitsMu.RLock()
+	l, ok := paramLimits[paramType&0x3FFF]
+	limitsMu.RUnlock()
+	if !ok {
+		return RangeOK, ""
+	}
+	f, ok := toFloat64(value)
+	if !ok {
+		return RangeOK, ""
+	}
+	var reason string
+	switch {
+	case math.IsNaN(f):
+		reason = "值为 NaN"
+	case l.Min != nil && f < *l.Min:
+		reason = fmt.Sprintf("值 %v 小于下限 %v", f, *l.Min)
+	case l.Max != nil && f > *l.Max:
+		reason = fmt.Sprintf("值 %v 大于上限 %v", f, *l.Max)
+	default:
+		return RangeOK, ""
+	}
+	if l.Action == LimitActionFlag {
+		return RangeSuspect, reason
+	}
+	return RangeRejected, reason
+}
@@ -2,92 +2,169 @@ package config
 
 //附录D表
 import (
+	"bytes"
+	"crypto/sha256"
 	"encoding/binary"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/paramcodec"
 )
 
 // Entry 表示一个参数在报文中的完整字段（不含后面的 CRC、帧头等）
 // 它只包含：
-// 1) head16：14bit 参数类型 + 2bit 长度指示位，按小端序写入报文时就是这 2 字节原样；
-// 2) data：真正的参数内容，长度固定，由 lengthFlag 决定。
+// 1) head16：14bit 参数类型 + 2bit 长度指示位，按小端序写入报文时就是这 2 字节原样，
+// LengthFlag 非 0 时其后还有长度字段（见 paramcodec.Spec）；
+// 2) data：真正的参数内容，长度固定，由参数表的 ByteLen 决定。
 type Entry struct {
 	Head16 uint16 // (ParameterType<<2 | LengthFlag), 小端序存储到报文字段
-	Length int    // DataLen：0→4, 1→1, 2→2, 3→3 字节
+	Length int    // 数据字节数
 	Data   []byte // 参数的可变内容
 }
 
-// 全局表：参数名 → *Entry
-var (
-	table = map[string]*Entry{
-		// 以下举例：假设有两个参数 "Temperature" 和 "Humidity"
-		// 它们在协议里定义的 ParameterType 和 LengthFlag 已知：
-		//  Temperature: 类型码 0x0005, 长度标志 0 → 数据固定 4 字节
-		//  Humidity:    类型码 0x0009, 长度标志 1 → 数据固定 1 字节
-		"Temperature": {
-			Head16: binary.LittleEndian.Uint16([]byte{0b00000101<<2 | 0b00, 0}), // (0x0005<<2)|0
-			Length: 4,
-			Data:   make([]byte, 4),
-		},
-		"Humidity": {
-			Head16: binary.LittleEndian.Uint16([]byte{0b00001001<<2 | 0b01, 0}), // (0x0009<<2)|1
-			Length: 1,
-			Data:   make([]byte, 1),
-		},
-		// 按照你的协议表继续添加……
+// paramEntry 统一参数表中的一项：上行解析（类型码 → ParamInfo）与下行编码（名称 → Entry）共用同一份定义
+type paramEntry struct {
+	paramType uint16
+	info      ParamInfo
+	// data 通过 UpdateData 设置的下发值，作为通用参数的默认期望值；未设置时为 nil，由 tableMu 保护
+	data []byte
+}
+
+// head 按规范编码参数头和长度字段（与上行解析、下行构造共用 paramcodec）
+func (e *paramEntry) head() []byte {
+	head, err := paramcodec.Spec.Head(e.paramType, e.info.ByteLen)
+	if err != nil {
+		// 参数表中的类型码和长度在 init 时已确定，只有表本身有误才会出错
+		panic(fmt.Sprintf("参数表 0x%04X 无法编码: %v", e.paramType, err))
 	}
+	return head
+}
+
+// head16 参数头 2 字节（小端）
+func (e *paramEntry) head16() uint16 {
+	return binary.LittleEndian.Uint16(e.head())
+}
+
+// entry 返回 Entry 副本，未设置下发值时数据为全 0，调用方需持有 tableMu 读锁
+func (e *paramEntry) entry() Entry {
+	data := make([]byte, e.info.ByteLen)
+	copy(data, e.data)
+	return Entry{Head16: e.head16(), Length: e.info.ByteLen, Data: data}
+}
+
+// 全局参数表：由 param_table_parser.go 中的 paramMap 在 init 时生成，之后只读（data 除外）
+var (
+	// tableMu 保护各参数的下发值 data，与设备资源表、值表的锁相互独立
+	tableMu sync.RWMutex
+	// paramsByType 类型码 → 参数
+	paramsByType = make(map[uint16]*paramEntry)
+	// paramsByName 参数名 → 参数，同名参数（如不同长度的 battery-level）保留全部
+	paramsByName = make(map[string][]*paramEntry)
 )
 
+func init() {
+	for key, info := range paramMap {
+		e := &paramEntry{paramType: key.TypeCode(), info: info}
+		paramsByType[e.paramType] = e
+		paramsByName[info.Name] = append(paramsByName[info.Name], e)
+	}
+	for _, list := range paramsByName {
+		sort.Slice(list, func(i, j int) bool { return list[i].paramType < list[j].paramType })
+	}
+}
+
+// lookupParamByName 按名称查找参数，同名参数有多个时报错
+func lookupParamByName(name string) (*paramEntry, error) {
+	list := paramsByName[name]
+	switch len(list) {
+	case 0:
+		return nil, errors.New("unknown parameter: " + name)
+	case 1:
+		return list[0], nil
+	}
+	return nil, fmt.Errorf("参数名 %q 对应 %d 个类型码，请按类型码指定", name, len(list))
+}
+
 // UpdateData 用于并发安全地更新某个参数的 data 内容
-// 要求 len(value) == entry.length，否则报错；
+// 要求 len(value) == 参数表长度，否则报错；
 // data 会被完整拷贝到内部存储。
 func UpdateData(name string, value []byte) error {
-	mu.Lock()
-	defer mu.Unlock()
-
-	e, ok := table[name]
-	if !ok {
-		return errors.New("unknown parameter: " + name)
+	e, err := lookupParamByName(name)
+	if err != nil {
+		return err
 	}
-	if len(value) != e.Length {
+	if len(value) != e.info.ByteLen {
 		return errors.New("invalid data length for " + name)
 	}
-	// 拷贝到内部
-	copy(e.Data, value)
+	tableMu.Lock()
+	defer tableMu.Unlock()
+	e.data = bytes.Clone(value)
 	return nil
 }
 
-// GetPacketFields 返回当前全量“头域+数据域”组合后的字节切片副本，map[key]=[]byte{head16_lo, head16_hi, ...data}
-// head16 按小端序存储在前面 2 字节，后面紧跟 data。
+// GetPacketFields 返回当前全量“头域+数据域”组合后的字节切片副本，map[key]=[]byte{head16_lo, head16_hi, [长度字段], ...data}
+// head16 按小端序存储在前面 2 字节，LengthFlag 非 0 时跟长度字段，之后是 data；
+// 同名参数的 key 追加类型码，如 "battery-level#0x0039"。
 func GetPacketFields() map[string][]byte {
-	mu.RLock()
-	defer mu.RUnlock()
-
-	out := make(map[string][]byte, len(table))
-	for name, e := range table {
-		buf := make([]byte, 2+e.Length)
-		// 2 字节小端序 head16
-		binary.LittleEndian.PutUint16(buf[0:2], e.Head16)
-		// 紧跟 data
-		copy(buf[2:], e.Data)
-		out[name] = buf
+	tableMu.RLock()
+	defer tableMu.RUnlock()
+
+	out := make(map[string][]byte, len(paramsByType))
+	for name, list := range paramsByName {
+		for _, e := range list {
+			key := name
+			if len(list) > 1 {
+				key = fmt.Sprintf("%s#0x%04X", name, e.paramType)
+			}
+			en := e.entry()
+			// 参数头（含长度字段），紧跟 data
+			out[key] = append(e.head(), en.Data...)
+		}
 	}
 	return out
 }
 
 // GetEntryCopy 返回某个参数的当前 Entry 副本，包含 head16、length 和 data 副本
 func GetEntryCopy(name string) (Entry, error) {
-	mu.RLock()
-	defer mu.RUnlock()
+	e, err := lookupParamByName(name)
+	if err != nil {
+		return Entry{}, err
+	}
+	tableMu.RLock()
+	defer tableMu.RUnlock()
+	return e.entry(), nil
+}
+
+// TableParams 返回参数表中已设置下发值（UpdateData）的参数数据，key 为 14bit 类型码，
+// 作为传感器通用参数的默认期望值
+func TableParams() map[uint16][]byte {
+	tableMu.RLock()
+	defer tableMu.RUnlock()
 
-	e, ok := table[name]
-	if !ok {
-		return Entry{}, errors.New("unknown parameter: " + name)
+	out := make(map[uint16][]byte)
+	for t, e := range paramsByType {
+		if e.data != nil {
+			out[t] = bytes.Clone(e.data)
+		}
+	}
+	return out
+}
+
+// ParamTableDigest 返回参数表的项数和 SHA-256 摘要（按类型码排序的类型码、名称、单位、长度、数据类型），
+// 用于确认部署的程序使用的是审定过的参数表
+func ParamTableDigest() (entries int, sum string) {
+	types := make([]uint16, 0, len(paramsByType))
+	for t := range paramsByType {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	h := sha256.New()
+	for _, t := range types {
+		info := paramsByType[t].info
+		fmt.Fprintf(h, "0x%04X\t%s\t%s\t%d\t%s\n", t, info.Name, info.Unit, info.ByteLen, info.DataType)
 	}
-	dataCopy := make([]byte, len(e.Data))
-	copy(dataCopy, e.Data)
-	return Entry{
-		Head16: e.Head16,
-		Length: e.Length,
-		Data:   dataCopy,
-	}, nil
+	return len(types), hex.EncodeToString(h.Sum(nil))
 }
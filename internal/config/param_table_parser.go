@@ -12,37 +12,130 @@ type ParamKey struct {
 	CodeBits    uint16 // 低11位（类型编码）
 }
 
+// TypeCode 返回 14bit 参量类型码
+func (k ParamKey) TypeCode() uint16 {
+	return uint16(k.FeatureBits&0x07)<<11 | k.CodeBits&0x7FF
+}
+
 type ParamInfo struct {
 	Name     string
 	Unit     string
 	ByteLen  int
 	DataType string
 	Parse    func([]byte) (any, error)
+	// Encode 将写入值编码为报文数据，与 Parse 互为逆操作；为 nil 时按 DataType 选择通用编码
+	Encode func(any) ([]byte, error)
 }
 
+// paramMap 参数表定义，上行解析和下行编码共用（见 param_table.go 中的 paramsByType / paramsByName）
 var paramMap = map[ParamKey]ParamInfo{
-	{0b000, 0b00000000001}: {"长度", "m", 4, "float32", parseFloat32},
-	{0b000, 0b00000000010}: {"battery-level", "%", 2, "uint16", parseAndStoreBatteryLevel},
-	{0b000, 0b00000000011}: {"voltage", "v", 4, "uint32", parseAndStoreVoltage},
-	{0b000, 0b00000000100}: {"state", "0:其它,1:正常,2:异常", 1, "uint8", parseAndStoreDeviceStatus},
-	{0b000, 0b00000000101}: {"温度", "℃", 4, "float32", parseFloat32},
-	{0b000, 0b00000000110}: {"物质的量", "mol", 4, "float32", parseFloat32},
-	{0b000, 0b00000000111}: {"发光强度", "cd", 4, "float32", parseFloat32},
-	{0b000, 0b00000001000}: {"temperature", "℃", 4, "float32", parseAndStoreTemperature},
-	{0b000, 0b00000001001}: {"humidity", "%RH", 2, "float32", parseAndStoreHumidity},
-	{0b000, 0b00000111000}: {"心跳状态", "\\", 1, "uint8", parseUint8},
-	{0b000, 0b00000111001}: {"battery-level", "%", 1, "uint8", parseUint8},
-	{0b000, 0b00010100011}: {"water-level", "m", 4, "float32", parseAndStoreLevelHeight},
+	{0b000, 0b00000000001}: {"长度", "m", 4, "float32", parseFloat32, encodeFloat32},
+	{0b000, 0b00000000010}: {"battery-level", "%", 2, "uint16", parseAndStoreBatteryLevel, encodeUint16},
+	{0b000, 0b00000000011}: {"voltage", "v", 4, "float32", parseAndStoreVoltage, encodeFloat32},
+	{0b000, 0b00000000100}: {"state", "0:其它,1:正常,2:异常", 1, "uint8", parseAndStoreDeviceStatus, encodeUint8},
+	{0b000, 0b00000000101}: {"温度", "℃", 4, "float32", parseFloat32, encodeFloat32},
+	{0b000, 0b00000000110}: {"物质的量", "mol", 4, "float32", parseFloat32, encodeFloat32},
+	{0b000, 0b00000000111}: {"发光强度", "cd", 4, "float32", parseFloat32, encodeFloat32},
+	{0b000, 0b00000001000}: {"temperature", "℃", 4, "float32", parseAndStoreTemperature, encodeFloat32},
+	{0b000, 0b00000001001}: {"humidity", "%RH", 2, "float32", parseAndStoreHumidity, encodeHumidity},
+	{0b000, 0b00000111000}: {"心跳状态", "\\", 1, "uint8", parseUint8, encodeUint8},
+	{0b000, 0b00000111001}: {"battery-level", "%", 1, "uint8", parseUint8, encodeUint8},
+	{0b000, 0b00010100011}: {"water-level", "m", 4, "float32", parseAndStoreLevelHeight, encodeFloat32},
 }
 
+// LookupParamInfo 按 14bit 类型码查找参数定义。每帧每个参量都会调用：
+// 参数表在 init 后只读，查找无锁、无分配，也不输出日志（诊断信息由调用方按日志级别输出）
 func LookupParamInfo(paramType uint16) (ParamInfo, bool) {
-	feature := byte((paramType >> 11) & 0x07)
-	code := paramType & 0x7FF
-	fmt.Printf("🔍 TypeCode=0x%04X → Feature=%03b (0x%X), Code=%011b (0x%X)\n", paramType, feature, feature, code, code)
+	e, ok := paramsByType[paramType&0x3FFF]
+	if !ok {
+		return ParamInfo{}, false
+	}
+	return e.info, true
+}
+
+// ===================== 通用编码函数 =====================
+// 与解析函数对称：多字节数值一律小端
+
+// encoderFor 返回 DataType 对应的通用编码函数
+func encoderFor(dataType string) (func(any) ([]byte, error), bool) {
+	switch dataType {
+	case "float32":
+		return encodeFloat32, true
+	case "uint8":
+		return encodeUint8, true
+	case "uint16":
+		return encodeUint16, true
+	case "uint32":
+		return encodeUint32, true
+	case "string":
+		return encodeString, true
+	}
+	return nil, false
+}
+
+func encodeFloat32(v any) ([]byte, error) {
+	f, ok := toFloat64(v)
+	if !ok {
+		return nil, fmt.Errorf("需要数值，得到 %T", v)
+	}
+	if math.Abs(f) > math.MaxFloat32 {
+		return nil, fmt.Errorf("值 %v 超出 float32 范围", f)
+	}
+	return binary.LittleEndian.AppendUint32(nil, math.Float32bits(float32(f))), nil
+}
+
+// toUint 将数值转换为不超过 max 的非负整数
+func toUint(v any, max uint64) (uint64, error) {
+	f, ok := toFloat64(v)
+	if !ok || f < 0 || f != math.Trunc(f) {
+		return 0, fmt.Errorf("需要非负整数，得到 %v", v)
+	}
+	if f > float64(max) {
+		return 0, fmt.Errorf("值 %v 超出范围 0~%d", v, max)
+	}
+	return uint64(f), nil
+}
+
+func encodeUint8(v any) ([]byte, error) {
+	n, err := toUint(v, math.MaxUint8)
+	if err != nil {
+		return nil, err
+	}
+	return []byte{byte(n)}, nil
+}
 
-	key := ParamKey{feature, code}
-	info, ok := paramMap[key]
-	return info, ok
+func encodeUint16(v any) ([]byte, error) {
+	n, err := toUint(v, math.MaxUint16)
+	if err != nil {
+		return nil, err
+	}
+	return binary.LittleEndian.AppendUint16(nil, uint16(n)), nil
+}
+
+func encodeUint32(v any) ([]byte, error) {
+	n, err := toUint(v, math.MaxUint32)
+	if err != nil {
+		return nil, err
+	}
+	return binary.LittleEndian.AppendUint32(nil, uint32(n)), nil
+}
+
+// encodeString 字符串按原始字节下发，长度不足时由调用方补 0
+func encodeString(v any) ([]byte, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("需要字符串，得到 %T", v)
+	}
+	return []byte(s), nil
+}
+
+// encodeHumidity 湿度以 uint16 上报、解析为 float32，下发时取整
+func encodeHumidity(v any) ([]byte, error) {
+	f, ok := toFloat64(v)
+	if !ok {
+		return nil, fmt.Errorf("需要数值，得到 %T", v)
+	}
+	return encodeUint16(math.Round(f))
 }
 
 // ===================== 通用解析函数 =====================
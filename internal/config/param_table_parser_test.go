@@ -0,0 +1,118 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+)
+
+// sampleValue 按参数定义选一个能无损往返的写入值
+func sampleValue(info ParamInfo) any {
+	switch info.DataType {
+	case "uint8":
+		return uint8(2)
+	case "uint16":
+		return uint16(300)
+	case "uint32":
+		return uint32(70000)
+	case "string":
+		return "ab"
+	}
+	// float32；湿度以 uint16 上报，取整数
+	return float32(55)
+}
+
+func TestParamEncodeRoundTrip(t *testing.T) {
+	for key, info := range paramMap {
+		v := sampleValue(info)
+		data, err := EncodeParamValue(info, v)
+		if err != nil {
+			t.Errorf("%s(0x%04X) 编码 %v: %v", info.Name, key.TypeCode(), v, err)
+			continue
+		}
+		if len(data) != info.ByteLen {
+			t.Errorf("%s(0x%04X) 编码后 %d 字节，参数表长度 %d", info.Name, key.TypeCode(), len(data), info.ByteLen)
+		}
+		got, err := info.Parse(data)
+		if err != nil {
+			t.Errorf("%s(0x%04X) 解析 % X: %v", info.Name, key.TypeCode(), data, err)
+			continue
+		}
+		want, _ := toFloat64(v)
+		if f, ok := toFloat64(got); !ok || f != want {
+			t.Errorf("%s(0x%04X) 往返 %v → % X → %v", info.Name, key.TypeCode(), v, data, got)
+		}
+		// 解析结果再编码得到相同的报文数据
+		again, err := EncodeParamValue(info, got)
+		if err != nil || !bytes.Equal(again, data) {
+			t.Errorf("%s(0x%04X) 再编码 % X, %v，期望 % X", info.Name, key.TypeCode(), again, err, data)
+		}
+	}
+}
+
+func TestEncodeParamValueLittleEndian(t *testing.T) {
+	for _, tc := range []struct {
+		info ParamInfo
+		v    any
+		want []byte
+	}{
+		{ParamInfo{Name: "f", ByteLen: 4, DataType: "float32"}, 10.0, []byte{0x00, 0x00, 0x20, 0x41}},
+		{ParamInfo{Name: "u16", ByteLen: 2, DataType: "uint16"}, 0x1234, []byte{0x34, 0x12}},
+		{ParamInfo{Name: "u32", ByteLen: 4, DataType: "uint32"}, uint32(0x01020304), []byte{0x04, 0x03, 0x02, 0x01}},
+		// 参数表长度更长时高位补 0
+		{ParamInfo{Name: "u8", ByteLen: 2, DataType: "uint8"}, 7, []byte{0x07, 0x00}},
+		{ParamInfo{Name: "s", ByteLen: 4, DataType: "string"}, "ab", []byte{'a', 'b', 0, 0}},
+		{ParamInfo{Name: "humidity", ByteLen: 2, DataType: "float32", Encode: encodeHumidity}, 55.4, []byte{55, 0}},
+	} {
+		got, err := EncodeParamValue(tc.info, tc.v)
+		if err != nil || !bytes.Equal(got, tc.want) {
+			t.Errorf("%s(%v) = % X, %v，期望 % X", tc.info.Name, tc.v, got, err, tc.want)
+		}
+	}
+}
+
+func TestEncodeParamValueErrors(t *testing.T) {
+	for _, tc := range []struct {
+		info ParamInfo
+		v    any
+	}{
+		{ParamInfo{Name: "u8", ByteLen: 1, DataType: "uint8"}, 256},
+		{ParamInfo{Name: "u16", ByteLen: 2, DataType: "uint16"}, -1},
+		{ParamInfo{Name: "u16", ByteLen: 2, DataType: "uint16"}, 1.5},
+		{ParamInfo{Name: "f", ByteLen: 4, DataType: "float32"}, "x"},
+		{ParamInfo{Name: "f", ByteLen: 4, DataType: "float32"}, 1e39},
+		{ParamInfo{Name: "s", ByteLen: 2, DataType: "string"}, "abc"},
+		{ParamInfo{Name: "b", ByteLen: 1, DataType: "bool"}, true},
+	} {
+		if got, err := EncodeParamValue(tc.info, tc.v); err == nil {
+			t.Errorf("%s(%v) = % X，期望错误", tc.info.Name, tc.v, got)
+		}
+	}
+}
+
+func TestLookupParamInfoNoAlloc(t *testing.T) {
+	// 每帧每个参量都会查找，命中和未命中都不应分配
+	if n := testing.AllocsPerRun(100, func() {
+		LookupParamInfo(0x00A3)
+		LookupParamInfo(0x3FFF)
+	}); n != 0 {
+		t.Errorf("LookupParamInfo 每次分配 %.0f 次", n)
+	}
+}
+
+func BenchmarkLookupParamInfo(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, ok := LookupParamInfo(0x00A3); !ok {
+			b.Fatal("未找到 water-level")
+		}
+	}
+}
+
+func BenchmarkLookupSensorParamInfo(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, ok := LookupSensorParamInfo("238A0821BEF2", 0x00A3); !ok {
+			b.Fatal("未找到 water-level")
+		}
+	}
+}
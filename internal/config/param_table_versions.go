@@ -0,0 +1,179 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultParamTableVersion 内置参数表（paramMap）的版本名
+const DefaultParamTableVersion = "v1"
+
+// ParamDef 参数表版本中重新定义的一个类型码
+type ParamDef struct {
+	ParameterType uint16 `yaml:"parameterType"`
+	Name          string `yaml:"name"`
+	Unit          string `yaml:"unit"`
+	// DataType float32 / uint8 / uint16 / uint32，小端；ByteLen 可省略，须与 DataType 一致
+	DataType string `yaml:"dataType"`
+	ByteLen  int    `yaml:"byteLen"`
+}
+
+// paramTablesYAML 对应参数表版本文件的顶层结构：版本名 → 与内置参数表不同的类型码
+type paramTablesYAML struct {
+	ParamTables map[string][]ParamDef `yaml:"paramTables"`
+}
+
+// paramTableState 参数表版本和各传感器选用的版本，整体替换后只读，解析时无锁读取
+type paramTableState struct {
+	// versions 版本名 → 类型码 → 重新定义的参数，未列出的类型码沿用内置参数表
+	versions map[string]map[uint16]ParamInfo
+	// sensors SensorID → 版本名，未登记的传感器使用内置参数表
+	sensors map[string]string
+}
+
+var (
+	// paramTablesMu 串行化 paramTables 的整体替换
+	paramTablesMu sync.Mutex
+	paramTables   atomic.Pointer[paramTableState]
+)
+
+func init() {
+	paramTables.Store(&paramTableState{})
+}
+
+// genericParsers 参数表版本中可用的数据类型：解析函数和字节数
+var genericParsers = map[string]struct {
+	parse   func([]byte) (any, error)
+	byteLen int
+}{
+	"float32": {parseFloat32, 4},
+	"uint8":   {parseUint8, 1},
+	"uint16":  {parseUint16, 2},
+	"uint32":  {parseUint32, 4},
+}
+
+// LoadParamTableVersions 读取参数表版本文件（YAML，paramTables 映射）；文件不存在时只有内置参数表
+func LoadParamTableVersions(path string) error {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return SetParamTableVersions(nil)
+	}
+	if err != nil {
+		return fmt.Errorf("无法读取参数表版本 %s：%w", path, err)
+	}
+	var doc paramTablesYAML
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		return fmt.Errorf("解析参数表版本 %s 失败：%w", path, err)
+	}
+	return SetParamTableVersions(doc.ParamTables)
+}
+
+// SetParamTableVersions 校验并整体替换参数表版本，同时清空各传感器的版本选择（由设备协议属性重新设置）
+func SetParamTableVersions(tables map[string][]ParamDef) error {
+	versions := make(map[string]map[uint16]ParamInfo, len(tables))
+	for name, defs := range tables {
+		if name == "" || name == DefaultParamTableVersion {
+			return fmt.Errorf("参数表版本：版本名 %q 无效（%s 为内置参数表）", name, DefaultParamTableVersion)
+		}
+		m := make(map[uint16]ParamInfo, len(defs))
+		for _, def := range defs {
+			t := def.ParameterType & 0x3FFF
+			if _, dup := m[t]; dup {
+				return fmt.Errorf("参数表版本 %s：类型 0x%04X 重复定义", name, t)
+			}
+			g, ok := genericParsers[def.DataType]
+			if !ok {
+				return fmt.Errorf("参数表版本 %s：类型 0x%04X 的 dataType %q 无效（float32 / uint8 / uint16 / uint32）", name, t, def.DataType)
+			}
+			if def.ByteLen != 0 && def.ByteLen != g.byteLen {
+				return fmt.Errorf("参数表版本 %s：类型 0x%04X 的 byteLen %d 与 dataType %s 不符", name, t, def.ByteLen, def.DataType)
+			}
+			if def.Name == "" {
+				return fmt.Errorf("参数表版本 %s：类型 0x%04X 缺少 name", name, t)
+			}
+			encode, _ := encoderFor(def.DataType)
+			m[t] = ParamInfo{Name: def.Name, Unit: def.Unit, ByteLen: g.byteLen, DataType: def.DataType, Parse: g.parse, Encode: encode}
+		}
+		versions[name] = m
+	}
+
+	paramTablesMu.Lock()
+	defer paramTablesMu.Unlock()
+	paramTables.Store(&paramTableState{versions: versions})
+	return nil
+}
+
+// ParamTableVersions 返回可选的参数表版本名（含内置的 v1），按名称排序
+func ParamTableVersions() []string {
+	st := paramTables.Load()
+	out := []string{DefaultParamTableVersion}
+	for v := range st.versions {
+		out = append(out, v)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// CheckParamTableVersion 检查版本名是否已定义，空串和 v1 表示内置参数表
+func CheckParamTableVersion(version string) error {
+	if version == "" || version == DefaultParamTableVersion {
+		return nil
+	}
+	if _, ok := paramTables.Load().versions[version]; !ok {
+		return fmt.Errorf("参数表版本 %q 未定义（可选 %v）", version, ParamTableVersions())
+	}
+	return nil
+}
+
+// SetSensorParamTable 设置传感器解析和下发使用的参数表版本，空串或 v1 恢复内置参数表
+func SetSensorParamTable(sensorID, version string) error {
+	if err := CheckParamTableVersion(version); err != nil {
+		return err
+	}
+	if version == DefaultParamTableVersion {
+		version = ""
+	}
+	paramTablesMu.Lock()
+	defer paramTablesMu.Unlock()
+	old := paramTables.Load()
+	if old.sensors[sensorID] == version {
+		return nil
+	}
+	sensors := make(map[string]string, len(old.sensors)+1)
+	for sid, v := range old.sensors {
+		sensors[sid] = v
+	}
+	if version == "" {
+		delete(sensors, sensorID)
+	} else {
+		sensors[sensorID] = version
+	}
+	paramTables.Store(&paramTableState{versions: old.versions, sensors: sensors})
+	return nil
+}
+
+// SensorParamTable 返回传感器使用的参数表版本
+func SensorParamTable(sensorID string) string {
+	if v := paramTables.Load().sensors[sensorID]; v != "" {
+		return v
+	}
+	return DefaultParamTableVersion
+}
+
+// LookupSensorParamInfo 按传感器使用的参数表版本查找参数定义，版本中未重新定义的类型码查内置参数表；
+// 与 LookupParamInfo 一样每个参量都会调用，无锁、无分配
+func LookupSensorParamInfo(sensorID string, paramType uint16) (ParamInfo, bool) {
+	st := paramTables.Load()
+	if v := st.sensors[sensorID]; v != "" {
+		if info, ok := st.versions[v][paramType&0x3FFF]; ok {
+			return info, true
+		}
+	}
+	return LookupParamInfo(paramType)
+}
@@ -0,0 +1,258 @@
+package config
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrTLVFormat 参量数据不符合其 TLV 子表（标签/长度越界、未知标签、数值长度不符）
+var ErrTLVFormat = errors.New("TLV 格式错误")
+
+// TLVCode TLV 子表中的一个标签：叶子标签解出一个读数，带 codes 的标签其值为下一层 TLV
+type TLVCode struct {
+	Tag  uint16 `yaml:"tag"`
+	Name string `yaml:"name"`
+	// DataType 叶子标签的值类型：uint8 / int8 / uint16 / int16 / uint32 / int32 / float32 / string / bytes
+	DataType string `yaml:"dataType"`
+	Unit     string `yaml:"unit"`
+	// Codes 嵌套的子表，非空时本标签不是叶子，DataType 须为空
+	Codes []TLVCode `yaml:"codes"`
+}
+
+// TLVTable 一个参量类型的 TLV 子表：厂家把多个子参量以 TLV 形式放进同一个参量时，
+// 按子表逐层解开，每个叶子标签作为一个独立资源发布，而不是整体作为一段原始数据
+type TLVTable struct {
+	ParameterType uint16 `yaml:"parameterType"`
+	// TagBytes / LengthBytes 标签和长度字段的字节数，1 或 2，默认 1；各层相同
+	TagBytes    int `yaml:"tagBytes"`
+	LengthBytes int `yaml:"lengthBytes"`
+	// ByteOrder 多字节的标签、长度和数值的字节序：little（默认，与参数表一致）或 big
+	ByteOrder string    `yaml:"byteOrder"`
+	Codes     []TLVCode `yaml:"codes"`
+
+	order binary.ByteOrder
+	root  map[uint16]*tlvNode
+}
+
+// tlvNode 编译后的标签
+type tlvNode struct {
+	code     *TLVCode
+	children map[uint16]*tlvNode
+}
+
+// TLVValue 解出的一个叶子读数，Name 为子表中的名称（资源名）
+type TLVValue struct {
+	Name  string
+	Unit  string
+	Value any
+}
+
+// paramTLVYAML 对应 TLV 子表文件的顶层结构
+type paramTLVYAML struct {
+	TLVParams []TLVTable `yaml:"tlvParams"`
+}
+
+var (
+	tlvMu sync.RWMutex
+	// tlvTables 类型码 → TLV 子表，由 LoadParamTLV 整体替换
+	tlvTables = make(map[uint16]*TLVTable)
+)
+
+// LoadParamTLV 读取 TLV 子表文件（YAML，tlvParams 列表）；文件不存在时清空子表，所有参量按参数表解析
+func LoadParamTLV(path string) error {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return SetParamTLV(nil)
+	}
+	if err != nil {
+		return fmt.Errorf("无法读取 TLV 子表 %s：%w", path, err)
+	}
+	var doc paramTLVYAML
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		return fmt.Errorf("解析 TLV 子表 %s 失败：%w", path, err)
+	}
+	return SetParamTLV(doc.TLVParams)
+}
+
+// SetParamTLV 校验并整体替换 TLV 子表。参量类型可以不在参数表中；
+// 同一子表内各层的名称须唯一（作为资源名）
+func SetParamTLV(tables []TLVTable) error {
+	m := make(map[uint16]*TLVTable, len(tables))
+	for i := range tables {
+		t := tables[i]
+		t.ParameterType &= 0x3FFF
+		where := fmt.Sprintf("TLV 子表：类型 0x%04X", t.ParameterType)
+		if _, dup := m[t.ParameterType]; dup {
+			return fmt.Errorf("%s 重复定义", where)
+		}
+		if t.TagBytes == 0 {
+			t.TagBytes = 1
+		}
+		if t.LengthBytes == 0 {
+			t.LengthBytes = 1
+		}
+		if t.TagBytes > 2 || t.TagBytes < 1 || t.LengthBytes > 2 || t.LengthBytes < 1 {
+			return fmt.Errorf("%s 的 tagBytes / lengthBytes 须为 1 或 2", where)
+		}
+		switch t.ByteOrder {
+		case "", "little":
+			t.order = binary.LittleEndian
+		case "big":
+			t.order = binary.BigEndian
+		default:
+			return fmt.Errorf("%s 的 byteOrder %q 无效（little / big）", where, t.ByteOrder)
+		}
+		names := make(map[string]bool)
+		root, err := compileTLVCodes(t.Codes, t.TagBytes, names)
+		if err != nil {
+			return fmt.Errorf("%s：%w", where, err)
+		}
+		t.root = root
+		m[t.ParameterType] = &t
+	}
+	tlvMu.Lock()
+	tlvTables = m
+	tlvMu.Unlock()
+	return nil
+}
+
+// compileTLVCodes 编译一层标签，names 收集整个子表已使用的名称
+func compileTLVCodes(codes []TLVCode, tagBytes int, names map[string]bool) (map[uint16]*tlvNode, error) {
+	if len(codes) == 0 {
+		return nil, errors.New("codes 为空")
+	}
+	level := make(map[uint16]*tlvNode, len(codes))
+	for i := range codes {
+		c := &codes[i]
+		if tagBytes == 1 && c.Tag > math.MaxUint8 {
+			return nil, fmt.Errorf("标签 0x%X 超出 1 字节", c.Tag)
+		}
+		if _, dup := level[c.Tag]; dup {
+			return nil, fmt.Errorf("标签 0x%X 重复", c.Tag)
+		}
+		if c.Name == "" || names[c.Name] {
+			return nil, fmt.Errorf("标签 0x%X 的名称 %q 为空或重复", c.Tag, c.Name)
+		}
+		names[c.Name] = true
+		n := &tlvNode{code: c}
+		if len(c.Codes) > 0 {
+			if c.DataType != "" {
+				return nil, fmt.Errorf("标签 0x%X（%s）带嵌套子表，不能再声明 dataType", c.Tag, c.Name)
+			}
+			children, err := compileTLVCodes(c.Codes, tagBytes, names)
+			if err != nil {
+				return nil, fmt.Errorf("标签 0x%X（%s）%w", c.Tag, c.Name, err)
+			}
+			n.children = children
+		} else if _, err := decodeTLVScalar(c.DataType, binary.LittleEndian, nil); errors.Is(err, errTLVDataType) {
+			return nil, fmt.Errorf("标签 0x%X（%s）的 dataType %q 无效", c.Tag, c.Name, c.DataType)
+		}
+		level[c.Tag] = n
+	}
+	return level, nil
+}
+
+// LookupTLVTable 按 14bit 类型码查找 TLV 子表
+func LookupTLVTable(paramType uint16) (*TLVTable, bool) {
+	tlvMu.RLock()
+	defer tlvMu.RUnlock()
+	t, ok := tlvTables[paramType&0x3FFF]
+	return t, ok
+}
+
+// Decode 逐层解开参量数据，返回各叶子标签的读数（按报文中的顺序）。
+// 未知标签跳过、格式错误时停止该层，已解出的读数照常返回，错误包装 ErrTLVFormat
+func (t *TLVTable) Decode(data []byte) ([]TLVValue, error) {
+	var out []TLVValue
+	err := t.decodeLevel(t.root, data, &out)
+	return out, err
+}
+
+func (t *TLVTable) decodeLevel(level map[uint16]*tlvNode, data []byte, out *[]TLVValue) error {
+	var firstErr error
+	for len(data) > 0 {
+		if len(data) < t.TagBytes+t.LengthBytes {
+			return errors.Join(firstErr, fmt.Errorf("%w：剩余 %d 字节不足以容纳标签和长度", ErrTLVFormat, len(data)))
+		}
+		tag, n := t.uint(data[:t.TagBytes]), t.uint(data[t.TagBytes:t.TagBytes+t.LengthBytes])
+		data = data[t.TagBytes+t.LengthBytes:]
+		if int(n) > len(data) {
+			return errors.Join(firstErr, fmt.Errorf("%w：标签 0x%X 长度 %d 超出剩余 %d 字节", ErrTLVFormat, tag, n, len(data)))
+		}
+		value := data[:n]
+		data = data[n:]
+		node, ok := level[tag]
+		if !ok {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%w：未知标签 0x%X", ErrTLVFormat, tag)
+			}
+			continue
+		}
+		if node.children != nil {
+			if err := t.decodeLevel(node.children, value, out); err != nil && firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		v, err := decodeTLVScalar(node.code.DataType, t.order, value)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%w：%s %v", ErrTLVFormat, node.code.Name, err)
+			}
+			continue
+		}
+		*out = append(*out, TLVValue{Name: node.code.Name, Unit: node.code.Unit, Value: v})
+	}
+	return firstErr
+}
+
+// uint 按子表字节序读取 1~2 字节的标签或长度
+func (t *TLVTable) uint(b []byte) uint16 {
+	if len(b) == 1 {
+		return uint16(b[0])
+	}
+	return t.order.Uint16(b)
+}
+
+// errTLVDataType 不支持的 dataType
+var errTLVDataType = errors.New("不支持的 dataType")
+
+// decodeTLVScalar 按 dataType 和字节序解析叶子标签的值；b 为 nil 时只校验 dataType
+func decodeTLVScalar(dataType string, order binary.ByteOrder, b []byte) (any, error) {
+	size := map[string]int{"uint8": 1, "int8": 1, "uint16": 2, "int16": 2, "uint32": 4, "int32": 4, "float32": 4}
+	n, fixed := size[dataType]
+	if !fixed && dataType != "string" && dataType != "bytes" {
+		return nil, errTLVDataType
+	}
+	if b == nil {
+		return nil, nil
+	}
+	if fixed && len(b) != n {
+		return nil, fmt.Errorf("期望%d字节，实际%d", n, len(b))
+	}
+	switch dataType {
+	case "uint8":
+		return b[0], nil
+	case "int8":
+		return int8(b[0]), nil
+	case "uint16":
+		return order.Uint16(b), nil
+	case "int16":
+		return int16(order.Uint16(b)), nil
+	case "uint32":
+		return order.Uint32(b), nil
+	case "int32":
+		return int32(order.Uint32(b)), nil
+	case "float32":
+		return math.Float32frombits(order.Uint32(b)), nil
+	case "string":
+		return string(b), nil
+	}
+	return append([]byte(nil), b...), nil
+}
@@ -0,0 +1,80 @@
+package config
+
+import "time"
+
+// QualityTag 读数质量标签名，取值见下方 Quality* 常量，消费者可按此过滤
+const QualityTag = "quality"
+
+// 读数质量
+const (
+	// QualityGood 正常读数
+	QualityGood = "good"
+	// QualityStale 超过 ReadingStaleAfter 未更新，返回的是缓存值
+	QualityStale = "stale"
+	// QualitySuspect 超出参量范围表的合理范围（action=flag）
+	QualitySuspect = "suspect"
+	// QualityRetransmit 来自拼接过程中出现过重传（重复首片或重复片段）的 SDU
+	QualityRetransmit = "reassembled-with-retransmit"
+)
+
+// valueQuality 读数的质量标签及写入时间
+type valueQuality struct {
+	quality string
+	at      time.Time
+}
+
+// SetValueQuality 记录传感器读数的质量标签（good、suspect 等）和写入时间，quality 为空按 good 处理
+func SetValueQuality(deviceName, resourceName, quality string) {
+	SetValueQualityAt(deviceName, resourceName, quality, time.Now())
+}
+
+// SetValueQualityAt 同 SetValueQuality，读数时间为 at（如帧的串口接收时间），
+// 过期判断和 ValueOrigin 均以此为准
+func SetValueQualityAt(deviceName, resourceName, quality string, at time.Time) {
+	if quality == "" {
+		quality = QualityGood
+	}
+	s := shardFor(deviceName)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d := s.device(deviceName)
+	if d.quality == nil {
+		d.quality = make(map[string]valueQuality)
+	}
+	d.quality[resourceName] = valueQuality{quality: quality, at: at}
+}
+
+// ValueOrigin 返回传感器读数的时间（帧的接收时间），非传感器读数返回 false
+func ValueOrigin(deviceName, resourceName string) (time.Time, bool) {
+	s := shardFor(deviceName)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if d := s.devices[deviceName]; d != nil {
+		if q, ok := d.quality[resourceName]; ok {
+			return q.at, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// ValueQuality 返回资源当前值的质量标签：staleAfter > 0 且读数超过该时长未更新时为 stale；
+// 非传感器读数（计数、状态等）返回空
+func ValueQuality(deviceName, resourceName string, staleAfter time.Duration) string {
+	s := shardFor(deviceName)
+	s.mu.RLock()
+	var (
+		q  valueQuality
+		ok bool
+	)
+	if d := s.devices[deviceName]; d != nil {
+		q, ok = d.quality[resourceName]
+	}
+	s.mu.RUnlock()
+	if !ok {
+		return ""
+	}
+	if staleAfter > 0 && time.Since(q.at) > staleAfter {
+		return QualityStale
+	}
+	return q.quality
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ParameterTypeAttr 为 deviceResource 的属性名，值为协议 14bit 参量类型码，
+// 如 parameterType: 0x00A3，解析器据此把参数值写入该资源，而不是 paramMap 中的默认名称
+const ParameterTypeAttr = "parameterType"
+
+// paramResourceMap 记录每个设备上参量类型码到资源名的映射，key: 设备名 → (类型码 → 资源名)，由 resourcesMu 保护
+var paramResourceMap = make(map[string]map[uint16]string)
+
+// parseParamTypeAttr 解析 parameterType 属性值，支持整数和 "0x00A3" 形式的字符串
+func parseParamTypeAttr(v any) (uint16, error) {
+	var n uint64
+	switch x := v.(type) {
+	case int:
+		if x < 0 {
+			return 0, fmt.Errorf("参量类型 %d 不能为负数", x)
+		}
+		n = uint64(x)
+	case int64:
+		if x < 0 {
+			return 0, fmt.Errorf("参量类型 %d 不能为负数", x)
+		}
+		n = uint64(x)
+	case uint64:
+		n = x
+	case float64:
+		n = uint64(x)
+	case string:
+		u, err := strconv.ParseUint(x, 0, 16)
+		if err != nil {
+			return 0, fmt.Errorf("参量类型 %q 格式错误：%w", x, err)
+		}
+		n = u
+	default:
+		return 0, fmt.Errorf("参量类型属性类型不支持：%T", v)
+	}
+	if n > 0x3FFF {
+		return 0, fmt.Errorf("参量类型 0x%X 超出 14bit 范围", n)
+	}
+	return uint16(n), nil
+}
+
+// ParamTypeFromAttributes 读取资源属性中的 parameterType，未声明时 ok 为 false
+func ParamTypeFromAttributes(attrs map[string]any) (paramType uint16, ok bool, err error) {
+	v, ok := attrs[ParameterTypeAttr]
+	if !ok {
+		return 0, false, nil
+	}
+	paramType, err = parseParamTypeAttr(v)
+	return paramType, true, err
+}
+
+// buildParamResourceIndex 根据资源属性建立类型码 → 资源名索引，调用方需持有 resourcesMu 写锁
+func buildParamResourceIndex(deviceName string, resources []DeviceResource) error {
+	index := make(map[uint16]string)
+	for _, dr := range resources {
+		v, ok := dr.Attributes[ParameterTypeAttr]
+		if !ok {
+			continue
+		}
+		code, err := parseParamTypeAttr(v)
+		if err != nil {
+			return fmt.Errorf("设备 %s 资源 %s 的 %s 属性无效：%w", deviceName, dr.Name, ParameterTypeAttr, err)
+		}
+		if prev, dup := index[code]; dup {
+			return fmt.Errorf("设备 %s 的资源 %s 与 %s 映射到同一参量类型 0x%04X", deviceName, prev, dr.Name, code)
+		}
+		index[code] = dr.Name
+	}
+	paramResourceMap[deviceName] = index
+	return nil
+}
+
+// ResolveResourceName 返回设备上承载指定参量类型的资源名；
+// 若 profile 未通过 parameterType 属性声明映射，则返回 fallback（通常为参数表中的默认名称）
+func ResolveResourceName(deviceName string, paramType uint16, fallback string) string {
+	resourcesMu.RLock()
+	defer resourcesMu.RUnlock()
+	if name, ok := paramResourceMap[deviceName][paramType]; ok {
+		return name
+	}
+	return fallback
+}
@@ -0,0 +1,149 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// EncodeParamValue 按参数表中的编码函数把写入值编码为报文数据（小端），与解析方向对称
+func EncodeParamValue(info ParamInfo, v any) ([]byte, error) {
+	encode := info.Encode
+	if encode == nil {
+		var ok bool
+		if encode, ok = encoderFor(info.DataType); !ok {
+			return nil, fmt.Errorf("参数 %s 的数据类型 %s 不支持写入", info.Name, info.DataType)
+		}
+	}
+	buf, err := encode(v)
+	if err != nil {
+		return nil, fmt.Errorf("参数 %s: %w", info.Name, err)
+	}
+	if len(buf) > info.ByteLen {
+		return nil, fmt.Errorf("参数 %s 编码后 %d 字节，超出参数表长度 %d", info.Name, len(buf), info.ByteLen)
+	}
+	if info.ByteLen > len(buf) {
+		// 参数表定义的长度更长时高位（小端在后）补 0
+		buf = append(buf, make([]byte, info.ByteLen-len(buf))...)
+	}
+	return buf, nil
+}
+
+// toFloat64 将 CommandValue 中常见的数值类型统一为 float64
+func toFloat64(v any) (float64, bool) {
+	switch x := v.(type) {
+	case float32:
+		return float64(x), true
+	case float64:
+		return x, true
+	case int:
+		return float64(x), true
+	case int8:
+		return float64(x), true
+	case int16:
+		return float64(x), true
+	case int32:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case uint:
+		return float64(x), true
+	case uint8:
+		return float64(x), true
+	case uint16:
+		return float64(x), true
+	case uint32:
+		return float64(x), true
+	case uint64:
+		return float64(x), true
+	}
+	return 0, false
+}
+
+var (
+	// sensorParamsMu 保护 reportedParams 和 desiredParams
+	sensorParamsMu sync.RWMutex
+	// reportedParams 传感器最近一次上报的通用参数，key: SensorID → (类型码 → 原始数据)
+	reportedParams = make(map[string]map[uint16][]byte)
+	// desiredParams 下发成功的参数期望值，key: SensorID → (类型码 → 原始数据)
+	desiredParams = make(map[string]map[uint16][]byte)
+)
+
+// setParams 将 params 合并进 m[sensorID]，调用方需持有 sensorParamsMu 写锁
+func setParams(m map[string]map[uint16][]byte, sensorID string, params map[uint16][]byte) {
+	dst, ok := m[sensorID]
+	if !ok {
+		dst = make(map[uint16][]byte, len(params))
+		m[sensorID] = dst
+	}
+	for t, data := range params {
+		dst[t] = bytes.Clone(data)
+	}
+}
+
+// getParams 返回 m[sensorID] 的副本
+func getParams(m map[string]map[uint16][]byte, sensorID string) map[uint16][]byte {
+	sensorParamsMu.RLock()
+	defer sensorParamsMu.RUnlock()
+	out := make(map[uint16][]byte, len(m[sensorID]))
+	for t, data := range m[sensorID] {
+		out[t] = bytes.Clone(data)
+	}
+	return out
+}
+
+// SetReportedParams 缓存传感器应答中上报的参数值
+func SetReportedParams(sensorID string, params map[uint16][]byte) {
+	sensorParamsMu.Lock()
+	defer sensorParamsMu.Unlock()
+	setParams(reportedParams, sensorID, params)
+}
+
+// GetReportedParams 返回传感器最近一次上报的参数值副本
+func GetReportedParams(sensorID string) map[uint16][]byte {
+	return getParams(reportedParams, sensorID)
+}
+
+// SetDesiredParams 记录已成功下发的参数期望值
+func SetDesiredParams(sensorID string, params map[uint16][]byte) {
+	sensorParamsMu.Lock()
+	defer sensorParamsMu.Unlock()
+	setParams(desiredParams, sensorID, params)
+}
+
+// GetDesiredParams 返回传感器参数期望值副本
+func GetDesiredParams(sensorID string) map[uint16][]byte {
+	return getParams(desiredParams, sensorID)
+}
+
+// DiffParams 比较期望值与上报值，返回不一致（含未上报）的类型码
+func DiffParams(desired, reported map[uint16][]byte) []uint16 {
+	var drifted []uint16
+	for t, want := range desired {
+		if got, ok := reported[t]; !ok || !bytes.Equal(got, want) {
+			drifted = append(drifted, t)
+		}
+	}
+	return drifted
+}
+
+// SensorForResource 返回承载设备资源的 SensorID：
+// 复合设备按资源名前缀匹配（取最长前缀），单传感器设备直接返回其 SensorID
+func SensorForResource(deviceName, resourceName string) (string, bool) {
+	sensorMu.RLock()
+	defer sensorMu.RUnlock()
+	best, bestLen := "", -1
+	for id, bs := range sensorBindings {
+		for _, b := range bs {
+			if b.DeviceName != deviceName || !strings.HasPrefix(resourceName, b.Prefix) {
+				continue
+			}
+			// 前缀相同时取 SensorID 较小者，保证结果稳定
+			if len(b.Prefix) > bestLen || (len(b.Prefix) == bestLen && id < best) {
+				best, bestLen = id, len(b.Prefix)
+			}
+		}
+	}
+	return best, bestLen >= 0
+}
@@ -0,0 +1,299 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 资源输出变换属性：解析出的读数发布前依次乘 multiply、加 offset、保留 round 位小数，
+// 再按 enumMap 把码值换成文字（资源 valueType 须为 String），单位换算和枚举翻译只改 profile
+const (
+	MultiplyAttr = "multiply"
+	OffsetAttr   = "offset"
+	RoundAttr    = "round"
+	EnumMapAttr  = "enumMap"
+)
+
+// transform 一个资源的输出变换
+type transform struct {
+	multiply, offset float64
+	numeric          bool // 声明了 multiply / offset / round
+	round            int  // 保留的小数位数，-1 不取整
+	enum             map[string]string
+	valueType        string
+}
+
+// transformMap 各设备资源的输出变换，key: 设备名 → (资源名 → 变换)，由 resourcesMu 保护
+var transformMap = make(map[string]map[string]*transform)
+
+// attrFloat 解析数值属性，支持数字和数字字符串
+func attrFloat(v any) (float64, error) {
+	switch x := v.(type) {
+	case int:
+		return float64(x), nil
+	case int64:
+		return float64(x), nil
+	case uint64:
+		return float64(x), nil
+	case float64:
+		return x, nil
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(x), 64)
+	}
+	return 0, fmt.Errorf("类型不支持：%T", v)
+}
+
+// parseEnumMap 解析 enumMap 属性：YAML 映射（如 {0: other, 1: normal}）或 "0:other,1:normal" 形式的字符串
+func parseEnumMap(v any) (map[string]string, error) {
+	out := make(map[string]string)
+	switch x := v.(type) {
+	case map[string]any:
+		for k, val := range x {
+			out[strings.TrimSpace(k)] = fmt.Sprint(val)
+		}
+	case map[any]any:
+		for k, val := range x {
+			out[strings.TrimSpace(fmt.Sprint(k))] = fmt.Sprint(val)
+		}
+	case string:
+		for _, item := range strings.Split(x, ",") {
+			if strings.TrimSpace(item) == "" {
+				continue
+			}
+			k, val, ok := strings.Cut(item, ":")
+			if !ok {
+				return nil, fmt.Errorf("项 %q 应为 码值:文字", item)
+			}
+			out[strings.TrimSpace(k)] = strings.TrimSpace(val)
+		}
+	default:
+		return nil, fmt.Errorf("类型不支持：%T", v)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("不能为空")
+	}
+	return out, nil
+}
+
+// parseTransform 解析资源的变换属性，未声明任何变换时返回 nil
+func parseTransform(dr DeviceResource) (*transform, error) {
+	t := &transform{multiply: 1, round: -1, valueType: dr.Properties.ValueType}
+	declared := false
+	if v, ok := dr.Attributes[MultiplyAttr]; ok {
+		f, err := attrFloat(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s 无效：%w", MultiplyAttr, err)
+		}
+		t.multiply, t.numeric, declared = f, true, true
+	}
+	if v, ok := dr.Attributes[OffsetAttr]; ok {
+		f, err := attrFloat(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s 无效：%w", OffsetAttr, err)
+		}
+		t.offset, t.numeric, declared = f, true, true
+	}
+	if v, ok := dr.Attributes[RoundAttr]; ok {
+		f, err := attrFloat(v)
+		if err != nil || f < 0 || f > 15 || f != math.Trunc(f) {
+			return nil, fmt.Errorf("%s 无效：应为 0~15 的整数", RoundAttr)
+		}
+		t.round, t.numeric, declared = int(f), true, true
+	}
+	if v, ok := dr.Attributes[EnumMapAttr]; ok {
+		m, err := parseEnumMap(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s 无效：%w", EnumMapAttr, err)
+		}
+		if t.valueType != "String" {
+			return nil, fmt.Errorf("声明了 %s 的资源 valueType 须为 String，实际为 %q", EnumMapAttr, t.valueType)
+		}
+		t.enum, declared = m, true
+	}
+	if !declared {
+		return nil, nil
+	}
+	return t, nil
+}
+
+// buildTransformIndex 编译设备各资源的输出变换，调用方需持有 resourcesMu 写锁
+func buildTransformIndex(deviceName string, resources []DeviceResource) error {
+	index := make(map[string]*transform)
+	for _, dr := range resources {
+		t, err := parseTransform(dr)
+		if err != nil {
+			return fmt.Errorf("设备 %s 资源 %s 的输出变换属性无效：%w", deviceName, dr.Name, err)
+		}
+		if t != nil {
+			index[dr.Name] = t
+		}
+	}
+	transformMap[deviceName] = index
+	return nil
+}
+
+// NumericValue 把数值读数转换为 float64，非数值时 ok 为 false
+func NumericValue(v any) (float64, bool) {
+	return toFloat(v)
+}
+
+// NumericFromFloat 按资源 valueType 把数值转换为对应的 Go 类型，整数类型四舍五入并检查范围
+func NumericFromFloat(f float64, valueType string) (any, error) {
+	return fromFloat(f, valueType)
+}
+
+// NumericAttr 解析资源的数值属性，支持数字和数字字符串
+func NumericAttr(v any) (float64, error) {
+	return attrFloat(v)
+}
+
+// toFloat 把数值读数转换为 float64
+func toFloat(v any) (float64, bool) {
+	switch x := v.(type) {
+	case float32:
+		return float64(x), true
+	case float64:
+		return x, true
+	case int8:
+		return float64(x), true
+	case int16:
+		return float64(x), true
+	case int32:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case int:
+		return float64(x), true
+	case uint8:
+		return float64(x), true
+	case uint16:
+		return float64(x), true
+	case uint32:
+		return float64(x), true
+	case uint64:
+		return float64(x), true
+	}
+	return 0, false
+}
+
+// fromFloat 按资源 valueType 把变换后的数值转换回对应的 Go 类型，整数类型四舍五入并检查范围
+func fromFloat(f float64, valueType string) (any, error) {
+	intRange := func(min, max float64) (float64, error) {
+		r := math.Round(f)
+		if r < min || r > max {
+			return 0, fmt.Errorf("变换结果 %v 超出 %s 范围", f, valueType)
+		}
+		return r, nil
+	}
+	switch valueType {
+	case "Float32":
+		return float32(f), nil
+	case "Float64":
+		return f, nil
+	case "String":
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	case "Int8":
+		r, err := intRange(math.MinInt8, math.MaxInt8)
+		return int8(r), err
+	case "Int16":
+		r, err := intRange(math.MinInt16, math.MaxInt16)
+		return int16(r), err
+	case "Int32":
+		r, err := intRange(math.MinInt32, math.MaxInt32)
+		return int32(r), err
+	case "Int64":
+		r, err := intRange(math.MinInt64, math.MaxInt64)
+		return int64(r), err
+	case "Uint8":
+		r, err := intRange(0, math.MaxUint8)
+		return uint8(r), err
+	case "Uint16":
+		r, err := intRange(0, math.MaxUint16)
+		return uint16(r), err
+	case "Uint32":
+		r, err := intRange(0, math.MaxUint32)
+		return uint32(r), err
+	case "Uint64":
+		r, err := intRange(0, math.MaxUint64)
+		return uint64(r), err
+	}
+	return nil, fmt.Errorf("valueType %q 不支持数值变换", valueType)
+}
+
+// apply 对一个读数执行变换
+func (t *transform) apply(value any) (any, error) {
+	// 1. 数值变换
+	if t.numeric {
+		f, ok := toFloat(value)
+		if !ok {
+			return nil, fmt.Errorf("读数 %v（%T）不是数值，无法做 %s/%s/%s 变换", value, value, MultiplyAttr, OffsetAttr, RoundAttr)
+		}
+		f = f*t.multiply + t.offset
+		if t.round >= 0 {
+			p := math.Pow(10, float64(t.round))
+			f = math.Round(f*p) / p
+		}
+		if t.enum == nil {
+			return fromFloat(f, t.valueType)
+		}
+		value = f
+	}
+
+	// 2. 枚举翻译：未列出的码值原样输出为字符串
+	return EnumText(t.enum, value), nil
+}
+
+// EnumTextSuffix 状态类参量的文字资源后缀：Profile 定义了 <资源名>_text 时，
+// 按参数表中的枚举（如 state 的 "0:其它,1:正常,2:异常"）同时发布文字
+const EnumTextSuffix = "_text"
+
+// unitEnums 参数表单位字段解析出的枚举，key 为单位字符串；值为 nil 表示不是枚举
+var unitEnums sync.Map
+
+// Enum 返回参数表单位字段中声明的枚举（码值 → 文字），如 "0:其它,1:正常,2:异常"；
+// 单位不是这种形式（码值须为整数）时 ok 为 false
+func (p ParamInfo) Enum() (map[string]string, bool) {
+	if v, ok := unitEnums.Load(p.Unit); ok {
+		m := v.(map[string]string)
+		return m, m != nil
+	}
+	m, err := parseEnumMap(p.Unit)
+	if err == nil {
+		for k := range m {
+			if _, perr := strconv.Atoi(k); perr != nil {
+				m = nil
+				break
+			}
+		}
+	} else {
+		m = nil
+	}
+	unitEnums.Store(p.Unit, m)
+	return m, m != nil
+}
+
+// EnumText 返回码值对应的文字，未列出的码值原样输出为字符串
+func EnumText(enum map[string]string, value any) string {
+	key := fmt.Sprint(value)
+	if f, ok := toFloat(value); ok {
+		key = strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	if s, ok := enum[key]; ok {
+		return s
+	}
+	return key
+}
+
+// TransformValue 按资源的 multiply / offset / round / enumMap 属性变换读数，未声明变换时原样返回
+func TransformValue(deviceName, resourceName string, value any) (any, error) {
+	resourcesMu.RLock()
+	t := transformMap[deviceName][resourceName]
+	resourcesMu.RUnlock()
+	if t == nil {
+		return value, nil
+	}
+	return t.apply(value)
+}
@@ -0,0 +1,157 @@
+package config
+
+import (
+	"strings"
+)
+
+// UnitTag 读数的单位标签，取值为 UCUM 编码（如 "Cel"、"m"、"%"），消费者可据此做单位换算
+const UnitTag = "unit"
+
+// 显示语言
+const (
+	LangZH = "zh"
+	LangEN = "en"
+	// DefaultLang 未指定语言时使用中文，与参数表和 profile 的描述一致
+	DefaultLang = LangZH
+)
+
+// DescriptionsAttr 资源属性：各语言的描述，如 {en: "Water level"}；中文缺省时使用资源的 description
+const DescriptionsAttr = "descriptions"
+
+// Unit 归一化后的单位：Raw 为参数表或 profile 中的原始写法，UCUM 为标准编码，Display 为各语言的显示名
+type Unit struct {
+	Raw     string            `json:"raw"`
+	UCUM    string            `json:"ucum"`
+	Display map[string]string `json:"display"`
+}
+
+// DisplayName 返回 lang 语言的显示名，没有该语言时依次使用中文和 UCUM 编码
+func (u Unit) DisplayName(lang string) string {
+	if s := u.Display[lang]; s != "" {
+		return s
+	}
+	if s := u.Display[DefaultLang]; s != "" {
+		return s
+	}
+	return u.UCUM
+}
+
+// unitTable 原始单位写法（小写）→ UCUM 编码和显示名；参数表中的单位混用中文符号、大小写和占位符
+var unitTable = map[string]Unit{
+	"m":     {UCUM: "m", Display: map[string]string{LangZH: "米", LangEN: "metre"}},
+	"cm":    {UCUM: "cm", Display: map[string]string{LangZH: "厘米", LangEN: "centimetre"}},
+	"mm":    {UCUM: "mm", Display: map[string]string{LangZH: "毫米", LangEN: "millimetre"}},
+	"%":     {UCUM: "%", Display: map[string]string{LangZH: "百分比", LangEN: "percent"}},
+	"%rh":   {UCUM: "%", Display: map[string]string{LangZH: "相对湿度", LangEN: "percent relative humidity"}},
+	"v":     {UCUM: "V", Display: map[string]string{LangZH: "伏特", LangEN: "volt"}},
+	"mv":    {UCUM: "mV", Display: map[string]string{LangZH: "毫伏", LangEN: "millivolt"}},
+	"a":     {UCUM: "A", Display: map[string]string{LangZH: "安培", LangEN: "ampere"}},
+	"w":     {UCUM: "W", Display: map[string]string{LangZH: "瓦特", LangEN: "watt"}},
+	"℃":     {UCUM: "Cel", Display: map[string]string{LangZH: "摄氏度", LangEN: "degree Celsius"}},
+	"°c":    {UCUM: "Cel", Display: map[string]string{LangZH: "摄氏度", LangEN: "degree Celsius"}},
+	"mol":   {UCUM: "mol", Display: map[string]string{LangZH: "摩尔", LangEN: "mole"}},
+	"cd":    {UCUM: "cd", Display: map[string]string{LangZH: "坎德拉", LangEN: "candela"}},
+	"s":     {UCUM: "s", Display: map[string]string{LangZH: "秒", LangEN: "second"}},
+	"d":     {UCUM: "d", Display: map[string]string{LangZH: "天", LangEN: "day"}},
+	"%/d":   {UCUM: "%/d", Display: map[string]string{LangZH: "百分点每天", LangEN: "percent per day"}},
+	"count": {UCUM: "{count}", Display: map[string]string{LangZH: "次", LangEN: "count"}},
+	"code":  {UCUM: "{code}", Display: map[string]string{LangZH: "代码", LangEN: "code"}},
+	// 参数表用 "\" 表示无量纲
+	"\\": {UCUM: "1", Display: map[string]string{LangZH: "无量纲", LangEN: "dimensionless"}},
+}
+
+// enumUnit 参数表中以 "码值:文字" 列表作为单位的状态类参量
+var enumUnit = Unit{UCUM: "{enum}", Display: map[string]string{LangZH: "枚举", LangEN: "enumeration"}}
+
+// NormalizeUnit 把参数表或 profile 中的单位写法归一化为 UCUM 编码，无法识别时 ok 为 false
+func NormalizeUnit(raw string) (Unit, bool) {
+	s := strings.TrimSpace(raw)
+	if s == "" {
+		return Unit{}, false
+	}
+	u, ok := unitTable[strings.ToLower(s)]
+	if !ok {
+		if _, err := parseEnumMap(s); err != nil || !strings.Contains(s, ":") {
+			return Unit{}, false
+		}
+		u = enumUnit
+	}
+	u.Raw = raw
+	return u, true
+}
+
+// ResourceUnit 返回资源的归一化单位：profile 中声明了 units 时以其为准，否则使用 fallback（参数表中的单位）
+func ResourceUnit(deviceName, resourceName, fallback string) (Unit, bool) {
+	resourcesMu.RLock()
+	var units string
+	for _, r := range resourcesMap[deviceName] {
+		if r.Name == resourceName {
+			units = r.Properties.Units
+			break
+		}
+	}
+	resourcesMu.RUnlock()
+	if units != "" {
+		return NormalizeUnit(units)
+	}
+	return NormalizeUnit(fallback)
+}
+
+// ResourceMeta 资源的元数据，描述和单位显示名按请求的语言给出
+type ResourceMeta struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	ValueType   string `json:"valueType"`
+	ReadWrite   string `json:"readWrite"`
+	Units       string `json:"units,omitempty"`
+	UCUM        string `json:"ucum,omitempty"`
+	UnitDisplay string `json:"unitDisplay,omitempty"`
+}
+
+// localizedDescription 资源在 lang 语言下的描述：descriptions 属性中没有该语言时使用 description
+func localizedDescription(dr DeviceResource, lang string) string {
+	switch m := dr.Attributes[DescriptionsAttr].(type) {
+	case map[string]any:
+		if s, ok := m[lang].(string); ok && s != "" {
+			return s
+		}
+	case map[any]any:
+		if s, ok := m[lang].(string); ok && s != "" {
+			return s
+		}
+	}
+	return dr.Description
+}
+
+// DeviceResourceMeta 返回设备各资源的元数据；单位取 profile 中的 units，未声明时按 parameterType 取参数表中的单位
+func DeviceResourceMeta(deviceName, lang string) ([]ResourceMeta, bool) {
+	if lang == "" {
+		lang = DefaultLang
+	}
+	resources, ok := GetDeviceResources(deviceName)
+	if !ok {
+		return nil, false
+	}
+	out := make([]ResourceMeta, 0, len(resources))
+	for _, dr := range resources {
+		m := ResourceMeta{
+			Name:        dr.Name,
+			Description: localizedDescription(dr, lang),
+			ValueType:   dr.Properties.ValueType,
+			ReadWrite:   dr.Properties.ReadWrite,
+			Units:       dr.Properties.Units,
+		}
+		if m.Units == "" {
+			if pt, ok, _ := ParamTypeFromAttributes(dr.Attributes); ok {
+				if info, ok := LookupParamInfo(pt); ok {
+					m.Units = info.Unit
+				}
+			}
+		}
+		if u, ok := NormalizeUnit(m.Units); ok {
+			m.UCUM, m.UnitDisplay = u.UCUM, u.DisplayName(lang)
+		}
+		out = append(out, m)
+	}
+	return out, true
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"sync"
+)
+
+// valueShardCount 运行时值表的分片数：按设备名哈希分片，解析器写入某台设备时
+// 只锁住所在分片，其它设备的读写不受影响
+const valueShardCount = 64
+
+// deviceValues 一台设备的运行时资源值和读数质量，由所在分片的锁保护
+type deviceValues struct {
+	values map[string]interface{}
+	// quality 传感器读数的质量标签和写入时间；只记录经 SetValueQuality 写入的读数，
+	// 驱动内部状态不带质量标签
+	quality map[string]valueQuality
+}
+
+// valueShard 值表的一个分片
+type valueShard struct {
+	mu      sync.RWMutex
+	devices map[string]*deviceValues
+}
+
+// valueShards 运行时值表，key: 设备名称 → (资源名称 → value)
+var valueShards [valueShardCount]valueShard
+
+func init() {
+	for i := range valueShards {
+		valueShards[i].devices = make(map[string]*deviceValues)
+	}
+}
+
+// shardFor 返回设备所在的分片（FNV-1a 哈希，不分配内存）
+func shardFor(deviceName string) *valueShard {
+	h := uint32(2166136261)
+	for i := 0; i < len(deviceName); i++ {
+		h ^= uint32(deviceName[i])
+		h *= 16777619
+	}
+	return &valueShards[h%valueShardCount]
+}
+
+// device 返回设备的值表，不存在时新建（调用方持有 s.mu 写锁）
+func (s *valueShard) device(deviceName string) *deviceValues {
+	d, ok := s.devices[deviceName]
+	if !ok {
+		d = &deviceValues{values: make(map[string]interface{})}
+		s.devices[deviceName] = d
+	}
+	return d
+}
+
+// replaceDeviceValues 用 values 整体替换设备的运行时值，读数质量保留
+func replaceDeviceValues(deviceName string, values map[string]interface{}) {
+	s := shardFor(deviceName)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.device(deviceName).values = values
+}
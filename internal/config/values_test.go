@@ -0,0 +1,144 @@
+package config
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// valueResources 测试设备的资源名
+var valueResources = [8]string{"r0", "r1", "r2", "r3", "r4", "r5", "r6", "r7"}
+
+// valueDevices 创建 n 台带 8 个资源的测试设备，返回设备名
+func valueDevices(prefix string, n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		names[i] = prefix + strconv.Itoa(i)
+		for _, r := range valueResources {
+			SetDeviceValue(names[i], r, float32(0))
+		}
+		SetDeviceValue(names[i], "count", uint32(0))
+	}
+	return names
+}
+
+// parallelSeq RunParallel 各协程的起始序号，错开各协程访问的设备
+var parallelSeq atomic.Int64
+
+// parallelSeed 返回一个协程的起始序号
+func parallelSeed() int {
+	return int(parallelSeq.Add(7919))
+}
+
+// TestDeviceValuesConcurrent 多协程同时写值、计数、读整表和复制设备，配合 go test -race 检查值表的加锁
+func TestDeviceValuesConcurrent(t *testing.T) {
+	const (
+		devices = 256
+		workers = 8
+		rounds  = 2048 // 设备数的整数倍，每台设备的计数增加次数相同
+	)
+	names := valueDevices("race-", devices)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(3)
+		// 写入读数和质量
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				dev, r := names[(w*rounds+i)%devices], valueResources[i%len(valueResources)]
+				SetDeviceValue(dev, r, float32(i))
+				SetValueQuality(dev, r, QualityGood)
+			}
+		}()
+		// 每台设备的计数各加 rounds/devices 次
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				if _, ok := IncDeviceCounter(names[i%devices], "count"); !ok {
+					t.Errorf("%s 没有计数资源", names[i%devices])
+					return
+				}
+			}
+		}()
+		// 读取整台设备的值，复制到另一台设备
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				dev := names[(w+i)%devices]
+				if vals, ok := GetDeviceValues(dev); !ok || len(vals) != len(valueResources)+1 {
+					t.Errorf("%s 的值表 %v", dev, vals)
+					return
+				}
+				ValueQuality(dev, valueResources[i%len(valueResources)], 0)
+				if err := CopyDeviceValues(dev, "race-copy-"+strconv.Itoa(w)); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	want := uint32(workers * rounds / devices)
+	for _, dev := range names {
+		vals, _ := GetDeviceValues(dev)
+		if vals["count"] != want {
+			t.Errorf("%s 计数 %v，期望 %d", dev, vals["count"], want)
+		}
+	}
+}
+
+func BenchmarkSetDeviceValue(b *testing.B) {
+	for _, n := range []int{1, 256} {
+		b.Run("devices="+strconv.Itoa(n), func(b *testing.B) {
+			names := valueDevices("bench-set-"+strconv.Itoa(n)+"-", n)
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for i := parallelSeed(); pb.Next(); i++ {
+					SetDeviceValue(names[i%n], valueResources[i%len(valueResources)], float32(i))
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkGetDeviceValue 按读命令的方式取整台设备的值表副本并读出单个资源
+func BenchmarkGetDeviceValue(b *testing.B) {
+	for _, n := range []int{1, 256} {
+		b.Run("devices="+strconv.Itoa(n), func(b *testing.B) {
+			names := valueDevices("bench-get-"+strconv.Itoa(n)+"-", n)
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for i := parallelSeed(); pb.Next(); i++ {
+					vals, ok := GetDeviceValues(names[i%n])
+					if !ok {
+						b.Fatal("设备不存在")
+					}
+					_ = vals[valueResources[i%len(valueResources)]]
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkDeviceValuesMixed 四分之一写、四分之三读：对比单台设备（同一分片的锁）与 256 台设备（分散到各分片）
+func BenchmarkDeviceValuesMixed(b *testing.B) {
+	for _, n := range []int{1, 256} {
+		b.Run("devices="+strconv.Itoa(n), func(b *testing.B) {
+			names := valueDevices("bench-mixed-"+strconv.Itoa(n)+"-", n)
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for i := parallelSeed(); pb.Next(); i++ {
+					dev := names[i%n]
+					if i%4 == 0 {
+						SetDeviceValue(dev, valueResources[i%len(valueResources)], float32(i))
+					} else {
+						GetDeviceValues(dev)
+					}
+				}
+			})
+		})
+	}
+}
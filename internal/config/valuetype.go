@@ -0,0 +1,77 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// paramValueTypes 参数表 DataType → 解析结果对应的资源 valueType
+var paramValueTypes = map[string]string{
+	"float32": "Float32",
+	"uint8":   "Uint8",
+	"uint16":  "Uint16",
+	"uint32":  "Uint32",
+	"string":  "String",
+}
+
+// ErrValueTypeMismatch 资源 valueType 与参数表中映射参量的数据类型不一致
+var ErrValueTypeMismatch = errors.New("资源 valueType 与参数表数据类型不一致")
+
+// checkParamValueTypes 检查映射到协议参量的资源 valueType 与参数表 DataType 一致，返回全部不一致项。
+// 通过 parameterType 声明映射的资源按类型码查找参量；未声明的资源按参数表默认名称匹配（同名参量均需一致）。
+// 声明了输出变换的资源发布前按 valueType 转换，不检查
+func checkParamValueTypes(deviceName string, resources []DeviceResource) []string {
+	var mismatches []string
+	check := func(dr DeviceResource, paramType uint16, info ParamInfo) {
+		want, ok := paramValueTypes[info.DataType]
+		if !ok || want == dr.Properties.ValueType {
+			return
+		}
+		mismatches = append(mismatches, fmt.Sprintf("设备 %s 资源 %s 的 valueType 为 %q，参量 0x%04X（%s）为 %s（%d 字节），应为 %q",
+			deviceName, dr.Name, dr.Properties.ValueType, paramType, info.Name, info.DataType, info.ByteLen, want))
+	}
+	for _, dr := range resources {
+		if hasTransform(dr) {
+			continue
+		}
+		paramType, declared, err := ParamTypeFromAttributes(dr.Attributes)
+		if err != nil {
+			continue
+		}
+		if declared {
+			if info, ok := LookupParamInfo(paramType); ok {
+				check(dr, paramType, info)
+			}
+			continue
+		}
+		for _, e := range paramsByName[dr.Name] {
+			// 该参量已由其它资源通过 parameterType 承载时，不会落到同名资源上
+			if _, mapped := paramResourceMap[deviceName][e.paramType]; mapped {
+				continue
+			}
+			check(dr, e.paramType, e.info)
+		}
+	}
+	return mismatches
+}
+
+// hasTransform 资源是否声明了输出变换属性
+func hasTransform(dr DeviceResource) bool {
+	for _, attr := range []string{MultiplyAttr, OffsetAttr, RoundAttr, EnumMapAttr} {
+		if _, ok := dr.Attributes[attr]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// valueTypeError 把全部不一致项合并为一个错误
+func valueTypeError(mismatches []string) error {
+	if len(mismatches) == 0 {
+		return nil
+	}
+	sort.Strings(mismatches)
+	return fmt.Errorf("%w（%d 项）：\n  %s", ErrValueTypeMismatch, len(mismatches), strings.Join(mismatches, "\n  "))
+}
@@ -0,0 +1,114 @@
+// Package correlation 负责把下行请求与随后收到的上行应答关联起来：
+// 发送方在下发前登记期望的应答，解析协程收到匹配的报文后唤醒等待方。
+package correlation
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrTimeout 表示在等待时间内未收到匹配的应答
+var ErrTimeout = errors.New("等待传感器应答超时")
+
+// Key 描述期望的应答：来自哪个传感器、哪种报文类型
+type Key struct {
+	SensorID   string // 大写十六进制 SensorID
+	PacketType byte   // 期望的应答报文类型
+}
+
+// Result 为解析协程交给等待方的应答内容
+type Result struct {
+	Payload any
+	Err     error
+}
+
+// Request 表示一次正在等待应答的下行请求
+type Request struct {
+	key  Key
+	sent time.Time
+	done chan Result
+}
+
+// Engine 保存所有等待中的请求，并发安全
+type Engine struct {
+	mu      sync.Mutex
+	pending map[Key][]*Request
+}
+
+// NewEngine 创建一个空的关联引擎
+func NewEngine() *Engine {
+	return &Engine{pending: make(map[Key][]*Request)}
+}
+
+// Default 为驱动和解析协程共享的全局关联引擎
+var Default = NewEngine()
+
+// Expect 在下发报文前登记期望的应答
+func (e *Engine) Expect(key Key) *Request {
+	r := &Request{key: key, sent: time.Now(), done: make(chan Result, 1)}
+	e.mu.Lock()
+	e.pending[key] = append(e.pending[key], r)
+	e.mu.Unlock()
+	return r
+}
+
+// Resolve 将应答交给所有匹配 key 的等待方，返回被唤醒的请求数
+func (e *Engine) Resolve(key Key, res Result) int {
+	e.mu.Lock()
+	reqs := e.pending[key]
+	delete(e.pending, key)
+	e.mu.Unlock()
+
+	for _, r := range reqs {
+		r.done <- res
+	}
+	return len(reqs)
+}
+
+// Waiting 判断是否有请求在等待 key 的应答，解析协程据此决定是否准备应答内容
+func (e *Engine) Waiting(key Key) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.pending[key]) > 0
+}
+
+// Cancel 撤销一个尚未收到应答的请求
+func (e *Engine) Cancel(r *Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	reqs := e.pending[r.key]
+	for i, p := range reqs {
+		if p == r {
+			e.pending[r.key] = append(reqs[:i], reqs[i+1:]...)
+			break
+		}
+	}
+	if len(e.pending[r.key]) == 0 {
+		delete(e.pending, r.key)
+	}
+}
+
+// Wait 阻塞等待应答，超时后自动撤销请求并返回 ErrTimeout
+func (e *Engine) Wait(r *Request, timeout time.Duration) (Result, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case res := <-r.done:
+		return res, res.Err
+	case <-timer.C:
+		e.Cancel(r)
+		// 撤销与应答可能同时发生，再检查一次避免丢失结果
+		select {
+		case res := <-r.done:
+			return res, res.Err
+		default:
+		}
+		return Result{}, ErrTimeout
+	}
+}
+
+// Sent 返回请求登记（即下发）的时间
+func (r *Request) Sent() time.Time {
+	return r.sent
+}
@@ -0,0 +1,98 @@
+package correlation
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	liveKey  = Key{SensorID: "238A0821BEF2", PacketType: 0x01}
+	paramKey = Key{SensorID: "238A0821BEF2", PacketType: 0x05}
+)
+
+// TestResolve 应答唤醒同一 key 的全部等待方，不影响其他 key；应答中的错误原样返回
+func TestResolve(t *testing.T) {
+	e := NewEngine()
+	before := time.Now()
+	a, b := e.Expect(liveKey), e.Expect(liveKey)
+	other := e.Expect(paramKey)
+	if a.Sent().Before(before) {
+		t.Errorf("登记时间 %v 早于 %v", a.Sent(), before)
+	}
+	if !e.Waiting(liveKey) || e.Waiting(Key{SensorID: "238A0821BEF2", PacketType: 0x09}) {
+		t.Error("Waiting 结果错误")
+	}
+
+	if n := e.Resolve(liveKey, Result{Payload: 42}); n != 2 {
+		t.Errorf("唤醒 %d 个，期望 2", n)
+	}
+	for _, r := range []*Request{a, b} {
+		if res, err := e.Wait(r, time.Second); err != nil || res.Payload != 42 {
+			t.Errorf("Wait=%+v, %v", res, err)
+		}
+	}
+	if e.Waiting(liveKey) || !e.Waiting(paramKey) {
+		t.Error("应答后等待状态错误")
+	}
+	if n := e.Resolve(liveKey, Result{}); n != 0 {
+		t.Errorf("无人等待时唤醒 %d 个", n)
+	}
+
+	nack := errors.New("传感器拒绝设置")
+	e.Resolve(paramKey, Result{Err: nack})
+	if _, err := e.Wait(other, time.Second); err != nack {
+		t.Errorf("Wait err=%v，期望 %v", err, nack)
+	}
+}
+
+// TestWaitTimeout 超时后撤销请求，之后的应答不再唤醒它
+func TestWaitTimeout(t *testing.T) {
+	e := NewEngine()
+	r := e.Expect(liveKey)
+	keep := e.Expect(liveKey)
+	if _, err := e.Wait(r, 10*time.Millisecond); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("err=%v，期望超时", err)
+	}
+	if n := e.Resolve(liveKey, Result{Payload: 1}); n != 1 {
+		t.Errorf("超时后唤醒 %d 个，期望只剩 1 个", n)
+	}
+	if res, err := e.Wait(keep, time.Second); err != nil || res.Payload != 1 {
+		t.Errorf("未超时的请求 Wait=%+v, %v", res, err)
+	}
+
+	e.Cancel(e.Expect(paramKey))
+	if e.Waiting(paramKey) || len(e.pending) != 0 {
+		t.Errorf("撤销后仍在等待 %v", e.pending)
+	}
+}
+
+// TestConcurrent 并发登记、应答、超时不丢失结果
+func TestConcurrent(t *testing.T) {
+	e := NewEngine()
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			key := Key{SensorID: "238A0821BEF2", PacketType: byte(i % 5)}
+			r := e.Expect(key)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				e.Resolve(key, Result{Payload: key.PacketType})
+			}()
+			res, err := e.Wait(r, time.Second)
+			if err != nil || res.Payload != key.PacketType {
+				t.Errorf("Wait=%+v, %v", res, err)
+			}
+		}()
+	}
+	wg.Wait()
+	for i := range 5 {
+		if key := (Key{SensorID: "238A0821BEF2", PacketType: byte(i)}); e.Waiting(key) {
+			t.Errorf("%+v 仍有请求在等待", key)
+		}
+	}
+}
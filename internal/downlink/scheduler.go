@@ -0,0 +1,264 @@
+// Package downlink 按优先级排队下发下行帧：告警确认、心跳应答等时间敏感的报文
+// 优先于普通控制，普通控制优先于固件分片等批量传输；低优先级连续被插队达到上限后
+// 强制发送一帧，避免饿死。所有帧由同一个发送协程串行写入链路。
+package downlink
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Priority 下行优先级，数值越小越优先
+type Priority int
+
+const (
+	PriorityHigh Priority = iota
+	PriorityNormal
+	PriorityBulk
+
+	numPriorities
+)
+
+// String 返回优先级名称，与 ParsePriority 对应
+func (p Priority) String() string {
+	switch p {
+	case PriorityHigh:
+		return "high"
+	case PriorityNormal:
+		return "normal"
+	case PriorityBulk:
+		return "bulk"
+	}
+	return fmt.Sprintf("Priority(%d)", int(p))
+}
+
+// ParsePriority 解析优先级名称（不区分大小写）：high / normal / bulk
+func ParsePriority(s string) (Priority, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "high":
+		return PriorityHigh, nil
+	case "normal":
+		return PriorityNormal, nil
+	case "bulk":
+		return PriorityBulk, nil
+	}
+	return PriorityNormal, fmt.Errorf("未知下行优先级 %q，应为 high / normal / bulk", s)
+}
+
+// MarshalText JSON 等文本格式中以名称表示优先级
+func (p Priority) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// ErrStopped 调度器已停止，帧未发送
+var ErrStopped = errors.New("下行调度已停止")
+
+// ErrCanceled 帧在发送前被取消（如传感器已永久离线，运维清空其队列）
+var ErrCanceled = errors.New("下行帧已取消")
+
+// Job 一帧待下发的报文
+type Job struct {
+	Priority Priority
+	// Writer 下发时使用的链路连接（入队时的当前链路）
+	Writer io.Writer
+	Frame  []byte
+	// NoDefer 为 true 时发射预算不足直接放弃，不推迟（如心跳应答）
+	NoDefer bool
+	// Target 目标传感器的 SensorID，供查询和按传感器清空队列
+	Target string
+}
+
+// QueuedJob 排队中的一帧的快照
+type QueuedJob struct {
+	ID       uint64    `json:"id"`
+	Priority Priority  `json:"priority"`
+	Target   string    `json:"sensorId"`
+	Size     int       `json:"size"`
+	Queued   time.Time `json:"queued"`
+}
+
+// SendFunc 实际下发一帧，由发送协程串行调用
+type SendFunc func(job Job) error
+
+type pending struct {
+	id     uint64
+	queued time.Time
+	job    Job
+	done   chan error
+}
+
+// Scheduler 优先级下行队列
+type Scheduler struct {
+	send SendFunc
+	// starvationLimit 低优先级帧在队列中时，连续发送更高优先级帧的上限
+	starvationLimit int
+
+	mu      sync.Mutex
+	queues  [numPriorities][]*pending
+	skipped [numPriorities]int // 各优先级自上次发送以来被插队的次数
+	stopped bool
+	lastID  uint64
+	wake    chan struct{}
+}
+
+// New 创建调度器，starvationLimit <= 0 时不做饿死保护（严格按优先级）
+func New(send SendFunc, starvationLimit int) *Scheduler {
+	return &Scheduler{
+		send:            send,
+		starvationLimit: starvationLimit,
+		wake:            make(chan struct{}, 1),
+	}
+}
+
+// Submit 将帧入队，返回的通道在帧发送完成（或失败）后收到结果
+func (s *Scheduler) Submit(job Job) <-chan error {
+	p := &pending{queued: time.Now(), job: job, done: make(chan error, 1)}
+	if job.Priority < 0 || job.Priority >= numPriorities {
+		p.job.Priority = PriorityNormal
+	}
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		p.done <- ErrStopped
+		return p.done
+	}
+	s.lastID++
+	p.id = s.lastID
+	s.queues[p.job.Priority] = append(s.queues[p.job.Priority], p)
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return p.done
+}
+
+// Send 入队并等待发送结果
+func (s *Scheduler) Send(job Job) error {
+	return <-s.Submit(job)
+}
+
+// Pending 返回排队中的帧，按发送顺序（优先级、入队先后）排列，不含正在发送的帧
+func (s *Scheduler) Pending() []QueuedJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []QueuedJob
+	for _, q := range s.queues {
+		for _, p := range q {
+			out = append(out, QueuedJob{
+				ID:       p.id,
+				Priority: p.job.Priority,
+				Target:   p.job.Target,
+				Size:     len(p.job.Frame),
+				Queued:   p.queued,
+			})
+		}
+	}
+	return out
+}
+
+// Cancel 取消一帧排队中的报文，等待方收到 ErrCanceled；帧已发出或不存在时返回 false
+func (s *Scheduler) Cancel(id uint64) bool {
+	return s.remove(func(p *pending) bool { return p.id == id }) > 0
+}
+
+// Flush 取消发往 target 的全部排队报文，返回取消的帧数
+func (s *Scheduler) Flush(target string) int {
+	return s.remove(func(p *pending) bool { return p.job.Target == target })
+}
+
+// remove 从队列中移除满足 match 的帧并以 ErrCanceled 结束
+func (s *Scheduler) remove(match func(p *pending) bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for i, q := range s.queues {
+		kept := q[:0]
+		for _, p := range q {
+			if match(p) {
+				p.done <- ErrCanceled
+				n++
+				continue
+			}
+			kept = append(kept, p)
+		}
+		s.queues[i] = kept
+	}
+	return n
+}
+
+// next 取出下一帧：被插队次数达到上限的低优先级先发，否则取最高优先级
+func (s *Scheduler) next() *pending {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pick := -1
+	if s.starvationLimit > 0 {
+		for p := numPriorities - 1; p > 0; p-- {
+			if len(s.queues[p]) > 0 && s.skipped[p] >= s.starvationLimit {
+				pick = int(p)
+				break
+			}
+		}
+	}
+	if pick < 0 {
+		for p := range s.queues {
+			if len(s.queues[p]) > 0 {
+				pick = p
+				break
+			}
+		}
+	}
+	if pick < 0 {
+		return nil
+	}
+
+	job := s.queues[pick][0]
+	s.queues[pick] = s.queues[pick][1:]
+	s.skipped[pick] = 0
+	// 更低优先级仍在排队的，记一次被插队
+	for p := pick + 1; p < int(numPriorities); p++ {
+		if len(s.queues[p]) > 0 {
+			s.skipped[p]++
+		}
+	}
+	return job
+}
+
+// Run 发送协程：逐帧取出并下发，直到 stop 关闭；停止时排队中的帧以 ErrStopped 结束
+func (s *Scheduler) Run(stop <-chan struct{}) {
+	defer s.drain()
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		if job := s.next(); job != nil {
+			job.done <- s.send(job.job)
+			continue
+		}
+		select {
+		case <-s.wake:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// drain 标记停止并结束排队中的帧
+func (s *Scheduler) drain() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopped = true
+	for p := range s.queues {
+		for _, job := range s.queues[p] {
+			job.done <- ErrStopped
+		}
+		s.queues[p] = nil
+	}
+}
@@ -0,0 +1,172 @@
+package downlink
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParsePriority(t *testing.T) {
+	for _, p := range []Priority{PriorityHigh, PriorityNormal, PriorityBulk} {
+		if got, err := ParsePriority(" " + p.String() + " "); err != nil || got != p {
+			t.Errorf("ParsePriority(%q)=%v, %v", p.String(), got, err)
+		}
+	}
+	if p, err := ParsePriority("BULK"); err != nil || p != PriorityBulk {
+		t.Errorf("不区分大小写 %v, %v", p, err)
+	}
+	if _, err := ParsePriority("urgent"); err == nil {
+		t.Error("未知优先级未报错")
+	}
+	b, _ := json.Marshal(QueuedJob{Priority: PriorityBulk})
+	var got map[string]any
+	json.Unmarshal(b, &got)
+	if got["priority"] != "bulk" {
+		t.Errorf("JSON 中的优先级 %v", got["priority"])
+	}
+}
+
+// runOrder 先入队全部帧再启动发送协程，返回实际发送顺序
+func runOrder(t *testing.T, starvationLimit int, jobs []Job) []string {
+	t.Helper()
+	var (
+		mu   sync.Mutex
+		sent []string
+	)
+	s := New(func(job Job) error {
+		mu.Lock()
+		sent = append(sent, job.Target)
+		mu.Unlock()
+		return nil
+	}, starvationLimit)
+	var done []<-chan error
+	for _, j := range jobs {
+		done = append(done, s.Submit(j))
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go s.Run(stop)
+	for i, ch := range done {
+		select {
+		case err := <-ch:
+			if err != nil {
+				t.Fatalf("%s 发送失败：%v", jobs[i].Target, err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%s 未发送", jobs[i].Target)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return sent
+}
+
+var mixedJobs = []Job{
+	{Priority: PriorityBulk, Target: "B1"},
+	{Priority: PriorityNormal, Target: "N1"},
+	{Priority: PriorityHigh, Target: "H1"},
+	{Priority: PriorityHigh, Target: "H2"},
+	{Priority: PriorityHigh, Target: "H3"},
+	{Priority: PriorityHigh, Target: "H4"},
+}
+
+func TestStrictPriority(t *testing.T) {
+	got := runOrder(t, 0, mixedJobs)
+	if want := []string{"H1", "H2", "H3", "H4", "N1", "B1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("发送顺序 %v，期望 %v", got, want)
+	}
+}
+
+// TestStarvationLimit 低优先级连续被插队达到上限后强制发送一帧，最低的优先
+func TestStarvationLimit(t *testing.T) {
+	got := runOrder(t, 2, mixedJobs)
+	if want := []string{"H1", "H2", "B1", "N1", "H3", "H4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("发送顺序 %v，期望 %v", got, want)
+	}
+	// 超出范围的优先级按 normal 处理
+	got = runOrder(t, 0, []Job{{Priority: PriorityBulk, Target: "B1"}, {Priority: 7, Target: "X"}, {Priority: -1, Target: "Y"}})
+	if want := []string{"X", "Y", "B1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("发送顺序 %v，期望 %v", got, want)
+	}
+}
+
+// TestPendingCancelFlush 排队快照按发送顺序；取消的帧以 ErrCanceled 结束
+func TestPendingCancelFlush(t *testing.T) {
+	s := New(func(Job) error { return nil }, 0)
+	bulk := s.Submit(Job{Priority: PriorityBulk, Target: "A", Frame: make([]byte, 300)})
+	normal := s.Submit(Job{Priority: PriorityNormal, Target: "B", Frame: make([]byte, 12)})
+	high := s.Submit(Job{Priority: PriorityHigh, Target: "A", Frame: make([]byte, 8)})
+
+	var got []string
+	for _, q := range s.Pending() {
+		got = append(got, q.Priority.String()+"/"+q.Target)
+	}
+	if want := []string{"high/A", "normal/B", "bulk/A"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("排队 %v，期望 %v", got, want)
+	}
+	pending := s.Pending()
+	if pending[0].ID != 3 || pending[0].Size != 8 || pending[2].ID != 1 || pending[2].Size != 300 {
+		t.Errorf("快照 %+v", pending)
+	}
+
+	if !s.Cancel(2) || s.Cancel(2) || s.Cancel(99) {
+		t.Error("Cancel 只应对排队中的帧返回 true")
+	}
+	if err := <-normal; !errors.Is(err, ErrCanceled) {
+		t.Errorf("取消的帧结果 %v", err)
+	}
+	if n := s.Flush("A"); n != 2 {
+		t.Errorf("Flush 取消 %d 帧，期望 2", n)
+	}
+	for _, ch := range []<-chan error{bulk, high} {
+		if err := <-ch; !errors.Is(err, ErrCanceled) {
+			t.Errorf("Flush 的帧结果 %v", err)
+		}
+	}
+	if len(s.Pending()) != 0 {
+		t.Errorf("仍有排队 %+v", s.Pending())
+	}
+}
+
+// TestStop 停止时排队中的帧以 ErrStopped 结束，之后入队的帧直接失败；发送错误原样返回给等待方
+func TestStop(t *testing.T) {
+	sendErr := errors.New("链路已断开")
+	release := make(chan struct{})
+	started := make(chan struct{})
+	s := New(func(job Job) error {
+		if job.Target == "first" {
+			close(started)
+			<-release
+			return sendErr
+		}
+		return nil
+	}, 0)
+	stop := make(chan struct{})
+	ran := make(chan struct{})
+	go func() {
+		s.Run(stop)
+		close(ran)
+	}()
+
+	first := s.Submit(Job{Target: "first"})
+	<-started
+	queued := s.Submit(Job{Target: "queued"})
+	if p := s.Pending(); len(p) != 1 || p[0].Target != "queued" {
+		t.Errorf("排队快照含正在发送的帧 %+v", p)
+	}
+	close(stop)
+	close(release)
+	if err := <-first; err != sendErr {
+		t.Errorf("发送结果 %v，期望 %v", err, sendErr)
+	}
+	<-ran
+	if err := <-queued; !errors.Is(err, ErrStopped) {
+		t.Errorf("停止时排队的帧结果 %v", err)
+	}
+	if err := s.Send(Job{Target: "late"}); !errors.Is(err, ErrStopped) {
+		t.Errorf("停止后入队结果 %v", err)
+	}
+}
@@ -0,0 +1,323 @@
+// Package downsample 按资源对高频读数降采样：profile 属性 downsample 声明了策略的资源，
+// 在每个时间窗口（按 Origin 对齐，如整分钟）内只发布一个聚合值（latest / mean / max），
+// 原始读数保留在按资源的环形历史缓冲中，可经 REST 接口查询。
+package downsample
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+)
+
+// KeyHistorySize Driver 配置项：每个降采样资源保留的原始读数条数
+const (
+	KeyHistorySize     = "DownsampleHistorySize"
+	defaultHistorySize = 600
+)
+
+// 资源属性
+const (
+	// AttrPolicy 降采样策略：latest / mean / max
+	AttrPolicy = "downsample"
+	// AttrWindow 聚合窗口，如 "1m"
+	AttrWindow = "downsampleWindow"
+)
+
+// 聚合读数上的标签：策略和窗口（如 "mean/1m0s"）、窗口内的原始读数个数
+const (
+	Tag        = "downsample"
+	TagSamples = "samples"
+)
+
+// 降采样策略
+const (
+	ModeLatest = "latest"
+	ModeMean   = "mean"
+	ModeMax    = "max"
+)
+
+// flushGrace 窗口结束后再等待的时间，容纳稍晚到达的读数
+const flushGrace = time.Second
+
+// Policy 一个资源的降采样策略
+type Policy struct {
+	Mode   string
+	Window time.Duration
+}
+
+// ParsePolicy 从资源属性读取降采样策略，未声明 downsample 时 ok 为 false
+func ParsePolicy(attrs map[string]any) (p Policy, ok bool, err error) {
+	v, declared := attrs[AttrPolicy]
+	if !declared {
+		return p, false, nil
+	}
+	p.Mode = strings.ToLower(strings.TrimSpace(fmt.Sprint(v)))
+	switch p.Mode {
+	case ModeLatest, ModeMean, ModeMax:
+	default:
+		return p, false, fmt.Errorf("%s 属性无效 %q，应为 latest / mean / max", AttrPolicy, v)
+	}
+	w, declared := attrs[AttrWindow]
+	if !declared {
+		return p, false, fmt.Errorf("声明了 %s 的资源须同时声明 %s", AttrPolicy, AttrWindow)
+	}
+	if p.Window, err = time.ParseDuration(strings.TrimSpace(fmt.Sprint(w))); err != nil || p.Window <= 0 {
+		return p, false, fmt.Errorf("%s 属性无效 %q", AttrWindow, w)
+	}
+	return p, true, nil
+}
+
+// HistorySizeFromDriver 读取原始读数历史条数
+func HistorySizeFromDriver(driverCfg map[string]string) (int, error) {
+	v := driverCfg[KeyHistorySize]
+	if v == "" {
+		return defaultHistorySize, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%s 配置无效 %q", KeyHistorySize, v)
+	}
+	return n, nil
+}
+
+// Sink 读数的下游，与 frameparser.ValueSink 相同
+type Sink interface {
+	SetValue(deviceName, resourceName string, value any, origin time.Time, tags map[string]string)
+}
+
+// Logger 日志输出
+type Logger interface {
+	Printf(format string, args ...any)
+}
+
+// Sample 一个原始读数
+type Sample struct {
+	Value  any       `json:"value"`
+	Origin time.Time `json:"origin"`
+}
+
+// entry 一个资源生效的策略，nil 表示不降采样
+type entry struct {
+	policy    Policy
+	valueType string
+}
+
+// bucket 一个窗口内的聚合状态
+type bucket struct {
+	start      time.Time
+	count      int
+	sum, max   float64
+	last       any
+	lastOrigin time.Time
+	lastTags   map[string]string
+	numeric    bool
+}
+
+// emission 待发布的聚合读数，在锁外交给下游
+type emission struct {
+	device, resource string
+	value            any
+	origin           time.Time
+	tags             map[string]string
+}
+
+// Sampler 作为 Sink 包装下游：降采样资源按窗口聚合后发布，其它资源直接转发
+type Sampler struct {
+	next        Sink
+	log         Logger
+	historySize int
+
+	mu      sync.Mutex
+	entries map[string]map[string]*entry
+	buckets map[string]*bucket
+	history map[string][]Sample
+}
+
+// New 创建降采样 Sink；需调用 Run 按时发布已结束的窗口
+func New(next Sink, historySize int, log Logger) *Sampler {
+	return &Sampler{
+		next:        next,
+		log:         log,
+		historySize: historySize,
+		entries:     make(map[string]map[string]*entry),
+		buckets:     make(map[string]*bucket),
+		history:     make(map[string][]Sample),
+	}
+}
+
+func key(deviceName, resourceName string) string {
+	return deviceName + "\x00" + resourceName
+}
+
+// resolve 返回资源的降采样策略，首次使用时按 profile 属性解析，调用方需持有 mu
+func (s *Sampler) resolve(deviceName, resourceName string) *entry {
+	byRes, ok := s.entries[deviceName]
+	if !ok {
+		byRes = make(map[string]*entry)
+		s.entries[deviceName] = byRes
+	}
+	if e, ok := byRes[resourceName]; ok {
+		return e
+	}
+	// 属性无效时同样缓存为不降采样，只报一次错误
+	byRes[resourceName] = nil
+	resources, _ := config.GetDeviceResources(deviceName)
+	for _, dr := range resources {
+		if dr.Name != resourceName {
+			continue
+		}
+		p, ok, err := ParsePolicy(dr.Attributes)
+		if err != nil {
+			s.log.Printf("设备 %s 资源 %s 的降采样属性无效，不降采样: %v", deviceName, resourceName, err)
+			return nil
+		}
+		if ok {
+			byRes[resourceName] = &entry{policy: p, valueType: dr.Properties.ValueType}
+		}
+		break
+	}
+	return byRes[resourceName]
+}
+
+// SetValue 实现 Sink
+func (s *Sampler) SetValue(deviceName, resourceName string, value any, origin time.Time, tags map[string]string) {
+	s.mu.Lock()
+	e := s.resolve(deviceName, resourceName)
+	if e == nil {
+		s.mu.Unlock()
+		s.next.SetValue(deviceName, resourceName, value, origin, tags)
+		return
+	}
+	k := key(deviceName, resourceName)
+
+	// 1. 原始读数进入历史缓冲
+	h := append(s.history[k], Sample{Value: value, Origin: origin})
+	if len(h) > s.historySize {
+		h = append([]Sample(nil), h[len(h)-s.historySize:]...)
+	}
+	s.history[k] = h
+
+	// 2. 进入新窗口时先发布上一个窗口
+	var out []emission
+	start := origin.Truncate(e.policy.Window)
+	b := s.buckets[k]
+	if b != nil && !b.start.Equal(start) {
+		out = append(out, s.flush(deviceName, resourceName, e, b))
+		b = nil
+	}
+	if b == nil {
+		b = &bucket{start: start, numeric: true}
+		s.buckets[k] = b
+	}
+
+	// 3. 聚合
+	if f, ok := config.NumericValue(value); ok {
+		if b.count == 0 || f > b.max {
+			b.max = f
+		}
+		b.sum += f
+	} else {
+		b.numeric = false
+	}
+	b.count++
+	b.last, b.lastOrigin, b.lastTags = value, origin, tags
+	s.mu.Unlock()
+
+	s.emit(out)
+}
+
+// flush 结束一个窗口，返回其聚合读数，调用方需持有 mu
+func (s *Sampler) flush(deviceName, resourceName string, e *entry, b *bucket) emission {
+	delete(s.buckets, key(deviceName, resourceName))
+	em := emission{device: deviceName, resource: resourceName, value: b.last, origin: b.lastOrigin, tags: make(map[string]string, len(b.lastTags)+2)}
+	for k, v := range b.lastTags {
+		em.tags[k] = v
+	}
+	em.tags[Tag] = e.policy.Mode + "/" + e.policy.Window.String()
+	em.tags[TagSamples] = strconv.Itoa(b.count)
+
+	if e.policy.Mode == ModeLatest {
+		return em
+	}
+	if !b.numeric {
+		s.log.Printf("设备 %s 资源 %s 的读数不是数值，无法按 %s 聚合，发布窗口内最后一个读数", deviceName, resourceName, e.policy.Mode)
+		return em
+	}
+	f := b.max
+	if e.policy.Mode == ModeMean {
+		f = b.sum / float64(b.count)
+	}
+	v, err := config.NumericFromFloat(f, e.valueType)
+	if err != nil {
+		s.log.Printf("设备 %s 资源 %s 聚合值转换失败，发布窗口内最后一个读数: %v", deviceName, resourceName, err)
+		return em
+	}
+	em.value = v
+	return em
+}
+
+// emit 把聚合读数交给下游
+func (s *Sampler) emit(out []emission) {
+	for _, em := range out {
+		s.next.SetValue(em.device, em.resource, em.value, em.origin, em.tags)
+	}
+}
+
+// flushDue 发布已结束的窗口（传感器停止上报时最后一个窗口也能发布）
+func (s *Sampler) flushDue(now time.Time) {
+	s.mu.Lock()
+	var out []emission
+	for k, b := range s.buckets {
+		deviceName, resourceName, _ := strings.Cut(k, "\x00")
+		e := s.entries[deviceName][resourceName]
+		if e == nil || now.Before(b.start.Add(e.policy.Window+flushGrace)) {
+			continue
+		}
+		out = append(out, s.flush(deviceName, resourceName, e, b))
+	}
+	s.mu.Unlock()
+	s.emit(out)
+}
+
+// Run 每秒发布已结束的窗口，直到 stop 关闭
+func (s *Sampler) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			s.flushDue(now)
+		}
+	}
+}
+
+// History 返回资源最近的原始读数（按到达顺序）
+func (s *Sampler) History(deviceName, resourceName string) []Sample {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Sample{}, s.history[key(deviceName, resourceName)]...)
+}
+
+// Forget 丢弃设备未发布的窗口、历史和策略缓存，设备 profile 变化后重新解析
+func (s *Sampler) Forget(deviceName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, deviceName)
+	prefix := deviceName + "\x00"
+	for k := range s.buckets {
+		if strings.HasPrefix(k, prefix) {
+			delete(s.buckets, k)
+		}
+	}
+	for k := range s.history {
+		if strings.HasPrefix(k, prefix) {
+			delete(s.history, k)
+		}
+	}
+}
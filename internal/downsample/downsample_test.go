@@ -0,0 +1,215 @@
+package downsample
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+)
+
+const testDevice = "Downsample-Test"
+
+// testProfile 各策略的降采样资源、属性无效和未声明策略的资源
+const testProfile = `name: "Downsample-Test-Profile"
+deviceResources:
+  - name: "level"
+    attributes: { downsample: "mean", downsampleWindow: "1m" }
+    properties: { valueType: "Float32", readWrite: "R", defaultValue: "0" }
+  - name: "peak"
+    attributes: { downsample: "MAX", downsampleWindow: "1m" }
+    properties: { valueType: "Int16", readWrite: "R", defaultValue: "0" }
+  - name: "mode"
+    attributes: { downsample: "latest", downsampleWindow: "1m" }
+    properties: { valueType: "String", readWrite: "R", defaultValue: "" }
+  - name: "label"
+    attributes: { downsample: "mean", downsampleWindow: "1m" }
+    properties: { valueType: "String", readWrite: "R", defaultValue: "" }
+  - name: "bad"
+    attributes: { downsample: "median", downsampleWindow: "1m" }
+    properties: { valueType: "Float32", readWrite: "R", defaultValue: "0" }
+  - name: "plain"
+    properties: { valueType: "Float32", readWrite: "R", defaultValue: "0" }
+`
+
+type reading struct {
+	resource string
+	value    any
+	origin   time.Time
+	tags     map[string]string
+}
+
+type recorder struct{ got []reading }
+
+func (r *recorder) SetValue(_, resourceName string, value any, origin time.Time, tags map[string]string) {
+	r.got = append(r.got, reading{resourceName, value, origin, tags})
+}
+
+type logs struct{ lines []string }
+
+func (l *logs) Printf(format string, args ...any) {
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func loadTestProfile(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "Downsample-Test-Profile.yaml"), []byte(testProfile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.LoadDeviceProfile(testDevice, "Downsample-Test-Profile", dir); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	cases := []struct {
+		attrs map[string]any
+		want  Policy
+		ok    bool
+		err   bool
+	}{
+		{attrs: map[string]any{}},
+		{attrs: map[string]any{AttrPolicy: " Mean ", AttrWindow: "30s"}, want: Policy{ModeMean, 30 * time.Second}, ok: true},
+		{attrs: map[string]any{AttrPolicy: "latest", AttrWindow: "1h"}, want: Policy{ModeLatest, time.Hour}, ok: true},
+		{attrs: map[string]any{AttrPolicy: "median", AttrWindow: "1m"}, err: true},
+		{attrs: map[string]any{AttrPolicy: "max"}, err: true},
+		{attrs: map[string]any{AttrPolicy: "max", AttrWindow: "0s"}, err: true},
+		{attrs: map[string]any{AttrPolicy: "max", AttrWindow: 60}, err: true},
+	}
+	for _, c := range cases {
+		p, ok, err := ParsePolicy(c.attrs)
+		if (err != nil) != c.err || ok != c.ok || ok && p != c.want {
+			t.Errorf("ParsePolicy(%v)=%+v, %v, %v", c.attrs, p, ok, err)
+		}
+	}
+}
+
+func TestHistorySizeFromDriver(t *testing.T) {
+	for v, want := range map[string]int{"": defaultHistorySize, "10": 10, "0": -1, "x": -1} {
+		n, err := HistorySizeFromDriver(map[string]string{KeyHistorySize: v})
+		if want < 0 && err == nil || want >= 0 && (err != nil || n != want) {
+			t.Errorf("%s=%q: %d, %v", KeyHistorySize, v, n, err)
+		}
+	}
+}
+
+// TestSamplerWindows 降采样资源在进入下一个窗口时发布上一个窗口的聚合值，带策略和读数个数标签；
+// 未声明或声明无效的资源直接转发
+func TestSamplerWindows(t *testing.T) {
+	loadTestProfile(t)
+	next, log := &recorder{}, &logs{}
+	s := New(next, 3, log)
+	w0 := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	w1 := w0.Add(time.Minute)
+
+	tags := map[string]string{"sensorId": "238A0821BEF2"}
+	for i, r := range []reading{
+		{"level", float32(1), w0, nil},
+		{"level", float32(2), w0.Add(10 * time.Second), nil},
+		{"level", float32(6), w0.Add(50 * time.Second), tags},
+		{"peak", int16(3), w0, nil},
+		{"peak", int16(9), w0.Add(time.Second), nil},
+		{"peak", int16(4), w0.Add(2 * time.Second), tags},
+		{"mode", "open", w0, nil},
+		{"mode", "closed", w0.Add(time.Second), tags},
+		{"label", "a", w0, nil},
+		{"label", "b", w0.Add(time.Second), tags},
+		{"bad", float32(7), w0, nil},
+		{"plain", float32(8), w0, nil},
+	} {
+		s.SetValue(testDevice, r.resource, r.value, r.origin, r.tags)
+		if n := len(next.got); r.resource == "bad" && n != 1 || r.resource == "plain" && n != 2 || i < 10 && n != 0 {
+			t.Fatalf("第 %d 个读数后下游收到 %v", i, next.got)
+		}
+	}
+	if next.got[0].value != float32(7) || next.got[1].value != float32(8) || next.got[1].tags != nil {
+		t.Errorf("直接转发的读数 %+v", next.got)
+	}
+	if len(log.lines) != 1 || !strings.Contains(log.lines[0], "bad") {
+		t.Errorf("日志 %q，期望只报一次 bad 的属性无效", log.lines)
+	}
+
+	// 进入下一个窗口：发布上一个窗口的聚合值，Origin 和标签沿用窗口内最后一个读数
+	next.got = nil
+	want := map[string]reading{
+		"level": {"level", float32(3), w0.Add(50 * time.Second), map[string]string{"sensorId": "238A0821BEF2", Tag: "mean/1m0s", TagSamples: "3"}},
+		"peak":  {"peak", int16(9), w0.Add(2 * time.Second), map[string]string{"sensorId": "238A0821BEF2", Tag: "max/1m0s", TagSamples: "3"}},
+		"mode":  {"mode", "closed", w0.Add(time.Second), map[string]string{"sensorId": "238A0821BEF2", Tag: "latest/1m0s", TagSamples: "2"}},
+		// 非数值无法求平均，发布最后一个读数
+		"label": {"label", "b", w0.Add(time.Second), map[string]string{"sensorId": "238A0821BEF2", Tag: "mean/1m0s", TagSamples: "2"}},
+	}
+	for _, res := range []string{"level", "peak", "mode", "label"} {
+		s.SetValue(testDevice, res, nil, w1, nil)
+	}
+	if len(next.got) != len(want) {
+		t.Fatalf("下游收到 %+v，期望 %d 个聚合读数", next.got, len(want))
+	}
+	for _, r := range next.got {
+		if w := want[r.resource]; !reflect.DeepEqual(r, w) {
+			t.Errorf("聚合读数 %+v，期望 %+v", r, w)
+		}
+	}
+	if tags[Tag] != "" {
+		t.Error("聚合标签写入了原始读数的标签")
+	}
+	if len(log.lines) != 2 || !strings.Contains(log.lines[1], "label") {
+		t.Errorf("日志 %q，期望报告 label 不是数值", log.lines)
+	}
+}
+
+// TestSamplerFlushDue 传感器停止上报时，窗口结束并超过 flushGrace 后由 flushDue 发布
+func TestSamplerFlushDue(t *testing.T) {
+	loadTestProfile(t)
+	next := &recorder{}
+	s := New(next, 3, &logs{})
+	w0 := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	s.SetValue(testDevice, "level", float32(4), w0.Add(30*time.Second), nil)
+
+	s.flushDue(w0.Add(time.Minute))
+	if len(next.got) != 0 {
+		t.Fatalf("宽限时间内提前发布 %+v", next.got)
+	}
+	s.flushDue(w0.Add(time.Minute + flushGrace))
+	if len(next.got) != 1 || next.got[0].value != float32(4) || next.got[0].tags[TagSamples] != "1" {
+		t.Fatalf("窗口结束后发布 %+v", next.got)
+	}
+	s.flushDue(w0.Add(time.Hour))
+	if len(next.got) != 1 {
+		t.Errorf("同一窗口发布了两次 %+v", next.got)
+	}
+}
+
+// TestSamplerHistory 原始读数按到达顺序保留最近 historySize 个；Forget 清除设备的窗口和历史
+func TestSamplerHistory(t *testing.T) {
+	loadTestProfile(t)
+	next := &recorder{}
+	s := New(next, 3, &logs{})
+	w0 := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	for i := range 5 {
+		s.SetValue(testDevice, "level", float32(i), w0.Add(time.Duration(i)*time.Second), nil)
+	}
+	var got []any
+	for _, h := range s.History(testDevice, "level") {
+		got = append(got, h.Value)
+	}
+	if want := []any{float32(2), float32(3), float32(4)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("历史 %v，期望 %v", got, want)
+	}
+	if h := s.History(testDevice, "plain"); len(h) != 0 {
+		t.Errorf("不降采样的资源有历史 %v", h)
+	}
+
+	s.Forget(testDevice)
+	if h := s.History(testDevice, "level"); len(h) != 0 {
+		t.Errorf("Forget 后历史 %v", h)
+	}
+	s.flushDue(w0.Add(time.Hour))
+	if len(next.got) != 0 {
+		t.Errorf("Forget 后仍发布了未结束的窗口 %+v", next.got)
+	}
+}
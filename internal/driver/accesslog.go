@@ -0,0 +1,100 @@
+package driver
+
+import (
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/edgexfoundry/device-sdk-go/v4/pkg/interfaces"
+	dsModels "github.com/edgexfoundry/device-sdk-go/v4/pkg/models"
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/common"
+	"github.com/labstack/echo/v4"
+	"github.com/linjuya-lu/device-lpmp-go/internal/accesslog"
+)
+
+// accessLogRoute 查询最近的控制报文审计记录：GET ?device=<设备名>&sensorId=<SensorID>&since=<RFC3339>&limit=<条数>
+const accessLogRoute = common.ApiBase + "/lpmp/access-log"
+
+// 控制报文的触发方式
+const (
+	accessSourceWrite       = "write"
+	accessSourceAlarmAck    = "alarm-ack"
+	accessSourceGroup       = "group"
+	accessSourceWriteVerify = driftSourceWrite
+	accessSourceAudit       = driftSourceAudit
+)
+
+// controlTrigger 触发下发控制报文的操作：EdgeX 不向驱动传递调用方身份，以命令的设备、资源和写入值标识
+type controlTrigger struct {
+	source  string
+	device  string
+	writeID string
+	values  map[string]any
+}
+
+// commandTrigger 由 EdgeX 写命令构造触发信息
+func commandTrigger(source, deviceName string, reqs []dsModels.CommandRequest, params []*dsModels.CommandValue) controlTrigger {
+	t := controlTrigger{source: source, device: deviceName, values: make(map[string]any, len(reqs))}
+	for i, req := range reqs {
+		t.values[req.DeviceResourceName] = params[i].Value
+	}
+	return t
+}
+
+// recordControl 记录一帧控制报文及其结果；写审计日志失败只输出错误，不影响下发结果
+func (d *LpMpDriver) recordControl(t controlTrigger, sensorID string, broadcast bool, ctrlType uint8, requestSet bool, frame []byte, start time.Time, err error) {
+	if d.accessLog == nil {
+		return
+	}
+	e := accesslog.Entry{
+		Time:       start,
+		Source:     t.source,
+		WriteID:    t.writeID,
+		Device:     t.device,
+		Values:     t.values,
+		SensorID:   sensorID,
+		Broadcast:  broadcast,
+		CtrlType:   ctrlType,
+		RequestSet: requestSet,
+		Frame:      hex.EncodeToString(frame),
+		Result:     accesslog.ResultAcked,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	for res := range t.values {
+		e.Resources = append(e.Resources, res)
+	}
+	sort.Strings(e.Resources)
+	if err != nil {
+		e.Result, e.Error = accesslog.ResultFailed, err.Error()
+	}
+	if err := d.accessLog.Record(e); err != nil {
+		d.lc.Errorf("记录控制报文审计日志失败: %v", err)
+	}
+}
+
+// registerAccessLogRoute 在 SDK 内置的 Web 服务上注册控制报文审计记录查询接口
+func (d *LpMpDriver) registerAccessLogRoute() error {
+	return d.sdk.AddCustomRoute(accessLogRoute, interfaces.Authenticated, func(c echo.Context) error {
+		if d.accessLog == nil {
+			return c.String(http.StatusServiceUnavailable, "访问审计日志未启动")
+		}
+		f := accesslog.Filter{Device: c.QueryParam("device"), SensorID: c.QueryParam("sensorId")}
+		if v := c.QueryParam("since"); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return c.String(http.StatusBadRequest, "since 须为 RFC3339 时间")
+			}
+			f.Since = t
+		}
+		if v := c.QueryParam("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				return c.String(http.StatusBadRequest, "limit 须为正整数")
+			}
+			f.Limit = n
+		}
+		return c.JSON(http.StatusOK, d.accessLog.Recent(f))
+	}, http.MethodGet)
+}
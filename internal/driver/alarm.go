@@ -0,0 +1,229 @@
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	dsModels "github.com/edgexfoundry/device-sdk-go/v4/pkg/models"
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/common"
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+	"github.com/linjuya-lu/device-lpmp-go/internal/frameparser"
+	"github.com/linjuya-lu/device-lpmp-go/internal/trace"
+)
+
+const (
+	// 传感器设备上的告警锁存资源：profile 声明了 alarmLatched 的设备才锁存告警
+	alarmLatchedResource = "alarmLatched" // Bool，收到告警报文后为 true，直到操作员确认
+	alarmLastResource    = "alarmLast"    // String，最近一次告警（JSON）
+	alarmHistoryResource = "alarmHistory" // String，最近的告警和确认记录（JSON 数组）
+	ackAlarmResource     = "ackAlarm"     // Bool，写 true 确认告警并解除锁存
+
+	// alarmAckCtrlTypeKey Driver 配置项：确认告警时向传感器下发的控制报文 CtrlType（如 "0x04"），
+	// 留空只在本地解除锁存，不下发
+	alarmAckCtrlTypeKey = "AlarmAckCtrlType"
+	// alarmHistorySizeKey Driver 配置项：每个设备保留的告警历史条数
+	alarmHistorySizeKey     = "AlarmHistorySize"
+	defaultAlarmHistorySize = 50
+
+	// 告警锁存/确认时发布的系统事件
+	alarmEventType         = "lpmp-alarm"
+	alarmEventActionRaised = "raised"
+	alarmEventActionAcked  = "acked"
+)
+
+// alarmRecord 一条告警历史：收到的告警或操作员的确认
+type alarmRecord struct {
+	Time   string `json:"time"`
+	Action string `json:"action"`
+	// Source 告警来源：空为传感器告警报文，threshold 为本地门限告警
+	Source   string         `json:"source,omitempty"`
+	SensorID string         `json:"sensorId,omitempty"`
+	TraceID  string         `json:"traceId,omitempty"`
+	Values   map[string]any `json:"values,omitempty"`
+	// AckSent 确认时是否已向传感器下发告警确认报文
+	AckSent bool `json:"ackSent,omitempty"`
+}
+
+// alarmBook 各设备的告警历史，按时间顺序最多保留 size 条
+type alarmBook struct {
+	mu      sync.Mutex
+	size    int
+	history map[string][]alarmRecord
+}
+
+// append 追加一条记录，返回该设备历史的 JSON
+func (b *alarmBook) append(deviceName string, rec alarmRecord) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.history == nil {
+		b.history = make(map[string][]alarmRecord)
+	}
+	size := b.size
+	if size <= 0 {
+		size = defaultAlarmHistorySize
+	}
+	h := append(b.history[deviceName], rec)
+	if len(h) > size {
+		h = append([]alarmRecord(nil), h[len(h)-size:]...)
+	}
+	b.history[deviceName] = h
+	out, _ := json.Marshal(h)
+	return string(out)
+}
+
+// forget 删除设备的告警历史
+func (b *alarmBook) forget(deviceName string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.history, deviceName)
+}
+
+// alarmConfig 读取告警确认报文的 CtrlType（0 表示不下发）和历史条数
+func alarmConfig(driverCfg map[string]string) (uint8, int, error) {
+	var ctrlType uint8
+	if v := driverCfg[alarmAckCtrlTypeKey]; v != "" {
+		n, err := strconv.ParseUint(v, 0, 8)
+		if err != nil || n == 0 || n > 0x7F {
+			return 0, 0, fmt.Errorf("%s 配置无效 %q，应为 1~127", alarmAckCtrlTypeKey, v)
+		}
+		ctrlType = uint8(n)
+	}
+	size := defaultAlarmHistorySize
+	if v := driverCfg[alarmHistorySizeKey]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return 0, 0, fmt.Errorf("%s 配置无效 %q", alarmHistorySizeKey, v)
+		}
+		size = n
+	}
+	return ctrlType, size, nil
+}
+
+// handleAlarm 作为 frameparser.AlarmHandler：声明了 alarmLatched 的绑定设备锁存告警
+func (d *LpMpDriver) handleAlarm(id trace.ID, sensorID string, received time.Time, values map[string]any) {
+	seen := make(map[string]bool)
+	for _, b := range config.LookupSensorBindings(sensorID) {
+		if seen[b.DeviceName] {
+			continue
+		}
+		seen[b.DeviceName] = true
+		d.latchAlarm(b.DeviceName, alarmRecord{
+			Time:     received.Format(time.RFC3339Nano),
+			Action:   alarmEventActionRaised,
+			SensorID: sensorID,
+			TraceID:  string(id),
+			Values:   values,
+		}, received)
+	}
+}
+
+// latchAlarm 记录一次告警并锁存，锁存状态变化时异步推送并发布事件；
+// 设备 profile 未声明 alarmLatched 时不处理
+func (d *LpMpDriver) latchAlarm(deviceName string, rec alarmRecord, origin time.Time) {
+	if !config.HasDeviceResource(deviceName, alarmLatchedResource) {
+		return
+	}
+	// 1. 记录历史和最近一次告警
+	d.recordAlarm(deviceName, rec)
+
+	// 2. 锁存：已锁存的设备再次告警只记历史，不重复推送
+	if alarmLatched(deviceName) {
+		d.lc.Debugf("[trace=%s] 设备 %s 告警仍未确认，记录本次告警", rec.TraceID, deviceName)
+		return
+	}
+	config.SetDeviceValue(deviceName, alarmLatchedResource, true)
+	d.lc.Warnf("[trace=%s] 设备 %s(SensorID=%s) 告警已锁存: %v", rec.TraceID, deviceName, rec.SensorID, rec.Values)
+	d.pushAlarmLatched(deviceName, true, origin)
+	d.sdk.PublishGenericSystemEvent(alarmEventType, alarmEventActionRaised, map[string]any{
+		"device":   deviceName,
+		"sensorId": rec.SensorID,
+		"traceId":  rec.TraceID,
+		"source":   rec.Source,
+		"values":   rec.Values,
+	})
+}
+
+// recordAlarm 追加告警历史并更新 alarmLast / alarmHistory 资源
+func (d *LpMpDriver) recordAlarm(deviceName string, rec alarmRecord) {
+	history := d.alarms.append(deviceName, rec)
+	if rec.Action == alarmEventActionRaised {
+		if b, err := json.Marshal(rec); err == nil {
+			config.SetDeviceValue(deviceName, alarmLastResource, string(b))
+		}
+	}
+	config.SetDeviceValue(deviceName, alarmHistoryResource, history)
+}
+
+// pushAlarmLatched 异步推送锁存状态变化；属于状态变化，过载时不参与抽样
+func (d *LpMpDriver) pushAlarmLatched(deviceName string, latched bool, origin time.Time) {
+	cv, err := dsModels.NewCommandValueWithOrigin(alarmLatchedResource, common.ValueTypeBool, latched, origin.UnixNano())
+	if err != nil {
+		d.lc.Errorf("构造读数 %s.%s 失败: %v", deviceName, alarmLatchedResource, err)
+		return
+	}
+	if !d.pushAsync(&dsModels.AsyncValues{DeviceName: deviceName, SourceName: alarmLatchedResource, CommandValues: []*dsModels.CommandValue{cv}}, pushEssential) {
+		d.lc.Warnf("异步读数通道已满，未能推送 %s.%s=%v", deviceName, alarmLatchedResource, latched)
+	}
+}
+
+// alarmLatched 读取设备当前的锁存状态
+func alarmLatched(deviceName string) bool {
+	values, _ := config.GetDeviceValues(deviceName)
+	latched, _ := values[alarmLatchedResource].(bool)
+	return latched
+}
+
+// ackAlarmRequested 判断写请求中是否有 ackAlarm=true
+func ackAlarmRequested(reqs []dsModels.CommandRequest, values []*dsModels.CommandValue) bool {
+	for i, req := range reqs {
+		if req.DeviceResourceName != ackAlarmResource {
+			continue
+		}
+		if v, ok := values[i].Value.(bool); ok && v {
+			return true
+		}
+	}
+	return false
+}
+
+// ackAlarm 确认设备的告警：配置了 AlarmAckCtrlType 时先向设备的全部传感器下发确认报文，
+// 全部确认后才解除锁存；未锁存时同样下发，便于传感器侧复位
+func (d *LpMpDriver) ackAlarm(deviceName string) error {
+	// 1. 可选：下发告警确认报文
+	ackSent := false
+	if d.alarmAckCtrlType != 0 {
+		trig := controlTrigger{source: accessSourceAlarmAck, device: deviceName, values: map[string]any{ackAlarmResource: true}}
+		for _, s := range config.LookupSensorIDs(deviceName) {
+			sid, err := frameparser.ParseSensorID(s)
+			if err != nil {
+				return err
+			}
+			frame, err := frameparser.BuildControlFrame(sid, d.alarmAckCtrlType, 1, nil, nil)
+			if err != nil {
+				return err
+			}
+			if _, _, err := d.controlRoundTrip(trig, s, frame, d.alarmAckCtrlType, true, d.downlinkPrios[cmdAlarmAck]); err != nil {
+				return fmt.Errorf("设备 %s(SensorID=%s) 告警确认报文未确认: %w", deviceName, s, err)
+			}
+		}
+		ackSent = true
+	}
+
+	// 2. 解除锁存并记录
+	now := time.Now()
+	wasLatched := alarmLatched(deviceName)
+	config.SetDeviceValue(deviceName, alarmLatchedResource, false)
+	d.recordAlarm(deviceName, alarmRecord{Time: now.Format(time.RFC3339Nano), Action: alarmEventActionAcked, AckSent: ackSent})
+	d.lc.Infof("设备 %s 告警已确认（下发确认报文=%v）", deviceName, ackSent)
+	if wasLatched {
+		d.pushAlarmLatched(deviceName, false, now)
+	}
+	d.sdk.PublishGenericSystemEvent(alarmEventType, alarmEventActionAcked, map[string]any{
+		"device":  deviceName,
+		"ackSent": ackSent,
+	})
+	return nil
+}
@@ -0,0 +1,109 @@
+package driver
+
+import (
+	"fmt"
+	"strconv"
+	"sync/atomic"
+)
+
+const (
+	// asyncHighWatermarkKey Driver 配置项：异步读数通道占用达到该比例（0~1）时开始抽样，"0" 关闭抽样
+	asyncHighWatermarkKey     = "AsyncHighWatermark"
+	defaultAsyncHighWatermark = 0.8
+	// asyncSampleEveryKey Driver 配置项：过载期间每 N 个监测读数只转发 1 个
+	asyncSampleEveryKey     = "AsyncSampleEvery"
+	defaultAsyncSampleEvery = 10
+
+	// asyncShedResource 网关设备上因异步通道过载被丢弃的读数数
+	asyncShedResource = "async-shed"
+)
+
+// pushClass 异步读数的类别：过载时监测读数按比例抽样，告警和状态变化只在通道全满时才丢
+type pushClass int
+
+const (
+	pushMonitoring pushClass = iota
+	pushEssential
+)
+
+// asyncGuard core-data 处理变慢、SDK 异步通道积压时保护解析协程：占用超过水位后抽样监测读数，
+// 统计丢弃数，而不是阻塞解析
+type asyncGuard struct {
+	highWatermark float64
+	sampleEvery   uint64
+
+	overloaded atomic.Bool
+	sampled    atomic.Uint64 // 本次过载期间到达的监测读数
+	shed       atomic.Uint64 // 启动（或 resetStats）以来丢弃的读数
+	episode    atomic.Uint64 // 本次过载期间丢弃的读数
+}
+
+// asyncGuardConfig 读取过载水位和抽样间隔
+func asyncGuardConfig(driverCfg map[string]string) (float64, uint64, error) {
+	watermark, every := defaultAsyncHighWatermark, uint64(defaultAsyncSampleEvery)
+	if v := driverCfg[asyncHighWatermarkKey]; v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 || f > 1 {
+			return 0, 0, fmt.Errorf("%s 配置无效 %q，应为 0~1", asyncHighWatermarkKey, v)
+		}
+		watermark = f
+	}
+	if v := driverCfg[asyncSampleEveryKey]; v != "" {
+		n, err := strconv.ParseUint(v, 10, 32)
+		if err != nil || n == 0 {
+			return 0, 0, fmt.Errorf("%s 配置无效 %q", asyncSampleEveryKey, v)
+		}
+		every = n
+	}
+	return watermark, every, nil
+}
+
+// overloadedAt 判断通道占用是否达到过载水位，未启用抽样时只有通道全满才算
+func (g *asyncGuard) overloadedAt(length, capacity int) bool {
+	if g.highWatermark <= 0 || capacity == 0 {
+		return length >= capacity
+	}
+	return float64(length) >= g.highWatermark*float64(capacity)
+}
+
+// admit 按通道当前占用判断是否转发，未转发的由调用方丢弃或写入溢出队列；
+// transition 为 1 表示刚进入过载，-1 表示刚恢复
+func (g *asyncGuard) admit(class pushClass, length, capacity int) (ok bool, transition int) {
+	if g.highWatermark <= 0 || capacity == 0 {
+		return true, 0
+	}
+	if float64(length) < g.highWatermark*float64(capacity) {
+		if g.overloaded.CompareAndSwap(true, false) {
+			return true, -1
+		}
+		return true, 0
+	}
+	if g.overloaded.CompareAndSwap(false, true) {
+		g.sampled.Store(0)
+		g.episode.Store(0)
+		transition = 1
+	}
+	if class == pushEssential || (g.sampled.Add(1)-1)%g.sampleEvery == 0 {
+		return true, transition
+	}
+	return false, transition
+}
+
+// drop 记一次丢弃
+func (g *asyncGuard) drop() {
+	g.shed.Add(1)
+	g.episode.Add(1)
+}
+
+// admitAsync 过载保护入口，进入/退出过载时记录日志
+func (d *LpMpDriver) admitAsync(class pushClass) bool {
+	ok, transition := d.async.admit(class, len(d.asyncCh), cap(d.asyncCh))
+	switch transition {
+	case 1:
+		d.lc.Warnf("异步读数通道占用达到 %.0f%%（%d/%d），开始抽样：监测读数每 %d 个转发 1 个，告警和状态变化照常转发",
+			d.async.highWatermark*100, len(d.asyncCh), cap(d.asyncCh), d.async.sampleEvery)
+	case -1:
+		d.lc.Infof("异步读数通道已恢复，过载期间丢弃 %d 个读数", d.async.episode.Load())
+	}
+	return ok
+}
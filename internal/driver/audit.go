@@ -0,0 +1,138 @@
+package driver
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+)
+
+const (
+	// paramAuditIntervalKey Driver 配置项：周期稽核传感器参数的间隔，为空或 0 表示不启用
+	paramAuditIntervalKey = "ParamAuditInterval"
+	// desiredValueAttr 资源属性：sensorParam 资源的期望值，稽核时与传感器上报值比对
+	desiredValueAttr = "desiredValue"
+)
+
+// paramAuditInterval 读取稽核间隔，返回 0 表示不启用
+func paramAuditInterval(driverCfg map[string]string) (time.Duration, error) {
+	v := driverCfg[paramAuditIntervalKey]
+	if v == "" || v == "0" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%s 配置无效 %q", paramAuditIntervalKey, v)
+	}
+	return d, nil
+}
+
+// startParamAudit 按间隔周期稽核所有设备的传感器参数，直到驱动停止
+func (d *LpMpDriver) startParamAudit(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-d.stopCh:
+				return
+			case <-ticker.C:
+				d.auditAllDevices()
+			}
+		}
+	}()
+}
+
+// auditAllDevices 逐个设备、逐个传感器查询通用参数并与期望值比对
+func (d *LpMpDriver) auditAllDevices() {
+	if d.currentPort() == nil {
+		d.lc.Debugf("链路未连通，跳过本轮参数稽核")
+		return
+	}
+	for _, dev := range d.sdk.Devices() {
+		desiredBySensor := d.desiredParamsForDevice(dev.Name)
+		drifted := false
+		for _, sid := range config.LookupSensorIDs(dev.Name) {
+			reported, err := d.querySensorParams(controlTrigger{source: accessSourceAudit, device: dev.Name}, sid)
+			if err != nil {
+				d.lc.Warnf("稽核设备 %s(SensorID=%s) 参数失败: %v", dev.Name, sid, err)
+				continue
+			}
+			// 参数表中的默认值只比对传感器实际具备的参数
+			desired := desiredBySensor[sid]
+			for t, data := range config.TableParams() {
+				if _, has := reported[t]; has {
+					if _, set := desired[t]; !set {
+						desired[t] = data
+					}
+				}
+			}
+			diff := config.DiffParams(desired, reported)
+			d.reportDrift(dev.Name, sid, driftSourceAudit, diff, desired, reported)
+			drifted = drifted || len(diff) > 0
+		}
+		config.SetDeviceValue(dev.Name, configDriftResource, drifted)
+	}
+}
+
+// desiredParamsForDevice 汇总设备各传感器的期望参数值：
+// profile 中 sensorParam 资源的 desiredValue 属性，被最近成功下发的值覆盖
+func (d *LpMpDriver) desiredParamsForDevice(deviceName string) map[string]map[uint16][]byte {
+	out := make(map[string]map[uint16][]byte)
+	for _, sid := range config.LookupSensorIDs(deviceName) {
+		out[sid] = make(map[uint16][]byte)
+	}
+
+	resources, _ := config.GetDeviceResources(deviceName)
+	for _, dr := range resources {
+		if !attrBool(dr.Attributes, sensorParamAttr) {
+			continue
+		}
+		raw, ok := dr.Attributes[desiredValueAttr]
+		if !ok {
+			continue
+		}
+		sid, ok := config.SensorForResource(deviceName, dr.Name)
+		if !ok {
+			continue
+		}
+		data, paramType, err := encodeDesiredValue(sid, dr.Attributes, raw)
+		if err != nil {
+			d.lc.Errorf("设备 %s 资源 %s 的 %s 无效: %v", deviceName, dr.Name, desiredValueAttr, err)
+			continue
+		}
+		out[sid][paramType] = data
+	}
+
+	for sid, desired := range out {
+		for t, data := range config.GetDesiredParams(sid) {
+			desired[t] = data
+		}
+	}
+	return out
+}
+
+// encodeDesiredValue 按资源的 parameterType 和传感器的参数表版本把 desiredValue 属性编码为报文数据
+func encodeDesiredValue(sensorID string, attrs map[string]any, raw any) ([]byte, uint16, error) {
+	paramType, ok, err := config.ParamTypeFromAttributes(attrs)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !ok {
+		return nil, 0, fmt.Errorf("未声明 %s", config.ParameterTypeAttr)
+	}
+	info, ok := config.LookupSensorParamInfo(sensorID, paramType)
+	if !ok {
+		return nil, 0, fmt.Errorf("参量类型 0x%04X 不在参数表中", paramType)
+	}
+	if s, isStr := raw.(string); isStr {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, 0, err
+		}
+		raw = f
+	}
+	data, err := config.EncodeParamValue(info, raw)
+	return data, paramType, err
+}
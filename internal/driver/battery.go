@@ -0,0 +1,68 @@
+package driver
+
+import (
+	"time"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/battery"
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+)
+
+const (
+	// 电池分析的输入资源：传感器 profile 中的电量（%）和电压（V）
+	batteryLevelResource = "battery-level"
+	voltageResource      = "voltage"
+
+	// 电池分析的输出资源，profile 声明了才更新：按当前放电速率估算的剩余天数（-1 表示尚无法估算）和
+	// 电量每天的变化（百分点）
+	estimatedDaysRemainingResource = "estimatedDaysRemaining"
+	batteryTrendSlopeResource      = "batteryTrendSlope"
+
+	// 低电量时发布的系统事件
+	batteryEventType      = "lpmp-battery"
+	batteryEventActionLow = "low"
+)
+
+// analyzeBattery 作为 Sink 使用：电量读数更新放电估算，电量或电压跌破门限时发布 lpmp-battery 事件
+func (d *LpMpDriver) analyzeBattery(deviceName, resourceName string, value any, origin time.Time, tags map[string]string) {
+	if resourceName != batteryLevelResource && resourceName != voltageResource {
+		return
+	}
+	v, ok := config.NumericValue(value)
+	if !ok {
+		return
+	}
+	if resourceName == voltageResource {
+		if low := d.battery.ObserveVoltage(deviceName, v); low != nil {
+			d.publishLowBattery(deviceName, tags, *low, nil)
+		}
+		return
+	}
+
+	est, low := d.battery.ObserveLevel(deviceName, v, origin)
+	if config.HasDeviceResource(deviceName, estimatedDaysRemainingResource) {
+		config.SetDeviceValue(deviceName, estimatedDaysRemainingResource, float32(est.DaysRemaining))
+	}
+	if config.HasDeviceResource(deviceName, batteryTrendSlopeResource) {
+		config.SetDeviceValue(deviceName, batteryTrendSlopeResource, float32(est.SlopePerDay))
+	}
+	if low != nil {
+		d.publishLowBattery(deviceName, tags, *low, &est)
+	}
+}
+
+// publishLowBattery 记录并发布一次低电量，est 为电量触发时的放电估算
+func (d *LpMpDriver) publishLowBattery(deviceName string, tags map[string]string, low battery.LowBattery, est *battery.Estimate) {
+	details := map[string]any{
+		"device":    deviceName,
+		"sensorId":  tags["sensorId"],
+		"source":    low.Source,
+		"value":     low.Value,
+		"threshold": low.Threshold,
+	}
+	if est != nil {
+		details["estimatedDaysRemaining"] = est.DaysRemaining
+		details["batteryTrendSlope"] = est.SlopePerDay
+	}
+	d.lc.Warnf("[trace=%s] 设备 %s 低电量（%s %v 低于 %v）", tags["traceId"], deviceName, low.Source, low.Value, low.Threshold)
+	d.sdk.PublishGenericSystemEvent(batteryEventType, batteryEventActionLow, details)
+}
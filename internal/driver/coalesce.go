@@ -0,0 +1,78 @@
+package driver
+
+import (
+	"sync"
+	"time"
+
+	dsModels "github.com/edgexfoundry/device-sdk-go/v4/pkg/models"
+)
+
+// pushTracker 记录各设备资源最近一次异步推送的时间，供 AutoEvents 轮询合并使用；零值可用
+type pushTracker struct {
+	mu   sync.Mutex
+	last map[string]map[string]time.Time // 设备名 → (资源名 → 推送时间)
+}
+
+// record 记录一次推送
+func (t *pushTracker) record(deviceName, resourceName string, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.last == nil {
+		t.last = make(map[string]map[string]time.Time)
+	}
+	if _, ok := t.last[deviceName]; !ok {
+		t.last[deviceName] = make(map[string]time.Time)
+	}
+	t.last[deviceName][resourceName] = at
+}
+
+// pushedWithin 判断资源是否在 now 之前 window 内推送过
+func (t *pushTracker) pushedWithin(deviceName, resourceName string, window time.Duration, now time.Time) bool {
+	t.mu.Lock()
+	at, ok := t.last[deviceName][resourceName]
+	t.mu.Unlock()
+	return ok && now.Sub(at) < window
+}
+
+// forget 删除设备的推送记录
+func (t *pushTracker) forget(deviceName string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.last, deviceName)
+}
+
+// pushAsync 把读数作为异步读数交给 SDK 并记录推送时间；解析协程不能被 SDK 阻塞，
+// 通道积压时按 class 抽样（见 asyncGuard），通道满时丢弃。启用了 SpillEnabled 时本应丢弃的读数
+// 写入磁盘溢出队列，队列有积压期间新读数也排在队列之后；读数被丢弃时返回 false
+func (d *LpMpDriver) pushAsync(av *dsModels.AsyncValues, class pushClass) bool {
+	if d.spill != nil && d.spill.edgex.Len() > 0 {
+		return d.shedAsync(av)
+	}
+	if !d.admitAsync(class) {
+		return d.shedAsync(av)
+	}
+	select {
+	case d.asyncCh <- av:
+	default:
+		return d.shedAsync(av)
+	}
+	now := time.Now()
+	for _, cv := range av.CommandValues {
+		d.pushes.record(av.DeviceName, cv.DeviceResourceName, now)
+	}
+	return true
+}
+
+// shedAsync 处理未能交给 SDK 的读数：写入溢出队列，未启用或写入失败时计入丢弃数
+func (d *LpMpDriver) shedAsync(av *dsModels.AsyncValues) bool {
+	if d.spill != nil && d.spillAsync(av) {
+		return true
+	}
+	d.async.drop()
+	return false
+}
+
+// coalesced 判断轮询的资源是否因最近已推送过而不再返回（设备协议属性 coalesceWindow）
+func (d *LpMpDriver) coalesced(deviceName, resourceName string, window time.Duration) bool {
+	return window > 0 && d.pushes.pushedWithin(deviceName, resourceName, window, time.Now())
+}
@@ -0,0 +1,209 @@
+package driver
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/edgexfoundry/device-sdk-go/v4/pkg/interfaces"
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/common"
+	"github.com/labstack/echo/v4"
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+)
+
+const (
+	// completenessRoute 按设备查询数据完整率序列：GET ?device=<设备名>
+	completenessRoute = common.ApiBase + "/lpmp/completeness"
+
+	// 设备上的完整率资源（%）：上一个整点小时和上一个自然日收到的上报数占应收数的比例，
+	// 设备协议属性 reportInterval 未配置或尚无完整的时段时为 -1
+	completenessHourlyResource = "completenessHourly"
+	completenessDailyResource  = "completenessDaily"
+
+	// 完整率序列保留的时段数
+	completenessHours = 48
+	completenessDays  = 31
+)
+
+// completenessBucket 一个时段的应收和实收上报数；Percent 为实收/应收（不超过 100），
+// 应收不足一次（如刚开始统计）时省略
+type completenessBucket struct {
+	Start    time.Time `json:"start"`
+	Expected float64   `json:"expected"`
+	Received int       `json:"received"`
+	Percent  *float64  `json:"percent,omitempty"`
+}
+
+// completenessSeries 完整率接口返回的一台设备的序列，时段按开始时间升序，最后一项为当前未结束的时段
+type completenessSeries struct {
+	Device         string               `json:"device"`
+	ReportInterval string               `json:"reportInterval"`
+	Since          time.Time            `json:"since"`
+	Hourly         []completenessBucket `json:"hourly"`
+	Daily          []completenessBucket `json:"daily"`
+}
+
+// deviceCompleteness 一台设备的上报计数，按小时和自然日（本地时间）分桶
+type deviceCompleteness struct {
+	interval time.Duration
+	// since 开始统计的时间，之前的时段不计应收
+	since time.Time
+	// lastTrace 最近一次计数的帧，同一帧的多个读数只计一次上报
+	lastTrace string
+	hours     map[time.Time]int
+	days      map[time.Time]int
+}
+
+// completenessTracker 按设备协议属性 reportInterval 统计各设备的应收和实收上报数，并发安全
+type completenessTracker struct {
+	mu      sync.Mutex
+	devices map[string]*deviceCompleteness
+}
+
+func newCompletenessTracker() *completenessTracker {
+	return &completenessTracker{devices: make(map[string]*deviceCompleteness)}
+}
+
+// hourStart / dayStart 时刻所在小时和自然日的开始
+func hourStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+}
+
+func dayStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// configure 设置设备的上报周期，interval 为 0 时不再统计；周期变化时从 now 重新统计
+func (t *completenessTracker) configure(deviceName string, interval time.Duration, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if interval <= 0 {
+		delete(t.devices, deviceName)
+		return
+	}
+	if dc, ok := t.devices[deviceName]; ok && dc.interval == interval {
+		return
+	}
+	t.devices[deviceName] = &deviceCompleteness{
+		interval: interval,
+		since:    now,
+		hours:    make(map[time.Time]int),
+		days:     make(map[time.Time]int),
+	}
+}
+
+// forget 删除设备的统计
+func (t *completenessTracker) forget(deviceName string) {
+	t.configure(deviceName, 0, time.Time{})
+}
+
+// record 记录设备收到的一帧上报，traceID 相同的读数属于同一帧，只计一次
+func (t *completenessTracker) record(deviceName, traceID string, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	dc, ok := t.devices[deviceName]
+	if !ok || (traceID != "" && traceID == dc.lastTrace) {
+		return
+	}
+	dc.lastTrace = traceID
+	dc.hours[hourStart(now)]++
+	dc.days[dayStart(now)]++
+	// 丢弃超出保留范围的时段
+	for h := range dc.hours {
+		if now.Sub(h) > completenessHours*time.Hour {
+			delete(dc.hours, h)
+		}
+	}
+	for day := range dc.days {
+		if now.Sub(day) > completenessDays*24*time.Hour {
+			delete(dc.days, day)
+		}
+	}
+}
+
+// bucket 计算 [start, end) 时段的应收数和完整率，只计 since 之后、now 之前的部分
+func (dc *deviceCompleteness) bucket(start, end time.Time, received int, now time.Time) completenessBucket {
+	b := completenessBucket{Start: start, Received: received}
+	from, to := start, end
+	if dc.since.After(from) {
+		from = dc.since
+	}
+	if now.Before(to) {
+		to = now
+	}
+	if to.After(from) {
+		b.Expected = float64(to.Sub(from)) / float64(dc.interval)
+	}
+	if b.Expected >= 1 {
+		p := min(100, float64(received)/b.Expected*100)
+		b.Percent = &p
+	}
+	return b
+}
+
+// series 返回设备最近的小时和自然日完整率，设备未配置 reportInterval 时 ok 为 false
+func (t *completenessTracker) series(deviceName string, now time.Time) (s completenessSeries, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	dc, ok := t.devices[deviceName]
+	if !ok {
+		return s, false
+	}
+	s = completenessSeries{Device: deviceName, ReportInterval: dc.interval.String(), Since: dc.since}
+	first := hourStart(dc.since)
+	if limit := hourStart(now).Add(-(completenessHours - 1) * time.Hour); limit.After(first) {
+		first = limit
+	}
+	for h := first; !h.After(now); h = h.Add(time.Hour) {
+		s.Hourly = append(s.Hourly, dc.bucket(h, h.Add(time.Hour), dc.hours[h], now))
+	}
+	firstDay := dayStart(dc.since)
+	if limit := dayStart(now).AddDate(0, 0, -(completenessDays - 1)); limit.After(firstDay) {
+		firstDay = limit
+	}
+	for day := firstDay; !day.After(now); day = day.AddDate(0, 0, 1) {
+		s.Daily = append(s.Daily, dc.bucket(day, day.AddDate(0, 0, 1), dc.days[day], now))
+	}
+	return s, true
+}
+
+// lastComplete 返回序列中倒数第二个时段（最近一个已结束的时段）的完整率，没有时为 -1
+func lastComplete(buckets []completenessBucket) float32 {
+	if len(buckets) < 2 || buckets[len(buckets)-2].Percent == nil {
+		return -1
+	}
+	return float32(*buckets[len(buckets)-2].Percent)
+}
+
+// countReport 作为 Sink 使用：按读数的 traceId 统计设备收到的上报帧
+func (d *LpMpDriver) countReport(deviceName, _ string, _ any, _ time.Time, tags map[string]string) {
+	d.completeness.record(deviceName, tags["traceId"], time.Now())
+}
+
+// updateCompleteness 把上一个整点小时和上一个自然日的完整率写入设备的完整率资源
+func (d *LpMpDriver) updateCompleteness(deviceName string) {
+	if d.completeness == nil || !config.HasDeviceResource(deviceName, completenessHourlyResource) {
+		return
+	}
+	hourly, daily := float32(-1), float32(-1)
+	if s, ok := d.completeness.series(deviceName, time.Now()); ok {
+		hourly, daily = lastComplete(s.Hourly), lastComplete(s.Daily)
+	}
+	config.SetDeviceValue(deviceName, completenessHourlyResource, hourly)
+	config.SetDeviceValue(deviceName, completenessDailyResource, daily)
+}
+
+// registerCompletenessRoute 在 SDK 内置的 Web 服务上注册数据完整率接口，供 SLA 报表按监测点取小时和日序列
+func (d *LpMpDriver) registerCompletenessRoute() error {
+	return d.sdk.AddCustomRoute(completenessRoute, interfaces.Authenticated, func(c echo.Context) error {
+		device := c.QueryParam("device")
+		if device == "" {
+			return c.String(http.StatusBadRequest, "需指定 device")
+		}
+		s, ok := d.completeness.series(device, time.Now())
+		if !ok {
+			return c.String(http.StatusNotFound, "设备 "+device+" 未配置 "+protocolName+"."+reportIntervalKey)
+		}
+		return c.JSON(http.StatusOK, s)
+	}, http.MethodGet)
+}
@@ -0,0 +1,178 @@
+package driver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/edgexfoundry/device-sdk-go/v4/pkg/interfaces"
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/common"
+	"github.com/labstack/echo/v4"
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+)
+
+// configReportRoute 配置校验报告：启动时加载的配置文件校验和、参数表摘要、已登记设备及其资源和告警项，
+// 供运维核对部署是否与审定的配置一致
+const configReportRoute = common.ApiBase + "/lpmp/config-report"
+
+// configFileSum 一个配置文件的校验和；可选文件不存在时 Missing 为 true
+type configFileSum struct {
+	Path    string `json:"path"`
+	SHA256  string `json:"sha256,omitempty"`
+	Size    int64  `json:"size"`
+	Missing bool   `json:"missing,omitempty"`
+}
+
+// configSnapshot Start 时记录的配置状态：文件校验和、参数表摘要和绑定设备时的错误
+type configSnapshot struct {
+	LoadedAt    time.Time       `json:"loadedAt"`
+	Files       []configFileSum `json:"files"`
+	ParamTable  paramTableSum   `json:"paramTable"`
+	bindingErrs []string
+}
+
+// paramTableSum 参数表摘要，Versions 为可选的参数表版本（见 param_tables.yaml）
+type paramTableSum struct {
+	Entries  int      `json:"entries"`
+	SHA256   string   `json:"sha256"`
+	Versions []string `json:"versions"`
+}
+
+// configReportDevice 报告中的一个设备
+type configReportDevice struct {
+	Name           string   `json:"name"`
+	Profile        string   `json:"profile"`
+	SensorIDs      []string `json:"sensorIds,omitempty"`
+	Resources      int      `json:"resources"`
+	AdminState     string   `json:"adminState,omitempty"`
+	OperatingState string   `json:"operatingState,omitempty"`
+}
+
+// configReport configReportRoute 的响应：文件和参数表为启动时的状态，设备和告警项为请求时的状态
+type configReport struct {
+	configSnapshot
+	Devices   []configReportDevice `json:"devices"`
+	Resources int                  `json:"resources"`
+	Warnings  []string             `json:"warnings"`
+}
+
+// fileSum 计算文件的 SHA-256；optional 的文件不存在时不报错
+func fileSum(path string, optional bool) (configFileSum, error) {
+	s := configFileSum{Path: path}
+	b, err := os.ReadFile(path)
+	if optional && errors.Is(err, os.ErrNotExist) {
+		s.Missing = true
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	h := sha256.Sum256(b)
+	s.SHA256, s.Size = hex.EncodeToString(h[:]), int64(len(b))
+	return s, nil
+}
+
+// snapshotConfig 计算设备清单、各 profile、参量范围表、TLV 子表和参数表版本的校验和以及参数表摘要，并写入日志
+func (d *LpMpDriver) snapshotConfig(devicesYAML, profilesDir string, optional []string, bindingErrs []string) (*configSnapshot, error) {
+	snap := &configSnapshot{LoadedAt: time.Now(), bindingErrs: bindingErrs}
+	profiles, err := filepath.Glob(filepath.Join(profilesDir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(profiles)
+	paths := append([]string{devicesYAML}, profiles...)
+	for i, p := range append(paths, optional...) {
+		s, err := fileSum(p, i >= len(paths))
+		if err != nil {
+			return nil, fmt.Errorf("计算配置文件校验和失败: %w", err)
+		}
+		snap.Files = append(snap.Files, s)
+		if s.Missing {
+			d.lc.Infof("配置文件 %s 不存在", p)
+			continue
+		}
+		d.lc.Infof("配置文件 %s sha256=%s", p, s.SHA256)
+	}
+	snap.ParamTable.Entries, snap.ParamTable.SHA256 = config.ParamTableDigest()
+	snap.ParamTable.Versions = config.ParamTableVersions()
+	d.lc.Infof("参数表 %d 项 sha256=%s，版本 %v", snap.ParamTable.Entries, snap.ParamTable.SHA256, snap.ParamTable.Versions)
+	return snap, nil
+}
+
+// buildConfigReport 汇总当前登记的设备，并检查不影响启动的配置问题：
+// 绑定失败的设备、未加载 profile 的设备、未在 core-metadata 中登记的清单设备、无法识别的单位、
+// 绑定到多个设备却未声明 resources 的传感器
+func (d *LpMpDriver) buildConfigReport(snap *configSnapshot) configReport {
+	rep := configReport{configSnapshot: *snap, Devices: []configReportDevice{}}
+	rep.Warnings = append([]string{}, snap.bindingErrs...)
+	registered := make(map[string]bool)
+	for _, dev := range d.sdk.Devices() {
+		registered[dev.Name] = true
+		rd := configReportDevice{
+			Name:           dev.Name,
+			Profile:        dev.ProfileName,
+			SensorIDs:      config.LookupSensorIDs(dev.Name),
+			AdminState:     string(dev.AdminState),
+			OperatingState: string(dev.OperatingState),
+		}
+		resources, ok := config.GetDeviceResources(dev.Name)
+		if !ok {
+			rep.Warnings = append(rep.Warnings, fmt.Sprintf("设备 %s 的 profile %s 未加载，读写命令不可用", dev.Name, dev.ProfileName))
+		}
+		rd.Resources = len(resources)
+		rep.Resources += len(resources)
+		rep.Devices = append(rep.Devices, rd)
+
+		meta, _ := config.DeviceResourceMeta(dev.Name, config.LangZH)
+		for _, m := range meta {
+			if m.Units != "" && m.UCUM == "" {
+				rep.Warnings = append(rep.Warnings, fmt.Sprintf("设备 %s 资源 %s 的单位 %q 无法识别为 UCUM", dev.Name, m.Name, m.Units))
+			}
+		}
+	}
+	for _, name := range config.DeviceNames() {
+		if !registered[name] {
+			rep.Warnings = append(rep.Warnings, fmt.Sprintf("设备 %s 在 devices.yaml 中但未在 core-metadata 中登记", name))
+		}
+	}
+	bindings := config.AllSensorBindings()
+	ids := make([]string, 0, len(bindings))
+	for id := range bindings {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		bs := bindings[id]
+		if len(bs) < 2 {
+			continue
+		}
+		var unfiltered []string
+		for _, b := range bs {
+			if len(b.Resources) == 0 {
+				unfiltered = append(unfiltered, b.DeviceName)
+			}
+		}
+		if len(unfiltered) > 0 {
+			rep.Warnings = append(rep.Warnings, fmt.Sprintf("SensorID %s 绑定到 %d 个设备，其中 %s 未声明 %s.%s，将收到该传感器的全部资源",
+				id, len(bs), strings.Join(unfiltered, "、"), protocolName, resourcesKey))
+		}
+	}
+	return rep
+}
+
+// registerConfigReportRoute 在 SDK 内置的 Web 服务上注册配置校验报告接口
+func (d *LpMpDriver) registerConfigReportRoute() error {
+	return d.sdk.AddCustomRoute(configReportRoute, interfaces.Authenticated, func(c echo.Context) error {
+		if d.configSnap == nil {
+			return c.String(http.StatusServiceUnavailable, "配置尚未加载")
+		}
+		return c.JSON(http.StatusOK, d.buildConfigReport(d.configSnap))
+	}, http.MethodGet)
+}
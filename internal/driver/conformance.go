@@ -0,0 +1,34 @@
+package driver
+
+import (
+	"net/http"
+
+	"github.com/edgexfoundry/device-sdk-go/v4/pkg/interfaces"
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/common"
+	"github.com/labstack/echo/v4"
+	"github.com/linjuya-lu/device-lpmp-go/internal/frameparser"
+)
+
+// conformanceRoute 一致性校验报告接口：GET 查询各传感器统计，DELETE 清空
+const conformanceRoute = common.ApiBase + "/lpmp/conformance"
+
+// conformanceResponse GET 接口返回的内容
+type conformanceResponse struct {
+	Enabled bool                            `json:"enabled"`
+	Sensors []frameparser.ConformanceReport `json:"sensors"`
+}
+
+// registerConformanceRoute 在 SDK 内置的 Web 服务上注册一致性校验报告接口，
+// 校验本身由 Writable.ConformanceMode 开关（认证新厂家传感器时开启）
+func (d *LpMpDriver) registerConformanceRoute() error {
+	return d.sdk.AddCustomRoute(conformanceRoute, interfaces.Authenticated, func(c echo.Context) error {
+		if c.Request().Method == http.MethodDelete {
+			frameparser.ResetConformance()
+			return c.NoContent(http.StatusNoContent)
+		}
+		return c.JSON(http.StatusOK, conformanceResponse{
+			Enabled: frameparser.ConformanceMode(),
+			Sensors: frameparser.ConformanceReports(),
+		})
+	}, http.MethodGet, http.MethodDelete)
+}
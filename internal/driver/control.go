@@ -0,0 +1,150 @@
+package driver
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	dsModels "github.com/edgexfoundry/device-sdk-go/v4/pkg/models"
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+	"github.com/linjuya-lu/device-lpmp-go/internal/frameparser"
+)
+
+const (
+	// ctrlTypeAttr 资源属性：写该资源时向传感器下发此 CtrlType（7bit）的控制报文，
+	// 新的控制类型只需在 profile 中增加资源，不必修改驱动
+	ctrlTypeAttr = "ctrlType"
+	// paramCodeAttr 资源属性：控制报文携带的参量类型（14bit），写入值按参数表编码为该参量；
+	// 未声明时写入值为原始报文内容（Binary 或十六进制字符串），Bool 资源写 true 只下发控制字节
+	paramCodeAttr = "paramCode"
+	// requestSetAttr 资源属性：控制报文的 RequestSetFlag，默认 true（设置）
+	requestSetAttr = "requestSet"
+)
+
+// attrUint 读取资源属性中的无符号整数，支持整数和 "0x06" 形式的字符串；未声明时 ok 为 false
+func attrUint(attrs map[string]any, key string, max uint64) (n uint64, ok bool, err error) {
+	v, ok := attrs[key]
+	if !ok {
+		return 0, false, nil
+	}
+	switch x := v.(type) {
+	case int:
+		if x < 0 {
+			return 0, true, fmt.Errorf("属性 %s=%d 不能为负数", key, x)
+		}
+		n = uint64(x)
+	case int64:
+		if x < 0 {
+			return 0, true, fmt.Errorf("属性 %s=%d 不能为负数", key, x)
+		}
+		n = uint64(x)
+	case uint64:
+		n = x
+	case float64:
+		n = uint64(x)
+	case string:
+		if n, err = strconv.ParseUint(strings.TrimSpace(x), 0, 64); err != nil {
+			return 0, true, fmt.Errorf("属性 %s=%q 格式错误：%w", key, x, err)
+		}
+	default:
+		return 0, true, fmt.Errorf("属性 %s 类型不支持：%T", key, v)
+	}
+	if n > max {
+		return 0, true, fmt.Errorf("属性 %s=0x%X 超出范围（最大 0x%X）", key, n, max)
+	}
+	return n, true, nil
+}
+
+// controlWrite 把声明了 ctrlType 的写请求转换为一次待下发的控制报文；
+// 资源未声明 ctrlType 时 ok 为 false，Bool 资源写 false 时不下发，ok 同样为 false
+func controlWrite(deviceName string, req dsModels.CommandRequest, cv *dsModels.CommandValue) (w sensorParamWrite, ok bool, err error) {
+	// 1. 控制类型和标志位
+	ctrlType, ok, err := attrUint(req.Attributes, ctrlTypeAttr, 0x7F)
+	if !ok || err != nil {
+		return w, false, wrapResourceErr(req.DeviceResourceName, err)
+	}
+	if ctrlType == 0 {
+		return w, false, fmt.Errorf("资源 %s 的 %s 不能为保留值 0", req.DeviceResourceName, ctrlTypeAttr)
+	}
+	if attrBool(req.Attributes, sensorParamAttr) {
+		return w, false, fmt.Errorf("资源 %s 不能同时声明 %s 和 %s", req.DeviceResourceName, ctrlTypeAttr, sensorParamAttr)
+	}
+	requestSet := true
+	if _, declared := req.Attributes[requestSetAttr]; declared {
+		requestSet = attrBool(req.Attributes, requestSetAttr)
+	}
+
+	// 2. 报文内容：声明了 paramCode 时按参数表编码为一个参量，否则为原始数据
+	w = sensorParamWrite{ctrlType: uint8(ctrlType), requestSet: requestSet, values: map[string]any{req.DeviceResourceName: cv.Value}}
+	code, hasCode, err := attrUint(req.Attributes, paramCodeAttr, 0x3FFF)
+	if err != nil {
+		return w, false, wrapResourceErr(req.DeviceResourceName, err)
+	}
+	if hasCode {
+		sid, _ := config.SensorForResource(deviceName, req.DeviceResourceName)
+		info, ok := config.LookupSensorParamInfo(sid, uint16(code))
+		if !ok {
+			return w, false, fmt.Errorf("资源 %s 的参量类型 0x%04X 不在参数表中", req.DeviceResourceName, code)
+		}
+		data, err := config.EncodeParamValue(info, cv.Value)
+		if err != nil {
+			return w, false, err
+		}
+		w.params = []frameparser.Param{{Type: uint16(code), Data: data}}
+	} else {
+		switch v := cv.Value.(type) {
+		case bool:
+			if !v {
+				return w, false, nil
+			}
+		case []byte:
+			w.data = append([]byte(nil), v...)
+		case string:
+			if w.data, err = hex.DecodeString(strings.ReplaceAll(strings.TrimSpace(v), " ", "")); err != nil {
+				return w, false, fmt.Errorf("资源 %s 的写入值须为十六进制字符串：%w", req.DeviceResourceName, err)
+			}
+		default:
+			return w, false, fmt.Errorf("资源 %s 未声明 %s，写入值须为 Bool、Binary 或十六进制字符串，got %T", req.DeviceResourceName, paramCodeAttr, cv.Value)
+		}
+	}
+
+	// 3. 目标传感器
+	sid, ok := config.SensorForResource(deviceName, req.DeviceResourceName)
+	if !ok {
+		return w, false, fmt.Errorf("设备 %s 未配置 SensorID", deviceName)
+	}
+	w.sensorID = sid
+	return w, true, nil
+}
+
+// wrapResourceErr 在属性解析错误前加上资源名，err 为 nil 时返回 nil
+func wrapResourceErr(resourceName string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("资源 %s: %w", resourceName, err)
+}
+
+// writeControl 下发一次通用控制报文并等待传感器确认，返回往返时延
+func (d *LpMpDriver) writeControl(deviceName string, w sensorParamWrite) (time.Duration, error) {
+	sid, err := frameparser.ParseSensorID(w.sensorID)
+	if err != nil {
+		return 0, err
+	}
+	var flag byte
+	if w.requestSet {
+		flag = 1
+	}
+	frame, err := frameparser.BuildControlFrame(sid, w.ctrlType, flag, w.params, w.data)
+	if err != nil {
+		return 0, err
+	}
+	_, rtt, err := d.controlRoundTrip(w.trigger(deviceName), w.sensorID, frame, w.ctrlType, w.requestSet, w.priority)
+	if err != nil {
+		return 0, fmt.Errorf("设备 %s 控制报文（CtrlType=%d）未确认: %w", deviceName, w.ctrlType, err)
+	}
+	d.lc.Infof("设备 %s(SensorID=%s) 已确认控制报文 CtrlType=%d（往返 %s）", deviceName, w.sensorID, w.ctrlType, rtt)
+	return rtt, nil
+}
@@ -0,0 +1,309 @@
+package driver
+
+import (
+	"fmt"
+	"maps"
+	"strconv"
+	"time"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/frameparser"
+	"github.com/linjuya-lu/device-lpmp-go/internal/objstore"
+	"github.com/linjuya-lu/device-lpmp-go/internal/spool"
+)
+
+const (
+	// customConfigSection 为 configuration.yaml 中本驱动的自定义配置节
+	customConfigSection = "LpmpCustom"
+	// writableSection 为自定义配置中可运行时修改的子节
+	writableSection = customConfigSection + "/Writable"
+)
+
+// WritableConfig 可在运行时通过配置中心修改的诊断开关和调优项；
+// 字符串项为空时沿用 Driver 节的同名项
+type WritableConfig struct {
+	// LogRawFrames 为 true 时打印每一帧原始报文的十六进制
+	LogRawFrames bool
+	// LogLevelFrameparser 解析器日志级别：WARN / INFO / DEBUG
+	LogLevelFrameparser string
+	// ConformanceMode 为 true 时逐帧做协议一致性校验，结果见 /api/v3/lpmp/conformance
+	ConformanceMode bool
+
+	// LogThrottleInterval 重复解析错误日志的汇总周期，如 "1m"
+	LogThrottleInterval string
+	// ReadingStaleAfter 读数超过该时长未更新时标记为 stale，"0" 不判断
+	ReadingStaleAfter string
+	// PreFirstWindow 首片之前到达的中间/尾片的暂存时长，"0" 不暂存
+	PreFirstWindow string
+	// SensorAllowList / SensorDenyList SensorID 允许/拒绝列表，逗号分隔，* 结尾为前缀
+	SensorAllowList string
+	SensorDenyList  string
+}
+
+// ReassemblyConfig 分片拼接参数
+type ReassemblyConfig struct {
+	// Timeout 拼接超时时间，如 "20s"
+	Timeout string
+	// FirstPSEQ 分片首片的 PSEQ：0~127 或 "auto"
+	FirstPSEQ string
+	// MaxOutOfOrderPerSDU / MaxOutOfOrderPerService 乱序片段暂存上限
+	MaxOutOfOrderPerSDU     int
+	MaxOutOfOrderPerService int
+}
+
+// ParserConfig 报文解析参数
+type ParserConfig struct {
+	// DefaultDialect 未在设备协议属性 lpmp.dialect 中声明方言的传感器使用的方言，
+	// 如整个项目的传感器都使用 CRC-CCITT 时填 "crc=ccitt"
+	DefaultDialect string
+	// MaxDecompressedSize 压缩报文解压后的最大字节数
+	MaxDecompressedSize int
+}
+
+// CaptureConfig 报文与 SDU 的留存和诊断输出
+type CaptureConfig struct {
+	// SpoolEnabled / SpoolDir / SpoolMaxSizeMB 拼接完成或丢弃的 SDU 原样导出为文件
+	SpoolEnabled   bool
+	SpoolDir       string
+	SpoolMaxSizeMB int
+	// LargeSDUThreshold / ObjectStoreDir 超过门限的 SDU 按内容寻址存为对象
+	LargeSDUThreshold int
+	ObjectStoreDir    string
+	// FailedFrameForwarding / FailedFrameTopic 校验失败的帧转发到 MQTT 主题
+	FailedFrameForwarding bool
+	FailedFrameTopic      string
+	// TraceSpansEnabled 以 DEBUG 日志输出每帧各阶段耗时
+	TraceSpansEnabled bool
+}
+
+// SecurityConfig 安全相关开关
+type SecurityConfig struct {
+	// ListenOnly 为 true 时只监听，不向空口下发任何报文（心跳应答、参数读写、实时查询、校时等），
+	// 用于在他人的网络中旁路采集或排查问题
+	ListenOnly bool
+}
+
+// CustomConfig 对应 LpmpCustom 配置节。Driver 节中的同名项仍然有效，
+// 本节填写了（非零值）的项优先，见 overlay
+type CustomConfig struct {
+	// Transports 模组链路：第一项为主链路（串口名），第二项为可选的备用链路（串口名或 tcp://host:port），
+	// 分别对应 Driver 节的 SerialPort 和 BackupTransport
+	Transports []string
+	Reassembly ReassemblyConfig
+	Parser     ParserConfig
+	Capture    CaptureConfig
+	Security   SecurityConfig
+	// Thresholds 数值资源的本地门限告警，覆盖 profile 中的 alarmHigh / alarmLow 等属性
+	Thresholds []ThresholdRule
+	// DeviceTemplates 设备模板：收到未绑定的 SensorID 时按前缀匹配模板自动创建设备
+	DeviceTemplates []DeviceTemplate
+	Writable        WritableConfig
+}
+
+// ServiceConfig 为 SDK 加载自定义配置的顶层结构
+type ServiceConfig struct {
+	LpmpCustom CustomConfig
+}
+
+// UpdateFromRaw 实现 interfaces.UpdatableConfig，由 SDK 在加载配置时调用
+func (c *ServiceConfig) UpdateFromRaw(rawConfig interface{}) bool {
+	cfg, ok := rawConfig.(*ServiceConfig)
+	if !ok {
+		return false
+	}
+	*c = *cfg
+	return true
+}
+
+// overlay 把本节已填写的项按 Driver 节的键名写入 m；零值（空串、0、false）的项不覆盖
+func (c CustomConfig) overlay(m map[string]string) error {
+	if len(c.Transports) > 2 {
+		return fmt.Errorf("%s.Transports 最多两项（主链路、备用链路），得到 %d 项", customConfigSection, len(c.Transports))
+	}
+	str := func(key, v string) {
+		if v != "" {
+			m[key] = v
+		}
+	}
+	num := func(key string, n int) {
+		if n != 0 {
+			m[key] = strconv.Itoa(n)
+		}
+	}
+	flag := func(key string, b bool) {
+		if b {
+			m[key] = "true"
+		}
+	}
+	if len(c.Transports) > 0 {
+		str(serialPortKey, c.Transports[0])
+	}
+	if len(c.Transports) > 1 {
+		str(backupTransportKey, c.Transports[1])
+	}
+
+	str(reassembleTimeoutKey, c.Reassembly.Timeout)
+	str(firstPSEQKey, c.Reassembly.FirstPSEQ)
+	num(maxOutOfOrderPerSDUKey, c.Reassembly.MaxOutOfOrderPerSDU)
+	num(maxOutOfOrderPerServiceKey, c.Reassembly.MaxOutOfOrderPerService)
+
+	str(defaultDialectKey, c.Parser.DefaultDialect)
+	num(maxDecompressedSizeKey, c.Parser.MaxDecompressedSize)
+
+	flag(spool.KeyEnabled, c.Capture.SpoolEnabled)
+	str(spool.KeyDir, c.Capture.SpoolDir)
+	num(spool.KeyMaxSizeMB, c.Capture.SpoolMaxSizeMB)
+	num(objstore.KeyThreshold, c.Capture.LargeSDUThreshold)
+	str(objstore.KeyDir, c.Capture.ObjectStoreDir)
+	flag(failedFrameForwardingKey, c.Capture.FailedFrameForwarding)
+	str(failedFrameTopicKey, c.Capture.FailedFrameTopic)
+	flag(traceSpansKey, c.Capture.TraceSpansEnabled)
+
+	flag(listenOnlyKey, c.Security.ListenOnly)
+
+	str(logThrottleIntervalKey, c.Writable.LogThrottleInterval)
+	str(readingStaleAfterKey, c.Writable.ReadingStaleAfter)
+	str(preFirstWindowKey, c.Writable.PreFirstWindow)
+	str(sensorAllowListKey, c.Writable.SensorAllowList)
+	str(sensorDenyListKey, c.Writable.SensorDenyList)
+	return nil
+}
+
+// mergedConfig 返回生效的 Driver 配置：Driver 节叠加 LpmpCustom 节（后者优先）。
+// 各组件仍按 Driver 节的键名解析配置，新旧写法共用同一套校验
+func mergedConfig(driverCfg map[string]string, c CustomConfig) (map[string]string, error) {
+	m := maps.Clone(driverCfg)
+	if m == nil {
+		m = make(map[string]string)
+	}
+	if err := c.overlay(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// parseBoolKey 读取布尔配置项，未配置时为 false
+func parseBoolKey(cfg map[string]string, key string) (bool, error) {
+	v := cfg[key]
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("%s 配置无效 %q", key, v)
+	}
+	return b, nil
+}
+
+// loadCustomConfig 加载自定义配置、应用诊断开关，并监听 Writable 子节的变化
+func (d *LpMpDriver) loadCustomConfig() error {
+	d.serviceConfig = &ServiceConfig{}
+	if err := d.sdk.LoadCustomConfig(d.serviceConfig, customConfigSection); err != nil {
+		return fmt.Errorf("加载自定义配置 %s 失败: %w", customConfigSection, err)
+	}
+	if err := d.applyWritable(d.serviceConfig.LpmpCustom.Writable); err != nil {
+		return err
+	}
+	if err := d.sdk.ListenForCustomConfigChanges(&d.serviceConfig.LpmpCustom.Writable, writableSection, d.processWritableChanges); err != nil {
+		return fmt.Errorf("监听自定义配置 %s 失败: %w", writableSection, err)
+	}
+	return nil
+}
+
+// applyWritable 将诊断开关同步到解析器
+func (d *LpMpDriver) applyWritable(w WritableConfig) error {
+	level, err := frameparser.ParseLogLevel(w.LogLevelFrameparser)
+	if err != nil {
+		return fmt.Errorf("LogLevelFrameparser 配置无效: %w", err)
+	}
+	frameparser.SetLogLevel(level)
+	frameparser.SetLogRawFrames(w.LogRawFrames)
+	frameparser.SetConformanceMode(w.ConformanceMode)
+	return nil
+}
+
+// tunables 可运行时调整的参数，由 parseTunables 从生效的 Driver 配置解析
+type tunables struct {
+	logThrottleInterval time.Duration // 0 表示保持当前值
+	readingStaleAfter   time.Duration
+	preFirstWindow      time.Duration
+	preFirstWindowSet   bool
+	allow, deny         []string
+}
+
+// parseTunables 解析可运行时调整的参数，任一项无效时整体不生效
+func parseTunables(cfg map[string]string) (tunables, error) {
+	var t tunables
+	if v := cfg[logThrottleIntervalKey]; v != "" {
+		interval, err := time.ParseDuration(v)
+		if err != nil || interval <= 0 {
+			return t, fmt.Errorf("%s 配置无效 %q", logThrottleIntervalKey, v)
+		}
+		t.logThrottleInterval = interval
+	}
+	if v := cfg[readingStaleAfterKey]; v != "" && v != "0" {
+		staleAfter, err := time.ParseDuration(v)
+		if err != nil || staleAfter < 0 {
+			return t, fmt.Errorf("%s 配置无效 %q", readingStaleAfterKey, v)
+		}
+		t.readingStaleAfter = staleAfter
+	}
+	if v := cfg[preFirstWindowKey]; v != "" {
+		window, err := time.ParseDuration(v)
+		if err != nil || window < 0 {
+			return t, fmt.Errorf("%s 配置无效 %q", preFirstWindowKey, v)
+		}
+		t.preFirstWindow, t.preFirstWindowSet = window, true
+	}
+	t.allow = splitList(cfg[sensorAllowListKey])
+	t.deny = splitList(cfg[sensorDenyListKey])
+	return t, nil
+}
+
+// applyTunables 应用可运行时调整的参数：启动时和 Writable 子节变化时调用
+func (d *LpMpDriver) applyTunables(t tunables) {
+	if t.logThrottleInterval > 0 {
+		frameparser.SetLogThrottleInterval(t.logThrottleInterval)
+	}
+	// HandleReadCommands 持有 locker 时读取
+	d.locker.Lock()
+	d.readingStaleAfter = t.readingStaleAfter
+	d.locker.Unlock()
+	if t.preFirstWindowSet {
+		frameparser.SetPreFirstWindow(t.preFirstWindow)
+	}
+	frameparser.SetSensorFilter(t.allow, t.deny)
+	if len(t.allow)+len(t.deny) > 0 {
+		d.lc.Infof("SensorID 过滤: allow=%v, deny=%v", t.allow, t.deny)
+	}
+}
+
+// processWritableChanges 为 Writable 子节变化的回调
+func (d *LpMpDriver) processWritableChanges(rawWritableConfig interface{}) {
+	updated, ok := rawWritableConfig.(*WritableConfig)
+	if !ok {
+		d.lc.Errorf("自定义配置 %s 更新类型错误: %T", writableSection, rawWritableConfig)
+		return
+	}
+	// 先按新值叠加 Driver 节校验调优项，全部有效才生效；清空的项恢复为 Driver 节的值
+	next := d.serviceConfig.LpmpCustom
+	next.Writable = *updated
+	cfg, err := mergedConfig(d.sdk.DriverConfigs(), next)
+	if err != nil {
+		d.lc.Errorf("%v", err)
+		return
+	}
+	t, err := parseTunables(cfg)
+	if err != nil {
+		d.lc.Errorf("自定义配置 %s 更新无效: %v", writableSection, err)
+		return
+	}
+	if err := d.applyWritable(*updated); err != nil {
+		d.lc.Errorf("%v", err)
+		return
+	}
+	d.applyTunables(t)
+	d.serviceConfig.LpmpCustom.Writable = *updated
+	d.lc.Infof("运行时配置已更新: LogRawFrames=%t, LogLevelFrameparser=%s, ConformanceMode=%t, LogThrottleInterval=%s, ReadingStaleAfter=%s, PreFirstWindow=%s",
+		updated.LogRawFrames, updated.LogLevelFrameparser, updated.ConformanceMode,
+		cfg[logThrottleIntervalKey], cfg[readingStaleAfterKey], cfg[preFirstWindowKey])
+}
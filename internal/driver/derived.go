@@ -0,0 +1,29 @@
+package driver
+
+import (
+	"maps"
+	"time"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+)
+
+// deriveResources 作为 Sink 使用：输入资源写入值表后，计算直接或间接依赖它的派生资源（profile 属性 expression），
+// 结果经完整的 Sink 链发布（值表、转发、归档、门限等），时间和标签沿用触发计算的读数，单位标签按派生资源的 units。
+// 派生结果经 Sink 链回到这里时不再计算：链式派生已由 EvaluateDerived 一次算完
+func (d *LpMpDriver) deriveResources(deviceName, resourceName string, _ any, origin time.Time, tags map[string]string) {
+	if config.IsDerived(deviceName, resourceName) {
+		return
+	}
+	values, errs := config.EvaluateDerived(deviceName, resourceName)
+	for _, err := range errs {
+		d.lc.Warnf("[trace=%s] %v", tags["traceId"], err)
+	}
+	for _, v := range values {
+		derivedTags := maps.Clone(tags)
+		delete(derivedTags, config.UnitTag)
+		if u, ok := config.ResourceUnit(deviceName, v.Resource, ""); ok {
+			derivedTags[config.UnitTag] = u.UCUM
+		}
+		d.sink.SetValue(deviceName, v.Resource, v.Value, origin, derivedTags)
+	}
+}
@@ -0,0 +1,232 @@
+package driver
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+	"github.com/linjuya-lu/device-lpmp-go/internal/frameparser"
+)
+
+const (
+	// sensorOfflineAfterKey Driver 配置项：已上线的传感器超过该时长没有任何上行帧（含心跳）时视为离线，"0" 不判断
+	sensorOfflineAfterKey = "SensorOfflineAfter"
+	// anomalyEventThresholdKey / anomalyEventWindowKey Driver 配置项：同一传感器在窗口内 CRC 失败、
+	// 拼接丢弃或安全校验失败达到该次数时发布事件，每个窗口每类最多一次
+	anomalyEventThresholdKey = "AnomalyEventThreshold"
+	anomalyEventWindowKey    = "AnomalyEventWindow"
+
+	defaultAnomalyEventThreshold = 5
+	defaultAnomalyEventWindow    = 10 * time.Minute
+	// sensorEventBuffer 帧级事件和拼接事件订阅通道的缓冲，处理不及时时丢弃
+	sensorEventBuffer = 256
+
+	// 传感器生命周期和协议异常的系统事件，供 support-notifications 通知运维
+	deviceEventType                     = "lpmp-device"
+	deviceEventActionFirstSeen          = "sensor-first-seen"   // 本次运行中首次收到该传感器的帧
+	deviceEventActionOffline            = "sensor-offline"      // 超过 SensorOfflineAfter 没有上行帧
+	deviceEventActionOnline             = "sensor-online"       // 离线后重新收到上行帧
+	deviceEventActionCRCFailures        = "crc-failures"        // 窗口内 CRC 校验失败达到阈值
+	deviceEventActionReassemblyFailures = "reassembly-failures" // 窗口内分片拼接丢弃达到阈值
+	deviceEventActionSecurityFailures   = "security-failures"   // 窗口内收到未绑定 SensorID 的帧达到阈值
+	deviceEventActionProvisioned        = "sensor-provisioned"  // 按 LpmpCustom.DeviceTemplates 为未绑定的 SensorID 创建了设备
+)
+
+// deviceEventConfig 设备事件的判断参数
+type deviceEventConfig struct {
+	offlineAfter time.Duration
+	threshold    int
+	window       time.Duration
+}
+
+// deviceEventConfigFrom 从 Driver 配置读取离线时长和异常事件阈值，未配置时不判断离线
+func deviceEventConfigFrom(cfg map[string]string) (deviceEventConfig, error) {
+	c := deviceEventConfig{threshold: defaultAnomalyEventThreshold, window: defaultAnomalyEventWindow}
+	if v := cfg[sensorOfflineAfterKey]; v != "" && v != "0" {
+		t, err := time.ParseDuration(v)
+		if err != nil || t <= 0 {
+			return c, fmt.Errorf("%s 配置无效 %q", sensorOfflineAfterKey, v)
+		}
+		c.offlineAfter = t
+	}
+	if v := cfg[anomalyEventThresholdKey]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return c, fmt.Errorf("%s 配置无效 %q", anomalyEventThresholdKey, v)
+		}
+		c.threshold = n
+	}
+	if v := cfg[anomalyEventWindowKey]; v != "" {
+		t, err := time.ParseDuration(v)
+		if err != nil || t <= 0 {
+			return c, fmt.Errorf("%s 配置无效 %q", anomalyEventWindowKey, v)
+		}
+		c.window = t
+	}
+	return c, nil
+}
+
+// deviceEvent 一条待发布的系统事件
+type deviceEvent struct {
+	action  string
+	details map[string]any
+}
+
+// sensorPresence 一个传感器的上线状态
+type sensorPresence struct {
+	lastSeen time.Time
+	offline  bool
+}
+
+// anomalyCounter 一个传感器某类异常在当前窗口内的次数
+type anomalyCounter struct {
+	start    time.Time
+	n        int
+	reported bool
+}
+
+// deviceEventTracker 根据帧级事件和拼接事件判断需要发布的设备事件；只在事件协程中使用，不加锁
+type deviceEventTracker struct {
+	cfg       deviceEventConfig
+	presence  map[string]*sensorPresence
+	anomalies map[string]*anomalyCounter
+}
+
+func newDeviceEventTracker(cfg deviceEventConfig) *deviceEventTracker {
+	return &deviceEventTracker{
+		cfg:       cfg,
+		presence:  make(map[string]*sensorPresence),
+		anomalies: make(map[string]*anomalyCounter),
+	}
+}
+
+// sensorDetails 事件的公共字段：SensorID 及其绑定的设备
+func sensorDetails(sensorID string) map[string]any {
+	var devices []string
+	for _, b := range config.LookupSensorBindings(sensorID) {
+		devices = append(devices, b.DeviceName)
+	}
+	return map[string]any{"sensorId": sensorID, "devices": devices}
+}
+
+// seen 记录一帧已接收的上行帧：首次收到或离线后恢复时返回对应事件
+func (t *deviceEventTracker) seen(sensorID string, now time.Time) []deviceEvent {
+	p, ok := t.presence[sensorID]
+	if !ok {
+		t.presence[sensorID] = &sensorPresence{lastSeen: now}
+		return []deviceEvent{{deviceEventActionFirstSeen, sensorDetails(sensorID)}}
+	}
+	var out []deviceEvent
+	if p.offline {
+		details := sensorDetails(sensorID)
+		details["offlineFor"] = now.Sub(p.lastSeen).Round(time.Second).String()
+		out = append(out, deviceEvent{deviceEventActionOnline, details})
+		p.offline = false
+	}
+	p.lastSeen = now
+	return out
+}
+
+// anomaly 记录一次异常，窗口内次数首次达到阈值时返回事件
+func (t *deviceEventTracker) anomaly(action, sensorID, reason string, now time.Time) []deviceEvent {
+	key := action + "|" + sensorID
+	c, ok := t.anomalies[key]
+	if !ok || now.Sub(c.start) >= t.cfg.window {
+		c = &anomalyCounter{start: now}
+		t.anomalies[key] = c
+	}
+	c.n++
+	if c.reported || c.n < t.cfg.threshold {
+		return nil
+	}
+	c.reported = true
+	details := sensorDetails(sensorID)
+	details["count"] = c.n
+	details["window"] = t.cfg.window.String()
+	details["reason"] = reason
+	return []deviceEvent{{action, details}}
+}
+
+// sweep 检查离线的传感器，并清理已过期的异常计数
+func (t *deviceEventTracker) sweep(now time.Time) []deviceEvent {
+	var out []deviceEvent
+	if t.cfg.offlineAfter > 0 {
+		for sid, p := range t.presence {
+			if p.offline || now.Sub(p.lastSeen) < t.cfg.offlineAfter {
+				continue
+			}
+			p.offline = true
+			details := sensorDetails(sid)
+			details["lastSeen"] = p.lastSeen.Format(time.RFC3339)
+			out = append(out, deviceEvent{deviceEventActionOffline, details})
+		}
+	}
+	for key, c := range t.anomalies {
+		if now.Sub(c.start) >= t.cfg.window {
+			delete(t.anomalies, key)
+		}
+	}
+	return out
+}
+
+// frameEvent 处理一条帧级事件
+func (t *deviceEventTracker) frameEvent(ev frameparser.SensorEvent) []deviceEvent {
+	if ev.Kind == frameparser.SensorFrameAccepted {
+		return t.seen(ev.SensorID, ev.Time)
+	}
+	kind := frameparser.ErrorKind(ev.Err)
+	switch {
+	case errors.Is(ev.Err, frameparser.ErrCRCMismatch):
+		return t.anomaly(deviceEventActionCRCFailures, ev.SensorID, kind, ev.Time)
+	case errors.Is(ev.Err, frameparser.ErrUnknownSensor):
+		return t.anomaly(deviceEventActionSecurityFailures, ev.SensorID, kind, ev.Time)
+	}
+	return nil
+}
+
+// reassemblyEvent 处理一条拼接事件，只统计未完成即被丢弃的 SDU 和分片帧
+func (t *deviceEventTracker) reassemblyEvent(ev frameparser.ReassemblyEvent, now time.Time) []deviceEvent {
+	// 去重丢弃的是已拼接完成过的 SDU，不属于拼接失败
+	if ev.Kind != frameparser.ReassemblyDropped || ev.Reason == frameparser.DropDuplicateSDU {
+		return nil
+	}
+	sid := strings.ToUpper(hex.EncodeToString(ev.SensorID[:]))
+	return t.anomaly(deviceEventActionReassemblyFailures, sid, string(ev.Reason), now)
+}
+
+// startDeviceEvents 订阅帧级事件和拼接事件，把传感器上线/离线和重复的协议异常发布为 lpmp-device 系统事件
+func (d *LpMpDriver) startDeviceEvents(cfg deviceEventConfig) {
+	frames := frameparser.SubscribeSensorEvents(sensorEventBuffer)
+	reassembly := frameparser.SubscribeReassembly(sensorEventBuffer)
+	tracker := newDeviceEventTracker(cfg)
+	// 离线判断的精度为 SensorOfflineAfter 的 1/4，最长一分钟
+	interval := time.Minute
+	if cfg.offlineAfter > 0 {
+		interval = min(interval, cfg.offlineAfter/4)
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			var events []deviceEvent
+			select {
+			case <-d.stopCh:
+				return
+			case ev := <-frames:
+				events = tracker.frameEvent(ev)
+			case ev := <-reassembly:
+				events = tracker.reassemblyEvent(ev, time.Now())
+			case now := <-ticker.C:
+				events = tracker.sweep(now)
+			}
+			for _, ev := range events {
+				d.lc.Infof("设备事件 %s: %v", ev.action, ev.details)
+				d.sdk.PublishGenericSystemEvent(deviceEventType, ev.action, ev.details)
+			}
+		}
+	}()
+}
@@ -0,0 +1,172 @@
+package driver
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/edgexfoundry/device-sdk-go/v4/pkg/interfaces"
+	dsModels "github.com/edgexfoundry/device-sdk-go/v4/pkg/models"
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/common"
+	"github.com/labstack/echo/v4"
+	"github.com/linjuya-lu/device-lpmp-go/internal/downlink"
+)
+
+const (
+	// downlinkPrioritiesKey Driver 配置项：按命令类型覆盖默认下行优先级，如 "paramQuery=normal,group=high"
+	downlinkPrioritiesKey = "DownlinkPriorities"
+	// downlinkStarvationKey Driver 配置项：低优先级帧最多被连续插队的次数，"0" 为严格优先级
+	downlinkStarvationKey     = "DownlinkStarvationLimit"
+	defaultDownlinkStarvation = 8
+
+	// downlinkQueueRoute 下行队列查询/取消接口
+	downlinkQueueRoute = common.ApiBase + "/lpmp/downlink"
+
+	// downlinkPriorityAttr 资源属性：覆盖该命令的下行优先级（high / normal / bulk）
+	downlinkPriorityAttr = "downlinkPriority"
+)
+
+// 下行命令类型，用于选择默认优先级
+const (
+	cmdHeartbeat  = "heartbeat"  // 心跳应答
+	cmdLiveQuery  = "liveQuery"  // 读取时的监测数据实时查询
+	cmdParamWrite = "paramWrite" // 传感器参数设置
+	cmdParamQuery = "paramQuery" // 参数回读校验和周期稽核
+	cmdGroup      = "group"      // 分组/广播控制
+	cmdAlarmAck   = "alarmAck"   // 告警确认
+)
+
+// defaultDownlinkPriorities 各命令类型的默认下行优先级
+var defaultDownlinkPriorities = map[string]downlink.Priority{
+	cmdHeartbeat:  downlink.PriorityHigh,
+	cmdLiveQuery:  downlink.PriorityNormal,
+	cmdParamWrite: downlink.PriorityNormal,
+	cmdParamQuery: downlink.PriorityBulk,
+	cmdGroup:      downlink.PriorityNormal,
+	cmdAlarmAck:   downlink.PriorityHigh,
+}
+
+// downlinkConfig 读取各命令类型的下行优先级和饿死保护上限
+func downlinkConfig(driverCfg map[string]string) (map[string]downlink.Priority, int, error) {
+	prios := make(map[string]downlink.Priority, len(defaultDownlinkPriorities))
+	for cmd, p := range defaultDownlinkPriorities {
+		prios[cmd] = p
+	}
+	for _, item := range splitList(driverCfg[downlinkPrioritiesKey]) {
+		cmd, v, ok := strings.Cut(item, "=")
+		cmd = strings.TrimSpace(cmd)
+		if _, known := prios[cmd]; !ok || !known {
+			return nil, 0, fmt.Errorf("%s 配置项 %q 无效，应为 <命令类型>=<优先级>", downlinkPrioritiesKey, item)
+		}
+		p, err := downlink.ParsePriority(v)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s: %w", downlinkPrioritiesKey, err)
+		}
+		prios[cmd] = p
+	}
+
+	limit := defaultDownlinkStarvation
+	if v := driverCfg[downlinkStarvationKey]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, 0, fmt.Errorf("%s 配置无效 %q", downlinkStarvationKey, v)
+		}
+		limit = n
+	}
+	return prios, limit, nil
+}
+
+// startDownlink 启动下行发送协程，直到驱动停止
+func (d *LpMpDriver) startDownlink(prios map[string]downlink.Priority, starvationLimit int) {
+	d.downlinkPrios = prios
+	d.downlink = downlink.New(d.sendDownlink, starvationLimit)
+	go d.downlink.Run(d.stopCh)
+}
+
+// requestPriority 返回命令的下行优先级：请求中任一资源声明了 downlinkPriority 时取其中最高者，
+// 否则为该命令类型的配置值
+func (d *LpMpDriver) requestPriority(cmd string, reqs []dsModels.CommandRequest) (downlink.Priority, error) {
+	prio, override := d.downlinkPrios[cmd], false
+	for _, req := range reqs {
+		v, ok := req.Attributes[downlinkPriorityAttr].(string)
+		if !ok || v == "" {
+			continue
+		}
+		p, err := downlink.ParsePriority(v)
+		if err != nil {
+			return prio, fmt.Errorf("资源 %s: %w", req.DeviceResourceName, err)
+		}
+		if !override || p < prio {
+			prio, override = p, true
+		}
+	}
+	return prio, nil
+}
+
+// transmit 按优先级排队下发一帧到无线链路并等待写入完成，
+// 所有经空口发出的下行帧都应走这里；自检回环帧不上空口，直接写串口
+func (d *LpMpDriver) transmit(port io.Writer, frame []byte, prio downlink.Priority) error {
+	return d.downlink.Send(downlink.Job{Priority: prio, Writer: port, Frame: frame, Target: frameTarget(frame)})
+}
+
+// frameTarget 返回下行帧的目标 SensorID（帧首 6 字节）
+func frameTarget(frame []byte) string {
+	if len(frame) < 6 {
+		return ""
+	}
+	return strings.ToUpper(hex.EncodeToString(frame[:6]))
+}
+
+// downlinkQueueEntry 下行队列接口返回的一帧
+type downlinkQueueEntry struct {
+	downlink.QueuedJob
+	Age string `json:"age"`
+}
+
+// registerDownlinkQueueRoute 在 SDK 内置的 Web 服务上注册下行队列接口：
+// GET 列出排队中的帧（?sensorId= 只看一个传感器），DELETE ?id= 取消一帧，DELETE ?sensorId= 清空该传感器的队列；
+// 传感器永久离线时，发往它的命令会一直占用队列直至超时，由运维手动清理
+func (d *LpMpDriver) registerDownlinkQueueRoute() error {
+	return d.sdk.AddCustomRoute(downlinkQueueRoute, interfaces.Authenticated, func(c echo.Context) error {
+		if d.downlink == nil {
+			return c.String(http.StatusServiceUnavailable, "下行调度未启动")
+		}
+		sensorID := strings.ToUpper(strings.TrimSpace(c.QueryParam("sensorId")))
+
+		if c.Request().Method == http.MethodDelete {
+			// 1. 取消单帧
+			if s := c.QueryParam("id"); s != "" {
+				id, err := strconv.ParseUint(s, 10, 64)
+				if err != nil {
+					return c.String(http.StatusBadRequest, fmt.Sprintf("id %q 格式错误", s))
+				}
+				if !d.downlink.Cancel(id) {
+					return c.String(http.StatusNotFound, fmt.Sprintf("队列中没有 id=%d 的帧（可能已发出）", id))
+				}
+				d.lc.Infof("已取消下行帧 id=%d", id)
+				return c.JSON(http.StatusOK, map[string]int{"canceled": 1})
+			}
+			// 2. 清空传感器队列
+			if sensorID == "" {
+				return c.String(http.StatusBadRequest, "需指定 id 或 sensorId")
+			}
+			n := d.downlink.Flush(sensorID)
+			d.lc.Infof("已清空 SensorID=%s 的下行队列，取消 %d 帧", sensorID, n)
+			return c.JSON(http.StatusOK, map[string]int{"canceled": n})
+		}
+
+		now := time.Now()
+		out := []downlinkQueueEntry{}
+		for _, job := range d.downlink.Pending() {
+			if sensorID != "" && job.Target != sensorID {
+				continue
+			}
+			out = append(out, downlinkQueueEntry{QueuedJob: job, Age: now.Sub(job.Queued).Truncate(time.Millisecond).String()})
+		}
+		return c.JSON(http.StatusOK, out)
+	}, http.MethodGet, http.MethodDelete)
+}
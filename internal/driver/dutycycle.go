@@ -0,0 +1,53 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/downlink"
+	"github.com/linjuya-lu/device-lpmp-go/internal/dutycycle"
+	"github.com/linjuya-lu/device-lpmp-go/internal/frameparser"
+	"github.com/linjuya-lu/device-lpmp-go/internal/serial"
+)
+
+// dutyCycleRemainingResource 网关设备上当前窗口剩余的发射时长（毫秒）
+const dutyCycleRemainingResource = "duty-cycle-remaining"
+
+// errListenOnly 只监听模式（ListenOnly）下拒绝下发
+var errListenOnly = errors.New("只监听模式，不下发报文")
+
+// sendDownlink 下行发送协程实际写入一帧：启用占空比限制时先等待发射预算（最长 DutyCycleMaxDefer），
+// NoDefer 的帧（如心跳应答，过时即无意义）预算不足时直接放弃
+func (d *LpMpDriver) sendDownlink(job downlink.Job) error {
+	if d.listenOnly {
+		return errListenOnly
+	}
+	// 构造器生成标准帧尾，按目标传感器的方言改写（如 CRC32 + 帧类型字节）
+	frame, err := frameparser.ReframeForSensor(job.Frame, d.dialect)
+	if err != nil {
+		return err
+	}
+	job.Frame = frame
+	if d.duty != nil {
+		if job.NoDefer {
+			wait, err := d.duty.Reserve(len(job.Frame))
+			if err != nil {
+				return err
+			}
+			if wait > 0 {
+				return fmt.Errorf("%w: 需推迟 %s", dutycycle.ErrBudgetExceeded, wait)
+			}
+		} else if err := d.duty.Wait(len(job.Frame), d.stopCh); err != nil {
+			return err
+		}
+	}
+	return serial.WriteFrame(job.Writer, job.Frame)
+}
+
+// dutyCycleRemaining 返回剩余发射时长（毫秒），未启用时返回 false
+func (d *LpMpDriver) dutyCycleRemaining() (uint32, bool) {
+	if d.duty == nil {
+		return 0, false
+	}
+	return uint32(d.duty.Remaining().Milliseconds()), true
+}
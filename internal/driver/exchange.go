@@ -0,0 +1,39 @@
+package driver
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/correlation"
+	"github.com/linjuya-lu/device-lpmp-go/internal/downlink"
+)
+
+// exchange 登记期望的应答 key 并下发 frame，按传感器的 commandTimeout 等待；
+// 应答超时后按 commandRetries 重新下发，下发失败（串口断开、只监听模式等）不重发。
+// 收到应答时返回并记录往返时延：从最后一次发出（不含下行排队）到应答到达
+func (d *LpMpDriver) exchange(port io.Writer, sensorID string, key correlation.Key, frame []byte, prio downlink.Priority) (correlation.Result, time.Duration, error) {
+	timeout, retries := d.commandPolicy(sensorID)
+	for attempt := 0; ; attempt++ {
+		// 先登记再下发，避免应答先于登记到达
+		req := correlation.Default.Expect(key)
+		if err := d.transmit(port, frame, prio); err != nil {
+			correlation.Default.Cancel(req)
+			return correlation.Result{}, 0, err
+		}
+		sent := time.Now()
+		res, err := correlation.Default.Wait(req, timeout)
+		if !errors.Is(err, correlation.ErrTimeout) {
+			// 传感器应答了执行失败同样计入时延
+			rtt := time.Since(sent)
+			if d.latency != nil {
+				d.latency.record(sensorID, rtt)
+			}
+			return res, rtt, err
+		}
+		if attempt >= retries {
+			return res, 0, err
+		}
+		d.lc.Debugf("SensorID=%s 应答超时（%s），第 %d/%d 次重发", sensorID, timeout, attempt+1, retries)
+	}
+}
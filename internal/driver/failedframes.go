@@ -0,0 +1,59 @@
+package driver
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/frameparser"
+)
+
+const (
+	// failedFrameForwardingKey Driver 配置项：为 true 时把 CRC/结构校验失败的帧转发到 FailedFrameTopic
+	failedFrameForwardingKey = "FailedFrameForwarding"
+	// failedFrameTopicKey Driver 配置项：失败帧的 MQTT 主题（经 MQTT 转发的 Broker 发布）
+	failedFrameTopicKey = "FailedFrameTopic"
+
+	defaultFailedFrameTopic = "lpmp/diagnostics/failed-frames"
+	// failedFrameBuffer 失败帧订阅通道的缓冲，转发不及时时丢弃
+	failedFrameBuffer = 256
+)
+
+// failedFrameConfig 从 Driver 配置读取失败帧转发开关和主题
+func failedFrameConfig(driverCfg map[string]string) (bool, string, error) {
+	topic := driverCfg[failedFrameTopicKey]
+	if topic == "" {
+		topic = defaultFailedFrameTopic
+	}
+	v := driverCfg[failedFrameForwardingKey]
+	if v == "" {
+		return false, topic, nil
+	}
+	on, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, topic, fmt.Errorf("%s 配置无效 %q", failedFrameForwardingKey, v)
+	}
+	return on, topic, nil
+}
+
+// startFailedFrameForwarding 开启诊断模式：订阅失败帧并以 JSON（十六进制原始帧 + 原因）发布到 MQTT 主题。
+// 需要同时启用 MQTT 转发，否则只记录在会话日志和节流日志中
+func (d *LpMpDriver) startFailedFrameForwarding(topic string) {
+	if d.mqttPub == nil {
+		d.lc.Warnf("已开启 %s，但未启用 MQTT 转发（MqttRepublishEnabled），失败帧不会被转发", failedFrameForwardingKey)
+		return
+	}
+	ch := frameparser.SubscribeFailedFrames(failedFrameBuffer)
+	frameparser.SetFailedFrameForwarding(true)
+	go func() {
+		for {
+			select {
+			case <-d.stopCh:
+				frameparser.SetFailedFrameForwarding(false)
+				return
+			case ff := <-ch:
+				d.mqttPub.PublishJSON(topic, ff)
+			}
+		}
+	}()
+	d.lc.Infof("已开启失败帧转发: topic=%s", topic)
+}
@@ -0,0 +1,293 @@
+package driver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	dsModels "github.com/edgexfoundry/device-sdk-go/v4/pkg/models"
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+	"github.com/linjuya-lu/device-lpmp-go/internal/correlation"
+	"github.com/linjuya-lu/device-lpmp-go/internal/downlink"
+	"github.com/linjuya-lu/device-lpmp-go/internal/frameparser"
+)
+
+const (
+	// 网关设备上用于分组/广播控制的资源
+	groupTargetResource   = "group-target"
+	groupTimeSyncResource = "group-time-sync"
+	groupAckedResource    = "group-acked"
+	groupMissingResource  = "group-missing"
+
+	// groupTargetAll 下发到广播地址，所有已绑定的传感器都应回复
+	groupTargetAll = "all"
+
+	// 分组/广播控制完成后发布的系统事件
+	groupEventType         = "lpmp-group"
+	groupEventActionResult = "result"
+)
+
+// groupCommand 一次分组/广播控制：报文按目标传感器逐个构造，广播时只构造一次
+type groupCommand struct {
+	name     string
+	ctrlType uint8
+	build    func(sid [6]byte) ([]byte, error)
+	priority downlink.Priority
+}
+
+// isGroupRequest 判断写请求是否为网关设备上的分组/广播控制
+func (d *LpMpDriver) isGroupRequest(deviceName string, reqs []dsModels.CommandRequest) bool {
+	if deviceName == "" || deviceName != d.link.GatewayDevice {
+		return false
+	}
+	for _, req := range reqs {
+		if req.DeviceResourceName == groupTimeSyncResource || attrBool(req.Attributes, sensorParamAttr) {
+			return true
+		}
+	}
+	return false
+}
+
+// groupSensors 返回目标对应的 SensorID 列表：all 为所有已登记设备的传感器，否则为该分组成员
+func (d *LpMpDriver) groupSensors(target string) []string {
+	d.devicesMu.RLock()
+	defer d.devicesMu.RUnlock()
+
+	seen := make(map[string]bool)
+	for dev, opts := range d.deviceOpts {
+		if target != groupTargetAll && !containsString(opts.Groups, target) {
+			continue
+		}
+		for _, sid := range config.LookupSensorIDs(dev) {
+			seen[sid] = true
+		}
+	}
+	out := make([]string, 0, len(seen))
+	for sid := range seen {
+		out = append(out, sid)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// containsString 判断列表中是否包含 s
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// collectGroupCommands 从网关写请求中解析目标和待下发的控制：
+// group-time-sync 为 true 时校时；sensorParam 资源按 parameterType 合并为一帧参数设置
+func collectGroupCommands(deviceName string, reqs []dsModels.CommandRequest, values []*dsModels.CommandValue) (string, []groupCommand, error) {
+	target := groupTargetAll
+	if vals, ok := config.GetDeviceValues(deviceName); ok {
+		if s, ok := vals[groupTargetResource].(string); ok && s != "" {
+			target = s
+		}
+	}
+
+	var cmds []groupCommand
+	var params []frameparser.Param
+	for i, req := range reqs {
+		switch {
+		case req.DeviceResourceName == groupTargetResource:
+			s, ok := values[i].Value.(string)
+			if !ok || strings.TrimSpace(s) == "" {
+				return "", nil, fmt.Errorf("资源 %s 须为非空字符串", groupTargetResource)
+			}
+			target = strings.TrimSpace(s)
+		case req.DeviceResourceName == groupTimeSyncResource:
+			if on, _ := values[i].Value.(bool); on {
+				cmds = append(cmds, groupCommand{
+					name:     "校时",
+					ctrlType: frameparser.CtrlTypeTimeParam,
+					build: func(sid [6]byte) ([]byte, error) {
+						return frameparser.BuildTimeParamFrame(sid, 1, uint32(time.Now().Unix()))
+					},
+				})
+			}
+		case attrBool(req.Attributes, sensorParamAttr):
+			paramType, ok, err := config.ParamTypeFromAttributes(req.Attributes)
+			if err != nil {
+				return "", nil, fmt.Errorf("资源 %s: %w", req.DeviceResourceName, err)
+			}
+			if !ok {
+				return "", nil, fmt.Errorf("资源 %s 标记为 %s 但未声明 %s", req.DeviceResourceName, sensorParamAttr, config.ParameterTypeAttr)
+			}
+			// 广播 / 分组报文由各传感器共同接收，按内置参数表编码
+			info, ok := config.LookupParamInfo(paramType)
+			if !ok {
+				return "", nil, fmt.Errorf("资源 %s 的参量类型 0x%04X 不在参数表中", req.DeviceResourceName, paramType)
+			}
+			data, err := config.EncodeParamValue(info, values[i].Value)
+			if err != nil {
+				return "", nil, err
+			}
+			params = append(params, frameparser.Param{Type: paramType, Data: data})
+		}
+	}
+	if len(params) > 0 {
+		cmds = append(cmds, groupCommand{
+			name:     "参数设置",
+			ctrlType: frameparser.CtrlTypeGeneralParams,
+			build: func(sid [6]byte) ([]byte, error) {
+				return frameparser.BuildParamSetFrame(sid, params)
+			},
+		})
+	}
+	return target, cmds, nil
+}
+
+// fanOutControl 向目标传感器下发控制报文并等待各自的控制响应：
+// 广播时只下发一帧到全 FF 地址，分组时逐个传感器下发；返回已确认和未确认的 SensorID。
+// 每个目标传感器的报文和应答情况各记一条访问审计日志
+func (d *LpMpDriver) fanOutControl(t controlTrigger, cmd groupCommand, broadcast bool, sensors []string) (acked, missing []string, err error) {
+	start := time.Now()
+	// frames 已下发到各传感器的报文，广播时各传感器共用一帧
+	frames := make(map[string][]byte, len(sensors))
+	record := func(sid string, err error) {
+		d.recordControl(t, sid, broadcast, cmd.ctrlType, true, frames[sid], start, err)
+	}
+	// 下发中途失败时，已下发的报文同样记录，但不再等待应答
+	abort := func(err error) {
+		for _, sid := range sensors {
+			if _, sent := frames[sid]; sent {
+				record(sid, fmt.Errorf("已下发，后续报文失败未等待应答: %w", err))
+			} else {
+				record(sid, err)
+			}
+		}
+	}
+	port := d.currentPort()
+	if port == nil {
+		err = fmt.Errorf("串口未打开")
+		abort(err)
+		return nil, nil, err
+	}
+
+	// 1. 先为每个目标登记期望的控制响应，避免应答先于登记到达
+	reqs := make(map[string]*correlation.Request, len(sensors))
+	cancelAll := func() {
+		for _, r := range reqs {
+			correlation.Default.Cancel(r)
+		}
+	}
+	for _, sid := range sensors {
+		reqs[sid] = correlation.Default.Expect(correlation.Key{SensorID: sid, PacketType: frameparser.PacketTypeControlResp})
+	}
+
+	// 2. 下发报文
+	if broadcast {
+		frame, err := cmd.build(frameparser.BroadcastAddress)
+		if err == nil {
+			err = d.transmit(port, frame, cmd.priority)
+		}
+		if err != nil {
+			cancelAll()
+			abort(err)
+			return nil, nil, err
+		}
+		for _, sid := range sensors {
+			frames[sid] = frame
+		}
+	} else {
+		for _, sid := range sensors {
+			addr, err := frameparser.ParseSensorID(sid)
+			if err != nil {
+				cancelAll()
+				abort(err)
+				return nil, nil, err
+			}
+			frame, err := cmd.build(addr)
+			if err == nil {
+				err = d.transmit(port, frame, cmd.priority)
+			}
+			if err != nil {
+				cancelAll()
+				err = fmt.Errorf("SensorID %s: %w", sid, err)
+				abort(err)
+				return nil, nil, err
+			}
+			frames[sid] = frame
+		}
+	}
+
+	// 3. 并发等待各传感器的应答
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for sid, r := range reqs {
+		wg.Add(1)
+		go func(sid string, r *correlation.Request) {
+			defer wg.Done()
+			// 广播只下发一次，不重发；各传感器按所属设备的 commandTimeout 等待
+			timeout, _ := d.commandPolicy(sid)
+			res, err := correlation.Default.Wait(r, timeout)
+			resp, _ := res.Payload.(frameparser.ControlResponse)
+			ok := err == nil && resp.CtrlType == cmd.ctrlType && resp.RequestSet
+			if err == nil && !ok {
+				err = fmt.Errorf("控制响应不匹配: CtrlType=%d RequestSet=%t", resp.CtrlType, resp.RequestSet)
+			}
+			record(sid, err)
+			mu.Lock()
+			if ok {
+				acked = append(acked, sid)
+			} else {
+				missing = append(missing, sid)
+			}
+			mu.Unlock()
+		}(sid, r)
+	}
+	wg.Wait()
+	sort.Strings(acked)
+	sort.Strings(missing)
+	return acked, missing, nil
+}
+
+// handleGroupWrite 执行网关设备上的分组/广播控制，并把确认情况写入 group-acked / group-missing
+func (d *LpMpDriver) handleGroupWrite(deviceName string, reqs []dsModels.CommandRequest, values []*dsModels.CommandValue) error {
+	target, cmds, err := collectGroupCommands(deviceName, reqs, values)
+	if err != nil {
+		return err
+	}
+	if len(cmds) == 0 {
+		return nil
+	}
+	prio, err := d.requestPriority(cmdGroup, reqs)
+	if err != nil {
+		return err
+	}
+	sensors := d.groupSensors(target)
+	if len(sensors) == 0 {
+		return fmt.Errorf("目标 %q 下没有已绑定的传感器", target)
+	}
+	broadcast := target == groupTargetAll
+
+	for _, cmd := range cmds {
+		cmd.priority = prio
+		acked, missing, err := d.fanOutControl(commandTrigger(accessSourceGroup, deviceName, reqs, values), cmd, broadcast, sensors)
+		if err != nil {
+			return fmt.Errorf("向 %s 下发%s失败: %w", target, cmd.name, err)
+		}
+		config.SetDeviceValue(deviceName, groupAckedResource, strings.Join(acked, ","))
+		config.SetDeviceValue(deviceName, groupMissingResource, strings.Join(missing, ","))
+		d.sdk.PublishGenericSystemEvent(groupEventType, groupEventActionResult, map[string]any{
+			"target":    target,
+			"broadcast": broadcast,
+			"command":   cmd.name,
+			"acked":     acked,
+			"missing":   missing,
+		})
+		if len(missing) > 0 {
+			d.lc.Warnf("向 %s 下发%s: %d/%d 个传感器确认，未确认: %v", target, cmd.name, len(acked), len(sensors), missing)
+		} else {
+			d.lc.Infof("向 %s 下发%s: %d 个传感器全部确认", target, cmd.name, len(acked))
+		}
+	}
+	return nil
+}
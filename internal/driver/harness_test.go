@@ -0,0 +1,1724 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	dsModels "github.com/edgexfoundry/device-sdk-go/v4/pkg/models"
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/common"
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/models"
+	"github.com/linjuya-lu/device-lpmp-go/internal/battery"
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+	"github.com/linjuya-lu/device-lpmp-go/internal/correlation"
+	"github.com/linjuya-lu/device-lpmp-go/internal/downlink"
+	"github.com/linjuya-lu/device-lpmp-go/internal/frameparser"
+	"github.com/linjuya-lu/device-lpmp-go/internal/objstore"
+	"github.com/linjuya-lu/device-lpmp-go/internal/sdkfake"
+	"github.com/linjuya-lu/device-lpmp-go/internal/serial"
+	"github.com/linjuya-lu/device-lpmp-go/internal/simulator"
+	"github.com/linjuya-lu/device-lpmp-go/internal/spill"
+	"github.com/linjuya-lu/device-lpmp-go/internal/spool"
+	"github.com/linjuya-lu/device-lpmp-go/internal/sseqstore"
+)
+
+const waterLevelParam uint16 = 0x00A3
+
+// memLink 内存中的串口：读取端接模拟器写入的 +DRX 行，写入的下行指令保存起来供断言
+type memLink struct {
+	r *io.PipeReader
+
+	mu sync.Mutex
+	tx bytes.Buffer
+	// reply 非 nil 时模拟模组对每条指令的应答（返回空串不应答），应答行写入 replyTo
+	reply   func(cmd string) string
+	replyTo io.Writer
+	// awaiting 已写出、尚未应答的指令；overlaps 为其间又写入指令的次数
+	awaiting bool
+	overlaps int
+	// readErr 非 nil 时下一次 Read 返回该错误，模拟暂时性读错误
+	readErr error
+}
+
+func (l *memLink) Read(p []byte) (int, error) {
+	l.mu.Lock()
+	err := l.readErr
+	l.readErr = nil
+	l.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	return l.r.Read(p)
+}
+
+func (l *memLink) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.reply != nil {
+		if l.awaiting {
+			l.overlaps++
+		}
+		if resp := l.reply(string(p)); resp != "" {
+			l.awaiting = true
+			go func() {
+				time.Sleep(2 * time.Millisecond)
+				l.mu.Lock()
+				l.awaiting = false
+				l.mu.Unlock()
+				_, _ = io.WriteString(l.replyTo, resp+"\r\n")
+			}()
+		}
+	}
+	return l.tx.Write(p)
+}
+
+// setReply 启用模组应答模拟
+func (l *memLink) setReply(to io.Writer, reply func(cmd string) string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.reply, l.replyTo = reply, to
+}
+
+func (l *memLink) Close() error { return l.r.Close() }
+
+// downlink 返回已写入的下行指令
+func (l *memLink) downlink() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.tx.String()
+}
+
+// memTransport 总是打开同一条内存串口
+type memTransport struct {
+	link *memLink
+}
+
+func (t memTransport) Open() (io.ReadWriteCloser, string, error) { return t.link, t.String(), nil }
+
+func (memTransport) String() string { return "mem://harness" }
+
+var _ serial.Transport = memTransport{}
+
+// harness 进程内集成测试环境：模拟器 → 内存串口 → 驱动的解析链路 → sdkfake
+type harness struct {
+	t    *testing.T
+	d    *LpMpDriver
+	sdk  *sdkfake.SDK
+	link *memLink
+	w    *io.PipeWriter
+	// events 本测试订阅的拼接事件
+	events <-chan frameparser.ReassemblyEvent
+}
+
+// newHarness 启动接入内存串口的驱动，等待链路连通
+func newHarness(t *testing.T) *harness {
+	t.Helper()
+	return newHarnessConfig(t, nil)
+}
+
+// newHarnessConfig 同 newHarness，extra 中的项覆盖测试用的 Driver 配置
+func newHarnessConfig(t *testing.T, extra map[string]string) *harness {
+	t.Helper()
+	return newHarnessCustom(t, extra, nil)
+}
+
+// newHarnessCustom 同 newHarnessConfig，custom 非空时作为 LpmpCustom 配置节
+func newHarnessCustom(t *testing.T, extra map[string]string, custom *CustomConfig) *harness {
+	t.Helper()
+	frameparser.ResetStats()
+	r, w := io.Pipe()
+	h := &harness{
+		t:      t,
+		link:   &memLink{r: r},
+		w:      w,
+		events: frameparser.SubscribeReassembly(256),
+	}
+
+	cfg := testDriverConfig()
+	for k, v := range extra {
+		cfg[k] = v
+	}
+	h.sdk = sdkfake.New(cfg)
+	if custom != nil {
+		h.sdk.SetCustomConfig(&ServiceConfig{LpmpCustom: *custom})
+	}
+	h.d = New()
+	h.d.resDir = "../../cmd/res"
+	h.d.linkOverride = memTransport{link: h.link}
+	if err := h.d.Initialize(h.sdk); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	addTestDevices(t, h.sdk)
+	if err := h.d.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := h.d.Stop(false); err != nil {
+			t.Errorf("Stop: %v", err)
+		}
+		w.Close()
+	})
+
+	h.waitFor("链路连通", func() bool { return h.d.currentPort() != nil })
+	return h
+}
+
+// sensor 创建绑定在水位设备上的模拟传感器
+func (h *harness) sensor() *simulator.Sensor {
+	h.t.Helper()
+	s, err := simulator.NewSensor(testSensorID)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	return s
+}
+
+// send 把一帧报文以 +DRX 行写入内存串口
+func (h *harness) send(s *simulator.Sensor, frame []byte) {
+	h.t.Helper()
+	if _, err := h.w.Write(s.Line(frame)); err != nil {
+		h.t.Fatalf("写入内存串口失败: %v", err)
+	}
+}
+
+// sendFragmented 把一条监测数据报文切成 n 个分片帧，逐帧以 +DRX 行写入内存串口，由驱动的流水线拼接
+func (h *harness) sendFragmented(s *simulator.Sensor, n int, params ...frameparser.ParamValue) {
+	h.t.Helper()
+	frames, err := s.MonitoringFragments(n, params...)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	for _, f := range frames {
+		h.send(s, f)
+	}
+}
+
+// waitFor 轮询直到 cond 成立，超时则失败
+func (h *harness) waitFor(what string, cond func() bool) {
+	h.t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			h.t.Fatalf("等待%s超时", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// drainEvents 取出已收到的拼接事件，按类型计数
+func (h *harness) drainEvents() map[frameparser.ReassemblyEventKind]int {
+	counts := make(map[frameparser.ReassemblyEventKind]int)
+	for {
+		select {
+		case ev := <-h.events:
+			counts[ev.Kind]++
+		default:
+			return counts
+		}
+	}
+}
+
+// published 返回 ResetStats 以来发布过读数的帧数
+func published() uint64 {
+	return frameparser.SnapshotStats().Latency.Count
+}
+
+// parseErrors 返回 ResetStats 以来的解析错误总数
+func parseErrors() uint64 {
+	var n uint64
+	for _, c := range frameparser.SnapshotStats().ParseErrors {
+		n += c
+	}
+	return n
+}
+
+// waterLevelValue 返回水位设备某个资源的当前值
+func waterLevelValue(resource string) any {
+	values, _ := config.GetDeviceValues(testWaterLevel)
+	return values[resource]
+}
+
+func TestHarnessMonitoringFrames(t *testing.T) {
+	for _, n := range []int{1, 50} {
+		t.Run(strconv.Itoa(n), func(t *testing.T) {
+			testMonitoringFrames(t, n)
+		})
+	}
+}
+
+// testMonitoringFrames 注入 n 帧监测数据，全部发布且没有解析错误，值表为最后一帧的读数
+func testMonitoringFrames(t *testing.T, n int) {
+	h := newHarness(t)
+	s := h.sensor()
+	for i := range n {
+		frame, err := s.Monitoring(frameparser.ParamValue{Type: waterLevelParam, Value: float32(i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.send(s, frame)
+	}
+	h.waitFor("读数发布", func() bool { return published() >= uint64(n) })
+
+	if got := published(); got != uint64(n) {
+		t.Errorf("发布读数的帧数 %d，期望 %d", got, n)
+	}
+	if got := parseErrors(); got != 0 {
+		t.Errorf("解析错误 %v", frameparser.SnapshotStats().ParseErrors)
+	}
+	if got, want := waterLevelValue("water-level"), float32(n-1); got != want {
+		t.Errorf("water-level=%#v，期望 %#v", got, want)
+	}
+}
+
+func TestHarnessFragmentedSDUs(t *testing.T) {
+	h := newHarness(t)
+	s := h.sensor()
+	const n = 10
+	for i := range n {
+		h.sendFragmented(s, 3, frameparser.ParamValue{Type: waterLevelParam, Value: float32(100 + i)})
+	}
+	h.waitFor("拼接后的读数发布", func() bool { return published() >= n })
+
+	counts := h.drainEvents()
+	if completed, dropped := counts[frameparser.ReassemblyCompleted], counts[frameparser.ReassemblyDropped]; completed != n || dropped != 0 {
+		t.Errorf("拼接完成 %d、丢弃 %d，期望完成 %d、丢弃 0", completed, dropped, n)
+	}
+	if len(frameparser.DropCounts()) != 0 {
+		t.Errorf("丢弃计数 %v", frameparser.DropCounts())
+	}
+	if got, want := waterLevelValue("water-level"), float32(100+n-1); got != want {
+		t.Errorf("water-level=%#v，期望 %#v", got, want)
+	}
+}
+
+func TestHarnessFragmentFrames(t *testing.T) {
+	h := newHarness(t)
+	s := h.sensor()
+	frames, err := s.MonitoringFragments(3, frameparser.ParamValue{Type: waterLevelParam, Value: float32(7.5)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 3 {
+		t.Fatalf("分片数 %d，期望 3", len(frames))
+	}
+
+	// 旁路订阅者与解析协程收到同一条 SDU
+	sub := h.d.pipelines[linkRolePrimary].SubscribeFrames("test", frameparser.FrameSubscribeOptions{DropWhenFull: true})
+
+	// 中间片先于首片到达：暂存后由迟到的首片接上，拼接完成的 SDU 由驱动的流水线解析
+	for _, i := range []int{1, 0, 2} {
+		h.send(s, frames[i])
+	}
+	h.waitFor("拼接后的读数发布", func() bool { return published() >= 1 })
+	select {
+	case f := <-sub.Frames():
+		if f.SensorID != s.ID || f.FragInd != 0 {
+			t.Errorf("订阅者收到 SensorID=%X FragInd=%d", f.SensorID, f.FragInd)
+		}
+	case <-time.After(time.Second):
+		t.Error("订阅者未收到拼接完成的 SDU")
+	}
+
+	if got := waterLevelValue("water-level"); got != float32(7.5) {
+		t.Errorf("water-level=%#v，期望 7.5", got)
+	}
+	counts := h.drainEvents()
+	if completed, dropped := counts[frameparser.ReassemblyCompleted], counts[frameparser.ReassemblyDropped]; completed != 1 || dropped != 0 {
+		t.Errorf("拼接完成 %d、丢弃 %d，期望完成 1、丢弃 0", completed, dropped)
+	}
+	if got := parseErrors(); got != 0 {
+		t.Errorf("解析错误 %v", frameparser.SnapshotStats().ParseErrors)
+	}
+
+	// 分片头不完整的帧计入 fragment-header 错误
+	short := frameparser.StandardDialect.Seal(append(append([]byte(nil), s.ID[:]...), 0x08, 0x00))
+	h.send(s, short)
+	h.waitFor("分片头错误", func() bool {
+		return frameparser.SnapshotStats().ParseErrors[frameparser.ErrorKind(frameparser.ErrFragmentHeader)] == 1
+	})
+}
+
+func TestHarnessAlarmAndHeartbeat(t *testing.T) {
+	h := newHarness(t)
+	s := h.sensor()
+
+	// 告警报文：锁存告警、发布 lpmp-alarm 事件，并经异步通道推送 alarmLatched
+	frame, err := s.Alarm(frameparser.ParamValue{Type: waterLevelParam, Value: float32(420)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.send(s, frame)
+	select {
+	case av := <-h.sdk.AsyncValuesChannel():
+		if av.DeviceName != testWaterLevel || av.SourceName != alarmLatchedResource {
+			t.Errorf("异步读数 %s.%s，期望 %s.%s", av.DeviceName, av.SourceName, testWaterLevel, alarmLatchedResource)
+		} else if len(av.CommandValues) != 1 || av.CommandValues[0].Value != true {
+			t.Errorf("alarmLatched 读数 %v", av.CommandValues)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("等待 alarmLatched 异步读数超时")
+	}
+	events := h.sdk.Events(alarmEventType)
+	if len(events) != 1 || events[0].Action != alarmEventActionRaised {
+		t.Errorf("lpmp-alarm 事件 %v", events)
+	}
+	if !alarmLatched(testWaterLevel) {
+		t.Error("告警未锁存")
+	}
+
+	// 心跳：应答经下行队列写回串口，应答成功后累计 heartbeatCount
+	before, _ := waterLevelValue(heartbeatCountResource).(uint32)
+	h.send(s, s.Heartbeat())
+	want := serial.FormatDTXCommand(frameparser.BuildHeartbeatResponse(s.ID))
+	h.waitFor("心跳应答", func() bool { return strings.Contains(h.link.downlink(), want) })
+	h.waitFor("心跳计数", func() bool { return waterLevelValue(heartbeatCountResource) == before+1 })
+}
+
+func TestHarnessParamQuery(t *testing.T) {
+	h := newHarness(t)
+	s := h.sensor()
+
+	// 模拟传感器：收到监测数据查询后上报所查询的参量
+	query, err := frameparser.BuildMonitoringQueryFrame(s.ID, []uint16{waterLevelParam})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		want := serial.FormatDTXCommand(query)
+		deadline := time.Now().Add(5 * time.Second)
+		for !strings.Contains(h.link.downlink(), want) {
+			if time.Now().After(deadline) {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		frame, err := s.Monitoring(frameparser.ParamValue{Type: waterLevelParam, Value: float32(12.5)})
+		if err == nil {
+			_, _ = h.w.Write(s.Line(frame))
+		}
+	}()
+
+	reqs := []dsModels.CommandRequest{{
+		DeviceResourceName: paramQueryResource,
+		Attributes:         map[string]any{urlRawQueryAttr: "params=water-level"},
+	}}
+	res, err := h.d.HandleReadCommands(testWaterLevel, nil, reqs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 {
+		t.Fatalf("返回 %v", res)
+	}
+	var got paramQueryResult
+	if err := json.Unmarshal([]byte(res[0].Value.(string)), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Sensors) != 1 || got.Sensors[0].Error != "" || len(got.Sensors[0].Params) != 1 {
+		t.Fatalf("查询结果 %+v", got)
+	}
+	p := got.Sensors[0].Params[0]
+	if p.ParameterType != "0x00A3" || p.Name != "water-level" || p.Value != 12.5 {
+		t.Errorf("参量 %+v", p)
+	}
+
+	// 未知参量名直接报错，不下发
+	reqs[0].Attributes[urlRawQueryAttr] = "params=no-such-param"
+	if _, err := h.d.HandleReadCommands(testWaterLevel, nil, reqs); err == nil {
+		t.Error("未知参量期望错误")
+	}
+}
+
+func TestHarnessCommandRetries(t *testing.T) {
+	h := newHarness(t)
+	s := h.sensor()
+	if err := h.d.applyDeviceProtocols(testWaterLevel, map[string]models.ProtocolProperties{
+		"lpmp": {"sensorIds": testSensorID, commandTimeoutKey: "200ms", commandRetriesKey: "1"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if timeout, retries := h.d.commandPolicy(testSensorID); timeout != 200*time.Millisecond || retries != 1 {
+		t.Fatalf("commandPolicy=(%s, %d)，期望 (200ms, 1)", timeout, retries)
+	}
+
+	// 模拟传感器：第一次查询不应答，收到重发的查询后才上报
+	query, err := frameparser.BuildMonitoringQueryFrame(s.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := serial.FormatDTXCommand(query)
+	go func() {
+		deadline := time.Now().Add(5 * time.Second)
+		for strings.Count(h.link.downlink(), want) < 2 {
+			if time.Now().After(deadline) {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		frame, err := s.Monitoring(frameparser.ParamValue{Type: waterLevelParam, Value: float32(7)})
+		if err == nil {
+			_, _ = h.w.Write(s.Line(frame))
+		}
+	}()
+	if err := h.d.liveQuery(testWaterLevel, downlink.PriorityNormal); err != nil {
+		t.Fatalf("重发后仍失败: %v", err)
+	}
+	if got := waterLevelValue("water-level"); got != float32(7) {
+		t.Errorf("water-level=%#v，期望 7", got)
+	}
+
+	// 重发次数用尽后返回超时
+	start := time.Now()
+	err = h.d.liveQuery(testWaterLevel, downlink.PriorityNormal)
+	if !errors.Is(err, correlation.ErrTimeout) {
+		t.Fatalf("期望超时，得到 %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("两次等待共耗时 %s，期望不少于 400ms", elapsed)
+	}
+}
+
+// deviceEventActions 返回已发布的 lpmp-device 事件动作
+func (h *harness) deviceEventActions() []string {
+	var actions []string
+	for _, ev := range h.sdk.Events(deviceEventType) {
+		actions = append(actions, ev.Action)
+	}
+	return actions
+}
+
+func TestHarnessDeviceEvents(t *testing.T) {
+	h := newHarness(t)
+	s := h.sensor()
+
+	// 首帧：发布 sensor-first-seen，之后的帧不再发布
+	h.send(s, s.Heartbeat())
+	h.send(s, s.Heartbeat())
+	h.waitFor("sensor-first-seen", func() bool { return len(h.deviceEventActions()) > 0 })
+
+	// CRC 错误达到阈值时发布一次 crc-failures
+	bad := s.Heartbeat()
+	bad[len(bad)-1] ^= 0xFF
+	for range defaultAnomalyEventThreshold + 2 {
+		h.send(s, bad)
+	}
+	h.waitFor("crc-failures", func() bool { return len(h.deviceEventActions()) > 1 })
+	time.Sleep(50 * time.Millisecond)
+	if got, want := h.deviceEventActions(), []string{deviceEventActionFirstSeen, deviceEventActionCRCFailures}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("事件 %v，期望 %v", got, want)
+	}
+}
+
+func TestHarnessDeviceTemplates(t *testing.T) {
+	h := newHarnessCustom(t, nil, &CustomConfig{DeviceTemplates: []DeviceTemplate{
+		{SensorIDPrefix: "aabb", Profile: "Friendcom-TempHumi-Profile", NamePattern: "TempHumi-{sensorId}"},
+		{SensorIDPrefix: "AABBCC", Profile: "Friendcom-Water-Level-Profile", NamePattern: "WaterLevel-{last4}",
+			Labels: []string{"rollout-1"}, Protocols: map[string]string{commandTimeoutKey: "30s"}},
+	}})
+	s, err := simulator.NewSensor("AABBCCDD1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 未绑定的 SensorID 按最长前缀匹配的模板创建设备
+	const name = "WaterLevel-1234"
+	t.Cleanup(func() { config.UnbindDevice(name) })
+	h.send(s, s.Heartbeat())
+	h.waitFor("创建设备", func() bool { return len(config.LookupSensorIDs(name)) == 1 })
+	dev, err := h.sdk.GetDeviceByName(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dev.ProfileName != "Friendcom-Water-Level-Profile" || dev.Protocols[protocolName][sensorIDsKey] != "AABBCCDD1234" ||
+		dev.Protocols[protocolName][commandTimeoutKey] != "30s" || len(dev.Labels) != 1 {
+		t.Errorf("设备 %+v", dev)
+	}
+	if _, ok := config.GetDeviceResources(name); !ok {
+		t.Error("未加载新设备的 profile 资源")
+	}
+	if got := h.deviceEventActions(); len(got) != 1 || got[0] != deviceEventActionProvisioned {
+		t.Errorf("事件 %v，期望 [%s]", got, deviceEventActionProvisioned)
+	}
+
+	// 之后的帧分发到新设备
+	heartbeats := func() any {
+		values, _ := config.GetDeviceValues(name)
+		return values[heartbeatCountResource]
+	}
+	before := heartbeats()
+	h.send(s, s.Heartbeat())
+	h.waitFor("新设备心跳计数", func() bool { return heartbeats() != before })
+}
+
+func TestCompileDeviceTemplates(t *testing.T) {
+	for _, bad := range []DeviceTemplate{
+		{Profile: "p"},
+		{Profile: "p", NamePattern: "x-{last13}"},
+		{Profile: "p", NamePattern: "x-{id}"},
+		{Profile: "p", NamePattern: "x", SensorIDPrefix: "XY"},
+		{Profile: "p", NamePattern: "x", Protocols: map[string]string{sensorIDsKey: "AABBCCDDEEFF"}},
+		{Profile: "p", NamePattern: "x", Protocols: map[string]string{commandTimeoutKey: "soon"}},
+	} {
+		if _, err := compileDeviceTemplates([]DeviceTemplate{bad}); err == nil {
+			t.Errorf("模板 %+v 期望错误", bad)
+		}
+	}
+	if name, err := expandName("WL-{last4}-{sensorId}", "238A0821BEF2"); err != nil || name != "WL-BEF2-238A0821BEF2" {
+		t.Errorf("expandName = %q, %v", name, err)
+	}
+}
+
+func TestDeviceEventTrackerOffline(t *testing.T) {
+	tr := newDeviceEventTracker(deviceEventConfig{offlineAfter: time.Minute, threshold: 1, window: time.Minute})
+	t0 := time.Now()
+	actions := func(evs []deviceEvent) string {
+		var out []string
+		for _, ev := range evs {
+			out = append(out, ev.action)
+		}
+		return strings.Join(out, ",")
+	}
+	if got := actions(tr.seen(testSensorID, t0)); got != deviceEventActionFirstSeen {
+		t.Errorf("首帧事件 %q", got)
+	}
+	if got := actions(tr.sweep(t0.Add(30 * time.Second))); got != "" {
+		t.Errorf("未超时即发布 %q", got)
+	}
+	if got := actions(tr.sweep(t0.Add(time.Minute))); got != deviceEventActionOffline {
+		t.Errorf("超时事件 %q", got)
+	}
+	if got := actions(tr.sweep(t0.Add(2 * time.Minute))); got != "" {
+		t.Errorf("离线事件重复发布 %q", got)
+	}
+	if got := actions(tr.seen(testSensorID, t0.Add(3*time.Minute))); got != deviceEventActionOnline {
+		t.Errorf("恢复事件 %q", got)
+	}
+}
+
+func TestHarnessDuplicateSDU(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "sseq.json")
+	h := newHarnessConfig(t, map[string]string{sseqstore.KeyFile: file})
+	s := h.sensor()
+	frames, err := s.MonitoringFragments(3, frameparser.ParamValue{Type: waterLevelParam, Value: float32(3)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := frameparser.ParseFragment(frames[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 同一 SDU 的分片帧经串口重传一次：驱动的流水线只发布一次，重传记为 duplicate-sdu
+	for range 2 {
+		for _, f := range frames {
+			h.send(s, f)
+		}
+	}
+	h.waitFor("读数发布和重复 SDU 丢弃", func() bool {
+		return published() >= 1 && frameparser.DropCounts()[frameparser.DropDuplicateSDU] >= 1
+	})
+	time.Sleep(50 * time.Millisecond)
+	if got := published(); got != 1 {
+		t.Errorf("发布读数的帧数 %d，期望 1", got)
+	}
+	counts := h.drainEvents()
+	if completed, dropped := counts[frameparser.ReassemblyCompleted], counts[frameparser.ReassemblyDropped]; completed != 1 || dropped != 1 {
+		t.Errorf("拼接完成 %d、丢弃 %d，期望各 1", completed, dropped)
+	}
+
+	// 记录落盘后，重启的服务仍把该 SSEQ 视为重复
+	if err := h.d.sseq.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := sseqstore.ConfigFromDriver(map[string]string{sseqstore.KeyFile: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted, err := sseqstore.New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restarted.Accept(testSensorID, first.SSEQ, time.Now()) {
+		t.Error("重启后未识别已拼接完成的 SSEQ")
+	}
+	if !restarted.Accept(testSensorID, first.SSEQ+1, time.Now()) {
+		t.Error("新的 SSEQ 被当作重复")
+	}
+}
+
+// fragmentSDU 返回分片帧按序拼接得到的 SDU
+func fragmentSDU(t *testing.T, frames [][]byte) []byte {
+	t.Helper()
+	var sdu []byte
+	for _, f := range frames {
+		frag, err := frameparser.ParseFragment(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sdu = append(sdu, frag.Data...)
+	}
+	return sdu
+}
+
+func TestHarnessObjectStore(t *testing.T) {
+	dir := t.TempDir()
+	h := newHarnessConfig(t, map[string]string{objstore.KeyThreshold: "8", objstore.KeyDir: dir})
+	s := h.sensor()
+	frames, err := s.MonitoringFragments(3,
+		frameparser.ParamValue{Type: waterLevelParam, Value: float32(1)},
+		frameparser.ParamValue{Type: waterLevelParam, Value: float32(2)},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sdu := fragmentSDU(t, frames)
+
+	// 经串口收到的分片帧由驱动的流水线拼接，超过门限的 SDU 按内容寻址存为对象
+	for _, f := range frames {
+		h.send(s, f)
+	}
+	sum := sha256.Sum256(sdu)
+	var stored []byte
+	h.waitFor("SDU 存为对象", func() bool {
+		stored, err = h.d.objects.Get(hex.EncodeToString(sum[:]))
+		return err == nil
+	})
+	if !bytes.Equal(stored, sdu) {
+		t.Errorf("对象内容 % X，期望 % X", stored, sdu)
+	}
+}
+
+func TestHarnessSpool(t *testing.T) {
+	dir := t.TempDir()
+	h := newHarnessConfig(t, map[string]string{spool.KeyEnabled: "true", spool.KeyDir: dir})
+	s := h.sensor()
+	param := frameparser.ParamValue{Type: waterLevelParam, Value: float32(5)}
+	lost, err := s.MonitoringFragments(3, param)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames, err := s.MonitoringFragments(3, param)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 只收到首片的 SDU 被下一条 SDU 的首片顶替，已收到的数据导出到 dropped 子目录；
+	// 拼接完成的 SDU 导出到导出目录
+	h.send(s, lost[0])
+	for _, f := range frames {
+		h.send(s, f)
+	}
+	// spooled 等待匹配 pattern 的导出文件写完并核对内容
+	spooled := func(pattern string, want []byte) {
+		t.Helper()
+		var data []byte
+		h.waitFor("SDU 导出 "+pattern, func() bool {
+			matches, _ := filepath.Glob(filepath.Join(dir, pattern))
+			if len(matches) == 0 {
+				return false
+			}
+			data, _ = os.ReadFile(matches[0])
+			return len(data) >= len(want)
+		})
+		if !bytes.Equal(data, want) {
+			t.Errorf("%s 内容 % X，期望 % X", pattern, data, want)
+		}
+	}
+	lostFrag, err := frameparser.ParseFragment(lost[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := frameparser.ParseFragment(frames[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	spooled(fmt.Sprintf("dropped/%s_%d_*.bin", testSensorID, lostFrag.SSEQ), lostFrag.Data)
+	spooled(fmt.Sprintf("%s_%d_*.bin", testSensorID, first.SSEQ), fragmentSDU(t, frames))
+}
+
+func TestHarnessTLVParam(t *testing.T) {
+	h := newHarness(t)
+	s := h.sensor()
+	const vendorParam = 0x0200
+	if err := config.SetParamTLV([]config.TLVTable{{
+		ParameterType: vendorParam,
+		ByteOrder:     "big",
+		Codes: []config.TLVCode{
+			{Tag: 0x01, Name: "water-level", DataType: "float32", Unit: "m"},
+			{Tag: 0x10, Name: "diag", Codes: []config.TLVCode{
+				{Tag: 0x02, Name: "battery-level", DataType: "uint16"},
+				{Tag: 0x03, Name: "state", DataType: "uint8"},
+			}},
+		},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = config.SetParamTLV(nil) })
+
+	// water-level=12.5（大端 float32）、嵌套的 battery-level=300 和 state=1，以及一个未知标签
+	data := []byte{
+		0x01, 4, 0x41, 0x48, 0x00, 0x00,
+		0x7F, 1, 0xEE,
+		0x10, 7, 0x02, 2, 0x01, 0x2C, 0x03, 1, 0x01,
+	}
+	frame, err := s.Monitoring(frameparser.ParamValue{Type: vendorParam, Value: data})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.send(s, frame)
+	h.waitFor("读数发布", func() bool { return published() >= 1 })
+
+	if got := waterLevelValue("water-level"); got != float32(12.5) {
+		t.Errorf("water-level=%#v，期望 12.5", got)
+	}
+	if got := waterLevelValue("battery-level"); got != uint16(300) {
+		t.Errorf("battery-level=%#v，期望 300", got)
+	}
+	if got := waterLevelValue("state"); got != uint8(1) {
+		t.Errorf("state=%#v，期望 1", got)
+	}
+	// 未知标签计入解析错误，其余标签照常发布
+	if got := frameparser.SnapshotStats().ParseErrors[frameparser.ErrorKind(frameparser.ErrTLVFormat)]; got != 1 {
+		t.Errorf("tlv-format 错误 %d，期望 1", got)
+	}
+
+	// 子表校验：同一子表内名称重复
+	if err := config.SetParamTLV([]config.TLVTable{{ParameterType: vendorParam, Codes: []config.TLVCode{
+		{Tag: 1, Name: "x", DataType: "uint8"}, {Tag: 2, Name: "x", DataType: "uint8"},
+	}}}); err == nil {
+		t.Error("名称重复期望错误")
+	}
+}
+
+// gatewayBoolWrite 向网关设备的 Bool 资源写 true，模拟 resetStats / pauseIngest 等命令
+func (h *harness) gatewayBoolWrite(resource string) {
+	h.t.Helper()
+	reqs := []dsModels.CommandRequest{{DeviceResourceName: resource, Type: "Bool"}}
+	values := []*dsModels.CommandValue{{DeviceResourceName: resource, Type: "Bool", Value: true}}
+	if err := h.d.HandleWriteCommands(testGateway, nil, reqs, values); err != nil {
+		h.t.Fatalf("写网关 %s 失败: %v", resource, err)
+	}
+}
+
+// linkEventActions 返回已发布的 lpmp-link 事件中以 prefix 开头的动作
+func (h *harness) linkEventActions(prefix string) []string {
+	var actions []string
+	for _, ev := range h.sdk.Events(linkEventType) {
+		if strings.HasPrefix(ev.Action, prefix) {
+			actions = append(actions, ev.Action)
+		}
+	}
+	return actions
+}
+
+func TestHarnessPauseIngestDrop(t *testing.T) {
+	h := newHarnessConfig(t, map[string]string{ingestPauseModeKey: "drop"})
+	s := h.sensor()
+	frame, err := s.Monitoring(frameparser.ParamValue{Type: waterLevelParam, Value: float32(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 暂停期间照常读取链路，帧丢弃并计数，不发布读数
+	h.gatewayBoolWrite(pauseIngestResource)
+	h.send(s, frame)
+	h.send(s, frame)
+	h.waitFor("丢弃两帧", func() bool { return h.d.ingest.stats().Dropped == 2 })
+	time.Sleep(50 * time.Millisecond)
+	if n := published(); n != 0 {
+		t.Fatalf("暂停期间发布了 %d 帧", n)
+	}
+	values, _ := config.GetDeviceValues(testGateway)
+	if values[ingestPausedResource] != true {
+		t.Errorf("ingest-paused = %v，期望 true", values[ingestPausedResource])
+	}
+
+	// 恢复后新帧照常解析
+	h.gatewayBoolWrite(resumeIngestResource)
+	h.send(s, frame)
+	h.waitFor("恢复后发布读数", func() bool { return published() == 1 })
+	values, _ = config.GetDeviceValues(testGateway)
+	if values[ingestPausedResource] != false || values[ingestDroppedResource] != uint32(2) {
+		t.Errorf("ingest-paused = %v, ingest-dropped = %v，期望 false, 2", values[ingestPausedResource], values[ingestDroppedResource])
+	}
+	if got := strings.Join(h.linkEventActions("ingest-"), ","); got != "ingest-paused,ingest-resumed" {
+		t.Errorf("事件 %s", got)
+	}
+
+	// resetStats 同时清零丢弃数
+	h.gatewayBoolWrite(resetStatsResource)
+	if n := h.d.ingest.stats().Dropped; n != 0 {
+		t.Errorf("resetStats 后丢弃数 %d", n)
+	}
+}
+
+func TestHarnessPauseIngestBuffer(t *testing.T) {
+	h := newHarnessConfig(t, map[string]string{ingestPauseMaxKey: "300ms"})
+	s := h.sensor()
+	frame, err := s.Monitoring(frameparser.ParamValue{Type: waterLevelParam, Value: float32(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// buffer：不再读取链路，写入方阻塞，帧留在缓冲区中
+	h.gatewayBoolWrite(pauseIngestResource)
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		_, _ = h.w.Write(s.Line(frame))
+		_, _ = h.w.Write(s.Line(frame))
+	}()
+	time.Sleep(100 * time.Millisecond)
+	if n := published(); n != 0 {
+		t.Fatalf("暂停期间发布了 %d 帧", n)
+	}
+
+	// 超过 IngestPauseMaxDuration 自动恢复，缓冲的帧一帧不少地解析
+	h.waitFor("自动恢复后发布读数", func() bool { return published() == 2 })
+	<-sent
+	if st := h.d.ingest.stats(); st.Paused || st.Dropped != 0 {
+		t.Errorf("恢复后状态 %+v", st)
+	}
+	evs := h.sdk.Events(linkEventType)
+	last := evs[len(evs)-1]
+	if details, _ := last.Details.(map[string]any); last.Action != linkEventActionIngestResumed || details["reason"] != "timeout" {
+		t.Errorf("最后一个事件 %s %v，期望超时恢复", last.Action, last.Details)
+	}
+}
+
+func TestHarnessSerialCommandQueue(t *testing.T) {
+	h := newHarnessConfig(t, map[string]string{serialResponseTimeoutKey: "200ms"})
+	h.link.setReply(h.w, func(cmd string) string {
+		switch {
+		case strings.HasPrefix(cmd, "AT+BAD"):
+			return "ERROR"
+		case strings.HasPrefix(cmd, "AT+SILENT"):
+			return ""
+		}
+		return "OK"
+	})
+	port := h.d.currentPort()
+
+	// 多个协程同时下发：每条指令整条写出，且上一条应答之前不写下一条
+	const n = 20
+	want := make(map[string]bool, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		frame := bytes.Repeat([]byte{byte(i)}, 64)
+		want[strings.TrimSuffix(serial.FormatDTXCommand(frame), "\r\n")] = true
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := serial.WriteFrame(port, frame); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	lines := strings.Split(strings.TrimSuffix(h.link.downlink(), "\r\n"), "\r\n")
+	if len(lines) != n {
+		t.Fatalf("写出 %d 行，期望 %d", len(lines), n)
+	}
+	for _, line := range lines {
+		if !want[line] {
+			t.Errorf("指令被截断或交错: %.40s…", line)
+		}
+	}
+	h.link.mu.Lock()
+	overlaps := h.link.overlaps
+	h.link.mu.Unlock()
+	if overlaps != 0 {
+		t.Errorf("%d 条指令在上一条应答前写出", overlaps)
+	}
+
+	// ERROR 应答和应答超时作为下发失败返回
+	if err := serial.WriteCommand(port, "AT+BAD"); !errors.Is(err, serial.ErrCommandRejected) {
+		t.Errorf("ERROR 应答得到 %v", err)
+	}
+	if err := serial.WriteCommand(port, "AT+SILENT"); !errors.Is(err, serial.ErrResponseTimeout) {
+		t.Errorf("无应答得到 %v", err)
+	}
+	if err := serial.WriteCommand(port, "AT"); err != nil {
+		t.Errorf("超时后的下一条指令: %v", err)
+	}
+}
+
+func TestHarnessReaderRecovery(t *testing.T) {
+	h := newHarness(t)
+	serial.ResetReaderStats()
+	s := h.sensor()
+	frame, err := s.Monitoring(frameparser.ParamValue{Type: waterLevelParam, Value: float32(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 超过 Scanner 缓冲的行被丢弃，之后的帧照常解析
+	long := "+DRX:" + testSensorID + ",35000," + strings.Repeat("AB", 35000) + "\r\n"
+	if _, err := io.WriteString(h.w, long); err != nil {
+		t.Fatal(err)
+	}
+	h.send(s, frame)
+	h.waitFor("超长行之后的读数", func() bool { return published() == 1 })
+
+	// 暂时性读错误后重试，链路不断开
+	h.link.mu.Lock()
+	h.link.readErr = os.ErrDeadlineExceeded
+	h.link.mu.Unlock()
+	h.send(s, frame)
+	h.send(s, frame)
+	h.waitFor("读错误之后的读数", func() bool { return published() == 3 })
+
+	if st := serial.SnapshotReaderStats(); st.TooLong != 1 || st.TransientErrors != 1 {
+		t.Errorf("恢复计数 %+v，期望各 1", st)
+	}
+	for _, ev := range h.sdk.Events(linkEventType) {
+		if ev.Action == linkEventActionDown {
+			t.Errorf("可恢复的错误导致链路断开: %v", ev.Details)
+		}
+	}
+}
+
+func TestHarnessFrameSizeLimit(t *testing.T) {
+	h := newHarnessConfig(t, map[string]string{maxFrameSizeKey: "64"})
+	serial.ResetReaderStats()
+	s := h.sensor()
+	frame, err := s.Monitoring(frameparser.ParamValue{Type: waterLevelParam, Value: float32(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 声明长度超限的行不解码 payload 直接丢弃，payload 超限的行同样丢弃
+	bad := "+DRX:" + testSensorID + ",100000,AB\r\n" +
+		"+DRX:" + testSensorID + ",10," + strings.Repeat("AB", 70) + "\r\n"
+	if _, err := io.WriteString(h.w, bad); err != nil {
+		t.Fatal(err)
+	}
+	h.send(s, frame)
+	h.waitFor("超限帧之后的读数", func() bool { return published() == 1 })
+
+	if st := serial.SnapshotReaderStats(); st.Oversize != 2 || st.TooLong != 0 {
+		t.Errorf("读取计数 %+v，期望 oversize 2、tooLong 0", st)
+	}
+}
+
+func TestHarnessReplayCapture(t *testing.T) {
+	dir := t.TempDir()
+	h := newHarnessConfig(t, map[string]string{captureDirKey: dir})
+	s := h.sensor()
+	monitoring, err := s.Monitoring(frameparser.ParamValue{Type: waterLevelParam, Value: float32(42)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	alarm, err := s.Alarm(frameparser.ParamValue{Type: waterLevelParam, Value: float32(420)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	frags, err := s.MonitoringFragments(2, frameparser.ParamValue{Type: waterLevelParam, Value: float32(43)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 抓包中的监测、告警和心跳帧，夹杂模组应答和格式错误的行；最后一条 SDU 分片到达，读完时才拼接完成
+	t0 := time.Date(2026, 3, 1, 8, 0, 0, 123456789, time.Local)
+	var capture bytes.Buffer
+	line := func(at time.Time, raw []byte) string {
+		return at.Format(time.RFC3339Nano) + " " + strings.TrimSpace(string(raw)) + "\n"
+	}
+	capture.WriteString(line(t0, s.Line(monitoring)))
+	capture.WriteString(line(t0.Add(time.Second), []byte("OK")))
+	capture.WriteString("not-a-timestamp +DRX:00,1,00\n")
+	capture.WriteString(line(t0.Add(time.Minute), s.Line(alarm)))
+	capture.WriteString(line(t0.Add(2*time.Minute), s.Line(s.Heartbeat())))
+	for i, f := range frags {
+		capture.WriteString(line(t0.Add(3*time.Minute+time.Duration(i)*time.Second), s.Line(f)))
+	}
+	if err := os.WriteFile(filepath.Join(dir, "outage.cap"), capture.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(name string) error {
+		reqs := []dsModels.CommandRequest{{DeviceResourceName: replayCaptureResource, Type: "String"}}
+		values := []*dsModels.CommandValue{{DeviceResourceName: replayCaptureResource, Type: "String", Value: name}}
+		return h.d.HandleWriteCommands(testGateway, nil, reqs, values)
+	}
+	if err := write("../outage.cap"); err == nil {
+		t.Error("目录外的抓包文件未被拒绝")
+	}
+	if err := write("outage.cap"); err != nil {
+		t.Fatal(err)
+	}
+	h.waitFor("回补完成", func() bool {
+		st := h.d.replay.snapshot()
+		return st != nil && st.State != replayRunning
+	})
+	st := h.d.replay.snapshot()
+	if st.State != replayDone || st.Frames != 5 || st.Skipped != 1 {
+		t.Errorf("回补进度 %+v，期望 done、5 帧、跳过 1 行", st)
+	}
+
+	// 读数以抓包时间为 Origin（分片 SDU 为首片的时间）、带 backfill 标签
+	var origins []time.Time
+	for len(h.sdk.AsyncValuesChannel()) > 0 {
+		av := <-h.sdk.AsyncValuesChannel()
+		if av.DeviceName != testWaterLevel || av.SourceName != "water-level" {
+			continue
+		}
+		cv := av.CommandValues[0]
+		if cv.Tags[backfillTag] != "true" {
+			t.Errorf("回补读数缺少 backfill 标签: %v", cv.Tags)
+		}
+		origins = append(origins, time.Unix(0, cv.Origin))
+	}
+	if want := []time.Time{t0, t0.Add(time.Minute), t0.Add(3 * time.Minute)}; len(origins) != len(want) ||
+		!origins[0].Equal(want[0]) || !origins[1].Equal(want[1]) || !origins[2].Equal(want[2]) {
+		t.Errorf("回补读数 Origin %v，期望 %v", origins, want)
+	}
+
+	// 当前设备状态不受影响：值表、告警锁存、心跳应答
+	if got := waterLevelValue("water-level"); got == float32(42) || got == float32(420) || got == float32(43) {
+		t.Errorf("回补读数写入了值表: water-level=%v", got)
+	}
+	if alarmLatched(testWaterLevel) {
+		t.Error("回补的告警帧锁存了告警")
+	}
+	if want := serial.FormatDTXCommand(frameparser.BuildHeartbeatResponse(s.ID)); strings.Contains(h.link.downlink(), want) {
+		t.Error("回补的心跳帧被应答")
+	}
+	if got := h.linkEventActions("replay-"); len(got) != 2 {
+		t.Errorf("回补事件 %v", got)
+	}
+
+	// lpmp-tail -capture 写出的文件可直接回放：分多次到达的行在换行到达时记录
+	var recorded bytes.Buffer
+	cw := serial.NewCaptureWriter(&recorded)
+	raw := s.Line(monitoring)
+	_, _ = cw.Write(raw[:5])
+	_, _ = cw.Write(append(append([]byte(nil), raw[5:]...), "OK\r\n"...))
+	f, err := serial.NewCaptureReader(&recorded).Next()
+	if err != nil || !bytes.Equal(f.Data, monitoring) || f.Received.IsZero() {
+		t.Errorf("抓包往返读出 %+v, %v", f, err)
+	}
+}
+
+func TestHarnessParamTableVersion(t *testing.T) {
+	h := newHarness(t)
+	// v2 固件把水位改为以厘米计的 uint16
+	if err := config.SetParamTableVersions(map[string][]config.ParamDef{
+		"v2": {{ParameterType: waterLevelParam, Name: "water-level", Unit: "cm", DataType: "uint16"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = config.SetParamTableVersions(nil) })
+	s := h.sensor()
+	v1, err := s.Monitoring(frameparser.ParamValue{Type: waterLevelParam, Value: float32(1.5)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.d.applyDeviceProtocols(testWaterLevel, map[string]models.ProtocolProperties{
+		"lpmp": {"sensorIds": testSensorID, paramTableVersionKey: "v3"},
+	}); err == nil {
+		t.Error("未定义的参数表版本未被拒绝")
+	}
+	if err := h.d.applyDeviceProtocols(testWaterLevel, map[string]models.ProtocolProperties{
+		"lpmp": {"sensorIds": testSensorID, paramTableVersionKey: "v2"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// 模拟传感器按其参数表版本编码
+	v2, err := s.Monitoring(frameparser.ParamValue{Type: waterLevelParam, Value: uint16(150)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.send(s, v2)
+	h.waitFor("v2 读数", func() bool { return published() == 1 })
+	if got := waterLevelValue("water-level"); got != uint16(150) {
+		t.Errorf("v2 water-level=%#v，期望 uint16(150)", got)
+	}
+
+	// 去掉 paramTableVersion 后恢复内置参数表
+	if err := h.d.applyDeviceProtocols(testWaterLevel, map[string]models.ProtocolProperties{
+		"lpmp": {"sensorIds": testSensorID},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	h.send(s, v1)
+	h.waitFor("v1 读数", func() bool { return published() == 2 })
+	if got := waterLevelValue("water-level"); got != float32(1.5) {
+		t.Errorf("v1 water-level=%#v，期望 float32(1.5)", got)
+	}
+	if got := parseErrors(); got != 0 {
+		t.Errorf("解析错误 %v", frameparser.SnapshotStats().ParseErrors)
+	}
+}
+
+func TestHarnessRawParamWrite(t *testing.T) {
+	h := newHarness(t)
+	s := h.sensor()
+	if err := h.d.applyDeviceProtocols(testWaterLevel, map[string]models.ProtocolProperties{
+		"lpmp": {"sensorIds": testSensorID, commandTimeoutKey: "200ms", commandRetriesKey: "0"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	write := func(body string) error {
+		reqs := []dsModels.CommandRequest{{DeviceResourceName: "rawParamWrite", Attributes: map[string]any{rawParamAttr: true}}}
+		values := []*dsModels.CommandValue{{DeviceResourceName: "rawParamWrite", Value: body}}
+		return h.d.HandleWriteCommands(testWaterLevel, nil, reqs, values)
+	}
+
+	// 格式错误在入队前拒绝，不下发
+	for _, body := range []string{
+		`not json`,
+		`{"paramType": "0x4000", "hex": "41200000"}`,
+		`{"paramType": "0x00A3", "lenFlag": 0, "hex": "4120"}`,
+		`{"paramType": "0x00A3", "lenFlag": 4, "hex": "41200000"}`,
+		`{"paramType": "0x00A3", "hex": "xyz"}`,
+	} {
+		if err := write(body); err == nil {
+			t.Errorf("%s 未被拒绝", body)
+		}
+	}
+	if got := h.link.downlink(); got != "" {
+		t.Fatalf("无效写入产生了下行 %q", got)
+	}
+
+	// 按指定的 LengthFlag 编码：4 字节数据用 1 字节长度字段
+	if err := write(`{"paramType": "0x00A3", "lenFlag": 1, "hex": "41 20 00 00"}`); err != nil {
+		t.Fatal(err)
+	}
+	frame, err := frameparser.BuildRawParamSetFrame(s.ID, frameparser.Param{Type: waterLevelParam, Data: []byte{0x41, 0x20, 0, 0}}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := serial.FormatDTXCommand(frame)
+	h.waitFor("原始参量下发", func() bool { return strings.Contains(h.link.downlink(), want) })
+	if auto, _ := frameparser.BuildParamSetFrame(s.ID, []frameparser.Param{{Type: waterLevelParam, Data: []byte{0x41, 0x20, 0, 0}}}); bytes.Equal(auto, frame) {
+		t.Error("指定 LengthFlag=1 与自动选择的编码相同")
+	}
+}
+
+func TestHarnessCommandLatency(t *testing.T) {
+	h := newHarness(t)
+	s := h.sensor()
+	if err := h.d.applyDeviceProtocols(testWaterLevel, map[string]models.ProtocolProperties{
+		"lpmp": {"sensorIds": testSensorID, commandTimeoutKey: "2s", commandRetriesKey: "0"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟传感器：收到参数设置 20ms 后确认
+	set, err := frameparser.BuildParamSetFrame(s.ID, []frameparser.Param{{Type: waterLevelParam, Data: []byte{0x41, 0x20, 0, 0}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		want := serial.FormatDTXCommand(set)
+		deadline := time.Now().Add(5 * time.Second)
+		for !strings.Contains(h.link.downlink(), want) {
+			if time.Now().After(deadline) {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(20 * time.Millisecond)
+		_, _ = h.w.Write(s.Line(s.ControlResponse(frameparser.CtrlTypeGeneralParams, frameparser.ControlStatusSuccess)))
+	}()
+	reqs := []dsModels.CommandRequest{{DeviceResourceName: "rawParamWrite", Attributes: map[string]any{rawParamAttr: true}}}
+	values := []*dsModels.CommandValue{{DeviceResourceName: "rawParamWrite", Value: `{"paramType": "0x00A3", "hex": "41200000"}`}}
+	if err := h.d.HandleWriteCommands(testWaterLevel, nil, reqs, values); err != nil {
+		t.Fatal(err)
+	}
+
+	// 确认事件带往返时延
+	var confirmed map[string]any
+	h.waitFor("写入确认", func() bool {
+		for _, ev := range h.sdk.Events(writeEventType) {
+			if ev.Action == writeStateConfirmed {
+				confirmed, _ = ev.Details.(map[string]any)
+				return true
+			}
+		}
+		return false
+	})
+	if ms, _ := confirmed["latencyMs"].(float64); ms < 20 {
+		t.Errorf("确认事件 latencyMs=%v，期望不少于 20", confirmed["latencyMs"])
+	}
+
+	// 网关时延资源
+	res, err := h.d.HandleReadCommands(testGateway, nil, []dsModels.CommandRequest{
+		{DeviceResourceName: commandLatencyP95Resource},
+		{DeviceResourceName: commandLatencyResource},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p95, _ := res[0].Value.(float32); p95 < 20 {
+		t.Errorf("%s=%v，期望不少于 20", commandLatencyP95Resource, res[0].Value)
+	}
+	var snap latencySnapshot
+	if err := json.Unmarshal([]byte(res[1].Value.(string)), &snap); err != nil {
+		t.Fatal(err)
+	}
+	if st := snap.Sensors[testSensorID]; st.Samples != 1 || st.P50Ms < 20 || snap.All.Samples != 1 {
+		t.Errorf("时延明细 %+v", snap)
+	}
+
+	// resetStats 清空
+	h.gatewayBoolWrite(resetStatsResource)
+	if got := h.d.latency.snapshot(); got.All.Samples != 0 || len(got.Sensors) != 0 {
+		t.Errorf("resetStats 后仍有时延记录 %+v", got)
+	}
+}
+
+func TestHarnessLowBattery(t *testing.T) {
+	h := newHarnessConfig(t, map[string]string{battery.KeyLowLevels: "10,20", battery.KeyLowVoltage: "3.3"})
+	s := h.sensor()
+	const batteryParam, voltageParam = 0x0002, 0x0003
+	lows := func() []map[string]any {
+		var out []map[string]any
+		for _, ev := range h.sdk.Events(batteryEventType) {
+			d, _ := ev.Details.(map[string]any)
+			out = append(out, d)
+		}
+		return out
+	}
+	send := func(pv frameparser.ParamValue) {
+		t.Helper()
+		n := published()
+		frame, err := s.Monitoring(pv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.send(s, frame)
+		h.waitFor("读数", func() bool { return published() == n+1 })
+	}
+
+	// 30% 未到门限；15% 跌破 20；一小时内的读数不估算剩余天数
+	send(frameparser.ParamValue{Type: batteryParam, Value: uint16(30)})
+	send(frameparser.ParamValue{Type: batteryParam, Value: uint16(15)})
+	if got := lows(); len(got) != 1 || got[0]["threshold"] != float64(20) || got[0]["source"] != battery.SourceLevel {
+		t.Fatalf("低电量事件 %v", got)
+	}
+	if got := waterLevelValue(estimatedDaysRemainingResource); got != float32(-1) {
+		t.Errorf("%s=%#v，期望 -1", estimatedDaysRemainingResource, got)
+	}
+	// 同一门限不重复报；跌破 10 再报一次
+	send(frameparser.ParamValue{Type: batteryParam, Value: uint16(14)})
+	send(frameparser.ParamValue{Type: batteryParam, Value: uint16(9)})
+	if got := lows(); len(got) != 2 || got[1]["threshold"] != float64(10) {
+		t.Fatalf("低电量事件 %v", got)
+	}
+
+	// 电压低于 BatteryLowVoltage
+	send(frameparser.ParamValue{Type: voltageParam, Value: float32(3.1)})
+	send(frameparser.ParamValue{Type: voltageParam, Value: float32(3.0)})
+	if got := lows(); len(got) != 3 || got[2]["source"] != battery.SourceVoltage {
+		t.Fatalf("低电压事件 %v", got)
+	}
+}
+
+func TestHarnessCompleteness(t *testing.T) {
+	h := newHarness(t)
+	s := h.sensor()
+	if err := h.d.applyDeviceProtocols(testWaterLevel, map[string]models.ProtocolProperties{
+		"lpmp": {"sensorIds": testSensorID, reportIntervalKey: "10m"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// 一帧两个参量计一次上报
+	n := published()
+	frame, err := s.Monitoring(
+		frameparser.ParamValue{Type: waterLevelParam, Value: float32(1.5)},
+		frameparser.ParamValue{Type: 0x0002, Value: uint16(80)},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.send(s, frame)
+	h.waitFor("读数", func() bool { return published() == n+1 })
+	series, ok := h.d.completeness.series(testWaterLevel, time.Now())
+	if !ok || series.Hourly[len(series.Hourly)-1].Received != 1 || series.Daily[len(series.Daily)-1].Received != 1 {
+		t.Fatalf("完整率序列 %+v", series)
+	}
+
+	// 刚开始统计，尚无完整的时段
+	res, err := h.d.HandleReadCommands(testWaterLevel, nil, []dsModels.CommandRequest{
+		{DeviceResourceName: completenessHourlyResource},
+		{DeviceResourceName: completenessDailyResource},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res[0].Value != float32(-1) || res[1].Value != float32(-1) {
+		t.Errorf("完整率资源 %v / %v，期望 -1", res[0].Value, res[1].Value)
+	}
+
+	// 去掉 reportInterval 后不再统计
+	if err := h.d.applyDeviceProtocols(testWaterLevel, map[string]models.ProtocolProperties{"lpmp": {"sensorIds": testSensorID}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := h.d.completeness.series(testWaterLevel, time.Now()); ok {
+		t.Error("未配置 reportInterval 时仍在统计")
+	}
+}
+
+func TestHarnessSpill(t *testing.T) {
+	h := newHarnessConfig(t, map[string]string{spill.KeyEnabled: "true", spill.KeyDir: t.TempDir()})
+	s := h.sensor()
+
+	// core-data 停止消费：异步通道被占满
+	ch := h.sdk.AsyncValuesChannel()
+	for len(ch) < cap(ch) {
+		ch <- &dsModels.AsyncValues{DeviceName: "filler"}
+	}
+
+	// 告警锁存读数进入溢出队列而不是丢弃
+	frame, err := s.Alarm(frameparser.ParamValue{Type: waterLevelParam, Value: float32(420)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.send(s, frame)
+	h.waitFor("告警读数写入溢出队列", func() bool { return h.d.spill.edgex.Len() == 1 })
+	origin := time.Now().Add(-time.Minute).UnixNano()
+	f32, _ := dsModels.NewCommandValueWithOrigin("water-level", common.ValueTypeFloat32, float32(1.25), origin)
+	bin, _ := dsModels.NewCommandValueWithOrigin("blob", common.ValueTypeBinary, []byte{0, 1, 0xFF}, origin)
+	arr, _ := dsModels.NewCommandValueWithOrigin("codes", common.ValueTypeUint16Array, []uint16{7, 65535}, origin)
+	f32.Tags = map[string]string{"sensorId": testSensorID}
+	if !h.d.pushAsync(&dsModels.AsyncValues{DeviceName: testWaterLevel, SourceName: "mixed", CommandValues: []*dsModels.CommandValue{f32, bin, arr}}, pushMonitoring) {
+		t.Fatal("溢出队列启用时读数被丢弃")
+	}
+	res, err := h.d.HandleReadCommands(testGateway, nil, []dsModels.CommandRequest{{DeviceResourceName: spillPendingResource}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res[0].Value != uint32(2) {
+		t.Errorf("%s=%v，期望 2", spillPendingResource, res[0].Value)
+	}
+	if h.d.async.shed.Load() != 0 {
+		t.Errorf("async-shed=%d，期望 0", h.d.async.shed.Load())
+	}
+
+	// core-data 恢复：按原顺序、原类型和 Origin 重新发布
+	for len(ch) > 0 {
+		<-ch
+	}
+	var replayed []*dsModels.AsyncValues
+	h.waitFor("回放溢出的读数", func() bool {
+		select {
+		case av := <-ch:
+			replayed = append(replayed, av)
+		default:
+		}
+		return len(replayed) == 2
+	})
+	if replayed[0].SourceName != alarmLatchedResource || replayed[0].CommandValues[0].Value != true {
+		t.Errorf("第一条回放 %s %v", replayed[0].SourceName, replayed[0].CommandValues)
+	}
+	got := replayed[1].CommandValues
+	if replayed[1].SourceName != "mixed" || len(got) != 3 ||
+		got[0].Value != float32(1.25) || got[0].Origin != origin || got[0].Tags["sensorId"] != testSensorID ||
+		!bytes.Equal(got[1].Value.([]byte), []byte{0, 1, 0xFF}) ||
+		!reflect.DeepEqual(got[2].Value, []uint16{7, 65535}) {
+		t.Errorf("第二条回放 %s %v", replayed[1].SourceName, got)
+	}
+	if n := h.d.spill.edgex.Len(); n != 0 {
+		t.Errorf("回放后仍积压 %d 条", n)
+	}
+}
+
+func TestListenerLifecycle(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+	s, err := simulator.NewSensor(testSensorID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := s.Line(s.Heartbeat())
+
+	// 没有消费者时监听协程阻塞在发送上，取消 ctx 后退出，frameCh 不被关闭
+	frameCh := make(chan serial.RxFrame)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := serial.ListenDRX(ctx, r, frameCh)
+	if _, err := w.Write(line); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("监听结束原因 %v，期望 context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("取消后监听协程未退出")
+	}
+
+	// 同一 frameCh 交给新的监听协程继续使用
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+	done2 := serial.ListenDRX(ctx2, r, frameCh)
+	go func() { _, _ = w.Write(line) }()
+	select {
+	case f := <-frameCh:
+		if len(f.Data) == 0 {
+			t.Error("收到空帧")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("新的监听协程未推送帧")
+	}
+
+	// 流水线以 ctx 取消退出，不依赖输入关闭
+	p := frameparser.NewPipeline(frameparser.PipelineOptions{Name: "lifecycle", Input: make(chan serial.RxFrame)})
+	pctx, pcancel := context.WithCancel(context.Background())
+	p.Start(pctx)
+	sub := p.SubscribeFrames("lifecycle", frameparser.FrameSubscribeOptions{})
+	pcancel()
+	select {
+	case <-p.Done():
+	case <-time.After(time.Second):
+		t.Fatal("取消后流水线未退出")
+	}
+	// 流水线退出后 SDU 订阅随之关闭
+	select {
+	case _, ok := <-sub.Frames():
+		if ok {
+			t.Error("流水线退出后订阅仍收到 SDU")
+		}
+	case <-time.After(time.Second):
+		t.Error("流水线退出后 SDU 订阅未关闭")
+	}
+
+	// EOF 作为结束原因返回，frameCh 仍由调用方所有
+	w.Close()
+	if err := <-done2; !errors.Is(err, io.EOF) {
+		t.Errorf("监听结束原因 %v，期望 io.EOF", err)
+	}
+	select {
+	case _, ok := <-frameCh:
+		if !ok {
+			t.Error("监听协程关闭了 frameCh")
+		}
+	default:
+	}
+}
+
+func TestReaderInvalidDataTimeout(t *testing.T) {
+	r, w := io.Pipe()
+	defer r.Close()
+	s, err := simulator.NewSensor(testSensorID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	garbage := []byte("\x00\xff\x13garbage\r\n")
+	dr := serial.NewDRXReaderOptions(r, serial.ReaderOptions{InvalidDataTimeout: 100 * time.Millisecond})
+
+	// 链路安静时不计时：安静超过 InvalidDataTimeout 后的一行乱码不触发，随后的有效帧照常返回
+	go func() {
+		time.Sleep(150 * time.Millisecond)
+		_, _ = w.Write(garbage)
+		_, _ = w.Write(s.Line(s.Heartbeat()))
+	}()
+	if _, err := dr.ReadFrame(); err != nil {
+		t.Fatalf("安静之后读取失败: %v", err)
+	}
+
+	// 持续输出乱码超过 InvalidDataTimeout 时返回 ErrInvalidDataTimeout
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				w.Close()
+				return
+			default:
+			}
+			if _, err := w.Write(garbage); err != nil {
+				return
+			}
+		}
+	}()
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := dr.ReadFrame()
+		errCh <- err
+	}()
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, serial.ErrInvalidDataTimeout) {
+			t.Errorf("读取结束原因 %v，期望 ErrInvalidDataTimeout", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("持续乱码未触发 ErrInvalidDataTimeout")
+	}
+}
+
+// TestHarnessInstanceSettings 同一进程中两个驱动实例同时运行，各自的默认方言和乱序片段上限互不影响
+func TestHarnessInstanceSettings(t *testing.T) {
+	ccitt := newHarnessConfig(t, map[string]string{defaultDialectKey: "crc=ccitt", maxOutOfOrderPerServiceKey: "1"})
+	standard := newHarness(t)
+	dialect, err := frameparser.ParseDialect("crc=ccitt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	subs := make(map[*harness]*frameparser.FrameSubscription)
+	for _, h := range []*harness{ccitt, standard} {
+		subs[h] = h.d.pipelines[linkRolePrimary].SubscribeFrames("test", frameparser.FrameSubscribeOptions{Buffer: 8, DropWhenFull: true})
+	}
+	received := func(h *harness) bool {
+		t.Helper()
+		select {
+		case <-subs[h].Frames():
+			return true
+		case <-time.After(200 * time.Millisecond):
+			return false
+		}
+	}
+	s := ccitt.sensor()
+	// reframe 把标准帧改为 CRC-CCITT 校验
+	reframe := func(frame []byte) []byte {
+		return dialect.Seal(append([]byte(nil), frame[:len(frame)-2]...))
+	}
+
+	fragments := func(n int) [][]byte {
+		t.Helper()
+		params := make([]frameparser.ParamValue, n)
+		for i := range params {
+			params[i] = frameparser.ParamValue{Type: waterLevelParam, Value: float32(i)}
+		}
+		frames, err := s.MonitoringFragments(n, params...)
+		if err != nil || len(frames) != n {
+			t.Fatalf("切成 %d 片: %d 片, %v", n, len(frames), err)
+		}
+		return frames
+	}
+
+	// 标准 CRC 的分片只有标准方言的实例拼接，CRC-CCITT 的分片只有 ccitt 实例拼接
+	for _, f := range fragments(2) {
+		ccitt.send(s, f)
+		standard.send(s, f)
+	}
+	if received(ccitt) || !received(standard) {
+		t.Error("标准帧未按各实例的默认方言校验")
+	}
+	for _, f := range fragments(2) {
+		ccitt.send(s, reframe(f))
+		standard.send(s, reframe(f))
+	}
+	if !received(ccitt) || received(standard) {
+		t.Error("CRC-CCITT 帧未按各实例的默认方言校验")
+	}
+
+	// 首片之后两个超前片段：上限为 1 的实例回收最早的一个，另一实例两个都暂存
+	frames := fragments(4)
+	for _, i := range []int{0, 2, 3} {
+		ccitt.send(s, reframe(frames[i]))
+		standard.send(s, frames[i])
+	}
+	budgets := map[*harness]*frameparser.OutOfOrderBudget{}
+	for _, h := range []*harness{ccitt, standard} {
+		budgets[h] = h.d.reassembly.OutOfOrderBudget
+	}
+	if budgets[ccitt] == budgets[standard] {
+		t.Fatal("两个实例共用了乱序片段预算")
+	}
+	standard.waitFor("两个超前片段暂存", func() bool { return budgets[standard].Buffered() == 2 })
+	ccitt.waitFor("超出上限的片段被回收", func() bool {
+		return budgets[ccitt].Buffered() == 1 && frameparser.DropCounts()[frameparser.DropOutOfOrderEvicted] == 1
+	})
+}
+
+// readsDuringRoundTrip 在 write 等待传感器应答期间（下行已写出，即 sent 成立）执行读命令：读命令应立即返回，
+// 不被写命令阻塞；返回 write 的结果
+func (h *harness) readsDuringRoundTrip(sent func(downlink string) bool, write func() error) error {
+	h.t.Helper()
+	errCh := make(chan error, 1)
+	go func() { errCh <- write() }()
+	h.waitFor("下行报文写出", func() bool { return sent(h.link.downlink()) })
+
+	start := time.Now()
+	reqs := []dsModels.CommandRequest{{DeviceResourceName: groupTargetResource}}
+	if _, err := h.d.HandleReadCommands(testGateway, nil, reqs); err != nil {
+		h.t.Errorf("读命令失败: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		h.t.Errorf("读命令等待写命令的应答 %s", elapsed)
+	}
+	select {
+	case err := <-errCh:
+		h.t.Fatalf("读命令返回前写命令已结束: %v", err)
+	default:
+	}
+	select {
+	case err := <-errCh:
+		return err
+	case <-time.After(5 * time.Second):
+		h.t.Fatal("写命令未结束")
+		return nil
+	}
+}
+
+// TestHarnessAckAlarmUnlocked 告警确认等待传感器应答期间不阻塞读命令
+func TestHarnessAckAlarmUnlocked(t *testing.T) {
+	const ctrlType = 0x10
+	h := newHarnessConfig(t, map[string]string{alarmAckCtrlTypeKey: strconv.Itoa(ctrlType)})
+	s := h.sensor()
+	if err := h.d.applyDeviceProtocols(testWaterLevel, map[string]models.ProtocolProperties{
+		"lpmp": {"sensorIds": testSensorID, commandTimeoutKey: "500ms", commandRetriesKey: "0"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ack, err := frameparser.BuildControlFrame(s.ID, ctrlType, 1, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := serial.FormatDTXCommand(ack)
+	err = h.readsDuringRoundTrip(func(downlink string) bool { return strings.Contains(downlink, want) }, func() error {
+		return h.d.HandleWriteCommands(testWaterLevel, nil,
+			[]dsModels.CommandRequest{{DeviceResourceName: ackAlarmResource}},
+			[]*dsModels.CommandValue{{DeviceResourceName: ackAlarmResource, Value: true}})
+	})
+	if !errors.Is(err, correlation.ErrTimeout) {
+		t.Errorf("传感器不应答时 ackAlarm 返回 %v，期望超时", err)
+	}
+}
+
+// TestHarnessGroupWriteUnlocked 分组下发等待传感器应答期间不阻塞读命令
+func TestHarnessGroupWriteUnlocked(t *testing.T) {
+	h := newHarness(t)
+	if err := h.d.applyDeviceProtocols(testWaterLevel, map[string]models.ProtocolProperties{
+		"lpmp": {"sensorIds": testSensorID, groupsKey: "tank", commandTimeoutKey: "500ms", commandRetriesKey: "0"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	err := h.readsDuringRoundTrip(func(downlink string) bool { return strings.Contains(downlink, testSensorID) }, func() error {
+		return h.d.HandleWriteCommands(testGateway, nil,
+			[]dsModels.CommandRequest{{DeviceResourceName: groupTargetResource}, {DeviceResourceName: groupTimeSyncResource}},
+			[]*dsModels.CommandValue{{DeviceResourceName: groupTargetResource, Value: "tank"}, {DeviceResourceName: groupTimeSyncResource, Value: true}})
+	})
+	if err != nil {
+		t.Fatalf("分组校时: %v", err)
+	}
+	if got := groupMissing(h); got != testSensorID {
+		t.Errorf("group-missing=%q，期望 %s", got, testSensorID)
+	}
+}
+
+// groupMissing 返回网关设备 group-missing 资源的值
+func groupMissing(h *harness) string {
+	values, _ := config.GetDeviceValues(testGateway)
+	s, _ := values[groupMissingResource].(string)
+	return s
+}
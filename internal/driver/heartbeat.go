@@ -0,0 +1,56 @@
+package driver
+
+import (
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+	"github.com/linjuya-lu/device-lpmp-go/internal/downlink"
+	"github.com/linjuya-lu/device-lpmp-go/internal/frameparser"
+	"github.com/linjuya-lu/device-lpmp-go/internal/trace"
+)
+
+// heartbeatCountResource 设备上记录已应答心跳次数的资源
+const heartbeatCountResource = "heartbeatCount"
+
+// handleHeartbeat 收到传感器心跳后下发心跳响应，并累加所属设备的 heartbeatCount。
+// 同一传感器绑定多个设备时只应答一次，任一设备开启 heartbeatResponse 即应答。
+func (d *LpMpDriver) handleHeartbeat(id trace.ID, sensorID string) {
+	var devices []string
+	for _, b := range config.LookupSensorBindings(sensorID) {
+		if d.deviceOptionsFor(b.DeviceName).HeartbeatResponse {
+			devices = append(devices, b.DeviceName)
+		}
+	}
+	// 只监听模式下不应答，也不计数
+	if len(devices) == 0 || d.listenOnly {
+		return
+	}
+
+	port := d.currentPort()
+	if port == nil {
+		d.lc.Debugf("[trace=%s] 链路未连通，无法应答 SensorID=%s 的心跳", id, sensorID)
+		return
+	}
+	sid, err := frameparser.ParseSensorID(sensorID)
+	if err != nil {
+		d.lc.Errorf("[trace=%s] %v", id, err)
+		return
+	}
+	// 心跳在解析协程中同步回调：应答异步入队，不等待发送结果，也不因发射预算推迟
+	done := d.downlink.Submit(downlink.Job{
+		Priority: d.downlinkPrios[cmdHeartbeat],
+		Writer:   port,
+		Frame:    frameparser.BuildHeartbeatResponse(sid),
+		NoDefer:  true,
+		Target:   sensorID,
+	})
+	go func() {
+		if err := <-done; err != nil {
+			d.lc.Warnf("[trace=%s] 应答 SensorID=%s 的心跳失败: %v", id, sensorID, err)
+			return
+		}
+		for _, dev := range devices {
+			if n, ok := config.IncDeviceCounter(dev, heartbeatCountResource); ok {
+				d.lc.Debugf("[trace=%s] 已应答 %s 的心跳，累计 %d 次", id, dev, n)
+			}
+		}
+	}()
+}
@@ -0,0 +1,30 @@
+package driver
+
+import (
+	"net/http"
+
+	"github.com/edgexfoundry/device-sdk-go/v4/pkg/interfaces"
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/common"
+	"github.com/labstack/echo/v4"
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+)
+
+// historyRoute 查询降采样资源的原始读数：GET ?device=<设备名>&resource=<资源名>
+const historyRoute = common.ApiBase + "/lpmp/history"
+
+// registerHistoryRoute 在 SDK 内置的 Web 服务上注册原始读数历史接口
+func (d *LpMpDriver) registerHistoryRoute() error {
+	return d.sdk.AddCustomRoute(historyRoute, interfaces.Authenticated, func(c echo.Context) error {
+		if d.sampler == nil {
+			return c.String(http.StatusServiceUnavailable, "降采样未启动")
+		}
+		device, resource := c.QueryParam("device"), c.QueryParam("resource")
+		if device == "" || resource == "" {
+			return c.String(http.StatusBadRequest, "需指定 device 和 resource")
+		}
+		if !config.HasDeviceResource(device, resource) {
+			return c.String(http.StatusNotFound, "设备 "+device+" 没有资源 "+resource)
+		}
+		return c.JSON(http.StatusOK, d.sampler.History(device, resource))
+	}, http.MethodGet)
+}
@@ -0,0 +1,224 @@
+package driver
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+	"github.com/linjuya-lu/device-lpmp-go/internal/serial"
+)
+
+const (
+	// ingestPauseModeKey Driver 配置项：暂停接收期间的处理方式，buffer（默认）或 drop
+	ingestPauseModeKey = "IngestPauseMode"
+	// ingestPauseMaxKey Driver 配置项：暂停接收的最长时长，超时自动恢复，"0" 不限制
+	ingestPauseMaxKey = "IngestPauseMaxDuration"
+
+	defaultIngestPauseMax = 30 * time.Minute
+	// ingestDrainTimeout 暂停时等待已入队的帧解析完的最长时间
+	ingestDrainTimeout = 5 * time.Second
+
+	// 网关设备上的接收控制资源：写 true 暂停 / 恢复接收，读取暂停状态和暂停期间丢弃的帧数
+	pauseIngestResource   = "pause-ingest"
+	resumeIngestResource  = "resume-ingest"
+	ingestPausedResource  = "ingest-paused"
+	ingestDroppedResource = "ingest-dropped"
+
+	// 暂停 / 恢复接收时发布的 lpmp-link 事件
+	linkEventActionIngestPaused  = "ingest-paused"
+	linkEventActionIngestResumed = "ingest-resumed"
+)
+
+// ingestPauseMode 暂停接收期间如何处理模组上报的帧
+type ingestPauseMode string
+
+const (
+	// ingestPauseBuffer 不再读取链路，帧留在模组和串口（或 TCP）缓冲区中，恢复后照常解析；
+	// 缓冲区满后模组自行丢弃，暂停期间也无法发现链路断开
+	ingestPauseBuffer ingestPauseMode = "buffer"
+	// ingestPauseDrop 照常读取链路，帧直接丢弃并计数
+	ingestPauseDrop ingestPauseMode = "drop"
+)
+
+// ingestStats snapshotStats 中的接收控制状态
+type ingestStats struct {
+	Paused  bool            `json:"paused"`
+	Mode    ingestPauseMode `json:"mode"`
+	Since   *time.Time      `json:"since,omitempty"`
+	Dropped uint64          `json:"dropped"`
+}
+
+// ingestGate 位于各链路的 DRX 监听和解析流水线之间，维护时段（如重新加载 profile）暂停接收，
+// 避免配置替换与正在进行的解析交错
+type ingestGate struct {
+	mode     ingestPauseMode
+	maxPause time.Duration
+
+	mu     sync.Mutex
+	paused bool
+	since  time.Time
+	// resumed 暂停时创建，恢复时关闭
+	resumed chan struct{}
+	timer   *time.Timer
+	// outs 各流水线的输入通道，暂停时等待其排空
+	outs []chan serial.RxFrame
+
+	dropped atomic.Uint64 // 启动（或 resetStats）以来 drop 模式下丢弃的帧数
+}
+
+// ingestConfig 读取暂停接收的处理方式和最长时长
+func ingestConfig(cfg map[string]string) (ingestPauseMode, time.Duration, error) {
+	mode := ingestPauseBuffer
+	switch v := ingestPauseMode(cfg[ingestPauseModeKey]); v {
+	case "":
+	case ingestPauseBuffer, ingestPauseDrop:
+		mode = v
+	default:
+		return "", 0, fmt.Errorf("%s 配置无效 %q（buffer / drop）", ingestPauseModeKey, v)
+	}
+	maxPause := defaultIngestPauseMax
+	if v := cfg[ingestPauseMaxKey]; v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return "", 0, fmt.Errorf("%s 配置无效 %q", ingestPauseMaxKey, v)
+		}
+		maxPause = d
+	}
+	return mode, maxPause, nil
+}
+
+// forward 把 in 中的帧转交给流水线输入 out，暂停期间按 mode 阻塞或丢弃，直到 stop 关闭
+func (g *ingestGate) forward(in <-chan serial.RxFrame, out chan<- serial.RxFrame, stop <-chan struct{}) {
+	for {
+		var f serial.RxFrame
+		select {
+		case <-stop:
+			return
+		case f = <-in:
+		}
+		g.mu.Lock()
+		paused, resumed := g.paused, g.resumed
+		g.mu.Unlock()
+		if paused {
+			if g.mode == ingestPauseDrop {
+				g.dropped.Add(1)
+				continue
+			}
+			// buffer：持有这一帧不再取下一帧，DRX 监听随之阻塞，链路不再被读取
+			select {
+			case <-stop:
+				return
+			case <-resumed:
+			}
+		}
+		select {
+		case <-stop:
+			return
+		case out <- f:
+		}
+	}
+}
+
+// pause 暂停接收并等待已入队的帧解析完；已暂停时返回 false
+func (g *ingestGate) pause(onTimeout func()) bool {
+	g.mu.Lock()
+	if g.paused {
+		g.mu.Unlock()
+		return false
+	}
+	g.paused, g.since, g.resumed = true, time.Now(), make(chan struct{})
+	if g.maxPause > 0 {
+		g.timer = time.AfterFunc(g.maxPause, onTimeout)
+	}
+	g.mu.Unlock()
+
+	deadline := time.Now().Add(ingestDrainTimeout)
+	for g.queued() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	return true
+}
+
+// resume 恢复接收，返回暂停时长；未暂停时返回 false
+func (g *ingestGate) resume() (time.Duration, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.paused {
+		return 0, false
+	}
+	if g.timer != nil {
+		g.timer.Stop()
+		g.timer = nil
+	}
+	close(g.resumed)
+	g.paused = false
+	return time.Since(g.since), true
+}
+
+// queued 返回各流水线输入中尚未解析的帧数
+func (g *ingestGate) queued() int {
+	n := 0
+	for _, ch := range g.outs {
+		n += len(ch)
+	}
+	return n
+}
+
+// stats 返回当前的接收控制状态
+func (g *ingestGate) stats() ingestStats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	st := ingestStats{Paused: g.paused, Mode: g.mode, Dropped: g.dropped.Load()}
+	if g.paused {
+		since := g.since
+		st.Since = &since
+	}
+	return st
+}
+
+// pauseIngest 网关 pauseIngest 命令：暂停接收，超过 IngestPauseMaxDuration 未恢复时自动恢复
+func (d *LpMpDriver) pauseIngest(deviceName string) error {
+	if d.ingest == nil {
+		return fmt.Errorf("驱动尚未启动，无法暂停接收")
+	}
+	if !d.ingest.pause(func() { d.resumeIngest(deviceName, "timeout") }) {
+		d.lc.Infof("接收已处于暂停状态")
+		return nil
+	}
+	config.SetDeviceValue(deviceName, ingestPausedResource, true)
+	d.lc.Warnf("已暂停接收（%s），%s", d.ingest.mode, pauseLimitText(d.ingest.maxPause))
+	d.publishLinkEvent(linkEventActionIngestPaused, map[string]any{"mode": string(d.ingest.mode), "maxDuration": d.ingest.maxPause.String()})
+	return nil
+}
+
+// resumeIngest 恢复接收；reason 为 command（网关命令）或 timeout（超过最长暂停时长）
+func (d *LpMpDriver) resumeIngest(deviceName, reason string) {
+	if d.ingest == nil {
+		return
+	}
+	paused, ok := d.ingest.resume()
+	if !ok {
+		return
+	}
+	dropped := d.ingest.dropped.Load()
+	config.SetDeviceValue(deviceName, ingestPausedResource, false)
+	config.SetDeviceValue(deviceName, ingestDroppedResource, uint32(dropped))
+	if reason == "timeout" {
+		d.lc.Warnf("暂停接收已达 %s，自动恢复", paused.Round(time.Second))
+	} else {
+		d.lc.Infof("已恢复接收，暂停了 %s", paused.Round(time.Second))
+	}
+	d.publishLinkEvent(linkEventActionIngestResumed, map[string]any{
+		"reason": reason, "pausedMs": paused.Milliseconds(), "dropped": dropped,
+	})
+}
+
+// pauseLimitText 返回用于日志的自动恢复说明
+func pauseLimitText(maxPause time.Duration) string {
+	if maxPause == 0 {
+		return "须以 resumeIngest 恢复"
+	}
+	return fmt.Sprintf("%s 后自动恢复", maxPause)
+}
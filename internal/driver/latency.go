@@ -0,0 +1,146 @@
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+)
+
+const (
+	// latencyWindowKey Driver 配置项：每个传感器参与时延分位数计算的最近往返次数
+	latencyWindowKey     = "LatencyWindow"
+	defaultLatencyWindow = 100
+
+	// 网关设备上的命令往返时延资源：全部传感器的 P50 / P95（ms）和按传感器的明细（JSON）
+	commandLatencyP50Resource = "command-latency-p50"
+	commandLatencyP95Resource = "command-latency-p95"
+	commandLatencyResource    = "command-latency"
+)
+
+// latencyStats 一组往返时延的统计，单位 ms
+type latencyStats struct {
+	Samples int     `json:"samples"`
+	P50Ms   float64 `json:"p50Ms"`
+	P95Ms   float64 `json:"p95Ms"`
+	LastMs  float64 `json:"lastMs"`
+}
+
+// latencySnapshot command-latency 资源和统计快照中的时延：全部传感器合计及按 SensorID 的明细
+type latencySnapshot struct {
+	All     latencyStats            `json:"all"`
+	Sensors map[string]latencyStats `json:"sensors"`
+}
+
+// latencyRing 最近 window 次往返时延，写满后覆盖最早的
+type latencyRing struct {
+	samples []time.Duration
+	next    int
+	last    time.Duration
+}
+
+func (r *latencyRing) add(d time.Duration, window int) {
+	r.last = d
+	if len(r.samples) < window {
+		r.samples = append(r.samples, d)
+		return
+	}
+	r.samples[r.next] = d
+	r.next = (r.next + 1) % window
+}
+
+// latencyTracker 记录下行报文发出到收到匹配应答的时间（不含下行排队），
+// 按传感器和全部传感器各保留最近 window 次，用于衡量无线网络质量；并发安全
+type latencyTracker struct {
+	window int
+
+	mu      sync.Mutex
+	all     latencyRing
+	sensors map[string]*latencyRing
+}
+
+// latencyConfig 读取时延窗口大小
+func latencyConfig(cfg map[string]string) (int, error) {
+	v := cfg[latencyWindowKey]
+	if v == "" {
+		return defaultLatencyWindow, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%s 配置无效 %q，应为正整数", latencyWindowKey, v)
+	}
+	return n, nil
+}
+
+func newLatencyTracker(window int) *latencyTracker {
+	return &latencyTracker{window: window, sensors: make(map[string]*latencyRing)}
+}
+
+// record 记录一次往返时延
+func (t *latencyTracker) record(sensorID string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.all.add(d, t.window)
+	r, ok := t.sensors[sensorID]
+	if !ok {
+		r = &latencyRing{}
+		t.sensors[sensorID] = r
+	}
+	r.add(d, t.window)
+}
+
+// reset 清空全部记录（resetStats）
+func (t *latencyTracker) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.all = latencyRing{}
+	t.sensors = make(map[string]*latencyRing)
+}
+
+// snapshot 计算当前的分位数
+func (t *latencyTracker) snapshot() latencySnapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	snap := latencySnapshot{All: t.all.stats(), Sensors: make(map[string]latencyStats, len(t.sensors))}
+	for sid, r := range t.sensors {
+		snap.Sensors[sid] = r.stats()
+	}
+	return snap
+}
+
+// stats 按最近邻秩法计算 P50 / P95
+func (r *latencyRing) stats() latencyStats {
+	n := len(r.samples)
+	if n == 0 {
+		return latencyStats{}
+	}
+	sorted := append([]time.Duration(nil), r.samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := func(p int) time.Duration { return sorted[(p*n+99)/100-1] }
+	return latencyStats{Samples: n, P50Ms: durationMs(rank(50)), P95Ms: durationMs(rank(95)), LastMs: durationMs(r.last)}
+}
+
+// durationMs 把时长换算为毫秒，保留 3 位小数
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// updateLatency 把当前的往返时延分位数写入网关设备的时延资源
+func (d *LpMpDriver) updateLatency(deviceName string) {
+	if d.latency == nil {
+		return
+	}
+	snap := d.latency.snapshot()
+	config.SetDeviceValue(deviceName, commandLatencyP50Resource, float32(snap.All.P50Ms))
+	config.SetDeviceValue(deviceName, commandLatencyP95Resource, float32(snap.All.P95Ms))
+	b, err := json.Marshal(snap)
+	if err != nil {
+		d.lc.Errorf("序列化往返时延失败: %v", err)
+		return
+	}
+	config.SetDeviceValue(deviceName, commandLatencyResource, string(b))
+}
@@ -0,0 +1,371 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/models"
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+	"github.com/linjuya-lu/device-lpmp-go/internal/frameparser"
+	"github.com/linjuya-lu/device-lpmp-go/internal/serial"
+)
+
+const (
+	// Driver 配置项
+	serialPortKey            = "SerialPort"
+	serialByIDKey            = "SerialByIdPattern"
+	serialModelKey           = "SerialModemModel"
+	serialUsbVidKey          = "SerialUsbVid"
+	serialUsbPidKey          = "SerialUsbPid"
+	serialBaudRateKey        = "SerialBaudRate"
+	serialRetryIntervalKey   = "SerialRetryInterval"
+	serialResponseTimeoutKey = "SerialResponseTimeout"
+	maxFrameSizeKey          = "MaxFrameSize"
+	linkInvalidDataKey       = "LinkInvalidDataTimeout"
+	linkReadTimeoutKey       = "LinkReadTimeout"
+	backupTransportKey       = "BackupTransport"
+	failoverThresholdKey     = "FailoverThreshold"
+	failbackIntervalKey      = "FailbackInterval"
+	gatewayDeviceKey         = "GatewayDeviceName"
+
+	defaultSerialBaudRate      = 115200
+	defaultSerialRetryInterval = 5 * time.Second
+	defaultFailoverThreshold   = 3
+	defaultFailbackInterval    = 30 * time.Second
+	defaultLinkInvalidData     = time.Minute
+
+	// linkStateResource 网关设备上表示串口链路是否连通的资源
+	linkStateResource = "link-state"
+
+	// 链路状态变化时发布的系统事件
+	linkEventType           = "lpmp-link"
+	linkEventActionUp       = "up"
+	linkEventActionDown     = "down"
+	linkEventActionFailover = "failover"
+	linkEventActionFailback = "failback"
+)
+
+// linkRole 标识当前使用的是主链路还是备用链路
+type linkRole string
+
+const (
+	linkRolePrimary linkRole = "primary"
+	linkRoleBackup  linkRole = "backup"
+)
+
+// label 返回用于日志的中文名称
+func (r linkRole) label() string {
+	if r == linkRoleBackup {
+		return "备用"
+	}
+	return "主"
+}
+
+// linkConfig 模组链路参数
+type linkConfig struct {
+	// Primary 主链路：本地串口，按固定端口名或 by-id / USB VID/PID 自动发现
+	Primary serial.Transport
+	// Backup 备用链路（如 ser2net 的 TCP 透传），为 nil 表示不启用切换
+	Backup            serial.Transport
+	RetryInterval     time.Duration
+	FailoverThreshold int
+	FailbackInterval  time.Duration
+	// ResponseTimeout 每条指令等待模组最终应答的时长，0 只保证写入互斥、不等待应答
+	ResponseTimeout time.Duration
+	// Reader 每条链路连接读取时的单帧上限、无效数据时长和读超时
+	Reader serial.ReaderOptions
+	// GatewayDevice 代表本地 LPMP 模组的设备名，为空则不维护网关状态
+	GatewayDevice string
+}
+
+// linkConfigFromDriver 从 Driver 配置读取链路参数，未配置的项使用默认值
+func linkConfigFromDriver(driverCfg map[string]string) (linkConfig, error) {
+	cfg := linkConfig{
+		RetryInterval:     defaultSerialRetryInterval,
+		FailoverThreshold: defaultFailoverThreshold,
+		FailbackInterval:  defaultFailbackInterval,
+		GatewayDevice:     driverCfg[gatewayDeviceKey],
+		Reader:            serial.ReaderOptions{MaxFrameSize: serial.DefaultMaxFrameSize, InvalidDataTimeout: defaultLinkInvalidData},
+	}
+	spec := serial.PortSpec{
+		Name:        serial.DefaultPortName,
+		ByIDPattern: driverCfg[serialByIDKey],
+		Model:       driverCfg[serialModelKey],
+		VID:         driverCfg[serialUsbVidKey],
+		PID:         driverCfg[serialUsbPidKey],
+	}
+	if v := driverCfg[serialPortKey]; v != "" {
+		spec.Name = v
+	}
+	if err := spec.Validate(); err != nil {
+		return cfg, err
+	}
+	baudRate := defaultSerialBaudRate
+	if v := driverCfg[serialBaudRateKey]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return cfg, fmt.Errorf("%s 配置无效 %q", serialBaudRateKey, v)
+		}
+		baudRate = n
+	}
+	cfg.Primary = serial.SerialTransport{Spec: spec, BaudRate: baudRate}
+
+	if v := driverCfg[backupTransportKey]; v != "" {
+		t, err := serial.ParseTransport(v, baudRate)
+		if err != nil {
+			return cfg, fmt.Errorf("%s 配置无效: %w", backupTransportKey, err)
+		}
+		cfg.Backup = t
+	}
+	if v := driverCfg[failoverThresholdKey]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return cfg, fmt.Errorf("%s 配置无效 %q", failoverThresholdKey, v)
+		}
+		cfg.FailoverThreshold = n
+	}
+	for key, dst := range map[string]*time.Duration{
+		serialRetryIntervalKey: &cfg.RetryInterval,
+		failbackIntervalKey:    &cfg.FailbackInterval,
+	} {
+		v := driverCfg[key]
+		if v == "" {
+			continue
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return cfg, fmt.Errorf("%s 配置无效 %q", key, v)
+		}
+		*dst = d
+	}
+	if v := driverCfg[maxFrameSizeKey]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return cfg, fmt.Errorf("%s 配置无效 %q", maxFrameSizeKey, v)
+		}
+		cfg.Reader.MaxFrameSize = n
+	}
+	// 以下各项可为 "0"（不等待应答 / 不检查无效数据 / 不设读超时）
+	for key, dst := range map[string]*time.Duration{
+		serialResponseTimeoutKey: &cfg.ResponseTimeout,
+		linkInvalidDataKey:       &cfg.Reader.InvalidDataTimeout,
+		linkReadTimeoutKey:       &cfg.Reader.ReadTimeout,
+	} {
+		v := driverCfg[key]
+		if v == "" {
+			continue
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return cfg, fmt.Errorf("%s 配置无效 %q", key, v)
+		}
+		*dst = d
+	}
+	return cfg, nil
+}
+
+// currentPort 返回已打开的串口，未连通时返回 nil
+func (d *LpMpDriver) currentPort() io.ReadWriteCloser {
+	d.portMu.RLock()
+	defer d.portMu.RUnlock()
+	return d.port
+}
+
+// setGatewayState 同步网关设备的 OperatingState 和 link-state 资源
+func (d *LpMpDriver) setGatewayState(up bool) {
+	name := d.link.GatewayDevice
+	if name == "" {
+		return
+	}
+	config.SetDeviceValue(name, linkStateResource, up)
+	state := models.OperatingState(models.Down)
+	if up {
+		state = models.Up
+	}
+	if err := d.sdk.UpdateDeviceOperatingState(name, state); err != nil {
+		d.lc.Warnf("更新网关设备 %s 状态为 %s 失败: %v", name, state, err)
+	}
+}
+
+// setPort 更新当前链路连接，nil 表示断开
+func (d *LpMpDriver) setPort(p io.ReadWriteCloser) {
+	d.portMu.Lock()
+	d.port = p
+	d.portMu.Unlock()
+}
+
+// publishLinkEvent 发布链路状态变化事件
+func (d *LpMpDriver) publishLinkEvent(action string, details map[string]any) {
+	d.sdk.PublishGenericSystemEvent(linkEventType, action, details)
+}
+
+// sleepOrStop 等待 dur，驱动停止时返回 false
+func (d *LpMpDriver) sleepOrStop(dur time.Duration) bool {
+	select {
+	case <-d.stopCh:
+		return false
+	case <-time.After(dur):
+		return true
+	}
+}
+
+// superviseLink 在后台维护模组链路，直到驱动停止：
+// 1. 循环打开当前链路（主链路为串口，每次尝试都重新发现端口），失败按间隔重试
+// 2. 主链路连续失败 FailoverThreshold 次且配置了备用链路时切换到备用链路
+// 3. 运行在备用链路上时每隔 FailbackInterval 探测主链路，恢复后切回
+// 4. 链路断开后网关置为 DOWN 并重新连接；连通后启动 DRX 监听、网关置为 UP
+// 每次连通、断开、切换都会发布 lpmp-link 事件。frameChs 为各链路解析流水线的输入。
+func (d *LpMpDriver) superviseLink(frameChs map[linkRole]chan<- serial.RxFrame) {
+	d.setGatewayState(false)
+	go func() {
+		active, role := d.link.Primary, linkRolePrimary
+		failures := 0
+		// pending 为探测主链路时已打开的连接，切回时直接使用
+		var pending io.ReadWriteCloser
+		var pendingAddr string
+
+		for {
+			// 1. 打开当前链路
+			conn, addr, err := pending, pendingAddr, error(nil)
+			pending = nil
+			if conn == nil {
+				conn, addr, err = active.Open()
+			}
+			if err != nil {
+				failures++
+				// 只在首次失败时告警，避免 USB 未插入时刷屏
+				if failures == 1 {
+					d.lc.Warnf("%s链路 %s 打开失败，将每 %s 重试: %v", role.label(), active, d.link.RetryInterval, err)
+				} else {
+					d.lc.Debugf("第 %d 次打开%s链路 %s 失败: %v", failures, role.label(), active, err)
+				}
+				// 2. 主链路持续失败，切换到备用链路；备用也持续失败则回到主链路
+				if d.link.Backup != nil && failures >= d.link.FailoverThreshold {
+					from, to := role, linkRoleBackup
+					if role == linkRoleBackup {
+						to = linkRolePrimary
+					}
+					d.lc.Warnf("%s链路连续 %d 次失败，切换到%s链路", from.label(), failures, to.label())
+					d.publishLinkEvent(linkEventActionFailover, map[string]any{"from": string(from), "to": string(to), "failures": failures})
+					if to == linkRoleBackup {
+						active = d.link.Backup
+					} else {
+						active = d.link.Primary
+					}
+					role, failures = to, 0
+					continue
+				}
+				if !d.sleepOrStop(d.link.RetryInterval) {
+					return
+				}
+				continue
+			}
+
+			// 连通：所有下行经 Commander 逐条写出，不同协程的指令不会交错；启动 DRX 监听，指令应答交给 Commander
+			failures = 0
+			port := serial.NewCommander(conn, d.link.ResponseTimeout)
+			d.setPort(port)
+			// frameChs 由流水线所有，监听协程不关闭；放弃本连接时取消 ctx，阻塞在发送上的监听协程随即退出
+			ctx, cancel := context.WithCancel(context.Background())
+			done := port.Listen(ctx, frameChs[role], d.link.Reader)
+			d.setGatewayState(true)
+			d.publishLinkEvent(linkEventActionUp, map[string]any{"transport": string(role), "address": addr})
+			d.lc.Infof("%s链路 %s 已连通", role.label(), addr)
+
+			// 3. 等待链路断开、驱动停止，或在备用链路上探测到主链路恢复
+			var probe <-chan time.Time
+			var ticker *time.Ticker
+			if role == linkRoleBackup {
+				ticker = time.NewTicker(d.link.FailbackInterval)
+				probe = ticker.C
+			}
+			var lost error
+		wait:
+			for {
+				select {
+				case <-d.stopCh:
+					if ticker != nil {
+						ticker.Stop()
+					}
+					d.setPort(nil)
+					cancel()
+					port.Close()
+					return
+				case lost = <-done:
+					break wait
+				case <-probe:
+					if c, a, err := d.link.Primary.Open(); err == nil {
+						pending, pendingAddr = c, a
+						break wait
+					}
+				}
+			}
+			if ticker != nil {
+				ticker.Stop()
+			}
+			d.setPort(nil)
+			cancel()
+			port.Close()
+
+			if pending != nil {
+				d.lc.Infof("主链路 %s 已恢复，从备用链路切回", pendingAddr)
+				d.publishLinkEvent(linkEventActionFailback, map[string]any{"from": string(role), "to": string(linkRolePrimary), "address": pendingAddr})
+				active, role = d.link.Primary, linkRolePrimary
+				continue
+			}
+
+			// 4. 链路断开，置 DOWN 后重连
+			d.lc.Warnf("%s链路 %s 断开: %v", role.label(), addr, lost)
+			d.setGatewayState(false)
+			d.publishLinkEvent(linkEventActionDown, map[string]any{"transport": string(role), "address": addr, "error": fmt.Sprint(lost)})
+			failures = 1
+			if !d.sleepOrStop(d.link.RetryInterval) {
+				return
+			}
+		}
+	}()
+}
+
+// lcLogger 把 EdgeX LoggingClient 适配为解析流水线的 Logger
+type lcLogger struct {
+	lc logger.LoggingClient
+}
+
+func (l lcLogger) Printf(format string, args ...any) {
+	l.lc.Infof(format, args...)
+}
+
+// startPipelines 为主链路和（已配置的）备用链路各启动一条解析流水线，返回各自经接收控制的输入通道
+func (d *LpMpDriver) startPipelines() map[linkRole]chan<- serial.RxFrame {
+	roles := []linkRole{linkRolePrimary}
+	if d.link.Backup != nil {
+		roles = append(roles, linkRoleBackup)
+	}
+	d.pipelines = make(map[linkRole]*frameparser.Pipeline, len(roles))
+	frameChs := make(map[linkRole]chan<- serial.RxFrame, len(roles))
+	for _, role := range roles {
+		ch := make(chan serial.RxFrame, 100)
+		p := frameparser.NewPipeline(frameparser.PipelineOptions{
+			Name:       string(role),
+			Input:      ch,
+			Sink:       d.sink,
+			Logger:     lcLogger{lc: d.lc},
+			Reassembly: d.reassembly,
+			Dialect:    d.dialect,
+			Heartbeat:  d.handleHeartbeat,
+			Alarm:      d.handleAlarm,
+		})
+		p.Start(context.Background())
+		d.pipelines[role] = p
+		// DRX 监听经接收控制转交给流水线，in 不带缓冲，buffer 模式暂停时监听立即阻塞
+		in := make(chan serial.RxFrame)
+		d.ingest.outs = append(d.ingest.outs, ch)
+		go d.ingest.forward(in, ch, d.stopCh)
+		frameChs[role] = in
+	}
+	return frameChs
+}
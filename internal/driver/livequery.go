@@ -0,0 +1,101 @@
+package driver
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	dsModels "github.com/edgexfoundry/device-sdk-go/v4/pkg/models"
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+	"github.com/linjuya-lu/device-lpmp-go/internal/correlation"
+	"github.com/linjuya-lu/device-lpmp-go/internal/downlink"
+	"github.com/linjuya-lu/device-lpmp-go/internal/frameparser"
+)
+
+const (
+	// liveQueryAttr 资源属性：为 true 时读取该资源会先向传感器下发监测数据查询
+	liveQueryAttr = "liveQuery"
+	// liveQueryTimeoutKey Driver 配置项：等待传感器应答的最长时间
+	liveQueryTimeoutKey     = "LiveQueryTimeout"
+	defaultLiveQueryTimeout = 5 * time.Second
+)
+
+// attrBool 将 profile attributes 中的布尔值（bool 或字符串）统一解析
+func attrBool(attrs map[string]interface{}, key string) bool {
+	switch v := attrs[key].(type) {
+	case bool:
+		return v
+	case string:
+		b, _ := strconv.ParseBool(v)
+		return b
+	}
+	return false
+}
+
+// needsLiveQuery 判断本次读请求中是否有资源要求实时查询
+func needsLiveQuery(reqs []dsModels.CommandRequest) bool {
+	for _, req := range reqs {
+		if attrBool(req.Attributes, liveQueryAttr) {
+			return true
+		}
+	}
+	return false
+}
+
+// liveQueryTimeout 从 Driver 配置读取实时查询超时时间
+func liveQueryTimeout(driverCfg map[string]string) (time.Duration, error) {
+	v := driverCfg[liveQueryTimeoutKey]
+	if v == "" {
+		return defaultLiveQueryTimeout, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%s 配置无效 %q", liveQueryTimeoutKey, v)
+	}
+	return d, nil
+}
+
+// liveQuery 向设备对应的全部传感器下发监测数据查询，并等待其上报新的监测数据。
+// 收到应答后解析协程已将最新值写入运行时值表，调用方直接读取即可。
+func (d *LpMpDriver) liveQuery(deviceName string, prio downlink.Priority) error {
+	port := d.currentPort()
+	if port == nil {
+		return fmt.Errorf("串口未打开")
+	}
+	sids := config.LookupSensorIDs(deviceName)
+	if len(sids) == 0 {
+		return fmt.Errorf("设备 %s 未配置 SensorID", deviceName)
+	}
+	frames := make([][]byte, len(sids))
+	for i, sid := range sids {
+		id, err := frameparser.ParseSensorID(sid)
+		if err != nil {
+			return err
+		}
+		if frames[i], err = frameparser.BuildMonitoringQueryFrame(id, nil); err != nil {
+			return err
+		}
+	}
+
+	// 各传感器并发查询，超时和重发次数按传感器所属设备的配置
+	errs := make([]error, len(sids))
+	var wg sync.WaitGroup
+	for i, sid := range sids {
+		wg.Add(1)
+		go func(i int, sid string) {
+			defer wg.Done()
+			key := correlation.Key{SensorID: sid, PacketType: frameparser.PacketTypeMonitoring}
+			if _, _, errs[i] = d.exchange(port, sid, key, frames[i], prio); errs[i] == nil {
+				d.lc.Debugf("%s(SensorID=%s) 已应答监测数据查询", deviceName, sid)
+			}
+		}(i, sid)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("SensorID %s: %w", sids[i], err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package driver
+
+import (
+	"fmt"
+	"time"
+
+	dsModels "github.com/edgexfoundry/device-sdk-go/v4/pkg/models"
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+	"github.com/linjuya-lu/device-lpmp-go/internal/correlation"
+	"github.com/linjuya-lu/device-lpmp-go/internal/frameparser"
+	"github.com/linjuya-lu/device-lpmp-go/internal/serial"
+)
+
+const (
+	// 网关设备 loopbackTest 命令读取的资源
+	loopbackPassedResource  = "loopback-passed"
+	loopbackLatencyResource = "loopback-latency"
+
+	// Driver 配置项：进入/退出模组回环模式的 AT 指令，为空表示模组已处于回环模式
+	loopbackEnableKey  = "LoopbackEnableCommand"
+	loopbackDisableKey = "LoopbackDisableCommand"
+)
+
+// isLoopbackRequest 判断读请求是否为自检命令
+func isLoopbackRequest(reqs []dsModels.CommandRequest) bool {
+	for _, req := range reqs {
+		if req.DeviceResourceName == loopbackPassedResource || req.DeviceResourceName == loopbackLatencyResource {
+			return true
+		}
+	}
+	return false
+}
+
+// runLoopback 下发一帧自检回环帧并等待其经 +DRX 回到解析协程，
+// 用于现场验证串口接线和模组配置，返回往返时延
+func (d *LpMpDriver) runLoopback() (time.Duration, error) {
+	port := d.currentPort()
+	if port == nil {
+		return 0, fmt.Errorf("串口未打开")
+	}
+	cfg := d.sdk.DriverConfigs()
+
+	// 1. 按需切换模组到回环模式，结束后恢复
+	if cmd := cfg[loopbackEnableKey]; cmd != "" {
+		if err := serial.WriteCommand(port, cmd); err != nil {
+			return 0, err
+		}
+	}
+	if cmd := cfg[loopbackDisableKey]; cmd != "" {
+		defer func() {
+			if err := serial.WriteCommand(port, cmd); err != nil {
+				d.lc.Errorf("退出回环模式失败: %v", err)
+			}
+		}()
+	}
+
+	// 2. 先登记再下发，nonce 用于识别本次自检的回环帧
+	nonce := uint32(time.Now().UnixNano())
+	req := correlation.Default.Expect(correlation.Key{SensorID: frameparser.LoopbackSensorID, PacketType: 0})
+	if err := serial.WriteFrame(port, frameparser.BuildLoopbackFrame(nonce)); err != nil {
+		correlation.Default.Cancel(req)
+		return 0, err
+	}
+
+	// 3. 等待回环帧并校验 nonce
+	res, err := correlation.Default.Wait(req, d.liveQueryTimeout)
+	if err != nil {
+		return 0, err
+	}
+	if got, _ := res.Payload.(uint32); got != nonce {
+		return 0, fmt.Errorf("回环帧内容不符: 期望 %08X, 收到 %08X", nonce, got)
+	}
+	return time.Since(req.Sent()), nil
+}
+
+// handleLoopback 执行自检并把结果写入网关设备的 loopback-* 资源
+func (d *LpMpDriver) handleLoopback(deviceName string) {
+	latency, err := d.runLoopback()
+	if err != nil {
+		d.lc.Warnf("设备 %s 回环自检失败: %v", deviceName, err)
+		config.SetDeviceValue(deviceName, loopbackPassedResource, false)
+		config.SetDeviceValue(deviceName, loopbackLatencyResource, float32(0))
+		return
+	}
+	d.lc.Infof("设备 %s 回环自检通过，往返时延 %s", deviceName, latency)
+	config.SetDeviceValue(deviceName, loopbackPassedResource, true)
+	config.SetDeviceValue(deviceName, loopbackLatencyResource, float32(latency.Seconds()*1000))
+}
@@ -9,7 +9,10 @@ package driver
 
 import (
 	"fmt"
-	"log"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -17,68 +20,671 @@ import (
 	dsModels "github.com/edgexfoundry/device-sdk-go/v4/pkg/models"
 	"github.com/edgexfoundry/go-mod-core-contracts/v4/clients/logger"
 	"github.com/edgexfoundry/go-mod-core-contracts/v4/models"
+	"github.com/linjuya-lu/device-lpmp-go/internal/accesslog"
+	"github.com/linjuya-lu/device-lpmp-go/internal/archive"
+	"github.com/linjuya-lu/device-lpmp-go/internal/battery"
+	"github.com/linjuya-lu/device-lpmp-go/internal/clockguard"
 	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+	"github.com/linjuya-lu/device-lpmp-go/internal/downlink"
+	"github.com/linjuya-lu/device-lpmp-go/internal/downsample"
+	"github.com/linjuya-lu/device-lpmp-go/internal/dutycycle"
 	"github.com/linjuya-lu/device-lpmp-go/internal/frameparser"
+	"github.com/linjuya-lu/device-lpmp-go/internal/mqttpub"
+	"github.com/linjuya-lu/device-lpmp-go/internal/objstore"
 	"github.com/linjuya-lu/device-lpmp-go/internal/serial"
+	"github.com/linjuya-lu/device-lpmp-go/internal/spill"
+	"github.com/linjuya-lu/device-lpmp-go/internal/spool"
+	"github.com/linjuya-lu/device-lpmp-go/internal/sseqstore"
+	"github.com/linjuya-lu/device-lpmp-go/internal/tlsconf"
+	"github.com/linjuya-lu/device-lpmp-go/internal/trace"
 )
 
 type LpMpDriver struct {
-	lc      logger.LoggingClient
-	asyncCh chan<- *dsModels.AsyncValues
-	locker  sync.Mutex
-	sdk     interfaces.DeviceServiceSDK
+	lc       logger.LoggingClient
+	asyncCh  chan<- *dsModels.AsyncValues
+	locker   sync.Mutex
+	sdk      interfaces.DeviceServiceSDK
+	mqttPub  *mqttpub.Publisher
+	archiver *archive.Archiver
+	// accessLog 控制报文访问审计日志，Start 之前为 nil
+	accessLog *accesslog.Log
+	spool     *spool.Spool
+	// objects 大 SDU 的对象存储，未启用时为 nil
+	objects *objstore.Store
+	// sseq 各传感器最近拼接完成的 SSEQ，用于丢弃重复的 SDU，未启用时为 nil
+	sseq *sseqstore.Store
+	// sampler 按资源降采样，Start 之前为 nil
+	sampler *downsample.Sampler
+	// clock 系统时钟检查，未启用时为 nil
+	clock *clockguard.Guard
+	// tls 各 TLS 连接（"transport"、"mqtt"）的证书加载器，Start 中写入后只读
+	tls map[string]*tlsconf.Loader
+
+	// port 为当前链路连接（串口或 TCP），用于下发控制报文；由链路协程写入，portMu 保护
+	port             io.ReadWriteCloser
+	portMu           sync.RWMutex
+	link             linkConfig
+	stopCh           chan struct{}
+	serviceConfig    *ServiceConfig
+	liveQueryTimeout time.Duration
+	// readingStaleAfter 读数超过该时长未更新时 quality 标为 stale，0 不判断；运行时可调，locker 保护
+	readingStaleAfter time.Duration
+	// listenOnly 只监听模式，不向空口下发任何报文
+	listenOnly bool
+
+	// pipelines 每条链路（主/备）各自的解析流水线，解析结果统一交给 sink
+	pipelines map[linkRole]*frameparser.Pipeline
+	// reassembly 各流水线拼接器的参数（超时、PSEQ 起点、乱序上限、SSEQ 去重、SDU 导出），
+	// 主/备链路的拼接器共享本实例的乱序片段预算
+	reassembly frameparser.ReassemblerOptions
+	// dialect 未在设备协议属性中声明方言的传感器使用的方言，nil 为标准格式
+	dialect *frameparser.Dialect
+	sink    frameparser.MultiSink
+
+	// duty 下行发射占空比预算，未启用时为 nil
+	duty *dutycycle.Budget
+	// downlink 下行优先级队列，downlinkPrios 为各命令类型的默认优先级
+	downlink      *downlink.Scheduler
+	downlinkPrios map[string]downlink.Priority
+
+	// writeQueue 待下发的传感器参数写入，由写入协程逐个处理
+	writeQueue chan queuedWrite
+
+	// deviceOpts 各设备协议属性中的驱动选项，devicesMu 保护
+	deviceOpts map[string]deviceOptions
+	devicesMu  sync.RWMutex
+
+	// pushes 各资源最近一次异步推送的时间，用于合并 AutoEvents 轮询（coalesceWindow）
+	pushes pushTracker
+	// async 异步读数通道过载保护
+	async asyncGuard
+	// alarms 各设备的告警历史；alarmAckCtrlType 为确认告警时下发的 CtrlType，0 不下发
+	alarms           alarmBook
+	alarmAckCtrlType uint8
+	// thresholds 数值资源的本地门限告警
+	thresholds *thresholdMonitor
+	// battery 电池放电估算和低电量判断，Start 之前为 nil
+	battery *battery.Monitor
+
+	// resDir 设备清单和 profile 所在目录，为空时使用 defaultResDir；单元测试中指向仓库的 cmd/res
+	resDir string
+	// configSnap 启动时加载的配置文件校验和与参数表摘要，Start 之前为 nil
+	configSnap *configSnapshot
+	// profilesDir 生效的 profile 目录，Start 时确定；不在设备清单中的设备从这里加载 profile
+	profilesDir string
+	// ingest 接收控制（pauseIngest / resumeIngest），Start 之前为 nil
+	ingest *ingestGate
+	// replay 抓包文件回补（replayCapture），Start 之前为 nil
+	replay *replayer
+	// latency 命令往返时延（command-latency-*），Start 之前为 nil
+	latency *latencyTracker
+	// spill 磁盘溢出队列，未启用 SpillEnabled 时为 nil
+	spill *spillQueues
+	// completeness 按设备 reportInterval 统计的数据完整率，Initialize 时创建
+	completeness *completenessTracker
+	// linkOverride 非空时替换配置的主链路，集成测试中接入内存管道
+	linkOverride serial.Transport
 }
 
-var once sync.Once
-var driver *LpMpDriver
+const (
+	// logThrottleIntervalKey Driver 配置项：重复解析错误日志的汇总周期
+	logThrottleIntervalKey = "LogThrottleInterval"
+	// readingStaleAfterKey Driver 配置项：读数超过该时长未更新时标记为 stale，"0" 不判断
+	readingStaleAfterKey = "ReadingStaleAfter"
+	// maxDecompressedSizeKey Driver 配置项：压缩报文解压后的最大字节数
+	maxDecompressedSizeKey = "MaxDecompressedSize"
+	// firstPSEQKey Driver 配置项：分片首片的 PSEQ，"auto" 表示以收到的首片为起点
+	firstPSEQKey = "FirstPSEQ"
+	// preFirstWindowKey Driver 配置项：首片之前到达的中间/尾片的暂存时长，"0" 不暂存
+	preFirstWindowKey = "PreFirstWindow"
+	// reassembleTimeoutKey Driver 配置项：分片拼接超时时间
+	reassembleTimeoutKey = "ReassembleTimeout"
+	// defaultDialectKey Driver 配置项：未声明 lpmp.dialect 的传感器使用的方言，格式同 lpmp.dialect
+	defaultDialectKey = "DefaultDialect"
+	// listenOnlyKey Driver 配置项：为 true 时只监听，不向空口下发任何报文
+	listenOnlyKey = "ListenOnly"
+	// Driver 配置项：乱序片段暂存上限，分别为单个 SDU 和整个服务合计
+	maxOutOfOrderPerSDUKey     = "MaxOutOfOrderPerSDU"
+	maxOutOfOrderPerServiceKey = "MaxOutOfOrderPerService"
+	// traceSpansKey Driver 配置项：为 true 时以 DEBUG 日志输出每帧各阶段 Span
+	traceSpansKey = "TraceSpansEnabled"
+	// Driver 配置项：SensorID 允许/拒绝列表，逗号分隔，项以 * 结尾时按前缀匹配
+	sensorAllowListKey = "SensorAllowList"
+	sensorDenyListKey  = "SensorDenyList"
+
+	// 网关设备上被允许/拒绝列表过滤的帧数
+	filteredDeniedResource     = "filtered-denied"
+	filteredNotAllowedResource = "filtered-not-allowed"
+)
+
+// defaultResDir 设备清单、profile 和参量范围表所在目录，相对于服务的工作目录
+const defaultResDir = "../cmd/res"
+
+// New 创建一个新的驱动实例。异步读数通道、链路、解析流水线及其分片拼接（SSEQ 去重、SDU 导出）
+// 和心跳/告警回调都是实例字段，多个实例互不共享。参数表和设备值表（config 包）、默认方言、
+// 解压上限、全服务乱序缓存上限、日志级别和追踪 Span 输出等解析设置仍是进程级的，
+// 同一进程中先后创建实例（如测试）时由后 Start 的实例覆盖
+func New() *LpMpDriver {
+	return &LpMpDriver{}
+}
 
+// NewVirtualDeviceDriver 兼容旧的入口，每次调用都返回新的实例
 func NewVirtualDeviceDriver() interfaces.ProtocolDriver {
-	once.Do(func() {
-		driver = new(LpMpDriver)
-	})
-	return driver
+	return New()
 }
 
 func (d *LpMpDriver) Initialize(sdk interfaces.DeviceServiceSDK) error {
 	d.sdk = sdk
 	d.lc = sdk.LoggingClient()
 	d.asyncCh = sdk.AsyncValuesChannel()
+	d.deviceOpts = make(map[string]deviceOptions)
+	d.completeness = newCompletenessTracker()
 
+	if err := d.registerConformanceRoute(); err != nil {
+		return fmt.Errorf("注册一致性校验接口失败: %w", err)
+	}
+	if err := d.registerSupportBundleRoute(); err != nil {
+		return fmt.Errorf("注册支持包接口失败: %w", err)
+	}
+	if err := d.registerObjectRoute(); err != nil {
+		return fmt.Errorf("注册对象下载接口失败: %w", err)
+	}
+	if err := d.registerDownlinkQueueRoute(); err != nil {
+		return fmt.Errorf("注册下行队列接口失败: %w", err)
+	}
+	if err := d.registerHistoryRoute(); err != nil {
+		return fmt.Errorf("注册原始读数历史接口失败: %w", err)
+	}
+	if err := d.registerResourceMetaRoute(); err != nil {
+		return fmt.Errorf("注册资源元数据接口失败: %w", err)
+	}
+	if err := d.registerAccessLogRoute(); err != nil {
+		return fmt.Errorf("注册访问审计日志接口失败: %w", err)
+	}
+	if err := d.registerMappingsRoute(); err != nil {
+		return fmt.Errorf("注册传感器映射导入导出接口失败: %w", err)
+	}
+	if err := d.registerConfigReportRoute(); err != nil {
+		return fmt.Errorf("注册配置校验报告接口失败: %w", err)
+	}
+	if err := d.registerCompletenessRoute(); err != nil {
+		return fmt.Errorf("注册数据完整率接口失败: %w", err)
+	}
 	return nil
 }
 
 func (d *LpMpDriver) Start() error {
-	// —— 0. 配置文件和串口参数（可以硬编码，也可从 d.config 读取）
-	const (
-		devicesYAML = "../cmd/res/devices/devices.yaml"
-		profilesDir = "../cmd/res/profiles"
+	// —— 0. 配置文件路径
+	resDir := d.resDir
+	if resDir == "" {
+		resDir = defaultResDir
+	}
+	var (
+		devicesYAML     = filepath.Join(resDir, "devices", "devices.yaml")
+		profilesDir     = filepath.Join(resDir, "profiles")
+		paramLimitsYAML = filepath.Join(resDir, "param_limits.yaml")
+		paramTLVYAML    = filepath.Join(resDir, "param_tlv.yaml")
+		paramTablesYAML = filepath.Join(resDir, "param_tables.yaml")
 	)
-	portName := "/dev/ttyUSB0"
-	baudRate := 115200
+	d.stopCh = make(chan struct{})
+	d.profilesDir = profilesDir
+
+	// —— 0.1 自定义配置 LpmpCustom：叠加到 Driver 节之上，Writable 子节运行时可调
+	if err := d.loadCustomConfig(); err != nil {
+		return err
+	}
+	cfg, err := mergedConfig(d.sdk.DriverConfigs(), d.serviceConfig.LpmpCustom)
+	if err != nil {
+		return err
+	}
+
+	// —— 0.2 串口参数
+	link, err := linkConfigFromDriver(cfg)
+	if err != nil {
+		return fmt.Errorf("读取串口配置失败: %w", err)
+	}
+	d.link = link
+	if d.linkOverride != nil {
+		d.link.Primary = d.linkOverride
+	}
+	if d.listenOnly, err = parseBoolKey(cfg, listenOnlyKey); err != nil {
+		return err
+	}
+	if d.listenOnly {
+		d.lc.Warnf("已启用只监听模式（%s），不向传感器下发任何报文", listenOnlyKey)
+	}
+	mode, maxPause, err := ingestConfig(cfg)
+	if err != nil {
+		return err
+	}
+	d.ingest = &ingestGate{mode: mode, maxPause: maxPause}
+	d.replay = &replayer{dir: replayConfig(cfg)}
+
+	// —— 0.3 TLS：备用链路为 tls:// 或配置了 TransportTLS* 时经 TLS 连接，证书轮换后下次握手生效
+	d.tls = make(map[string]*tlsconf.Loader)
+	if err := d.setupTransportTLS(cfg); err != nil {
+		return err
+	}
 
 	// —— 1. 初始化静态资源定义 + 默认初始值
 	if err := config.InitDeviceResources(devicesYAML, profilesDir); err != nil {
 		return fmt.Errorf("初始化设备资源失败: %w", err)
 	}
+	// 外部参量范围表：超出范围的读数按 action 丢弃或标记为 suspect，文件不存在时不校验
+	if err := config.LoadParamLimits(paramLimitsYAML); err != nil {
+		return err
+	}
+	// 厂家 TLV 子表：按子表把同一参量中嵌套的子参量展开为独立资源，文件不存在时不展开
+	if err := config.LoadParamTLV(paramTLVYAML); err != nil {
+		return err
+	}
+	// 参数表版本：厂家固件重新定义了部分类型码时，设备协议属性 paramTableVersion 选择其传感器使用的版本
+	if err := config.LoadParamTableVersions(paramTablesYAML); err != nil {
+		return err
+	}
+
+	// —— 1.1 按设备协议属性绑定 SensorID（复合设备可声明多个）并读取驱动选项
+	// 不在设备清单中的设备（按模板创建或经 API 添加）按其 profile 加载资源定义
+	var bindingErrs []string
+	for _, dev := range d.sdk.Devices() {
+		if _, ok := config.GetDeviceResources(dev.Name); !ok && dev.ProfileName != "" {
+			if err := config.LoadDeviceProfile(dev.Name, dev.ProfileName, profilesDir); err != nil {
+				d.lc.Errorf("加载设备 %s 的 profile 失败: %v", dev.Name, err)
+				bindingErrs = append(bindingErrs, fmt.Sprintf("加载设备 %s 的 profile 失败: %v", dev.Name, err))
+			}
+		}
+		if err := d.applyDeviceProtocols(dev.Name, dev.Protocols); err != nil {
+			d.lc.Errorf("%v", err)
+			bindingErrs = append(bindingErrs, err.Error())
+		}
+	}
+
+	// —— 1.1.0 配置校验报告：记录设备清单、profile、参量范围表、TLV 子表的校验和及参数表摘要，
+	// 告警项写入日志，完整报告见 GET /api/v3/lpmp/config-report
+	snap, err := d.snapshotConfig(devicesYAML, profilesDir, []string{paramLimitsYAML, paramTLVYAML, paramTablesYAML}, bindingErrs)
+	if err != nil {
+		return err
+	}
+	d.configSnap = snap
+	for _, w := range d.buildConfigReport(snap).Warnings {
+		d.lc.Warnf("配置检查: %s", w)
+	}
+
+	// 解析结果先写入值表，再交给下面按配置追加的转发/归档 Sink；
+	// 声明了 rawFrameStream 的设备，其 rawFrame 资源逐帧异步上报
+	d.sink = frameparser.MultiSink{frameparser.ConfigSink{}, frameparser.ValueSinkFunc(d.streamRawFrame)}
+
+	// —— 1.1.1 本地门限告警：profile 属性 alarmHigh / alarmLow 或 LpmpCustom.Thresholds 声明了门限的数值资源，
+	// 越限时发布 lpmp-threshold 事件
+	if d.thresholds, err = newThresholdMonitor(d.serviceConfig.LpmpCustom.Thresholds); err != nil {
+		return err
+	}
+	d.sink = append(d.sink, frameparser.ValueSinkFunc(d.checkThreshold))
+
+	// —— 1.1.1.1 电池分析：按 battery-level 读数估算放电速率和剩余天数，电量或电压跌破门限时发布 lpmp-battery 事件
+	batteryCfg, err := battery.ConfigFromDriver(cfg)
+	if err != nil {
+		return err
+	}
+	d.battery = battery.New(batteryCfg)
+	d.sink = append(d.sink, frameparser.ValueSinkFunc(d.analyzeBattery))
+
+	// —— 1.1.2 派生资源：profile 属性 expression 引用的资源更新后重新计算，如 apparentPower = voltage * current
+	d.sink = append(d.sink, frameparser.ValueSinkFunc(d.deriveResources))
+
+	// —— 1.2 可选：将解析结果转发到外部 MQTT Broker
+	mqttCfg, err := mqttpub.ConfigFromDriver(cfg)
+	if err != nil {
+		return fmt.Errorf("读取 MQTT 转发配置失败: %w", err)
+	}
+	if mqttCfg.Enabled {
+		loader, host, err := d.mqttTLSLoader(cfg, mqttCfg.BrokerURL)
+		if err != nil {
+			return err
+		}
+		if loader != nil {
+			mqttCfg.TLS = loader.TLSConfig(host)
+			d.tls["mqtt"] = loader
+		}
+		pub, err := mqttpub.New(mqttCfg, d.lc)
+		if err != nil {
+			return err
+		}
+		d.mqttPub = pub
+		d.sink = append(d.sink, frameparser.ValueSinkFunc(func(deviceName, resourceName string, value any, origin time.Time, _ map[string]string) {
+			d.forwardMQTT(deviceName, resourceName, value, origin.UnixNano())
+		}))
+		d.lc.Infof("已启用 MQTT 转发: broker=%s, topic=%s", mqttCfg.BrokerURL, mqttCfg.TopicTemplate)
+	}
+
+	// —— 1.2.1 可选：磁盘溢出队列，异步读数通道积压（core-data / 消息总线不可用）或 MQTT Broker 断开时
+	// 本应丢弃的读数写入 SpillDir，恢复后按顺序重新发布
+	spillCfg, err := spill.ConfigFromDriver(cfg)
+	if err != nil {
+		return fmt.Errorf("读取磁盘溢出队列配置失败: %w", err)
+	}
+	if spillCfg.Enabled {
+		if d.spill, err = openSpill(spillCfg, d.mqttPub != nil); err != nil {
+			return err
+		}
+		go d.runSpillReplay()
+		st := d.spill.stats()
+		d.lc.Infof("已启用磁盘溢出队列: dir=%s, maxSize=%dMB, retention=%s, 遗留 %d 个读数待重新发布",
+			spillCfg.Dir, spillCfg.MaxSize>>20, spillCfg.Retention, st.pending())
+	}
+
+	// —— 1.3 可选：本地归档所有解析结果
+	archiveCfg, err := archive.ConfigFromDriver(cfg)
+	if err != nil {
+		return fmt.Errorf("读取本地归档配置失败: %w", err)
+	}
+	if archiveCfg.Enabled {
+		arc, err := archive.New(archiveCfg)
+		if err != nil {
+			return err
+		}
+		d.archiver = arc
+		d.sink = append(d.sink, frameparser.ValueSinkFunc(func(deviceName, resourceName string, value any, origin time.Time, tags map[string]string) {
+			// traceId 每帧不同，不作为归档标签
+			arcTags := map[string]string{"sensorId": tags["sensorId"]}
+			if q := tags[config.QualityTag]; q != "" {
+				arcTags[config.QualityTag] = q
+			}
+			if u := tags[config.UnitTag]; u != "" {
+				arcTags[config.UnitTag] = u
+			}
+			if err := arc.Append(deviceName, resourceName, value, origin.UnixNano(), arcTags); err != nil {
+				d.lc.Errorf("归档读数 %s.%s 失败: %v", deviceName, resourceName, err)
+			}
+		}))
+		d.lc.Infof("已启用本地归档: dir=%s, format=%s", archiveCfg.Dir, archiveCfg.Format)
+	}
 
-	// —— 2. 打开串口
-	serialPort, err := serial.Open(portName, baudRate)
+	// —— 1.3.1 可选：导出拼接完成/丢弃的 SDU 原始数据
+	spoolCfg, err := spool.ConfigFromDriver(cfg)
 	if err != nil {
-		return fmt.Errorf("打开串口 %s 失败: %w", portName, err)
+		return fmt.Errorf("读取 SDU 导出配置失败: %w", err)
+	}
+	if spoolCfg.Enabled {
+		sp, err := spool.New(spoolCfg)
+		if err != nil {
+			return err
+		}
+		d.spool = sp
+		d.lc.Infof("已启用 SDU 导出: dir=%s, maxSize=%dMB", spoolCfg.Dir, spoolCfg.MaxSize>>20)
 	}
 
-	// —— 3. 启动 AT+DRX 监听，把解析到的二进制帧推到 frameCh
-	frameCh := make(chan []byte, 100)
-	serial.StartDRXListener(serialPort, frameCh)
+	// —— 1.3.1.1 拼接完成的 SDU 按 SSEQ 去重：空口重传的 SDU 在窗口内只发布一次，
+	// 配置了 SSEQStateFile 时记录定期落盘，重启后仍然有效
+	sseqCfg, err := sseqstore.ConfigFromDriver(cfg)
+	if err != nil {
+		return fmt.Errorf("读取 SSEQ 去重配置失败: %w", err)
+	}
+	d.reassembly = frameparser.ReassemblerOptions{}
+	if sseqCfg.Enabled() {
+		if d.sseq, err = sseqstore.New(sseqCfg); err != nil {
+			return err
+		}
+		d.reassembly.SSEQFilter = d.sseq.Accept
+		go d.sseq.Run(d.stopCh, func(err error) { d.lc.Errorf("%v", err) })
+		d.lc.Infof("已启用 SDU 去重: window=%s, file=%q, 已加载 %d 个传感器的记录", sseqCfg.Window, sseqCfg.File, d.sseq.Len())
+	}
 
-	// —— 4. 解析协程
-	frameparser.StartParser(frameCh)
+	// —— 1.3.2 可选：超过门限的 SDU 按内容寻址存为对象，读数只带引用
+	objCfg, err := objstore.ConfigFromDriver(cfg)
+	if err != nil {
+		return fmt.Errorf("读取对象存储配置失败: %w", err)
+	}
+	if objCfg.Enabled() {
+		st, err := objstore.New(objCfg)
+		if err != nil {
+			return err
+		}
+		d.objects = st
+		d.lc.Infof("已启用大 SDU 对象存储: dir=%s, threshold=%d 字节", objCfg.Dir, objCfg.Threshold)
+	}
+	if d.spool != nil || d.objects != nil {
+		d.reassembly.SDUSink = d.handleSDU
+	}
 
-	d.lc.Infof("串口监听和解析已启动")
+	// —— 1.3.2.1 按资源降采样：profile 属性 downsample / downsampleWindow 声明了策略的资源按窗口聚合后
+	// 再交给上面的 Sink，原始读数见 GET /api/v3/lpmp/history
+	historySize, err := downsample.HistorySizeFromDriver(cfg)
+	if err != nil {
+		return err
+	}
+	d.sampler = downsample.New(d.sink, historySize, lcLogger{lc: d.lc})
+	d.sink = frameparser.MultiSink{d.sampler}
+	go d.sampler.Run(d.stopCh)
+
+	// —— 1.3.3 可选：检查系统时钟（无 RTC 的网关开机时可能从 1970 年起计时），
+	// 未同步时读数打标签或暂存，同步后修正 Origin；包在所有 Sink 之外
+	clockCfg, err := clockguard.ConfigFromDriver(cfg)
+	if err != nil {
+		return fmt.Errorf("读取时钟检查配置失败: %w", err)
+	}
+	if clockCfg.Enabled() {
+		d.clock = clockguard.New(clockCfg, d.sink, lcLogger{lc: d.lc})
+		d.sink = frameparser.MultiSink{d.clock}
+		go d.clock.Run(d.stopCh)
+		d.lc.Infof("已启用时钟检查: policy=%s, minEpoch=%s, ntp=%q", clockCfg.Policy, clockCfg.MinEpoch.Format("2006-01-02"), clockCfg.NTPServer)
+	}
+
+	// —— 1.3.4 数据完整率：按帧统计配置了 reportInterval 的设备收到的上报数，在降采样之前计数
+	d.sink = append(frameparser.MultiSink{frameparser.ValueSinkFunc(d.countReport)}, d.sink...)
+
+	// —— 1.4 实时查询超时时间和往返时延统计窗口
+	if d.liveQueryTimeout, err = liveQueryTimeout(cfg); err != nil {
+		return err
+	}
+	window, err := latencyConfig(cfg)
+	if err != nil {
+		return err
+	}
+	d.latency = newLatencyTracker(window)
+
+	// —— 1.4.0.1 异步读数通道过载保护：core-data 变慢时抽样监测读数，不阻塞解析
+	if d.async.highWatermark, d.async.sampleEvery, err = asyncGuardConfig(cfg); err != nil {
+		return err
+	}
+
+	// —— 1.4.1 运行时可调的参数（读数过期判断、错误日志汇总周期、首片前暂存时长、SensorID 过滤），
+	// LpmpCustom/Writable 变化时重新应用
+	tun, err := parseTunables(cfg)
+	if err != nil {
+		return err
+	}
+	d.applyTunables(tun)
+
+	// —— 1.5 未在设备协议属性中声明方言的传感器使用的方言（CRC 算法、字节序等）
+	if v := cfg[defaultDialectKey]; v != "" {
+		dialect, err := frameparser.ParseDialect(v)
+		if err != nil {
+			return fmt.Errorf("%s 配置无效: %w", defaultDialectKey, err)
+		}
+		d.dialect = dialect
+		d.lc.Infof("默认报文方言: %s", dialect.Name)
+	}
+
+	// —— 1.5.1 压缩报文（方言 compress=）解压后的大小上限，防止解压炸弹
+	if v := cfg[maxDecompressedSizeKey]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("%s 配置无效 %q", maxDecompressedSizeKey, v)
+		}
+		frameparser.SetMaxDecompressedSize(n)
+	}
+
+	// —— 1.5.1.1 分片首片的 PSEQ 起点：规范为 0，部分实现从 1 开始或跨 SDU 连续编号
+	if v := cfg[firstPSEQKey]; v != "" {
+		start, err := frameparser.ParsePSEQStart(v)
+		if err != nil {
+			return fmt.Errorf("%s 配置无效: %w", firstPSEQKey, err)
+		}
+		d.reassembly.PSEQStart = &start
+	}
+	if v := cfg[reassembleTimeoutKey]; v != "" {
+		timeout, err := time.ParseDuration(v)
+		if err != nil || timeout <= 0 {
+			return fmt.Errorf("%s 配置无效 %q", reassembleTimeoutKey, v)
+		}
+		d.reassembly.Timeout = timeout
+	}
+	var oooLimits [2]int
+	for i, key := range []string{maxOutOfOrderPerSDUKey, maxOutOfOrderPerServiceKey} {
+		if v := cfg[key]; v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				return fmt.Errorf("%s 配置无效 %q", key, v)
+			}
+			oooLimits[i] = n
+		}
+	}
+	// 两个上限都按实例生效：主/备链路的拼接器共享本实例的预算，不与同进程的其它实例相互回收
+	d.reassembly.MaxOutOfOrderPerSDU = oooLimits[0]
+	d.reassembly.OutOfOrderBudget = frameparser.NewOutOfOrderBudget(oooLimits[1])
+
+	// —— 1.5.2 可选：诊断模式，CRC/结构校验失败的帧转发到 MQTT 主题供离线分析
+	failedOn, failedTopic, err := failedFrameConfig(cfg)
+	if err != nil {
+		return err
+	}
+	if failedOn {
+		d.startFailedFrameForwarding(failedTopic)
+	}
+
+	// —— 1.5.3 传感器首次上线、离线和重复的协议异常（CRC 失败、拼接丢弃、未绑定 SensorID）发布为系统事件
+	eventCfg, err := deviceEventConfigFrom(cfg)
+	if err != nil {
+		return err
+	}
+	d.startDeviceEvents(eventCfg)
+
+	// —— 1.5.4 可选：设备模板，未绑定的 SensorID 按前缀匹配模板自动创建设备（名称、profile、协议属性统一）
+	if len(d.serviceConfig.LpmpCustom.DeviceTemplates) > 0 {
+		templates, err := compileDeviceTemplates(d.serviceConfig.LpmpCustom.DeviceTemplates)
+		if err != nil {
+			return err
+		}
+		d.startProvisioning(templates)
+		d.lc.Infof("已启用设备模板自动创建设备: %d 个模板", len(templates))
+	}
+
+	// —— 1.6 可选：输出每帧各阶段（接收/拼接/解析/发布）的追踪 Span
+	if strings.EqualFold(cfg[traceSpansKey], "true") {
+		trace.SetExporter(func(sp trace.Span) {
+			d.lc.Debugf("[trace=%s] %s 耗时 %s attrs=%v err=%v", sp.TraceID, sp.Stage, sp.End.Sub(sp.Start), sp.Attrs, sp.Err)
+		})
+	}
+
+	// —— 1.7 传感器心跳应答（handleHeartbeat，经 startPipelines 交给各流水线）
+
+	// —— 1.7.1 告警锁存：profile 声明了 alarmLatched 的设备收到告警后保持告警状态，直到写 ackAlarm 确认
+	ackCtrlType, historySize, err := alarmConfig(cfg)
+	if err != nil {
+		return err
+	}
+	d.alarmAckCtrlType = ackCtrlType
+	d.alarms.size = historySize
+
+	// —— 1.7.2 控制报文访问审计日志：每帧控制报文的触发命令、目标传感器、参数和结果，
+	// 启用 AccessLogEnabled 时只追加写入文件并按大小/跨天滚动，最近记录可经 REST 查询
+	accessCfg, err := accesslog.ConfigFromDriver(cfg)
+	if err != nil {
+		return fmt.Errorf("读取访问审计日志配置失败: %w", err)
+	}
+	if d.accessLog, err = accesslog.New(accessCfg); err != nil {
+		return err
+	}
+	if accessCfg.Enabled {
+		d.lc.Infof("已启用控制报文访问审计日志: dir=%s", accessCfg.Dir)
+	}
+
+	// —— 1.8 可选：周期稽核传感器参数
+	auditInterval, err := paramAuditInterval(cfg)
+	if err != nil {
+		return err
+	}
+	if auditInterval > 0 {
+		d.startParamAudit(auditInterval)
+		d.lc.Infof("已启用传感器参数稽核，间隔 %s", auditInterval)
+	}
+
+	// —— 1.9 传感器参数写入协程：写命令入队即返回，确认结果经 writeStatus 上报
+	d.startWriteWorker()
+
+	// —— 1.10 可选：按地区规定限制下行发射占空比
+	dutyCfg, err := dutycycle.ConfigFromDriver(cfg)
+	if err != nil {
+		return fmt.Errorf("读取占空比配置失败: %w", err)
+	}
+	if dutyCfg.Enabled() {
+		d.duty = dutycycle.New(dutyCfg)
+		d.lc.Infof("已启用下行占空比限制: %.2f%%/%s, 空口速率 %d bit/s", dutyCfg.Limit, dutyCfg.Window, dutyCfg.DataRate)
+	}
+
+	// —— 1.11 下行优先级队列：心跳应答优先，参数回读等后台流量最后，低优先级有饿死保护
+	prios, starvation, err := downlinkConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("读取下行优先级配置失败: %w", err)
+	}
+	d.startDownlink(prios, starvation)
+
+	// —— 2. 每条链路一条解析流水线，Stop 时关闭
+	frameChs := d.startPipelines()
+
+	// —— 3. 后台维护模组链路（USB 枚举可能晚于服务启动；可选主备切换），连通后启动 AT+DRX 监听
+	d.superviseLink(frameChs)
+
+	d.lc.Infof("解析已启动，链路 %s 后台连接中", d.link.Primary)
 	return nil
 }
 
 func (d *LpMpDriver) HandleReadCommands(deviceName string, protocols map[string]models.ProtocolProperties, reqs []dsModels.CommandRequest) (res []*dsModels.CommandValue, err error) {
+	// 带 liveQuery 属性的资源先向传感器实时查询，超时则退回缓存值
+	if needsLiveQuery(reqs) {
+		prio, err := d.requestPriority(cmdLiveQuery, reqs)
+		if err != nil {
+			return nil, err
+		}
+		if err := d.liveQuery(deviceName, prio); err != nil {
+			d.lc.Warnf("设备 %s 实时查询失败，返回缓存值: %v", deviceName, err)
+		}
+	}
+	// queryParams 命令：按 URL 查询参数 params 查询指定参量，应答写入 paramQuery 后随本次读取返回
+	if hasResource(reqs, paramQueryResource) {
+		if err := d.handleParamQuery(deviceName, reqs); err != nil {
+			d.lc.Errorf("设备 %s 参量查询失败: %v", deviceName, err)
+			return nil, err
+		}
+	}
+	d.updateCompleteness(deviceName)
+	if deviceName == d.link.GatewayDevice {
+		// 网关设备的 loopbackTest 命令：先执行自检再返回结果
+		if isLoopbackRequest(reqs) {
+			d.handleLoopback(deviceName)
+		}
+		denied, notAllowed := frameparser.FilteredCounts()
+		config.SetDeviceValue(deviceName, filteredDeniedResource, uint32(denied))
+		config.SetDeviceValue(deviceName, filteredNotAllowedResource, uint32(notAllowed))
+		if ms, ok := d.dutyCycleRemaining(); ok {
+			config.SetDeviceValue(deviceName, dutyCycleRemainingResource, ms)
+		}
+		config.SetDeviceValue(deviceName, asyncShedResource, uint32(d.async.shed.Load()))
+		if d.ingest != nil {
+			st := d.ingest.stats()
+			config.SetDeviceValue(deviceName, ingestPausedResource, st.Paused)
+			config.SetDeviceValue(deviceName, ingestDroppedResource, uint32(st.Dropped))
+		}
+		d.updateReplayStatus(deviceName)
+		d.updateLatency(deviceName)
+		d.updateSpill(deviceName)
+		// snapshotStats 命令：采集此刻的全部指标
+		if hasResource(reqs, statsSnapshotResource) {
+			d.snapshotStats(deviceName)
+		}
+	}
+
 	d.locker.Lock()
 	defer d.locker.Unlock()
 
@@ -91,23 +697,39 @@ func (d *LpMpDriver) HandleReadCommands(deviceName string, protocols map[string]
 		return nil, fmt.Errorf("设备 %s 未找到或无可用值", deviceName)
 	}
 
+	// 设置了 coalesceWindow 的设备，刚异步推送过的资源不再返回，全部被合并时返回空列表
+	window := d.deviceOptionsFor(deviceName).CoalesceWindow
 	results := make([]*dsModels.CommandValue, 0, len(reqs))
 	for _, req := range reqs {
 		resName := req.DeviceResourceName
+		if d.coalesced(deviceName, resName, window) {
+			d.lc.Debugf("资源 %s.%s 在 %s 内已推送，本次轮询不返回", deviceName, resName, window)
+			continue
+		}
 		val, exists := values[resName]
 		if !exists {
 			d.lc.Errorf("设备 %s 上未找到资源 %s 的值", deviceName, resName)
 			return nil, fmt.Errorf("设备 %s 上未找到资源 %s 的值", deviceName, resName)
 		}
 
-		// 构造 CommandValue
+		// 构造 CommandValue：传感器读数的 Origin 为帧的串口接收时间，其余资源为读取时刻
+		origin, ok := config.ValueOrigin(deviceName, resName)
+		if !ok {
+			origin = time.Now()
+		}
 		cv := &dsModels.CommandValue{
 			DeviceResourceName: resName,
 			Type:               req.Type,
 			Value:              val,
-			Origin:             time.Now().UnixNano(),
+			Origin:             origin.UnixNano(),
 			Tags:               map[string]string{},
 		}
+		if q := config.ValueQuality(deviceName, resName, d.readingStaleAfter); q != "" {
+			cv.Tags[config.QualityTag] = q
+		}
+		if u, ok := config.ResourceUnit(deviceName, resName, ""); ok {
+			cv.Tags[config.UnitTag] = u.UCUM
+		}
 		results = append(results, cv)
 		d.lc.Infof("读取值: %s.%s = %v", deviceName, resName, val)
 	}
@@ -117,50 +739,185 @@ func (d *LpMpDriver) HandleReadCommands(deviceName string, protocols map[string]
 
 func (d *LpMpDriver) HandleWriteCommands(deviceName string, protocols map[string]models.ProtocolProperties, reqs []dsModels.CommandRequest,
 	params []*dsModels.CommandValue) error {
+	if err := d.beginWrite(deviceName, reqs, params); err != nil {
+		return err
+	}
+
+	// 告警确认和分组下发要等待传感器应答（可达数个 commandTimeout），不持有 locker，
+	// 期间的读命令和其它写命令不被阻塞
+	// ackAlarm=true：确认告警并解除锁存，下发确认报文失败时保持锁存
+	if ackAlarmRequested(reqs, params) {
+		if err := d.ackAlarm(deviceName); err != nil {
+			d.lc.Errorf("%v", err)
+			return err
+		}
+	}
+	group := d.isGroupRequest(deviceName, reqs)
+	if group {
+		// 网关设备：按 group-target 广播或分组下发控制报文
+		if err := d.handleGroupWrite(deviceName, reqs, params); err != nil {
+			d.lc.Errorf("%v", err)
+			return err
+		}
+	}
+
 	d.locker.Lock()
 	defer d.locker.Unlock()
 
-	d.lc.Infof("HandleWriteCommands 调用: 设备=%s, 写入请求数=%d", deviceName, len(reqs))
-
-	// 请求数与参数数必须一致
-	if len(reqs) != len(params) {
-		d.lc.Errorf("请求数与参数数不匹配: %d vs %d", len(reqs), len(params))
-		return fmt.Errorf("请求数与参数数不匹配")
+	// pendingRes 为已入队等待传感器确认的资源，不立即写入值表
+	pendingRes := make(map[string]bool)
+	if !group {
+		// 标记为 sensorParam 的资源入队下发到传感器，确认后才更新值表
+		writes, err := collectSensorParams(deviceName, reqs, params)
+		if err != nil {
+			d.lc.Errorf("设备 %s 参数写入无效: %v", deviceName, err)
+			return err
+		}
+		prio, err := d.requestPriority(cmdParamWrite, reqs)
+		if err != nil {
+			d.lc.Errorf("设备 %s 参数写入无效: %v", deviceName, err)
+			return err
+		}
+		for _, w := range writes {
+			w.priority = prio
+			id, err := d.queueSensorWrite(deviceName, w)
+			if err != nil {
+				d.lc.Errorf("%v", err)
+				return err
+			}
+			d.lc.Infof("[write=%s] 设备 %s(SensorID=%s) 参数写入已入队", id, deviceName, w.sensorID)
+			for res := range w.values {
+				pendingRes[res] = true
+			}
+		}
 	}
 
 	// 遍历每个请求，取出对应的值并写入 config
 	for i, req := range reqs {
 		resName := req.DeviceResourceName
 		cv := params[i]
+		if pendingRes[resName] {
+			continue
+		}
 
 		// 直接使用 CommandValue.Value（已经是合适的 Go 类型）
 		value := cv.Value
 
 		// 并发安全地写入运行时值表
-		config.SetDeviceValue(deviceName, resName, value)
+		if err := config.WriteDeviceValue(deviceName, resName, value); err != nil {
+			d.lc.Errorf("%v", err)
+			return err
+		}
 		d.lc.Infof("写入值: %s.%s = %v", deviceName, resName, value)
 	}
 
 	return nil
 }
 
+// beginWrite 写命令在任何下发之前的检查，以及网关设备上只涉及本地状态的命令（持有 locker）
+func (d *LpMpDriver) beginWrite(deviceName string, reqs []dsModels.CommandRequest, params []*dsModels.CommandValue) error {
+	d.locker.Lock()
+	defer d.locker.Unlock()
+
+	// Profile 中 readWrite 不含 W 的资源拒绝外部写入，在任何下发之前整体检查
+	for _, req := range reqs {
+		if err := config.CheckWritable(deviceName, req.DeviceResourceName); err != nil {
+			d.lc.Errorf("%v", err)
+			return err
+		}
+	}
+
+	d.lc.Infof("HandleWriteCommands 调用: 设备=%s, 写入请求数=%d", deviceName, len(reqs))
+
+	// 请求数与参数数必须一致
+	if len(reqs) != len(params) {
+		d.lc.Errorf("请求数与参数数不匹配: %d vs %d", len(reqs), len(params))
+		return fmt.Errorf("请求数与参数数不匹配")
+	}
+
+	// 网关设备的 resetStats 命令：清零流水线计数
+	if deviceName == d.link.GatewayDevice && resetStatsRequested(reqs, params) {
+		d.resetStats(deviceName)
+	}
+	// 网关设备的 pauseIngest / resumeIngest 命令：维护时段暂停从链路接收帧
+	if deviceName == d.link.GatewayDevice {
+		if boolRequested(reqs, params, pauseIngestResource) {
+			if err := d.pauseIngest(deviceName); err != nil {
+				d.lc.Errorf("%v", err)
+				return err
+			}
+		}
+		if boolRequested(reqs, params, resumeIngestResource) {
+			d.resumeIngest(deviceName, "command")
+		}
+		// replayCapture 命令：在后台以回补模式回放抓包文件
+		if name, ok := replayRequested(reqs, params); ok {
+			if err := d.startReplay(deviceName, name); err != nil {
+				d.lc.Errorf("%v", err)
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func (d *LpMpDriver) Stop(force bool) error {
 	d.lc.Info("VirtualDriver.Stop: device-virtual driver is stopping...")
 
+	// 链路协程收到停止信号后关闭当前连接
+	if d.stopCh != nil {
+		close(d.stopCh)
+	}
+	// 停止暂停接收的自动恢复计时
+	if d.ingest != nil {
+		d.ingest.resume()
+	}
+	for _, p := range d.pipelines {
+		p.Stop()
+	}
+	if d.mqttPub != nil {
+		d.mqttPub.Close()
+	}
+	if d.spill != nil {
+		d.spill.close()
+	}
+	if d.archiver != nil {
+		if err := d.archiver.Close(); err != nil {
+			d.lc.Errorf("关闭本地归档失败: %v", err)
+		}
+	}
+	if d.sseq != nil {
+		if err := d.sseq.Flush(); err != nil {
+			d.lc.Errorf("保存 SSEQ 记录失败: %v", err)
+		}
+	}
+	if d.accessLog != nil {
+		if err := d.accessLog.Close(); err != nil {
+			d.lc.Errorf("关闭访问审计日志失败: %v", err)
+		}
+	}
+
 	return nil
 }
 
 func (d *LpMpDriver) AddDevice(deviceName string, protocols map[string]models.ProtocolProperties, adminState models.AdminState) error {
 	d.lc.Debugf("a new Device is added: %s", deviceName)
+	if err := d.applyDeviceProtocols(deviceName, protocols); err != nil {
+		return err
+	}
 	if err := config.CopyDeviceValues(deviceName, deviceName); err != nil {
-		log.Fatalf("复制设备值失败：%v", err)
+		d.lc.Errorf("复制设备 %s 的资源值失败: %v", deviceName, err)
+		return err
 	}
-	d.lc.Info("已将设备 %s 的所有资源值复制到 %s", deviceName, deviceName)
+	d.lc.Infof("已将设备 %s 的所有资源值复制到 %s", deviceName, deviceName)
 	return nil
 }
 
 func (d *LpMpDriver) UpdateDevice(deviceName string, protocols map[string]models.ProtocolProperties, adminState models.AdminState) error {
 	d.lc.Debugf("Device %s is updated", deviceName)
+	if err := d.applyDeviceProtocols(deviceName, protocols); err != nil {
+		return err
+	}
 
 	// 1. 清空旧的运行时值表
 	// config.DeleteDeviceValues(deviceName)
@@ -182,8 +939,9 @@ func (d *LpMpDriver) RemoveDevice(deviceName string, protocols map[string]models
 	// // 1. 删除运行时值表
 	// config.DeleteDeviceValues(deviceName)
 
-	// // 2. 删除 sensorID 到 deviceName 的所有映射
-	// config.DeleteSensorIDMappingsByDevice(deviceName)
+	// 2. 删除 sensorID 到 deviceName 的所有映射
+	d.forgetDevice(deviceName)
+	config.UnbindDevice(deviceName)
 
 	d.lc.Infof("已移除设备 %s 的所有运行时数据和映射", deviceName)
 	return nil
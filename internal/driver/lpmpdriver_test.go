@@ -0,0 +1,572 @@
+package driver
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	dsModels "github.com/edgexfoundry/device-sdk-go/v4/pkg/models"
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/models"
+	"github.com/linjuya-lu/device-lpmp-go/internal/battery"
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+	"github.com/linjuya-lu/device-lpmp-go/internal/frameparser"
+	"github.com/linjuya-lu/device-lpmp-go/internal/sdkfake"
+)
+
+const (
+	testGateway    = "LPMP-Gateway"
+	testTempHumi   = "Friendcom-TempHumi-Sensor"
+	testWaterLevel = "Friendcom-Water-Level-Sensor"
+	testSensorID   = "238A0821BEF2"
+)
+
+// testDriverConfig 串口不存在，链路协程打开失败后长时间等待重试，直到 Stop
+func testDriverConfig() map[string]string {
+	return map[string]string{
+		serialPortKey:          "/dev/lpmp-test-absent",
+		serialRetryIntervalKey: "1h",
+		gatewayDeviceKey:       testGateway,
+	}
+}
+
+// newTestDriver 创建使用 sdkfake 的驱动并完成 Initialize
+func newTestDriver(t *testing.T) (*LpMpDriver, *sdkfake.SDK) {
+	t.Helper()
+	sdk := sdkfake.New(testDriverConfig())
+	d := New()
+	d.resDir = "../../cmd/res"
+	if err := d.Initialize(sdk); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	return d, sdk
+}
+
+// startTestDriver 创建并启动驱动，水位传感器绑定 testSensorID；测试结束时 Stop
+func startTestDriver(t *testing.T) (*LpMpDriver, *sdkfake.SDK) {
+	t.Helper()
+	d, sdk := newTestDriver(t)
+	addTestDevices(t, sdk)
+	if err := d.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := d.Stop(false); err != nil {
+			t.Errorf("Stop: %v", err)
+		}
+	})
+	return d, sdk
+}
+
+// addTestDevices 添加网关、温湿度和水位三个设备，水位传感器绑定 testSensorID
+func addTestDevices(t *testing.T, sdk *sdkfake.SDK) {
+	t.Helper()
+	for _, dev := range []models.Device{
+		{Name: testGateway, ProfileName: "LPMP-Gateway-Profile"},
+		{Name: testTempHumi, ProfileName: "Friendcom-TempHumi-Profile"},
+		{Name: testWaterLevel, ProfileName: "Friendcom-Water-Level-Profile", Protocols: map[string]models.ProtocolProperties{
+			"lpmp": {"sensorIds": testSensorID},
+		}},
+	} {
+		if _, err := sdk.AddDevice(dev); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestNewReturnsDistinctInstances(t *testing.T) {
+	d1, sdk1 := newTestDriver(t)
+	d2, sdk2 := newTestDriver(t)
+	if d1 == d2 {
+		t.Fatal("New 返回了同一个实例")
+	}
+	if d1.asyncCh != sdk1.AsyncValuesChannel() || d2.asyncCh != sdk2.AsyncValuesChannel() {
+		t.Error("实例未使用各自 SDK 的异步读数通道")
+	}
+	if NewVirtualDeviceDriver() == NewVirtualDeviceDriver() {
+		t.Error("NewVirtualDeviceDriver 仍返回单例")
+	}
+}
+
+func TestInitializeRegistersRoutes(t *testing.T) {
+	_, sdk := newTestDriver(t)
+	want := []string{
+		accessLogRoute,
+		completenessRoute,
+		configReportRoute,
+		conformanceRoute,
+		downlinkQueueRoute,
+		historyRoute,
+		mappingsRoute,
+		objectRoute,
+		resourceMetaRoute,
+		supportBundleRoute,
+	}
+	got := sdk.Routes()
+	for _, r := range want {
+		i := sort.SearchStrings(got, r)
+		if i == len(got) || got[i] != r {
+			t.Errorf("路由 %s 未注册，已注册: %v", r, got)
+		}
+	}
+
+	// Start 之前依赖运行时状态的接口返回 503
+	for _, route := range []string{accessLogRoute, configReportRoute, historyRoute} {
+		rec, err := sdk.Serve(http.MethodGet, route, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", route, err)
+		}
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s: 状态码 %d，期望 %d", route, rec.Code, http.StatusServiceUnavailable)
+		}
+	}
+}
+
+func TestStartBindsSensorsAndMarksGatewayDown(t *testing.T) {
+	_, sdk := startTestDriver(t)
+
+	if ids := config.LookupSensorIDs(testWaterLevel); !reflect.DeepEqual(ids, []string{testSensorID}) {
+		t.Errorf("水位传感器绑定 %v，期望 [%s]", ids, testSensorID)
+	}
+	gw, err := sdk.GetDeviceByName(testGateway)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gw.OperatingState != models.Down {
+		t.Errorf("串口未连通时网关 OperatingState=%s，期望 %s", gw.OperatingState, models.Down)
+	}
+}
+
+func TestConfigReport(t *testing.T) {
+	_, sdk := startTestDriver(t)
+	// 第二个设备绑定同一传感器且未声明 resources：报告告警
+	if _, err := sdk.AddDevice(models.Device{Name: "Water-Level-Copy", ProfileName: "Friendcom-Water-Level-Profile"}); err != nil {
+		t.Fatal(err)
+	}
+	config.BindSensors("Water-Level-Copy", map[string]string{testSensorID: ""}, nil)
+	t.Cleanup(func() { config.UnbindDevice("Water-Level-Copy") })
+
+	rec, err := sdk.Serve(http.MethodGet, configReportRoute, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rep configReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &rep); err != nil {
+		t.Fatalf("响应 %s: %v", rec.Body.String(), err)
+	}
+	if rec.Code != http.StatusOK || len(rep.Devices) != 4 || rep.Resources == 0 {
+		t.Fatalf("状态码 %d，报告 %+v", rec.Code, rep)
+	}
+	if rep.ParamTable.Entries == 0 || len(rep.ParamTable.SHA256) != 64 || len(rep.ParamTable.Versions) == 0 {
+		t.Errorf("参数表摘要 %+v", rep.ParamTable)
+	}
+	// devices.yaml + 3 个 profile + 三个可选表
+	if len(rep.Files) != 7 || rep.Files[0].SHA256 == "" {
+		t.Errorf("文件校验和 %+v", rep.Files)
+	}
+	var warned bool
+	for _, w := range rep.Warnings {
+		warned = warned || strings.Contains(w, "Water-Level-Copy")
+	}
+	if !warned {
+		t.Errorf("告警项 %v 未包含共享传感器的设备", rep.Warnings)
+	}
+}
+
+func TestSensorMappingsImportExport(t *testing.T) {
+	_, sdk := startTestDriver(t)
+	const newID = "AABBCCDDEEFF"
+	// 绑定关系是包级状态（TestAddDevice 等也会绑定温湿度设备），从未绑定开始，结束时解除
+	config.UnbindDevice(testTempHumi)
+	t.Cleanup(func() { config.UnbindDevice(testTempHumi) })
+
+	post := func(body string, dryRun bool) (int, mappingImportReport) {
+		t.Helper()
+		q := url.Values{"format": {"csv"}, "dryRun": {strconv.FormatBool(dryRun)}}
+		rec, err := sdk.ServeBody(http.MethodPost, mappingsRoute, q, []byte(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rep mappingImportReport
+		if err := json.Unmarshal(rec.Body.Bytes(), &rep); err != nil {
+			t.Fatalf("响应 %s: %v", rec.Body.String(), err)
+		}
+		return rec.Code, rep
+	}
+
+	// 设备不存在、同一设备重复的 SensorID：返回 400，不做修改
+	code, rep := post("sensorId,device\n"+newID+",no-such-device\n"+newID+","+testTempHumi+"\n"+newID+","+testTempHumi+"\n", false)
+	if code != http.StatusBadRequest || len(rep.Errors) != 2 {
+		t.Fatalf("状态码 %d，错误 %v", code, rep.Errors)
+	}
+
+	// dryRun 只返回将要做的修改
+	csvBody := "sensorId,device\n" + newID + "," + testTempHumi + "\n"
+	code, rep = post(csvBody, true)
+	if code != http.StatusOK || len(rep.Changes) != 1 || rep.Changes[0].SensorIDs != newID {
+		t.Fatalf("dryRun 状态码 %d，结果 %+v", code, rep)
+	}
+	if ids := config.LookupSensorIDs(testTempHumi); len(ids) != 0 {
+		t.Fatalf("dryRun 后温湿度设备绑定了 %v", ids)
+	}
+
+	code, rep = post(csvBody, false)
+	if code != http.StatusOK || rep.DryRun {
+		t.Fatalf("导入状态码 %d，结果 %+v", code, rep)
+	}
+	if ids := config.LookupSensorIDs(testTempHumi); !reflect.DeepEqual(ids, []string{newID}) {
+		t.Errorf("导入后温湿度设备绑定 %v，期望 [%s]", ids, newID)
+	}
+	dev, err := sdk.GetDeviceByName(testTempHumi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := dev.Protocols["lpmp"]["sensorIds"]; got != newID {
+		t.Errorf("设备协议 sensorIds=%q，期望 %q", got, newID)
+	}
+
+	rec, err := sdk.Serve(http.MethodGet, mappingsRoute, url.Values{"device": {testTempHumi}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "sensorId,device,prefix,resources\n" + newID + "," + testTempHumi + ",,\n"; rec.Body.String() != want {
+		t.Errorf("导出 %q，期望 %q", rec.Body.String(), want)
+	}
+}
+
+func TestHandleReadCommands(t *testing.T) {
+	d, _ := startTestDriver(t)
+
+	tests := []struct {
+		name     string
+		device   string
+		resource string
+		want     any
+		wantErr  bool
+	}{
+		{name: "网关默认值", device: testGateway, resource: groupTargetResource, want: "all"},
+		{name: "传感器默认值", device: testTempHumi, resource: "temperature", want: float32(0)},
+		{name: "未知资源", device: testTempHumi, resource: "no-such-resource", wantErr: true},
+		{name: "未知设备", device: "no-such-device", resource: "temperature", wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := d.HandleReadCommands(tc.device, nil, []dsModels.CommandRequest{{DeviceResourceName: tc.resource}})
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("期望错误，得到 %v", res)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res) != 1 || res[0].DeviceResourceName != tc.resource {
+				t.Fatalf("返回 %v", res)
+			}
+			if !reflect.DeepEqual(res[0].Value, tc.want) {
+				t.Errorf("值 %#v，期望 %#v", res[0].Value, tc.want)
+			}
+			if res[0].Origin == 0 {
+				t.Error("Origin 为 0")
+			}
+		})
+	}
+}
+
+func TestHandleWriteCommands(t *testing.T) {
+	d, _ := startTestDriver(t)
+
+	tests := []struct {
+		name     string
+		device   string
+		reqs     []dsModels.CommandRequest
+		values   []*dsModels.CommandValue
+		wantErr  bool
+		readBack any
+	}{
+		{
+			name:     "可写资源",
+			device:   testGateway,
+			reqs:     []dsModels.CommandRequest{{DeviceResourceName: groupTargetResource}},
+			values:   []*dsModels.CommandValue{{DeviceResourceName: groupTargetResource, Value: "water"}},
+			readBack: "water",
+		},
+		{
+			name:    "只读资源",
+			device:  testTempHumi,
+			reqs:    []dsModels.CommandRequest{{DeviceResourceName: "temperature"}},
+			values:  []*dsModels.CommandValue{{DeviceResourceName: "temperature", Value: float32(1)}},
+			wantErr: true,
+		},
+		{
+			name:    "请求数与值数不一致",
+			device:  testGateway,
+			reqs:    []dsModels.CommandRequest{{DeviceResourceName: groupTargetResource}},
+			wantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := d.HandleWriteCommands(tc.device, nil, tc.reqs, tc.values)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("期望错误")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			values, _ := config.GetDeviceValues(tc.device)
+			if got := values[tc.reqs[0].DeviceResourceName]; !reflect.DeepEqual(got, tc.readBack) {
+				t.Errorf("写入后值 %#v，期望 %#v", got, tc.readBack)
+			}
+		})
+	}
+}
+
+func TestAddDevice(t *testing.T) {
+	d, _ := startTestDriver(t)
+
+	tests := []struct {
+		name      string
+		sensorIDs string
+		want      []string
+		wantErr   bool
+	}{
+		{name: "绑定 SensorID", sensorIDs: "0102030405A6", want: []string{"0102030405A6"}},
+		{name: "无效 SensorID", sensorIDs: "xyz", wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			protocols := map[string]models.ProtocolProperties{"lpmp": {"sensorIds": tc.sensorIDs}}
+			err := d.AddDevice(testTempHumi, protocols, models.Unlocked)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("期望错误")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ids := config.LookupSensorIDs(testTempHumi); !reflect.DeepEqual(ids, tc.want) {
+				t.Errorf("绑定 %v，期望 %v", ids, tc.want)
+			}
+		})
+	}
+
+	// 设备未加载值表时返回错误，不退出进程
+	if err := d.AddDevice("Unknown-Device", nil, models.Unlocked); err == nil {
+		t.Error("未加载值表的设备未报错")
+	}
+}
+
+func TestLatencyPercentiles(t *testing.T) {
+	tr := newLatencyTracker(10)
+	// 窗口 10：前 5 个 1000ms 被覆盖，只留 1~10ms
+	for i := 0; i < 5; i++ {
+		tr.record("A", time.Second)
+	}
+	for i := 1; i <= 10; i++ {
+		tr.record("A", time.Duration(i)*time.Millisecond)
+	}
+	tr.record("B", 3*time.Millisecond)
+	snap := tr.snapshot()
+	if got, want := snap.Sensors["A"], (latencyStats{Samples: 10, P50Ms: 5, P95Ms: 10, LastMs: 10}); got != want {
+		t.Errorf("A=%+v，期望 %+v", got, want)
+	}
+	if got := snap.Sensors["B"]; got.Samples != 1 || got.P50Ms != 3 || got.P95Ms != 3 {
+		t.Errorf("B=%+v", got)
+	}
+	if snap.All.Samples != 10 || snap.All.LastMs != 3 {
+		t.Errorf("all=%+v", snap.All)
+	}
+}
+
+func TestBatteryEstimate(t *testing.T) {
+	m := battery.New(battery.Config{TrendWindow: 7 * 24 * time.Hour})
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	// 每天下降 2 个百分点
+	var est battery.Estimate
+	for day := 0; day <= 5; day++ {
+		est, _ = m.ObserveLevel("dev", float64(80-2*day), start.Add(time.Duration(day)*24*time.Hour))
+	}
+	if math.Abs(est.SlopePerDay+2) > 1e-9 || math.Abs(est.DaysRemaining-35) > 1e-9 {
+		t.Errorf("估算 %+v，期望每天 -2、剩余 35 天", est)
+	}
+	// 窗口外的读数不参与拟合：7 天后电量不变
+	for day := 6; day <= 14; day++ {
+		est, _ = m.ObserveLevel("dev", 70, start.Add(time.Duration(day)*24*time.Hour))
+	}
+	if est.SlopePerDay != 0 || est.DaysRemaining != -1 {
+		t.Errorf("窗口内电量不变时估算 %+v", est)
+	}
+	// 电量回升视为更换电池，历史清空
+	est, _ = m.ObserveLevel("dev", 100, start.Add(15*24*time.Hour))
+	if est.SlopePerDay != 0 || est.DaysRemaining != -1 || est.Level != 100 {
+		t.Errorf("更换电池后估算 %+v", est)
+	}
+}
+
+func TestCompletenessSeries(t *testing.T) {
+	ct := newCompletenessTracker()
+	since := time.Date(2026, 3, 10, 9, 0, 0, 0, time.Local)
+	ct.configure("dev", 10*time.Minute, since)
+	// 9 点收到 5 帧，同一帧的多个读数只计一次
+	for i := 0; i < 5; i++ {
+		at := since.Add(time.Duration(i) * 10 * time.Minute)
+		ct.record("dev", "trace-"+strconv.Itoa(i), at)
+		ct.record("dev", "trace-"+strconv.Itoa(i), at)
+	}
+	now := since.Add(90 * time.Minute)
+	s, ok := ct.series("dev", now)
+	if !ok || len(s.Hourly) != 2 || len(s.Daily) != 1 {
+		t.Fatalf("序列 %+v", s)
+	}
+	if h := s.Hourly[0]; h.Expected != 6 || h.Received != 5 || h.Percent == nil || math.Abs(*h.Percent-500.0/6) > 1e-9 {
+		t.Errorf("9 点 %+v", h)
+	}
+	// 当前小时过了一半：应收 3，实收 0
+	if h := s.Hourly[1]; h.Expected != 3 || h.Received != 0 || h.Percent == nil || *h.Percent != 0 {
+		t.Errorf("10 点 %+v", h)
+	}
+	if got := lastComplete(s.Hourly); math.Abs(float64(got)-500.0/6) > 1e-4 {
+		t.Errorf("上一小时完整率 %v", got)
+	}
+	if got := lastComplete(s.Daily); got != -1 {
+		t.Errorf("尚无完整的自然日时完整率 %v，期望 -1", got)
+	}
+
+	// 次日：前一天从 9 点起统计，应收 90，完整率不超过 100
+	for i := 0; i < 100; i++ {
+		ct.record("dev", "extra-"+strconv.Itoa(i), since.Add(2*time.Hour))
+	}
+	s, _ = ct.series("dev", since.Add(24*time.Hour))
+	if d := s.Daily[0]; d.Expected != 90 || d.Received != 105 || *d.Percent != 100 {
+		t.Errorf("前一天 %+v", d)
+	}
+	if len(s.Hourly) != 25 {
+		t.Errorf("小时序列 %d 项，期望 25", len(s.Hourly))
+	}
+
+	// 周期变化时重新统计；未配置时不统计
+	ct.configure("dev", 5*time.Minute, now)
+	if s, _ := ct.series("dev", now); s.Hourly[0].Received != 0 || !s.Since.Equal(now) {
+		t.Errorf("周期变化后 %+v", s)
+	}
+	ct.forget("dev")
+	if _, ok := ct.series("dev", now); ok {
+		t.Error("forget 后仍有统计")
+	}
+}
+
+func TestCompletenessRoute(t *testing.T) {
+	d, sdk := newTestDriver(t)
+	d.completeness.configure(testWaterLevel, time.Minute, time.Now().Add(-time.Hour))
+	for query, want := range map[string]int{"": http.StatusBadRequest, "nope": http.StatusNotFound, testWaterLevel: http.StatusOK} {
+		q := url.Values{}
+		if query != "" {
+			q.Set("device", query)
+		}
+		rec, err := sdk.Serve(http.MethodGet, completenessRoute, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.Code != want {
+			t.Errorf("device=%q: 状态码 %d，期望 %d", query, rec.Code, want)
+		}
+		if want == http.StatusOK {
+			var s completenessSeries
+			if err := json.Unmarshal(rec.Body.Bytes(), &s); err != nil || s.ReportInterval != "1m0s" || len(s.Hourly) < 2 {
+				t.Errorf("响应 %s: %v", rec.Body.String(), err)
+			}
+		}
+	}
+}
+
+// derivedProfile 输入 lineVoltage(Int16)、lineCurrent(Float32)，三级链式派生 power → powerKW → powerLevel
+const derivedProfile = `name: "Derived-Test-Profile"
+deviceResources:
+  - name: "lineVoltage"
+    properties: { valueType: "Int16", readWrite: "R", defaultValue: "0" }
+  - name: "lineCurrent"
+    properties: { valueType: "Float32", readWrite: "R", defaultValue: "0" }
+  - name: "power"
+    attributes: { expression: "lineVoltage * lineCurrent" }
+    properties: { valueType: "Float32", readWrite: "R", units: "W", defaultValue: "0" }
+  - name: "powerKW"
+    attributes: { expression: "power / 1000" }
+    properties: { valueType: "Float64", readWrite: "R", defaultValue: "0" }
+  - name: "powerLevel"
+    attributes: { expression: "round({powerKW} * 100)" }
+    properties: { valueType: "Uint8", readWrite: "R", defaultValue: "0" }
+`
+
+// depthSink 记录经过的读数和 SetValue 的最大嵌套深度
+type depthSink struct {
+	next     frameparser.ValueSink
+	depth    int
+	maxDepth int
+	got      map[string][]any
+}
+
+func (s *depthSink) SetValue(deviceName, resourceName string, value any, origin time.Time, tags map[string]string) {
+	s.depth++
+	s.maxDepth = max(s.maxDepth, s.depth)
+	s.got[resourceName] = append(s.got[resourceName], value)
+	s.next.SetValue(deviceName, resourceName, value, origin, tags)
+	s.depth--
+}
+
+// TestDerivedResourcesChain 链式派生资源由触发的输入读数一次算完：每个派生值只发布一次，
+// 派生结果经 d.sink 发布时不再进入计算，SetValue 最多嵌套一层
+func TestDerivedResourcesChain(t *testing.T) {
+	d, _ := startTestDriver(t)
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "Derived-Test-Profile.yaml"), []byte(derivedProfile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	const dev = "Derived-Test"
+	if err := config.LoadDeviceProfile(dev, "Derived-Test-Profile", dir); err != nil {
+		t.Fatal(err)
+	}
+	sink := &depthSink{next: d.sink}
+	d.sink = frameparser.MultiSink{sink}
+
+	for _, step := range []struct {
+		resource string
+		value    any
+		want     map[string][]any
+	}{
+		// lineCurrent 尚无读数，不计算
+		{"lineVoltage", int16(220), map[string][]any{"lineVoltage": {int16(220)}}},
+		{"lineCurrent", float32(1.5), map[string][]any{
+			"lineCurrent": {float32(1.5)}, "power": {float32(330)}, "powerKW": {0.33}, "powerLevel": {uint8(33)},
+		}},
+		{"lineVoltage", int16(100), map[string][]any{
+			"lineVoltage": {int16(100)}, "power": {float32(150)}, "powerKW": {0.15}, "powerLevel": {uint8(15)},
+		}},
+	} {
+		sink.got, sink.maxDepth = make(map[string][]any), 0
+		d.sink.SetValue(dev, step.resource, step.value, time.Now(), map[string]string{config.UnitTag: "V"})
+		if !reflect.DeepEqual(sink.got, step.want) {
+			t.Errorf("%s=%v 后发布 %v，期望 %v", step.resource, step.value, sink.got, step.want)
+		}
+		if want := min(len(step.want), 2); sink.maxDepth != want {
+			t.Errorf("%s=%v: SetValue 嵌套 %d 层，期望 %d", step.resource, step.value, sink.maxDepth, want)
+		}
+	}
+	if vals, _ := config.GetDeviceValues(dev); vals["powerLevel"] != uint8(15) {
+		t.Errorf("值表 powerLevel=%v，期望 15", vals["powerLevel"])
+	}
+}
@@ -0,0 +1,355 @@
+package driver
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/edgexfoundry/device-sdk-go/v4/pkg/interfaces"
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/common"
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/models"
+	"github.com/labstack/echo/v4"
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// mappingsRoute SensorID ↔ 设备映射的批量导入导出接口：
+// GET ?format=csv|yaml&device=<设备名> 导出当前映射；
+// POST ?format=csv|yaml&dryRun=true 导入请求体中的映射，dryRun 时只校验并返回将要做的修改
+const mappingsRoute = common.ApiBase + "/lpmp/sensor-mappings"
+
+// 映射文件格式
+const (
+	mappingFormatCSV  = "csv"
+	mappingFormatYAML = "yaml"
+)
+
+// mappingCSVHeader CSV 的表头；resources 列内多个资源名以分号分隔
+var mappingCSVHeader = []string{"sensorId", "device", "prefix", "resources"}
+
+// sensorMapping 一条 SensorID ↔ 设备映射，对应映射文件的一行
+type sensorMapping struct {
+	SensorID string `json:"sensorId" yaml:"sensorId"`
+	Device   string `json:"device" yaml:"device"`
+	// Prefix 复合设备中该传感器的资源名前缀
+	Prefix string `json:"prefix,omitempty" yaml:"prefix,omitempty"`
+	// Resources 设备接收的资源过滤列表（设备级，同一设备的各行须一致）
+	Resources []string `json:"resources,omitempty" yaml:"resources,omitempty"`
+}
+
+// mappingChange 导入对一个设备协议属性的修改
+type mappingChange struct {
+	Device    string `json:"device"`
+	SensorIDs string `json:"sensorIds"`
+	Resources string `json:"resources,omitempty"`
+	// Previous 导入前的 lpmp.sensorIds
+	Previous string `json:"previous,omitempty"`
+}
+
+// mappingImportReport 导入结果：有 Errors 时不做任何修改
+type mappingImportReport struct {
+	DryRun  bool            `json:"dryRun"`
+	Rows    int             `json:"rows"`
+	Changes []mappingChange `json:"changes"`
+	Errors  []string        `json:"errors,omitempty"`
+}
+
+// exportMappings 返回当前生效的映射（含 idToDevice.go 中的静态映射），device 非空时只返回该设备的
+func exportMappings(device string) []sensorMapping {
+	var out []sensorMapping
+	for id, bs := range config.AllSensorBindings() {
+		for _, b := range bs {
+			if device != "" && b.DeviceName != device {
+				continue
+			}
+			out = append(out, sensorMapping{SensorID: id, Device: b.DeviceName, Prefix: b.Prefix, Resources: b.Resources})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Device != out[j].Device {
+			return out[i].Device < out[j].Device
+		}
+		return out[i].SensorID < out[j].SensorID
+	})
+	return out
+}
+
+// encodeMappings 按格式输出映射
+func encodeMappings(format string, rows []sensorMapping) ([]byte, error) {
+	switch format {
+	case mappingFormatCSV:
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		_ = w.Write(mappingCSVHeader)
+		for _, r := range rows {
+			_ = w.Write([]string{r.SensorID, r.Device, r.Prefix, strings.Join(r.Resources, ";")})
+		}
+		w.Flush()
+		return buf.Bytes(), w.Error()
+	case mappingFormatYAML:
+		if rows == nil {
+			rows = []sensorMapping{}
+		}
+		return yaml.Marshal(rows)
+	}
+	return nil, fmt.Errorf("不支持的格式 %q，可选 csv、yaml", format)
+}
+
+// decodeMappings 解析映射文件；CSV 首行须为表头，列顺序不限，prefix、resources 列可省略
+func decodeMappings(format string, data []byte) ([]sensorMapping, error) {
+	switch format {
+	case mappingFormatCSV:
+		r := csv.NewReader(bytes.NewReader(data))
+		r.FieldsPerRecord = -1
+		r.TrimLeadingSpace = true
+		records, err := r.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("CSV 格式错误: %w", err)
+		}
+		if len(records) == 0 {
+			return nil, errors.New("CSV 为空，缺少表头")
+		}
+		col := make(map[string]int)
+		for i, name := range records[0] {
+			col[strings.TrimSpace(name)] = i
+		}
+		for _, name := range mappingCSVHeader[:2] {
+			if _, ok := col[name]; !ok {
+				return nil, fmt.Errorf("CSV 表头缺少 %s 列", name)
+			}
+		}
+		field := func(rec []string, name string) string {
+			if i, ok := col[name]; ok && i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		rows := make([]sensorMapping, 0, len(records)-1)
+		for _, rec := range records[1:] {
+			rows = append(rows, sensorMapping{
+				SensorID:  field(rec, "sensorId"),
+				Device:    field(rec, "device"),
+				Prefix:    field(rec, "prefix"),
+				Resources: splitResources(field(rec, "resources")),
+			})
+		}
+		return rows, nil
+	case mappingFormatYAML:
+		var rows []sensorMapping
+		if err := yaml.Unmarshal(data, &rows); err != nil {
+			return nil, fmt.Errorf("YAML 格式错误: %w", err)
+		}
+		return rows, nil
+	}
+	return nil, fmt.Errorf("不支持的格式 %q，可选 csv、yaml", format)
+}
+
+// splitResources 拆分 CSV resources 列，分号或逗号分隔
+func splitResources(s string) []string {
+	return splitList(strings.ReplaceAll(s, ";", ","))
+}
+
+// planImport 校验导入的映射并计算各设备新的 lpmp.sensorIds。每行的 SensorID 须为 12 位十六进制、设备须已存在；
+// 同一设备的 SensorID 不得重复、各行 resources 须一致；同一 SensorID 绑定多个设备时各设备都须声明 resources；
+// SensorID 已绑定到本次未导入的设备时视为冲突。导入只替换出现在文件中的设备的映射，其它设备不变
+func (d *LpMpDriver) planImport(rows []sensorMapping) mappingImportReport {
+	rep := mappingImportReport{Rows: len(rows), Changes: []mappingChange{}}
+	fail := func(format string, args ...any) {
+		rep.Errors = append(rep.Errors, fmt.Sprintf(format, args...))
+	}
+
+	// 1. 逐行校验，按设备归组
+	byDevice := make(map[string][]int)
+	for i := range rows {
+		r := &rows[i]
+		r.SensorID = strings.ToUpper(strings.TrimSpace(r.SensorID))
+		r.Device = strings.TrimSpace(r.Device)
+		if ids, err := config.ParseSensorIDList(r.SensorID); err != nil || len(ids) != 1 {
+			fail("第 %d 条: SensorID %q 必须为 12 位十六进制", i+1, r.SensorID)
+			continue
+		}
+		if strings.ContainsAny(r.Prefix, ",:") {
+			fail("第 %d 条: 前缀 %q 不能包含逗号或冒号", i+1, r.Prefix)
+			continue
+		}
+		if r.Device == "" {
+			fail("第 %d 条: 缺少设备名", i+1)
+			continue
+		}
+		if !d.sdk.DeviceExistsForName(r.Device) {
+			fail("第 %d 条: 设备 %s 不存在", i+1, r.Device)
+			continue
+		}
+		byDevice[r.Device] = append(byDevice[r.Device], i)
+	}
+
+	// 2. 设备内 SensorID 不重复，resources 一致；按设备名、SensorID 顺序检查，报告顺序稳定
+	devices := make([]string, 0, len(byDevice))
+	for dev := range byDevice {
+		devices = append(devices, dev)
+	}
+	sort.Strings(devices)
+	devicesOf := make(map[string][]string)
+	filtered := make(map[string]bool)
+	for _, dev := range devices {
+		idx := byDevice[dev]
+		seen := make(map[string]int)
+		first := rows[idx[0]].Resources
+		for _, i := range idx {
+			r := rows[i]
+			if prev, dup := seen[r.SensorID]; dup {
+				fail("第 %d 条: SensorID %s 在设备 %s 中重复（第 %d 条）", i+1, r.SensorID, dev, prev+1)
+				continue
+			}
+			seen[r.SensorID] = i
+			if strings.Join(r.Resources, ",") != strings.Join(first, ",") {
+				fail("第 %d 条: 设备 %s 的 resources 与第 %d 条不一致（resources 为设备级属性）", i+1, dev, idx[0]+1)
+			}
+			devicesOf[r.SensorID] = append(devicesOf[r.SensorID], dev)
+		}
+		filtered[dev] = len(first) > 0
+	}
+
+	// 3. 跨设备：多设备绑定须有资源过滤；与未导入设备的现有绑定冲突
+	sids := make([]string, 0, len(devicesOf))
+	for sid := range devicesOf {
+		sids = append(sids, sid)
+	}
+	sort.Strings(sids)
+	for _, sid := range sids {
+		devs := devicesOf[sid]
+		if len(devs) > 1 {
+			for _, dev := range devs {
+				if !filtered[dev] {
+					fail("SensorID %s 同时映射到 %s，需为各设备声明 resources 区分接收的资源", sid, strings.Join(devs, "、"))
+					break
+				}
+			}
+		}
+		for _, b := range config.LookupSensorBindings(sid) {
+			if _, imported := byDevice[b.DeviceName]; !imported {
+				fail("SensorID %s 已绑定到设备 %s（不在本次导入中），请先解除或一并导入", sid, b.DeviceName)
+			}
+		}
+	}
+	if len(rep.Errors) > 0 {
+		return rep
+	}
+
+	// 4. 各设备新的协议属性
+	for _, dev := range devices {
+		idx := byDevice[dev]
+		items := make([]string, 0, len(idx))
+		for _, i := range idx {
+			item := rows[i].SensorID
+			if rows[i].Prefix != "" {
+				item += ":" + rows[i].Prefix
+			}
+			items = append(items, item)
+		}
+		sort.Strings(items)
+		change := mappingChange{
+			Device:    dev,
+			SensorIDs: strings.Join(items, ","),
+			Resources: strings.Join(rows[idx[0]].Resources, ","),
+		}
+		if existing, err := d.sdk.GetDeviceByName(dev); err == nil {
+			change.Previous, _ = protocolString(existing.Protocols, sensorIDsKey)
+		}
+		rep.Changes = append(rep.Changes, change)
+	}
+	return rep
+}
+
+// applyImport 把校验通过的修改写回设备（经 SDK 更新 core-metadata），并立即更新本地绑定
+func (d *LpMpDriver) applyImport(changes []mappingChange) error {
+	for _, c := range changes {
+		dev, err := d.sdk.GetDeviceByName(c.Device)
+		if err != nil {
+			return fmt.Errorf("读取设备 %s 失败: %w", c.Device, err)
+		}
+		// 复制协议属性，避免修改 SDK 缓存中的设备
+		protocols := make(map[string]models.ProtocolProperties, len(dev.Protocols)+1)
+		for name, props := range dev.Protocols {
+			cp := make(models.ProtocolProperties, len(props))
+			for k, v := range props {
+				cp[k] = v
+			}
+			protocols[name] = cp
+		}
+		if protocols[protocolName] == nil {
+			protocols[protocolName] = models.ProtocolProperties{}
+		}
+		protocols[protocolName][sensorIDsKey] = c.SensorIDs
+		if c.Resources != "" {
+			protocols[protocolName][resourcesKey] = c.Resources
+		} else {
+			delete(protocols[protocolName], resourcesKey)
+		}
+		dev.Protocols = protocols
+		if err := d.sdk.UpdateDevice(dev); err != nil {
+			return fmt.Errorf("更新设备 %s 失败: %w", c.Device, err)
+		}
+		if err := d.applyDeviceProtocols(c.Device, protocols); err != nil {
+			return err
+		}
+		d.lc.Infof("已导入设备 %s 的传感器映射: %s（原为 %q）", c.Device, c.SensorIDs, c.Previous)
+	}
+	return nil
+}
+
+// registerMappingsRoute 在 SDK 内置的 Web 服务上注册映射导入导出接口
+func (d *LpMpDriver) registerMappingsRoute() error {
+	return d.sdk.AddCustomRoute(mappingsRoute, interfaces.Authenticated, func(c echo.Context) error {
+		format := strings.ToLower(c.QueryParam("format"))
+		if format == "" {
+			format = mappingFormatCSV
+		}
+
+		if c.Request().Method == http.MethodGet {
+			b, err := encodeMappings(format, exportMappings(c.QueryParam("device")))
+			if err != nil {
+				return c.String(http.StatusBadRequest, err.Error())
+			}
+			contentType := "text/csv; charset=utf-8"
+			if format == mappingFormatYAML {
+				contentType = "application/yaml; charset=utf-8"
+			}
+			return c.Blob(http.StatusOK, contentType, b)
+		}
+
+		dryRun := false
+		if v := c.QueryParam("dryRun"); v != "" {
+			var err error
+			if dryRun, err = strconv.ParseBool(v); err != nil {
+				return c.String(http.StatusBadRequest, fmt.Sprintf("dryRun %q 格式错误", v))
+			}
+		}
+		body, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			return c.String(http.StatusBadRequest, fmt.Sprintf("读取请求体失败: %v", err))
+		}
+		rows, err := decodeMappings(format, body)
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+		rep := d.planImport(rows)
+		rep.DryRun = dryRun
+		if len(rep.Errors) > 0 {
+			return c.JSON(http.StatusBadRequest, rep)
+		}
+		if !dryRun {
+			if err := d.applyImport(rep.Changes); err != nil {
+				rep.Errors = append(rep.Errors, err.Error())
+				return c.JSON(http.StatusInternalServerError, rep)
+			}
+		}
+		return c.JSON(http.StatusOK, rep)
+	}, http.MethodGet, http.MethodPost)
+}
@@ -0,0 +1,28 @@
+package driver
+
+import (
+	"net/http"
+
+	"github.com/edgexfoundry/device-sdk-go/v4/pkg/interfaces"
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/common"
+	"github.com/labstack/echo/v4"
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+)
+
+// resourceMetaRoute 查询设备资源的元数据（描述、UCUM 单位和显示名）：GET ?device=<设备名>&lang=zh|en
+const resourceMetaRoute = common.ApiBase + "/lpmp/resources"
+
+// registerResourceMetaRoute 在 SDK 内置的 Web 服务上注册资源元数据接口
+func (d *LpMpDriver) registerResourceMetaRoute() error {
+	return d.sdk.AddCustomRoute(resourceMetaRoute, interfaces.Authenticated, func(c echo.Context) error {
+		device := c.QueryParam("device")
+		if device == "" {
+			return c.String(http.StatusBadRequest, "需指定 device")
+		}
+		meta, ok := config.DeviceResourceMeta(device, c.QueryParam("lang"))
+		if !ok {
+			return c.String(http.StatusNotFound, "设备 "+device+" 不存在")
+		}
+		return c.JSON(http.StatusOK, meta)
+	}, http.MethodGet)
+}
@@ -0,0 +1,77 @@
+package driver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/edgexfoundry/device-sdk-go/v4/pkg/interfaces"
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/common"
+	"github.com/labstack/echo/v4"
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+	"github.com/linjuya-lu/device-lpmp-go/internal/objstore"
+)
+
+const (
+	// sduObjectResource 设备上可选的大 SDU 对象引用资源（String，JSON）
+	sduObjectResource = "sduObject"
+	// objectRoute 按 SHA-256 取回对象存储中的 SDU 原始数据
+	objectRoute = common.ApiBase + "/lpmp/objects/:sha256"
+)
+
+// registerObjectRoute 在 SDK 内置的 Web 服务上注册对象下载接口
+func (d *LpMpDriver) registerObjectRoute() error {
+	return d.sdk.AddCustomRoute(objectRoute, interfaces.Authenticated, func(c echo.Context) error {
+		if d.objects == nil {
+			return c.String(http.StatusNotFound, "未启用对象存储（LargeSDUThreshold）")
+		}
+		data, err := d.objects.Get(c.Param("sha256"))
+		if errors.Is(err, objstore.ErrNotFound) {
+			return c.String(http.StatusNotFound, err.Error())
+		}
+		if err != nil {
+			return c.String(http.StatusInternalServerError, err.Error())
+		}
+		return c.Blob(http.StatusOK, "application/octet-stream", data)
+	}, http.MethodGet)
+}
+
+// handleSDU 作为 frameparser 的 SDU 接收方：按配置导出到 spool，
+// 拼接完成且达到门限的 SDU 另存为对象并向绑定设备发布引用
+func (d *LpMpDriver) handleSDU(sensorID string, sseq uint8, data []byte, complete bool) {
+	if d.spool != nil {
+		if err := d.spool.Write(sensorID, sseq, data, complete); err != nil {
+			d.lc.Errorf("导出 SDU %s/%d 失败: %v", sensorID, sseq, err)
+		}
+	}
+	if d.objects == nil || !complete || len(data) < d.objects.Threshold() {
+		return
+	}
+	ref, err := d.objects.Put(data)
+	if err != nil {
+		d.lc.Errorf("保存 SDU %s/%d 到对象存储失败: %v", sensorID, sseq, err)
+		return
+	}
+	d.publishSDUObject(sensorID, sseq, ref)
+}
+
+// publishSDUObject 把对象引用作为 sduObject 读数交给 Sink，只发给 Profile 定义了该资源的绑定设备
+func (d *LpMpDriver) publishSDUObject(sensorID string, sseq uint8, ref objstore.Ref) {
+	payload, err := json.Marshal(ref)
+	if err != nil {
+		d.lc.Errorf("序列化对象引用失败: %v", err)
+		return
+	}
+	origin := time.Now()
+	tags := map[string]string{"sensorId": sensorID, "sseq": strconv.Itoa(int(sseq))}
+	for _, b := range config.LookupSensorBindings(sensorID) {
+		resName := b.Prefix + sduObjectResource
+		if !config.HasDeviceResource(b.DeviceName, resName) || !b.Accepts(resName) {
+			continue
+		}
+		d.sink.SetValue(b.DeviceName, resName, string(payload), origin, tags)
+		d.lc.Debugf("SDU %s/%d（%d 字节）已存为对象 %s，发布到 %s.%s", sensorID, sseq, ref.Size, ref.URI, b.DeviceName, resName)
+	}
+}
@@ -0,0 +1,198 @@
+package driver
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	dsModels "github.com/edgexfoundry/device-sdk-go/v4/pkg/models"
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+	"github.com/linjuya-lu/device-lpmp-go/internal/correlation"
+	"github.com/linjuya-lu/device-lpmp-go/internal/downlink"
+	"github.com/linjuya-lu/device-lpmp-go/internal/frameparser"
+)
+
+const (
+	// paramQueryResource 传感器设备上的参量查询资源（queryParams 命令）：读取时按查询参数 params
+	// 向传感器下发监测数据查询，返回各传感器应答中解码后的参量（JSON），供现场临时诊断
+	paramQueryResource = "paramQuery"
+	// paramQueryArg 查询参数：逗号分隔的参量类型码（如 0x00A3）或名称（资源名或参数表名称），省略时查询全部监测数据
+	paramQueryArg = "params"
+	// urlRawQueryAttr SDK 把 GET 命令的 URL 查询参数（已去掉 ds- 前缀的参数）放入请求属性的键名
+	urlRawQueryAttr = "urlRawQuery"
+)
+
+// paramQueryResult paramQuery 资源的值
+type paramQueryResult struct {
+	Time    time.Time           `json:"time"`
+	Types   []string            `json:"parameterTypes,omitempty"`
+	Sensors []sensorQueryResult `json:"sensors"`
+}
+
+// sensorQueryResult 一个传感器的应答，超时或下发失败时只有 Error
+type sensorQueryResult struct {
+	SensorID  string         `json:"sensorId"`
+	LatencyMs int64          `json:"latencyMs,omitempty"`
+	Params    []queriedParam `json:"params,omitempty"`
+	Error     string         `json:"error,omitempty"`
+}
+
+// queriedParam 应答中的一个参量；不在参数表中或无法解析时 Value 为原始数据的十六进制
+type queriedParam struct {
+	ParameterType string `json:"parameterType"`
+	Name          string `json:"name,omitempty"`
+	Value         any    `json:"value"`
+}
+
+// paramQueryTypes 从请求的 URL 查询参数中取出要查询的参量类型码
+func paramQueryTypes(deviceName string, reqs []dsModels.CommandRequest) ([]uint16, error) {
+	var raw string
+	for _, req := range reqs {
+		if req.DeviceResourceName != paramQueryResource {
+			continue
+		}
+		if s, ok := req.Attributes[urlRawQueryAttr].(string); ok {
+			raw = s
+		}
+	}
+	q, err := url.ParseQuery(raw)
+	if err != nil {
+		return nil, fmt.Errorf("查询参数 %q 格式错误: %w", raw, err)
+	}
+	var types []uint16
+	seen := make(map[uint16]bool)
+	for _, v := range q[paramQueryArg] {
+		for _, item := range strings.Split(v, ",") {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			t, err := resolveParamType(deviceName, item)
+			if err != nil {
+				return nil, err
+			}
+			if !seen[t] {
+				seen[t] = true
+				types = append(types, t)
+			}
+		}
+	}
+	if len(types) > frameparser.MaxExtendedParams {
+		return nil, fmt.Errorf("一次最多查询 %d 个参量，请求了 %d 个", frameparser.MaxExtendedParams, len(types))
+	}
+	return types, nil
+}
+
+// resolveParamType 把类型码或名称解析为参量类型码：先按数字解析，再查设备上声明了 parameterType 的资源，最后查参数表
+func resolveParamType(deviceName, s string) (uint16, error) {
+	if n, err := strconv.ParseUint(s, 0, 16); err == nil {
+		if n > 0x3FFF {
+			return 0, fmt.Errorf("参量类型 %s 超出 14bit 范围", s)
+		}
+		return uint16(n), nil
+	}
+	resources, _ := config.GetDeviceResources(deviceName)
+	for _, dr := range resources {
+		if dr.Name != s {
+			continue
+		}
+		if t, ok, err := config.ParamTypeFromAttributes(dr.Attributes); err == nil && ok {
+			return t, nil
+		}
+	}
+	entry, err := config.GetEntryCopy(s)
+	if err != nil {
+		return 0, fmt.Errorf("未知参量 %q: %w", s, err)
+	}
+	return entry.Head16 >> 2, nil
+}
+
+// handleParamQuery 向设备的全部传感器下发指定参量的监测数据查询，把应答写入 paramQuery 资源；
+// 单个传感器超时记入结果，不影响其它传感器
+func (d *LpMpDriver) handleParamQuery(deviceName string, reqs []dsModels.CommandRequest) error {
+	types, err := paramQueryTypes(deviceName, reqs)
+	if err != nil {
+		return err
+	}
+	prio, err := d.requestPriority(cmdLiveQuery, reqs)
+	if err != nil {
+		return err
+	}
+	sids := config.LookupSensorIDs(deviceName)
+	if len(sids) == 0 {
+		return fmt.Errorf("设备 %s 未配置 SensorID", deviceName)
+	}
+
+	port := d.currentPort()
+	if port == nil {
+		return fmt.Errorf("串口未打开")
+	}
+
+	result := paramQueryResult{Time: time.Now(), Sensors: make([]sensorQueryResult, len(sids))}
+	for _, t := range types {
+		result.Types = append(result.Types, fmt.Sprintf("0x%04X", t))
+	}
+	// 各传感器并发查询，超时和重发次数按传感器所属设备的配置，单个传感器失败记入结果
+	var wg sync.WaitGroup
+	for i, sid := range sids {
+		wg.Add(1)
+		go func(r *sensorQueryResult, sid string) {
+			defer wg.Done()
+			r.SensorID = sid
+			values, latency, err := d.queryParamTypes(port, deviceName, sid, types, prio)
+			if err != nil {
+				r.Error = err.Error()
+				return
+			}
+			r.LatencyMs = latency.Milliseconds()
+			for _, pv := range values {
+				r.Params = append(r.Params, queriedParamOf(deviceName, sid, pv))
+			}
+		}(&result.Sensors[i], sid)
+	}
+	wg.Wait()
+
+	b, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("序列化参量查询结果失败: %w", err)
+	}
+	config.SetDeviceValue(deviceName, paramQueryResource, string(b))
+	return nil
+}
+
+// queryParamTypes 向一个传感器下发指定参量的监测数据查询，返回应答中解码后的参量和往返时延
+func (d *LpMpDriver) queryParamTypes(port io.Writer, deviceName, sensorID string, types []uint16, prio downlink.Priority) ([]frameparser.ParamValue, time.Duration, error) {
+	id, err := frameparser.ParseSensorID(sensorID)
+	if err != nil {
+		return nil, 0, err
+	}
+	frame, err := frameparser.BuildMonitoringQueryFrame(id, types)
+	if err != nil {
+		return nil, 0, err
+	}
+	res, rtt, err := d.exchange(port, sensorID, correlation.Key{SensorID: sensorID, PacketType: frameparser.PacketTypeMonitoring}, frame, prio)
+	if err != nil {
+		return nil, 0, err
+	}
+	d.lc.Debugf("%s(SensorID=%s) 已应答参量查询 %v", deviceName, sensorID, types)
+	values, _ := res.Payload.([]frameparser.ParamValue)
+	return values, rtt, nil
+}
+
+// queriedParamOf 把解码后的参量转换为结果项，名称优先取设备上承载该参量的资源名
+func queriedParamOf(deviceName, sensorID string, pv frameparser.ParamValue) queriedParam {
+	q := queriedParam{ParameterType: fmt.Sprintf("0x%04X", pv.Type), Value: pv.Value}
+	if info, ok := config.LookupSensorParamInfo(sensorID, pv.Type); ok {
+		q.Name = config.ResolveResourceName(deviceName, pv.Type, info.Name)
+	}
+	if raw, ok := pv.Value.([]byte); ok {
+		q.Value = strings.ToUpper(hex.EncodeToString(raw))
+	}
+	return q
+}
@@ -0,0 +1,230 @@
+package driver
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	dsModels "github.com/edgexfoundry/device-sdk-go/v4/pkg/models"
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+	"github.com/linjuya-lu/device-lpmp-go/internal/correlation"
+	"github.com/linjuya-lu/device-lpmp-go/internal/downlink"
+	"github.com/linjuya-lu/device-lpmp-go/internal/frameparser"
+	"github.com/linjuya-lu/device-lpmp-go/internal/sessionlog"
+)
+
+const (
+	// sensorParamAttr 资源属性：为 true 时写该资源会向传感器下发通用参数设置报文，
+	// 资源须同时声明 parameterType
+	sensorParamAttr = "sensorParam"
+	// configDriftResource 设备上表示传感器参数与期望值不一致的资源
+	configDriftResource = "configDrift"
+
+	// 参数回读不一致时发布的系统事件
+	configEventType        = "lpmp-config"
+	configEventActionDrift = "drift"
+
+	// 差异来源：写入后回读 / 周期稽核
+	driftSourceWrite = "write-verify"
+	driftSourceAudit = "audit"
+)
+
+// sensorParamWrite 一个传感器待下发的参数，values 为确认后写入值表的资源值；
+// ctrlType 非 0 时为按资源属性构造的通用控制报文（见 controlWrite），否则为通用参数设置
+type sensorParamWrite struct {
+	sensorID   string
+	params     []frameparser.Param
+	values     map[string]any
+	priority   downlink.Priority
+	ctrlType   uint8
+	requestSet bool
+	data       []byte
+	// frame 已构造好的参数设置报文（原始参量写入，见 rawParamWrite），为空时按 params 构造
+	frame []byte
+	// writeID 写入队列中的 ID，入队时填写
+	writeID string
+}
+
+// trigger 返回这次写入在访问审计日志中的触发信息
+func (w sensorParamWrite) trigger(deviceName string) controlTrigger {
+	return controlTrigger{source: accessSourceWrite, device: deviceName, writeID: w.writeID, values: w.values}
+}
+
+// collectSensorParams 从写请求中挑出需要下发到传感器的参数，按 SensorID 分组；
+// 声明了 ctrlType 的资源和原始参量（rawParam）各自下发一帧报文，排在参数设置之后
+func collectSensorParams(deviceName string, reqs []dsModels.CommandRequest, values []*dsModels.CommandValue) ([]sensorParamWrite, error) {
+	groups := make(map[string]*sensorParamWrite)
+	var controls []sensorParamWrite
+	for i, req := range reqs {
+		cw, isControl, err := controlWrite(deviceName, req, values[i])
+		if err != nil {
+			return nil, err
+		}
+		if isControl {
+			controls = append(controls, cw)
+			continue
+		}
+		rw, isRaw, err := rawParamWrite(deviceName, req, values[i])
+		if err != nil {
+			return nil, err
+		}
+		if isRaw {
+			controls = append(controls, rw)
+			continue
+		}
+		if !attrBool(req.Attributes, sensorParamAttr) {
+			continue
+		}
+		paramType, ok, err := config.ParamTypeFromAttributes(req.Attributes)
+		if err != nil {
+			return nil, fmt.Errorf("资源 %s: %w", req.DeviceResourceName, err)
+		}
+		if !ok {
+			return nil, fmt.Errorf("资源 %s 标记为 %s 但未声明 %s", req.DeviceResourceName, sensorParamAttr, config.ParameterTypeAttr)
+		}
+		sid, ok := config.SensorForResource(deviceName, req.DeviceResourceName)
+		if !ok {
+			return nil, fmt.Errorf("设备 %s 未配置 SensorID", deviceName)
+		}
+		info, ok := config.LookupSensorParamInfo(sid, paramType)
+		if !ok {
+			return nil, fmt.Errorf("资源 %s 的参量类型 0x%04X 不在参数表中", req.DeviceResourceName, paramType)
+		}
+		data, err := config.EncodeParamValue(info, values[i].Value)
+		if err != nil {
+			return nil, err
+		}
+		w, ok := groups[sid]
+		if !ok {
+			w = &sensorParamWrite{sensorID: sid, values: make(map[string]any)}
+			groups[sid] = w
+		}
+		w.params = append(w.params, frameparser.Param{Type: paramType, Data: data})
+		w.values[req.DeviceResourceName] = values[i].Value
+	}
+
+	out := make([]sensorParamWrite, 0, len(groups))
+	for _, w := range groups {
+		out = append(out, *w)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].sensorID < out[j].sensorID })
+	return append(out, controls...), nil
+}
+
+// controlRoundTrip 下发一帧控制报文并等待对应传感器的控制响应，返回响应和往返时延，报文和结果记入访问审计日志
+func (d *LpMpDriver) controlRoundTrip(t controlTrigger, sensorID string, frame []byte, ctrlType uint8, wantSet bool, prio downlink.Priority) (resp frameparser.ControlResponse, rtt time.Duration, err error) {
+	start := time.Now()
+	defer func() {
+		d.recordControl(t, sensorID, false, ctrlType, wantSet, frame, start, err)
+	}()
+	port := d.currentPort()
+	if port == nil {
+		return frameparser.ControlResponse{}, 0, fmt.Errorf("串口未打开")
+	}
+	res, rtt, err := d.exchange(port, sensorID, correlation.Key{SensorID: sensorID, PacketType: frameparser.PacketTypeControlResp}, frame, prio)
+	if err != nil {
+		return frameparser.ControlResponse{}, rtt, fmt.Errorf("SensorID %s: %w", sensorID, err)
+	}
+	resp, _ = res.Payload.(frameparser.ControlResponse)
+	if resp.CtrlType != ctrlType || resp.RequestSet != wantSet {
+		return resp, rtt, fmt.Errorf("SensorID %s 的控制响应不匹配: CtrlType=%d RequestSet=%t", sensorID, resp.CtrlType, resp.RequestSet)
+	}
+	return resp, rtt, nil
+}
+
+// writeSensorParams 向传感器下发参数设置并等待确认，确认后记录期望值并在后台回读校验；返回往返时延
+func (d *LpMpDriver) writeSensorParams(deviceName string, w sensorParamWrite) (time.Duration, error) {
+	if w.ctrlType != 0 {
+		return d.writeControl(deviceName, w)
+	}
+	sid, err := frameparser.ParseSensorID(w.sensorID)
+	if err != nil {
+		return 0, err
+	}
+	frame := w.frame
+	if frame == nil {
+		if frame, err = frameparser.BuildParamSetFrame(sid, w.params); err != nil {
+			return 0, err
+		}
+	} else {
+		d.lc.Warnf("[write=%s] 设备 %s(SensorID=%s) 专家模式下发原始参量 0x%04X: % X", w.writeID, deviceName, w.sensorID, w.params[0].Type, frame)
+	}
+	_, rtt, err := d.controlRoundTrip(w.trigger(deviceName), w.sensorID, frame, frameparser.CtrlTypeGeneralParams, true, w.priority)
+	if err != nil {
+		return 0, fmt.Errorf("设备 %s 参数设置未确认: %w", deviceName, err)
+	}
+
+	desired := make(map[uint16][]byte, len(w.params))
+	for _, p := range w.params {
+		desired[p.Type] = p.Data
+	}
+	config.SetDesiredParams(w.sensorID, desired)
+	d.lc.Infof("设备 %s(SensorID=%s) 已确认 %d 个参数设置（往返 %s），开始回读校验", deviceName, w.sensorID, len(w.params), rtt)
+
+	// 部分传感器会静默截断越界的设置值，确认后再查询一次比对
+	go d.verifySensorParams(deviceName, w.sensorID, desired)
+	return rtt, nil
+}
+
+// querySensorParams 查询传感器全部通用参数并更新参数缓存，t 为触发查询的操作
+func (d *LpMpDriver) querySensorParams(t controlTrigger, sensorID string) (map[uint16][]byte, error) {
+	sid, err := frameparser.ParseSensorID(sensorID)
+	if err != nil {
+		return nil, err
+	}
+	frame, err := frameparser.BuildGeneralParamFrame(sid, 0, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, _, err := d.controlRoundTrip(t, sensorID, frame, frameparser.CtrlTypeGeneralParams, false, d.downlinkPrios[cmdParamQuery])
+	if err != nil {
+		return nil, err
+	}
+	params, err := resp.Params()
+	if err != nil {
+		return nil, fmt.Errorf("SensorID %s 参数应答格式错误: %w", sensorID, err)
+	}
+	reported := make(map[uint16][]byte, len(params))
+	for _, p := range params {
+		reported[p.Type] = p.Data
+	}
+	config.SetReportedParams(sensorID, reported)
+	return reported, nil
+}
+
+// verifySensorParams 回读传感器参数并与期望值比对，不一致时置 configDrift 并发布事件
+func (d *LpMpDriver) verifySensorParams(deviceName, sensorID string, desired map[uint16][]byte) {
+	reported, err := d.querySensorParams(controlTrigger{source: accessSourceWriteVerify, device: deviceName}, sensorID)
+	if err != nil {
+		d.lc.Warnf("设备 %s 参数回读失败: %v", deviceName, err)
+		return
+	}
+	drifted := config.DiffParams(desired, reported)
+	config.SetDeviceValue(deviceName, configDriftResource, len(drifted) > 0)
+	d.reportDrift(deviceName, sensorID, driftSourceWrite, drifted, desired, reported)
+}
+
+// reportDrift 记录并发布参数差异事件，无差异时只输出调试日志
+func (d *LpMpDriver) reportDrift(deviceName, sensorID, source string, drifted []uint16, desired, reported map[uint16][]byte) {
+	if len(drifted) == 0 {
+		d.lc.Debugf("设备 %s(SensorID=%s) 参数与期望值一致", deviceName, sensorID)
+		return
+	}
+	sort.Slice(drifted, func(i, j int) bool { return drifted[i] < drifted[j] })
+	items := make([]map[string]any, 0, len(drifted))
+	for _, t := range drifted {
+		items = append(items, map[string]any{
+			"parameterType": fmt.Sprintf("0x%04X", t),
+			"desired":       fmt.Sprintf("% X", desired[t]),
+			"reported":      fmt.Sprintf("% X", reported[t]),
+		})
+	}
+	d.lc.Warnf("设备 %s(SensorID=%s) 有 %d 个参数与期望值不一致: %v", deviceName, sensorID, len(drifted), items)
+	sessionlog.Default.Record(sensorID, sessionlog.KindConfig, "设备 %s 参数差异（%s）: %v", deviceName, source, items)
+	d.sdk.PublishGenericSystemEvent(configEventType, configEventActionDrift, map[string]any{
+		"device":     deviceName,
+		"sensorId":   sensorID,
+		"source":     source,
+		"parameters": items,
+	})
+}
@@ -0,0 +1,253 @@
+package driver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/models"
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+	"github.com/linjuya-lu/device-lpmp-go/internal/frameparser"
+)
+
+const (
+	// protocolName 设备协议属性中本驱动使用的协议段名称
+	protocolName = "lpmp"
+	// sensorIDsKey 设备包含的 SensorID 列表，逗号分隔，可带资源名前缀：
+	// "238A0821BEF2" 或 "238A0821BEF2:oil-,238A0821BEF3:winding-"
+	sensorIDsKey = "sensorIds"
+	// resourcesKey 可选，逗号分隔的资源名列表；同一传感器绑定多个设备时，
+	// 每个设备只接收列表中的资源
+	resourcesKey = "resources"
+	// heartbeatResponseKey 可选，收到该设备传感器的心跳时是否下发心跳响应，默认 true
+	heartbeatResponseKey = "heartbeatResponse"
+	// groupsKey 可选，逗号分隔的分组名；网关设备可按分组下发控制报文
+	groupsKey = "groups"
+	// dialectKey 可选，设备传感器的报文方言：已登记的名称，或 "crc=ccitt;crcOrder=little;values=swapped;header=1"，
+	// 压缩报文加 "compress=zlib;compressFlag=0:0x80"，CRC32 加帧类型字节的帧尾为 "crc=crc32;trailer=1"
+	// （见 frameparser.ParseDialect）
+	dialectKey = "dialect"
+	// rawFrameStreamKey 可选，为 true 时 rawFrame 资源的每一帧都作为异步读数上报，
+	// 默认只保存最近一帧，读取时返回
+	rawFrameStreamKey = "rawFrameStream"
+	// coalesceWindowKey 可选，如 "30s"：AutoEvents 轮询的资源若在该时长内已异步推送过，
+	// 本次读取不再返回，避免同一读数重复进入 core-data；默认 0 不合并
+	coalesceWindowKey = "coalesceWindow"
+	// commandTimeoutKey 可选，如 "60s"：等待该设备传感器应答下行命令的时长，默认为服务的 LiveQueryTimeout；
+	// 深度休眠的传感器需要较长的超时，市电供电的传感器可以更快失败
+	commandTimeoutKey = "commandTimeout"
+	// commandRetriesKey 可选，应答超时后重新下发的次数，默认 0
+	commandRetriesKey = "commandRetries"
+	// paramTableVersionKey 可选，设备传感器的固件使用的参数表版本（param_tables.yaml 中的版本名），
+	// 默认 v1 即内置参数表；新旧固件混用时按设备分别选择
+	paramTableVersionKey = "paramTableVersion"
+	// reportIntervalKey 可选，如 "15m"：设备传感器的定时上报周期，配置后按此统计应收上报数和数据完整率
+	// （completenessHourly / completenessDaily 及 GET /api/v3/lpmp/completeness），默认不统计
+	reportIntervalKey = "reportInterval"
+)
+
+// deviceOptions 设备协议属性中的驱动选项
+type deviceOptions struct {
+	HeartbeatResponse bool
+	Groups            []string
+	// Dialect 设备传感器的报文方言，nil 表示使用服务的默认方言（DefaultDialect）
+	Dialect        *frameparser.Dialect
+	RawFrameStream bool
+	CoalesceWindow time.Duration
+	// CommandTimeout 为 0 时使用服务的 LiveQueryTimeout
+	CommandTimeout time.Duration
+	CommandRetries int
+	// ParamTableVersion 为空时使用内置参数表
+	ParamTableVersion string
+	// ReportInterval 为 0 时不统计数据完整率
+	ReportInterval time.Duration
+}
+
+// parseDeviceOptions 解析设备协议属性中的驱动选项，未配置的项使用默认值
+func parseDeviceOptions(protocols map[string]models.ProtocolProperties) (deviceOptions, error) {
+	opts := deviceOptions{HeartbeatResponse: true}
+	if v, ok := protocolString(protocols, heartbeatResponseKey); ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, fmt.Errorf("%s.%s 配置无效 %q", protocolName, heartbeatResponseKey, v)
+		}
+		opts.HeartbeatResponse = b
+	}
+	if v, ok := protocolString(protocols, groupsKey); ok {
+		opts.Groups = splitList(v)
+	}
+	if v, ok := protocolString(protocols, rawFrameStreamKey); ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, fmt.Errorf("%s.%s 配置无效 %q", protocolName, rawFrameStreamKey, v)
+		}
+		opts.RawFrameStream = b
+	}
+	if v, ok := protocolString(protocols, coalesceWindowKey); ok {
+		w, err := time.ParseDuration(v)
+		if err != nil || w < 0 {
+			return opts, fmt.Errorf("%s.%s 配置无效 %q", protocolName, coalesceWindowKey, v)
+		}
+		opts.CoalesceWindow = w
+	}
+	if v, ok := protocolString(protocols, commandTimeoutKey); ok {
+		t, err := time.ParseDuration(v)
+		if err != nil || t <= 0 {
+			return opts, fmt.Errorf("%s.%s 配置无效 %q", protocolName, commandTimeoutKey, v)
+		}
+		opts.CommandTimeout = t
+	}
+	if v, ok := protocolString(protocols, commandRetriesKey); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return opts, fmt.Errorf("%s.%s 配置无效 %q", protocolName, commandRetriesKey, v)
+		}
+		opts.CommandRetries = n
+	}
+	if v, ok := protocolString(protocols, reportIntervalKey); ok {
+		t, err := time.ParseDuration(v)
+		if err != nil || t <= 0 {
+			return opts, fmt.Errorf("%s.%s 配置无效 %q", protocolName, reportIntervalKey, v)
+		}
+		opts.ReportInterval = t
+	}
+	if v, ok := protocolString(protocols, paramTableVersionKey); ok {
+		if err := config.CheckParamTableVersion(v); err != nil {
+			return opts, fmt.Errorf("%s.%s 配置无效: %w", protocolName, paramTableVersionKey, err)
+		}
+		opts.ParamTableVersion = v
+	}
+	if v, ok := protocolString(protocols, dialectKey); ok {
+		dialect, err := frameparser.ParseDialect(v)
+		if err != nil {
+			return opts, fmt.Errorf("%s.%s 配置无效: %w", protocolName, dialectKey, err)
+		}
+		opts.Dialect = dialect
+	}
+	return opts, nil
+}
+
+// applyDeviceProtocols 在设备加入或更新时应用其协议属性：绑定传感器并保存驱动选项
+func (d *LpMpDriver) applyDeviceProtocols(deviceName string, protocols map[string]models.ProtocolProperties) error {
+	if err := d.bindDeviceSensors(deviceName, protocols); err != nil {
+		return err
+	}
+	opts, err := parseDeviceOptions(protocols)
+	if err != nil {
+		return fmt.Errorf("设备 %s 的%w", deviceName, err)
+	}
+	d.devicesMu.Lock()
+	d.deviceOpts[deviceName] = opts
+	d.devicesMu.Unlock()
+	d.completeness.configure(deviceName, opts.ReportInterval, time.Now())
+	for _, sid := range config.LookupSensorIDs(deviceName) {
+		frameparser.SetSensorDialect(sid, opts.Dialect)
+		if err := config.SetSensorParamTable(sid, opts.ParamTableVersion); err != nil {
+			return fmt.Errorf("设备 %s 的%w", deviceName, err)
+		}
+	}
+	if opts.Dialect != nil {
+		d.lc.Infof("设备 %s 的传感器使用报文方言 %s", deviceName, opts.Dialect.Name)
+	}
+	if opts.ParamTableVersion != "" {
+		d.lc.Infof("设备 %s 的传感器使用参数表版本 %s", deviceName, opts.ParamTableVersion)
+	}
+	return nil
+}
+
+// deviceOptionsFor 返回设备的驱动选项，未登记的设备返回默认值
+func (d *LpMpDriver) deviceOptionsFor(deviceName string) deviceOptions {
+	d.devicesMu.RLock()
+	defer d.devicesMu.RUnlock()
+	if opts, ok := d.deviceOpts[deviceName]; ok {
+		return opts
+	}
+	return deviceOptions{HeartbeatResponse: true}
+}
+
+// commandPolicy 返回下发给 sensorID 的命令的应答超时和重发次数：传感器绑定了多个设备时取其中最宽松的配置，
+// 均未配置时超时为服务的 LiveQueryTimeout、不重发
+func (d *LpMpDriver) commandPolicy(sensorID string) (timeout time.Duration, retries int) {
+	timeout = d.liveQueryTimeout
+	configured := false
+	for _, b := range config.LookupSensorBindings(sensorID) {
+		opts := d.deviceOptionsFor(b.DeviceName)
+		if opts.CommandTimeout > 0 && (!configured || opts.CommandTimeout > timeout) {
+			timeout, configured = opts.CommandTimeout, true
+		}
+		retries = max(retries, opts.CommandRetries)
+	}
+	return timeout, retries
+}
+
+// forgetDevice 删除设备的驱动选项和推送记录并恢复其传感器的标准方言和内置参数表，需在解除 SensorID 绑定之前调用
+func (d *LpMpDriver) forgetDevice(deviceName string) {
+	d.pushes.forget(deviceName)
+	d.alarms.forget(deviceName)
+	if d.thresholds != nil {
+		d.thresholds.forget(deviceName)
+	}
+	if d.battery != nil {
+		d.battery.Forget(deviceName)
+	}
+	if d.sampler != nil {
+		d.sampler.Forget(deviceName)
+	}
+	d.completeness.forget(deviceName)
+	for _, sid := range config.LookupSensorIDs(deviceName) {
+		frameparser.SetSensorDialect(sid, nil)
+		_ = config.SetSensorParamTable(sid, "")
+	}
+	d.devicesMu.Lock()
+	delete(d.deviceOpts, deviceName)
+	d.devicesMu.Unlock()
+}
+
+// protocolString 读取协议属性中的字符串值
+func protocolString(protocols map[string]models.ProtocolProperties, key string) (string, bool) {
+	props, ok := protocols[protocolName]
+	if !ok {
+		return "", false
+	}
+	v, ok := props[key]
+	if !ok {
+		return "", false
+	}
+	s, ok := v.(string)
+	if !ok {
+		return fmt.Sprint(v), true
+	}
+	return s, true
+}
+
+// bindDeviceSensors 按协议属性中的 sensorIds 更新设备的传感器绑定；
+// 未声明 sensorIds 的设备保持 idToDevice.go 中的静态映射
+func (d *LpMpDriver) bindDeviceSensors(deviceName string, protocols map[string]models.ProtocolProperties) error {
+	raw, ok := protocolString(protocols, sensorIDsKey)
+	if !ok {
+		return nil
+	}
+	sensors, err := config.ParseSensorIDList(raw)
+	if err != nil {
+		return fmt.Errorf("设备 %s 的 %s.%s 无效: %w", deviceName, protocolName, sensorIDsKey, err)
+	}
+	var resources []string
+	if rs, ok := protocolString(protocols, resourcesKey); ok {
+		resources = splitList(rs)
+	}
+	config.BindSensors(deviceName, sensors, resources)
+	d.lc.Debugf("设备 %s 绑定传感器: %v, 资源过滤: %v", deviceName, sensors, resources)
+	return nil
+}
+
+// splitList 拆分逗号分隔的列表，忽略空项
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
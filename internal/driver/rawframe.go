@@ -0,0 +1,26 @@
+package driver
+
+import (
+	"strings"
+	"time"
+
+	dsModels "github.com/edgexfoundry/device-sdk-go/v4/pkg/models"
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/common"
+	"github.com/linjuya-lu/device-lpmp-go/internal/frameparser"
+)
+
+// streamRawFrame 作为 Sink 使用：设备协议属性 rawFrameStream 为 true 时，
+// 把 rawFrame 资源的每一帧作为异步读数推送给 SDK，不依赖 AutoEvents 轮询（否则只能读到最近一帧）
+func (d *LpMpDriver) streamRawFrame(deviceName, resourceName string, value any, origin time.Time, _ map[string]string) {
+	if !strings.HasSuffix(resourceName, frameparser.RawFrameResource) || !d.deviceOptionsFor(deviceName).RawFrameStream {
+		return
+	}
+	cv, err := dsModels.NewCommandValueWithOrigin(resourceName, common.ValueTypeBinary, value, origin.UnixNano())
+	if err != nil {
+		d.lc.Errorf("构造原始帧读数 %s.%s 失败: %v", deviceName, resourceName, err)
+		return
+	}
+	if !d.pushAsync(&dsModels.AsyncValues{DeviceName: deviceName, SourceName: resourceName, CommandValues: []*dsModels.CommandValue{cv}}, pushMonitoring) {
+		d.lc.Debugf("异步读数通道积压，丢弃 %s.%s 的原始帧", deviceName, resourceName)
+	}
+}
@@ -0,0 +1,103 @@
+package driver
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	dsModels "github.com/edgexfoundry/device-sdk-go/v4/pkg/models"
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+	"github.com/linjuya-lu/device-lpmp-go/internal/frameparser"
+)
+
+// rawParamAttr 资源属性：为 true 时写入值为 JSON 描述的原始参量（专家模式），驱动按给定的类型码、
+// LengthFlag 和数据构造通用参数设置报文下发，不查参数表；用于参数表尚未描述、现场须立即配置的新参量
+const rawParamAttr = "rawParam"
+
+// rawParamRequest 原始参量写入的 JSON，如 {"paramType": "0x00A3", "lenFlag": 0, "hex": "41200000"}；
+// lenFlag 省略时按数据长度自动选择（与参数表参量相同）
+type rawParamRequest struct {
+	ParamType string `json:"paramType"`
+	LenFlag   *int   `json:"lenFlag"`
+	Hex       string `json:"hex"`
+}
+
+// rawParamWrite 把声明了 rawParam 的写请求转换为一次待下发的参数设置，报文在此构造，
+// 格式错误在入队前返回；资源未声明 rawParam 时 ok 为 false
+func rawParamWrite(deviceName string, req dsModels.CommandRequest, cv *dsModels.CommandValue) (w sensorParamWrite, ok bool, err error) {
+	if !attrBool(req.Attributes, rawParamAttr) {
+		return w, false, nil
+	}
+	if attrBool(req.Attributes, sensorParamAttr) || req.Attributes[ctrlTypeAttr] != nil {
+		return w, false, fmt.Errorf("资源 %s 不能同时声明 %s 和 %s / %s", req.DeviceResourceName, rawParamAttr, sensorParamAttr, ctrlTypeAttr)
+	}
+	s, isString := cv.Value.(string)
+	if !isString {
+		return w, false, fmt.Errorf("资源 %s 的写入值须为 JSON 字符串，got %T", req.DeviceResourceName, cv.Value)
+	}
+
+	// 1. 解析参量
+	p, lenFlag, err := parseRawParam(s)
+	if err != nil {
+		return w, false, fmt.Errorf("资源 %s: %w", req.DeviceResourceName, err)
+	}
+
+	// 2. 目标传感器和报文
+	sid, ok := config.SensorForResource(deviceName, req.DeviceResourceName)
+	if !ok {
+		return w, false, fmt.Errorf("设备 %s 未配置 SensorID", deviceName)
+	}
+	id, err := frameparser.ParseSensorID(sid)
+	if err != nil {
+		return w, false, err
+	}
+	var frame []byte
+	if lenFlag < 0 {
+		frame, err = frameparser.BuildParamSetFrame(id, []frameparser.Param{p})
+	} else {
+		frame, err = frameparser.BuildRawParamSetFrame(id, p, lenFlag)
+	}
+	if err != nil {
+		return w, false, fmt.Errorf("资源 %s: %w", req.DeviceResourceName, err)
+	}
+	return sensorParamWrite{
+		sensorID: sid,
+		params:   []frameparser.Param{p},
+		values:   map[string]any{req.DeviceResourceName: s},
+		frame:    frame,
+	}, true, nil
+}
+
+// parseRawParam 解析原始参量 JSON，返回参量和 LengthFlag（未指定时为 -1）
+func parseRawParam(s string) (frameparser.Param, int, error) {
+	var r rawParamRequest
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&r); err != nil {
+		return frameparser.Param{}, 0, fmt.Errorf("原始参量 JSON 格式错误：%w", err)
+	}
+	if r.ParamType == "" {
+		return frameparser.Param{}, 0, fmt.Errorf("原始参量缺少 paramType")
+	}
+	t, err := strconv.ParseUint(strings.TrimSpace(r.ParamType), 0, 16)
+	if err != nil || t > 0x3FFF {
+		return frameparser.Param{}, 0, fmt.Errorf("原始参量 paramType=%q 无效，须为 14bit 类型码（如 \"0x00A3\"）", r.ParamType)
+	}
+	data, err := hex.DecodeString(strings.ReplaceAll(strings.TrimSpace(r.Hex), " ", ""))
+	if err != nil {
+		return frameparser.Param{}, 0, fmt.Errorf("原始参量 hex 须为十六进制字符串：%w", err)
+	}
+	if len(data) == 0 {
+		return frameparser.Param{}, 0, fmt.Errorf("原始参量 hex 不能为空")
+	}
+	lenFlag := -1
+	if r.LenFlag != nil {
+		if *r.LenFlag < 0 || *r.LenFlag > 3 {
+			return frameparser.Param{}, 0, fmt.Errorf("原始参量 lenFlag=%d 超出 0~3", *r.LenFlag)
+		}
+		lenFlag = *r.LenFlag
+	}
+	return frameparser.Param{Type: uint16(t), Data: data}, lenFlag, nil
+}
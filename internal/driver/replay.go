@@ -0,0 +1,241 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	dsModels "github.com/edgexfoundry/device-sdk-go/v4/pkg/models"
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+	"github.com/linjuya-lu/device-lpmp-go/internal/frameparser"
+	"github.com/linjuya-lu/device-lpmp-go/internal/serial"
+)
+
+const (
+	// captureDirKey Driver 配置项：抓包文件（lpmp-tail -capture 写出）所在目录，replay-capture 只接受其中的文件名
+	captureDirKey     = "CaptureDir"
+	defaultCaptureDir = "./captures"
+
+	// 网关设备上的回补资源：写入抓包文件名开始回放，读取最近一次回放的进度（JSON）
+	replayCaptureResource = "replay-capture"
+	replayStatusResource  = "replay-status"
+
+	// backfillTag 回补读数的标签，值为 "true"，用于在 core-data 中区分事后补录的数据
+	backfillTag = "backfill"
+
+	// 回放开始 / 结束时发布的 lpmp-link 事件
+	linkEventActionReplayStarted  = "replay-started"
+	linkEventActionReplayFinished = "replay-finished"
+)
+
+// 回放状态
+const (
+	replayRunning = "running"
+	replayDone    = "done"
+	replayFailed  = "failed"
+)
+
+// replayStatus replay-status 资源的内容
+type replayStatus struct {
+	File     string     `json:"file"`
+	State    string     `json:"state"`
+	Started  time.Time  `json:"started"`
+	Finished *time.Time `json:"finished,omitempty"`
+	// Frames 交给解析的帧数，Skipped 格式错误被跳过的行数，Readings 推送到 EdgeX 的读数数
+	Frames   uint64 `json:"frames"`
+	Skipped  uint64 `json:"skipped"`
+	Readings uint64 `json:"readings"`
+	Error    string `json:"error,omitempty"`
+}
+
+// replayer 以回补模式回放抓包文件：帧经独立的解析流水线（只产出读数，不影响当前设备状态），
+// 读数以抓包时间为 Origin、带 backfill=true 标签直接推送到 EdgeX，不写入值表；同一时间只有一次回放
+type replayer struct {
+	dir string
+
+	mu      sync.Mutex
+	running bool
+	status  *replayStatus
+
+	frames, skipped, readings atomic.Uint64
+}
+
+// replayConfig 读取抓包文件目录
+func replayConfig(cfg map[string]string) string {
+	if dir := cfg[captureDirKey]; dir != "" {
+		return dir
+	}
+	return defaultCaptureDir
+}
+
+// snapshot 返回最近一次回放的进度，没有回放过时返回 nil
+func (r *replayer) snapshot() *replayStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.status == nil {
+		return nil
+	}
+	st := *r.status
+	st.Frames, st.Skipped, st.Readings = r.frames.Load(), r.skipped.Load(), r.readings.Load()
+	return &st
+}
+
+// open 检查文件名并打开抓包文件，标记回放开始；已有回放进行中时返回错误
+func (r *replayer) open(name string) (*os.File, error) {
+	if name == "" || name != filepath.Base(name) || name == "." || name == ".." {
+		return nil, fmt.Errorf("抓包文件名 %q 无效，只能是 %s 目录下的文件名", name, captureDirKey)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.running {
+		return nil, fmt.Errorf("抓包文件 %s 正在回放，请等待完成", r.status.File)
+	}
+	f, err := os.Open(filepath.Join(r.dir, name))
+	if err != nil {
+		return nil, fmt.Errorf("打开抓包文件失败: %w", err)
+	}
+	r.running = true
+	r.status = &replayStatus{File: name, State: replayRunning, Started: time.Now()}
+	r.frames.Store(0)
+	r.skipped.Store(0)
+	r.readings.Store(0)
+	return f, nil
+}
+
+// finish 记录回放结果
+func (r *replayer) finish(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	r.running = false
+	r.status.Finished = &now
+	r.status.State = replayDone
+	if err != nil {
+		r.status.State, r.status.Error = replayFailed, err.Error()
+	}
+}
+
+// startReplay 网关 replayCapture 命令：在后台回放 CaptureDir 下的抓包文件，进度见 replay-status
+func (d *LpMpDriver) startReplay(deviceName, name string) error {
+	if d.replay == nil {
+		return fmt.Errorf("驱动尚未启动，无法回放抓包文件")
+	}
+	f, err := d.replay.open(name)
+	if err != nil {
+		return err
+	}
+	d.lc.Infof("开始回补抓包文件 %s", f.Name())
+	d.publishLinkEvent(linkEventActionReplayStarted, map[string]any{"file": name})
+	go func() {
+		defer f.Close()
+		err := d.runReplay(serial.NewCaptureReader(f))
+		d.replay.finish(err)
+		st := d.replay.snapshot()
+		d.updateReplayStatus(deviceName)
+		details := map[string]any{"file": name, "frames": st.Frames, "skipped": st.Skipped, "readings": st.Readings}
+		if err != nil {
+			details["error"] = err.Error()
+			d.lc.Errorf("回补抓包文件 %s 失败（已回补 %d 条读数）: %v", name, st.Readings, err)
+		} else {
+			d.lc.Infof("抓包文件 %s 回补完成: %d 帧、%d 条读数，跳过 %d 行", name, st.Frames, st.Readings, st.Skipped)
+		}
+		d.publishLinkEvent(linkEventActionReplayFinished, details)
+	}()
+	return nil
+}
+
+// runReplay 把抓包文件中的帧逐帧交给回补流水线，直到读完、读取失败或驱动停止
+func (d *LpMpDriver) runReplay(r *serial.CaptureReader) error {
+	in := make(chan serial.RxFrame)
+	p := frameparser.NewPipeline(frameparser.PipelineOptions{
+		Name:     "backfill",
+		Input:    in,
+		Sink:     frameparser.ValueSinkFunc(d.backfillReading),
+		Logger:   lcLogger{lc: d.lc},
+		Backfill: true,
+	})
+	p.Start(context.Background())
+	// 中断时 Stop 等待当前帧处理完
+	defer p.Stop()
+	for {
+		f, err := r.Next()
+		switch {
+		case errors.Is(err, io.EOF):
+			// 读完时关闭输入，等流水线解析完最后拼接完成的 SDU
+			close(in)
+			<-p.Done()
+			return nil
+		case errors.Is(err, serial.ErrMalformedDRXLine) || errors.Is(err, serial.ErrFrameTooLarge):
+			d.replay.skipped.Add(1)
+			d.lc.Debugf("回补跳过 %v", err)
+			continue
+		case err != nil:
+			return err
+		}
+		select {
+		case in <- f:
+			d.replay.frames.Add(1)
+		case <-d.stopCh:
+			return fmt.Errorf("驱动停止，回补中断")
+		}
+	}
+}
+
+// backfillReading 作为回补流水线的 Sink：读数以抓包时间为 Origin、带 backfill=true 标签推送到 EdgeX。
+// 回补不受实时读数的抽样保护，通道满时等待，驱动停止时放弃
+func (d *LpMpDriver) backfillReading(deviceName, resourceName string, value any, origin time.Time, tags map[string]string) {
+	valueType, ok := config.ResourceValueType(deviceName, resourceName)
+	if !ok {
+		return
+	}
+	cv, err := dsModels.NewCommandValueWithOrigin(resourceName, valueType, value, origin.UnixNano())
+	if err != nil {
+		d.lc.Errorf("构造回补读数 %s.%s 失败: %v", deviceName, resourceName, err)
+		return
+	}
+	cv.Tags = make(map[string]string, len(tags)+1)
+	for k, v := range tags {
+		cv.Tags[k] = v
+	}
+	cv.Tags[backfillTag] = "true"
+	select {
+	case d.asyncCh <- &dsModels.AsyncValues{DeviceName: deviceName, SourceName: resourceName, CommandValues: []*dsModels.CommandValue{cv}}:
+		d.replay.readings.Add(1)
+	case <-d.stopCh:
+	}
+}
+
+// updateReplayStatus 把最近一次回放的进度写入网关设备的 replay-status 资源
+func (d *LpMpDriver) updateReplayStatus(deviceName string) {
+	if d.replay == nil {
+		return
+	}
+	st := d.replay.snapshot()
+	if st == nil {
+		return
+	}
+	b, err := json.Marshal(st)
+	if err != nil {
+		d.lc.Errorf("序列化回补进度失败: %v", err)
+		return
+	}
+	config.SetDeviceValue(deviceName, replayStatusResource, string(b))
+}
+
+// replayRequested 返回网关写请求中 replay-capture 的文件名
+func replayRequested(reqs []dsModels.CommandRequest, values []*dsModels.CommandValue) (string, bool) {
+	for i, req := range reqs {
+		if req.DeviceResourceName == replayCaptureResource {
+			name, _ := values[i].Value.(string)
+			return name, true
+		}
+	}
+	return "", false
+}
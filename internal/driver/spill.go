@@ -0,0 +1,257 @@
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	dsModels "github.com/edgexfoundry/device-sdk-go/v4/pkg/models"
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+	"github.com/linjuya-lu/device-lpmp-go/internal/mqttpub"
+	"github.com/linjuya-lu/device-lpmp-go/internal/spill"
+)
+
+const (
+	// 网关设备上的磁盘溢出队列资源：积压的读数数 / 因超出 SpillMaxSizeMB 或 SpillRetention 未能重新发布的读数数
+	spillPendingResource = "spill-pending"
+	spillDroppedResource = "spill-dropped"
+
+	// spillReplayInterval 检查积压并尝试重新发布的周期
+	spillReplayInterval = time.Second
+
+	// 两个队列各占 SpillDir 下的一个子目录
+	spillEdgexDir = "edgex"
+	spillMQTTDir  = "mqtt"
+)
+
+// spilledValue 溢出到磁盘的一个 CommandValue，值为 json.Marshal 的结果，重新发布时按 Type 还原
+type spilledValue struct {
+	Resource string            `json:"resource"`
+	Type     string            `json:"type"`
+	Value    json.RawMessage   `json:"value"`
+	Origin   int64             `json:"origin"`
+	Tags     map[string]string `json:"tags,omitempty"`
+}
+
+// spilledAsync 溢出到磁盘的一组异步读数
+type spilledAsync struct {
+	Device string         `json:"device"`
+	Source string         `json:"source"`
+	Values []spilledValue `json:"values"`
+}
+
+// spillQueues 异步读数通道（core-data / 消息总线）和外部 MQTT 转发各自的溢出队列，
+// 分开存放，一方未恢复时不阻塞另一方的回放；mqtt 在未启用 MQTT 转发时为 nil
+type spillQueues struct {
+	edgex *spill.Queue
+	mqtt  *spill.Queue
+}
+
+// spillStats 统计快照中的溢出队列状态
+type spillStats struct {
+	Edgex spill.Stats  `json:"edgex"`
+	MQTT  *spill.Stats `json:"mqtt,omitempty"`
+}
+
+// openSpill 按配置打开溢出队列，上次运行遗留的积压在连接恢复后继续回放
+func openSpill(cfg spill.Config, withMQTT bool) (*spillQueues, error) {
+	q := &spillQueues{}
+	var err error
+	if q.edgex, err = spill.Open(filepath.Join(cfg.Dir, spillEdgexDir), cfg); err != nil {
+		return nil, err
+	}
+	if withMQTT {
+		if q.mqtt, err = spill.Open(filepath.Join(cfg.Dir, spillMQTTDir), cfg); err != nil {
+			q.edgex.Close()
+			return nil, err
+		}
+	}
+	return q, nil
+}
+
+func (q *spillQueues) stats() spillStats {
+	st := spillStats{Edgex: q.edgex.Stats()}
+	if q.mqtt != nil {
+		ms := q.mqtt.Stats()
+		st.MQTT = &ms
+	}
+	return st
+}
+
+// pending / dropped 两个队列合计的积压数和未能重新发布的读数数
+func (st spillStats) pending() int {
+	n := st.Edgex.Pending
+	if st.MQTT != nil {
+		n += st.MQTT.Pending
+	}
+	return n
+}
+
+func (st spillStats) dropped() int64 {
+	n := st.Edgex.Dropped + st.Edgex.Expired
+	if st.MQTT != nil {
+		n += st.MQTT.Dropped + st.MQTT.Expired
+	}
+	return n
+}
+
+// resetStats 清零两个队列的累计计数
+func (q *spillQueues) resetStats() {
+	q.edgex.ResetStats()
+	if q.mqtt != nil {
+		q.mqtt.ResetStats()
+	}
+}
+
+func (q *spillQueues) close() {
+	q.edgex.Close()
+	if q.mqtt != nil {
+		q.mqtt.Close()
+	}
+}
+
+// spillAsync 把未能交给 SDK 的异步读数写入磁盘队列，写入失败时返回 false（读数丢弃）
+func (d *LpMpDriver) spillAsync(av *dsModels.AsyncValues) bool {
+	rec := spilledAsync{Device: av.DeviceName, Source: av.SourceName, Values: make([]spilledValue, 0, len(av.CommandValues))}
+	for _, cv := range av.CommandValues {
+		raw, err := json.Marshal(cv.Value)
+		if err != nil {
+			d.lc.Errorf("读数 %s.%s 无法写入溢出队列: %v", av.DeviceName, cv.DeviceResourceName, err)
+			return false
+		}
+		rec.Values = append(rec.Values, spilledValue{Resource: cv.DeviceResourceName, Type: cv.Type, Value: raw, Origin: cv.Origin, Tags: cv.Tags})
+	}
+	if err := d.pushSpill(d.spill.edgex, rec); err != nil {
+		d.lc.Errorf("读数 %s.%s 写入溢出队列失败: %v", av.DeviceName, av.SourceName, err)
+		return false
+	}
+	return true
+}
+
+// forwardMQTT 作为 MQTT 转发 Sink：Broker 未连接或溢出队列还有积压时写入队列，保持发布顺序
+func (d *LpMpDriver) forwardMQTT(deviceName, resourceName string, value any, origin int64) {
+	if d.spill == nil {
+		d.mqttPub.Publish(deviceName, resourceName, value, origin)
+		return
+	}
+	if d.spill.mqtt.Len() == 0 && d.mqttPub.Publish(deviceName, resourceName, value, origin) {
+		return
+	}
+	if err := d.pushSpill(d.spill.mqtt, mqttpub.Reading{DeviceName: deviceName, ResourceName: resourceName, Value: value, Origin: origin}); err != nil {
+		d.lc.Errorf("MQTT 转发读数 %s.%s 写入溢出队列失败: %v", deviceName, resourceName, err)
+	}
+}
+
+// pushSpill 序列化记录并追加到队列，队列由空变为有积压时记录日志
+func (d *LpMpDriver) pushSpill(q *spill.Queue, rec any) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	first := q.Len() == 0
+	if err := q.Push(b, time.Now()); err != nil {
+		return err
+	}
+	if first {
+		d.lc.Warnf("读数开始写入磁盘溢出队列 %s，连接恢复后按顺序重新发布", q.Dir())
+	}
+	return nil
+}
+
+// decodeSpilled 把磁盘记录还原为异步读数
+func decodeSpilled(b []byte) (*dsModels.AsyncValues, error) {
+	var rec spilledAsync
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return nil, err
+	}
+	av := &dsModels.AsyncValues{DeviceName: rec.Device, SourceName: rec.Source}
+	for _, v := range rec.Values {
+		value, err := config.ParseJSONValue(v.Value, v.Type)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", rec.Device, v.Resource, err)
+		}
+		cv, err := dsModels.NewCommandValueWithOrigin(v.Resource, v.Type, value, v.Origin)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", rec.Device, v.Resource, err)
+		}
+		if v.Tags != nil {
+			cv.Tags = v.Tags
+		}
+		av.CommandValues = append(av.CommandValues, cv)
+	}
+	return av, nil
+}
+
+// runSpillReplay 周期检查溢出队列：异步读数通道低于过载水位时按顺序重新交给 SDK，
+// MQTT 转发重新连上后按顺序重新发布；直到驱动停止
+func (d *LpMpDriver) runSpillReplay() {
+	ticker := time.NewTicker(spillReplayInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.stopCh:
+			return
+		case <-ticker.C:
+		}
+		d.replaySpill(time.Now())
+	}
+}
+
+// replaySpill 执行一次回放
+func (d *LpMpDriver) replaySpill(now time.Time) {
+	if d.spill.edgex.Len() > 0 {
+		n, err := d.spill.edgex.Drain(now, func(b []byte) bool {
+			av, err := decodeSpilled(b)
+			if err != nil {
+				d.lc.Errorf("溢出队列中的记录无法还原，跳过: %v", err)
+				return true
+			}
+			if d.async.overloadedAt(len(d.asyncCh), cap(d.asyncCh)) {
+				return false
+			}
+			select {
+			case d.asyncCh <- av:
+				return true
+			default:
+				return false
+			}
+		})
+		d.logSpillReplay("core-data", d.spill.edgex, n, err)
+	}
+	if d.spill.mqtt != nil && d.spill.mqtt.Len() > 0 && d.mqttPub.Connected() {
+		n, err := d.spill.mqtt.Drain(now, func(b []byte) bool {
+			var r mqttpub.Reading
+			if err := json.Unmarshal(b, &r); err != nil {
+				d.lc.Errorf("溢出队列中的记录无法还原，跳过: %v", err)
+				return true
+			}
+			return d.mqttPub.Publish(r.DeviceName, r.ResourceName, r.Value, r.Origin)
+		})
+		d.logSpillReplay("MQTT", d.spill.mqtt, n, err)
+	}
+}
+
+func (d *LpMpDriver) logSpillReplay(target string, q *spill.Queue, n int, err error) {
+	if err != nil {
+		d.lc.Errorf("回放 %s 溢出队列失败: %v", target, err)
+	}
+	if n == 0 {
+		return
+	}
+	if left := q.Len(); left > 0 {
+		d.lc.Debugf("已向 %s 重新发布 %d 个溢出的读数，剩余 %d 个", target, n, left)
+	} else {
+		d.lc.Infof("%s 溢出队列已回放完毕，本次重新发布 %d 个读数", target, n)
+	}
+}
+
+// updateSpill 把溢出队列状态写入网关设备的 spill-* 资源
+func (d *LpMpDriver) updateSpill(deviceName string) {
+	if d.spill == nil {
+		return
+	}
+	st := d.spill.stats()
+	config.SetDeviceValue(deviceName, spillPendingResource, uint32(st.pending()))
+	config.SetDeviceValue(deviceName, spillDroppedResource, uint32(st.dropped()))
+}
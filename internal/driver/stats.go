@@ -0,0 +1,133 @@
+package driver
+
+import (
+	"encoding/json"
+	"time"
+
+	dsModels "github.com/edgexfoundry/device-sdk-go/v4/pkg/models"
+	"github.com/linjuya-lu/device-lpmp-go/internal/clockguard"
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+	"github.com/linjuya-lu/device-lpmp-go/internal/frameparser"
+	"github.com/linjuya-lu/device-lpmp-go/internal/serial"
+	"github.com/linjuya-lu/device-lpmp-go/internal/tlsconf"
+)
+
+const (
+	// 网关设备上的统计资源：写 true 清零流水线计数 / 读取全部指标的 JSON 快照
+	resetStatsResource    = "reset-stats"
+	statsSnapshotResource = "stats-snapshot"
+)
+
+// gatewayStats snapshotStats 命令返回的指标快照
+type gatewayStats struct {
+	Time            time.Time         `json:"time"`
+	LinkUp          bool              `json:"linkUp"`
+	Pipeline        frameparser.Stats `json:"pipeline"`
+	DownlinkPending int               `json:"downlinkPending"`
+	// Reader 链路读取时恢复的错误（超长行、暂时性读错误）
+	Reader serial.ReaderStats `json:"reader"`
+	// AsyncShed 异步读数通道过载时丢弃的读数
+	AsyncShed uint64 `json:"asyncShed"`
+	// DutyCycleRemainingMs 未启用 DutyCycleLimit 时省略
+	DutyCycleRemainingMs *uint32 `json:"dutyCycleRemainingMs,omitempty"`
+	// Clock 未启用 ClockPolicy 时省略
+	Clock *clockguard.Stats `json:"clock,omitempty"`
+	// TLS 各 TLS 连接最近一次握手的状态和证书到期时间，未启用 TLS 时省略
+	TLS map[string]tlsconf.State `json:"tls,omitempty"`
+	// Ingest 接收控制状态，Start 之前省略
+	Ingest *ingestStats `json:"ingest,omitempty"`
+	// Latency 命令往返时延，Start 之前省略
+	Latency *latencySnapshot `json:"latency,omitempty"`
+	// Spill 磁盘溢出队列，未启用 SpillEnabled 时省略
+	Spill *spillStats `json:"spill,omitempty"`
+}
+
+// hasResource 判断请求中是否包含指定资源
+func hasResource(reqs []dsModels.CommandRequest, resourceName string) bool {
+	for _, req := range reqs {
+		if req.DeviceResourceName == resourceName {
+			return true
+		}
+	}
+	return false
+}
+
+// boolRequested 判断写请求中是否有 resourceName=true
+func boolRequested(reqs []dsModels.CommandRequest, values []*dsModels.CommandValue, resourceName string) bool {
+	for i, req := range reqs {
+		if req.DeviceResourceName == resourceName {
+			if on, _ := values[i].Value.(bool); on {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// snapshotStats 采集当前全部指标并写入网关设备的 stats-snapshot 资源
+func (d *LpMpDriver) snapshotStats(deviceName string) {
+	st := gatewayStats{
+		Time:      time.Now(),
+		LinkUp:    d.currentPort() != nil,
+		Pipeline:  frameparser.SnapshotStats(),
+		Reader:    serial.SnapshotReaderStats(),
+		AsyncShed: d.async.shed.Load(),
+	}
+	if d.downlink != nil {
+		st.DownlinkPending = len(d.downlink.Pending())
+	}
+	if ms, ok := d.dutyCycleRemaining(); ok {
+		st.DutyCycleRemainingMs = &ms
+	}
+	if d.clock != nil {
+		cs := d.clock.Stats()
+		st.Clock = &cs
+	}
+	st.TLS = d.tlsStates()
+	if d.ingest != nil {
+		is := d.ingest.stats()
+		st.Ingest = &is
+	}
+	if d.latency != nil {
+		ls := d.latency.snapshot()
+		st.Latency = &ls
+	}
+	if d.spill != nil {
+		ss := d.spill.stats()
+		st.Spill = &ss
+	}
+	b, err := json.Marshal(st)
+	if err != nil {
+		d.lc.Errorf("序列化统计快照失败: %v", err)
+		return
+	}
+	config.SetDeviceValue(deviceName, statsSnapshotResource, string(b))
+}
+
+// resetStatsRequested 判断网关写请求中是否有 reset-stats=true
+func resetStatsRequested(reqs []dsModels.CommandRequest, values []*dsModels.CommandValue) bool {
+	return boolRequested(reqs, values, resetStatsResource)
+}
+
+// resetStats 清零流水线计数、链路读取恢复计数、异步通道丢弃数、往返时延记录和溢出队列计数，并同步网关上的计数资源
+func (d *LpMpDriver) resetStats(deviceName string) {
+	frameparser.ResetStats()
+	serial.ResetReaderStats()
+	d.async.shed.Store(0)
+	config.SetDeviceValue(deviceName, asyncShedResource, uint32(0))
+	if d.ingest != nil {
+		d.ingest.dropped.Store(0)
+		config.SetDeviceValue(deviceName, ingestDroppedResource, uint32(0))
+	}
+	config.SetDeviceValue(deviceName, filteredDeniedResource, uint32(0))
+	config.SetDeviceValue(deviceName, filteredNotAllowedResource, uint32(0))
+	if d.latency != nil {
+		d.latency.reset()
+		d.updateLatency(deviceName)
+	}
+	if d.spill != nil {
+		d.spill.resetStats()
+		d.updateSpill(deviceName)
+	}
+	d.lc.Infof("网关 %s 的流水线计数已清零", deviceName)
+}
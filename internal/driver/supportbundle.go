@@ -0,0 +1,153 @@
+package driver
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/edgexfoundry/device-sdk-go/v4/pkg/interfaces"
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/common"
+	"github.com/labstack/echo/v4"
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+	"github.com/linjuya-lu/device-lpmp-go/internal/frameparser"
+	"github.com/linjuya-lu/device-lpmp-go/internal/sessionlog"
+)
+
+const (
+	// supportBundleRoute 导出设备支持包（ZIP），附到厂家工单
+	supportBundleRoute = common.ApiBase + "/lpmp/support-bundle/:device"
+	// supportBundleSDUs 每个传感器附带的最近 SDU 原始数据文件数
+	supportBundleSDUs = 20
+)
+
+// registerSupportBundleRoute 在 SDK 内置的 Web 服务上注册支持包导出接口
+func (d *LpMpDriver) registerSupportBundleRoute() error {
+	return d.sdk.AddCustomRoute(supportBundleRoute, interfaces.Authenticated, func(c echo.Context) error {
+		deviceName := c.Param("device")
+		data, err := d.buildSupportBundle(deviceName)
+		if err != nil {
+			return c.String(http.StatusNotFound, err.Error())
+		}
+		name := fmt.Sprintf("lpmp-%s-%s.zip", deviceName, time.Now().Format("20060102T150405"))
+		c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", name))
+		return c.Blob(http.StatusOK, "application/zip", data)
+	}, http.MethodGet)
+}
+
+// sensorParamsDump 传感器参数缓存，数据以十六进制表示
+type sensorParamsDump struct {
+	Reported map[string]string `json:"reported"`
+	Desired  map[string]string `json:"desired"`
+}
+
+// hexParams 将参数缓存转换为 "0x00A3" → "01 02 03 04" 形式
+func hexParams(params map[uint16][]byte) map[string]string {
+	out := make(map[string]string, len(params))
+	for t, data := range params {
+		out[fmt.Sprintf("0x%04X", t)] = fmt.Sprintf("% X", data)
+	}
+	return out
+}
+
+// buildSupportBundle 打包设备的诊断信息：
+//
+//	manifest.json                 设备、传感器、驱动选项、解析错误计数和生成时间
+//	values.json                   值表中的当前资源值
+//	sensors/<SID>/session.json    最近的帧摘要、错误和配置变更
+//	sensors/<SID>/params.json     传感器参数上报值与期望值
+//	sensors/<SID>/conformance.json 一致性校验统计（开启过校验时）
+//	sensors/<SID>/sdu/*.bin       最近导出的 SDU 原始数据（启用 SpoolEnabled 时）
+func (d *LpMpDriver) buildSupportBundle(deviceName string) ([]byte, error) {
+	values, ok := config.GetDeviceValues(deviceName)
+	if !ok {
+		return nil, fmt.Errorf("设备 %s 不存在", deviceName)
+	}
+	sensors := config.LookupSensorIDs(deviceName)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	writeJSON := func(name string, v any) error {
+		w, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	// 1. 设备概况和当前值
+	opts := d.deviceOptionsFor(deviceName)
+	dialect := opts.Dialect
+	if dialect == nil {
+		dialect = d.dialect
+	}
+	if dialect == nil {
+		dialect = frameparser.StandardDialect
+	}
+	if err := writeJSON("manifest.json", map[string]any{
+		"device":            deviceName,
+		"sensors":           sensors,
+		"heartbeatResponse": opts.HeartbeatResponse,
+		"groups":            opts.Groups,
+		"dialect":           dialect.Name,
+		"parseErrors":       frameparser.ErrorCounts(),
+		"generatedAt":       time.Now().Format(time.RFC3339),
+	}); err != nil {
+		return nil, err
+	}
+	if err := writeJSON("values.json", values); err != nil {
+		return nil, err
+	}
+
+	// 2. 各传感器的活动记录、参数缓存、一致性统计和最近的 SDU
+	reports := make(map[string]frameparser.ConformanceReport)
+	for _, r := range frameparser.ConformanceReports() {
+		reports[r.SensorID] = r
+	}
+	for _, sid := range sensors {
+		dir := path.Join("sensors", sid)
+		if err := writeJSON(path.Join(dir, "session.json"), sessionlog.Default.Snapshot(sid)); err != nil {
+			return nil, err
+		}
+		if err := writeJSON(path.Join(dir, "params.json"), sensorParamsDump{
+			Reported: hexParams(config.GetReportedParams(sid)),
+			Desired:  hexParams(config.GetDesiredParams(sid)),
+		}); err != nil {
+			return nil, err
+		}
+		if r, ok := reports[sid]; ok {
+			if err := writeJSON(path.Join(dir, "conformance.json"), r); err != nil {
+				return nil, err
+			}
+		}
+		if d.spool == nil {
+			continue
+		}
+		for _, p := range d.spool.Recent(sid, supportBundleSDUs) {
+			data, err := os.ReadFile(p)
+			if err != nil {
+				// 文件可能刚被容量清理删除
+				continue
+			}
+			w, err := zw.Create(path.Join(dir, "sdu", filepath.Base(p)))
+			if err != nil {
+				return nil, err
+			}
+			if _, err := w.Write(data); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
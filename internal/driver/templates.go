@@ -0,0 +1,176 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/models"
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+	"github.com/linjuya-lu/device-lpmp-go/internal/frameparser"
+)
+
+// provisionRetryInterval 按模板创建设备失败后，同一 SensorID 再次尝试的最短间隔
+const provisionRetryInterval = 10 * time.Minute
+
+// DeviceTemplate LpmpCustom.DeviceTemplates 中的一个设备模板：收到未绑定到任何设备的 SensorID 的帧时，
+// 按前缀匹配模板，以统一的 profile、设备名和协议属性自动创建设备，省去大批量部署时逐个登记
+type DeviceTemplate struct {
+	// SensorIDPrefix 匹配的 SensorID 前缀（十六进制），为空匹配所有；多个模板匹配时取前缀最长的
+	SensorIDPrefix string
+	// Profile 创建设备使用的 profile，须在 profiles 目录中
+	Profile string
+	// NamePattern 设备名模式：{sensorId} 为完整 SensorID，{lastN}（N 为 1~12）为其后 N 位，如 "WaterLevel-{last4}"
+	NamePattern string
+	Description string
+	Labels      []string
+	// Protocols 创建设备的 lpmp 协议属性（如 commandTimeout、dialect），sensorIds 由驱动填写
+	Protocols map[string]string
+}
+
+// namePlaceholder NamePattern 中的占位符
+var namePlaceholder = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// expandName 按 NamePattern 生成 sensorID 对应的设备名
+func expandName(pattern, sensorID string) (string, error) {
+	var err error
+	name := namePlaceholder.ReplaceAllStringFunc(pattern, func(m string) string {
+		key := m[1 : len(m)-1]
+		if key == "sensorId" {
+			return sensorID
+		}
+		if n, e := strconv.Atoi(strings.TrimPrefix(key, "last")); strings.HasPrefix(key, "last") && e == nil && n >= 1 && n <= len(sensorID) {
+			return sensorID[len(sensorID)-n:]
+		}
+		if err == nil {
+			err = fmt.Errorf("NamePattern %q 中的占位符 %s 无效，可选 {sensorId}、{last1}~{last12}", pattern, m)
+		}
+		return m
+	})
+	return name, err
+}
+
+// compileDeviceTemplates 校验模板并统一 SensorIDPrefix 为大写
+func compileDeviceTemplates(ts []DeviceTemplate) ([]DeviceTemplate, error) {
+	out := make([]DeviceTemplate, 0, len(ts))
+	seen := make(map[string]bool, len(ts))
+	for i, t := range ts {
+		where := fmt.Sprintf("%s.DeviceTemplates[%d]", customConfigSection, i)
+		t.SensorIDPrefix = strings.ToUpper(strings.TrimSpace(t.SensorIDPrefix))
+		if t.Profile == "" || t.NamePattern == "" {
+			return nil, fmt.Errorf("%s 须填写 Profile 和 NamePattern", where)
+		}
+		if strings.Trim(t.SensorIDPrefix, "0123456789ABCDEF") != "" || len(t.SensorIDPrefix) > 12 {
+			return nil, fmt.Errorf("%s 的 SensorIDPrefix %q 不是十六进制 SensorID 前缀", where, t.SensorIDPrefix)
+		}
+		if seen[t.SensorIDPrefix] {
+			return nil, fmt.Errorf("%s 的 SensorIDPrefix %q 与前面的模板重复", where, t.SensorIDPrefix)
+		}
+		seen[t.SensorIDPrefix] = true
+		if _, err := expandName(t.NamePattern, "000000000000"); err != nil {
+			return nil, fmt.Errorf("%s %w", where, err)
+		}
+		if _, ok := t.Protocols[sensorIDsKey]; ok {
+			return nil, fmt.Errorf("%s 的 Protocols 不能包含 %s，由驱动按收到的 SensorID 填写", where, sensorIDsKey)
+		}
+		props := make(models.ProtocolProperties, len(t.Protocols))
+		for k, v := range t.Protocols {
+			props[k] = v
+		}
+		if _, err := parseDeviceOptions(map[string]models.ProtocolProperties{protocolName: props}); err != nil {
+			return nil, fmt.Errorf("%s 的 Protocols %w", where, err)
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+// matchTemplate 返回 SensorIDPrefix 匹配 sensorID 且最长的模板
+func matchTemplate(ts []DeviceTemplate, sensorID string) (DeviceTemplate, bool) {
+	best, found := DeviceTemplate{}, false
+	for _, t := range ts {
+		if strings.HasPrefix(sensorID, t.SensorIDPrefix) && (!found || len(t.SensorIDPrefix) > len(best.SensorIDPrefix)) {
+			best, found = t, true
+		}
+	}
+	return best, found
+}
+
+// provisionSensor 按模板为 sensorID 创建设备：加载 profile 的资源和默认值，在 core-metadata 中登记设备并绑定 SensorID
+func (d *LpMpDriver) provisionSensor(t DeviceTemplate, sensorID string) error {
+	name, err := expandName(t.NamePattern, sensorID)
+	if err != nil {
+		return err
+	}
+	if d.sdk.DeviceExistsForName(name) {
+		return fmt.Errorf("设备名 %s 已被占用（NamePattern %q 生成的设备名重复）", name, t.NamePattern)
+	}
+	if err := config.LoadDeviceProfile(name, t.Profile, d.profilesDir); err != nil {
+		return err
+	}
+	props := models.ProtocolProperties{sensorIDsKey: sensorID}
+	for k, v := range t.Protocols {
+		props[k] = v
+	}
+	protocols := map[string]models.ProtocolProperties{protocolName: props}
+	dev := models.Device{
+		Name:           name,
+		Description:    t.Description,
+		Labels:         t.Labels,
+		ProfileName:    t.Profile,
+		AdminState:     models.Unlocked,
+		OperatingState: models.Up,
+		Protocols:      protocols,
+	}
+	if _, err := d.sdk.AddDevice(dev); err != nil {
+		return fmt.Errorf("创建设备 %s 失败: %w", name, err)
+	}
+	// SDK 回调 AddDevice 之前到达的帧也能分发到新设备
+	return d.applyDeviceProtocols(name, protocols)
+}
+
+// startProvisioning 订阅帧级事件，收到未绑定 SensorID 的帧时按 LpmpCustom.DeviceTemplates 创建设备；
+// 触发创建的这一帧已被丢弃，设备从下一帧起接收读数
+func (d *LpMpDriver) startProvisioning(templates []DeviceTemplate) {
+	events := frameparser.SubscribeSensorEvents(sensorEventBuffer)
+	// failed 创建失败的 SensorID 及时间，provisionRetryInterval 内不再尝试
+	failed := make(map[string]time.Time)
+	go func() {
+		for {
+			var ev frameparser.SensorEvent
+			select {
+			case <-d.stopCh:
+				return
+			case ev = <-events:
+			}
+			if ev.Kind != frameparser.SensorFrameRejected || !errors.Is(ev.Err, frameparser.ErrUnknownSensor) {
+				continue
+			}
+			// 同一传感器的后续帧可能在创建之前已排队
+			if len(config.LookupSensorBindings(ev.SensorID)) > 0 {
+				continue
+			}
+			if t, ok := failed[ev.SensorID]; ok && time.Since(t) < provisionRetryInterval {
+				continue
+			}
+			t, ok := matchTemplate(templates, ev.SensorID)
+			if !ok {
+				continue
+			}
+			if err := d.provisionSensor(t, ev.SensorID); err != nil {
+				failed[ev.SensorID] = time.Now()
+				d.lc.Errorf("按模板（SensorIDPrefix=%q）为 SensorID=%s 创建设备失败: %v", t.SensorIDPrefix, ev.SensorID, err)
+				continue
+			}
+			delete(failed, ev.SensorID)
+			details := sensorDetails(ev.SensorID)
+			details["profile"] = t.Profile
+			details["sensorIdPrefix"] = t.SensorIDPrefix
+			d.lc.Infof("已按模板为 SensorID=%s 创建设备 %v（profile=%s）", ev.SensorID, details["devices"], t.Profile)
+			d.sdk.PublishGenericSystemEvent(deviceEventType, deviceEventActionProvisioned, details)
+		}
+	}()
+}
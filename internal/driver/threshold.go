@@ -0,0 +1,311 @@
+package driver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+)
+
+const (
+	// 数值资源的本地门限告警属性，用于本身不上报告警报文的传感器：
+	// 读数高于 alarmHigh 或低于 alarmLow 时告警，回到门限内 alarmHysteresis 以上才解除，
+	// 同一资源两次告警至少间隔 alarmCooldown（如 "5m"）
+	alarmHighAttr       = "alarmHigh"
+	alarmLowAttr        = "alarmLow"
+	alarmHysteresisAttr = "alarmHysteresis"
+	alarmCooldownAttr   = "alarmCooldown"
+
+	// 门限告警/解除时发布的系统事件
+	thresholdEventType          = "lpmp-threshold"
+	thresholdEventActionRaised  = "raised"
+	thresholdEventActionCleared = "cleared"
+
+	// alarmSourceThreshold 门限告警在告警历史中的来源
+	alarmSourceThreshold = "threshold"
+)
+
+// ThresholdRule LpmpCustom.Thresholds 中的一条门限，覆盖 profile 中同一资源的门限属性；
+// 数值项为空表示不设置
+type ThresholdRule struct {
+	// Device 设备名，"*" 匹配所有具有该资源的设备
+	Device   string
+	Resource string
+	// High / Low 上下限，至少填一项
+	High string
+	Low  string
+	// Hysteresis 解除告警的回差，默认 0
+	Hysteresis string
+	// Cooldown 同一资源两次告警的最小间隔，如 "5m"，默认不限制
+	Cooldown string
+}
+
+// threshold 一个资源生效的门限
+type threshold struct {
+	high, low       float64
+	hasHigh, hasLow bool
+	hysteresis      float64
+	cooldown        time.Duration
+}
+
+// 门限状态：正常、高于上限、低于下限
+const (
+	levelNormal = 0
+	levelHigh   = 1
+	levelLow    = -1
+)
+
+// thresholdState 一个资源的告警状态
+type thresholdState struct {
+	level int
+	// announced 本次越限是否已发布告警（冷却期内越限不发布，解除时也不发布）
+	announced  bool
+	lastRaised time.Time
+}
+
+// thresholdMonitor 作为 Sink 使用：按资源门限判断读数越限，带回差和冷却
+type thresholdMonitor struct {
+	custom []ThresholdRule
+
+	mu sync.Mutex
+	// rules 各设备资源生效的门限（nil 表示未设置），首次收到读数时按 profile 属性和自定义配置解析
+	rules  map[string]map[string]*threshold
+	states map[string]*thresholdState
+}
+
+// parseThresholdValue 解析门限数值，空串表示未设置
+func parseThresholdValue(v any) (f float64, ok bool, err error) {
+	if s, isStr := v.(string); v == nil || isStr && strings.TrimSpace(s) == "" {
+		return 0, false, nil
+	}
+	f, err = config.NumericAttr(v)
+	return f, err == nil, err
+}
+
+// newThreshold 由上下限、回差和冷却时间构造门限；上下限都未设置时返回 nil
+func newThreshold(high, low, hysteresis, cooldown any) (*threshold, error) {
+	t := &threshold{}
+	var err error
+	if t.high, t.hasHigh, err = parseThresholdValue(high); err != nil {
+		return nil, fmt.Errorf("上限无效：%w", err)
+	}
+	if t.low, t.hasLow, err = parseThresholdValue(low); err != nil {
+		return nil, fmt.Errorf("下限无效：%w", err)
+	}
+	if !t.hasHigh && !t.hasLow {
+		return nil, nil
+	}
+	if t.hasHigh && t.hasLow && t.low > t.high {
+		return nil, fmt.Errorf("下限 %v 大于上限 %v", t.low, t.high)
+	}
+	if t.hysteresis, _, err = parseThresholdValue(hysteresis); err != nil || t.hysteresis < 0 {
+		return nil, fmt.Errorf("回差无效 %v", hysteresis)
+	}
+	if s := strings.TrimSpace(fmt.Sprint(cooldown)); cooldown != nil && s != "" {
+		if t.cooldown, err = time.ParseDuration(s); err != nil || t.cooldown < 0 {
+			return nil, fmt.Errorf("冷却时间无效 %q", s)
+		}
+	}
+	return t, nil
+}
+
+// newThresholdMonitor 校验自定义配置中的门限
+func newThresholdMonitor(custom []ThresholdRule) (*thresholdMonitor, error) {
+	for i, r := range custom {
+		if r.Device == "" || r.Resource == "" {
+			return nil, fmt.Errorf("%s.Thresholds[%d] 须填写 Device 和 Resource", customConfigSection, i)
+		}
+		t, err := newThreshold(r.High, r.Low, r.Hysteresis, r.Cooldown)
+		if err != nil {
+			return nil, fmt.Errorf("%s.Thresholds[%d]（%s.%s）%w", customConfigSection, i, r.Device, r.Resource, err)
+		}
+		if t == nil {
+			return nil, fmt.Errorf("%s.Thresholds[%d]（%s.%s）须填写 High 或 Low", customConfigSection, i, r.Device, r.Resource)
+		}
+	}
+	return &thresholdMonitor{
+		custom: custom,
+		rules:  make(map[string]map[string]*threshold),
+		states: make(map[string]*thresholdState),
+	}, nil
+}
+
+// resolve 返回资源生效的门限：自定义配置优先（设备名精确匹配优先于 "*"），其次为 profile 属性，调用方需持有 mu
+func (m *thresholdMonitor) resolve(deviceName, resourceName string) (*threshold, error) {
+	rules, ok := m.rules[deviceName]
+	if !ok {
+		rules = make(map[string]*threshold)
+		m.rules[deviceName] = rules
+	}
+	if t, ok := rules[resourceName]; ok {
+		return t, nil
+	}
+	// 解析失败同样缓存为未设置，只报一次错误
+	rules[resourceName] = nil
+
+	var match *ThresholdRule
+	for i := range m.custom {
+		r := &m.custom[i]
+		if r.Resource != resourceName {
+			continue
+		}
+		if r.Device == deviceName {
+			match = r
+			break
+		}
+		if r.Device == "*" && match == nil {
+			match = r
+		}
+	}
+	if match != nil {
+		t, err := newThreshold(match.High, match.Low, match.Hysteresis, match.Cooldown)
+		if err != nil {
+			return nil, err
+		}
+		rules[resourceName] = t
+		return t, nil
+	}
+
+	resources, _ := config.GetDeviceResources(deviceName)
+	for _, dr := range resources {
+		if dr.Name != resourceName {
+			continue
+		}
+		t, err := newThreshold(dr.Attributes[alarmHighAttr], dr.Attributes[alarmLowAttr], dr.Attributes[alarmHysteresisAttr], dr.Attributes[alarmCooldownAttr])
+		if err != nil {
+			return nil, fmt.Errorf("设备 %s 资源 %s 的门限属性无效：%w", deviceName, resourceName, err)
+		}
+		rules[resourceName] = t
+		return t, nil
+	}
+	return nil, nil
+}
+
+// thresholdEvent 一次越限或解除，由 observe 返回后在锁外发布
+type thresholdEvent struct {
+	action    string
+	level     int
+	threshold float64
+}
+
+// observe 按门限判断一个读数，状态变化且需要发布时返回事件
+func (m *thresholdMonitor) observe(deviceName, resourceName string, value float64, now time.Time) (*thresholdEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, err := m.resolve(deviceName, resourceName)
+	if t == nil || err != nil {
+		return nil, err
+	}
+	key := deviceName + "\x00" + resourceName
+	st, ok := m.states[key]
+	if !ok {
+		st = &thresholdState{}
+		m.states[key] = st
+	}
+
+	// 1. 新状态：越限立即告警，回到门限内超过回差才解除
+	level := levelNormal
+	switch {
+	case t.hasHigh && value > t.high:
+		level = levelHigh
+	case t.hasLow && value < t.low:
+		level = levelLow
+	case st.level == levelHigh && value > t.high-t.hysteresis:
+		level = levelHigh
+	case st.level == levelLow && value < t.low+t.hysteresis:
+		level = levelLow
+	}
+	if level == st.level {
+		return nil, nil
+	}
+	st.level = level
+
+	// 2. 解除：只在本次越限发布过告警时发布
+	if level == levelNormal {
+		if !st.announced {
+			return nil, nil
+		}
+		st.announced = false
+		return &thresholdEvent{action: thresholdEventActionCleared}, nil
+	}
+
+	// 3. 告警：冷却期内不发布
+	if t.cooldown > 0 && !st.lastRaised.IsZero() && now.Sub(st.lastRaised) < t.cooldown {
+		st.announced = false
+		return nil, nil
+	}
+	st.announced, st.lastRaised = true, now
+	ev := &thresholdEvent{action: thresholdEventActionRaised, level: level, threshold: t.high}
+	if level == levelLow {
+		ev.threshold = t.low
+	}
+	return ev, nil
+}
+
+// forget 删除设备的门限缓存和告警状态，设备 profile 变化后重新解析
+func (m *thresholdMonitor) forget(deviceName string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.rules, deviceName)
+	prefix := deviceName + "\x00"
+	for key := range m.states {
+		if strings.HasPrefix(key, prefix) {
+			delete(m.states, key)
+		}
+	}
+}
+
+// levelName 门限状态的名称
+func levelName(level int) string {
+	switch level {
+	case levelHigh:
+		return "high"
+	case levelLow:
+		return "low"
+	}
+	return "normal"
+}
+
+// checkThreshold 作为 Sink 使用：数值读数越限时发布 lpmp-threshold 事件，
+// 设备声明了 alarmLatched 时同时锁存告警
+func (d *LpMpDriver) checkThreshold(deviceName, resourceName string, value any, origin time.Time, tags map[string]string) {
+	v, ok := config.NumericValue(value)
+	if !ok {
+		return
+	}
+	ev, err := d.thresholds.observe(deviceName, resourceName, v, origin)
+	if err != nil {
+		d.lc.Errorf("%v", err)
+		return
+	}
+	if ev == nil {
+		return
+	}
+
+	details := map[string]any{
+		"device":   deviceName,
+		"resource": resourceName,
+		"value":    value,
+		"sensorId": tags["sensorId"],
+	}
+	if ev.action == thresholdEventActionCleared {
+		d.lc.Infof("设备 %s 资源 %s 已回到门限内: %v", deviceName, resourceName, value)
+		d.sdk.PublishGenericSystemEvent(thresholdEventType, ev.action, details)
+		return
+	}
+	details["level"] = levelName(ev.level)
+	details["threshold"] = ev.threshold
+	d.lc.Warnf("[trace=%s] 设备 %s 资源 %s 越限（%s %s）: %v", tags["traceId"], deviceName, resourceName, levelName(ev.level), strconv.FormatFloat(ev.threshold, 'g', -1, 64), value)
+	d.sdk.PublishGenericSystemEvent(thresholdEventType, ev.action, details)
+	d.latchAlarm(deviceName, alarmRecord{
+		Time:     origin.Format(time.RFC3339Nano),
+		Action:   alarmEventActionRaised,
+		Source:   alarmSourceThreshold,
+		SensorID: tags["sensorId"],
+		TraceID:  tags["traceId"],
+		Values:   map[string]any{resourceName: value, "level": levelName(ev.level), "threshold": ev.threshold},
+	}, origin)
+}
@@ -0,0 +1,81 @@
+package driver
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/serial"
+	"github.com/linjuya-lu/device-lpmp-go/internal/tlsconf"
+)
+
+// TLS 配置前缀，完整键名见 tlsconf，如 TransportTLSCAFile、MqttTLSCertFile
+const (
+	transportTLSPrefix = "Transport"
+	mqttTLSPrefix      = "Mqtt"
+)
+
+// tlsLoader 按前缀读取 TLS 配置并创建证书加载器；未启用且 force 为 false 时返回 nil
+func (d *LpMpDriver) tlsLoader(driverCfg map[string]string, prefix string, force bool) (*tlsconf.Loader, error) {
+	cfg, err := tlsconf.ConfigFromDriver(driverCfg, prefix)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.Enabled && !force {
+		return nil, nil
+	}
+	cfg.Enabled = true
+	var secrets tlsconf.SecretSource
+	if sp := d.sdk.SecretProvider(); sp != nil {
+		secrets = sp.GetSecret
+	}
+	loader, err := tlsconf.NewLoader(cfg, secrets)
+	if err != nil {
+		return nil, fmt.Errorf("加载 %s TLS 证书失败: %w", prefix, err)
+	}
+	return loader, nil
+}
+
+// setupTransportTLS 备用链路为 TCP 透传时按配置启用 TLS；tls:// 地址不配置证书时使用系统根证书
+func (d *LpMpDriver) setupTransportTLS(driverCfg map[string]string) error {
+	t, ok := d.link.Backup.(serial.TCPTransport)
+	if !ok {
+		return nil
+	}
+	loader, err := d.tlsLoader(driverCfg, transportTLSPrefix, t.Secure)
+	if err != nil || loader == nil {
+		return err
+	}
+	t.Secure = true
+	t.TLS = loader.TLSConfig(t.Host())
+	d.link.Backup = t
+	d.tls["transport"] = loader
+	return nil
+}
+
+// mqttTLSLoader Broker 地址为 ssl:// / tls:// / mqtts:// 或配置了证书时返回证书加载器，否则返回 nil
+func (d *LpMpDriver) mqttTLSLoader(driverCfg map[string]string, brokerURL string) (*tlsconf.Loader, string, error) {
+	u, err := url.Parse(brokerURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("MQTT Broker 地址无效 %q: %w", brokerURL, err)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "ssl", "tls", "mqtts", "tcps":
+		loader, err := d.tlsLoader(driverCfg, mqttTLSPrefix, true)
+		return loader, u.Hostname(), err
+	}
+	loader, err := d.tlsLoader(driverCfg, mqttTLSPrefix, false)
+	return loader, u.Hostname(), err
+}
+
+// tlsStates 各 TLS 连接最近一次握手的状态，未启用 TLS 时为 nil
+func (d *LpMpDriver) tlsStates() map[string]tlsconf.State {
+	if len(d.tls) == 0 {
+		return nil
+	}
+	out := make(map[string]tlsconf.State, len(d.tls))
+	for name, l := range d.tls {
+		out[name] = l.State()
+	}
+	return out
+}
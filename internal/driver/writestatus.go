@@ -0,0 +1,124 @@
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+	"github.com/linjuya-lu/device-lpmp-go/internal/sessionlog"
+	"github.com/linjuya-lu/device-lpmp-go/internal/trace"
+)
+
+const (
+	// writeStatusResource 设备上记录最近一次传感器参数写入状态的资源（JSON 字符串）
+	writeStatusResource = "writeStatus"
+
+	// 写入状态：已入队 → 传感器确认 / 失败
+	writeStatePending   = "pending"
+	writeStateConfirmed = "confirmed"
+	writeStateFailed    = "failed"
+
+	// 写入状态变化时发布的系统事件，action 为状态
+	writeEventType = "lpmp-write"
+
+	// writeQueueSize 待下发参数写入的队列长度，队列满时写请求直接失败
+	writeQueueSize = 32
+)
+
+// writeStatus 一次参数写入的状态，ID 用于关联 pending 与之后的 confirmed/failed
+type writeStatus struct {
+	ID        string   `json:"id"`
+	Device    string   `json:"device"`
+	SensorID  string   `json:"sensorId"`
+	State     string   `json:"state"`
+	Resources []string `json:"resources"`
+	Error     string   `json:"error,omitempty"`
+	Time      string   `json:"time"`
+	// LatencyMs 确认时下发到收到控制响应的往返时延（ms），其它状态省略
+	LatencyMs float64 `json:"latencyMs,omitempty"`
+}
+
+// queuedWrite 队列中待下发的参数写入
+type queuedWrite struct {
+	id         string
+	deviceName string
+	write      sensorParamWrite
+}
+
+// startWriteWorker 启动参数写入协程：按入队顺序逐个下发并等待确认，直到驱动停止
+func (d *LpMpDriver) startWriteWorker() {
+	d.writeQueue = make(chan queuedWrite, writeQueueSize)
+	go func() {
+		for {
+			select {
+			case <-d.stopCh:
+				return
+			case q := <-d.writeQueue:
+				d.processWrite(q)
+			}
+		}
+	}()
+}
+
+// queueSensorWrite 将参数写入入队并置为 pending，返回写入 ID
+func (d *LpMpDriver) queueSensorWrite(deviceName string, w sensorParamWrite) (string, error) {
+	q := queuedWrite{id: string(trace.New()), deviceName: deviceName, write: w}
+	q.write.writeID = q.id
+	select {
+	case d.writeQueue <- q:
+	default:
+		return "", fmt.Errorf("参数写入队列已满（%d），请稍后重试", writeQueueSize)
+	}
+	d.setWriteStatus(q, writeStatePending, 0, nil)
+	return q.id, nil
+}
+
+// processWrite 下发一次参数写入；确认后才把写入值更新到值表
+func (d *LpMpDriver) processWrite(q queuedWrite) {
+	rtt, err := d.writeSensorParams(q.deviceName, q.write)
+	if err != nil {
+		d.lc.Errorf("[write=%s] %v", q.id, err)
+		d.setWriteStatus(q, writeStateFailed, 0, err)
+		return
+	}
+	for res, v := range q.write.values {
+		config.SetDeviceValue(q.deviceName, res, v)
+	}
+	d.setWriteStatus(q, writeStateConfirmed, rtt, nil)
+}
+
+// setWriteStatus 更新设备的 writeStatus 资源并发布状态变化事件，rtt 为确认时的往返时延
+func (d *LpMpDriver) setWriteStatus(q queuedWrite, state string, rtt time.Duration, err error) {
+	st := writeStatus{
+		ID:        q.id,
+		Device:    q.deviceName,
+		SensorID:  q.write.sensorID,
+		State:     state,
+		Time:      time.Now().Format(time.RFC3339),
+		LatencyMs: durationMs(rtt),
+	}
+	for res := range q.write.values {
+		st.Resources = append(st.Resources, res)
+	}
+	sort.Strings(st.Resources)
+	if err != nil {
+		st.Error = err.Error()
+	}
+	if b, jerr := json.Marshal(st); jerr == nil {
+		config.SetDeviceValue(q.deviceName, writeStatusResource, string(b))
+	}
+	sessionlog.Default.Record(st.SensorID, sessionlog.KindConfig, "[write=%s] 设备 %s 参数写入 %s: %v %s", st.ID, st.Device, state, st.Resources, st.Error)
+	details := map[string]any{
+		"id":        st.ID,
+		"device":    st.Device,
+		"sensorId":  st.SensorID,
+		"resources": st.Resources,
+		"error":     st.Error,
+	}
+	if state == writeStateConfirmed {
+		details["latencyMs"] = st.LatencyMs
+	}
+	d.sdk.PublishGenericSystemEvent(writeEventType, state, details)
+}
@@ -0,0 +1,187 @@
+// Package dutycycle 按地区无线电规定限制下行发射占空比：根据帧长和空口速率估算每帧的
+// 发射时长（airtime），在滑动窗口内累计，超出预算的下发推迟到窗口内有预算释放后再发。
+package dutycycle
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Driver 配置段中与占空比相关的键名
+const (
+	keyLimit    = "DutyCycleLimit"
+	keyWindow   = "DutyCycleWindow"
+	keyDataRate = "RadioDataRate"
+	keyOverhead = "RadioFrameOverhead"
+	keyMaxDefer = "DutyCycleMaxDefer"
+)
+
+// ErrBudgetExceeded 在允许的最长推迟时间内等不到足够的发射预算
+var ErrBudgetExceeded = errors.New("发射占空比预算不足")
+
+// ErrStopped 等待预算期间驱动停止
+var ErrStopped = errors.New("等待发射预算时驱动已停止")
+
+// Config 保存占空比限制配置
+type Config struct {
+	// Limit 占空比上限（百分比，如 1 表示 1%），0 表示不限制
+	Limit float64
+	// Window 统计窗口，预算为 Window × Limit
+	Window time.Duration
+	// DataRate 空口速率（bit/s），用于估算发射时长
+	DataRate int
+	// Overhead 每帧额外的空口字节数（前导码、同步字、无线帧头等）
+	Overhead int
+	// MaxDefer 单帧最长推迟时间，超过则放弃下发
+	MaxDefer time.Duration
+}
+
+// ConfigFromDriver 从 Driver 配置段读取占空比配置，未配置项使用默认值
+func ConfigFromDriver(driverCfg map[string]string) (Config, error) {
+	cfg := Config{
+		Window:   time.Hour,
+		DataRate: 9600,
+		MaxDefer: 30 * time.Second,
+	}
+	if v := driverCfg[keyLimit]; v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 || f > 100 {
+			return cfg, fmt.Errorf("%s 配置无效 %q，应为 0~100 的百分比", keyLimit, v)
+		}
+		cfg.Limit = f
+	}
+	for key, dst := range map[string]*time.Duration{keyWindow: &cfg.Window, keyMaxDefer: &cfg.MaxDefer} {
+		if v := driverCfg[key]; v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				return cfg, fmt.Errorf("%s 配置无效 %q", key, v)
+			}
+			*dst = d
+		}
+	}
+	if v := driverCfg[keyDataRate]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return cfg, fmt.Errorf("%s 配置无效 %q", keyDataRate, v)
+		}
+		cfg.DataRate = n
+	}
+	if v := driverCfg[keyOverhead]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return cfg, fmt.Errorf("%s 配置无效 %q", keyOverhead, v)
+		}
+		cfg.Overhead = n
+	}
+	return cfg, nil
+}
+
+// Enabled 是否启用占空比限制
+func (c Config) Enabled() bool {
+	return c.Limit > 0
+}
+
+// transmission 一次发射的记录
+type transmission struct {
+	at      time.Time
+	airtime time.Duration
+}
+
+// Budget 滑动窗口内的发射预算，并发安全
+type Budget struct {
+	cfg    Config
+	budget time.Duration // 窗口内允许的总发射时长
+
+	mu   sync.Mutex
+	sent []transmission // 窗口内的发射记录，按时间递增
+}
+
+// New 创建发射预算
+func New(cfg Config) *Budget {
+	return &Budget{
+		cfg:    cfg,
+		budget: time.Duration(float64(cfg.Window) * cfg.Limit / 100),
+	}
+}
+
+// Airtime 估算 n 字节帧的发射时长
+func (b *Budget) Airtime(n int) time.Duration {
+	bits := int64(n+b.cfg.Overhead) * 8
+	return time.Duration(bits * int64(time.Second) / int64(b.cfg.DataRate))
+}
+
+// prune 删除窗口外的记录并返回窗口内已用时长，调用方需持有 mu
+func (b *Budget) prune(now time.Time) time.Duration {
+	cutoff := now.Add(-b.cfg.Window)
+	i := 0
+	for i < len(b.sent) && !b.sent[i].at.After(cutoff) {
+		i++
+	}
+	b.sent = b.sent[i:]
+	var used time.Duration
+	for _, t := range b.sent {
+		used += t.airtime
+	}
+	return used
+}
+
+// Reserve 尝试为 n 字节的帧预留发射时长：预算足够时记账并返回 0，
+// 否则返回需要等待的时长（不记账）；单帧超过整个窗口预算时返回 ErrBudgetExceeded
+func (b *Budget) Reserve(n int) (time.Duration, error) {
+	air := b.Airtime(n)
+	if air > b.budget {
+		return 0, fmt.Errorf("%w: 单帧 %d 字节需 %s，窗口预算仅 %s", ErrBudgetExceeded, n, air, b.budget)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	used := b.prune(now)
+	if used+air <= b.budget {
+		b.sent = append(b.sent, transmission{at: now, airtime: air})
+		return 0, nil
+	}
+	// 按时间顺序等最早的记录移出窗口，直到释放出足够预算
+	need := used + air - b.budget
+	for _, t := range b.sent {
+		need -= t.airtime
+		if need <= 0 {
+			return t.at.Add(b.cfg.Window).Sub(now), nil
+		}
+	}
+	return b.cfg.Window, nil
+}
+
+// Wait 等待并预留 n 字节帧的发射预算，最长等待 MaxDefer；stop 关闭时返回 ErrStopped
+func (b *Budget) Wait(n int, stop <-chan struct{}) error {
+	deadline := time.Now().Add(b.cfg.MaxDefer)
+	for {
+		wait, err := b.Reserve(n)
+		if err != nil || wait == 0 {
+			return err
+		}
+		if time.Now().Add(wait).After(deadline) {
+			return fmt.Errorf("%w: 需推迟 %s，超过 %s", ErrBudgetExceeded, wait.Round(time.Millisecond), b.cfg.MaxDefer)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-stop:
+			timer.Stop()
+			return ErrStopped
+		}
+	}
+}
+
+// Remaining 返回当前窗口内剩余的发射时长
+func (b *Budget) Remaining() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if left := b.budget - b.prune(time.Now()); left > 0 {
+		return left
+	}
+	return 0
+}
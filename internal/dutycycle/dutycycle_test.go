@@ -0,0 +1,113 @@
+package dutycycle
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConfigFromDriver(t *testing.T) {
+	cfg, err := ConfigFromDriver(map[string]string{})
+	if err != nil || cfg.Enabled() || cfg != (Config{Window: time.Hour, DataRate: 9600, MaxDefer: 30 * time.Second}) {
+		t.Errorf("默认配置 %+v, %v", cfg, err)
+	}
+	cfg, err = ConfigFromDriver(map[string]string{
+		keyLimit: "0.1", keyWindow: "10m", keyDataRate: "1200", keyOverhead: "12", keyMaxDefer: "2m",
+	})
+	if want := (Config{Limit: 0.1, Window: 10 * time.Minute, DataRate: 1200, Overhead: 12, MaxDefer: 2 * time.Minute}); err != nil || cfg != want || !cfg.Enabled() {
+		t.Errorf("配置 %+v, %v", cfg, err)
+	}
+	for _, bad := range []map[string]string{
+		{keyLimit: "-1"},
+		{keyLimit: "101"},
+		{keyLimit: "1%"},
+		{keyWindow: "0s"},
+		{keyMaxDefer: "soon"},
+		{keyDataRate: "0"},
+		{keyOverhead: "-1"},
+	} {
+		if _, err := ConfigFromDriver(bad); err == nil {
+			t.Errorf("%v 未报错", bad)
+		}
+	}
+}
+
+func TestAirtime(t *testing.T) {
+	b := New(Config{Limit: 1, Window: time.Hour, DataRate: 9600, Overhead: 10})
+	// (14+10)×8 = 192 bit，9600 bit/s 下 20ms
+	if got := b.Airtime(14); got != 20*time.Millisecond {
+		t.Errorf("Airtime(14)=%s，期望 20ms", got)
+	}
+}
+
+// slowBudget 1% × 1h = 36s 预算，8 bit/s 下每字节发射 1s
+func slowBudget(maxDefer time.Duration) *Budget {
+	return New(Config{Limit: 1, Window: time.Hour, DataRate: 8, MaxDefer: maxDefer})
+}
+
+// TestReserve 预算内直接记账；不足时返回等到足够的早期记录移出窗口所需的时长，不记账；
+// 单帧超过整个预算时报错；窗口外的记录不占预算
+func TestReserve(t *testing.T) {
+	b := slowBudget(time.Minute)
+	for _, n := range []int{20, 10} {
+		if wait, err := b.Reserve(n); wait != 0 || err != nil {
+			t.Fatalf("Reserve(%d)=%s, %v", n, wait, err)
+		}
+	}
+	if left := b.Remaining(); left != 6*time.Second {
+		t.Errorf("剩余 %s，期望 6s", left)
+	}
+	// 需释放 4s，第一条记录（20s）移出窗口即可
+	if wait, err := b.Reserve(10); err != nil || wait < time.Hour-time.Second || wait > time.Hour {
+		t.Errorf("预算不足时 Reserve=%s, %v，期望约 1h", wait, err)
+	}
+	if len(b.sent) != 2 {
+		t.Errorf("预算不足时记账了 %d 条", len(b.sent))
+	}
+	b.sent[0].at = time.Now().Add(10*time.Second - time.Hour)
+	if wait, _ := b.Reserve(10); wait < 9*time.Second || wait > 10*time.Second {
+		t.Errorf("Reserve=%s，期望约 10s", wait)
+	}
+	// 需释放 30s：两条都要移出窗口
+	if wait, _ := b.Reserve(30); wait < time.Hour-time.Second || wait > time.Hour {
+		t.Errorf("Reserve(30)=%s，期望等第二条移出窗口约 1h", wait)
+	}
+
+	b.sent[0].at = time.Now().Add(-2 * time.Hour)
+	if left := b.Remaining(); left != 26*time.Second || len(b.sent) != 1 {
+		t.Errorf("窗口外记录未移除：剩余 %s，记录 %d 条", left, len(b.sent))
+	}
+
+	if _, err := b.Reserve(37); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("单帧超过预算 err=%v", err)
+	}
+}
+
+// TestWait 预算在 MaxDefer 内释放时等待后记账；等不到时报 ErrBudgetExceeded；停止时报 ErrStopped
+func TestWait(t *testing.T) {
+	b := slowBudget(time.Second)
+	b.Reserve(36)
+	if err := b.Wait(1, nil); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("需等约 1h 时 err=%v", err)
+	}
+
+	b.sent[0].at = time.Now().Add(50*time.Millisecond - time.Hour)
+	start := time.Now()
+	if err := b.Wait(1, nil); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 40*time.Millisecond {
+		t.Errorf("仅等待 %s", d)
+	}
+	if len(b.sent) != 1 || b.sent[0].airtime != time.Second {
+		t.Errorf("等待后记录 %+v", b.sent)
+	}
+
+	b.sent[0].airtime = 36 * time.Second
+	b.sent[0].at = time.Now().Add(500*time.Millisecond - time.Hour)
+	stop := make(chan struct{})
+	time.AfterFunc(20*time.Millisecond, func() { close(stop) })
+	if err := b.Wait(1, stop); !errors.Is(err, ErrStopped) {
+		t.Errorf("停止时 err=%v", err)
+	}
+}
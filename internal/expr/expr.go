@@ -0,0 +1,365 @@
+// Package expr 为派生资源提供的小型算术表达式引擎：
+// 支持 + - * / %、一元负号、括号、数字常量、变量和少量函数（abs、sqrt、min、max、pow、round）。
+// 变量名为字母、数字、下划线和点组成的标识符；含 "-" 等字符的资源名写在花括号中，如 {water-level}。
+package expr
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expr 编译后的表达式，可并发求值
+type Expr struct {
+	src  string
+	root node
+	vars []string
+}
+
+// Lookup 求值时按变量名取值，变量不存在时 ok 为 false
+type Lookup func(name string) (float64, bool)
+
+type node interface {
+	eval(vars Lookup) (float64, error)
+}
+
+type (
+	numNode float64
+	varNode string
+	negNode struct{ x node }
+	binNode struct {
+		op   byte
+		l, r node
+	}
+	callNode struct {
+		name string
+		fn   func(args []float64) float64
+		args []node
+	}
+)
+
+// funcs 支持的函数及参数个数
+var funcs = map[string]struct {
+	arity int
+	fn    func(args []float64) float64
+}{
+	"abs":   {1, func(a []float64) float64 { return math.Abs(a[0]) }},
+	"sqrt":  {1, func(a []float64) float64 { return math.Sqrt(a[0]) }},
+	"round": {1, func(a []float64) float64 { return math.Round(a[0]) }},
+	"min":   {2, func(a []float64) float64 { return math.Min(a[0], a[1]) }},
+	"max":   {2, func(a []float64) float64 { return math.Max(a[0], a[1]) }},
+	"pow":   {2, func(a []float64) float64 { return math.Pow(a[0], a[1]) }},
+}
+
+func (n numNode) eval(Lookup) (float64, error) { return float64(n), nil }
+
+func (n varNode) eval(vars Lookup) (float64, error) {
+	v, ok := vars(string(n))
+	if !ok {
+		return 0, fmt.Errorf("变量 %s 无值", string(n))
+	}
+	return v, nil
+}
+
+func (n negNode) eval(vars Lookup) (float64, error) {
+	v, err := n.x.eval(vars)
+	return -v, err
+}
+
+func (n binNode) eval(vars Lookup) (float64, error) {
+	l, err := n.l.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	r, err := n.r.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	switch n.op {
+	case '+':
+		return l + r, nil
+	case '-':
+		return l - r, nil
+	case '*':
+		return l * r, nil
+	case '/':
+		if r == 0 {
+			return 0, fmt.Errorf("除数为 0")
+		}
+		return l / r, nil
+	case '%':
+		if r == 0 {
+			return 0, fmt.Errorf("除数为 0")
+		}
+		return math.Mod(l, r), nil
+	}
+	return 0, fmt.Errorf("未知运算符 %q", n.op)
+}
+
+func (n callNode) eval(vars Lookup) (float64, error) {
+	args := make([]float64, len(n.args))
+	for i, a := range n.args {
+		v, err := a.eval(vars)
+		if err != nil {
+			return 0, err
+		}
+		args[i] = v
+	}
+	return n.fn(args), nil
+}
+
+// Parse 编译表达式
+func Parse(src string) (*Expr, error) {
+	p := &parser{src: src, vars: make(map[string]bool)}
+	p.next()
+	root, err := p.parseExpr()
+	if err != nil {
+		return nil, fmt.Errorf("表达式 %q: %w", src, err)
+	}
+	switch p.tok.kind {
+	case tokEOF:
+	case tokErr:
+		return nil, fmt.Errorf("表达式 %q: 位置 %d 处%s", src, p.tok.pos, p.tok.text)
+	default:
+		return nil, fmt.Errorf("表达式 %q: 位置 %d 处多余的 %q", src, p.tok.pos, p.tok.text)
+	}
+	e := &Expr{src: src, root: root}
+	for v := range p.vars {
+		e.vars = append(e.vars, v)
+	}
+	sort.Strings(e.vars)
+	return e, nil
+}
+
+// Vars 表达式引用的变量名（已排序）
+func (e *Expr) Vars() []string {
+	return append([]string(nil), e.vars...)
+}
+
+// String 返回表达式原文
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Eval 求值；结果为 NaN 或无穷大时返回错误
+func (e *Expr) Eval(vars Lookup) (float64, error) {
+	v, err := e.root.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("表达式 %q 结果无效: %v", e.src, v)
+	}
+	return v, nil
+}
+
+// —— 词法分析
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokNum
+	tokIdent
+	tokOp
+	tokErr
+)
+
+type token struct {
+	kind tokKind
+	text string
+	pos  int
+}
+
+type parser struct {
+	src  string
+	pos  int
+	tok  token
+	vars map[string]bool
+}
+
+// next 读取下一个词法单元到 p.tok
+func (p *parser) next() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+	start := p.pos
+	if p.pos >= len(p.src) {
+		p.tok = token{kind: tokEOF, pos: start}
+		return
+	}
+	c := p.src[p.pos]
+	switch {
+	case c >= '0' && c <= '9' || c == '.':
+		for p.pos < len(p.src) && (isDigit(p.src[p.pos]) || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		// 科学计数法 1e-3
+		if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+			p.pos++
+			if p.pos < len(p.src) && (p.src[p.pos] == '+' || p.src[p.pos] == '-') {
+				p.pos++
+			}
+			for p.pos < len(p.src) && isDigit(p.src[p.pos]) {
+				p.pos++
+			}
+		}
+		p.tok = token{kind: tokNum, text: p.src[start:p.pos], pos: start}
+	case c == '{':
+		end := strings.IndexByte(p.src[p.pos:], '}')
+		if end < 0 {
+			p.tok = token{kind: tokErr, text: "未闭合的 {", pos: start}
+			return
+		}
+		name := strings.TrimSpace(p.src[p.pos+1 : p.pos+end])
+		p.pos += end + 1
+		if name == "" {
+			p.tok = token{kind: tokErr, text: "空的 {}", pos: start}
+			return
+		}
+		p.tok = token{kind: tokIdent, text: name, pos: start}
+	case isIdentStart(c):
+		for p.pos < len(p.src) && (isIdentStart(p.src[p.pos]) || isDigit(p.src[p.pos]) || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		p.tok = token{kind: tokIdent, text: p.src[start:p.pos], pos: start}
+	case strings.IndexByte("+-*/%(),", c) >= 0:
+		p.pos++
+		p.tok = token{kind: tokOp, text: string(c), pos: start}
+	default:
+		p.pos++
+		p.tok = token{kind: tokErr, text: fmt.Sprintf("非法字符 %q", c), pos: start}
+	}
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// —— 语法分析（递归下降）：expr = term {(+|-) term}; term = unary {(*|/|%) unary};
+// unary = -unary | primary; primary = num | ident | ident(args) | (expr)
+
+func (p *parser) isOp(op string) bool {
+	return p.tok.kind == tokOp && p.tok.text == op
+}
+
+func (p *parser) parseExpr() (node, error) {
+	l, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("+") || p.isOp("-") {
+		op := p.tok.text[0]
+		p.next()
+		r, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		l = binNode{op: op, l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseTerm() (node, error) {
+	l, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("*") || p.isOp("/") || p.isOp("%") {
+		op := p.tok.text[0]
+		p.next()
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l = binNode{op: op, l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.isOp("-") {
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return negNode{x: x}, nil
+	}
+	if p.isOp("+") {
+		p.next()
+		return p.parseUnary()
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokNum:
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("位置 %d 处数字 %q 无效", tok.pos, tok.text)
+		}
+		p.next()
+		return numNode(f), nil
+	case tokIdent:
+		p.next()
+		if !p.isOp("(") {
+			p.vars[tok.text] = true
+			return varNode(tok.text), nil
+		}
+		return p.parseCall(tok)
+	case tokOp:
+		if tok.text == "(" {
+			p.next()
+			x, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if !p.isOp(")") {
+				return nil, fmt.Errorf("位置 %d 处缺少 )", p.tok.pos)
+			}
+			p.next()
+			return x, nil
+		}
+	case tokErr:
+		return nil, fmt.Errorf("位置 %d 处%s", tok.pos, tok.text)
+	case tokEOF:
+		return nil, fmt.Errorf("表达式不完整")
+	}
+	return nil, fmt.Errorf("位置 %d 处意外的 %q", tok.pos, tok.text)
+}
+
+// parseCall 解析函数调用，当前词法单元为 "("
+func (p *parser) parseCall(name token) (node, error) {
+	f, ok := funcs[name.text]
+	if !ok {
+		return nil, fmt.Errorf("位置 %d 处未知函数 %s", name.pos, name.text)
+	}
+	p.next()
+	var args []node
+	for !p.isOp(")") {
+		if len(args) > 0 {
+			if !p.isOp(",") {
+				return nil, fmt.Errorf("位置 %d 处缺少 , 或 )", p.tok.pos)
+			}
+			p.next()
+		}
+		a, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, a)
+	}
+	p.next()
+	if len(args) != f.arity {
+		return nil, fmt.Errorf("函数 %s 需要 %d 个参数，得到 %d 个", name.text, f.arity, len(args))
+	}
+	return callNode{name: name.text, fn: f.fn, args: args}, nil
+}
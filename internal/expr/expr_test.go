@@ -0,0 +1,149 @@
+package expr
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testVars 测试用变量表，water-level 需写在花括号中
+func testVars(name string) (float64, bool) {
+	v, ok := map[string]float64{
+		"voltage":     220,
+		"current":     1.5,
+		"water-level": 250,
+		"zero":        0,
+		"neg":         -4,
+		"a.b":         3,
+	}[name]
+	return v, ok
+}
+
+func TestEval(t *testing.T) {
+	cases := []struct {
+		src  string
+		want float64
+	}{
+		// 优先级与结合性
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3},
+		{"24 / 4 / 2", 3},
+		{"2 * 3 % 4", 2},
+		{"1 + 10 % 4", 3},
+		{"-2 * 3", -6},
+		{"2 * -3", -6},
+		{"--2", 2},
+		{"+2 - -2", 4},
+		{"-(1 + 2) * 2", -6},
+		// 整数与小数混合、科学计数法
+		{"7 / 2", 3.5},
+		{"7 % 2.5", 2},
+		{".5 + 1", 1.5},
+		{"1e3 * 2", 2000},
+		{"2.5E-1 * 4", 1},
+		// 变量和函数
+		{"voltage * current", 330},
+		{"{water-level} / 100", 2.5},
+		{"{ water-level } + a.b", 253},
+		{"abs(neg) + sqrt(16)", 8},
+		{"round(current) + min(voltage, 1) + max(zero, neg)", 3},
+		{"pow(2, 1 + 2)", 8},
+		{"max(min(voltage, 100), current * 2)", 100},
+	}
+	for _, c := range cases {
+		e, err := Parse(c.src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", c.src, err)
+			continue
+		}
+		got, err := e.Eval(testVars)
+		if err != nil {
+			t.Errorf("Eval(%q): %v", c.src, err)
+			continue
+		}
+		if math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("Eval(%q)=%v，期望 %v", c.src, got, c.want)
+		}
+		if e.String() != c.src {
+			t.Errorf("String()=%q，期望原文 %q", e.String(), c.src)
+		}
+	}
+}
+
+func TestVars(t *testing.T) {
+	e, err := Parse("voltage * current + {water-level} - voltage / abs(a.b)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a.b", "current", "voltage", "water-level"}
+	if got := e.Vars(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Vars()=%v，期望 %v（去重、排序，不含函数名）", got, want)
+	}
+	e.Vars()[0] = "x"
+	if e.Vars()[0] != "a.b" {
+		t.Error("修改 Vars() 的返回值影响了表达式")
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	cases := []struct {
+		src  string
+		want string
+	}{
+		{"1 / 0", "除数为 0"},
+		{"voltage / zero", "除数为 0"},
+		{"1 % (2 - 2)", "除数为 0"},
+		{"voltage * power", "变量 power 无值"},
+		{"{water level} + 1", "变量 water level 无值"},
+		{"sqrt(neg)", "结果无效"},
+		{"pow(10, 400)", "结果无效"},
+	}
+	for _, c := range cases {
+		e, err := Parse(c.src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", c.src, err)
+			continue
+		}
+		if _, err := e.Eval(testVars); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("Eval(%q) err=%v，期望包含 %q", c.src, err, c.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		src  string
+		want string
+	}{
+		{"", "表达式不完整"},
+		{"1 +", "表达式不完整"},
+		{"(1 + 2", "缺少 )"},
+		{"abs(1", "缺少 , 或 )"},
+		{"min(1 2)", "缺少 , 或 )"},
+		{"1 + 2)", "多余的 \")\""},
+		{"1 2", "多余的 \"2\""},
+		{"voltage current", "多余的 \"current\""},
+		{"{water-level", "未闭合的 {"},
+		{"{ } + 1", "空的 {}"},
+		{"1 $ 2", "非法字符 '$'"},
+		{"voltage # 2", "非法字符 '#'"},
+		{"1.2.3", "数字 \"1.2.3\" 无效"},
+		{"* 2", "意外的 \"*\""},
+		{"log(2)", "未知函数 log"},
+		{"min(1)", "函数 min 需要 2 个参数，得到 1 个"},
+		{"abs(1, 2)", "函数 abs 需要 1 个参数，得到 2 个"},
+		{"round()", "函数 round 需要 1 个参数，得到 0 个"},
+	}
+	for _, c := range cases {
+		e, err := Parse(c.src)
+		if err == nil {
+			t.Errorf("Parse(%q) 未报错，得到 %v", c.src, e)
+			continue
+		}
+		if !strings.Contains(err.Error(), c.want) {
+			t.Errorf("Parse(%q) err=%v，期望包含 %q", c.src, err, c.want)
+		}
+	}
+}
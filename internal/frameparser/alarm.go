@@ -0,0 +1,48 @@
+package frameparser
+
+import (
+	"sync"
+	"time"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/trace"
+)
+
+// AlarmHandler 在告警报文（PacketType=2）的参量写入 Sink 后被调用：
+// values 为解析成功的参量（参数表名称 → 值），received 为帧的接收时间
+type AlarmHandler func(id trace.ID, sensorID string, received time.Time, values map[string]any)
+
+var (
+	alarmMu      sync.RWMutex
+	alarmHandler AlarmHandler
+)
+
+// SetAlarmHandler 设置告警回调（如告警锁存），对未设置 PipelineOptions.Alarm 的流水线生效
+func SetAlarmHandler(h AlarmHandler) {
+	alarmMu.Lock()
+	defer alarmMu.Unlock()
+	alarmHandler = h
+}
+
+// notifyAlarm 按参数表解析告警参量并调用生效的告警回调
+func (p *Pipeline) notifyAlarm(id trace.ID, sensorID string, received time.Time, params []Param, dialect *Dialect) {
+	h := p.alarm
+	if h == nil {
+		alarmMu.RLock()
+		h = alarmHandler
+		alarmMu.RUnlock()
+	}
+	if h == nil {
+		return
+	}
+	values := make(map[string]any, len(params))
+	for _, param := range params {
+		info, ok := p.cfg.LookupParamInfo(sensorID, param.Type)
+		if !ok {
+			continue
+		}
+		if v, err := info.Parse(dialect.valueBytes(param.Data)); err == nil {
+			values[info.Name] = v
+		}
+	}
+	h(id, sensorID, received, values)
+}
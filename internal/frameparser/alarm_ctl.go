@@ -0,0 +1,63 @@
+package frameparser
+
+// 封装 7.3 节 告警参数查询/设置报文
+
+import (
+	"fmt"
+)
+
+// ctrlTypeAlarmParams 7bit = 2 （协议“告警参数查询/设置”类型码）
+const ctrlTypeAlarmParams = 0x02
+
+// CtrlTypeAlarmParams 导出给下发方匹配告警参数的控制响应
+const CtrlTypeAlarmParams = ctrlTypeAlarmParams
+
+// BuildAlarmParamFrame 构造“告警参数查询/设置”控制报文：
+//
+//	sensorID        [6]byte — 传感器 ID
+//	requestSetFlag  byte    — 0=查询，1=设置
+//	params          []Param — 设置时为告警阈值参量（类型 + 数据，编码同上行参量）；
+//	                          查询时为空表示查询全部，否则只列出参量类型（数据忽略）
+//
+// 返回：完整的二进制帧（已附加 CRC16），或错误。
+func BuildAlarmParamFrame(sensorID [6]byte, requestSetFlag byte, params []Param) ([]byte, error) {
+	if requestSetFlag != 0 && requestSetFlag != 1 {
+		return nil, fmt.Errorf("invalid requestSetFlag %d, must be 0 or 1", requestSetFlag)
+	}
+	m := len(params)
+	if m > MaxExtendedParams || (requestSetFlag == 1 && m == 0) {
+		return nil, fmt.Errorf("%w: 告警参量个数必须 %d~%d, got %d", ErrParamCount, requestSetFlag, MaxExtendedParams, m)
+	}
+
+	// 1. DataLen：查询全部时为 0b1111；超过 14 个时为 0b1111 加扩展计数
+	dataLen := dataLenNibble(m)
+	if m == 0 {
+		dataLen = DataLenExtended
+	}
+
+	// 2. SensorID + head + CtrlType<<1|flag
+	buf := make([]byte, 0, 6+1+1+1+6*m+2)
+	buf = append(buf, sensorID[:]...)
+	buf = append(buf, byte((dataLen&0x0F)<<4)|byte(packetTypeControl&0x07))
+	buf = append(buf, byte((ctrlTypeAlarmParams&0x7F)<<1)|(requestSetFlag&0x01))
+	buf = appendExtendedCount(buf, m)
+
+	// 3. 参量列表：设置时带数据，查询时只有 2 字节类型头
+	for _, p := range params {
+		if requestSetFlag == 1 {
+			var err error
+			if buf, err = AppendParam(buf, p); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if p.Type > 0x3FFF {
+			return nil, fmt.Errorf("%w: 0x%X", ErrInvalidParamType, p.Type)
+		}
+		head := p.Type << 2
+		buf = append(buf, byte(head), byte(head>>8))
+	}
+
+	// 4. CRC16（大端）
+	return StandardDialect.Seal(buf), nil
+}
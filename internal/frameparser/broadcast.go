@@ -0,0 +1,11 @@
+package frameparser
+
+// BroadcastSensorID 广播地址：全 FF 的 SensorID，控制报文发往该地址时所有传感器都会执行，
+// 各传感器以自身 SensorID 回复控制响应
+const BroadcastSensorID = "FFFFFFFFFFFF"
+
+// BroadcastAddress 为 BroadcastSensorID 的字节形式，可直接传给各 Build*Frame
+var BroadcastAddress = [6]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+
+// CtrlTypeTimeParam 导出时间参数查询/设置的控制类型码
+const CtrlTypeTimeParam = ctrlTypeTimeParam
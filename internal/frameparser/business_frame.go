@@ -0,0 +1,78 @@
+package frameparser
+
+// 构造上行业务数据报文（监测数据 / 告警数据），供模拟传感器和联调工具使用；分片帧见 BuildFragmentFrames
+
+import (
+	"fmt"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+)
+
+// 业务数据报文类型
+const (
+	PacketTypeMonitoring byte = 0x00 // 监测数据报文
+	PacketTypeAlarm      byte = 0x02 // 告警数据报文
+)
+
+// ParamValue 待编码的参量：Value 为 []byte 时按原始数据写入，否则按参数表中该类型的 Encode 编码
+type ParamValue struct {
+	Type  uint16
+	Value any
+}
+
+// BuildBusinessFrame 构造上行业务数据报文（不分片）：
+//
+//	SensorID(6B) + head(DataLen|FragInd=0|PacketType) + [扩展计数] + 参量列表 + CRC16（大端）
+//
+// 参量按 AppendParam 相同的格式编码（非 []byte 的值按该传感器的参数表版本编码），
+// 生成的帧经 DecodeParams 和参数表解析后得到原值
+func BuildBusinessFrame(sensorID [6]byte, packetType byte, params []ParamValue) ([]byte, error) {
+	head, content, err := businessContent(sensorID, packetType, params)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 0, 6+1+len(content)+2)
+	buf = append(buf, sensorID[:]...)
+	buf = append(buf, head)
+	buf = append(buf, content...)
+	// CRC16（大端）
+	return StandardDialect.Seal(buf), nil
+}
+
+// businessContent 返回业务数据报文的报文头（FragInd=0）和报文头之后的内容（[扩展计数] + 参量列表），
+// 分片帧切分的即该内容
+func businessContent(sensorID [6]byte, packetType byte, params []ParamValue) (byte, []byte, error) {
+	sid := sensorHex(sensorID)
+	if packetType != PacketTypeMonitoring && packetType != PacketTypeAlarm {
+		return 0, nil, fmt.Errorf("PacketType=%d 不是业务数据报文（监测=0，告警=2）", packetType)
+	}
+	// DataLen=0 为心跳，超过 14 个参量时 DataLen=0b1111 并带扩展计数
+	m := len(params)
+	if m == 0 || m > MaxExtendedParams {
+		return 0, nil, fmt.Errorf("%w: 必须 1~%d, got %d", ErrParamCount, MaxExtendedParams, m)
+	}
+
+	// 1. 扩展计数
+	buf := make([]byte, 0, 1+6*m)
+	buf = appendExtendedCount(buf, m)
+
+	// 2. 参量列表
+	for _, pv := range params {
+		data, ok := pv.Value.([]byte)
+		if !ok {
+			info, found := config.LookupSensorParamInfo(sid, pv.Type)
+			if !found {
+				return 0, nil, fmt.Errorf("参量类型 0x%04X 不在参数表中，请传入原始 []byte", pv.Type)
+			}
+			var err error
+			if data, err = config.EncodeParamValue(info, pv.Value); err != nil {
+				return 0, nil, err
+			}
+		}
+		var err error
+		if buf, err = AppendParam(buf, Param{Type: pv.Type, Data: data}); err != nil {
+			return 0, nil, err
+		}
+	}
+	return dataLenNibble(m)<<4 | packetType&0x07, buf, nil
+}
@@ -0,0 +1,183 @@
+package frameparser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+	"github.com/linjuya-lu/device-lpmp-go/internal/serial"
+)
+
+// 水位（float32，小端）
+const waterLevelType uint16 = 0x00A3
+
+// testConfig 把所有传感器绑定到同一个设备，其余查询使用 config 包的全局表
+type testConfig struct {
+	PackageConfig
+	device string
+}
+
+func (c testConfig) LookupSensorBindings(string) []config.SensorBinding {
+	return []config.SensorBinding{{DeviceName: c.device}}
+}
+
+// published Sink 收到的一个读数
+type published struct {
+	Resource string
+	Value    any
+}
+
+// runPipeline 把 frames 依次交给一条新的流水线，输入读完后返回 Sink 收到的读数
+func runPipeline(t testing.TB, frames ...[]byte) []published {
+	t.Helper()
+	in := make(chan serial.RxFrame, len(frames))
+	for _, f := range frames {
+		in <- serial.RxFrame{Data: f, Received: time.Now()}
+	}
+	close(in)
+	var out []published
+	p := NewPipeline(PipelineOptions{
+		Name:   t.Name(),
+		Input:  in,
+		Config: testConfig{device: "dev"},
+		Sink: ValueSinkFunc(func(_, resourceName string, value any, _ time.Time, _ map[string]string) {
+			out = append(out, published{resourceName, value})
+		}),
+		Backfill: true,
+	})
+	p.Start(context.Background())
+	select {
+	case <-p.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("流水线未退出")
+	}
+	return out
+}
+
+func TestBuildBusinessFrameRoundTrip(t *testing.T) {
+	id := [6]byte{0x23, 0x8A, 0x08, 0x21, 0xBE, 0x01}
+	frame, err := BuildBusinessFrame(id, PacketTypeMonitoring, []ParamValue{
+		{Type: waterLevelType, Value: 1.25},
+		{Type: 0x0FFF, Value: []byte{0x01, 0x02, 0x03}}, // 不在参数表中：原始数据，LengthFlag=1
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := sealedBody(t, frame)
+	if body[6] != 0x20 {
+		t.Errorf("报文头 0x%02X，期望 0x20（DataLen=2 | 监测数据）", body[6])
+	}
+
+	s := DescribeFrame(frame, StandardDialect)
+	if !s.CRCOK || s.Err != nil || s.PacketType != PacketTypeMonitoring || s.DataLen != 2 || len(s.Params) != 2 {
+		t.Fatalf("解码 %+v", s)
+	}
+	if p := s.Params[0]; p.Type != waterLevelType || p.Err != nil || p.Value != float32(1.25) {
+		t.Errorf("水位 %+v，期望 1.25", p)
+	}
+	if p := s.Params[1]; p.Type != 0x0FFF || fmt.Sprintf("% X", p.Raw) != "01 02 03" {
+		t.Errorf("原始参量 %+v", p)
+	}
+
+	// 流水线解析后交给 Sink 的值与构造时一致
+	got := runPipeline(t, frame)
+	if len(got) == 0 || got[0].Resource != "water-level" || got[0].Value != float32(1.25) {
+		t.Errorf("流水线输出 %+v，期望 water-level=1.25", got)
+	}
+}
+
+func TestBuildBusinessFrameExtendedCount(t *testing.T) {
+	id := [6]byte{0x23, 0x8A, 0x08, 0x21, 0xBE, 0x02}
+	params := make([]ParamValue, 20)
+	for i := range params {
+		params[i] = ParamValue{Type: waterLevelType, Value: float32(i)}
+	}
+	frame, err := BuildBusinessFrame(id, PacketTypeAlarm, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 超过 14 个参量：DataLen=0b1111，报文头之后为扩展计数
+	body := sealedBody(t, frame)
+	if body[6] != 0xF2 {
+		t.Errorf("报文头 0x%02X，期望 0xF2", body[6])
+	}
+	s := DescribeFrame(frame, StandardDialect)
+	if s.Err != nil || s.PacketType != PacketTypeAlarm || s.DataLen != len(params) || len(s.Params) != len(params) {
+		t.Fatalf("解码 %+v", s)
+	}
+	for i, p := range s.Params {
+		if p.Value != float32(i) {
+			t.Errorf("第 %d 个参量 %v，期望 %d", i, p.Value, i)
+		}
+	}
+}
+
+func TestBuildBusinessFrameCRC(t *testing.T) {
+	id := [6]byte{0x23, 0x8A, 0x08, 0x21, 0xBE, 0x03}
+	frame, err := BuildBusinessFrame(id, PacketTypeMonitoring, []ParamValue{{Type: waterLevelType, Value: 2.5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealedBody(t, frame)
+
+	// 报文体或 CRC 任一字节出错都校验失败，流水线不输出读数并按 crc-mismatch 计数
+	for _, i := range []int{0, 6, 8, len(frame) - 1} {
+		bad := append([]byte(nil), frame...)
+		bad[i] ^= 0x01
+		if s := DescribeFrame(bad, StandardDialect); s.CRCOK || !errors.Is(s.Err, ErrCRCMismatch) {
+			t.Errorf("第 %d 字节出错: %+v", i, s)
+		}
+		before := ErrorCounts()[ErrorKind(ErrCRCMismatch)]
+		if got := runPipeline(t, bad); len(got) != 0 {
+			t.Errorf("第 %d 字节出错仍输出 %+v", i, got)
+		}
+		if after := ErrorCounts()[ErrorKind(ErrCRCMismatch)]; after != before+1 {
+			t.Errorf("第 %d 字节出错: crc-mismatch 计数 %d → %d", i, before, after)
+		}
+	}
+}
+
+func TestBuildBusinessFrameErrors(t *testing.T) {
+	id := [6]byte{0x23, 0x8A, 0x08, 0x21, 0xBE, 0x04}
+	one := []ParamValue{{Type: waterLevelType, Value: 1}}
+	if _, err := BuildBusinessFrame(id, 0x04, one); err == nil {
+		t.Error("控制报文类型未被拒绝")
+	}
+	if _, err := BuildBusinessFrame(id, PacketTypeMonitoring, nil); !errors.Is(err, ErrParamCount) {
+		t.Errorf("没有参量（心跳）: %v", err)
+	}
+	if _, err := BuildBusinessFrame(id, PacketTypeMonitoring, make([]ParamValue, MaxExtendedParams+1)); !errors.Is(err, ErrParamCount) {
+		t.Errorf("参量个数超限: %v", err)
+	}
+	if _, err := BuildBusinessFrame(id, PacketTypeMonitoring, []ParamValue{{Type: 0x0FFF, Value: 1}}); err == nil {
+		t.Error("不在参数表中的非 []byte 参量未被拒绝")
+	}
+}
+
+func TestBuildFragmentFramesRoundTrip(t *testing.T) {
+	id := [6]byte{0x23, 0x8A, 0x08, 0x21, 0xBE, 0x05}
+	frames, err := BuildFragmentFrames(id, PacketTypeMonitoring, 9, []ParamValue{{Type: waterLevelType, Value: 3.5}}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 3 {
+		t.Fatalf("%d 片，期望 3", len(frames))
+	}
+	for i, frame := range frames {
+		sealedBody(t, frame)
+		f, err := ParseFragment(frame)
+		if err != nil {
+			t.Fatalf("第 %d 片: %v", i, err)
+		}
+		if f.SensorID != id || f.SSEQ != 9 || f.PSEQ != uint8(i) {
+			t.Errorf("第 %d 片分片头 %+v", i, f)
+		}
+	}
+	got := runPipeline(t, frames...)
+	if len(got) == 0 || got[0].Resource != "water-level" || got[0].Value != float32(3.5) {
+		t.Errorf("流水线输出 %+v，期望 water-level=3.5", got)
+	}
+}
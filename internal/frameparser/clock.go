@@ -0,0 +1,163 @@
+package frameparser
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Timer 为拼接超时定时器，与 *time.Timer 的 Stop 语义相同
+type Timer interface {
+	Stop() bool
+}
+
+// Clock 提供拼接超时所需的定时器，测试中可替换为 ManualClock 以确定性地触发超时
+type Clock interface {
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// realClock 使用系统时间
+type realClock struct{}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+var (
+	clockMu sync.RWMutex
+	clock   Clock = realClock{}
+)
+
+// SetClock 替换拼接使用的时钟，传 nil 恢复系统时钟；只影响之后启动的定时器
+func SetClock(c Clock) {
+	clockMu.Lock()
+	defer clockMu.Unlock()
+	if c == nil {
+		c = realClock{}
+	}
+	clock = c
+}
+
+// SetReassembleTimeout 设置分片拼接超时时间
+func SetReassembleTimeout(d time.Duration) {
+	clockMu.Lock()
+	defer clockMu.Unlock()
+	reassembleTimeout = d
+}
+
+// SetPSEQStart 设置未在 ReassemblerOptions 中指定时使用的首片序号起点
+func SetPSEQStart(start PSEQStart) {
+	clockMu.Lock()
+	defer clockMu.Unlock()
+	pseqStartDefault = start
+}
+
+// SetPreFirstWindow 设置首片之前到达片段的暂存时长，0 表示不暂存（直接丢弃）
+func SetPreFirstWindow(d time.Duration) {
+	clockMu.Lock()
+	defer clockMu.Unlock()
+	preFirstWindow = d
+}
+
+// currentPreFirstWindow 返回包级首片前暂存时长
+func currentPreFirstWindow() time.Duration {
+	clockMu.RLock()
+	defer clockMu.RUnlock()
+	return preFirstWindow
+}
+
+// currentPSEQStart 返回包级首片序号起点
+func currentPSEQStart() PSEQStart {
+	clockMu.RLock()
+	defer clockMu.RUnlock()
+	return pseqStartDefault
+}
+
+// currentClock 返回当前时钟和拼接超时时间
+func currentClock() (Clock, time.Duration) {
+	clockMu.RLock()
+	defer clockMu.RUnlock()
+	return clock, reassembleTimeout
+}
+
+// ManualClock 手动推进的时钟：定时器只在 Advance 越过到期时间时在调用方协程中触发
+type ManualClock struct {
+	mu     sync.Mutex
+	now    time.Duration
+	timers []*manualTimer
+}
+
+type manualTimer struct {
+	clock   *ManualClock
+	due     time.Duration
+	f       func()
+	stopped bool
+}
+
+// NewManualClock 创建一个从 0 开始的手动时钟
+func NewManualClock() *ManualClock {
+	return &ManualClock{}
+}
+
+// AfterFunc 实现 Clock
+func (c *ManualClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &manualTimer{clock: c, due: c.now + d, f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance 推进时钟，按到期先后依次执行到期的定时器回调
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now += d
+	var due, pending []*manualTimer
+	for _, t := range c.timers {
+		switch {
+		case t.stopped:
+		case t.due <= c.now:
+			due = append(due, t)
+		default:
+			pending = append(pending, t)
+		}
+	}
+	c.timers = pending
+	c.mu.Unlock()
+
+	sort.SliceStable(due, func(i, j int) bool { return due[i].due < due[j].due })
+	for _, t := range due {
+		t.f()
+	}
+}
+
+// Now 返回手动时钟的当前时间（以 Unix 零点为起点加上已推进的时长）
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Unix(0, 0).Add(c.now)
+}
+
+// Pending 返回尚未触发且未停止的定时器个数
+func (c *ManualClock) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for _, t := range c.timers {
+		if !t.stopped {
+			n++
+		}
+	}
+	return n
+}
+
+// Stop 实现 Timer
+func (t *manualTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	if t.stopped || t.due <= t.clock.now {
+		return false
+	}
+	t.stopped = true
+	return true
+}
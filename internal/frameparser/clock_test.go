@@ -0,0 +1,42 @@
+package frameparser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/trace"
+)
+
+// TestLiveFragmentTimeout 只收到首片的拼接在 ManualClock 越过超时时间后丢弃
+func TestLiveFragmentTimeout(t *testing.T) {
+	clock := NewManualClock()
+	p := NewPipeline(PipelineOptions{Name: "test", Reassembly: ReassemblerOptions{Timeout: 5 * time.Second, Clock: clock}})
+	events := SubscribeReassembly(16)
+
+	p.handleFrame(trace.ID("clock-test"), fragFirst, time.Time{})
+	if clock.Pending() != 1 {
+		t.Fatalf("首片后定时器个数 %d，期望 1", clock.Pending())
+	}
+	clock.Advance(4 * time.Second)
+	if clock.Pending() != 1 {
+		t.Fatal("未到超时时间定时器已触发")
+	}
+	clock.Advance(time.Second)
+	if clock.Pending() != 0 {
+		t.Fatal("超时后定时器仍未触发")
+	}
+	for {
+		select {
+		case ev := <-events:
+			if ev.Kind != ReassemblyDropped {
+				continue
+			}
+			if ev.Reason != DropTimeout || ev.SSEQ != 5 || ev.Bytes != 4 {
+				t.Errorf("Dropped 事件 %+v，期望 %s SSEQ=5 4 字节", ev, DropTimeout)
+			}
+			return
+		case <-time.After(time.Second):
+			t.Fatal("未收到超时丢弃事件")
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package frameparser
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/paramcodec"
+)
+
+// 一致性校验规则名，对应《Q/GDW 12184—2021》附录 D 的结构性约束
+const (
+	RuleCRC              = "crc"               // CRC 与报文内容不符
+	RuleFrameLength      = "frame-length"      // 帧长度不足 SensorID + 报文头 + CRC
+	RulePacketType       = "packet-type"       // PacketType 为保留值（6、7）
+	RuleDataLen          = "data-len"          // 上行报文 DataLen=0b1111 但缺少扩展计数，或扩展计数不超过 14
+	RuleParamType        = "param-type"        // 参量类型码为保留值 0
+	RuleParamOverflow    = "param-overflow"    // 参量声明的长度超出帧长度
+	RuleTrailingBytes    = "trailing-bytes"    // 按 DataLen 解析完参量后仍有多余字节
+	RuleCtrlType         = "ctrl-type"         // 控制报文缺少控制字节或 CtrlType 为保留值 0
+	RuleHeartbeatPayload = "heartbeat-payload" // 心跳（DataLen=0）携带了数据
+)
+
+// ConformanceReport 单个传感器的一致性校验统计
+type ConformanceReport struct {
+	SensorID   string            `json:"sensorId"`
+	Frames     uint64            `json:"frames"`
+	Violations uint64            `json:"violations"`
+	ByRule     map[string]uint64 `json:"byRule,omitempty"`
+	// 最近一次违规的说明和原始帧（十六进制）
+	LastViolation string    `json:"lastViolation,omitempty"`
+	LastFrame     string    `json:"lastFrame,omitempty"`
+	LastSeen      time.Time `json:"lastSeen"`
+}
+
+var (
+	// conformanceEnabled 为 true 时逐帧做结构性校验（用于新厂家传感器认证，有额外开销）
+	conformanceEnabled atomic.Bool
+
+	conformanceMu sync.Mutex
+	// conformance 按 SensorID 汇总的校验结果
+	conformance = make(map[string]*ConformanceReport)
+)
+
+// SetConformanceMode 开关一致性校验模式，关闭时保留已有统计
+func SetConformanceMode(on bool) {
+	conformanceEnabled.Store(on)
+}
+
+// ConformanceMode 返回一致性校验模式是否开启
+func ConformanceMode() bool {
+	return conformanceEnabled.Load()
+}
+
+// ConformanceReports 返回各传感器的校验统计副本，按 SensorID 排序
+func ConformanceReports() []ConformanceReport {
+	conformanceMu.Lock()
+	defer conformanceMu.Unlock()
+	out := make([]ConformanceReport, 0, len(conformance))
+	for _, r := range conformance {
+		c := *r
+		c.ByRule = make(map[string]uint64, len(r.ByRule))
+		for k, v := range r.ByRule {
+			c.ByRule[k] = v
+		}
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].SensorID < out[j].SensorID })
+	return out
+}
+
+// ResetConformance 清空校验统计
+func ResetConformance() {
+	conformanceMu.Lock()
+	defer conformanceMu.Unlock()
+	conformance = make(map[string]*ConformanceReport)
+}
+
+// violation 一条违规：规则名 + 说明
+type violation struct {
+	rule, detail string
+}
+
+// recordConformance 记录一帧的校验结果，raw 为原始帧（用于报告），violations 为空表示合规
+func recordConformance(sensorID string, raw []byte, violations []violation) {
+	conformanceMu.Lock()
+	defer conformanceMu.Unlock()
+	r, ok := conformance[sensorID]
+	if !ok {
+		r = &ConformanceReport{SensorID: sensorID, ByRule: make(map[string]uint64)}
+		conformance[sensorID] = r
+	}
+	r.Frames++
+	r.LastSeen = time.Now()
+	if len(violations) == 0 {
+		return
+	}
+	r.Violations++
+	for _, v := range violations {
+		r.ByRule[v.rule]++
+	}
+	last := violations[len(violations)-1]
+	r.LastViolation = fmt.Sprintf("%s: %s", last.rule, last.detail)
+	r.LastFrame = hex.EncodeToString(raw)
+}
+
+// checkConformance 按规范检查已剔除方言私有头的标准帧（含 CRC，CRC 已校验通过）；
+// compressed 为 true 时报文内容为压缩数据，只检查报文头；参量长度按方言的 LengthFlag 长度表
+func checkConformance(frame []byte, compressed bool, lengths paramcodec.LengthTable) []violation {
+	var out []violation
+	add := func(rule, format string, args ...any) {
+		out = append(out, violation{rule, fmt.Sprintf(format, args...)})
+	}
+	if len(frame) < 9 {
+		add(RuleFrameLength, "帧长 %d 字节，至少需要 9 字节", len(frame))
+		return out
+	}
+
+	// 1. 报文头
+	head := frame[6]
+	dataLen := int(head >> 4)
+	fragInd := (head >> 3) & 0x1
+	packetType := head & 0x07
+	body := frame[7 : len(frame)-2]
+	if packetType > packetTypeControlResp {
+		add(RulePacketType, "PacketType=%d 为保留值", packetType)
+		return out
+	}
+	// 分片帧的内容是 SDU 片段、压缩帧的内容需解压，都不做参量级检查
+	if fragInd == 1 || compressed {
+		return out
+	}
+
+	// 2. 控制报文：首字节为 CtrlType<<1|RequestSetFlag
+	if packetType == packetTypeControl || packetType == packetTypeControlResp {
+		if len(body) < 1 {
+			add(RuleCtrlType, "控制报文缺少控制字节")
+			return out
+		}
+		if body[0]>>1 == 0 {
+			add(RuleCtrlType, "CtrlType=0 为保留值")
+		}
+		return out
+	}
+
+	// 3. 业务报文：心跳不带数据，其余按 DataLen 逐个参量核对长度
+	if dataLen == DataLenExtended {
+		count, rest, err := ExpandDataLen(dataLen, body)
+		if err != nil {
+			add(RuleDataLen, "上行报文 DataLen=0b1111 但缺少扩展计数字节")
+			return out
+		}
+		if count <= maxQueryParams {
+			add(RuleDataLen, "扩展计数 %d 不超过 %d，应直接写在 DataLen 中", count, maxQueryParams)
+		}
+		dataLen, body = count, rest
+	}
+	if dataLen == 0 {
+		if len(body) != 0 {
+			add(RuleHeartbeatPayload, "DataLen=0 但携带 %d 字节数据", len(body))
+		}
+		return out
+	}
+	params, idx, err := lengths.Decode(body, dataLen)
+	for i, p := range params {
+		if p.Type == 0 {
+			add(RuleParamType, "第 %d 个参量类型码为 0", i+1)
+		}
+	}
+	if err != nil {
+		add(RuleParamOverflow, "第 %d/%d 个参量: %v", len(params)+1, dataLen, err)
+		return out
+	}
+	if idx != len(body) {
+		add(RuleTrailingBytes, "%d 个参量共 %d 字节，报文内容 %d 字节", dataLen, idx, len(body))
+	}
+	return out
+}
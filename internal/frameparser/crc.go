@@ -53,3 +53,19 @@ func CRC16(data []byte) uint16 {
 	}
 	return uint16(crcHi)<<8 | uint16(crcLo)
 }
+
+// CRC16CCITT 计算 CRC-16/CCITT-FALSE（多项式 0x1021，初值 0xFFFF），部分厂家报文使用
+func CRC16CCITT(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,124 @@
+package frameparser
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/correlation"
+	"github.com/linjuya-lu/device-lpmp-go/internal/paramcodec"
+)
+
+// packetTypeControlResp 3bit = 101b = 5（控制响应报文）
+const packetTypeControlResp = 0x05
+
+// PacketTypeControlResp 导出给下发方登记期望的控制响应
+const PacketTypeControlResp byte = packetTypeControlResp
+
+// CtrlTypeGeneralParams 导出通用参数查询/设置的控制类型码
+const CtrlTypeGeneralParams = ctrlTypeGeneralParams
+
+// 设置应答不回送参量时（DataLen=0）携带 1 字节执行结果
+const (
+	ControlStatusSuccess byte = 0xFF
+	ControlStatusFailure byte = 0x00
+)
+
+// ErrControlFailed 传感器应答执行失败
+var ErrControlFailed = errors.New("传感器拒绝执行控制报文")
+
+// ControlResponse 控制响应报文的子层内容
+type ControlResponse struct {
+	SensorID   string
+	CtrlType   uint8 // 7bit 控制类型，与请求相同
+	RequestSet bool  // 与请求的 RequestSetFlag 相同：false=查询应答，true=设置应答
+	DataLen    int   // 报文头中的 DataLen，0b1111 时参量个数见 Data 首字节（扩展计数）
+	Data       []byte
+	HasStatus  bool // 应答只携带执行结果，不含参量
+	Status     byte // 执行结果，HasStatus 为 true 时有效
+}
+
+// Succeeded 应答是否表示执行成功：只携带执行结果时按状态码判断，回送参量视为成功
+func (r ControlResponse) Succeeded() bool {
+	return !r.HasStatus || r.Status == ControlStatusSuccess
+}
+
+// Params 按参量格式解出响应中携带的参数
+func (r ControlResponse) Params() ([]Param, error) {
+	count, data, err := ExpandDataLen(r.DataLen, r.Data)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeParams(data, count)
+}
+
+// parseControlResponse 解析控制响应子层：首字节 CtrlType(7bit)<<1 | RequestSetFlag，其后为数据
+func parseControlResponse(fc FrameCtl) (ControlResponse, error) {
+	raw, ok := fc.Payload.([]byte)
+	if !ok || len(raw) < 1 {
+		return ControlResponse{}, fmt.Errorf("控制响应 SensorID=%s 缺少控制字节", fc.SensorID)
+	}
+	resp := ControlResponse{
+		SensorID:   fc.SensorID,
+		CtrlType:   raw[0] >> 1,
+		RequestSet: raw[0]&0x01 == 1,
+		DataLen:    fc.DataLen,
+		Data:       raw[1:],
+	}
+	if resp.RequestSet && resp.DataLen == 0 && len(resp.Data) == 1 {
+		resp.HasStatus = true
+		resp.Status = resp.Data[0]
+	}
+	return resp, nil
+}
+
+// resolveControlResponse 唤醒等待该传感器控制响应的下发方，执行失败以 ErrControlFailed 通知
+func resolveControlResponse(fc FrameCtl, resp ControlResponse, err error) {
+	if err == nil && !resp.Succeeded() {
+		err = fmt.Errorf("%w: CtrlType=%d 状态码=0x%02X", ErrControlFailed, resp.CtrlType, resp.Status)
+	}
+	key := correlation.Key{SensorID: fc.SensorID, PacketType: packetTypeControlResp}
+	if n := correlation.Default.Resolve(key, correlation.Result{Payload: resp, Err: err}); n > 0 {
+		debugf("[trace=%s] 控制响应 SensorID=%s CtrlType=%d 已交给 %d 个等待方", fc.TraceID, fc.SensorID, resp.CtrlType, n)
+	}
+}
+
+// BuildParamSetFrame 按参量类型构造“通用参数设置”控制报文（RequestSetFlag=1），
+// 参量按上行相同的格式编码
+func BuildParamSetFrame(sensorID [6]byte, params []Param) ([]byte, error) {
+	m := len(params)
+	if m == 0 || m > MaxExtendedParams {
+		return nil, fmt.Errorf("%w: 必须 1~%d, got %d", ErrParamCount, MaxExtendedParams, m)
+	}
+
+	// 1. SensorID + head(DataLen|FragInd=0|PacketType=4) + CtrlType<<1|1 + 扩展计数（超过 14 个时）
+	buf := make([]byte, 0, 6+1+1+1+6*m+2)
+	buf = append(buf, sensorID[:]...)
+	buf = append(buf, dataLenNibble(m)<<4|byte(packetTypeControl&0x07))
+	buf = append(buf, byte((ctrlTypeGeneralParams&0x7F)<<1)|0x01)
+	buf = appendExtendedCount(buf, m)
+
+	// 2. 参数列表
+	var err error
+	for _, p := range params {
+		if buf, err = AppendParam(buf, p); err != nil {
+			return nil, err
+		}
+	}
+
+	// 3. CRC16（大端）
+	return StandardDialect.Seal(buf), nil
+}
+
+// BuildRawParamSetFrame 构造只含一个参量的“通用参数设置”控制报文，参量按指定的 LengthFlag 编码
+// （见 paramcodec.LengthTable.AppendFlag），用于参数表尚未描述的参量
+func BuildRawParamSetFrame(sensorID [6]byte, p Param, lenFlag int) ([]byte, error) {
+	buf := make([]byte, 0, 6+1+1+2+3+len(p.Data)+2)
+	buf = append(buf, sensorID[:]...)
+	buf = append(buf, dataLenNibble(1)<<4|byte(packetTypeControl&0x07))
+	buf = append(buf, byte((ctrlTypeGeneralParams&0x7F)<<1)|0x01)
+	buf, err := paramcodec.Spec.AppendFlag(buf, p, lenFlag)
+	if err != nil {
+		return nil, err
+	}
+	return StandardDialect.Seal(buf), nil
+}
@@ -0,0 +1,74 @@
+package frameparser
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/trace"
+)
+
+// LogLevel 解析器日志级别，警告和错误始终输出
+type LogLevel int32
+
+const (
+	LevelWarn LogLevel = iota
+	LevelInfo
+	LevelDebug
+)
+
+var (
+	// logLevel 当前解析器日志级别，默认 INFO（与原有输出一致）
+	logLevel atomic.Int32
+	// logRawFrames 为 true 时打印每一帧的原始十六进制内容
+	logRawFrames atomic.Bool
+)
+
+func init() {
+	logLevel.Store(int32(LevelInfo))
+}
+
+// ParseLogLevel 解析日志级别字符串（不区分大小写）：ERROR/WARN、INFO、DEBUG/TRACE
+func ParseLogLevel(s string) (LogLevel, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "ERROR", "WARN":
+		return LevelWarn, nil
+	case "", "INFO":
+		return LevelInfo, nil
+	case "DEBUG", "TRACE":
+		return LevelDebug, nil
+	}
+	return LevelInfo, fmt.Errorf("未知日志级别 %q", s)
+}
+
+// SetLogLevel 运行时调整解析器日志级别
+func SetLogLevel(l LogLevel) {
+	logLevel.Store(int32(l))
+}
+
+// SetLogRawFrames 运行时开关原始帧十六进制打印
+func SetLogRawFrames(on bool) {
+	logRawFrames.Store(on)
+}
+
+// infof 在 INFO 及以上级别输出
+func infof(format string, args ...any) {
+	if LogLevel(logLevel.Load()) >= LevelInfo {
+		log.Printf(format, args...)
+	}
+}
+
+// debugf 仅在 DEBUG 级别输出
+func debugf(format string, args ...any) {
+	if LogLevel(logLevel.Load()) >= LevelDebug {
+		log.Printf(format, args...)
+	}
+}
+
+// dumpRawFrame 在开启原始帧打印时输出整帧十六进制
+func dumpRawFrame(id trace.ID, frame []byte) {
+	if logRawFrames.Load() {
+		log.Printf("[RAW][trace=%s] %d 字节: % X", id, len(frame), frame)
+	}
+}
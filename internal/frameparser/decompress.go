@@ -0,0 +1,165 @@
+package frameparser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+// 方言中可选的 SDU 压缩算法
+const (
+	CompressionGzip = "gzip"
+	CompressionZlib = "zlib"
+	// CompressionLZ4 为 LZ4 块格式（无帧头），解压后长度以 maxDecompressedSize 为上限
+	CompressionLZ4 = "lz4"
+)
+
+// DefaultMaxDecompressedSize 解压后报文内容的默认上限，防止解压炸弹
+const DefaultMaxDecompressedSize = 64 << 10
+
+// ErrDecompressedTooLarge 解压后的数据超过上限
+var ErrDecompressedTooLarge = errors.New("解压后数据超过上限")
+
+// maxDecompressedSize 解压后报文内容的上限（字节）
+var maxDecompressedSize atomic.Int64
+
+func init() {
+	maxDecompressedSize.Store(DefaultMaxDecompressedSize)
+}
+
+// SetMaxDecompressedSize 设置解压后报文内容的上限，n <= 0 时不修改
+func SetMaxDecompressedSize(n int) {
+	if n <= 0 {
+		return
+	}
+	maxDecompressedSize.Store(int64(n))
+}
+
+// validCompression 判断方言中的压缩算法名是否支持
+func validCompression(name string) bool {
+	switch name {
+	case CompressionGzip, CompressionZlib, CompressionLZ4:
+		return true
+	}
+	return false
+}
+
+// isCompressed 按方言判断原始帧（剔除厂家头之前）的报文内容是否经过压缩：
+// 未配置压缩时为 false；配置了压缩但未指定标志位时所有帧都视为压缩
+func (d *Dialect) isCompressed(raw []byte) bool {
+	if d.Compression == "" {
+		return false
+	}
+	if d.CompressFlagMask == 0 {
+		return true
+	}
+	idx := 6 + d.CompressFlagByte
+	return idx < len(raw) && raw[idx]&d.CompressFlagMask != 0
+}
+
+// decompress 按方言解压报文内容，结果超过 maxDecompressedSize 时返回 ErrDecompressedTooLarge
+func (d *Dialect) decompress(body []byte) ([]byte, error) {
+	limit := maxDecompressedSize.Load()
+	var r io.ReadCloser
+	var err error
+	switch d.Compression {
+	case CompressionGzip:
+		r, err = gzip.NewReader(bytes.NewReader(body))
+	case CompressionZlib:
+		r, err = zlib.NewReader(bytes.NewReader(body))
+	case CompressionLZ4:
+		return lz4DecompressBlock(body, int(limit))
+	default:
+		return nil, fmt.Errorf("不支持的压缩算法 %q", d.Compression)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s 解压失败: %w", d.Compression, err)
+	}
+	defer r.Close()
+	// 多读 1 字节用于判断是否超限
+	out, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("%s 解压失败: %w", d.Compression, err)
+	}
+	if int64(len(out)) > limit {
+		return nil, fmt.Errorf("%w（%d 字节）", ErrDecompressedTooLarge, limit)
+	}
+	return out, nil
+}
+
+// lz4DecompressBlock 解压 LZ4 块格式：每个序列为 token（高 4 位字面量长度、低 4 位匹配长度-4）、
+// 扩展长度、字面量、2 字节小端偏移和扩展匹配长度，最后一个序列只有字面量
+func lz4DecompressBlock(src []byte, limit int) ([]byte, error) {
+	errCorrupt := errors.New("lz4 数据损坏")
+	out := make([]byte, 0, min(len(src)*4, limit))
+	// readLen 读取 15 之后的扩展长度字节
+	readLen := func(i int, n int) (int, int, error) {
+		for {
+			if i >= len(src) {
+				return 0, 0, errCorrupt
+			}
+			b := src[i]
+			i++
+			n += int(b)
+			if n > limit {
+				return 0, 0, fmt.Errorf("%w（%d 字节）", ErrDecompressedTooLarge, limit)
+			}
+			if b != 0xFF {
+				return i, n, nil
+			}
+		}
+	}
+	var err error
+	for i := 0; i < len(src); {
+		token := src[i]
+		i++
+		// 1. 字面量
+		litLen := int(token >> 4)
+		if litLen == 15 {
+			if i, litLen, err = readLen(i, litLen); err != nil {
+				return nil, err
+			}
+		}
+		if i+litLen > len(src) {
+			return nil, errCorrupt
+		}
+		if len(out)+litLen > limit {
+			return nil, fmt.Errorf("%w（%d 字节）", ErrDecompressedTooLarge, limit)
+		}
+		out = append(out, src[i:i+litLen]...)
+		i += litLen
+		if i == len(src) {
+			// 最后一个序列没有匹配部分
+			return out, nil
+		}
+
+		// 2. 匹配：从已输出数据中偏移 offset 处复制，允许重叠
+		if i+2 > len(src) {
+			return nil, errCorrupt
+		}
+		offset := int(src[i]) | int(src[i+1])<<8
+		i += 2
+		if offset == 0 || offset > len(out) {
+			return nil, errCorrupt
+		}
+		matchLen := int(token & 0x0F)
+		if matchLen == 15 {
+			if i, matchLen, err = readLen(i, matchLen); err != nil {
+				return nil, err
+			}
+		}
+		matchLen += 4
+		if len(out)+matchLen > limit {
+			return nil, fmt.Errorf("%w（%d 字节）", ErrDecompressedTooLarge, limit)
+		}
+		start := len(out) - offset
+		for k := 0; k < matchLen; k++ {
+			out = append(out, out[start+k])
+		}
+	}
+	return out, nil
+}
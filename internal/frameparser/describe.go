@@ -0,0 +1,123 @@
+package frameparser
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+)
+
+// FrameSummary 一帧的解码结果摘要，供 lpmp-tail 等现场诊断工具显示；
+// DescribeFrame 只解码，不写值表、不唤醒等待方，也不影响一致性/丢弃统计
+type FrameSummary struct {
+	SensorID   string
+	Bytes      int
+	CRCOK      bool
+	PacketType uint8
+	// DataLen 参量个数，报文头 DataLen=0b1111 时为扩展计数
+	DataLen    int
+	FragInd    uint8
+	Compressed bool
+	// Control 控制/控制响应报文的子层，其它报文为 nil
+	Control *ControlResponse
+	Params  []ParamSummary
+	// Err 帧级错误（长度、CRC、方言头、解压、参量结构），参量解析错误见 ParamSummary.Err
+	Err error
+}
+
+// ParamSummary 单个参量的解码结果
+type ParamSummary struct {
+	Type  uint16
+	Name  string
+	Unit  string
+	Value any
+	Raw   []byte
+	Err   error
+}
+
+// PacketTypeName 报文类型的简短名称
+func PacketTypeName(t uint8) string {
+	switch t {
+	case PacketTypeMonitoring:
+		return "monitor"
+	case packetTypeMonitoringResp:
+		return "monitor-resp"
+	case PacketTypeAlarm:
+		return "alarm"
+	case packetTypeControl:
+		return "control"
+	case packetTypeControlResp:
+		return "control-resp"
+	}
+	return fmt.Sprintf("type%d", t)
+}
+
+// DescribeFrame 按方言解码一帧完整报文，dialect 为 nil 时按 SensorID 已登记的方言
+func DescribeFrame(frame []byte, dialect *Dialect) FrameSummary {
+	s := FrameSummary{Bytes: len(frame)}
+	if len(frame) < 9 {
+		s.Err = fmt.Errorf("%w: 帧长 %d 字节，至少需要 9 字节", ErrTruncatedFrame, len(frame))
+		return s
+	}
+	s.SensorID = strings.ToUpper(hex.EncodeToString(frame[:6]))
+	if dialect == nil {
+		dialect = dialectFor(s.SensorID, nil)
+	}
+	if _, s.CRCOK = dialect.checkCRC(frame); !s.CRCOK {
+		s.Err = ErrCRCMismatch
+		return s
+	}
+	s.Compressed = dialect.isCompressed(frame)
+	std, err := dialect.normalize(frame)
+	if err != nil {
+		s.Err = fmt.Errorf("%w: %w", ErrDialectFormat, err)
+		return s
+	}
+
+	// 1. 报文头
+	head := std[6]
+	s.DataLen = int(head >> 4)
+	s.FragInd = (head >> 3) & 0x1
+	s.PacketType = head & 0x07
+	payload := std[7 : len(std)-2]
+	if s.FragInd == 1 || isHeartbeat(s.PacketType, s.DataLen) {
+		return s
+	}
+
+	// 2. 控制报文只解出子层
+	if s.PacketType == packetTypeControl || s.PacketType == packetTypeControlResp {
+		resp, err := parseControlResponse(FrameCtl{SensorID: s.SensorID, DataLen: s.DataLen, Payload: payload})
+		if err != nil {
+			s.Err = err
+			return s
+		}
+		s.Control = &resp
+		return s
+	}
+
+	// 3. 业务报文逐个参量按参数表解析
+	if s.DataLen, payload, err = ExpandDataLen(s.DataLen, payload); err != nil {
+		s.Err = err
+		return s
+	}
+	if s.Compressed {
+		if payload, err = dialect.decompress(payload); err != nil {
+			s.Err = fmt.Errorf("%w: %w", ErrDecompressFailed, err)
+			return s
+		}
+	}
+	params, err := dialect.decodeParams(payload, s.DataLen)
+	s.Err = err
+	for _, p := range params {
+		ps := ParamSummary{Type: p.Type, Raw: p.Data}
+		if info, ok := config.LookupSensorParamInfo(s.SensorID, p.Type); ok {
+			ps.Name, ps.Unit = info.Name, info.Unit
+			ps.Value, ps.Err = info.Parse(dialect.valueBytes(p.Data))
+		} else {
+			ps.Err = ErrUnknownParam
+		}
+		s.Params = append(s.Params, ps)
+	}
+	return s
+}
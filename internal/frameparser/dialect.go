@@ -0,0 +1,340 @@
+package frameparser
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/paramcodec"
+)
+
+// Dialect 描述某一厂家对标准报文格式的偏差，解析器按 SensorID 选用
+type Dialect struct {
+	Name string
+	// CRC 校验算法，标准为 CRC16（Modbus）
+	CRC func([]byte) uint16
+	// CRC32 非 nil 时帧尾校验为 4 字节 CRC32，取代 CRC
+	CRC32 func([]byte) uint32
+	// CRCByteOrder CRC 字段的字节序，标准为大端
+	CRCByteOrder binary.ByteOrder
+	// TrailerBytes CRC 之后的厂家附加字节数（如帧类型），不计入 CRC，解析前剔除；
+	// 构造下行帧时填 TrailerFill
+	TrailerBytes int
+	TrailerFill  byte
+	// SwapValueBytes 为 true 时参量数据的字节序与参数表相反，解析前先翻转
+	SwapValueBytes bool
+	// HeaderBytes SensorID 与报文头之间的厂家私有字节数，解析前剔除（计入 CRC）
+	HeaderBytes int
+	// Compression 报文内容（参量数据）的压缩算法：gzip / zlib / lz4，为空表示不压缩
+	Compression string
+	// CompressFlagByte / CompressFlagMask 压缩标志在厂家私有头中的字节下标和位掩码，
+	// 该位置位时报文内容为压缩数据；掩码为 0 表示配置了 Compression 的帧都已压缩
+	CompressFlagByte int
+	CompressFlagMask byte
+	// ParamLengths 各 LengthFlag 的数据长度表示方式，零值为规范（paramcodec.Spec）
+	ParamLengths paramcodec.LengthTable
+}
+
+// StandardDialect 为 Q/GDW 12184 标准格式
+var StandardDialect = &Dialect{Name: "standard", CRC: CRC16, CRCByteOrder: binary.BigEndian}
+
+// crcAlgorithms 方言中可选的 CRC 算法
+var crcAlgorithms = map[string]func([]byte) uint16{
+	"modbus": CRC16,
+	"ccitt":  CRC16CCITT,
+}
+
+// crc32Algorithms 方言中可选的 CRC32 算法（帧尾 4 字节）
+var crc32Algorithms = map[string]func([]byte) uint32{
+	"crc32":  crc32.ChecksumIEEE,
+	"crc32c": func(b []byte) uint32 { return crc32.Checksum(b, crc32.MakeTable(crc32.Castagnoli)) },
+}
+
+var (
+	dialectsMu sync.RWMutex
+	// dialects 已登记的命名方言
+	dialects = map[string]*Dialect{
+		StandardDialect.Name: StandardDialect,
+	}
+	// sensorDialects SensorID → 方言，未登记的传感器使用 defaultDialect
+	sensorDialects = make(map[string]*Dialect)
+	// defaultDialect 未单独指定方言的传感器使用的方言，默认标准格式
+	defaultDialect = StandardDialect
+)
+
+// RegisterDialect 登记一个命名方言，设备协议属性中可直接引用其名称
+func RegisterDialect(d *Dialect) {
+	dialectsMu.Lock()
+	defer dialectsMu.Unlock()
+	dialects[d.Name] = d
+}
+
+// ParseDialect 解析方言描述：已登记的名称，或以标准格式为基础的 key=value 列表（分号分隔），
+// 例如 "crc=ccitt;crcOrder=little;values=swapped;header=1;compress=zlib;compressFlag=0:0x80"，
+// 帧尾为 CRC32 加 1 字节帧类型时为 "crc=crc32;trailer=1;trailerFill=0x01"，
+// LengthFlag 1/2/3 表示固定 1/2/3 字节数据（无长度字段）时为 "lenTable=4,1,2,3"
+func ParseDialect(spec string) (*Dialect, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return StandardDialect, nil
+	}
+	dialectsMu.RLock()
+	named, ok := dialects[spec]
+	dialectsMu.RUnlock()
+	if ok {
+		return named, nil
+	}
+	if !strings.Contains(spec, "=") {
+		return nil, fmt.Errorf("未知方言 %q", spec)
+	}
+
+	d := *StandardDialect
+	d.Name = spec
+	for _, item := range strings.Split(spec, ";") {
+		k, v, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok {
+			return nil, fmt.Errorf("方言项 %q 格式错误，应为 key=value", item)
+		}
+		k, v = strings.TrimSpace(k), strings.ToLower(strings.TrimSpace(v))
+		switch k {
+		case "crc":
+			if crc, ok := crc32Algorithms[v]; ok {
+				d.CRC32 = crc
+				break
+			}
+			crc, ok := crcAlgorithms[v]
+			if !ok {
+				return nil, fmt.Errorf("不支持的 CRC 算法 %q", v)
+			}
+			d.CRC, d.CRC32 = crc, nil
+		case "crcOrder":
+			switch v {
+			case "big":
+				d.CRCByteOrder = binary.BigEndian
+			case "little":
+				d.CRCByteOrder = binary.LittleEndian
+			default:
+				return nil, fmt.Errorf("crcOrder 只能为 big 或 little，得到 %q", v)
+			}
+		case "values":
+			switch v {
+			case "table":
+				d.SwapValueBytes = false
+			case "swapped":
+				d.SwapValueBytes = true
+			default:
+				return nil, fmt.Errorf("values 只能为 table 或 swapped，得到 %q", v)
+			}
+		case "header":
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 || n > 8 {
+				return nil, fmt.Errorf("header 须为 0~8 的整数，得到 %q", v)
+			}
+			d.HeaderBytes = n
+		case "trailer":
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 || n > 4 {
+				return nil, fmt.Errorf("trailer 须为 0~4 的整数，得到 %q", v)
+			}
+			d.TrailerBytes = n
+		case "trailerFill":
+			b, err := strconv.ParseUint(v, 0, 8)
+			if err != nil {
+				return nil, fmt.Errorf("trailerFill 须为 0~255，得到 %q", v)
+			}
+			d.TrailerFill = byte(b)
+		case "lenTable":
+			t, err := paramcodec.ParseLengthTable(v)
+			if err != nil {
+				return nil, err
+			}
+			d.ParamLengths = t
+		case "compress":
+			if !validCompression(v) {
+				return nil, fmt.Errorf("compress 只能为 gzip、zlib 或 lz4，得到 %q", v)
+			}
+			d.Compression = v
+		case "compressFlag":
+			// 格式 <厂家头字节下标>:<位掩码>，如 0:0x80
+			idx, mask, ok := strings.Cut(v, ":")
+			n, err1 := strconv.Atoi(idx)
+			m, err2 := strconv.ParseUint(mask, 0, 8)
+			if !ok || err1 != nil || err2 != nil || n < 0 || m == 0 {
+				return nil, fmt.Errorf("compressFlag 须为 <字节下标>:<非零位掩码>，得到 %q", v)
+			}
+			d.CompressFlagByte, d.CompressFlagMask = n, byte(m)
+		default:
+			return nil, fmt.Errorf("未知方言项 %q", k)
+		}
+	}
+	if d.CompressFlagMask != 0 {
+		if d.Compression == "" {
+			return nil, fmt.Errorf("compressFlag 需要同时配置 compress")
+		}
+		if d.CompressFlagByte >= d.HeaderBytes {
+			return nil, fmt.Errorf("compressFlag 字节下标 %d 超出厂家头长度 %d", d.CompressFlagByte, d.HeaderBytes)
+		}
+	}
+	return &d, nil
+}
+
+// SetSensorDialect 指定传感器使用的方言，传 nil 时恢复默认方言（见 SetDefaultDialect）
+func SetSensorDialect(sensorID string, d *Dialect) {
+	dialectsMu.Lock()
+	defer dialectsMu.Unlock()
+	if d == nil {
+		delete(sensorDialects, sensorID)
+		return
+	}
+	sensorDialects[sensorID] = d
+}
+
+// SetDefaultDialect 设置未单独指定方言的传感器使用的方言，如整个项目的传感器都使用 CRC-CCITT；
+// 传 nil 恢复标准格式
+func SetDefaultDialect(d *Dialect) {
+	if d == nil {
+		d = StandardDialect
+	}
+	dialectsMu.Lock()
+	defer dialectsMu.Unlock()
+	defaultDialect = d
+}
+
+// DefaultDialect 返回未单独指定方言的传感器使用的方言
+func DefaultDialect() *Dialect {
+	dialectsMu.RLock()
+	defer dialectsMu.RUnlock()
+	return defaultDialect
+}
+
+// dialectFor 返回传感器使用的方言：单独指定的优先，其次 def，def 为 nil 时使用 SetDefaultDialect 设置的方言
+func dialectFor(sensorID string, def *Dialect) *Dialect {
+	dialectsMu.RLock()
+	defer dialectsMu.RUnlock()
+	if d, ok := sensorDialects[sensorID]; ok {
+		return d
+	}
+	if def != nil {
+		return def
+	}
+	return defaultDialect
+}
+
+// crcWidth 帧尾 CRC 字段的字节数
+func (d *Dialect) crcWidth() int {
+	if d.CRC32 != nil {
+		return 4
+	}
+	return 2
+}
+
+// trailerLen 帧尾（CRC 与附加字节）的总字节数
+func (d *Dialect) trailerLen() int {
+	return d.crcWidth() + d.TrailerBytes
+}
+
+// standardTrailer 帧尾与标准格式相同（2 字节大端 CRC16，无附加字节），标准化时可保留原帧尾
+func (d *Dialect) standardTrailer() bool {
+	return d.CRC32 == nil && d.TrailerBytes == 0 && d.CRCByteOrder == binary.BigEndian
+}
+
+// checkCRC 按方言校验帧尾 CRC：CRC 覆盖帧尾之前的全部字节，附加字节不参与校验
+func (d *Dialect) checkCRC(frame []byte) (recv uint32, ok bool) {
+	n := len(frame) - d.trailerLen()
+	if n < 7 {
+		return 0, false
+	}
+	field := frame[n : n+d.crcWidth()]
+	if d.CRC32 != nil {
+		recv = d.CRCByteOrder.Uint32(field)
+		return recv, d.CRC32(frame[:n]) == recv
+	}
+	recv = uint32(d.CRCByteOrder.Uint16(field))
+	return recv, uint32(d.CRC(frame[:n])) == recv
+}
+
+// Seal 按方言为不含帧尾的报文追加 CRC 和附加字节
+func (d *Dialect) Seal(buf []byte) []byte {
+	var field [4]byte
+	if d.CRC32 != nil {
+		d.CRCByteOrder.PutUint32(field[:], d.CRC32(buf))
+	} else {
+		d.CRCByteOrder.PutUint16(field[:], d.CRC(buf))
+	}
+	buf = append(buf, field[:d.crcWidth()]...)
+	for i := 0; i < d.TrailerBytes; i++ {
+		buf = append(buf, d.TrailerFill)
+	}
+	return buf
+}
+
+// Reframe 把构造好的标准帧改写为该方言的帧尾；厂家私有头无法推断，不做补充
+func (d *Dialect) Reframe(std []byte) ([]byte, error) {
+	if len(std) < 9 {
+		return nil, fmt.Errorf("%w: 帧长 %d 字节，至少需要 9 字节", ErrTruncatedFrame, len(std))
+	}
+	if d.standardTrailer() {
+		return std, nil
+	}
+	body := make([]byte, len(std)-2, len(std)-2+d.trailerLen())
+	copy(body, std)
+	return d.Seal(body), nil
+}
+
+// normalize 剔除厂家私有头字节和非标准帧尾，得到标准格式的帧（SensorID + 报文头 + 报文内容 + 2 字节大端 CRC16）；
+// 帧尾与标准相同时原样保留 CRC 字段，否则按标准重新计算
+func (d *Dialect) normalize(frame []byte) ([]byte, error) {
+	if d.HeaderBytes == 0 && d.standardTrailer() {
+		return frame, nil
+	}
+	if len(frame) < 7+d.HeaderBytes+d.trailerLen() {
+		return nil, fmt.Errorf("%w: 不足以容纳 %d 字节厂家头和 %d 字节帧尾", ErrTruncatedFrame, d.HeaderBytes, d.trailerLen())
+	}
+	content := frame[6+d.HeaderBytes : len(frame)-d.trailerLen()]
+	out := make([]byte, 0, 6+len(content)+2)
+	out = append(out, frame[:6]...)
+	out = append(out, content...)
+	if d.standardTrailer() {
+		return append(out, frame[len(frame)-2:]...), nil
+	}
+	return StandardDialect.Seal(out), nil
+}
+
+// ReframeForSensor 按目标传感器登记的方言改写下行帧的帧尾，未登记方言的传感器按 def
+// （nil 表示 SetDefaultDialect 设置的方言）改写
+func ReframeForSensor(frame []byte, def *Dialect) ([]byte, error) {
+	if len(frame) < 6 {
+		return frame, nil
+	}
+	return dialectFor(strings.ToUpper(hex.EncodeToString(frame[:6])), def).Reframe(frame)
+}
+
+// paramLengths 返回生效的 LengthFlag 长度表
+func (d *Dialect) paramLengths() paramcodec.LengthTable {
+	if d.ParamLengths[0] == 0 {
+		return paramcodec.Spec
+	}
+	return d.ParamLengths
+}
+
+// decodeParams 按方言的长度表依次解出 count 个参量
+func (d *Dialect) decodeParams(data []byte, count int) ([]Param, error) {
+	params, _, err := d.paramLengths().Decode(data, count)
+	return params, err
+}
+
+// valueBytes 返回按参数表字节序排列的参量数据
+func (d *Dialect) valueBytes(data []byte) []byte {
+	if !d.SwapValueBytes {
+		return data
+	}
+	out := make([]byte, len(data))
+	for i, b := range data {
+		out[len(data)-1-i] = b
+	}
+	return out
+}
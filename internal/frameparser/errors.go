@@ -0,0 +1,101 @@
+package frameparser
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+	"github.com/linjuya-lu/device-lpmp-go/internal/paramcodec"
+)
+
+// 解析和构造报文的错误类别，调用方用 errors.Is 判断；参量结构错误见 params.go，
+// 解压超限见 ErrDecompressedTooLarge，控制执行失败见 ErrControlFailed
+var (
+	// ErrTruncatedFrame 帧长度不足以容纳 SensorID、报文头和帧尾
+	ErrTruncatedFrame = errors.New("帧长度不足")
+	// ErrCRCMismatch 帧尾 CRC 与报文内容不符
+	ErrCRCMismatch = errors.New("CRC 校验失败")
+	// ErrDialectFormat 帧不符合传感器方言声明的厂家头/帧尾格式
+	ErrDialectFormat = errors.New("方言格式错误")
+	// ErrUnknownSensor SensorID 未绑定到任何设备
+	ErrUnknownSensor = errors.New("未知 SensorID")
+	// ErrInvalidSensorID SensorID 不是 12 位十六进制
+	ErrInvalidSensorID = errors.New("SensorID 无效")
+	// ErrDecompressFailed 压缩报文内容无法解压
+	ErrDecompressFailed = errors.New("解压失败")
+	// ErrUnknownParam 参量类型不在参数表中
+	ErrUnknownParam = errors.New("未知参数类型")
+	// ErrParamParse 参量数据无法按参数表解析
+	ErrParamParse = errors.New("参数解析失败")
+	// ErrParamOutOfRange 参量值超出 param_limits 配置的范围（reject）
+	ErrParamOutOfRange = errors.New("参数超出范围")
+	// ErrTLVFormat 参量数据不符合 param_tlv 配置的 TLV 子表
+	ErrTLVFormat = config.ErrTLVFormat
+	// ErrInvalidParamType 构造报文时参量类型超出 14bit
+	ErrInvalidParamType = paramcodec.ErrInvalidType
+	// ErrParamCount 构造报文时参量个数超出允许范围
+	ErrParamCount = errors.New("参量个数超出范围")
+)
+
+// errorKinds 错误类别到指标标签的映射，按顺序匹配
+var errorKinds = []struct {
+	err  error
+	kind string
+}{
+	{ErrTruncatedFrame, "truncated-frame"},
+	{ErrCRCMismatch, "crc-mismatch"},
+	{ErrDialectFormat, "dialect-format"},
+	{ErrUnknownSensor, "unknown-sensor"},
+	{ErrInvalidSensorID, "invalid-sensor-id"},
+	{ErrDecompressedTooLarge, "decompressed-too-large"},
+	{ErrDecompressFailed, "decompress-failed"},
+	{ErrExtendedCountMissing, "extended-count-missing"},
+	{ErrFragmentHeader, "fragment-header"},
+	{ErrParamHeadOverflow, "param-head-overflow"},
+	{ErrParamDataOverflow, "param-data-overflow"},
+	{ErrUnknownParam, "unknown-param"},
+	{ErrParamParse, "param-parse"},
+	{ErrParamOutOfRange, "param-out-of-range"},
+	{ErrTLVFormat, "tlv-format"},
+	{ErrInvalidParamType, "invalid-param-type"},
+	{paramcodec.ErrLength, "param-length"},
+	{ErrParamCount, "param-count"},
+	{ErrControlFailed, "control-failed"},
+}
+
+// ErrorKind 返回错误的类别标签（如 "crc-mismatch"），供指标分桶；nil 返回 ""，
+// 不属于上述类别的返回 "other"
+func ErrorKind(err error) string {
+	if err == nil {
+		return ""
+	}
+	for _, k := range errorKinds {
+		if errors.Is(err, k.err) {
+			return k.kind
+		}
+	}
+	return "other"
+}
+
+var (
+	errorCountsMu sync.Mutex
+	errorCounts   = make(map[string]uint64)
+)
+
+// countError 按类别累计解析时丢弃帧或参量的次数
+func countError(err error) {
+	errorCountsMu.Lock()
+	errorCounts[ErrorKind(err)]++
+	errorCountsMu.Unlock()
+}
+
+// ErrorCounts 返回启动以来各类解析错误的次数，键为 ErrorKind
+func ErrorCounts() map[string]uint64 {
+	errorCountsMu.Lock()
+	defer errorCountsMu.Unlock()
+	out := make(map[string]uint64, len(errorCounts))
+	for k, v := range errorCounts {
+		out[k] = v
+	}
+	return out
+}
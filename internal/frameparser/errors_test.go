@@ -0,0 +1,43 @@
+package frameparser
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/trace"
+)
+
+func TestErrorKind(t *testing.T) {
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{nil, ""},
+		{ErrCRCMismatch, "crc-mismatch"},
+		{fmt.Errorf("SensorID=%s: %w", "238A0821BEF2", ErrUnknownSensor), "unknown-sensor"},
+		{fmt.Errorf("%w: FragInd=0", ErrFragmentHeader), "fragment-header"},
+		{fmt.Errorf("其它错误"), "other"},
+	}
+	for _, c := range cases {
+		if got := ErrorKind(c.err); got != c.want {
+			t.Errorf("ErrorKind(%v)=%q，期望 %q", c.err, got, c.want)
+		}
+	}
+}
+
+// TestErrorCounts 解析入口丢弃的帧按错误类别计数
+func TestErrorCounts(t *testing.T) {
+	p := NewPipeline(PipelineOptions{Name: "test"})
+	before := ErrorCounts()
+	bad := append([]byte(nil), fragFirst...)
+	bad[len(bad)-1] ^= 0xFF
+	p.handleFrame(trace.ID("errors-test"), bad, time.Time{})
+	p.handleFrame(trace.ID("errors-test"), sealed("000000000001"+"10"+"01000000"), time.Time{})
+	after := ErrorCounts()
+	for _, kind := range []string{"crc-mismatch", "unknown-sensor"} {
+		if after[kind]-before[kind] != 1 {
+			t.Errorf("%s 计数增加 %d，期望 1", kind, after[kind]-before[kind])
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package frameparser
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/trace"
+)
+
+// FailedFrame 未能通过 CRC 或结构校验的帧，供离线协议分析工具收集
+type FailedFrame struct {
+	Time     time.Time `json:"time"`
+	TraceID  trace.ID  `json:"traceId"`
+	SensorID string    `json:"sensorId,omitempty"`
+	// Reason 失败类别（与节流日志的类别一致，一致性违规为 "conformance:<规则>"）
+	Reason string `json:"reason"`
+	Detail string `json:"detail"`
+	// Frame 收到的原始帧（含厂家私有头和 CRC），十六进制
+	Frame string `json:"frame"`
+}
+
+var (
+	// failedForwarding 为 true 时把失败帧发给订阅者，默认只记日志
+	failedForwarding atomic.Bool
+
+	failedSubsMu sync.RWMutex
+	failedSubs   []chan FailedFrame
+)
+
+// SetFailedFrameForwarding 开关失败帧转发（诊断模式）
+func SetFailedFrameForwarding(on bool) {
+	failedForwarding.Store(on)
+}
+
+// SubscribeFailedFrames 订阅失败帧，buf 为通道缓冲；订阅者处理不及时时丢弃，不阻塞解析
+func SubscribeFailedFrames(buf int) <-chan FailedFrame {
+	ch := make(chan FailedFrame, buf)
+	failedSubsMu.Lock()
+	failedSubs = append(failedSubs, ch)
+	failedSubsMu.Unlock()
+	return ch
+}
+
+// forwardFailed 诊断模式下把失败帧非阻塞地发给所有订阅者
+func forwardFailed(id trace.ID, sensorID, reason string, raw []byte, format string, args ...any) {
+	if !failedForwarding.Load() {
+		return
+	}
+	ff := FailedFrame{
+		Time:     time.Now(),
+		TraceID:  id,
+		SensorID: sensorID,
+		Reason:   reason,
+		Detail:   fmt.Sprintf(format, args...),
+		Frame:    hex.EncodeToString(raw),
+	}
+	failedSubsMu.RLock()
+	defer failedSubsMu.RUnlock()
+	for _, ch := range failedSubs {
+		select {
+		case ch <- ff:
+		default:
+		}
+	}
+}
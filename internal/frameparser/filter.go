@@ -0,0 +1,86 @@
+package frameparser
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// sensorFilter 按 SensorID 过滤上行帧，在 CRC 校验和解析之前执行：
+// 共用信道上其它项目的传感器流量直接丢弃，不进入日志和统计
+type sensorFilter struct {
+	allowExact, denyExact       map[string]bool
+	allowPrefixes, denyPrefixes []string
+}
+
+var (
+	filterMu sync.RWMutex
+	filter   *sensorFilter
+
+	// 被过滤的帧数：命中拒绝列表 / 不在允许列表中
+	filteredDenied     atomic.Uint64
+	filteredNotAllowed atomic.Uint64
+)
+
+// SetSensorFilter 设置允许/拒绝列表，项以 * 结尾时按前缀匹配（如 "238A08*"），
+// 大小写不敏感；两个列表都为空时不过滤。允许列表非空时只接收其中的传感器，拒绝列表优先。
+func SetSensorFilter(allow, deny []string) {
+	f := &sensorFilter{allowExact: make(map[string]bool), denyExact: make(map[string]bool)}
+	add := func(items []string, exact map[string]bool, prefixes *[]string) {
+		for _, item := range items {
+			item = strings.ToUpper(strings.TrimSpace(item))
+			if p, ok := strings.CutSuffix(item, "*"); ok {
+				*prefixes = append(*prefixes, p)
+			} else if item != "" {
+				exact[item] = true
+			}
+		}
+	}
+	add(allow, f.allowExact, &f.allowPrefixes)
+	add(deny, f.denyExact, &f.denyPrefixes)
+
+	filterMu.Lock()
+	defer filterMu.Unlock()
+	if len(f.allowExact)+len(f.allowPrefixes)+len(f.denyExact)+len(f.denyPrefixes) == 0 {
+		filter = nil
+		return
+	}
+	filter = f
+}
+
+// FilteredCounts 返回启动以来被拒绝列表和允许列表过滤掉的帧数
+func FilteredCounts() (denied, notAllowed uint64) {
+	return filteredDenied.Load(), filteredNotAllowed.Load()
+}
+
+// matches 判断 SensorID 是否命中精确项或前缀项
+func matches(sensorID string, exact map[string]bool, prefixes []string) bool {
+	if exact[sensorID] {
+		return true
+	}
+	for _, p := range prefixes {
+		if strings.HasPrefix(sensorID, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptSensor 按允许/拒绝列表判断是否处理该传感器的帧，被过滤时计数
+func acceptSensor(sensorID string) bool {
+	filterMu.RLock()
+	f := filter
+	filterMu.RUnlock()
+	if f == nil {
+		return true
+	}
+	if matches(sensorID, f.denyExact, f.denyPrefixes) {
+		filteredDenied.Add(1)
+		return false
+	}
+	if len(f.allowExact)+len(f.allowPrefixes) > 0 && !matches(sensorID, f.allowExact, f.allowPrefixes) {
+		filteredNotAllowed.Add(1)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,78 @@
+package frameparser
+
+// 分片帧（FragInd=1）格式，依照《Q/GDW 12184—2021》附录 D 业务报文分片：
+//
+//	SensorID(6B) + head(DataLen|FragInd=1|PacketType) + 分片头(2B) + 片段数据 + CRC16
+//	分片头 byte0 = SSEQ(6bit)<<2 | Flag(2bit)，byte1 = 保留(1bit) | PSEQ(7bit)
+//	Flag：00 首片，10 中间片，11 尾片
+//
+// 各片段按 PSEQ 顺序拼接得到的 SDU 即未分片报文中报文头之后的参量列表；
+// 参量个数和报文类型以首片的报文头为准，中间片和尾片的 DataLen 为 0。
+// 仓库中暂无现场抓包，字段位宽与 Frame 的 SSEQ/PSEQ/Flag 一致，测试向量按上述格式手工构造。
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// fragIndBit 报文头中的分片指示位
+	fragIndBit byte = 1 << 3
+	// fragHeaderLen 分片头长度
+	fragHeaderLen = 2
+)
+
+// ErrFragmentHeader 分片帧长度不足以容纳分片头，或报文头未置 FragInd
+var ErrFragmentHeader = errors.New("分片头不完整")
+
+// ParseFragment 从 CRC 已校验的分片帧中取出分片头和片段数据；片段数据引用 frame 的底层数组
+func ParseFragment(frame []byte) (*Frame, error) {
+	if len(frame) < 6+1+fragHeaderLen+2 {
+		return nil, fmt.Errorf("%w: 帧长 %d 字节", ErrFragmentHeader, len(frame))
+	}
+	if frame[6]&fragIndBit == 0 {
+		return nil, fmt.Errorf("%w: FragInd=0", ErrFragmentHeader)
+	}
+	f := &Frame{
+		FragInd: 1,
+		SSEQ:    frame[7] >> 2,
+		Flag:    frame[7] & 0x03,
+		PSEQ:    frame[8] & 0x7F,
+		Head:    frame[6],
+		Data:    frame[7+fragHeaderLen : len(frame)-2],
+	}
+	copy(f.SensorID[:], frame[:6])
+	return f, nil
+}
+
+// BuildFragmentFrames 构造一条上行业务数据报文的分片帧：参量按 BuildBusinessFrame 相同的方式编码后
+// 切成最多 n 片（至少 2 片），PSEQ 从 0 开始，首片 Flag=00、中间片 10、尾片 11，sseq 取低 6 位；
+// 首片沿用未分片时的报文头，中间片和尾片 DataLen=0
+func BuildFragmentFrames(sensorID [6]byte, packetType byte, sseq uint8, params []ParamValue, n int) ([][]byte, error) {
+	head, content, err := businessContent(sensorID, packetType, params)
+	if err != nil {
+		return nil, err
+	}
+	n = min(n, len(content), 0x80)
+	if n < 2 {
+		return nil, fmt.Errorf("分片数须至少为 2（报文内容 %d 字节）", len(content))
+	}
+	size := (len(content) + n - 1) / n
+	frames := make([][]byte, 0, n)
+	for pseq := 0; pseq*size < len(content); pseq++ {
+		end := min((pseq+1)*size, len(content))
+		h, flag := packetType&0x07|fragIndBit, byte(0b10)
+		switch {
+		case pseq == 0:
+			h, flag = head|fragIndBit, 0b00
+		case end == len(content):
+			flag = 0b11
+		}
+		buf := make([]byte, 0, 6+1+fragHeaderLen+end-pseq*size+2)
+		buf = append(buf, sensorID[:]...)
+		buf = append(buf, h, (sseq&0x3F)<<2|flag, byte(pseq))
+		buf = append(buf, content[pseq*size:end]...)
+		frames = append(frames, StandardDialect.Seal(buf))
+	}
+	return frames, nil
+}
@@ -0,0 +1,258 @@
+package frameparser
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+	"github.com/linjuya-lu/device-lpmp-go/internal/trace"
+)
+
+// 传感器 238A0821BEF2 的一条监测数据（长度=2.5m、温度=21.5℃，共 12 字节参量）分 3 片上送，SSEQ=5。
+// 仓库中暂无现场抓包，以下帧按 fragment.go 中的分片头格式逐字节构造，CRC16 为完整帧的真实校验值。
+var (
+	// head=0x28(DataLen=2 FragInd=1 监测) 分片头 14 00(SSEQ=5 首片 PSEQ=0) 04 00 00 00
+	fragFirst = mustHex("238A0821BEF2" + "28" + "1400" + "04000000" + "FD44")
+	// head=0x08(DataLen=0 FragInd=1) 分片头 16 01(中间片 PSEQ=1) 20 40 14 00
+	fragMiddle = mustHex("238A0821BEF2" + "08" + "1601" + "20401400" + "395D")
+	// head=0x08 分片头 17 02(尾片 PSEQ=2) 00 00 AC 41
+	fragLast = mustHex("238A0821BEF2" + "08" + "1702" + "0000AC41" + "CCA0")
+)
+
+const fragDevice = "Friendcom-Water-Level-Sensor"
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// feed 不启动解析协程时同步解析一帧：拼接完成的 SDU 从本流水线拼接器的输出通道直接取出解析
+func feed(p *Pipeline, id trace.ID, frame []byte, received time.Time) {
+	p.handleFrame(id, frame, received)
+	for {
+		select {
+		case f := <-p.reasm.Output():
+			p.handleSDU(f)
+		default:
+			return
+		}
+	}
+}
+
+func TestParseFragment(t *testing.T) {
+	cases := []struct {
+		frame            []byte
+		flag, pseq, head uint8
+		data             string
+	}{
+		{fragFirst, 0b00, 0, 0x28, "04000000"},
+		{fragMiddle, 0b10, 1, 0x08, "20401400"},
+		{fragLast, 0b11, 2, 0x08, "0000ac41"},
+	}
+	for _, c := range cases {
+		f, err := ParseFragment(c.frame)
+		if err != nil {
+			t.Fatalf("ParseFragment(% X): %v", c.frame, err)
+		}
+		if hex.EncodeToString(f.SensorID[:]) != "238a0821bef2" || f.FragInd != 1 || f.SSEQ != 5 {
+			t.Errorf("SensorID=%X FragInd=%d SSEQ=%d", f.SensorID, f.FragInd, f.SSEQ)
+		}
+		if f.Flag != c.flag || f.PSEQ != c.pseq || f.Head != c.head {
+			t.Errorf("Flag=%02b PSEQ=%d Head=0x%02X，期望 %02b/%d/0x%02X", f.Flag, f.PSEQ, f.Head, c.flag, c.pseq, c.head)
+		}
+		if got := hex.EncodeToString(f.Data); got != c.data {
+			t.Errorf("Data=%s，期望 %s", got, c.data)
+		}
+	}
+}
+
+func TestParseFragmentErrors(t *testing.T) {
+	for _, frame := range [][]byte{
+		mustHex("238A0821BEF2" + "28" + "14" + "0000"), // 缺 PSEQ
+		mustHex("238A0821BEF2" + "20" + "1400" + "04000000" + "0000"),
+	} {
+		if _, err := ParseFragment(frame); !errors.Is(err, ErrFragmentHeader) {
+			t.Errorf("ParseFragment(% X) err=%v，期望 ErrFragmentHeader", frame, err)
+		}
+	}
+}
+
+// TestHandleFrameReassembles 分片帧经解析入口拼接后按首片的报文头解析参量，乱序到达的中间片同样拼接；
+// DataLen=0 的中间片和尾片不能被当作心跳
+func TestHandleFrameReassembles(t *testing.T) {
+	p := NewPipeline(PipelineOptions{Name: "test"})
+	heartbeats := 0
+	SetHeartbeatHandler(func(_ trace.ID, _ string) { heartbeats++ })
+	defer SetHeartbeatHandler(nil)
+
+	for _, order := range [][][]byte{
+		{fragFirst, fragMiddle, fragLast},
+		{fragFirst, fragLast, fragMiddle},
+	} {
+		config.SetDeviceValue(fragDevice, "长度", nil)
+		config.SetDeviceValue(fragDevice, "温度", nil)
+		for i, frame := range order {
+			feed(p, trace.ID("frag-test"), frame, time.Time{})
+			if vals, _ := config.GetDeviceValues(fragDevice); i < len(order)-1 && vals["长度"] != nil {
+				t.Fatalf("第 %d 片后提前输出了读数 %v", i+1, vals)
+			}
+		}
+		vals, _ := config.GetDeviceValues(fragDevice)
+		if vals["长度"] != float32(2.5) || vals["温度"] != float32(21.5) {
+			t.Errorf("拼接后读数 长度=%v 温度=%v，期望 2.5/21.5", vals["长度"], vals["温度"])
+		}
+	}
+	if heartbeats != 0 {
+		t.Errorf("分片帧触发了 %d 次心跳回调", heartbeats)
+	}
+}
+
+// TestReassembledQuality 拼接过程中收到过重复片段的 SDU，读数质量标为 reassembled-with-retransmit
+func TestReassembledQuality(t *testing.T) {
+	qualities := make(map[string]string)
+	p := NewPipeline(PipelineOptions{Name: "test", Sink: ValueSinkFunc(func(_, resourceName string, _ any, _ time.Time, tags map[string]string) {
+		qualities[resourceName] = tags[config.QualityTag]
+	})})
+	for _, order := range []struct {
+		frames [][]byte
+		want   string
+	}{
+		{[][]byte{fragFirst, fragMiddle, fragLast}, config.QualityGood},
+		{[][]byte{fragFirst, fragMiddle, fragMiddle, fragLast}, config.QualityRetransmit},
+	} {
+		clear(qualities)
+		for _, frame := range order.frames {
+			feed(p, trace.ID("quality-test"), frame, time.Time{})
+		}
+		if qualities["长度"] != order.want || qualities["温度"] != order.want {
+			t.Errorf("读数质量 %v，期望 %s", qualities, order.want)
+		}
+	}
+}
+
+// TestReassembledTLVQuality TLV 参量展开的读数同样带拼接 SDU 的质量标签
+func TestReassembledTLVQuality(t *testing.T) {
+	const vendorParam = 0x0200
+	if err := config.SetParamTLV([]config.TLVTable{{
+		ParameterType: vendorParam,
+		ByteOrder:     "big",
+		Codes:         []config.TLVCode{{Tag: 0x01, Name: "tlv-level", DataType: "uint16"}},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	defer config.SetParamTLV(nil)
+	id, err := ParseSensorID("238A0821BEF2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames, err := BuildFragmentFrames(id, PacketTypeMonitoring, 9, []ParamValue{{Type: vendorParam, Value: []byte{0x01, 2, 0x01, 0x2C}}}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qualities := make(map[string]string)
+	p := NewPipeline(PipelineOptions{Name: "test", Sink: ValueSinkFunc(func(_, resourceName string, _ any, _ time.Time, tags map[string]string) {
+		qualities[resourceName] = tags[config.QualityTag]
+	})})
+	for _, frame := range [][]byte{frames[0], frames[1], frames[1], frames[2]} {
+		feed(p, trace.ID("tlv-quality-test"), frame, time.Time{})
+	}
+	if got := qualities["tlv-level"]; got != config.QualityRetransmit {
+		t.Errorf("TLV 读数质量 %q，期望 %s", got, config.QualityRetransmit)
+	}
+}
+
+// TestCompressedFragments 压缩方言的传感器分片上送时，按首片的压缩标志解压拼接后的 SDU
+func TestCompressedFragments(t *testing.T) {
+	const sensorID = "238A0821BEF3"
+	d, err := ParseDialect("compress=zlib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetSensorDialect(sensorID, d)
+	defer SetSensorDialect(sensorID, nil)
+	config.BindSensors("zlib-dev", map[string]string{sensorID: ""}, nil)
+	defer config.UnbindDevice("zlib-dev")
+
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(mustHex("04000000204014000000AC41"))
+	zw.Close()
+	z := hex.EncodeToString(buf.Bytes())
+	half := len(z) / 4 * 2
+
+	got := make(map[string]any)
+	p := NewPipeline(PipelineOptions{Name: "test", Sink: ValueSinkFunc(func(_, resourceName string, value any, _ time.Time, _ map[string]string) {
+		got[resourceName] = value
+	})})
+	feed(p, trace.ID("zlib-test"), sealed(sensorID+"28"+"1400"+z[:half]), time.Time{})
+	feed(p, trace.ID("zlib-test"), sealed(sensorID+"08"+"1701"+z[half:]), time.Time{})
+	if got["长度"] != float32(2.5) || got["温度"] != float32(21.5) {
+		t.Errorf("解压后读数 %v，期望 长度=2.5 温度=21.5", got)
+	}
+}
+
+// TestLateFirstFragment 起点序号按规范固定为 0 时，先到的中间片和尾片暂存，迟到的首片到达后拼接完成
+func TestLateFirstFragment(t *testing.T) {
+	got := make(map[string]any)
+	p := NewPipeline(PipelineOptions{Name: "test", Sink: ValueSinkFunc(func(_, resourceName string, value any, _ time.Time, _ map[string]string) {
+		got[resourceName] = value
+	})})
+	for _, frame := range [][]byte{fragLast, fragMiddle} {
+		feed(p, trace.ID("late-first-test"), frame, time.Time{})
+	}
+	if len(got) != 0 {
+		t.Fatalf("首片未到已输出读数 %v", got)
+	}
+	feed(p, trace.ID("late-first-test"), fragFirst, time.Time{})
+	if got["长度"] != float32(2.5) || got["温度"] != float32(21.5) {
+		t.Errorf("迟到首片后读数 %v，期望 长度=2.5 温度=21.5", got)
+	}
+}
+
+// TestBuildFragmentFrames 构造的分片帧与手工构造的测试向量一致
+func TestBuildFragmentFrames(t *testing.T) {
+	id, err := ParseSensorID("238A0821BEF2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := []ParamValue{{Type: 0x0001, Value: mustHex("00002040")}, {Type: 0x0005, Value: mustHex("0000AC41")}}
+	frames, err := BuildFragmentFrames(id, PacketTypeMonitoring, 5, params, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]byte{fragFirst, fragMiddle, fragLast}
+	if len(frames) != len(want) {
+		t.Fatalf("得到 %d 片，期望 %d", len(frames), len(want))
+	}
+	for i := range want {
+		if !bytes.Equal(frames[i], want[i]) {
+			t.Errorf("第 %d 片 % X，期望 % X", i, frames[i], want[i])
+		}
+	}
+	if _, err := BuildFragmentFrames(id, PacketTypeMonitoring, 5, params[:1], 1); err == nil {
+		t.Error("分片数 1 未报错")
+	}
+}
+
+// TestReassembledOrigin 拼接完成的读数以首片的串口接收时间为 Origin
+func TestReassembledOrigin(t *testing.T) {
+	origins := make(map[string]time.Time)
+	p := NewPipeline(PipelineOptions{Name: "test", Sink: ValueSinkFunc(func(_, resourceName string, _ any, origin time.Time, _ map[string]string) {
+		origins[resourceName] = origin
+	})})
+	first := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	for i, frame := range [][]byte{fragFirst, fragMiddle, fragLast} {
+		feed(p, trace.ID("origin-test"), frame, first.Add(time.Duration(i)*time.Second))
+	}
+	if !origins["长度"].Equal(first) || !origins["温度"].Equal(first) {
+		t.Errorf("读数 Origin %v，期望首片接收时间 %v", origins, first)
+	}
+}
@@ -0,0 +1,124 @@
+package frameparser
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// FrameSubscribeOptions 订阅完整帧的参数
+type FrameSubscribeOptions struct {
+	// Buffer 订阅通道缓冲，0 表示 100
+	Buffer int
+	// DropWhenFull 为 true 时通道满则丢弃该帧并计数（指标、抓包等旁路消费者）；
+	// 为 false 时阻塞等待，处理慢时会拖慢所有订阅者（解析等不能丢帧的消费者）
+	DropWhenFull bool
+}
+
+// FrameSubscription 一个完整帧订阅者。各订阅者收到的是同一个 *Frame，只读，不得修改
+type FrameSubscription struct {
+	name    string
+	ch      chan *Frame
+	quit    chan struct{}
+	opts    FrameSubscribeOptions
+	dropped atomic.Uint64
+}
+
+// Name 返回订阅时的名称，用于日志和统计
+func (s *FrameSubscription) Name() string { return s.name }
+
+// Frames 返回接收完整帧的通道，输入关闭或取消订阅后关闭
+func (s *FrameSubscription) Frames() <-chan *Frame { return s.ch }
+
+// Dropped 返回因通道满被丢弃的帧数（仅 DropWhenFull 订阅者）
+func (s *FrameSubscription) Dropped() uint64 { return s.dropped.Load() }
+
+// FrameFanout 把一个输入通道上的完整帧分发给多个订阅者，各订阅者互不抢帧。
+// 首个订阅者订阅时开始读取输入，此前的帧留在输入通道中；没有订阅者时读到的帧被丢弃
+type FrameFanout struct {
+	in <-chan *Frame
+
+	mu    sync.RWMutex
+	subs  []*FrameSubscription
+	start sync.Once
+	// closed 输入已关闭，之后的订阅者直接收到已关闭的通道
+	closed bool
+}
+
+// NewFrameFanout 创建分发器，in 须只由分发器读取（如 Reassembler.Output()）
+func NewFrameFanout(in <-chan *Frame) *FrameFanout {
+	return &FrameFanout{in: in}
+}
+
+// Subscribe 增加一个订阅者，name 用于日志和统计
+func (f *FrameFanout) Subscribe(name string, opts FrameSubscribeOptions) *FrameSubscription {
+	n := opts.Buffer
+	if n <= 0 {
+		n = defaultOutputBuffer
+	}
+	s := &FrameSubscription{name: name, ch: make(chan *Frame, n), quit: make(chan struct{}), opts: opts}
+	f.mu.Lock()
+	if f.closed {
+		close(s.ch)
+	} else {
+		f.subs = append(f.subs, s)
+	}
+	f.mu.Unlock()
+	f.start.Do(func() { go f.run() })
+	return s
+}
+
+// Unsubscribe 取消订阅并关闭其通道，未取走的帧被丢弃；重复调用无效
+func (f *FrameFanout) Unsubscribe(s *FrameSubscription) {
+	// 先让分发协程放弃向该订阅者的阻塞发送，再在写锁下移除，保证关闭通道时没有发送在进行
+	select {
+	case <-s.quit:
+		return
+	default:
+		close(s.quit)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, sub := range f.subs {
+		if sub == s {
+			f.subs = append(f.subs[:i:i], f.subs[i+1:]...)
+			close(s.ch)
+			return
+		}
+	}
+}
+
+// Subscriptions 返回当前的订阅者
+func (f *FrameFanout) Subscriptions() []*FrameSubscription {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return append([]*FrameSubscription(nil), f.subs...)
+}
+
+// run 读取输入并按订阅顺序分发，输入关闭后关闭全部订阅通道
+func (f *FrameFanout) run() {
+	for frame := range f.in {
+		f.mu.RLock()
+		for _, s := range f.subs {
+			if s.opts.DropWhenFull {
+				select {
+				case s.ch <- frame:
+				default:
+					s.dropped.Add(1)
+				}
+				continue
+			}
+			select {
+			case s.ch <- frame:
+			case <-s.quit:
+			}
+		}
+		f.mu.RUnlock()
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	for _, s := range f.subs {
+		close(s.ch)
+	}
+	f.subs = nil
+}
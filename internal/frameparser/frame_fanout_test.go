@@ -0,0 +1,73 @@
+package frameparser
+
+import (
+	"testing"
+	"time"
+)
+
+// TestFrameFanout 各订阅者都收到同一帧；DropWhenFull 的订阅者通道满时丢帧计数，不拖慢其它订阅者；
+// 输入关闭后全部订阅通道关闭，之后的订阅者直接收到已关闭的通道
+func TestFrameFanout(t *testing.T) {
+	in := make(chan *Frame)
+	f := NewFrameFanout(in)
+	parse := f.Subscribe("parse", FrameSubscribeOptions{Buffer: 4})
+	tap := f.Subscribe("tap", FrameSubscribeOptions{Buffer: 1, DropWhenFull: true})
+
+	frames := []*Frame{{SSEQ: 1}, {SSEQ: 2}, {SSEQ: 3}}
+	for _, fr := range frames {
+		in <- fr
+	}
+	close(in)
+
+	for _, want := range frames {
+		if got := <-parse.Frames(); got != want {
+			t.Errorf("parse 收到 SSEQ=%d，期望 %d", got.SSEQ, want.SSEQ)
+		}
+	}
+	if got := <-tap.Frames(); got != frames[0] {
+		t.Errorf("tap 收到 SSEQ=%d，期望 1", got.SSEQ)
+	}
+	for _, s := range []*FrameSubscription{parse, tap} {
+		select {
+		case _, ok := <-s.Frames():
+			if ok {
+				t.Errorf("%s 输入关闭后仍收到帧", s.Name())
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%s 输入关闭后通道未关闭", s.Name())
+		}
+	}
+	if got := tap.Dropped(); got != 2 {
+		t.Errorf("tap 丢弃 %d 帧，期望 2", got)
+	}
+	if _, ok := <-f.Subscribe("late", FrameSubscribeOptions{}).Frames(); ok {
+		t.Error("输入关闭后的订阅者收到帧")
+	}
+}
+
+// TestFrameFanoutUnsubscribe 取消订阅后通道关闭，阻塞在该订阅者上的分发不影响其它订阅者
+func TestFrameFanoutUnsubscribe(t *testing.T) {
+	in := make(chan *Frame)
+	f := NewFrameFanout(in)
+	stuck := f.Subscribe("stuck", FrameSubscribeOptions{Buffer: 1})
+	other := f.Subscribe("other", FrameSubscribeOptions{Buffer: 4})
+	in <- &Frame{SSEQ: 1}
+	in <- &Frame{SSEQ: 2} // stuck 通道已满，分发阻塞在 stuck 上
+
+	f.Unsubscribe(stuck)
+	f.Unsubscribe(stuck)
+	if got := len(f.Subscriptions()); got != 1 {
+		t.Errorf("取消后订阅者 %d 个，期望 1", got)
+	}
+	for want := uint8(1); want <= 2; want++ {
+		select {
+		case fr := <-other.Frames():
+			if fr.SSEQ != want {
+				t.Errorf("other 收到 SSEQ=%d，期望 %d", fr.SSEQ, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("取消阻塞的订阅者后分发未继续")
+		}
+	}
+	close(in)
+}
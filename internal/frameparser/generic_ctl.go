@@ -0,0 +1,52 @@
+package frameparser
+
+// 通用控制报文：按 profile 资源属性中声明的 CtrlType 构造，新增控制类型无需新增构造函数
+
+import (
+	"fmt"
+)
+
+// BuildControlFrame 构造任意 CtrlType 的控制报文：
+//
+//	sensorID        [6]byte — 传感器 ID
+//	ctrlType        uint8   — 7bit 控制类型，1~127
+//	requestSetFlag  byte    — 0=查询，1=设置
+//	params          []Param — 参量列表，DataLen 为参量个数（超过 14 个时带扩展计数）
+//	data            []byte  — 无参量时的原始报文内容，DataLen 为 0
+//
+// params 与 data 不能同时给出；两者都为空时为只有控制字节的报文（如复位）。
+func BuildControlFrame(sensorID [6]byte, ctrlType uint8, requestSetFlag byte, params []Param, data []byte) ([]byte, error) {
+	// 1. 校验控制类型和标志位
+	if ctrlType == 0 || ctrlType > 0x7F {
+		return nil, fmt.Errorf("CtrlType %d 超出范围，必须 1~127", ctrlType)
+	}
+	if requestSetFlag != 0 && requestSetFlag != 1 {
+		return nil, fmt.Errorf("invalid requestSetFlag %d, must be 0 or 1", requestSetFlag)
+	}
+	m := len(params)
+	if m > 0 && len(data) > 0 {
+		return nil, fmt.Errorf("控制报文不能同时携带参量列表和原始数据")
+	}
+	if m > MaxExtendedParams {
+		return nil, fmt.Errorf("%w: 必须 0~%d, got %d", ErrParamCount, MaxExtendedParams, m)
+	}
+
+	// 2. SensorID + head(DataLen|FragInd=0|PacketType=4) + CtrlType<<1|flag + 扩展计数
+	buf := make([]byte, 0, 6+1+1+1+6*m+len(data)+2)
+	buf = append(buf, sensorID[:]...)
+	buf = append(buf, dataLenNibble(m)<<4|byte(packetTypeControl&0x07))
+	buf = append(buf, byte((ctrlType&0x7F)<<1)|(requestSetFlag&0x01))
+	buf = appendExtendedCount(buf, m)
+
+	// 3. 参量列表或原始数据
+	for _, p := range params {
+		var err error
+		if buf, err = AppendParam(buf, p); err != nil {
+			return nil, err
+		}
+	}
+	buf = append(buf, data...)
+
+	// 4. CRC16（大端）
+	return StandardDialect.Seal(buf), nil
+}
@@ -0,0 +1,54 @@
+package frameparser
+
+import (
+	"sync"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/trace"
+)
+
+// packetTypeMonitoringResp 3bit = 001b = 1（监测数据响应报文）
+const packetTypeMonitoringResp = 0x01
+
+// HeartbeatHandler 在收到传感器心跳后被调用，sensorID 为大写十六进制
+type HeartbeatHandler func(id trace.ID, sensorID string)
+
+var (
+	heartbeatMu      sync.RWMutex
+	heartbeatHandler HeartbeatHandler
+)
+
+// SetHeartbeatHandler 设置心跳回调（如下发心跳响应），对未设置 PipelineOptions.Heartbeat 的流水线生效
+func SetHeartbeatHandler(h HeartbeatHandler) {
+	heartbeatMu.Lock()
+	defer heartbeatMu.Unlock()
+	heartbeatHandler = h
+}
+
+// isHeartbeat 不携带任何参量（DataLen=0）的监测数据报文即为心跳
+func isHeartbeat(packetType byte, dataCount int) bool {
+	return packetType == 0 && dataCount == 0
+}
+
+// notifyHeartbeat 调用生效的心跳回调
+func (p *Pipeline) notifyHeartbeat(id trace.ID, sensorID string) {
+	h := p.heartbeat
+	if h == nil {
+		heartbeatMu.RLock()
+		h = heartbeatHandler
+		heartbeatMu.RUnlock()
+	}
+	if h != nil {
+		h(id, sensorID)
+	}
+}
+
+// BuildHeartbeatResponse 构造心跳响应：对应传感器的监测数据响应报文，
+// 不携带参量（DataLen=0，FragInd=0，PacketType=1），仅由 SensorID + 报文头 + CRC 组成
+func BuildHeartbeatResponse(sensorID [6]byte) []byte {
+	// 6B SensorID + 1B head + 2B CRC
+	buf := make([]byte, 0, 6+1+2)
+	buf = append(buf, sensorID[:]...)
+	buf = append(buf, byte(packetTypeMonitoringResp&0x07))
+
+	return StandardDialect.Seal(buf)
+}
@@ -0,0 +1,65 @@
+package frameparser
+
+// 封装 7.8 节 历史数据查询报文
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// ctrlTypeHistoryQuery 7bit = 7 （“历史数据查询”类型码）。该值尚未对照《Q/GDW 12184—2021》原文核实，
+// 只保证不与本包已用的 1~5 冲突；报文内容布局同样以本文件注释为准，核实后如有出入请一并修改
+const ctrlTypeHistoryQuery = 0x07
+
+// CtrlTypeHistoryQuery 导出给下发方匹配历史数据的控制响应
+const CtrlTypeHistoryQuery = ctrlTypeHistoryQuery
+
+// BuildHistoryQueryFrame 构造“历史数据查询”控制报文：
+//
+//	sensorID    [6]byte   — 传感器 ID
+//	start, end  time.Time — 查询时间段，按世纪秒（Unix 秒）编码，大端 4 字节
+//	paramTypes  []uint16  — 需要查询的参量类型（14bit）；为空表示查询全部
+//
+// 报文内容：StartTime(4B) + EndTime(4B) + 参量类型列表（每项 2 字节小端，同监测数据查询）。
+// 返回：完整的二进制帧（已附加 CRC16），或错误。
+func BuildHistoryQueryFrame(sensorID [6]byte, start, end time.Time, paramTypes []uint16) ([]byte, error) {
+	if end.Before(start) {
+		return nil, fmt.Errorf("结束时间 %s 早于开始时间 %s", end.Format(time.RFC3339), start.Format(time.RFC3339))
+	}
+	if start.Unix() < 0 || end.Unix() > 0xFFFFFFFF {
+		return nil, fmt.Errorf("查询时间超出 32 位世纪秒范围")
+	}
+	m := len(paramTypes)
+	if m > MaxExtendedParams {
+		return nil, fmt.Errorf("%w: 查询参量个数必须 0~%d, got %d", ErrParamCount, MaxExtendedParams, m)
+	}
+
+	// 1. DataLen：0b1111 且无参量列表表示查询全部；超过 14 个时为 0b1111 加扩展计数
+	dataLen := dataLenNibble(m)
+	if m == 0 {
+		dataLen = DataLenExtended
+	}
+
+	// 2. SensorID + head + CtrlType<<1（查询固定为 0）
+	buf := make([]byte, 0, 6+1+1+1+8+2*m+2)
+	buf = append(buf, sensorID[:]...)
+	buf = append(buf, byte((dataLen&0x0F)<<4)|byte(packetTypeControl&0x07))
+	buf = append(buf, byte((ctrlTypeHistoryQuery&0x7F)<<1))
+	buf = appendExtendedCount(buf, m)
+
+	// 3. 时间段
+	buf = binary.BigEndian.AppendUint32(buf, uint32(start.Unix()))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(end.Unix()))
+
+	// 4. 参量类型列表
+	for _, t := range paramTypes {
+		if t > 0x3FFF {
+			return nil, fmt.Errorf("%w: 0x%X", ErrInvalidParamType, t)
+		}
+		buf = binary.LittleEndian.AppendUint16(buf, t<<2)
+	}
+
+	// 5. CRC16（大端）
+	return StandardDialect.Seal(buf), nil
+}
@@ -0,0 +1,132 @@
+package frameparser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+)
+
+// 示例报文的传感器 ID；各测试的期望报文体按 history_ctl.go、alarm_ctl.go 注释中的编码规则逐字节写出，
+// 不是规范附录中的原始示例
+var exampleSensorID = [6]byte{0x23, 0x8A, 0x08, 0x21, 0xBE, 0xF2}
+
+// sealedBody 校验帧尾为报文体的 CRC16（大端），返回去掉 CRC 的报文体
+func sealedBody(t *testing.T, frame []byte) []byte {
+	t.Helper()
+	if len(frame) < 9 {
+		t.Fatalf("帧长 %d", len(frame))
+	}
+	body := frame[:len(frame)-2]
+	if got, want := binary.BigEndian.Uint16(frame[len(frame)-2:]), CRC16(body); got != want {
+		t.Fatalf("CRC 0x%04X，期望 0x%04X", got, want)
+	}
+	return body
+}
+
+func TestBuildHistoryQueryFrame(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC) // 0x5FEE6600
+	end := start.Add(time.Hour)                          // 0x5FEE7410
+	frame, err := BuildHistoryQueryFrame(exampleSensorID, start, end, []uint16{0x00A3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		0x23, 0x8A, 0x08, 0x21, 0xBE, 0xF2, // SensorID
+		0x14,                   // DataLen=1 | FragInd=0 | PacketType=4（控制报文）
+		0x0E,                   // CtrlType=7 <<1 | RequestSetFlag=0
+		0x5F, 0xEE, 0x66, 0x00, // StartTime（大端世纪秒）
+		0x5F, 0xEE, 0x74, 0x10, // EndTime
+		0x8C, 0x02, // 参量类型 0x00A3<<2（小端）
+	}
+	if body := sealedBody(t, frame); !bytes.Equal(body, want) {
+		t.Fatalf("报文体 % X，期望 % X", body, want)
+	}
+
+	// 按控制报文子层解回
+	s := DescribeFrame(frame, StandardDialect)
+	if !s.CRCOK || s.Err != nil || s.PacketType != packetTypeControl || s.Control == nil {
+		t.Fatalf("解码 %+v", s)
+	}
+	c := s.Control
+	if c.CtrlType != CtrlTypeHistoryQuery || c.RequestSet || c.DataLen != 1 {
+		t.Errorf("控制子层 %+v", c)
+	}
+	if got := time.Unix(int64(binary.BigEndian.Uint32(c.Data[0:4])), 0); !got.Equal(start) {
+		t.Errorf("开始时间 %s，期望 %s", got, start)
+	}
+	if got := time.Unix(int64(binary.BigEndian.Uint32(c.Data[4:8])), 0); !got.Equal(end) {
+		t.Errorf("结束时间 %s，期望 %s", got, end)
+	}
+	if got := binary.LittleEndian.Uint16(c.Data[8:10]) >> 2; got != 0x00A3 {
+		t.Errorf("参量类型 0x%04X，期望 0x00A3", got)
+	}
+}
+
+func TestBuildHistoryQueryFrameAll(t *testing.T) {
+	start := time.Unix(0x5FEE6600, 0)
+	frame, err := BuildHistoryQueryFrame(exampleSensorID, start, start, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 不列参量类型时 DataLen=0b1111，报文内容只有时间段
+	want := []byte{0x23, 0x8A, 0x08, 0x21, 0xBE, 0xF2, 0xF4, 0x0E, 0x5F, 0xEE, 0x66, 0x00, 0x5F, 0xEE, 0x66, 0x00}
+	if body := sealedBody(t, frame); !bytes.Equal(body, want) {
+		t.Errorf("报文体 % X，期望 % X", body, want)
+	}
+}
+
+func TestBuildHistoryQueryFrameErrors(t *testing.T) {
+	start := time.Unix(0x5FEE6600, 0)
+	if _, err := BuildHistoryQueryFrame(exampleSensorID, start, start.Add(-time.Second), nil); err == nil {
+		t.Error("结束时间早于开始时间未被拒绝")
+	}
+	if _, err := BuildHistoryQueryFrame(exampleSensorID, time.Unix(-1, 0), start, nil); err == nil {
+		t.Error("负的世纪秒未被拒绝")
+	}
+	if _, err := BuildHistoryQueryFrame(exampleSensorID, start, start, []uint16{0x4000}); !errors.Is(err, ErrInvalidParamType) {
+		t.Errorf("超出 14bit 的参量类型: %v", err)
+	}
+	if _, err := BuildHistoryQueryFrame(exampleSensorID, start, start, make([]uint16, MaxExtendedParams+1)); !errors.Is(err, ErrParamCount) {
+		t.Errorf("参量个数超限: %v", err)
+	}
+}
+
+func TestBuildAlarmParamFrame(t *testing.T) {
+	// 查询两个参量的告警阈值：只列类型
+	query, err := BuildAlarmParamFrame(exampleSensorID, 0, []Param{{Type: 0x00A3}, {Type: 0x00A4}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x23, 0x8A, 0x08, 0x21, 0xBE, 0xF2, 0x24, 0x04, 0x8C, 0x02, 0x90, 0x02}
+	if body := sealedBody(t, query); !bytes.Equal(body, want) {
+		t.Errorf("查询报文体 % X，期望 % X", body, want)
+	}
+
+	// 设置阈值：参量编码同上行（4 字节数据 LengthFlag=0），解回后与设置值一致
+	threshold := Param{Type: 0x00A3, Data: []byte{0x41, 0x20, 0x00, 0x00}}
+	set, err := BuildAlarmParamFrame(exampleSensorID, 1, []Param{threshold})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []byte{0x23, 0x8A, 0x08, 0x21, 0xBE, 0xF2, 0x14, 0x05, 0x8C, 0x02, 0x41, 0x20, 0x00, 0x00}
+	if body := sealedBody(t, set); !bytes.Equal(body, want) {
+		t.Errorf("设置报文体 % X，期望 % X", body, want)
+	}
+	s := DescribeFrame(set, StandardDialect)
+	if s.Control == nil || s.Control.CtrlType != CtrlTypeAlarmParams || !s.Control.RequestSet {
+		t.Fatalf("解码 %+v", s)
+	}
+	params, err := s.Control.Params()
+	if err != nil || len(params) != 1 || params[0].Type != threshold.Type || !bytes.Equal(params[0].Data, threshold.Data) {
+		t.Errorf("解回参量 %+v, %v", params, err)
+	}
+
+	if _, err := BuildAlarmParamFrame(exampleSensorID, 1, nil); !errors.Is(err, ErrParamCount) {
+		t.Errorf("设置时未带参量: %v", err)
+	}
+	if _, err := BuildAlarmParamFrame(exampleSensorID, 2, nil); err == nil {
+		t.Error("无效的 requestSetFlag 未被拒绝")
+	}
+}
@@ -0,0 +1,42 @@
+package frameparser
+
+import (
+	"encoding/binary"
+	"log"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/correlation"
+	"github.com/linjuya-lu/device-lpmp-go/internal/trace"
+)
+
+// LoopbackSensorID 自检回环帧使用的保留 SensorID，不会分配给真实传感器
+const LoopbackSensorID = "000000000000"
+
+// loopbackFrameLen 6B SensorID + 1B head + 2B 参数头 + 4B nonce + 2B CRC
+const loopbackFrameLen = 6 + 1 + 2 + 4 + 2
+
+// BuildLoopbackFrame 构造自检回环帧：SensorID 为全 0 的监测数据报文，
+// 携带 1 个参量（类型 0，默认 4 字节长度），数据为 nonce（大端序），
+// 模组处于回环模式时会原样以 +DRX 上报。
+func BuildLoopbackFrame(nonce uint32) []byte {
+	buf := make([]byte, 6, loopbackFrameLen)
+
+	// head：DataLen=1 | FragInd=0 | PacketType=0（监测数据）
+	buf = append(buf, byte(1<<4))
+
+	// 参数头：ParamType=0，lenFlag=0（4 字节），小端序
+	buf = append(buf, 0x00, 0x00)
+	buf = binary.BigEndian.AppendUint32(buf, nonce)
+
+	return StandardDialect.Seal(buf)
+}
+
+// handleLoopback 处理收到的回环帧，唤醒等待自检结果的请求
+func handleLoopback(id trace.ID, frame []byte) {
+	if len(frame) != loopbackFrameLen {
+		log.Printf("[trace=%s] 回环帧长度错误: %d", id, len(frame))
+		return
+	}
+	nonce := binary.BigEndian.Uint32(frame[9:13])
+	n := correlation.Default.Resolve(correlation.Key{SensorID: LoopbackSensorID, PacketType: 0}, correlation.Result{Payload: nonce})
+	debugf("[trace=%s] 收到回环帧 nonce=%08X，唤醒 %d 个等待方", id, nonce, n)
+}
@@ -0,0 +1,76 @@
+package frameparser
+
+// 封装 7.1 节 监测数据查询报文
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// ctrlTypeMonitoringQuery 7bit = 1 （协议“监测数据查询”类型码）
+const ctrlTypeMonitoringQuery = 0x01
+
+// maxQueryParams DataLen 为 4bit，0xF 保留表示“查询全部”或扩展计数，因此不带扩展计数时最多列出 14 个参量
+const maxQueryParams = 14
+
+// BuildMonitoringQueryFrame 构造“监测数据查询”控制报文：
+//
+//	sensorID    [6]byte  — 传感器 ID
+//	paramTypes  []uint16 — 需要查询的参量类型（14bit）；为空表示查询全部监测数据
+//
+// 每个参量类型按上行参数头相同的格式编码：ParamType(14b)<<2 | 保留(2b=0)，小端序 2 字节。
+// 返回：完整的二进制帧（已附加 CRC16），或错误。
+func BuildMonitoringQueryFrame(sensorID [6]byte, paramTypes []uint16) ([]byte, error) {
+	m := len(paramTypes)
+	if m > MaxExtendedParams {
+		return nil, fmt.Errorf("%w: 查询参量个数必须 0~%d, got %d", ErrParamCount, MaxExtendedParams, m)
+	}
+
+	// 1. DataLen：0b1111 且无参量列表表示查询全部；超过 14 个时为 0b1111 加扩展计数
+	dataLen := dataLenNibble(m)
+	if m == 0 {
+		dataLen = DataLenExtended
+	}
+
+	// 2. 预分配：6B SensorID + 1B head + 1B ctrl + 1B 扩展计数 + 2B*m + 2B CRC
+	buf := make([]byte, 0, 6+1+1+1+2*m+2)
+	buf = append(buf, sensorID[:]...)
+
+	// 3. head：DataLen(4b) | FragInd(1b=0)<<3 | PacketType(3b)
+	head := byte((dataLen&0x0F)<<4) | byte(packetTypeControl&0x07)
+	buf = append(buf, head)
+
+	// 4. CtrlType+RequestSetFlag：查询固定为 0
+	ctrlByte := byte((ctrlTypeMonitoringQuery & 0x7F) << 1)
+	buf = append(buf, ctrlByte)
+	buf = appendExtendedCount(buf, m)
+
+	// 5. 参量类型列表
+	for _, t := range paramTypes {
+		if t > 0x3FFF {
+			return nil, fmt.Errorf("%w: 0x%X", ErrInvalidParamType, t)
+		}
+		le := make([]byte, 2)
+		binary.LittleEndian.PutUint16(le, t<<2)
+		buf = append(buf, le...)
+	}
+
+	// 6. CRC16 校验位（大端序）
+	return StandardDialect.Seal(buf), nil
+}
+
+// ParseSensorID 将 12 位十六进制字符串形式的 SensorID 转为 6 字节数组
+func ParseSensorID(s string) ([6]byte, error) {
+	var id [6]byte
+	raw, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return id, fmt.Errorf("%w: %q 不是合法的十六进制：%w", ErrInvalidSensorID, s, err)
+	}
+	if len(raw) != len(id) {
+		return id, fmt.Errorf("%w: %q 长度应为 6 字节，实际 %d 字节", ErrInvalidSensorID, s, len(raw))
+	}
+	copy(id[:], raw)
+	return id, nil
+}
@@ -0,0 +1,270 @@
+package frameparser
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// 乱序缓存上限的默认值：单个 SDU 不超过序号窗口，整个服务 4096 个片段
+const (
+	DefaultMaxOutOfOrderPerSDU     = pseqWindow
+	DefaultMaxOutOfOrderPerService = 4096
+)
+
+var (
+	maxOutOfOrderPerSDU atomic.Int64
+	// bufferedFragments 所有拼接器乱序缓存和首片前暂存中的片段总数
+	bufferedFragments atomic.Int64
+	// arrivalSeq 暂存片段的到达次序，各拼接器共用，超出上限时跨拼接器按先后回收
+	arrivalSeq atomic.Uint64
+	// defaultBudget 未设置 ReassemblerOptions.OutOfOrderBudget 的拼接器共享的预算
+	defaultBudget = NewOutOfOrderBudget(DefaultMaxOutOfOrderPerService)
+)
+
+func init() {
+	maxOutOfOrderPerSDU.Store(DefaultMaxOutOfOrderPerSDU)
+}
+
+// OutOfOrderBudget 若干拼接器（如一个驱动实例的主/备链路）共享的暂存片段上限，乱序缓存和首片前暂存
+// 的片段都计入。超出上限时按到达先后回收这些拼接器中最早暂存的片段，不论它属于哪条链路；
+// 回收在 Process 返回之前完成，并发处理时可短暂超出
+type OutOfOrderBudget struct {
+	limit    atomic.Int64
+	buffered atomic.Int64
+
+	// evictMu 串行化回收，持有时逐个（不嵌套）获取拼接器锁
+	evictMu sync.Mutex
+	// mu 保护 holders：当前有暂存片段的拼接器。拼接器持有自己的锁时可获取 mu，反之不可
+	mu      sync.Mutex
+	holders map[*Reassembler]struct{}
+}
+
+// NewOutOfOrderBudget 创建暂存片段上限为 limit 的预算，limit 小于等于 0 时使用 DefaultMaxOutOfOrderPerService
+func NewOutOfOrderBudget(limit int) *OutOfOrderBudget {
+	b := &OutOfOrderBudget{holders: make(map[*Reassembler]struct{})}
+	b.SetLimit(limit)
+	return b
+}
+
+// SetLimit 修改上限，小于等于 0 时使用 DefaultMaxOutOfOrderPerService；已超出的部分在下一个片段暂存时回收
+func (b *OutOfOrderBudget) SetLimit(limit int) {
+	if limit <= 0 {
+		limit = DefaultMaxOutOfOrderPerService
+	}
+	b.limit.Store(int64(limit))
+}
+
+// Buffered 返回共享该预算的拼接器当前暂存的片段数
+func (b *OutOfOrderBudget) Buffered() int64 {
+	return b.buffered.Load()
+}
+
+// enforce 超出上限时回收共享该预算的拼接器中最早暂存的片段，直到不超出（调用方不得持有任何拼接器锁）
+func (b *OutOfOrderBudget) enforce() {
+	if b.buffered.Load() <= b.limit.Load() {
+		return
+	}
+	b.evictMu.Lock()
+	defer b.evictMu.Unlock()
+	for b.buffered.Load() > b.limit.Load() {
+		b.mu.Lock()
+		holders := make([]*Reassembler, 0, len(b.holders))
+		for r := range b.holders {
+			holders = append(holders, r)
+		}
+		b.mu.Unlock()
+
+		var (
+			victim *Reassembler
+			at     uint64
+		)
+		for _, r := range holders {
+			r.mu.Lock()
+			a, ok := r.oldestBuffered()
+			r.mu.Unlock()
+			if ok && (victim == nil || a < at) {
+				victim, at = r, a
+			}
+		}
+		if victim == nil {
+			return
+		}
+		victim.mu.Lock()
+		victim.evictOldest()
+		victim.mu.Unlock()
+	}
+}
+
+// SetOutOfOrderLimits 设置乱序缓存上限：perSDU 为单个 SDU 最多暂存的片段数（可被
+// ReassemblerOptions.MaxOutOfOrderPerSDU 覆盖），perService 为未设置 ReassemblerOptions.OutOfOrderBudget
+// 的拼接器合计；小于等于 0 的值不修改
+func SetOutOfOrderLimits(perSDU, perService int) {
+	if perSDU > 0 {
+		maxOutOfOrderPerSDU.Store(int64(perSDU))
+	}
+	if perService > 0 {
+		defaultBudget.SetLimit(perService)
+	}
+}
+
+// BufferedFragments 返回当前所有拼接器乱序缓存和首片前暂存中的片段总数
+func BufferedFragments() int64 {
+	return bufferedFragments.Load()
+}
+
+// maxOutOfOrderPerSDU 返回生效的单个 SDU 乱序缓存上限
+func (r *Reassembler) maxOutOfOrderPerSDU() int {
+	if r.opts.MaxOutOfOrderPerSDU > 0 {
+		return r.opts.MaxOutOfOrderPerSDU
+	}
+	return int(maxOutOfOrderPerSDU.Load())
+}
+
+// budget 返回生效的暂存片段预算
+func (r *Reassembler) budget() *OutOfOrderBudget {
+	if r.opts.OutOfOrderBudget != nil {
+		return r.opts.OutOfOrderBudget
+	}
+	return defaultBudget
+}
+
+// hold 调整本拼接器暂存的片段数，同步到所属预算和全服务计数（调用方持有 r.mu）
+func (r *Reassembler) hold(n int) {
+	if n == 0 {
+		return
+	}
+	b := r.budget()
+	r.buffered += n
+	b.buffered.Add(int64(n))
+	bufferedFragments.Add(int64(n))
+	b.mu.Lock()
+	if r.buffered > 0 {
+		b.holders[r] = struct{}{}
+	} else {
+		delete(b.holders, r)
+	}
+	b.mu.Unlock()
+}
+
+// bufferOutOfOrder 把超前的片段放入乱序缓存，超出单个 SDU 的上限时丢弃本 SDU 最早暂存的片段；
+// 返回该 PSEQ 是否已暂存过（重传）。超出预算的部分由 Process 返回前回收（调用方持有 r.mu）
+func (r *Reassembler) bufferOutOfOrder(sensorID [6]byte, c *SDUCache, pseq uint8, data []byte) bool {
+	c.ooArrival[pseq] = arrivalSeq.Add(1)
+	if _, dup := c.outOfOrder[pseq]; dup {
+		c.outOfOrder[pseq] = data
+		return true
+	}
+	c.outOfOrder[pseq] = data
+	c.outOfOrderCount++
+	r.hold(1)
+
+	for len(c.outOfOrder) > r.maxOutOfOrderPerSDU() {
+		r.evictOutOfOrder(sensorID, c, oldestOutOfOrder(c))
+	}
+	return false
+}
+
+// oldestOutOfOrder 返回缓存中最早暂存的片段序号，缓存非空
+func oldestOutOfOrder(c *SDUCache) uint8 {
+	var (
+		oldest uint8
+		at     uint64
+		found  bool
+	)
+	for seq := range c.outOfOrder {
+		if a := c.ooArrival[seq]; !found || a < at {
+			oldest, at, found = seq, a, true
+		}
+	}
+	return oldest
+}
+
+// oldestBuffered 返回本拼接器最早暂存的片段（乱序缓存或首片前暂存）的到达次序（调用方持有 r.mu）
+func (r *Reassembler) oldestBuffered() (uint64, bool) {
+	var (
+		at    uint64
+		found bool
+	)
+	for _, c := range r.caches {
+		for _, a := range c.ooArrival {
+			if !found || a < at {
+				at, found = a, true
+			}
+		}
+	}
+	for _, bySSEQ := range r.early {
+		for _, e := range bySSEQ {
+			for _, a := range e.arrival {
+				if !found || a < at {
+					at, found = a, true
+				}
+			}
+		}
+	}
+	return at, found
+}
+
+// evictOldest 回收本拼接器最早暂存的片段（调用方持有 r.mu）
+func (r *Reassembler) evictOldest() {
+	var (
+		at       uint64
+		found    bool
+		sensorID [6]byte
+		cache    *SDUCache
+		early    *earlySDU
+		pseq     uint8
+	)
+	for id, c := range r.caches {
+		for seq, a := range c.ooArrival {
+			if !found || a < at {
+				at, found, sensorID, cache, early, pseq = a, true, id, c, nil, seq
+			}
+		}
+	}
+	for id, bySSEQ := range r.early {
+		for _, e := range bySSEQ {
+			for seq, a := range e.arrival {
+				if !found || a < at {
+					at, found, sensorID, cache, early, pseq = a, true, id, nil, e, seq
+				}
+			}
+		}
+	}
+	switch {
+	case cache != nil:
+		r.evictOutOfOrder(sensorID, cache, pseq)
+	case early != nil:
+		r.evictEarly(sensorID, early, pseq)
+	}
+}
+
+// evictOutOfOrder 因超出上限丢弃一个暂存片段并计数（调用方持有 r.mu）
+func (r *Reassembler) evictOutOfOrder(sensorID [6]byte, c *SDUCache, pseq uint8) {
+	data := c.outOfOrder[pseq]
+	r.takeOutOfOrder(c, pseq)
+	recordDrop(ReassemblyEvent{
+		SensorID: sensorID,
+		SSEQ:     c.SSEQ,
+		PSEQ:     pseq,
+		Reason:   DropOutOfOrderEvicted,
+		Bytes:    len(data),
+		TraceID:  c.traceID,
+	})
+}
+
+// takeOutOfOrder 从乱序缓存中移除一个片段（调用方持有 r.mu）
+func (r *Reassembler) takeOutOfOrder(c *SDUCache, pseq uint8) {
+	if _, ok := c.outOfOrder[pseq]; !ok {
+		return
+	}
+	delete(c.outOfOrder, pseq)
+	delete(c.ooArrival, pseq)
+	r.hold(-1)
+}
+
+// releaseOutOfOrder 缓存结束（完成或丢弃）时归还其乱序片段的计数（调用方持有 r.mu）
+func (r *Reassembler) releaseOutOfOrder(c *SDUCache) {
+	r.hold(-len(c.outOfOrder))
+	clear(c.outOfOrder)
+	clear(c.ooArrival)
+}
@@ -0,0 +1,121 @@
+package frameparser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/trace"
+)
+
+// TestOutOfOrderPerSDULimit 解析入口收到的超前片段超过单个 SDU 上限时丢弃最早暂存的片段
+func TestOutOfOrderPerSDULimit(t *testing.T) {
+	p := NewPipeline(PipelineOptions{Name: "test", Reassembly: ReassemblerOptions{MaxOutOfOrderPerSDU: 1}})
+	events := SubscribeReassembly(16)
+	before := DropCounts()
+	buffered := BufferedFragments()
+
+	p.handleFrame(trace.ID("ooo-test"), fragFirst, time.Time{})
+	p.handleFrame(trace.ID("ooo-test"), sealed("238A0821BEF2"+"08"+"1602"+"00000000"), time.Time{})
+	p.handleFrame(trace.ID("ooo-test"), sealed("238A0821BEF2"+"08"+"1703"+"00000000"), time.Time{})
+	var ev ReassemblyEvent
+	for ev.Kind != ReassemblyDropped {
+		select {
+		case ev = <-events:
+		case <-time.After(time.Second):
+			t.Fatalf("未收到 %s 事件", DropOutOfOrderEvicted)
+		}
+	}
+	if ev.Reason != DropOutOfOrderEvicted || ev.PSEQ != 2 {
+		t.Errorf("Dropped 事件 %+v，期望 %s PSEQ=2", ev, DropOutOfOrderEvicted)
+	}
+	if n := DropCounts()[DropOutOfOrderEvicted] - before[DropOutOfOrderEvicted]; n != 1 {
+		t.Errorf("%s 计数增加 %d，期望 1", DropOutOfOrderEvicted, n)
+	}
+	if n := BufferedFragments() - buffered; n != 1 {
+		t.Errorf("乱序缓存增加 %d 个片段，期望 1", n)
+	}
+	// 新首片替换未完成的 SDU，其乱序片段归还服务级计数
+	p.handleFrame(trace.ID("ooo-test"), sealed("238A0821BEF2"+"28"+"1800"+"04000000"), time.Time{})
+	if n := BufferedFragments() - buffered; n != 0 {
+		t.Errorf("替换后乱序缓存仍多 %d 个片段", n)
+	}
+}
+
+func mustFragment(t *testing.T, frame []byte) *Frame {
+	t.Helper()
+	f, err := ParseFragment(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// TestPreFirstStashLimit 首片前暂存的片段计入预算和单个 SDU 的上限，超出时回收最早暂存的片段并计数
+func TestPreFirstStashLimit(t *testing.T) {
+	b := NewOutOfOrderBudget(16)
+	r := NewReassembler(ReassemblerOptions{PSEQStart: &PSEQStart{Auto: true}, MaxOutOfOrderPerSDU: 1, OutOfOrderBudget: b})
+	before := DropCounts()
+
+	r.Process(mustFragment(t, fragMiddle))
+	if b.Buffered() != 1 {
+		t.Fatalf("暂存 1 片后预算计数 %d", b.Buffered())
+	}
+	r.Process(mustFragment(t, fragLast))
+	if b.Buffered() != 1 {
+		t.Errorf("超出单个 SDU 上限后预算计数 %d，期望 1", b.Buffered())
+	}
+	if n := DropCounts()[DropOutOfOrderEvicted] - before[DropOutOfOrderEvicted]; n != 1 {
+		t.Errorf("%s 计数增加 %d，期望 1", DropOutOfOrderEvicted, n)
+	}
+	r.mu.Lock()
+	_, kept := r.early[mustFragment(t, fragLast).SensorID][5].frags[2]
+	r.mu.Unlock()
+	if !kept {
+		t.Error("回收的不是最早暂存的片段")
+	}
+
+	// 首片到达后尾片转入乱序缓存，缺中间片未完成；缓存丢弃后计数归零
+	r.Process(mustFragment(t, fragFirst))
+	if b.Buffered() != 1 {
+		t.Errorf("并入首片后预算计数 %d，期望 1", b.Buffered())
+	}
+	r.Process(mustFragment(t, sealed("238A0821BEF2"+"28"+"1800"+"04000000")))
+	if b.Buffered() != 0 {
+		t.Errorf("缓存被替换后预算计数 %d，期望 0", b.Buffered())
+	}
+}
+
+// TestOutOfOrderBudgetShared 共享预算的拼接器超出上限时，回收的是所有拼接器中最早暂存的片段
+func TestOutOfOrderBudgetShared(t *testing.T) {
+	b := NewOutOfOrderBudget(2)
+	primary := NewReassembler(ReassemblerOptions{OutOfOrderBudget: b})
+	backup := NewReassembler(ReassemblerOptions{OutOfOrderBudget: b, PSEQStart: &PSEQStart{Auto: true}})
+	before := DropCounts()
+
+	primary.Process(mustFragment(t, fragFirst))
+	primary.Process(mustFragment(t, sealed("238A0821BEF2"+"08"+"1602"+"00000000")))
+	backup.Process(mustFragment(t, fragMiddle)) // 首片前暂存
+	if b.Buffered() != 2 {
+		t.Fatalf("预算计数 %d，期望 2", b.Buffered())
+	}
+	backup.Process(mustFragment(t, sealed("238A0821BEF2"+"08"+"1603"+"00000000")))
+	if b.Buffered() != 2 {
+		t.Errorf("超出上限后预算计数 %d，期望 2", b.Buffered())
+	}
+	if n := DropCounts()[DropOutOfOrderEvicted] - before[DropOutOfOrderEvicted]; n != 1 {
+		t.Errorf("%s 计数增加 %d，期望 1", DropOutOfOrderEvicted, n)
+	}
+	primary.mu.Lock()
+	evicted := primary.buffered == 0
+	primary.mu.Unlock()
+	if !evicted {
+		t.Error("未回收另一拼接器中最早暂存的片段")
+	}
+
+	// 调高上限后不再回收
+	b.SetLimit(8)
+	backup.Process(mustFragment(t, sealed("238A0821BEF2"+"08"+"1604"+"00000000")))
+	if b.Buffered() != 3 {
+		t.Errorf("调高上限后预算计数 %d，期望 3", b.Buffered())
+	}
+}
@@ -0,0 +1,74 @@
+package frameparser
+
+import (
+	"errors"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/paramcodec"
+)
+
+var (
+	// ErrParamHeadOverflow 剩余字节不足以容纳参数头或长度字段
+	ErrParamHeadOverflow = paramcodec.ErrHeadOverflow
+	// ErrParamDataOverflow 参数数据长度超出报文
+	ErrParamDataOverflow = paramcodec.ErrDataOverflow
+	// ErrExtendedCountMissing DataLen=0b1111 的上行报文缺少扩展计数字节
+	ErrExtendedCountMissing = errors.New("DataLen=0b1111 但缺少扩展计数字节")
+)
+
+// DataLenExtended 报文头 DataLen 的 0b1111：下行查询不带参量列表时表示“查询全部”；
+// 带参量列表（含上行报文）时参量个数由紧随其后的 1 字节扩展计数给出，
+// 业务报文紧跟报文头，控制报文紧跟控制字节，该字节不参与压缩
+const DataLenExtended = 0x0F
+
+// MaxExtendedParams 扩展计数可表示的最多参量个数
+const MaxExtendedParams = 0xFF
+
+// ExpandDataLen 按 DataLen 取出参量个数和参量列表：DataLen 为 0b1111 时
+// 读取 content 首字节作为扩展计数，content 为空时返回 ErrExtendedCountMissing
+// （下行查询全部的情形由调用方先用 IsQueryAll 判断）
+func ExpandDataLen(dataLen int, content []byte) (int, []byte, error) {
+	if dataLen != DataLenExtended {
+		return dataLen, content, nil
+	}
+	if len(content) == 0 {
+		return 0, content, ErrExtendedCountMissing
+	}
+	return int(content[0]), content[1:], nil
+}
+
+// IsQueryAll 下行查询报文 DataLen=0b1111 且不带参量列表，表示查询全部
+func IsQueryAll(dataLen int, content []byte) bool {
+	return dataLen == DataLenExtended && len(content) == 0
+}
+
+// dataLenNibble 参量个数对应的报文头 DataLen：超过 14 个时为 0b1111（扩展计数）
+func dataLenNibble(m int) byte {
+	if m > maxQueryParams {
+		return DataLenExtended
+	}
+	return byte(m)
+}
+
+// appendExtendedCount 参量个数超过 14 个时追加 1 字节扩展计数
+func appendExtendedCount(buf []byte, m int) []byte {
+	if m > maxQueryParams {
+		return append(buf, byte(m))
+	}
+	return buf
+}
+
+// Param 报文中的一个参量：14bit 类型码 + 原始数据
+type Param = paramcodec.Param
+
+// DecodeParams 按规范的 LengthFlag 语义（见 paramcodec.Spec）依次解出 count 个参量，
+// 出错时返回已解出的参量和错误；按方言解析见 Dialect.ParamLengths
+func DecodeParams(data []byte, count int) ([]Param, error) {
+	params, _, err := paramcodec.Spec.Decode(data, count)
+	return params, err
+}
+
+// AppendParam 按规范格式编码一个参量并追加到 buf：4 字节数据使用默认长度（LengthFlag=0），
+// 其余按所需最少字节写长度字段
+func AppendParam(buf []byte, p Param) ([]byte, error) {
+	return paramcodec.Spec.Append(buf, p)
+}
@@ -1,136 +1,365 @@
 package frameparser
 
 import (
-	"encoding/binary"
+	"context"
 	"encoding/hex"
-	"log"
+	"errors"
+	"maps"
 	"strings"
+	"time"
 
 	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+	"github.com/linjuya-lu/device-lpmp-go/internal/correlation"
+	"github.com/linjuya-lu/device-lpmp-go/internal/serial"
+	"github.com/linjuya-lu/device-lpmp-go/internal/sessionlog"
+	"github.com/linjuya-lu/device-lpmp-go/internal/trace"
 )
 
 // StartParser 从 frameCh 通道中持续读取完整帧，启动一个后台协程进行业务数据解析。
 // 依照《Q/GDW 12184—2021》附录 D 业务报文格式，实现以下功能：
 // 1. 提取 SensorID、报文类型（仅处理业务数据：监测和告警）  控制报文与控制报文响应单独函数处理
 // 2. 根据 DataLen（4bit）、FragInd（1bit）、PacketType（3bit）判断是否处理
-// 3. 分片帧（FragInd=1）开协程处理
+// 3. 分片帧（FragInd=1）取出分片头（SSEQ/PSEQ/Flag）交给流水线的拼接器，拼接完成的 SDU 同样解析参量
 // 4. 按照参量个数逐个解析 ParamType(14bit)+LengthFlag(2bit) + 可选长度字段 + 数据
 // 5. 将数值按表大端转换为 float32/float64/int8等基本类型
-// 6. 针对已知 SensorID（如"238A08262319"水位传感器），调用 config.SetDeviceValue 存储解析结果
+// 6. 针对已知 SensorID（如"238A08262319"水位传感器），把解析结果交给 ValueSink（默认写入值表）
 // 7. 异常或格式不符时跳过本帧，确保解析循环不中断
-func StartParser(frameCh <-chan []byte) {
-	go func() {
-		for frame := range frameCh {
-			// 最小长度校验：6字节ID +1字节头 +2字节CRC
-			if len(frame) < 9 {
-				log.Println("帧长度不足，跳过解析")
-				continue
-			}
-			// CRC 校验：最后 2 字节为 CRC-16
-			payload := frame[:len(frame)-2]
-			recvCRC := binary.BigEndian.Uint16(frame[len(frame)-2:])
-			if CRC16(payload) != recvCRC {
-				log.Println("CRC 校验失败，跳过解析")
-				continue
-			}
-			// 1. 读取6字节SensorID，使用Hex字符串表示
-			sidBytes := frame[0:6]
-			sensorID := strings.ToUpper(hex.EncodeToString(sidBytes))
-			deviceName, hasDevice := config.LookupDeviceName(sensorID)
-			if !hasDevice {
-				log.Printf("未知 SensorID=%s，跳过本帧", sensorID)
-				continue
-			}
-			// 2. 读取头部：4bit DataLen、1bit FragInd、3bit PacketType
-			head := frame[6]
-			dataCount := int(head >> 4)  // 参量个数
-			fragInd := (head >> 3) & 0x1 // 分片指示
-			packetType := head & 0x07    // 报文类型
-			body := make([]byte, len(frame)-2-7)
-			copy(body, frame[7:len(frame)-2])
-			frame_ctl := FrameCtl{
-				SensorID:   sensorID,
-				DataLen:    dataCount,
-				FragInd:    fragInd,
-				PacketType: packetType,
-				Payload:    body,
-				Check:      recvCRC,
-			}
-			// 只处理业务数据报文（监测=0、告警=2）
-			if packetType != 0 && packetType != 2 {
-				if packetType == 4 || packetType == 5 {
-					handle_frame_ctl(frame_ctl)
-				}
-				continue
-			}
+// 等同于以默认配置启动一条 Pipeline，ctx 取消时退出；frameCh 由调用方所有，见 PipelineOptions.Input。
+func StartParser(ctx context.Context, frameCh <-chan serial.RxFrame) *Pipeline {
+	p := NewPipeline(PipelineOptions{Name: "default", Input: frameCh})
+	p.Start(ctx)
+	return p
+}
 
-			// 分片帧不拼接，仅打印提示并跳过
-			if fragInd == 1 {
-				log.Printf("检测到分片帧 SensorID=%s，暂不拼接，跳过解析", sensorID)
-				continue
-			}
+// handleFrame 解析一帧完整报文，trace 为该帧的追踪 ID，贯穿各阶段日志
+func (p *Pipeline) handleFrame(id trace.ID, frame []byte, received time.Time) {
+	// 未带接收时间的输入（如测试或回放工具直接构造的帧）以开始处理的时刻为准
+	if received.IsZero() {
+		received = time.Now()
+	}
+	var parseErr error
+	endParse := trace.Begin(id, trace.StageParse)
+	defer func() { endParse(nil, parseErr) }()
+	raw := frame
+	skip, reject := p.rejecter(id, raw, &parseErr)
 
-			// 3. 从第7字节开始解析参数数据，末尾2字节为CRC
-			idx := 7
-			parsed := 0
-			for parsed < dataCount {
-				// 参数头2字节
-				if idx+2 > len(frame)-2 {
-					log.Printf("参数头越界 SensorID=%s，跳过本帧", sensorID)
-					break
-				}
-				head16 := binary.LittleEndian.Uint16(frame[idx : idx+2])
-				idx += 2
-				paramType := head16 >> 2       // 14bit类型码
-				lenFlag := uint8(head16 & 0x3) // 2bit长度指示
-
-				// 计算真实数据长度
-				var dataLen uint32
-				switch lenFlag {
-				case 0:
-					dataLen = 4 // 默认4字节
-				case 1:
-					dataLen = uint32(frame[idx])
-					idx++
-				case 2:
-					dataLen = uint32(binary.BigEndian.Uint16(frame[idx : idx+2]))
-					idx += 2
-				case 3:
-					dataLen = uint32(frame[idx])<<16 | uint32(frame[idx+1])<<8 | uint32(frame[idx+2])
-					idx += 3
-				}
+	dumpRawFrame(id, frame)
+	// 最小长度校验：6字节ID +1字节头 +2字节CRC
+	if len(frame) < 9 {
+		reject(ErrTruncatedFrame, "", "帧长度不足，跳过解析")
+		return
+	}
+	// 1. 读取6字节SensorID，使用Hex字符串表示（CRC 错误时仅用于日志聚合）
+	sidBytes := frame[0:6]
+	sensorID := strings.ToUpper(hex.EncodeToString(sidBytes))
+	// 允许/拒绝列表在校验和解析之前过滤，自检回环帧不受影响
+	if sensorID != LoopbackSensorID && !acceptSensor(sensorID) {
+		debugf("[trace=%s] SensorID=%s 被允许/拒绝列表过滤", id, sensorID)
+		return
+	}
+	// CRC 校验：帧尾布局（CRC16/CRC32、字节序、附加字节）按该传感器的方言
+	dialect := dialectFor(sensorID, p.dialect)
+	recvCRC, ok := dialect.checkCRC(frame)
+	if !ok {
+		if conformanceEnabled.Load() {
+			recordConformance(sensorID, frame, []violation{{RuleCRC, "CRC 与报文内容不符"}})
+		}
+		reject(ErrCRCMismatch, sensorID, "CRC 校验失败 SensorID=%s 方言=%s，跳过解析", sensorID, dialect.Name)
+		return
+	}
+	// 剔除厂家私有头字节，之后按标准格式解析；原始帧 raw 保留给 rawFrame 资源
+	frame, err := dialect.normalize(frame)
+	if err != nil {
+		reject(ErrDialectFormat, sensorID, "SensorID=%s 方言=%s: %v，跳过解析", sensorID, dialect.Name, err)
+		return
+	}
+	// 压缩标志位于厂家私有头中，须按原始帧判断
+	compressed := dialect.isCompressed(raw)
+	// 一致性校验模式：逐帧检查结构性约束并按传感器统计，不影响后续解析
+	if conformanceEnabled.Load() && sensorID != LoopbackSensorID {
+		violations := checkConformance(frame, compressed, dialect.paramLengths())
+		recordConformance(sensorID, frame, violations)
+		for _, v := range violations {
+			forwardFailed(id, sensorID, "conformance:"+v.rule, raw, "%s", v.detail)
+		}
+	}
+	// 自检回环帧不属于任何设备
+	if sensorID == LoopbackSensorID {
+		handleLoopback(id, frame)
+		return
+	}
+	// 同一传感器可绑定到多个设备，解析结果按绑定逐个分发
+	bindings := p.cfg.LookupSensorBindings(sensorID)
+	if len(bindings) == 0 {
+		skip(ErrUnknownSensor, sensorID, "未知 SensorID=%s，跳过本帧", sensorID)
+		return
+	}
+	if !p.backfill {
+		publishSensorEvent(SensorEvent{Kind: SensorFrameAccepted, SensorID: sensorID, TraceID: id})
+	}
+	p.publishRawFrame(id, sensorID, bindings, raw, received)
+	// 2. 读取头部：4bit DataLen、1bit FragInd、3bit PacketType
+	head := frame[6]
+	dataCount := int(head >> 4)  // 参量个数
+	fragInd := (head >> 3) & 0x1 // 分片指示
+	packetType := head & 0x07    // 报文类型
+	debugf("[trace=%s] SensorID=%s DataLen=%d FragInd=%d PacketType=%d", id, sensorID, dataCount, fragInd, packetType)
+	sessionlog.Default.Record(sensorID, sessionlog.KindFrame, "[trace=%s] PacketType=%d DataLen=%d FragInd=%d 帧长=%d", id, packetType, dataCount, fragInd, len(frame))
+	body := make([]byte, len(frame)-2-7)
+	copy(body, frame[7:len(frame)-2])
+	frame_ctl := FrameCtl{
+		TraceID:    id,
+		SensorID:   sensorID,
+		DataLen:    dataCount,
+		FragInd:    fragInd,
+		PacketType: packetType,
+		Payload:    body,
+		Check:      recvCRC,
+		Received:   received,
+	}
+	// 心跳：交给驱动决定是否应答，不再解析参量
+	if isHeartbeat(packetType, dataCount) && fragInd == 0 {
+		debugf("[trace=%s] 收到心跳 SensorID=%s", id, sensorID)
+		if !p.backfill {
+			p.notifyHeartbeat(id, sensorID)
+		}
+		return
+	}
+	// 只处理业务数据报文（监测=0、告警=2）
+	if packetType != PacketTypeMonitoring && packetType != PacketTypeAlarm {
+		if !p.backfill && (packetType == packetTypeControl || packetType == packetTypeControlResp) {
+			p.handleControlFrame(frame_ctl, bindings, dialect)
+		}
+		return
+	}
 
-				// 数据越界校验
-				if idx+int(dataLen) > len(frame)-2 {
-					log.Printf("参数数据越界 SensorID=%s，跳过本帧", sensorID)
-					break
-				}
+	// 分片帧：取出分片头交给拼接器，拼接完成的 SDU 由 handleSDU 解析参量
+	if fragInd == 1 {
+		frag, err := ParseFragment(frame)
+		if err != nil {
+			reject(ErrFragmentHeader, sensorID, "%v SensorID=%s，跳过本帧", err, sensorID)
+			return
+		}
+		frag.TraceID, frag.Compressed, frag.Received = id, compressed, received
+		if err := p.reasm.Process(frag); err != nil {
+			// 丢弃原因已由拼接器计数并节流记录
+			parseErr = err
+		}
+		return
+	}
 
-				// 提取原始值字节
-				valBytes := frame[idx : idx+int(dataLen)]
-				idx += int(dataLen)
-
-				// 解析数据
-				if info, ok := config.LookupParamInfo(paramType); ok {
-					val, err := info.Parse(valBytes)
-					if err != nil {
-						log.Printf("❌ 参数 %s.%s 解析失败: %v", deviceName, info.Name, err)
-					} else {
-						// 写入运行时值表
-						config.SetDeviceValue(deviceName, info.Name, val)
-						log.Printf("✅ 写入值 %s.%s = %v %s", deviceName, info.Name, val, info.Unit)
-					}
-				} else {
-					log.Printf("未找到参数类型信息 type=0x%X", paramType)
-				}
+	// 3. 从第7字节开始解析参数数据，末尾2字节为CRC
+	if err := p.parseBusiness(id, sensorID, bindings, dialect, head, frame[7:len(frame)-2], compressed, config.QualityGood, received, reject); err != nil {
+		parseErr = err
+	}
+}
 
-				parsed++
-			}
+// rejecter 返回记录丢弃原因的 skip 和 reject，原因写入 *parseErr；raw 为诊断模式下转发的原始数据
+func (p *Pipeline) rejecter(id trace.ID, raw []byte, parseErr *error) (skip, reject func(kind error, sensorID, format string, args ...any)) {
+	// skip 记录丢弃原因：同类错误按传感器节流输出，并写入本帧的 parse Span
+	skip = func(kind error, sensorID, format string, args ...any) {
+		*parseErr = kind
+		countError(kind)
+		throttledf(kind.Error(), sensorID, "[trace=%s] "+format, append([]any{id}, args...)...)
+		sessionlog.Default.Record(sensorID, sessionlog.KindError, "[trace=%s] "+format, append([]any{id}, args...)...)
+		if !p.backfill {
+			publishSensorEvent(SensorEvent{Kind: SensorFrameRejected, SensorID: sensorID, Err: kind, TraceID: id})
+		}
+	}
+	// reject 用于 CRC/结构校验失败：除 skip 外，诊断模式下把原始帧转发给失败帧订阅者
+	reject = func(kind error, sensorID, format string, args ...any) {
+		skip(kind, sensorID, format, args...)
+		if !p.backfill {
+			forwardFailed(id, sensorID, kind.Error(), raw, format, args...)
+		}
+	}
+	return skip, reject
+}
+
+// handleSDU 解析拼接完成的 SDU：报文头、压缩标志和接收时间沿用首片，拼接中出现过重传的读数标为
+// reassembled-with-retransmit
+func (p *Pipeline) handleSDU(f *Frame) {
+	var parseErr error
+	endParse := trace.Begin(f.TraceID, trace.StageParse)
+	defer func() { endParse(map[string]any{"sseq": f.SSEQ}, parseErr) }()
+	_, reject := p.rejecter(f.TraceID, f.Data, &parseErr)
+
+	sensorID := sensorHex(f.SensorID)
+	bindings := p.cfg.LookupSensorBindings(sensorID)
+	if len(bindings) == 0 {
+		// 拼接期间设备被删除或解绑
+		debugf("[trace=%s] SensorID=%s 已无绑定，丢弃拼接完成的 SDU", f.TraceID, sensorID)
+		return
+	}
+	debugf("[trace=%s] SensorID=%s 拼接完成 SSEQ=%d SDU=%d 字节", f.TraceID, sensorID, f.SSEQ, len(f.Data))
+	if err := p.parseBusiness(f.TraceID, sensorID, bindings, dialectFor(sensorID, p.dialect), f.Head, f.Data, f.Compressed, f.Quality(), f.Received, reject); err != nil {
+		parseErr = err
+	}
+}
 
-			// 若未完全解析，跳过后续逻辑
-			if parsed < dataCount {
-				continue
+// parseBusiness 解析业务数据报文头之后的参量列表 content（未分片帧或拼接完成的 SDU），按绑定发布读数；
+// 格式错误经 reject 记录，部分参量无法解析时返回最后一种失败原因
+func (p *Pipeline) parseBusiness(id trace.ID, sensorID string, bindings []config.SensorBinding, dialect *Dialect, head byte, content []byte,
+	compressed bool, quality string, received time.Time, reject func(kind error, sensorID, format string, args ...any)) error {
+	dataCount := int(head >> 4)
+	packetType := head & 0x07
+	// DataLen=0b1111 时参量个数在报文头后的扩展计数字节中（不参与压缩）
+	dataCount, content, err := ExpandDataLen(dataCount, content)
+	if err != nil {
+		reject(ErrExtendedCountMissing, sensorID, "%v SensorID=%s，跳过本帧", err, sensorID)
+		return nil
+	}
+	// 压缩的报文内容先解压（有大小上限）
+	if compressed {
+		n := len(content)
+		if content, err = dialect.decompress(content); err != nil {
+			reject(ErrDecompressFailed, sensorID, "SensorID=%s 方言=%s: %v，跳过本帧", sensorID, dialect.Name, err)
+			return nil
+		}
+		debugf("[trace=%s] SensorID=%s %s 解压 %d → %d 字节", id, sensorID, dialect.Compression, n, len(content))
+	}
+	params, decodeErr := dialect.decodeParams(content, dataCount)
+	publishErr := p.publishParams(id, sensorID, bindings, params, dialect, quality, received)
+	// 告警报文：参量已写入值表，再交给驱动锁存（部分参量无法解析时同样通知）
+	if packetType == PacketTypeAlarm && !p.backfill {
+		p.notifyAlarm(id, sensorID, received, params, dialect)
+	}
+
+	// 若未完全解析，跳过后续逻辑
+	if decodeErr != nil {
+		kind := ErrParamDataOverflow
+		if errors.Is(decodeErr, ErrParamHeadOverflow) {
+			kind = ErrParamHeadOverflow
+		}
+		reject(kind, sensorID, "%v SensorID=%s，跳过本帧", decodeErr, sensorID)
+		return nil
+	}
+
+	// 唤醒等待该传感器监测数据的实时查询，应答内容为按参数表解码后的参量（[]ParamValue）
+	if packetType == PacketTypeMonitoring && !p.backfill {
+		key := correlation.Key{SensorID: sensorID, PacketType: packetType}
+		if correlation.Default.Waiting(key) {
+			correlation.Default.Resolve(key, correlation.Result{Payload: p.decodeValues(sensorID, params, dialect)})
+		}
+	}
+	return publishErr
+}
+
+// decodeValues 按传感器的参数表解码参量，不在参数表中或无法解析的参量保留原始数据（[]byte）
+func (p *Pipeline) decodeValues(sensorID string, params []Param, dialect *Dialect) []ParamValue {
+	out := make([]ParamValue, 0, len(params))
+	for _, param := range params {
+		pv := ParamValue{Type: param.Type, Value: append([]byte(nil), param.Data...)}
+		if info, ok := p.cfg.LookupParamInfo(sensorID, param.Type); ok {
+			if v, err := info.Parse(dialect.valueBytes(param.Data)); err == nil {
+				pv.Value = v
 			}
 		}
+		out = append(out, pv)
+	}
+	return out
+}
+
+// publishParams 按参数表解析参量，并按传感器绑定逐个写入 Sink，读数的质量标签为 quality
+// （范围校验可疑时改为 suspect），Origin 为帧的接收时间 received；
+// 有参量无法解析时返回最后一种失败原因（其余参量照常写入）
+func (p *Pipeline) publishParams(id trace.ID, sensorID string, bindings []config.SensorBinding, params []Param, dialect *Dialect,
+	quality string, received time.Time) error {
+	published := false
+	defer func() {
+		if published {
+			observeLatency(time.Since(received))
+		}
 	}()
+	var skipErr error
+	skip := func(kind error, sensorID, format string, args ...any) {
+		skipErr = kind
+		countError(kind)
+		throttledf(kind.Error(), sensorID, "[trace=%s] "+format, append([]any{id}, args...)...)
+		sessionlog.Default.Record(sensorID, sessionlog.KindError, "[trace=%s] "+format, append([]any{id}, args...)...)
+	}
+	for i, param := range params {
+		paramType := param.Type
+		debugf("[trace=%s] SensorID=%s 参数 %d/%d: type=0x%04X(feature=%03b code=0x%03X) len=%d",
+			id, sensorID, i+1, len(params), paramType, (paramType>>11)&0x07, paramType&0x7FF, len(param.Data))
+
+		// 厂家把多个子参量以 TLV 放在同一参量中：按 TLV 子表展开，每个叶子标签作为独立资源；
+		// 字节序由子表的 byteOrder 决定，不按方言整体翻转
+		if table, ok := p.cfg.LookupTLVTable(paramType); ok {
+			values, err := table.Decode(param.Data)
+			if err != nil {
+				skip(ErrTLVFormat, sensorID, "❌ 参数 %s 类型 0x%04X: %v", sensorID, paramType, err)
+			}
+			tags := map[string]string{"sensorId": sensorID, "traceId": string(id), config.QualityTag: quality}
+			for _, v := range values {
+				for _, b := range bindings {
+					published = p.publishValue(id, b, b.Prefix+v.Name, v.Value, v.Unit, nil, tags, received, skip) || published
+				}
+			}
+			continue
+		}
+
+		// 解析数据
+		info, ok := p.cfg.LookupParamInfo(sensorID, paramType)
+		if !ok {
+			skip(ErrUnknownParam, sensorID, "未找到参数类型信息 type=0x%X SensorID=%s", paramType, sensorID)
+			continue
+		}
+		val, err := info.Parse(dialect.valueBytes(param.Data))
+		if err != nil {
+			skip(ErrParamParse, sensorID, "❌ 参数 %s.%s 解析失败: %v", sensorID, info.Name, err)
+			continue
+		}
+
+		// 范围校验：reject 丢弃读数，flag 照常写入并打上 quality=suspect
+		tags := map[string]string{"sensorId": sensorID, "traceId": string(id), config.QualityTag: quality}
+		switch res, reason := p.cfg.CheckParamRange(paramType, val); res {
+		case config.RangeRejected:
+			skip(ErrParamOutOfRange, sensorID, "❌ 参数 %s.%s 超出范围，已丢弃: %s", sensorID, info.Name, reason)
+			continue
+		case config.RangeSuspect:
+			tags[config.QualityTag] = config.QualitySuspect
+			throttledf("参数范围可疑", sensorID, "⚠️ [trace=%s] 参数 %s.%s 超出范围，标记为 %s: %s", id, sensorID, info.Name, config.QualitySuspect, reason)
+		}
+
+		for _, b := range bindings {
+			// profile 中通过 parameterType 属性声明的资源优先，否则沿用参数表名称；
+			// 复合设备再加上该传感器的资源名前缀
+			resName := b.Prefix + p.cfg.ResolveResourceName(b.DeviceName, paramType, info.Name)
+			published = p.publishValue(id, b, resName, val, info.Unit, &info, tags, received, skip) || published
+		}
+	}
+	return skipErr
+}
+
+// publishValue 把一个读数按输出变换和单位写入 Sink，资源被设备的资源过滤排除或变换失败时返回 false；
+// info 非空且为状态类参量时同时发布 <资源名>_text
+func (p *Pipeline) publishValue(id trace.ID, b config.SensorBinding, resName string, val any, unit string, info *config.ParamInfo,
+	tags map[string]string, received time.Time, skip func(kind error, sensorID, format string, args ...any)) bool {
+	if !b.Accepts(resName) {
+		return false
+	}
+	// profile 中声明的输出变换（单位换算、枚举翻译），各设备可不同
+	out, err := p.cfg.TransformValue(b.DeviceName, resName, val)
+	if err != nil {
+		skip(ErrParamParse, tags["sensorId"], "❌ 参数 %s.%s 输出变换失败: %v", b.DeviceName, resName, err)
+		return false
+	}
+	// 交给 Sink（值表、转发、归档等），能识别单位时带上 UCUM 单位标签
+	resTags := tags
+	if u, ok := p.cfg.ResourceUnit(b.DeviceName, resName, unit); ok {
+		resTags = maps.Clone(tags)
+		resTags[config.UnitTag] = u.UCUM
+	}
+	endPublish := trace.Begin(id, trace.StagePublish)
+	p.sink.SetValue(b.DeviceName, resName, out, received, resTags)
+	infof("✅ [trace=%s] 写入值 %s.%s = %v %s", id, b.DeviceName, resName, out, unit)
+	// 状态类参量：Profile 定义了 <资源名>_text 时按参数表的枚举同时发布文字
+	if info != nil {
+		if enum, ok := info.Enum(); ok && p.cfg.HasResource(b.DeviceName, resName+config.EnumTextSuffix) {
+			p.sink.SetValue(b.DeviceName, resName+config.EnumTextSuffix, config.EnumText(enum, val), received, tags)
+		}
+	}
+	endPublish(map[string]any{"device": b.DeviceName, "resource": resName}, nil)
+	return true
 }
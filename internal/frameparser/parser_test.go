@@ -0,0 +1,81 @@
+package frameparser
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/trace"
+)
+
+// benchFrame 构造一帧带 n 个水位参量的监测数据报文
+func benchFrame(b *testing.B, n int) []byte {
+	b.Helper()
+	params := make([]ParamValue, n)
+	for i := range params {
+		params[i] = ParamValue{Type: waterLevelType, Value: float32(i)}
+	}
+	frame, err := BuildBusinessFrame([6]byte{0x23, 0x8A, 0x08, 0x21, 0xBE, 0x10}, PacketTypeMonitoring, params)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return frame
+}
+
+// quietLogs 基准测试期间关闭 INFO 日志并丢弃标准库日志（参数表的解析函数直接输出），
+// 避免每帧的输出计入耗时
+func quietLogs(b *testing.B) {
+	prev, out := LogLevel(logLevel.Load()), log.Writer()
+	SetLogLevel(LevelWarn)
+	log.SetOutput(io.Discard)
+	b.Cleanup(func() {
+		SetLogLevel(prev)
+		log.SetOutput(out)
+	})
+}
+
+func BenchmarkDecodeParams(b *testing.B) {
+	quietLogs(b)
+	frame := benchFrame(b, 8)
+	payload := frame[7 : len(frame)-2]
+	b.ReportAllocs()
+	b.SetBytes(int64(len(payload)))
+	for i := 0; i < b.N; i++ {
+		params, err := DecodeParams(payload, 8)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, p := range params {
+			info, ok := PackageConfig{}.LookupParamInfo("238A0821BE10", p.Type)
+			if !ok {
+				b.Fatalf("未找到 0x%04X", p.Type)
+			}
+			if _, err := info.Parse(p.Data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkHandleFrame(b *testing.B) {
+	for _, n := range []int{1, 8, 20} {
+		b.Run(fmt.Sprintf("params=%d", n), func(b *testing.B) {
+			quietLogs(b)
+			frame := benchFrame(b, n)
+			p := NewPipeline(PipelineOptions{
+				Name:   b.Name(),
+				Config: testConfig{device: "dev"},
+				Sink:   ValueSinkFunc(func(string, string, any, time.Time, map[string]string) {}),
+			})
+			id, received := trace.New(), time.Now()
+			b.ReportAllocs()
+			b.SetBytes(int64(len(frame)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				p.handleFrame(id, frame, received)
+			}
+		})
+	}
+}
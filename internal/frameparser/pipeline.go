@@ -0,0 +1,219 @@
+package frameparser
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+	"github.com/linjuya-lu/device-lpmp-go/internal/serial"
+)
+
+// Logger 解析流水线的日志输出，*log.Logger 或适配后的 EdgeX LoggingClient 均可
+type Logger interface {
+	Printf(format string, args ...any)
+}
+
+// ConfigAccessor 解析时查询的设备配置：传感器绑定、参数表（按传感器选用的版本）、TLV 子表、取值范围、资源定义、资源名映射、输出变换和单位
+type ConfigAccessor interface {
+	LookupSensorBindings(sensorID string) []config.SensorBinding
+	LookupParamInfo(sensorID string, paramType uint16) (config.ParamInfo, bool)
+	LookupTLVTable(paramType uint16) (*config.TLVTable, bool)
+	CheckParamRange(paramType uint16, value any) (config.RangeResult, string)
+	ResolveResourceName(deviceName string, paramType uint16, fallback string) string
+	HasResource(deviceName, resourceName string) bool
+	TransformValue(deviceName, resourceName string, value any) (any, error)
+	ResourceUnit(deviceName, resourceName, fallback string) (config.Unit, bool)
+}
+
+// PackageConfig 直接使用 config 包全局表的 ConfigAccessor
+type PackageConfig struct{}
+
+func (PackageConfig) LookupSensorBindings(sensorID string) []config.SensorBinding {
+	return config.LookupSensorBindings(sensorID)
+}
+
+func (PackageConfig) LookupParamInfo(sensorID string, paramType uint16) (config.ParamInfo, bool) {
+	return config.LookupSensorParamInfo(sensorID, paramType)
+}
+
+func (PackageConfig) LookupTLVTable(paramType uint16) (*config.TLVTable, bool) {
+	return config.LookupTLVTable(paramType)
+}
+
+func (PackageConfig) CheckParamRange(paramType uint16, value any) (config.RangeResult, string) {
+	return config.CheckParamRange(paramType, value)
+}
+
+func (PackageConfig) ResolveResourceName(deviceName string, paramType uint16, fallback string) string {
+	return config.ResolveResourceName(deviceName, paramType, fallback)
+}
+
+func (PackageConfig) HasResource(deviceName, resourceName string) bool {
+	return config.HasDeviceResource(deviceName, resourceName)
+}
+
+func (PackageConfig) TransformValue(deviceName, resourceName string, value any) (any, error) {
+	return config.TransformValue(deviceName, resourceName, value)
+}
+
+func (PackageConfig) ResourceUnit(deviceName, resourceName, fallback string) (config.Unit, bool) {
+	return config.ResourceUnit(deviceName, resourceName, fallback)
+}
+
+// PipelineOptions 构造解析流水线的参数，除 Input 外零值使用默认实现
+type PipelineOptions struct {
+	// Name 流水线名称（如链路名），用于日志
+	Name string
+	// Input 完整帧输入，由生产者（如 DRX 监听）所有。流水线以 ctx 取消或 Stop 退出，不依赖 Input 关闭；
+	// 生产者关闭 Input 时流水线同样退出，但关闭后不得再有生产者写入
+	Input <-chan serial.RxFrame
+	// Config 设备配置，默认 PackageConfig
+	Config ConfigAccessor
+	// Sink 接收解析结果，默认 DefaultSink（值表 + AddValueHandler 订阅者）
+	Sink ValueSink
+	// Logger 流水线自身的日志，默认标准库 log
+	Logger Logger
+	// Backfill 回补历史数据（如回放抓包文件）：只把读数交给 Sink，不应答心跳、不锁存告警、不处理控制报文，
+	// 也不发布传感器事件和失败帧，避免旧帧影响当前的设备状态
+	Backfill bool
+	// Reassembly 本流水线分片拼接器的参数，零值使用包级默认值
+	Reassembly ReassemblerOptions
+	// Dialect 未单独指定方言（SetSensorDialect）的传感器使用的方言，nil 表示使用 SetDefaultDialect 设置的值
+	Dialect *Dialect
+	// Heartbeat 心跳回调，nil 表示使用 SetHeartbeatHandler 设置的值
+	Heartbeat HeartbeatHandler
+	// Alarm 告警回调，nil 表示使用 SetAlarmHandler 设置的值
+	Alarm AlarmHandler
+}
+
+// Pipeline 一条链路的解析流水线：从 Input 读取完整帧，分片帧经本流水线的拼接器拼接，解析结果交给 Sink。
+// 拼接完成的 SDU 经分发器交给解析协程，其它消费者用 SubscribeFrames 订阅
+type Pipeline struct {
+	name  string
+	input <-chan serial.RxFrame
+	cfg   ConfigAccessor
+	sink  ValueSink
+	log   Logger
+	// backfill、dialect 见 PipelineOptions
+	backfill bool
+	dialect  *Dialect
+	// heartbeat、alarm 见 PipelineOptions
+	heartbeat HeartbeatHandler
+	alarm     AlarmHandler
+	// reasm 拼接本链路的分片帧，只在解析协程中调用 Process
+	reasm *Reassembler
+	// frames 分发 reasm 输出的 SDU，解析协程是其中一个阻塞订阅者
+	frames *FrameFanout
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewPipeline 创建解析流水线，需调用 Start 启动
+func NewPipeline(opts PipelineOptions) *Pipeline {
+	p := &Pipeline{
+		name:  opts.Name,
+		input: opts.Input,
+		cfg:   opts.Config,
+		sink:  opts.Sink,
+		log:   opts.Logger,
+
+		backfill:  opts.Backfill,
+		dialect:   opts.Dialect,
+		heartbeat: opts.Heartbeat,
+		alarm:     opts.Alarm,
+		reasm:     NewReassembler(opts.Reassembly),
+	}
+	p.frames = NewFrameFanout(p.reasm.Output())
+	if p.cfg == nil {
+		p.cfg = PackageConfig{}
+	}
+	if p.sink == nil {
+		p.sink = DefaultSink
+	}
+	if p.log == nil {
+		p.log = log.Default()
+	}
+	return p
+}
+
+// Start 启动后台解析协程，ctx 取消、调用 Stop 或 Input 关闭时退出（Input 关闭时先解析完已拼接的 SDU）；
+// 重复调用无效
+func (p *Pipeline) Start(ctx context.Context) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.done != nil {
+		return
+	}
+	ctx, p.cancel = context.WithCancel(ctx)
+	p.done = make(chan struct{})
+	sdus := p.frames.Subscribe(p.name+"/parse", FrameSubscribeOptions{}).Frames()
+	go func() {
+		defer close(p.done)
+		for {
+			// 先解析已拼接完成的 SDU，再读取新帧
+			select {
+			case f := <-sdus:
+				p.handleSDU(f)
+				continue
+			default:
+			}
+			select {
+			case <-ctx.Done():
+				// 退出后不再调用 Process，关闭拼接器输出，分发器随之关闭全部订阅
+				close(p.reasm.out)
+				return
+			case f := <-sdus:
+				p.handleSDU(f)
+			case rx, ok := <-p.input:
+				if !ok {
+					p.log.Printf("解析流水线 %s 输入已关闭，退出", p.name)
+					// 输入已读完：解析完分发器中剩余的 SDU 再退出
+					close(p.reasm.out)
+					for f := range sdus {
+						p.handleSDU(f)
+					}
+					return
+				}
+				p.handleFrame(rx.TraceID, rx.Data, rx.Received)
+			}
+		}
+	}()
+}
+
+// Reassembler 返回本流水线的分片拼接器，可用 OnComplete 观察拼接完成的 SDU；Process 只由流水线调用
+func (p *Pipeline) Reassembler() *Reassembler {
+	return p.reasm
+}
+
+// SubscribeFrames 订阅本流水线拼接完成的 SDU（归档、指标、抓包等旁路消费者），流水线退出后通道关闭。
+// 各订阅者与解析协程收到同一个 *Frame，只读；阻塞订阅者处理慢时会拖慢解析
+func (p *Pipeline) SubscribeFrames(name string, opts FrameSubscribeOptions) *FrameSubscription {
+	return p.frames.Subscribe(name, opts)
+}
+
+// UnsubscribeFrames 取消 SubscribeFrames 的订阅
+func (p *Pipeline) UnsubscribeFrames(s *FrameSubscription) {
+	p.frames.Unsubscribe(s)
+}
+
+// Done 返回流水线退出时关闭的通道，Start 之前为 nil
+func (p *Pipeline) Done() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.done
+}
+
+// Stop 停止解析协程并等待当前帧处理完毕
+func (p *Pipeline) Stop() {
+	p.mu.Lock()
+	cancel, done := p.cancel, p.done
+	p.mu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
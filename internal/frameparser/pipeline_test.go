@@ -0,0 +1,98 @@
+package frameparser
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/serial"
+)
+
+// drxLine 构造串口模块输出的一行 "+DRX:<SensorID>,<长度>,<十六进制报文>\r\n"
+// （同 simulator.DRXLine，simulator 依赖本包，测试中不能引用）
+func drxLine(frame []byte) []byte {
+	return fmt.Appendf(nil, "+DRX:%X,%d,%X\r\n", frame[:6], len(frame), frame)
+}
+
+// benchmarkPipeline 经 模拟串口 → DRX 监听 → 分片拼接 → 报文解析 → Sink 逐条处理 b.N 条监测报文：
+// 每条报文写入后等到 Sink 收到读数再写下一条，ns/op 即单条报文的端到端时延，另报告 P99。
+// fragments 大于 1 时每条报文切成分片帧写入，时延从第一个分片写入开始计时
+func benchmarkPipeline(b *testing.B, fragments int) {
+	quietLogs(b)
+	id := [6]byte{0x23, 0x8A, 0x08, 0x21, 0xBE, byte(0x20 + fragments)}
+	params := []ParamValue{{Type: 0x0001, Value: float32(1.5)}} // "长度"，float32
+	// 预先生成流量：分片报文轮流使用各个 SSEQ
+	traffic := [][]byte{drxLines(b, id, params, 1, 0)}
+	if fragments > 1 {
+		traffic = make([][]byte, 64) // SSEQ 6bit
+		for sseq := range traffic {
+			traffic[sseq] = drxLines(b, id, params, fragments, uint8(sseq))
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r, w := io.Pipe()
+	defer w.Close()
+	frameCh := make(chan serial.RxFrame, 100)
+	serial.ListenDRX(ctx, r, frameCh)
+	got := make(chan struct{}, 1)
+	p := NewPipeline(PipelineOptions{
+		Name:   b.Name(),
+		Input:  frameCh,
+		Config: testConfig{device: "dev"},
+		Sink: ValueSinkFunc(func(string, string, any, time.Time, map[string]string) {
+			got <- struct{}{}
+		}),
+	})
+	p.Start(ctx)
+	defer p.Stop()
+
+	latencies := make([]time.Duration, 0, b.N)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		start := time.Now()
+		if _, err := w.Write(traffic[i%len(traffic)]); err != nil {
+			b.Fatal(err)
+		}
+		select {
+		case <-got:
+		case <-time.After(5 * time.Second):
+			b.Fatalf("第 %d 条报文未输出读数", i)
+		}
+		latencies = append(latencies, time.Since(start))
+	}
+	b.StopTimer()
+	slices.Sort(latencies)
+	b.ReportMetric(float64(latencies[(len(latencies)*99+99)/100-1].Nanoseconds()), "p99-ns/frame")
+}
+
+// drxLines 构造一条监测报文的 +DRX 行，fragments 大于 1 时为各分片帧的 +DRX 行
+func drxLines(b *testing.B, id [6]byte, params []ParamValue, fragments int, sseq uint8) []byte {
+	b.Helper()
+	if fragments <= 1 {
+		frame, err := BuildBusinessFrame(id, PacketTypeMonitoring, params)
+		if err != nil {
+			b.Fatal(err)
+		}
+		return drxLine(frame)
+	}
+	frames, err := BuildFragmentFrames(id, PacketTypeMonitoring, sseq, params, fragments)
+	if err != nil {
+		b.Fatal(err)
+	}
+	var lines []byte
+	for _, f := range frames {
+		lines = append(lines, drxLine(f)...)
+	}
+	return lines
+}
+
+func BenchmarkPipeline(b *testing.B) {
+	b.Run("unfragmented", func(b *testing.B) { benchmarkPipeline(b, 1) })
+	b.Run("fragments=3", func(b *testing.B) { benchmarkPipeline(b, 3) })
+}
@@ -0,0 +1,138 @@
+package frameparser
+
+import (
+	"time"
+)
+
+// DefaultPreFirstWindow 首片之前到达的中间/尾片的默认暂存时长
+const DefaultPreFirstWindow = 2 * time.Second
+
+// 包级首片前暂存时长，通过 SetPreFirstWindow 修改
+var preFirstWindow = DefaultPreFirstWindow
+
+// earlySDU 某传感器某 SSEQ 在首片到达之前收到的片段，按 PSEQ 保存
+type earlySDU struct {
+	frags       map[uint8]*Frame
+	arrival     map[uint8]uint64 // 各片段的到达次序，超出暂存上限时先回收最早的
+	retransmits int              // 暂存期间收到同一 PSEQ 重复片段的次数
+	timer       Timer
+}
+
+// preFirstWindow 返回生效的首片前暂存时长，0 表示不暂存
+func (r *Reassembler) preFirstWindow() time.Duration {
+	if r.opts.PreFirstWindow != 0 {
+		return max(r.opts.PreFirstWindow, 0)
+	}
+	return currentPreFirstWindow()
+}
+
+// stashEarly 暂存首片之前到达的中间/尾片，等待同一 SSEQ 的首片；暂存的片段与乱序缓存一样计入
+// 单个 SDU 的上限和 OutOfOrderBudget，超出单个 SDU 的上限时回收该 SSEQ 最早暂存的片段。
+// 未启用暂存时按 reason 丢弃（调用方持有 r.mu）
+func (r *Reassembler) stashEarly(frame *Frame, reason DropReason) error {
+	window := r.preFirstWindow()
+	if window <= 0 {
+		return dropFrame(frame, reason)
+	}
+	bySSEQ := r.early[frame.SensorID]
+	if bySSEQ == nil {
+		bySSEQ = make(map[uint8]*earlySDU)
+		r.early[frame.SensorID] = bySSEQ
+	}
+	e, ok := bySSEQ[frame.SSEQ]
+	if !ok {
+		e = &earlySDU{frags: make(map[uint8]*Frame), arrival: make(map[uint8]uint64)}
+		bySSEQ[frame.SSEQ] = e
+		// 窗口从该 SSEQ 第一个暂存片段开始计算，到期仍未等到首片则整体丢弃
+		clk, _ := r.clockAndTimeout()
+		sensorID, sseq := frame.SensorID, frame.SSEQ
+		e.timer = clk.AfterFunc(window, func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if r.early[sensorID][sseq] == e {
+				r.removeEarly(sensorID, sseq, e)
+				for _, f := range e.frags {
+					dropFrame(f, DropPreFirstExpired)
+				}
+			}
+		})
+	}
+	e.arrival[frame.PSEQ] = arrivalSeq.Add(1)
+	if _, dup := e.frags[frame.PSEQ]; dup {
+		e.retransmits++
+		e.frags[frame.PSEQ] = frame
+		return nil
+	}
+	e.frags[frame.PSEQ] = frame
+	r.hold(1)
+	for len(e.frags) > r.maxOutOfOrderPerSDU() {
+		r.evictEarly(frame.SensorID, e, oldestEarly(e))
+	}
+	return nil
+}
+
+// oldestEarly 返回最早暂存的片段序号，暂存非空
+func oldestEarly(e *earlySDU) uint8 {
+	var (
+		oldest uint8
+		at     uint64
+		found  bool
+	)
+	for seq := range e.frags {
+		if a := e.arrival[seq]; !found || a < at {
+			oldest, at, found = seq, a, true
+		}
+	}
+	return oldest
+}
+
+// evictEarly 因超出上限回收一个首片前暂存的片段并计数，暂存清空时一并删除该 SSEQ 的记录（调用方持有 r.mu）
+func (r *Reassembler) evictEarly(sensorID [6]byte, e *earlySDU, pseq uint8) {
+	f, ok := e.frags[pseq]
+	if !ok {
+		return
+	}
+	delete(e.frags, pseq)
+	delete(e.arrival, pseq)
+	r.hold(-1)
+	dropFrame(f, DropOutOfOrderEvicted)
+	if len(e.frags) == 0 {
+		if e.timer != nil {
+			e.timer.Stop()
+		}
+		r.removeEarly(sensorID, f.SSEQ, e)
+	}
+}
+
+// mergeEarly 把暂存的同一 SSEQ 片段并入新建的缓存：相对期望序号超前的放入乱序缓存，
+// 其余（落在起点之前）无法定位，丢弃（调用方持有 r.mu）
+func (r *Reassembler) mergeEarly(sensorID [6]byte, c *SDUCache) {
+	e, ok := r.early[sensorID][c.SSEQ]
+	if !ok {
+		return
+	}
+	r.removeEarly(sensorID, c.SSEQ, e)
+	if e.timer != nil {
+		e.timer.Stop()
+	}
+	c.retransmits += e.retransmits
+	for pseq, f := range e.frags {
+		if ahead := pseqAhead(c.expectedSeq, pseq); ahead == 0 || ahead >= pseqWindow {
+			dropFrame(f, DropNoFirstFragment)
+			continue
+		}
+		r.bufferOutOfOrder(sensorID, c, pseq, f.Data)
+		if isFlagLast(f.Flag) {
+			c.finalSeq, c.haveFinal = pseq, true
+		}
+	}
+}
+
+// removeEarly 删除某 SSEQ 的暂存记录 e 并归还其片段计数（调用方持有 r.mu）
+func (r *Reassembler) removeEarly(sensorID [6]byte, sseq uint8, e *earlySDU) {
+	r.hold(-len(e.frags))
+	delete(r.early[sensorID], sseq)
+	if len(r.early[sensorID]) == 0 {
+		delete(r.early, sensorID)
+	}
+}
@@ -0,0 +1,73 @@
+package frameparser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/trace"
+)
+
+// TestPreFirstMerge 起点序号自动时先到的中间片和尾片暂存，首片到达后并入拼接；
+// 正在拼接其它 SSEQ 时到达的片段同样暂存
+func TestPreFirstMerge(t *testing.T) {
+	got := make(map[string]any)
+	p := NewPipeline(PipelineOptions{Name: "test",
+		Reassembly: ReassemblerOptions{PSEQStart: &PSEQStart{Auto: true}},
+		Sink: ValueSinkFunc(func(_, resourceName string, value any, _ time.Time, _ map[string]string) {
+			got[resourceName] = value
+		})})
+
+	for _, frame := range [][]byte{fragMiddle, fragLast} {
+		feed(p, trace.ID("prefirst-test"), frame, time.Time{})
+	}
+	if len(got) != 0 {
+		t.Fatalf("首片未到已输出读数 %v", got)
+	}
+	feed(p, trace.ID("prefirst-test"), fragFirst, time.Time{})
+	if got["长度"] != float32(2.5) || got["温度"] != float32(21.5) {
+		t.Errorf("首片到达后读数 %v，期望 长度=2.5 温度=21.5", got)
+	}
+
+	// SSEQ=5 拼接中收到 SSEQ=6 的尾片，SSEQ=6 首片到达后与暂存的尾片拼接
+	clear(got)
+	feed(p, trace.ID("prefirst-test"), fragFirst, time.Time{})
+	feed(p, trace.ID("prefirst-test"), sealed("238A0821BEF2"+"08"+"1B01"+"20401400"+"0000AC41"), time.Time{})
+	feed(p, trace.ID("prefirst-test"), sealed("238A0821BEF2"+"28"+"1800"+"04000000"), time.Time{})
+	if got["长度"] != float32(2.5) || got["温度"] != float32(21.5) {
+		t.Errorf("SSEQ=6 读数 %v，期望 长度=2.5 温度=21.5", got)
+	}
+}
+
+// TestPreFirstExpired 暂存窗口内未等到首片的片段按 pre-first-expired 丢弃
+func TestPreFirstExpired(t *testing.T) {
+	clock := NewManualClock()
+	p := NewPipeline(PipelineOptions{Name: "test", Reassembly: ReassemblerOptions{
+		PSEQStart: &PSEQStart{Auto: true}, PreFirstWindow: 2 * time.Second, Clock: clock}})
+	events := SubscribeReassembly(16)
+	before := DropCounts()
+
+	feed(p, trace.ID("prefirst-test"), fragMiddle, time.Time{})
+	feed(p, trace.ID("prefirst-test"), fragLast, time.Time{})
+	if clock.Pending() != 1 {
+		t.Fatalf("暂存后定时器个数 %d，期望 1", clock.Pending())
+	}
+	clock.Advance(2 * time.Second)
+	dropped := 0
+	for dropped < 2 {
+		select {
+		case ev := <-events:
+			if ev.Kind != ReassemblyDropped {
+				continue
+			}
+			if ev.Reason != DropPreFirstExpired || ev.SSEQ != 5 {
+				t.Errorf("Dropped 事件 %+v，期望 %s SSEQ=5", ev, DropPreFirstExpired)
+			}
+			dropped++
+		case <-time.After(time.Second):
+			t.Fatalf("收到 %d 个 pre-first-expired 事件，期望 2", dropped)
+		}
+	}
+	if n := DropCounts()[DropPreFirstExpired] - before[DropPreFirstExpired]; n != 2 {
+		t.Errorf("%s 计数增加 %d，期望 2", DropPreFirstExpired, n)
+	}
+}
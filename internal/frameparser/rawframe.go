@@ -0,0 +1,29 @@
+package frameparser
+
+import (
+	"time"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+	"github.com/linjuya-lu/device-lpmp-go/internal/trace"
+)
+
+// RawFrameResource 设备上可选的原始帧资源（Binary）：Profile 定义了该资源的设备，
+// 每收到其传感器的一帧（CRC 通过，含方言私有头和 CRC）就写入一次，供应用服务自行解码或归档
+const RawFrameResource = "rawFrame"
+
+// publishRawFrame 将原始帧交给 Sink，复合设备的资源名带该传感器的前缀
+func (p *Pipeline) publishRawFrame(id trace.ID, sensorID string, bindings []config.SensorBinding, raw []byte, origin time.Time) {
+	var data []byte
+	tags := map[string]string{"sensorId": sensorID, "traceId": string(id)}
+	for _, b := range bindings {
+		resName := b.Prefix + RawFrameResource
+		if !p.cfg.HasResource(b.DeviceName, resName) || !b.Accepts(resName) {
+			continue
+		}
+		// 只复制一次，各设备共享同一份只读数据
+		if data == nil {
+			data = append([]byte(nil), raw...)
+		}
+		p.sink.SetValue(b.DeviceName, resName, data, origin, tags)
+	}
+}
@@ -0,0 +1,94 @@
+package frameparser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/trace"
+)
+
+// TestReassemblerIndependent 独立的拼接器与包级默认拼接器互不影响，各自按自己的超时丢弃
+func TestReassemblerIndependent(t *testing.T) {
+	clock := NewManualClock()
+	r := NewReassembler(ReassemblerOptions{Timeout: time.Second, Clock: clock, OutputBuffer: 1})
+	frags := make([]*Frame, 0, 3)
+	for _, b := range [][]byte{fragFirst, fragMiddle, fragLast} {
+		f, err := ParseFragment(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		frags = append(frags, f)
+	}
+
+	// 首片进入 r，中间片和尾片送入默认拼接器，两边都无法完成
+	if err := r.Process(frags[0]); err != nil {
+		t.Fatalf("Process(首片): %v", err)
+	}
+	for _, f := range frags[1:] {
+		if err := ProcessFrame(f); err != nil {
+			t.Fatalf("ProcessFrame(PSEQ=%d): %v", f.PSEQ, err)
+		}
+	}
+	select {
+	case out := <-r.Output():
+		t.Fatalf("拼接器用默认拼接器的片段输出了 %+v", out)
+	case out := <-FrameCh:
+		t.Fatalf("默认拼接器用 r 的首片输出了 %+v", out)
+	default:
+	}
+	for _, f := range frags[1:] {
+		if err := r.Process(f); err != nil {
+			t.Fatalf("Process(PSEQ=%d): %v", f.PSEQ, err)
+		}
+	}
+	select {
+	case out := <-r.Output():
+		if out.FragInd != 0 || out.Head != 0x20 || len(out.Data) != 12 {
+			t.Errorf("完整帧 FragInd=%d Head=0x%02X %d 字节，期望 0/0x20/12", out.FragInd, out.Head, len(out.Data))
+		}
+	default:
+		t.Fatal("拼接器未输出完整帧")
+	}
+
+	// 只有首片的拼接按 r 自己的 1 秒超时丢弃，之后的中间片和尾片等待新的首片
+	if err := r.Process(frags[0]); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Second)
+	for _, f := range frags[1:] {
+		if err := r.Process(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case out := <-r.Output():
+		t.Errorf("超时后没有首片仍输出了 %+v", out)
+	default:
+	}
+}
+
+// TestOnCompleteStats 解析入口拼接完成时回调收到 SDU 及其乱序、重传和耗时统计
+func TestOnCompleteStats(t *testing.T) {
+	clock := NewManualClock()
+	p := NewPipeline(PipelineOptions{Name: "test", Reassembly: ReassemblerOptions{Clock: clock}})
+	var got []SDUStats
+	p.Reassembler().OnComplete(func(sensorID [6]byte, sseq uint8, sdu []byte, stats SDUStats) {
+		if sseq != 5 || len(sdu) != 12 {
+			t.Errorf("回调 SSEQ=%d %d 字节，期望 5/12", sseq, len(sdu))
+		}
+		got = append(got, stats)
+	})
+
+	p.handleFrame(trace.ID("stats-test"), fragFirst, time.Time{})
+	p.handleFrame(trace.ID("stats-test"), fragLast, time.Time{})
+	p.handleFrame(trace.ID("stats-test"), fragLast, time.Time{})
+	clock.Advance(3 * time.Second)
+	p.handleFrame(trace.ID("stats-test"), fragMiddle, time.Time{})
+	if len(got) != 1 {
+		t.Fatalf("回调 %d 次，期望 1", len(got))
+	}
+	s := got[0]
+	if s.Fragments != 3 || s.Bytes != 12 || s.OutOfOrder != 1 || s.Retransmissions != 1 || s.Duration != 3*time.Second {
+		t.Errorf("统计 %+v，期望 3 片 12 字节 乱序 1 重传 1 耗时 3s", s)
+	}
+}
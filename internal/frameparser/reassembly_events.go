@@ -0,0 +1,149 @@
+package frameparser
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/trace"
+)
+
+// DropReason 分片帧或未完成 SDU 被丢弃的原因
+type DropReason string
+
+const (
+	// 当前分片帧被丢弃
+	DropNoFirstFragment   DropReason = "no-first-fragment"  // 没有进行中的拼接且不是首片
+	DropForeignSSEQ       DropReason = "foreign-sseq"       // 不属于进行中的业务单元且不是首片
+	DropDuplicateFragment DropReason = "duplicate-fragment" // 序号小于期望值，重复或过期
+	DropBadFirstPSEQ      DropReason = "bad-first-pseq"     // 首片序号与约定的起点不符
+	DropPreFirstExpired   DropReason = "pre-first-expired"  // 首片之前到达的片段暂存超时，未等到首片
+	// 已暂存的乱序片段被丢弃
+	DropOutOfOrderEvicted DropReason = "out-of-order-evicted" // 超出单个 SDU 或 OutOfOrderBudget 的暂存上限，最早暂存的乱序或首片前片段被回收
+	// 进行中的未完成 SDU 被丢弃
+	DropReplaced  DropReason = "replaced"  // 被新业务单元的首片替换
+	DropRestarted DropReason = "restarted" // 收到同一业务单元的重复首片，重新拼接
+	DropTimeout   DropReason = "timeout"   // 拼接超时
+	// 已拼接完成的 SDU 被丢弃
+	DropDuplicateSDU DropReason = "duplicate-sdu" // 同一 SSEQ 在去重窗口内已拼接完成过（空口重传或重放），见 SetSSEQFilter
+)
+
+// DropError 为 ProcessFrame 丢弃当前分片帧时返回的错误
+type DropError struct {
+	SensorID [6]byte
+	SSEQ     uint8
+	PSEQ     uint8
+	Reason   DropReason
+}
+
+func (e *DropError) Error() string {
+	return fmt.Sprintf("丢弃分片帧 SensorID=%s SSEQ=%d PSEQ=%d: %s", sensorHex(e.SensorID), e.SSEQ, e.PSEQ, e.Reason)
+}
+
+// ReassemblyEventKind 拼接过程中的事件类型
+type ReassemblyEventKind string
+
+const (
+	ReassemblyStarted   ReassemblyEventKind = "started"   // 收到首片，开始拼接
+	ReassemblyCompleted ReassemblyEventKind = "completed" // SDU 拼接完成并已输出
+	ReassemblyDropped   ReassemblyEventKind = "dropped"   // 分片帧或未完成的 SDU 被丢弃
+)
+
+// ReassemblyEvent 供测试、指标等观察者消费的拼接事件
+type ReassemblyEvent struct {
+	Kind     ReassemblyEventKind
+	SensorID [6]byte
+	SSEQ     uint8
+	PSEQ     uint8
+	Reason   DropReason // 仅 Dropped 事件有效
+	Bytes    int        // Completed 为 SDU 长度，Dropped 为已丢弃的数据长度
+	TraceID  trace.ID
+	// Retransmit 仅 Completed 事件有效：拼接过程中收到过重传
+	Retransmit bool
+}
+
+var (
+	observersMu sync.RWMutex
+	observers   []chan ReassemblyEvent
+
+	dropCountsMu sync.Mutex
+	dropCounts   = make(map[DropReason]uint64)
+)
+
+// SubscribeReassembly 订阅拼接事件，buf 为通道缓冲；观察者处理不及时时事件被丢弃，不阻塞拼接
+func SubscribeReassembly(buf int) <-chan ReassemblyEvent {
+	ch := make(chan ReassemblyEvent, buf)
+	observersMu.Lock()
+	observers = append(observers, ch)
+	observersMu.Unlock()
+	return ch
+}
+
+// DropCounts 返回启动以来各丢弃原因的次数
+func DropCounts() map[DropReason]uint64 {
+	dropCountsMu.Lock()
+	defer dropCountsMu.Unlock()
+	out := make(map[DropReason]uint64, len(dropCounts))
+	for r, n := range dropCounts {
+		out[r] = n
+	}
+	return out
+}
+
+// publishReassembly 非阻塞地把事件发给所有观察者
+func publishReassembly(ev ReassemblyEvent) {
+	observersMu.RLock()
+	defer observersMu.RUnlock()
+	for _, ch := range observers {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// recordDrop 计数、按原因节流记录日志并发布 Dropped 事件
+func recordDrop(ev ReassemblyEvent) {
+	ev.Kind = ReassemblyDropped
+	dropCountsMu.Lock()
+	dropCounts[ev.Reason]++
+	dropCountsMu.Unlock()
+	sid := sensorHex(ev.SensorID)
+	throttledf("分片丢弃:"+string(ev.Reason), sid, "[trace=%s] 丢弃分片数据 SensorID=%s SSEQ=%d PSEQ=%d 原因=%s 字节=%d",
+		ev.TraceID, sid, ev.SSEQ, ev.PSEQ, ev.Reason, ev.Bytes)
+	publishReassembly(ev)
+}
+
+// dropFrame 丢弃当前分片帧并返回对应的 DropError
+func dropFrame(frame *Frame, reason DropReason) error {
+	recordDrop(ReassemblyEvent{
+		SensorID: frame.SensorID,
+		SSEQ:     frame.SSEQ,
+		PSEQ:     frame.PSEQ,
+		Reason:   reason,
+		Bytes:    len(frame.Data),
+		TraceID:  frame.TraceID,
+	})
+	return &DropError{SensorID: frame.SensorID, SSEQ: frame.SSEQ, PSEQ: frame.PSEQ, Reason: reason}
+}
+
+// dropCache 丢弃未完成的 SDU：结束拼接 Span、导出已收到的数据并记录原因（调用方持有拼接器锁）
+func (r *Reassembler) dropCache(sensorID [6]byte, cache *SDUCache, reason DropReason, spanErr error) {
+	cache.endSpan(nil, spanErr)
+	r.emitSDU(sensorID, cache, false)
+	r.releaseOutOfOrder(cache)
+	recordDrop(ReassemblyEvent{
+		SensorID: sensorID,
+		SSEQ:     cache.SSEQ,
+		PSEQ:     cache.expectedSeq,
+		Reason:   reason,
+		Bytes:    len(cache.dataBuffer),
+		TraceID:  cache.traceID,
+	})
+}
+
+// sensorHex 把 6 字节 SensorID 转为大写十六进制
+func sensorHex(id [6]byte) string {
+	return strings.ToUpper(hex.EncodeToString(id[:]))
+}
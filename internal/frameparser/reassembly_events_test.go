@@ -0,0 +1,61 @@
+package frameparser
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/trace"
+)
+
+// TestLiveFragmentDrops 解析入口收到的无法拼接的分片帧按原因计数并发布 Dropped 事件
+func TestLiveFragmentDrops(t *testing.T) {
+	// 起点序号自动且不暂存时，首片之前的片段直接丢弃
+	p := NewPipeline(PipelineOptions{Name: "test", Reassembly: ReassemblerOptions{PSEQStart: &PSEQStart{Auto: true}, PreFirstWindow: -1}})
+	events := SubscribeReassembly(16)
+	before := DropCounts()
+	next := func(kind ReassemblyEventKind) ReassemblyEvent {
+		t.Helper()
+		for {
+			select {
+			case ev := <-events:
+				if ev.Kind == kind {
+					return ev
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("未收到 %s 事件", kind)
+			}
+		}
+	}
+
+	// 没有进行中的拼接，中间片被丢弃
+	p.handleFrame(trace.ID("drop-test"), fragMiddle, time.Time{})
+	if ev := next(ReassemblyDropped); ev.Reason != DropNoFirstFragment || ev.SSEQ != 5 || ev.PSEQ != 1 {
+		t.Errorf("Dropped 事件 %+v，期望 %s SSEQ=5 PSEQ=1", ev, DropNoFirstFragment)
+	}
+	// 重复的中间片被丢弃，其余片段照常拼接完成
+	for _, f := range [][]byte{fragFirst, fragMiddle, fragMiddle, fragLast} {
+		p.handleFrame(trace.ID("drop-test"), f, time.Time{})
+	}
+	if ev := next(ReassemblyDropped); ev.Reason != DropDuplicateFragment || ev.PSEQ != 1 {
+		t.Errorf("Dropped 事件 %+v，期望 %s PSEQ=1", ev, DropDuplicateFragment)
+	}
+	if ev := next(ReassemblyCompleted); ev.SSEQ != 5 || ev.Bytes != 12 {
+		t.Errorf("Completed 事件 %+v，期望 SSEQ=5 12 字节", ev)
+	}
+	after := DropCounts()
+	for _, r := range []DropReason{DropNoFirstFragment, DropDuplicateFragment} {
+		if after[r]-before[r] != 1 {
+			t.Errorf("%s 计数增加 %d，期望 1", r, after[r]-before[r])
+		}
+	}
+
+	var dropErr *DropError
+	frag, err := ParseFragment(fragLast)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Reassembler().Process(frag); !errors.As(err, &dropErr) || dropErr.Reason != DropNoFirstFragment {
+		t.Errorf("Process 返回 %v，期望 %s", err, DropNoFirstFragment)
+	}
+}
@@ -1,9 +1,6 @@
 package frameparser
 
 // 封装 7.7 节 传感器复位设置报文
-import (
-	"encoding/binary"
-)
 
 // BuildResetRequest 构造“复位”控制报文。
 // sensorID: 6 字节传感器 ID；
@@ -36,10 +33,5 @@ func BuildResetRequest(sensorID [6]byte) ([]byte, error) {
 	buf = append(buf, ctrlByte)
 
 	// 4. 计算 CRC16（针对前面所有字节），并以大端序追加 2 字节
-	crc := CRC16(buf)
-	crcBytes := make([]byte, 2)
-	binary.BigEndian.PutUint16(crcBytes, crc)
-	buf = append(buf, crcBytes...)
-
-	return buf, nil
+	return StandardDialect.Seal(buf), nil
 }
@@ -0,0 +1,51 @@
+package frameparser
+
+import (
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SDUSink 接收拼接完成（complete=true）或未完成即被丢弃（complete=false）的 SDU 数据
+type SDUSink func(sensorID string, sseq uint8, data []byte, complete bool)
+
+var (
+	sduSinkMu sync.RWMutex
+	sduSink   SDUSink
+)
+
+// SetSDUSink 设置 SDU 接收方，传 nil 关闭；对未设置 ReassemblerOptions.SDUSink 的拼接器生效
+func SetSDUSink(s SDUSink) {
+	sduSinkMu.Lock()
+	defer sduSinkMu.Unlock()
+	sduSink = s
+}
+
+// emitSDU 在后台把 SDU 副本交给生效的接收方，避免文件写入阻塞拼接（调用方持有拼接器锁）
+func (r *Reassembler) emitSDU(sensorID [6]byte, cache *SDUCache, complete bool) {
+	s := r.opts.SDUSink
+	if s == nil {
+		sduSinkMu.RLock()
+		s = sduSink
+		sduSinkMu.RUnlock()
+	}
+	if s == nil {
+		return
+	}
+	data := make([]byte, len(cache.dataBuffer))
+	copy(data, cache.dataBuffer)
+	// 未完成的 SDU 把已到达的乱序片段按序号追加在后面
+	if !complete && len(cache.outOfOrder) > 0 {
+		seqs := make([]int, 0, len(cache.outOfOrder))
+		for seq := range cache.outOfOrder {
+			seqs = append(seqs, int(seq))
+		}
+		sort.Ints(seqs)
+		for _, seq := range seqs {
+			data = append(data, cache.outOfOrder[uint8(seq)]...)
+		}
+	}
+	id := strings.ToUpper(hex.EncodeToString(sensorID[:]))
+	go s(id, cache.SSEQ, data, complete)
+}
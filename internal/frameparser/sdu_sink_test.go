@@ -0,0 +1,69 @@
+package frameparser
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/trace"
+)
+
+// sealed 给十六进制表示的帧（不含 CRC）追加 CRC16
+func sealed(s string) []byte {
+	b := mustHex(s)
+	return binary.BigEndian.AppendUint16(b, CRC16(b))
+}
+
+type sinkedSDU struct {
+	sensorID string
+	sseq     uint8
+	data     string
+	complete bool
+}
+
+// TestSDUSinkFromLiveFragments 解析入口收到的分片帧拼接完成或被新首片替换时，SDU 交给 SDUSink
+func TestSDUSinkFromLiveFragments(t *testing.T) {
+	p := NewPipeline(PipelineOptions{Name: "test"})
+	got := make(chan sinkedSDU, 4)
+	SetSDUSink(func(sensorID string, sseq uint8, data []byte, complete bool) {
+		got <- sinkedSDU{sensorID, sseq, hex.EncodeToString(data), complete}
+	})
+	defer SetSDUSink(nil)
+	// SDUSink 在各自的协程中调用，到达顺序不定
+	expect := func(want ...sinkedSDU) {
+		t.Helper()
+		pending := make(map[sinkedSDU]bool)
+		for _, w := range want {
+			pending[w] = true
+		}
+		for len(pending) > 0 {
+			select {
+			case s := <-got:
+				if !pending[s] {
+					t.Fatalf("SDUSink 收到 %+v，期望 %+v", s, want)
+				}
+				delete(pending, s)
+			case <-time.After(time.Second):
+				t.Fatalf("SDUSink 未收到 %+v", pending)
+			}
+		}
+	}
+
+	for _, f := range [][]byte{fragFirst, fragMiddle, fragLast} {
+		p.handleFrame(trace.ID("sink-test"), f, time.Time{})
+	}
+	expect(sinkedSDU{"238A0821BEF2", 5, "04000000" + "20401400" + "0000ac41", true})
+
+	// SSEQ=5 只到首片就被 SSEQ=6 的首片替换，未完成的 SDU 按丢弃导出
+	p.handleFrame(trace.ID("sink-test"), fragFirst, time.Time{})
+	for _, f := range [][]byte{
+		sealed("238A0821BEF2" + "28" + "1800" + "04000000"),
+		sealed("238A0821BEF2" + "08" + "1A01" + "20401400"),
+		sealed("238A0821BEF2" + "08" + "1B02" + "0000AC41"),
+	} {
+		p.handleFrame(trace.ID("sink-test"), f, time.Time{})
+	}
+	expect(sinkedSDU{"238A0821BEF2", 5, "04000000", false},
+		sinkedSDU{"238A0821BEF2", 6, "04000000" + "20401400" + "0000ac41", true})
+}
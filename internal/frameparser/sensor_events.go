@@ -0,0 +1,56 @@
+package frameparser
+
+import (
+	"sync"
+	"time"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/trace"
+)
+
+// SensorEventKind 按传感器观察到的帧级事件类型
+type SensorEventKind string
+
+const (
+	SensorFrameAccepted SensorEventKind = "accepted" // 收到已绑定传感器的帧（CRC 和方言校验通过，含心跳）
+	SensorFrameRejected SensorEventKind = "rejected" // 帧被丢弃，Err 为错误类别
+)
+
+// SensorEvent 供驱动跟踪传感器上下线和异常的帧级事件；允许/拒绝列表过滤掉的帧和自检回环帧不产生事件
+type SensorEvent struct {
+	Kind     SensorEventKind
+	SensorID string
+	// Err 仅 Rejected 事件有效，用 errors.Is 判断类别（如 ErrCRCMismatch、ErrUnknownSensor）
+	Err     error
+	TraceID trace.ID
+	Time    time.Time
+}
+
+var (
+	sensorSubsMu sync.RWMutex
+	sensorSubs   []chan SensorEvent
+)
+
+// SubscribeSensorEvents 订阅帧级事件，buf 为通道缓冲；订阅者处理不及时时事件被丢弃，不阻塞解析
+func SubscribeSensorEvents(buf int) <-chan SensorEvent {
+	ch := make(chan SensorEvent, buf)
+	sensorSubsMu.Lock()
+	sensorSubs = append(sensorSubs, ch)
+	sensorSubsMu.Unlock()
+	return ch
+}
+
+// publishSensorEvent 非阻塞地把事件发给所有订阅者；没有 SensorID 的帧（长度不足）不发布
+func publishSensorEvent(ev SensorEvent) {
+	if ev.SensorID == "" {
+		return
+	}
+	ev.Time = time.Now()
+	sensorSubsMu.RLock()
+	defer sensorSubsMu.RUnlock()
+	for _, ch := range sensorSubs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
@@ -3,7 +3,6 @@ package frameparser
 // 封装 7.6 节 传感器ID查询/设置报文
 
 import (
-	"encoding/binary"
 	"fmt"
 )
 
@@ -42,10 +41,5 @@ func BuildSensorIDFrame(sensorID [6]byte, requestSetFlag byte, newID [6]byte) ([
 	buf = append(buf, newID[:]...)
 
 	// 4. 校验位：CRC16 前面所有字节，大端序追加 2 字节
-	crc := CRC16(buf)
-	crcBytes := make([]byte, 2)
-	binary.BigEndian.PutUint16(crcBytes, crc)
-	buf = append(buf, crcBytes...)
-
-	return buf, nil
+	return StandardDialect.Seal(buf), nil
 }
@@ -1,199 +1,433 @@
 package frameparser
 
 import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+	"github.com/linjuya-lu/device-lpmp-go/internal/trace"
 )
 
 // Frame 表示数据帧的结构，假设已有定义。
 // 其中包含SensorID（6字节）、FragInd（是否为分片帧指示）、SSEQ（业务单元序号）、
 // PSEQ（分片序号）、Flag（片段标志）、Data（负载数据）等字段。
 type Frame struct {
-	SensorID [6]byte // 传感器ID，6字节唯一标识传感器
-	FragInd  uint8   // 分片指示: 1表示分片帧, 0表示完整帧
-	SSEQ     uint8   // 业务单元序号 (6 bit有效位, 这里用byte表示0-63范围的值)
-	PSEQ     uint8   // 分片序号 (7 bit有效位, 0-127范围)
-	Flag     uint8   // 片段标志 (2 bit有效位: 00首片, 10中间片, 11尾片)
-	Data     []byte  // 帧的有效载荷数据
-	// 其他帧头字段如帧类型、长度等根据协议需要可加入
+	SensorID [6]byte  // 传感器ID，6字节唯一标识传感器
+	FragInd  uint8    // 分片指示: 1表示分片帧, 0表示完整帧
+	SSEQ     uint8    // 业务单元序号 (6 bit有效位, 这里用byte表示0-63范围的值)
+	PSEQ     uint8    // 分片序号 (7 bit有效位, 0-127范围)
+	Flag     uint8    // 片段标志 (2 bit有效位: 00首片, 10中间片, 11尾片)
+	Data     []byte   // 帧的有效载荷数据
+	TraceID  trace.ID // 追踪 ID，拼接后的完整帧沿用首片的 ID
+	// Retransmit 拼接后的完整帧有效：拼接过程中收到过重复首片或重复片段（发送端重传）
+	Retransmit bool
+	// Head 报文头（DataLen|FragInd|PacketType），拼接后的完整帧沿用首片的报文头
+	Head byte
+	// Compressed 报文内容为压缩数据（方言私有头中的压缩标志），拼接后沿用首片的标志
+	Compressed bool
+	// Received 从串口读出该帧的时间，拼接后的完整帧沿用首片的时间
+	Received time.Time
 }
 
 // SDUCache 结构保存正在拼接的某个传感器的一条SDU信息
 type SDUCache struct {
 	SSEQ        uint8            // 当前正在拼装的业务单元序号
 	expectedSeq uint8            // 下一个期望收到的PSEQ序号
-	finalSeq    uint8            // 最后尾片的序号（如果已知的话），0表示暂未确定
+	finalSeq    uint8            // 最后尾片的序号，haveFinal 为 true 时有效
+	haveFinal   bool             // 是否已收到尾片
+	haveFirst   bool             // 是否已收到首片；起点序号固定时缓存可由先到的中间/尾片建立
 	dataBuffer  []byte           // 已接收片段的累计数据
 	outOfOrder  map[uint8][]byte // 临时保存的乱序片段: key是PSEQ序号, value是该片段数据
-	timer       *time.Timer      // 超时定时器，用于超时未完成时清理
+	ooArrival   map[uint8]uint64 // 乱序片段的到达次序，超出上限时先丢弃最早的
+	timer       Timer            // 超时定时器，用于超时未完成时清理
+	traceID     trace.ID         // 首片的追踪 ID
+	retransmits int              // 收到重传的首片或片段的次数
+	head        byte             // 首片的报文头，输出完整帧时沿用
+	compressed  bool             // 首片的压缩标志
+	received    time.Time        // 首片的接收时间
+	// 统计信息，见 SDUStats
+	startedAt       time.Time
+	fragments       int
+	outOfOrderCount int
+	endSpan         func(attrs map[string]any, err error)
+}
+
+// SDUStats 一条拼接完成的 SDU 的统计信息
+type SDUStats struct {
+	Fragments int // 拼入的片段数
+	Bytes     int // SDU 长度
+	// OutOfOrder 先于前序片段到达、经乱序缓存或首片前暂存的片段数
+	OutOfOrder int
+	// Retransmissions 收到的重复首片、重复片段次数
+	Retransmissions int
+	Started         time.Time     // 建立拼接缓存的时间
+	Duration        time.Duration // 从建立缓存到拼接完成的时长
+}
+
+// CompleteFunc SDU 拼接完成回调
+type CompleteFunc func(sensorID [6]byte, sseq uint8, sdu []byte, stats SDUStats)
+
+// 可配置的拼接超时时间，默认20秒，通过 SetReassembleTimeout 修改
+var reassembleTimeout = 20 * time.Second
+
+// 包级首片序号起点，默认按规范为 0，通过 SetPSEQStart 修改
+var pseqStartDefault = SpecPSEQStart
+
+// defaultOutputBuffer 输出通道的默认缓冲
+const defaultOutputBuffer = 100
+
+// PSEQ 为 7bit，跨 SDU 连续编号的实现会回绕
+const (
+	pseqModulo = 128
+	// pseqWindow 相对期望序号前进距离小于该值的片段视为超前，否则视为重复或过期
+	pseqWindow = pseqModulo / 2
+)
+
+// nextPSEQ 返回下一个序号（回绕）
+func nextPSEQ(s uint8) uint8 {
+	return (s + 1) % pseqModulo
+}
+
+// pseqAhead 返回 p 相对 expected 的前进距离（0~127，按回绕计算）
+func pseqAhead(expected, p uint8) int {
+	return int((p - expected) % pseqModulo)
+}
+
+// PSEQStart 首片 PSEQ 的起点规则
+type PSEQStart struct {
+	// Auto 为 true 时以收到的首片序号为起点（兼容跨 SDU 连续编号的实现），
+	// 此时首片之前到达的中间/尾片无法定位，只能丢弃
+	Auto bool
+	// Value Auto 为 false 时首片的固定序号；首片序号不符的分片被丢弃
+	Value uint8
+}
+
+// SpecPSEQStart 规范约定的首片序号为 0
+var SpecPSEQStart = PSEQStart{Value: 0}
+
+// ParsePSEQStart 解析起点配置："auto" 或 0~127 的整数
+func ParsePSEQStart(s string) (PSEQStart, error) {
+	s = strings.TrimSpace(s)
+	if strings.EqualFold(s, "auto") {
+		return PSEQStart{Auto: true}, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 || n >= pseqModulo {
+		return PSEQStart{}, fmt.Errorf("首片 PSEQ 起点须为 auto 或 0~127，得到 %q", s)
+	}
+	return PSEQStart{Value: uint8(n)}, nil
+}
+
+// ReassemblerOptions 拼接器参数，零值使用包级默认值
+type ReassemblerOptions struct {
+	// Timeout 拼接超时时间，0 表示使用 SetReassembleTimeout 设置的值（默认 20 秒）
+	Timeout time.Duration
+	// Clock 定时器来源，nil 表示使用 SetClock 设置的时钟（默认系统时钟）
+	Clock Clock
+	// OutputBuffer 输出通道缓冲，0 表示 100
+	OutputBuffer int
+	// PSEQStart 首片序号的起点规则，nil 表示使用 SetPSEQStart 设置的值（默认 SpecPSEQStart）
+	PSEQStart *PSEQStart
+	// PreFirstWindow 首片之前到达的中间/尾片的暂存时长，0 表示使用 SetPreFirstWindow 设置的值
+	// （默认 DefaultPreFirstWindow），负数表示不暂存
+	PreFirstWindow time.Duration
+	// MaxOutOfOrderPerSDU 单个 SDU 最多暂存的乱序片段数，0 表示使用 SetOutOfOrderLimits 设置的值
+	MaxOutOfOrderPerSDU int
+	// OutOfOrderBudget 乱序缓存和首片前暂存片段的合计上限，可由多个拼接器共享；
+	// nil 表示使用 SetOutOfOrderLimits 设置的服务级上限（所有未设置该项的拼接器共享）
+	OutOfOrderBudget *OutOfOrderBudget
+	// SSEQFilter 拼接完成 SDU 的去重判断，nil 表示使用 SetSSEQFilter 设置的值
+	SSEQFilter SSEQFilter
+	// SDUSink 拼接完成或丢弃的 SDU 的接收方，nil 表示使用 SetSDUSink 设置的值
+	SDUSink SDUSink
+}
+
+// Reassembler 保存一条链路上各传感器正在拼接的 SDU，并发安全；
+// 多串口时每条链路使用独立的 Reassembler
+type Reassembler struct {
+	opts   ReassemblerOptions
+	mu     sync.Mutex
+	caches map[[6]byte]*SDUCache           // 按SensorID区分的SDUCache
+	early  map[[6]byte]map[uint8]*earlySDU // 首片之前到达的片段，按 SensorID、SSEQ 暂存
+
+	onComplete []CompleteFunc
+	buffered   int         // 乱序缓存和首片前暂存中的片段数，计入 OutOfOrderBudget
+	out        chan *Frame // 重组/未分片的 Frame 推给解析或上层逻辑
+}
+
+// NewReassembler 创建一个拼接器
+func NewReassembler(opts ReassemblerOptions) *Reassembler {
+	n := opts.OutputBuffer
+	if n <= 0 {
+		n = defaultOutputBuffer
+	}
+	return newReassembler(opts, make(chan *Frame, n))
+}
+
+func newReassembler(opts ReassemblerOptions, out chan *Frame) *Reassembler {
+	return &Reassembler{
+		opts:   opts,
+		caches: make(map[[6]byte]*SDUCache),
+		early:  make(map[[6]byte]map[uint8]*earlySDU),
+		out:    out,
+	}
+}
+
+// Output 返回输出完整帧的通道
+func (r *Reassembler) Output() <-chan *Frame {
+	return r.out
+}
+
+// clockAndTimeout 返回生效的时钟和超时时间
+func (r *Reassembler) clockAndTimeout() (Clock, time.Duration) {
+	clk, timeout := currentClock()
+	if r.opts.Clock != nil {
+		clk = r.opts.Clock
+	}
+	if r.opts.Timeout > 0 {
+		timeout = r.opts.Timeout
+	}
+	return clk, timeout
+}
+
+// OnComplete 注册 SDU 拼接完成回调，可注册多个，按注册顺序调用；回调不占用输出通道。
+// 回调在持有拼接器锁时同步执行：不得再调用该拼接器的 Process，sdu 不得修改，耗时处理应转交其它协程
+func (r *Reassembler) OnComplete(fn CompleteFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onComplete = append(r.onComplete, fn)
+}
+
+// notifyComplete 调用拼接完成回调（调用方持有 r.mu）
+func (r *Reassembler) notifyComplete(sensorID [6]byte, cache *SDUCache) {
+	if len(r.onComplete) == 0 {
+		return
+	}
+	stats := SDUStats{
+		Fragments:       cache.fragments,
+		Bytes:           len(cache.dataBuffer),
+		OutOfOrder:      cache.outOfOrderCount,
+		Retransmissions: cache.retransmits,
+		Started:         cache.startedAt,
+		Duration:        r.now().Sub(cache.startedAt),
+	}
+	for _, fn := range r.onComplete {
+		fn(sensorID, cache.SSEQ, cache.dataBuffer, stats)
+	}
+}
+
+// now 返回当前时间：时钟实现了 Now 时（如 ManualClock）以其为准
+func (r *Reassembler) now() time.Time {
+	clk, _ := r.clockAndTimeout()
+	if n, ok := clk.(interface{ Now() time.Time }); ok {
+		return n.Now()
+	}
+	return time.Now()
 }
 
-// 全局缓存map: 按SensorID区分的SDUCache
+// pseqStart 返回生效的首片序号起点
+func (r *Reassembler) pseqStart() PSEQStart {
+	if r.opts.PSEQStart != nil {
+		return *r.opts.PSEQStart
+	}
+	return currentPSEQStart()
+}
+
+// 兼容旧接口：包级默认拼接器及其输出通道
 var (
-	sduCacheMap = make(map[[6]byte]*SDUCache)
-	cacheMu     sync.Mutex
-	// 这个通道用来把重组/未分片的 Frame 推给 StartParser 或上层逻辑
-	FrameCh = make(chan *Frame, 100)
+	// 这个通道用来把 ProcessFrame 重组/未分片的 Frame 推给上层逻辑；只能有一个消费者，
+	// 多个消费者时用 NewFrameFanout 分发
+	FrameCh = make(chan *Frame, defaultOutputBuffer)
+
+	defaultReassembler = newReassembler(ReassemblerOptions{}, FrameCh)
 )
 
-// 可配置的拼接超时时间，默认20秒
-var reassembleTimeout = 20 * time.Second
+// ProcessFrame 使用默认拼接器处理一帧，输出到 FrameCh，见 Reassembler.Process
+func ProcessFrame(frame *Frame) error {
+	return defaultReassembler.Process(frame)
+}
 
-// ProcessFrame 处理收到的单帧数据，根据是否分片进行缓存或直接解析
+// Process 处理收到的单帧数据，根据是否分片进行缓存或直接解析
 // 若非分片帧 (FragInd != 1)，直接通过通道发送，不进入缓存流程。
 // 若是分片帧，根据是否已有缓存及片段类型分别处理：
-// 首片处理： 创建新的缓存结构，初始化期望序号和数据缓冲，并启动超时定时器
-// biaozhuns.com
-// 。
+// 首片处理： 创建新的缓存结构，以起点序号（见 PSEQStart）初始化期望序号，并启动超时定时器；
+// 起点序号固定时，先到的中间/尾片已建立了缓存，迟到的首片直接接上已暂存的片段；
+// 起点序号不确定（auto）或正在拼接其它 SSEQ 时，先到的片段暂存一个短窗口（见 PreFirstWindow），首片到达时并入。
 // 重复首片或新消息首片冲突： 如已存在缓存，遇到新的首片，根据 SSEQ 判定是同一消息的重发还是新的消息开始，从而决定是重置当前缓存重新开始，还是丢弃旧缓存转入新消息的拼接。
-// 中间/尾片处理： 检查 PSEQ 与期望序号的关系，采取顺序拼接、乱序暂存或重复忽略等措施，确保数据按序整合。收到尾片时记录最后序号，在确定所有片段齐全后进行最终拼装。
-func ProcessFrame(frame *Frame) {
+// 中间/尾片处理： 检查 PSEQ 与期望序号的关系（7bit 序号按回绕比较），采取顺序拼接、乱序暂存或重复忽略等措施，确保数据按序整合。收到尾片时记录最后序号，在确定所有片段齐全后进行最终拼装。
+// 暂存片段超出 OutOfOrderBudget 时，返回前回收共享该预算的拼接器中最早暂存的片段。
+// 当前帧被丢弃时返回 *DropError（含 DropReason），暂存或拼接成功返回 nil；
+// 所有丢弃都会计数、节流记录日志并发布 ReassemblyDropped 事件。
+func (r *Reassembler) Process(frame *Frame) error {
 	// 如果不是分片帧，直接转发给下一阶段解析
 	if frame.FragInd != 1 {
-		FrameCh <- frame // 假设frameCh为全局帧通道，StartParser从此通道读取
-		return
+		r.out <- frame
+		return nil
 	}
 
-	cacheMu.Lock() // 加锁保护全局缓存访问
-	defer cacheMu.Unlock()
+	r.mu.Lock() // 加锁保护缓存访问
+	err := r.process(frame)
+	r.mu.Unlock()
+	// 回收可能涉及其它拼接器，须在释放本拼接器的锁之后进行
+	r.budget().enforce()
+	return err
+}
 
+// process 处理一个分片帧，见 Process（调用方持有 r.mu）
+func (r *Reassembler) process(frame *Frame) error {
 	// 获取该传感器对应的缓存（如果存在）
 	sensorID := frame.SensorID
-	sduCache, exists := sduCacheMap[sensorID]
+	sduCache, exists := r.caches[sensorID]
+	start := r.pseqStart()
 
-	// 帧是分片帧的情况：
+	// 1. 首片
+	if isFlagFirst(frame.Flag) {
+		if !start.Auto && frame.PSEQ != start.Value {
+			// 首片序号与约定的起点不符，无法判断其后片段的位置
+			return dropFrame(frame, DropBadFirstPSEQ)
+		}
+		switch {
+		case exists && frame.SSEQ == sduCache.SSEQ && !sduCache.haveFirst:
+			// 首片迟到：缓存由先到的中间/尾片建立，按序接上
+			sduCache.haveFirst = true
+			sduCache.takeFirst(frame)
+			r.appendInOrder(sensorID, sduCache, frame)
+			return nil
+		case exists && frame.SSEQ == sduCache.SSEQ:
+			// 收到重复的首片（可能是发送端重传），重启拼接
+			cancelReassembleTimer(sduCache)
+			delete(r.caches, sensorID)
+			r.dropCache(sensorID, sduCache, DropRestarted, errors.New("收到重复首片，重新拼接"))
+			sduCache = r.newCache(sensorID, frame, frame.PSEQ, true)
+			sduCache.retransmits++
+		case exists:
+			// 新的消息开始：释放旧的未完成缓存，开始新的拼接
+			cancelReassembleTimer(sduCache)
+			delete(r.caches, sensorID)
+			r.dropCache(sensorID, sduCache, DropReplaced, errors.New("被新业务单元的首片替换"))
+			sduCache = r.newCache(sensorID, frame, frame.PSEQ, true)
+		default:
+			sduCache = r.newCache(sensorID, frame, frame.PSEQ, true)
+		}
+		sduCache.takeFirst(frame)
+		// 首片同时也是尾片时直接完成拼接
+		r.appendInOrder(sensorID, sduCache, frame)
+		return nil
+	}
+
+	// 2. 中间片/尾片
 	if !exists {
-		// 当前没有该传感器的缓存，表示这是新收到的分片数据
-		if isFlagFirst(frame.Flag) {
-			// 是首片，则创建新的SDUCache进行缓存
-			sduCache = &SDUCache{
-				SSEQ:        frame.SSEQ,
-				expectedSeq: frame.PSEQ, // 首片的PSEQ通常为起始序号
-				finalSeq:    0,          // 还未确定最后片序号
-				dataBuffer:  make([]byte, 0),
-				outOfOrder:  make(map[uint8][]byte),
-			}
-			// 缓存首片数据并更新期望下一个序号
-			appendFragmentData(sduCache, frame.PSEQ, frame.Data)
-			sduCache.expectedSeq = frame.PSEQ + 1
-
-			// 启动超时定时器
-			startReassembleTimer(sensorID, sduCache)
-			// 将缓存保存到全局map
-			sduCacheMap[sensorID] = sduCache
-
-			// 检查该片是否同时也是尾片（首片==尾片的特殊情况）
-			if isFlagLast(frame.Flag) {
-				finalizeAndOutput(sensorID, sduCache)
-			}
-		} else {
-			// 没有缓存且收到的不是首片，无法处理该片段（可能缺少前序片段）
-			// 丢弃该片段（可记录警告日志）
-			return
+		// 起点序号未知时无法确定片段位置，暂存等待首片
+		if start.Auto {
+			return r.stashEarly(frame, DropNoFirstFragment)
+		}
+		if ahead := pseqAhead(start.Value, frame.PSEQ); ahead == 0 || ahead >= pseqWindow {
+			return dropFrame(frame, DropNoFirstFragment)
+		}
+		// 起点序号固定：先建立缓存暂存该片段，等待迟到的首片
+		sduCache = r.newCache(sensorID, frame, start.Value, false)
+	} else if frame.SSEQ != sduCache.SSEQ {
+		// 收到一个不属于当前缓存SSEQ的片段且不是新的首片（可能是下一条消息先到的片段），暂存等待其首片
+		return r.stashEarly(frame, DropForeignSSEQ)
+	}
+
+	// 检查片段序号与期望序号的关系
+	ahead := pseqAhead(sduCache.expectedSeq, frame.PSEQ)
+	switch {
+	case ahead == 0:
+		// 按顺序收到正确的下一片段
+		r.appendInOrder(sensorID, sduCache, frame)
+	case ahead < pseqWindow:
+		// 缺少中间片段，此片段超前了，将其暂存于乱序缓存（已暂存过则为重传）
+		// 超出单个 SDU 的乱序缓存上限时丢弃本 SDU 最早暂存的片段（见 SetOutOfOrderLimits）
+		if r.bufferOutOfOrder(sensorID, sduCache, frame.PSEQ, frame.Data) {
+			sduCache.retransmits++
 		}
-	} else {
-		// 已有该传感器的缓存正在拼接
-		// 检查SSEQ是否匹配当前缓存的业务单元
-		if frame.SSEQ != sduCache.SSEQ {
-			// 收到不同SSEQ的分片帧
-			if isFlagFirst(frame.Flag) {
-				// 如果新来的帧是一个新的首片（新的消息开始）
-				// 释放旧的未完成缓存，开始新的拼接
-				cancelReassembleTimer(sduCache) // 停止旧定时器
-				delete(sduCacheMap, sensorID)   // 删除旧缓存
-				// 可在此记录日志: 丢弃旧SSEQ未完成的拼接数据
-
-				// 使用新帧的信息创建新的缓存
-				newCache := &SDUCache{
-					SSEQ:        frame.SSEQ,
-					expectedSeq: frame.PSEQ,
-					finalSeq:    0,
-					dataBuffer:  make([]byte, 0),
-					outOfOrder:  make(map[uint8][]byte),
-				}
-				appendFragmentData(newCache, frame.PSEQ, frame.Data)
-				newCache.expectedSeq = frame.PSEQ + 1
-				startReassembleTimer(sensorID, newCache)
-				sduCacheMap[sensorID] = newCache
-				sduCache = newCache
-
-				// 如果新首片同时也是尾片，则直接完成拼接输出
-				if isFlagLast(frame.Flag) {
-					finalizeAndOutput(sensorID, newCache)
-				}
-			} else {
-				// 收到一个不属于当前缓存SSEQ的片段且不是新的首片，无法拼接，丢弃
-				return
-			}
-		} else {
-			// SSEQ匹配当前缓存，继续拼接流程
-			if isFlagFirst(frame.Flag) {
-				// 收到重复的首片（可能是发送端重传），重启拼接
-				cancelReassembleTimer(sduCache) // 停止当前定时器
-				delete(sduCacheMap, sensorID)   // 移除当前缓存
-				// 创建新缓存（使用当前帧覆盖旧数据）
-				newCache := &SDUCache{
-					SSEQ:        frame.SSEQ,
-					expectedSeq: frame.PSEQ,
-					finalSeq:    0,
-					dataBuffer:  make([]byte, 0),
-					outOfOrder:  make(map[uint8][]byte),
-				}
-				appendFragmentData(newCache, frame.PSEQ, frame.Data)
-				newCache.expectedSeq = frame.PSEQ + 1
-				startReassembleTimer(sensorID, newCache)
-				sduCacheMap[sensorID] = newCache
-				sduCache = newCache
-
-				// 检查是否同时为尾片
-				if isFlagLast(frame.Flag) {
-					finalizeAndOutput(sensorID, newCache)
-				}
-			} else {
-				// 正常的中间片或尾片
-				// 检查片段序号是否为期望的下一序号
-				if frame.PSEQ < sduCache.expectedSeq {
-					// 收到重复或过期的片段，直接忽略
-					return
-				}
-				if frame.PSEQ > sduCache.expectedSeq {
-					// 缺少中间片段，此片段超前了，将其暂存于乱序缓存
-					sduCache.outOfOrder[frame.PSEQ] = frame.Data
-					// 如果此片段是尾片，记录最后片序号
-					if isFlagLast(frame.Flag) {
-						sduCache.finalSeq = frame.PSEQ
-					}
-					return // 先返回，等待缺失的片段到达或超时
-				}
-				if frame.PSEQ == sduCache.expectedSeq {
-					// 按顺序收到正确的下一片段
-					appendFragmentData(sduCache, frame.PSEQ, frame.Data)
-					sduCache.expectedSeq++ // 更新下一个期望序号
-
-					// 如果是尾片，记录最后片序号
-					if isFlagLast(frame.Flag) {
-						sduCache.finalSeq = frame.PSEQ
-					}
-					// 尝试拼接乱序缓存中后续连续的片段
-					for {
-						data, ok := sduCache.outOfOrder[sduCache.expectedSeq]
-						if !ok {
-							break
-						}
-						// 找到按序衔接的片段，取出拼接
-						appendFragmentData(sduCache, sduCache.expectedSeq, data)
-						delete(sduCache.outOfOrder, sduCache.expectedSeq)
-						sduCache.expectedSeq++
-					}
-					// 检查是否已完成整个SDU拼接：
-					// 条件：已收到尾片且所有片段序号都已衔接到尾片
-					if sduCache.finalSeq != 0 && sduCache.expectedSeq > sduCache.finalSeq {
-						finalizeAndOutput(sensorID, sduCache)
-					}
-				}
-			}
+		// 如果此片段是尾片，记录最后片序号；先返回，等待缺失的片段到达或超时
+		if isFlagLast(frame.Flag) {
+			sduCache.finalSeq, sduCache.haveFinal = frame.PSEQ, true
 		}
+	default:
+		// 收到重复或过期的片段，直接忽略，完成后的 SDU 标记为有重传
+		sduCache.retransmits++
+		return dropFrame(frame, DropDuplicateFragment)
 	}
+	return nil
+}
+
+// newCache 为传感器建立新的拼接缓存并启动超时定时器，expected 为期望的首个 PSEQ，
+// haveFirst 为 false 表示缓存由先到的中间/尾片建立（调用方持有 r.mu）
+func (r *Reassembler) newCache(sensorID [6]byte, frame *Frame, expected uint8, haveFirst bool) *SDUCache {
+	c := &SDUCache{
+		SSEQ:        frame.SSEQ,
+		expectedSeq: expected,
+		dataBuffer:  make([]byte, 0),
+		outOfOrder:  make(map[uint8][]byte),
+		ooArrival:   make(map[uint8]uint64),
+		traceID:     frame.TraceID,
+		endSpan:     trace.Begin(frame.TraceID, trace.StageReassemble),
+		haveFirst:   haveFirst,
+		startedAt:   r.now(),
+	}
+	r.startReassembleTimer(sensorID, c)
+	r.caches[sensorID] = c
+	publishStarted(frame)
+	r.mergeEarly(sensorID, c)
+	return c
+}
+
+// takeFirst 记录首片的报文头、压缩标志和接收时间
+func (c *SDUCache) takeFirst(frame *Frame) {
+	c.head, c.compressed, c.received = frame.Head, frame.Compressed, frame.Received
+}
+
+// appendInOrder 拼接序号等于期望值的片段，接上乱序缓存中随后连续的片段；
+// 尾片及其之前的片段全部到齐时输出完整 SDU（调用方持有 r.mu）
+func (r *Reassembler) appendInOrder(sensorID [6]byte, c *SDUCache, frame *Frame) {
+	appendFragmentData(c, frame.PSEQ, frame.Data)
+	c.expectedSeq = nextPSEQ(frame.PSEQ)
+	if isFlagLast(frame.Flag) {
+		c.finalSeq, c.haveFinal = frame.PSEQ, true
+	}
+	for {
+		data, ok := c.outOfOrder[c.expectedSeq]
+		if !ok {
+			break
+		}
+		// 找到按序衔接的片段，取出拼接
+		appendFragmentData(c, c.expectedSeq, data)
+		r.takeOutOfOrder(c, c.expectedSeq)
+		c.expectedSeq = nextPSEQ(c.expectedSeq)
+	}
+	// 已收到尾片且所有片段序号都已衔接到尾片
+	if c.haveFinal && c.expectedSeq == nextPSEQ(c.finalSeq) {
+		r.finalizeAndOutput(sensorID, c)
+	}
+}
+
+// Quality 返回由该帧解析出的读数应带的质量标签：拼接中出现过重传时为
+// reassembled-with-retransmit，否则为 good
+func (f *Frame) Quality() string {
+	if f.Retransmit {
+		return config.QualityRetransmit
+	}
+	return config.QualityGood
+}
+
+// publishStarted 发布开始拼接事件
+func publishStarted(frame *Frame) {
+	publishReassembly(ReassemblyEvent{
+		Kind:     ReassemblyStarted,
+		SensorID: frame.SensorID,
+		SSEQ:     frame.SSEQ,
+		PSEQ:     frame.PSEQ,
+		Bytes:    len(frame.Data),
+		TraceID:  frame.TraceID,
+	})
 }
 
 // 辅助函数：判断Flag是否标识首片 (2-bit 值 == 00)
@@ -211,20 +445,22 @@ func isFlagLast(flag uint8) bool {
 func appendFragmentData(cache *SDUCache, pseq uint8, data []byte) {
 	// 简单拼接数据片段
 	cache.dataBuffer = append(cache.dataBuffer, data...)
+	cache.fragments++
 	// （注：根据协议，可能需要在首片处处理协议头或长度字段，这里假设Data已经是纯净的SDU数据片段）
 }
 
-// 启动拼接超时定时器
-func startReassembleTimer(sensorID [6]byte, cache *SDUCache) {
-	cache.timer = time.AfterFunc(reassembleTimeout, func() {
-		cacheMu.Lock()
-		defer cacheMu.Unlock()
+// 启动拼接超时定时器（使用可替换的 Clock）
+func (r *Reassembler) startReassembleTimer(sensorID [6]byte, cache *SDUCache) {
+	clk, timeout := r.clockAndTimeout()
+	cache.timer = clk.AfterFunc(timeout, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
 		// 定时器触发时再次检查：
-		currentCache, ok := sduCacheMap[sensorID]
+		currentCache, ok := r.caches[sensorID]
 		if ok && currentCache == cache {
 			// 若超时时该SensorID缓存仍是当前cache且尚未完成拼接，则丢弃
-			delete(sduCacheMap, sensorID)
-			// 记录超时日志（如需要）：fmt.Printf("拼接超时，丢弃传感器[%x]序号[%d]的未完成SDU\n", sensorID, cache.SSEQ)
+			delete(r.caches, sensorID)
+			r.dropCache(sensorID, cache, DropTimeout, errors.New("拼接超时"))
 		}
 	})
 }
@@ -238,10 +474,24 @@ func cancelReassembleTimer(cache *SDUCache) {
 }
 
 // 完成拼接后输出完整帧到解析通道
-func finalizeAndOutput(sensorID [6]byte, cache *SDUCache) {
+func (r *Reassembler) finalizeAndOutput(sensorID [6]byte, cache *SDUCache) {
 	// 在输出前先清除定时器和缓存，以免重复
 	cancelReassembleTimer(cache)
-	delete(sduCacheMap, sensorID)
+	delete(r.caches, sensorID)
+	r.releaseOutOfOrder(cache)
+	// 去重窗口内已拼接完成过的 SSEQ 不再输出，避免重复发布
+	if !r.acceptSSEQ(sensorID, cache.SSEQ, r.now()) {
+		cache.endSpan(nil, errors.New("重复的 SDU"))
+		recordDrop(ReassemblyEvent{
+			SensorID: sensorID,
+			SSEQ:     cache.SSEQ,
+			PSEQ:     cache.expectedSeq,
+			Reason:   DropDuplicateSDU,
+			Bytes:    len(cache.dataBuffer),
+			TraceID:  cache.traceID,
+		})
+		return
+	}
 
 	// 构造新的Frame，内容与首片帧类似但标记为非分片
 	fullFrame := &Frame{
@@ -251,7 +501,24 @@ func finalizeAndOutput(sensorID [6]byte, cache *SDUCache) {
 		PSEQ:     0,                // 完整帧无分片序号
 		Flag:     0,                // 完整帧无分片标志
 		Data:     cache.dataBuffer, // 拼接后的完整SDU数据
+		TraceID:  cache.traceID,    // 沿用首片的追踪 ID
+
+		Retransmit: cache.retransmits > 0,
+		Head:       cache.head &^ fragIndBit, // 沿用首片的报文头，清除分片指示
+		Compressed: cache.compressed,
+		Received:   cache.received,
 	}
-	// 通过frameCh通道发送给下一阶段解析
-	FrameCh <- fullFrame
+	cache.endSpan(map[string]any{"bytes": len(cache.dataBuffer)}, nil)
+	r.emitSDU(sensorID, cache, true)
+	publishReassembly(ReassemblyEvent{
+		Kind:       ReassemblyCompleted,
+		SensorID:   sensorID,
+		SSEQ:       cache.SSEQ,
+		Bytes:      len(cache.dataBuffer),
+		TraceID:    cache.traceID,
+		Retransmit: cache.retransmits > 0,
+	})
+	r.notifyComplete(sensorID, cache)
+	// 通过输出通道发送给下一阶段解析
+	r.out <- fullFrame
 }
@@ -0,0 +1,198 @@
+package frameparser
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+)
+
+// 分片头中的 Flag
+const (
+	fragFlagFirst  uint8 = 0b00
+	fragFlagMiddle uint8 = 0b10
+	fragFlagLast   uint8 = 0b11
+)
+
+// fragment 构造一个分片帧
+func fragment(id [6]byte, sseq, pseq, flag uint8, data ...byte) *Frame {
+	return &Frame{SensorID: id, FragInd: 1, SSEQ: sseq, PSEQ: pseq, Flag: flag, Data: data}
+}
+
+// newManualReassembler 创建使用手动时钟的拼接器，并订阅拼接事件
+func newManualReassembler(opts ReassemblerOptions) (*Reassembler, *ManualClock, <-chan ReassemblyEvent) {
+	clk := NewManualClock()
+	opts.Clock = clk
+	return NewReassembler(opts), clk, SubscribeReassembly(64)
+}
+
+// dropReasons 取出该传感器已发布的丢弃事件的原因
+func dropReasons(events <-chan ReassemblyEvent, id [6]byte) []DropReason {
+	var out []DropReason
+	for {
+		select {
+		case ev := <-events:
+			if ev.SensorID == id && ev.Kind == ReassemblyDropped {
+				out = append(out, ev.Reason)
+			}
+		default:
+			return out
+		}
+	}
+}
+
+// process 处理一组分片，期望全部被接受
+func process(t *testing.T, r *Reassembler, frames ...*Frame) {
+	t.Helper()
+	for _, f := range frames {
+		if err := r.Process(f); err != nil {
+			t.Fatalf("SSEQ=%d PSEQ=%d: %v", f.SSEQ, f.PSEQ, err)
+		}
+	}
+}
+
+// output 取出拼接器已输出的完整帧，没有时返回 nil
+func output(r *Reassembler) *Frame {
+	select {
+	case f := <-r.Output():
+		return f
+	default:
+		return nil
+	}
+}
+
+func TestReassembleTimeout(t *testing.T) {
+	id := [6]byte{0x66, 1}
+	r, clk, events := newManualReassembler(ReassemblerOptions{Timeout: 10 * time.Second})
+
+	process(t, r, fragment(id, 1, 0, fragFlagFirst, 1), fragment(id, 1, 1, fragFlagMiddle, 2))
+	if clk.Pending() != 1 {
+		t.Fatalf("定时器 %d 个，期望 1", clk.Pending())
+	}
+	clk.Advance(10*time.Second - time.Nanosecond)
+	if got := dropReasons(events, id); len(got) != 0 {
+		t.Fatalf("超时之前丢弃 %v", got)
+	}
+
+	// 到期后未完成的 SDU 整体丢弃
+	clk.Advance(time.Nanosecond)
+	if got := dropReasons(events, id); !reflect.DeepEqual(got, []DropReason{DropTimeout}) {
+		t.Fatalf("丢弃 %v，期望 [timeout]", got)
+	}
+	if clk.Pending() != 0 {
+		t.Errorf("超时后仍有 %d 个定时器", clk.Pending())
+	}
+	// 超时后迟到的尾片当作新的 SDU 等待首片，同样到期丢弃
+	process(t, r, fragment(id, 1, 2, fragFlagLast, 3))
+	clk.Advance(10 * time.Second)
+	if got := dropReasons(events, id); !reflect.DeepEqual(got, []DropReason{DropTimeout}) {
+		t.Fatalf("迟到的尾片: 丢弃 %v，期望 [timeout]", got)
+	}
+	if f := output(r); f != nil {
+		t.Errorf("超时的 SDU 被输出: % X", f.Data)
+	}
+
+	// 在超时之前完成的 SDU 停止定时器，之后推进时钟不再丢弃
+	process(t, r, fragment(id, 2, 0, fragFlagFirst, 4), fragment(id, 2, 1, fragFlagLast, 5))
+	clk.Advance(time.Minute)
+	if f := output(r); f == nil || !bytes.Equal(f.Data, []byte{4, 5}) {
+		t.Errorf("输出 %+v，期望 SDU 04 05", f)
+	}
+	if got := dropReasons(events, id); len(got) != 0 {
+		t.Errorf("完成后丢弃 %v", got)
+	}
+}
+
+func TestReassembleRepeatedFirst(t *testing.T) {
+	id := [6]byte{0x66, 2}
+	r, clk, events := newManualReassembler(ReassemblerOptions{Timeout: 10 * time.Second})
+
+	process(t, r, fragment(id, 3, 0, fragFlagFirst, 1), fragment(id, 3, 1, fragFlagMiddle, 2))
+	clk.Advance(6 * time.Second)
+
+	// 同一 SSEQ 的首片重传：丢弃已拼接的部分，重新开始并重新计时
+	process(t, r, fragment(id, 3, 0, fragFlagFirst, 1))
+	if got := dropReasons(events, id); !reflect.DeepEqual(got, []DropReason{DropRestarted}) {
+		t.Fatalf("丢弃 %v，期望 [restarted]", got)
+	}
+	if clk.Pending() != 1 {
+		t.Fatalf("定时器 %d 个，期望 1（旧定时器已停止）", clk.Pending())
+	}
+	clk.Advance(6 * time.Second)
+	if got := dropReasons(events, id); len(got) != 0 {
+		t.Fatalf("重新计时后按旧的到期时间丢弃 %v", got)
+	}
+
+	process(t, r, fragment(id, 3, 1, fragFlagMiddle, 2), fragment(id, 3, 2, fragFlagLast, 3))
+	f := output(r)
+	if f == nil || !bytes.Equal(f.Data, []byte{1, 2, 3}) {
+		t.Fatalf("输出 %+v，期望 SDU 01 02 03", f)
+	}
+	if !f.Retransmit || f.Quality() != config.QualityRetransmit {
+		t.Errorf("重新拼接的 SDU 未标记重传: %+v", f)
+	}
+
+	// 不同 SSEQ 的首片替换未完成的 SDU
+	process(t, r, fragment(id, 4, 0, fragFlagFirst, 1), fragment(id, 5, 0, fragFlagFirst, 9))
+	if got := dropReasons(events, id); !reflect.DeepEqual(got, []DropReason{DropReplaced}) {
+		t.Errorf("丢弃 %v，期望 [replaced]", got)
+	}
+}
+
+func TestReassembleOutOfOrderEviction(t *testing.T) {
+	id := [6]byte{0x66, 3}
+	r, clk, events := newManualReassembler(ReassemblerOptions{Timeout: 10 * time.Second, MaxOutOfOrderPerSDU: 2})
+	// 服务级计数包括其它测试留在拼接器中的片段，只比较本测试前后的差值
+	base := BufferedFragments()
+
+	// PSEQ=1 缺失，超前的片段进入乱序缓存；超出单个 SDU 的上限时回收最早暂存的 PSEQ=2
+	process(t, r,
+		fragment(id, 6, 0, fragFlagFirst, 0),
+		fragment(id, 6, 2, fragFlagMiddle, 2),
+		fragment(id, 6, 3, fragFlagMiddle, 3),
+		fragment(id, 6, 4, fragFlagLast, 4),
+	)
+	if got := dropReasons(events, id); !reflect.DeepEqual(got, []DropReason{DropOutOfOrderEvicted}) {
+		t.Fatalf("丢弃 %v，期望 [out-of-order-evicted]", got)
+	}
+
+	// 缺片补到后 PSEQ=2 已被回收，SDU 无法完成，最终超时
+	process(t, r, fragment(id, 6, 1, fragFlagMiddle, 1))
+	if f := output(r); f != nil {
+		t.Fatalf("缺片的 SDU 被输出: % X", f.Data)
+	}
+	clk.Advance(10 * time.Second)
+	if got := dropReasons(events, id); !reflect.DeepEqual(got, []DropReason{DropTimeout}) {
+		t.Errorf("丢弃 %v，期望 [timeout]", got)
+	}
+	if n := BufferedFragments() - base; n != 0 {
+		t.Errorf("超时后乱序缓存仍有 %d 个片段", n)
+	}
+}
+
+func TestReassemblePreFirstExpired(t *testing.T) {
+	id := [6]byte{0x66, 4}
+	auto := PSEQStart{Auto: true}
+	r, clk, events := newManualReassembler(ReassemblerOptions{PSEQStart: &auto, PreFirstWindow: time.Second})
+
+	// 起点序号不确定时，首片之前到达的片段暂存一个窗口，未等到首片则丢弃
+	process(t, r, fragment(id, 7, 5, fragFlagMiddle, 5), fragment(id, 7, 6, fragFlagLast, 6))
+	clk.Advance(time.Second)
+	if got := dropReasons(events, id); !reflect.DeepEqual(got, []DropReason{DropPreFirstExpired, DropPreFirstExpired}) {
+		t.Fatalf("丢弃 %v，期望两个 pre-first-expired", got)
+	}
+
+	// 窗口内到达的首片接上暂存的片段
+	process(t, r, fragment(id, 8, 5, fragFlagMiddle, 5), fragment(id, 8, 6, fragFlagLast, 6))
+	clk.Advance(time.Second / 2)
+	process(t, r, fragment(id, 8, 4, fragFlagFirst, 4))
+	if f := output(r); f == nil || !bytes.Equal(f.Data, []byte{4, 5, 6}) {
+		t.Errorf("输出 %+v，期望 SDU 04 05 06", f)
+	}
+	clk.Advance(time.Second)
+	if got := dropReasons(events, id); len(got) != 0 {
+		t.Errorf("首片接上后仍丢弃 %v", got)
+	}
+}
@@ -0,0 +1,52 @@
+package frameparser
+
+import (
+	"time"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/config"
+)
+
+// ValueSink 接收解析出的读数。值表、MQTT 转发、本地归档、测试桩等都实现为 Sink，
+// 由 Pipeline 注入，解析代码不依赖具体去向。
+// tags 至少包含 sensorId 和 traceId，Sink 不应修改它。
+type ValueSink interface {
+	SetValue(deviceName, resourceName string, value any, origin time.Time, tags map[string]string)
+}
+
+// ValueSinkFunc 把普通函数适配为 ValueSink
+type ValueSinkFunc func(deviceName, resourceName string, value any, origin time.Time, tags map[string]string)
+
+// SetValue 实现 ValueSink
+func (f ValueSinkFunc) SetValue(deviceName, resourceName string, value any, origin time.Time, tags map[string]string) {
+	f(deviceName, resourceName, value, origin, tags)
+}
+
+// ConfigSink 将读数及其 quality 标签写入 config 包的运行时值表，供 HandleReadCommands 返回
+type ConfigSink struct{}
+
+// SetValue 实现 ValueSink
+func (ConfigSink) SetValue(deviceName, resourceName string, value any, origin time.Time, tags map[string]string) {
+	config.SetDeviceValue(deviceName, resourceName, value)
+	config.SetValueQualityAt(deviceName, resourceName, tags[config.QualityTag], origin)
+}
+
+// MultiSink 依次把读数交给多个 Sink
+type MultiSink []ValueSink
+
+// SetValue 实现 ValueSink
+func (m MultiSink) SetValue(deviceName, resourceName string, value any, origin time.Time, tags map[string]string) {
+	for _, s := range m {
+		s.SetValue(deviceName, resourceName, value, origin, tags)
+	}
+}
+
+// handlerSink 通知通过 AddValueHandler 注册的旧式订阅者
+type handlerSink struct{}
+
+// SetValue 实现 ValueSink
+func (handlerSink) SetValue(deviceName, resourceName string, value any, origin time.Time, _ map[string]string) {
+	notifyValue(deviceName, resourceName, value, origin.UnixNano())
+}
+
+// DefaultSink 未指定 Sink 时使用：写入值表并通知 AddValueHandler 注册的订阅者
+var DefaultSink ValueSink = MultiSink{ConfigSink{}, handlerSink{}}
@@ -0,0 +1,33 @@
+package frameparser
+
+import (
+	"sync"
+	"time"
+)
+
+// SSEQFilter 在 SDU 拼接完成、输出之前调用：返回 false 表示该传感器的这个 SSEQ 刚拼接完成过
+// （空口重传或重放），丢弃并记为 DropDuplicateSDU
+type SSEQFilter func(sensorID string, sseq uint8, now time.Time) bool
+
+var (
+	sseqFilterMu sync.RWMutex
+	sseqFilter   SSEQFilter
+)
+
+// SetSSEQFilter 设置拼接完成 SDU 的去重判断，传 nil 关闭；对未设置 ReassemblerOptions.SSEQFilter 的拼接器生效
+func SetSSEQFilter(f SSEQFilter) {
+	sseqFilterMu.Lock()
+	defer sseqFilterMu.Unlock()
+	sseqFilter = f
+}
+
+// acceptSSEQ 按生效的去重判断决定是否输出拼接完成的 SDU，未设置时总是输出
+func (r *Reassembler) acceptSSEQ(sensorID [6]byte, sseq uint8, now time.Time) bool {
+	f := r.opts.SSEQFilter
+	if f == nil {
+		sseqFilterMu.RLock()
+		f = sseqFilter
+		sseqFilterMu.RUnlock()
+	}
+	return f == nil || f(sensorHex(sensorID), sseq, now)
+}
@@ -0,0 +1,97 @@
+package frameparser
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stats 解析流水线计数在某一时刻的快照，Since 为计数起点（启动或上次 ResetStats）
+type Stats struct {
+	Since              time.Time             `json:"since"`
+	FilteredDenied     uint64                `json:"filteredDenied"`
+	FilteredNotAllowed uint64                `json:"filteredNotAllowed"`
+	ParseErrors        map[string]uint64     `json:"parseErrors"`
+	ReassemblyDrops    map[DropReason]uint64 `json:"reassemblyDrops"`
+	// BufferedFragments 为当前值，不随 ResetStats 清零
+	BufferedFragments int64 `json:"bufferedFragments"`
+	// Latency 帧从串口读出到读数交给 Sink 的时延
+	Latency LatencyStats `json:"latency"`
+}
+
+// LatencyStats 接收到发布的时延统计
+type LatencyStats struct {
+	Count  uint64  `json:"count"`
+	MeanMs float64 `json:"meanMs"`
+	MaxMs  float64 `json:"maxMs"`
+}
+
+var (
+	statsSinceMu sync.Mutex
+	statsSince   = time.Now()
+
+	// 接收到发布的时延：帧数、累计和最大值（纳秒）
+	latencyCount atomic.Uint64
+	latencyTotal atomic.Int64
+	latencyMax   atomic.Int64
+)
+
+// observeLatency 记录一帧从接收到发布的时延
+func observeLatency(d time.Duration) {
+	latencyCount.Add(1)
+	latencyTotal.Add(int64(d))
+	for {
+		cur := latencyMax.Load()
+		if int64(d) <= cur || latencyMax.CompareAndSwap(cur, int64(d)) {
+			return
+		}
+	}
+}
+
+// snapshotLatency 返回当前的时延统计
+func snapshotLatency() LatencyStats {
+	n := latencyCount.Load()
+	st := LatencyStats{Count: n, MaxMs: float64(latencyMax.Load()) / float64(time.Millisecond)}
+	if n > 0 {
+		st.MeanMs = float64(latencyTotal.Load()) / float64(n) / float64(time.Millisecond)
+	}
+	return st
+}
+
+// SnapshotStats 返回当前的解析流水线计数
+func SnapshotStats() Stats {
+	statsSinceMu.Lock()
+	since := statsSince
+	statsSinceMu.Unlock()
+	denied, notAllowed := FilteredCounts()
+	return Stats{
+		Since:              since,
+		FilteredDenied:     denied,
+		FilteredNotAllowed: notAllowed,
+		ParseErrors:        ErrorCounts(),
+		ReassemblyDrops:    DropCounts(),
+		BufferedFragments:  BufferedFragments(),
+		Latency:            snapshotLatency(),
+	}
+}
+
+// ResetStats 清零过滤、解析错误、拼接丢弃计数和时延统计（如新无线部署验收测试开始前）
+func ResetStats() {
+	filteredDenied.Store(0)
+	filteredNotAllowed.Store(0)
+	latencyCount.Store(0)
+	latencyTotal.Store(0)
+	latencyMax.Store(0)
+
+	errorCountsMu.Lock()
+	clear(errorCounts)
+	errorCountsMu.Unlock()
+
+	dropCountsMu.Lock()
+	clear(dropCounts)
+	dropCountsMu.Unlock()
+
+	statsSinceMu.Lock()
+	statsSince = time.Now()
+	statsSinceMu.Unlock()
+}
@@ -0,0 +1,106 @@
+package frameparser
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultLogThrottleInterval 重复错误日志的汇总周期
+const DefaultLogThrottleInterval = time.Minute
+
+// throttleKey 按错误类别 + SensorID 聚合
+type throttleKey struct {
+	kind     string
+	sensorID string
+}
+
+var (
+	throttleMu       sync.Mutex
+	throttleInterval = DefaultLogThrottleInterval
+	// throttleCounts 记录首次输出之后被抑制的次数
+	throttleCounts = make(map[throttleKey]int)
+	throttleOnce   sync.Once
+)
+
+// SetLogThrottleInterval 调整重复错误日志的汇总周期，须在 StartParser 之前调用
+func SetLogThrottleInterval(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	throttleMu.Lock()
+	throttleInterval = d
+	throttleMu.Unlock()
+}
+
+// throttledf 对同一类别、同一传感器的重复错误只输出首次，
+// 之后的次数累计并在每个汇总周期输出一条统计
+func throttledf(kind, sensorID, format string, args ...any) {
+	throttleOnce.Do(startThrottleSummary)
+
+	key := throttleKey{kind: kind, sensorID: sensorID}
+	throttleMu.Lock()
+	n, seen := throttleCounts[key]
+	if seen {
+		throttleCounts[key] = n + 1
+	} else {
+		// 首次出现直接输出，记为 0 次抑制
+		throttleCounts[key] = 0
+	}
+	throttleMu.Unlock()
+
+	if !seen {
+		log.Printf(format, args...)
+	}
+}
+
+// startThrottleSummary 启动后台协程，周期输出被抑制日志的统计
+func startThrottleSummary() {
+	throttleMu.Lock()
+	interval := throttleInterval
+	throttleMu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			flushThrottleSummary(interval)
+		}
+	}()
+}
+
+// flushThrottleSummary 输出并清空本周期的统计；
+// 本周期无重复的条目被移除，下次出现时重新输出首条日志
+func flushThrottleSummary(interval time.Duration) {
+	type line struct {
+		key   throttleKey
+		count int
+	}
+	var lines []line
+
+	throttleMu.Lock()
+	for k, n := range throttleCounts {
+		if n == 0 {
+			delete(throttleCounts, k)
+			continue
+		}
+		lines = append(lines, line{k, n})
+		throttleCounts[k] = 0
+	}
+	throttleMu.Unlock()
+
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].key.kind != lines[j].key.kind {
+			return lines[i].key.kind < lines[j].key.kind
+		}
+		return lines[i].key.sensorID < lines[j].key.sensorID
+	})
+	for _, l := range lines {
+		if l.key.sensorID == "" {
+			log.Printf("⚠️ 近 %s 内「%s」又出现 %d 次", interval, l.key.kind, l.count)
+		} else {
+			log.Printf("⚠️ 近 %s 内「%s」又出现 %d 次 SensorID=%s", interval, l.key.kind, l.count, l.key.sensorID)
+		}
+	}
+}
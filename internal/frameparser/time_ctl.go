@@ -50,12 +50,7 @@ func BuildTimeParamFrame(
 	buf = append(buf, tsBytes...)
 
 	// 6. CRC16 校验位（大端序）
-	crc := CRC16(buf)
-	crcBytes := make([]byte, 2)
-	binary.BigEndian.PutUint16(crcBytes, crc)
-	buf = append(buf, crcBytes...)
-
-	return buf, nil
+	return StandardDialect.Seal(buf), nil
 }
 
 //----------------------------------------------------------exmaple----------------------------------------------------------
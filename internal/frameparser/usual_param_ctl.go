@@ -3,8 +3,6 @@ package frameparser
 // 封装 7.2 节 传感器通用参数查询/设置报文
 
 import (
-	"bytes"
-	"encoding/binary"
 	"fmt"
 
 	"github.com/linjuya-lu/device-lpmp-go/internal/config"
@@ -19,6 +17,78 @@ const (
 	maxParams = 16
 )
 
+// ControlFrameBuilder 按参数名逐个添加取值，构造“通用参数查询/设置”报文：
+// 参量类型和数据长度取自参数表（config.GetEntryCopy），取值按数据类型编码为报文格式
+type ControlFrameBuilder struct {
+	sensorID [6]byte
+	params   []Param
+	names    map[string]bool
+}
+
+// NewGeneralParamBuilder 创建指定传感器的通用参数报文构造器
+func NewGeneralParamBuilder(sensorID [6]byte) *ControlFrameBuilder {
+	return &ControlFrameBuilder{sensorID: sensorID, names: make(map[string]bool)}
+}
+
+// AddParam 添加一个待设置的参数：value 为 []byte 时视为已编码的原始数据，长度须与参数表一致；
+// 其余按参数表中该参数的 Encode 编码（如 float32 → 4 字节小端），类型或范围不符时报错
+func (b *ControlFrameBuilder) AddParam(name string, value any) error {
+	if b.names[name] {
+		return fmt.Errorf("参数 %q 重复添加", name)
+	}
+	if len(b.params) >= MaxExtendedParams {
+		return fmt.Errorf("一帧最多设置 %d 个参数", MaxExtendedParams)
+	}
+	entry, err := config.GetEntryCopy(name)
+	if err != nil {
+		return fmt.Errorf("参数 %q 不在参数表中: %w", name, err)
+	}
+	data, err := encodeEntryValue(name, entry, value)
+	if err != nil {
+		return err
+	}
+	b.params = append(b.params, Param{Type: entry.Head16 >> 2, Data: data})
+	b.names[name] = true
+	return nil
+}
+
+// Len 返回已添加的参数个数
+func (b *ControlFrameBuilder) Len() int {
+	return len(b.params)
+}
+
+// Build 构造报文：未添加参数时为“查询全部通用参数”（RequestSetFlag=0，DataLen=0b1111），
+// 否则为按添加顺序的参数设置（RequestSetFlag=1）
+func (b *ControlFrameBuilder) Build() ([]byte, error) {
+	if len(b.params) > 0 {
+		return BuildParamSetFrame(b.sensorID, b.params)
+	}
+
+	// SensorID(6B) + head(DataLen=0xF|FragInd=0|PacketType) + CtrlType<<1|0
+	buf := make([]byte, 0, 6+1+1+2)
+	buf = append(buf, b.sensorID[:]...)
+	buf = append(buf, byte(DataLenExtended<<4)|byte(packetTypeControl&0x07))
+	buf = append(buf, byte((ctrlTypeGeneralParams&0x7F)<<1))
+
+	// CRC16（大端）
+	return StandardDialect.Seal(buf), nil
+}
+
+// encodeEntryValue 按参数表条目把 value 编码为 entry.Length 字节的报文数据
+func encodeEntryValue(name string, entry config.Entry, value any) ([]byte, error) {
+	if raw, ok := value.([]byte); ok {
+		if len(raw) != entry.Length {
+			return nil, fmt.Errorf("参数 %q 长度错误: want %d, got %d", name, entry.Length, len(raw))
+		}
+		return append([]byte(nil), raw...), nil
+	}
+	info, ok := config.LookupParamInfo(entry.Head16 >> 2)
+	if !ok {
+		return nil, fmt.Errorf("参数 %q 的类型码 0x%04X 不在参数表中", name, entry.Head16>>2)
+	}
+	return config.EncodeParamValue(info, value)
+}
+
 // BuildGeneralParamFrame 构造“通用参数查询/设置”报文。
 //
 //	sensorID:        6 字节传感器 ID
@@ -27,72 +97,23 @@ const (
 //	paramsOrder:     设 requestSetFlag=1 时，按此顺序列出要查询/设置的参数名
 //	paramsMap:       map[参数名]→[]byte（对应参数的数据内容）
 //
-// 返回：完整帧字节切片（含 CRC16）
+// 返回：完整帧字节切片（含 CRC16）。新代码请使用 NewGeneralParamBuilder。
 func BuildGeneralParamFrame(sensorID [6]byte, requestSetFlag byte, paramsOrder []string, paramsMap map[string][]byte) ([]byte, error) {
-	// 1. 确定 DataLen 和 ParameterList
-	var dataLen byte
-	var parameterList []byte
-
+	b := NewGeneralParamBuilder(sensorID)
 	if requestSetFlag == 0 {
-		// 查询所有通用参数：DataLen=0b1111，不附带 ParameterList
-		dataLen = 0x0F
-	} else {
-		m := len(paramsOrder)
-		if m == 0 || m > maxParams {
-			return nil, fmt.Errorf("参数个数必须 1~%d, got %d", maxParams, m)
+		return b.Build()
+	}
+	if m := len(paramsOrder); m == 0 || m > maxParams {
+		return nil, fmt.Errorf("%w: 必须 1~%d, got %d", ErrParamCount, maxParams, m)
+	}
+	for _, name := range paramsOrder {
+		val, ok := paramsMap[name]
+		if !ok {
+			return nil, fmt.Errorf("缺少参数 %q 的值", name)
 		}
-		dataLen = byte(m & 0x0F)
-
-		// 构造 ParameterList: 每个参数名对应 head16(2B little-endian) + data
-		buf := &bytes.Buffer{}
-		for _, name := range paramsOrder {
-			// 先拿到当前表中对应的 entry 副本
-			entry, err := config.GetEntryCopy(name)
-			if err != nil {
-				return nil, err
-			}
-			// 再更新 entry.data 为调用者传来的值
-			val, ok := paramsMap[name]
-			if !ok {
-				return nil, fmt.Errorf("缺少参数 %q 的值", name)
-			}
-			if len(val) != entry.Length {
-				return nil, fmt.Errorf("参数 %q 长度错误: want %d, got %d", name, entry.Length, len(val))
-			}
-			entry.Data = make([]byte, entry.Length)
-			copy(entry.Data, val)
-
-			// 将 head16(小端) 写入
-			le := make([]byte, 2)
-			binary.LittleEndian.PutUint16(le, entry.Head16)
-			buf.Write(le)
-			// 将 data 写入
-			buf.Write(entry.Data)
+		if err := b.AddParam(name, val); err != nil {
+			return nil, err
 		}
-		parameterList = buf.Bytes()
-	}
-
-	// 2. 构建前导头：SensorID(6B) + head(1B)
-	//    head = DataLen(4b)<<4 | FragInd(1b=0)<<3 | PacketType(3b)
-	head := byte((dataLen&0x0F)<<4) | byte(packetTypeControl&0x07)
-
-	// 3. 构建 CtrlType+RequestSetFlag(1b)
-	ctrlByte := byte((ctrlTypeGeneralParams&0x7F)<<1) | (requestSetFlag & 0x01)
-
-	// 4. 汇总所有字段，准备计算 CRC
-	buf := &bytes.Buffer{}
-	buf.Write(sensorID[:])
-	buf.WriteByte(head)
-	buf.WriteByte(ctrlByte)
-	if requestSetFlag == 1 {
-		buf.Write(parameterList)
 	}
-
-	// 5. 计算并追加 CRC16（大端）
-	crc := CRC16(buf.Bytes())
-	crcb := make([]byte, 2)
-	binary.BigEndian.PutUint16(crcb, crc)
-	buf.Write(crcb)
-
-	return buf.Bytes(), nil
+	return b.Build()
 }
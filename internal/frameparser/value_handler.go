@@ -0,0 +1,30 @@
+package frameparser
+
+import "sync"
+
+// ValueHandler 在参数解析成功并写入运行时值表后被调用，
+// 用于把解析结果转发给 MQTT、本地归档等旁路订阅者。
+// origin 为纳秒时间戳。
+type ValueHandler func(deviceName, resourceName string, value any, origin int64)
+
+var (
+	handlersMu    sync.RWMutex
+	valueHandlers []ValueHandler
+)
+
+// AddValueHandler 注册一个解析结果订阅者，需在 StartParser 之前调用；
+// 只有使用 DefaultSink 的流水线会通知这些订阅者，新代码应实现 ValueSink
+func AddValueHandler(h ValueHandler) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	valueHandlers = append(valueHandlers, h)
+}
+
+// notifyValue 依次通知所有已注册的订阅者
+func notifyValue(deviceName, resourceName string, value any, origin int64) {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+	for _, h := range valueHandlers {
+		h(deviceName, resourceName, value, origin)
+	}
+}
@@ -0,0 +1,188 @@
+// Package mqttpub 将解析后的传感器读数转发到外部 MQTT Broker，
+// 供不接入 EdgeX 消息总线、直接消费传感器数据的现场系统使用。
+package mqttpub
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/clients/logger"
+)
+
+// 默认主题模板，{deviceName}、{resource} 会被替换为实际的设备名和资源名
+const DefaultTopicTemplate = "lpmp/{deviceName}/{resource}"
+
+// Driver 配置段中与 MQTT 转发相关的键名
+const (
+	keyEnabled       = "MqttRepublishEnabled"
+	keyBrokerURL     = "MqttBrokerUrl"
+	keyClientID      = "MqttClientId"
+	keyUsername      = "MqttUsername"
+	keyPassword      = "MqttPassword"
+	keyTopicTemplate = "MqttTopicTemplate"
+	keyQos           = "MqttQos"
+	keyRetain        = "MqttRetain"
+)
+
+// Config 保存 MQTT 转发的连接和发布参数
+type Config struct {
+	Enabled       bool
+	BrokerURL     string
+	ClientID      string
+	Username      string
+	Password      string
+	TopicTemplate string
+	Qos           byte
+	Retain        bool
+	// TLS Broker 地址为 ssl:// 等加密连接时使用的客户端配置，由驱动按 MqttTLS* 配置生成
+	TLS *tls.Config
+}
+
+// ConfigFromDriver 从 sdk.DriverConfigs() 返回的 Driver 配置段中读取 MQTT 转发配置，
+// 未配置的项使用默认值
+func ConfigFromDriver(driverCfg map[string]string) (Config, error) {
+	cfg := Config{
+		BrokerURL:     driverCfg[keyBrokerURL],
+		ClientID:      driverCfg[keyClientID],
+		Username:      driverCfg[keyUsername],
+		Password:      driverCfg[keyPassword],
+		TopicTemplate: driverCfg[keyTopicTemplate],
+	}
+	if v := driverCfg[keyEnabled]; v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return cfg, fmt.Errorf("%s 配置无效 %q：%w", keyEnabled, v, err)
+		}
+		cfg.Enabled = b
+	}
+	if v := driverCfg[keyQos]; v != "" {
+		q, err := strconv.ParseUint(v, 10, 8)
+		if err != nil || q > 2 {
+			return cfg, fmt.Errorf("%s 配置无效 %q，必须为 0~2", keyQos, v)
+		}
+		cfg.Qos = byte(q)
+	}
+	if v := driverCfg[keyRetain]; v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return cfg, fmt.Errorf("%s 配置无效 %q：%w", keyRetain, v, err)
+		}
+		cfg.Retain = b
+	}
+	if cfg.TopicTemplate == "" {
+		cfg.TopicTemplate = DefaultTopicTemplate
+	}
+	if cfg.ClientID == "" {
+		cfg.ClientID = "device-lpmp-republish"
+	}
+	if cfg.Enabled && cfg.BrokerURL == "" {
+		return cfg, fmt.Errorf("已启用 MQTT 转发，但未配置 %s", keyBrokerURL)
+	}
+	return cfg, nil
+}
+
+// Reading 是发布到 MQTT 的 JSON 负载
+type Reading struct {
+	DeviceName   string `json:"deviceName"`
+	ResourceName string `json:"resourceName"`
+	Value        any    `json:"value"`
+	Origin       int64  `json:"origin"`
+}
+
+// Publisher 维护到外部 Broker 的连接并发布读数
+type Publisher struct {
+	cfg    Config
+	client mqtt.Client
+	lc     logger.LoggingClient
+}
+
+// New 按配置建立 MQTT 连接；连接断开后由客户端自动重连
+func New(cfg Config, lc logger.LoggingClient) (*Publisher, error) {
+	opts := mqtt.NewClientOptions().
+		AddBroker(cfg.BrokerURL).
+		SetClientID(cfg.ClientID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(5 * time.Second).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			lc.Warnf("MQTT 转发连接断开：%v", err)
+		})
+
+	if cfg.TLS != nil {
+		opts.SetTLSConfig(cfg.TLS)
+	}
+
+	client := mqtt.NewClient(opts)
+	token := client.Connect()
+	// SetConnectRetry 下 Connect 不会因 Broker 暂不可达而失败，这里只等待首次尝试
+	if token.WaitTimeout(10*time.Second) && token.Error() != nil {
+		return nil, fmt.Errorf("连接 MQTT Broker %s 失败：%w", cfg.BrokerURL, token.Error())
+	}
+
+	return &Publisher{cfg: cfg, client: client, lc: lc}, nil
+}
+
+// Topic 根据主题模板生成指定设备资源的发布主题
+func (p *Publisher) Topic(deviceName, resourceName string) string {
+	return RenderTopic(p.cfg.TopicTemplate, deviceName, resourceName)
+}
+
+// RenderTopic 将模板中的 {deviceName}、{resource} 占位符替换为实际值
+func RenderTopic(tmpl, deviceName, resourceName string) string {
+	return strings.NewReplacer(
+		"{deviceName}", deviceName,
+		"{resource}", resourceName,
+	).Replace(tmpl)
+}
+
+// Connected 判断当前是否连接着 Broker
+func (p *Publisher) Connected() bool {
+	return p.client.IsConnected()
+}
+
+// Publish 以 JSON 格式发布单个读数，不阻塞调用方等待 Broker 确认；
+// 未连接时不发布并返回 false，由调用方决定丢弃还是暂存
+func (p *Publisher) Publish(deviceName, resourceName string, value any, origin int64) bool {
+	if !p.client.IsConnected() {
+		p.lc.Debugf("MQTT 转发未连接，未发布读数 %s.%s", deviceName, resourceName)
+		return false
+	}
+	payload, err := json.Marshal(Reading{
+		DeviceName:   deviceName,
+		ResourceName: resourceName,
+		Value:        value,
+		Origin:       origin,
+	})
+	if err != nil {
+		p.lc.Errorf("序列化读数 %s.%s 失败：%v", deviceName, resourceName, err)
+		return true
+	}
+	p.client.Publish(p.Topic(deviceName, resourceName), p.cfg.Qos, p.cfg.Retain, payload)
+	return true
+}
+
+// PublishJSON 以 JSON 格式向指定主题发布任意负载（如诊断数据），不使用主题模板
+func (p *Publisher) PublishJSON(topic string, v any) {
+	if !p.client.IsConnected() {
+		p.lc.Debugf("MQTT 转发未连接，丢弃发往 %s 的消息", topic)
+		return
+	}
+	payload, err := json.Marshal(v)
+	if err != nil {
+		p.lc.Errorf("序列化发往 %s 的消息失败：%v", topic, err)
+		return
+	}
+	p.client.Publish(topic, p.cfg.Qos, false, payload)
+}
+
+// Close 断开与 Broker 的连接
+func (p *Publisher) Close() {
+	p.client.Disconnect(250)
+}
@@ -0,0 +1,121 @@
+package mqttpub
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/clients/logger"
+)
+
+type message struct {
+	topic    string
+	qos      byte
+	retained bool
+	payload  []byte
+}
+
+// fakeClient 记录发布的消息；未实现的方法调用时 panic
+type fakeClient struct {
+	mqtt.Client
+	connected bool
+	sent      []message
+}
+
+func (c *fakeClient) IsConnected() bool { return c.connected }
+
+func (c *fakeClient) Publish(topic string, qos byte, retained bool, payload any) mqtt.Token {
+	c.sent = append(c.sent, message{topic, qos, retained, payload.([]byte)})
+	return nil
+}
+
+func TestConfigFromDriver(t *testing.T) {
+	cfg, err := ConfigFromDriver(map[string]string{})
+	if err != nil || cfg.Enabled || cfg.TopicTemplate != DefaultTopicTemplate || cfg.ClientID != "device-lpmp-republish" || cfg.Qos != 0 || cfg.Retain {
+		t.Errorf("默认配置 %+v, %v", cfg, err)
+	}
+	cfg, err = ConfigFromDriver(map[string]string{
+		keyEnabled: "true", keyBrokerURL: "tcp://broker:1883", keyClientID: "site-a", keyUsername: "u", keyPassword: "p",
+		keyTopicTemplate: "site-a/{resource}/{deviceName}", keyQos: "2", keyRetain: "true",
+	})
+	want := Config{
+		Enabled: true, BrokerURL: "tcp://broker:1883", ClientID: "site-a", Username: "u", Password: "p",
+		TopicTemplate: "site-a/{resource}/{deviceName}", Qos: 2, Retain: true,
+	}
+	if err != nil || !reflect.DeepEqual(cfg, want) {
+		t.Errorf("配置 %+v, %v", cfg, err)
+	}
+	for _, bad := range []map[string]string{
+		{keyEnabled: "yes please"},
+		{keyQos: "3"},
+		{keyQos: "-1"},
+		{keyRetain: "sometimes"},
+		{keyEnabled: "true"}, // 未配置 Broker 地址
+	} {
+		if _, err := ConfigFromDriver(bad); err == nil {
+			t.Errorf("%v 未报错", bad)
+		}
+	}
+}
+
+func TestRenderTopic(t *testing.T) {
+	for _, c := range []struct{ tmpl, want string }{
+		{DefaultTopicTemplate, "lpmp/tank-1/level"},
+		{"{deviceName}/{deviceName}/{resource}", "tank-1/tank-1/level"},
+		{"fixed/topic", "fixed/topic"},
+		{"{device}/{resource}", "{device}/level"},
+	} {
+		if got := RenderTopic(c.tmpl, "tank-1", "level"); got != c.want {
+			t.Errorf("RenderTopic(%q)=%q，期望 %q", c.tmpl, got, c.want)
+		}
+	}
+}
+
+// TestPublish 已连接时按模板主题发布 JSON 读数，带配置的 QoS 和 Retain；未连接时不发布并返回 false
+func TestPublish(t *testing.T) {
+	client := &fakeClient{}
+	p := &Publisher{cfg: Config{TopicTemplate: DefaultTopicTemplate, Qos: 1, Retain: true}, client: client, lc: logger.NewMockClient()}
+
+	if p.Publish("tank-1", "level", 1.5, 1760000000000000000) || len(client.sent) != 0 {
+		t.Fatalf("未连接时发布了 %+v", client.sent)
+	}
+	client.connected = true
+	if !p.Publish("tank-1", "level", 1.5, 1760000000000000000) {
+		t.Fatal("已连接时返回 false")
+	}
+	if len(client.sent) != 1 {
+		t.Fatalf("发布 %d 条，期望 1 条", len(client.sent))
+	}
+	m := client.sent[0]
+	if m.topic != "lpmp/tank-1/level" || m.qos != 1 || !m.retained {
+		t.Errorf("发布参数 %+v", m)
+	}
+	var got Reading
+	if err := json.Unmarshal(m.payload, &got); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Reading{DeviceName: "tank-1", ResourceName: "level", Value: 1.5, Origin: 1760000000000000000}); got != want {
+		t.Errorf("负载 %+v，期望 %+v", got, want)
+	}
+
+	// 无法序列化的读数丢弃，但不算未连接
+	if !p.Publish("tank-1", "level", make(chan int), 0) || len(client.sent) != 1 {
+		t.Errorf("无法序列化的读数 发布 %d 条", len(client.sent))
+	}
+}
+
+// TestPublishJSON 诊断数据发往指定主题，不使用模板、不保留
+func TestPublishJSON(t *testing.T) {
+	client := &fakeClient{}
+	p := &Publisher{cfg: Config{TopicTemplate: DefaultTopicTemplate, Qos: 1, Retain: true}, client: client, lc: logger.NewMockClient()}
+	p.PublishJSON("lpmp/diag", map[string]int{"crcErrors": 3})
+	if len(client.sent) != 0 {
+		t.Fatalf("未连接时发布了 %+v", client.sent)
+	}
+	client.connected = true
+	p.PublishJSON("lpmp/diag", map[string]int{"crcErrors": 3})
+	if len(client.sent) != 1 || client.sent[0].topic != "lpmp/diag" || client.sent[0].retained || string(client.sent[0].payload) != `{"crcErrors":3}` {
+		t.Errorf("发布 %+v", client.sent)
+	}
+}
@@ -0,0 +1,133 @@
+// Package objstore 将较大的 SDU 载荷按内容寻址（SHA-256）保存到本地目录，
+// 读数中只携带对象引用、校验和与大小，应用服务按需经 REST 接口取回原始数据。
+package objstore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Driver 配置段中与对象存储相关的键名，驱动的 LpmpCustom 配置节映射到同名键
+const (
+	KeyThreshold = "LargeSDUThreshold"
+	KeyDir       = "ObjectStoreDir"
+)
+
+// URIScheme 对象引用的 URI 前缀，后接十六进制 SHA-256
+const URIScheme = "lpmp-object://sha256/"
+
+// ErrNotFound 对象不存在
+var ErrNotFound = errors.New("对象不存在")
+
+// Config 保存对象存储配置
+type Config struct {
+	// Threshold 拼接完成的 SDU 达到该字节数时改存对象，0 表示不启用
+	Threshold int
+	Dir       string
+}
+
+// Enabled 是否启用大 SDU 对象存储
+func (c Config) Enabled() bool {
+	return c.Threshold > 0
+}
+
+// ConfigFromDriver 从 Driver 配置段读取对象存储配置，未配置项使用默认值
+func ConfigFromDriver(driverCfg map[string]string) (Config, error) {
+	cfg := Config{Dir: "./objects"}
+	if v := driverCfg[KeyThreshold]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return cfg, fmt.Errorf("%s 配置无效 %q", KeyThreshold, v)
+		}
+		cfg.Threshold = n
+	}
+	if v := driverCfg[KeyDir]; v != "" {
+		cfg.Dir = v
+	}
+	return cfg, nil
+}
+
+// Ref 对象引用，作为读数值（JSON）发布
+type Ref struct {
+	URI    string `json:"uri"`
+	SHA256 string `json:"sha256"`
+	Size   int    `json:"size"`
+}
+
+// Store 内容寻址的本地对象存储，同一内容只保存一份；写入经临时文件改名，并发安全
+type Store struct {
+	cfg Config
+}
+
+// New 创建存储目录
+func New(cfg Config) (*Store, error) {
+	if err := os.MkdirAll(cfg.Dir, 0o750); err != nil {
+		return nil, fmt.Errorf("创建对象存储目录 %s 失败：%w", cfg.Dir, err)
+	}
+	return &Store{cfg: cfg}, nil
+}
+
+// Threshold 返回改存对象的 SDU 大小门限
+func (s *Store) Threshold() int {
+	return s.cfg.Threshold
+}
+
+// path 对象文件路径：<Dir>/<前两位>/<完整哈希>
+func (s *Store) path(sum string) string {
+	return filepath.Join(s.cfg.Dir, sum[:2], sum)
+}
+
+// Put 保存数据并返回引用，内容已存在时不重复写入
+func (s *Store) Put(data []byte) (Ref, error) {
+	h := sha256.Sum256(data)
+	sum := hex.EncodeToString(h[:])
+	ref := Ref{URI: URIScheme + sum, SHA256: sum, Size: len(data)}
+
+	p := s.path(sum)
+	if _, err := os.Stat(p); err == nil {
+		return ref, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o750); err != nil {
+		return Ref{}, fmt.Errorf("创建对象目录失败：%w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), sum+".tmp*")
+	if err != nil {
+		return Ref{}, fmt.Errorf("创建对象文件失败：%w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return Ref{}, fmt.Errorf("写入对象 %s 失败：%w", sum, err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return Ref{}, fmt.Errorf("写入对象 %s 失败：%w", sum, err)
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		os.Remove(tmp.Name())
+		return Ref{}, fmt.Errorf("保存对象 %s 失败：%w", sum, err)
+	}
+	return ref, nil
+}
+
+// Get 按十六进制 SHA-256 读取对象
+func (s *Store) Get(sum string) ([]byte, error) {
+	sum = strings.ToLower(sum)
+	if len(sum) != sha256.Size*2 {
+		return nil, fmt.Errorf("%w: %q", ErrNotFound, sum)
+	}
+	if _, err := hex.DecodeString(sum); err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrNotFound, sum)
+	}
+	data, err := os.ReadFile(s.path(sum))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, sum)
+	}
+	return data, err
+}
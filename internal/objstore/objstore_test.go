@@ -0,0 +1,76 @@
+package objstore
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPutGet(t *testing.T) {
+	dir := t.TempDir()
+	s, err := New(Config{Threshold: 1, Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := bytes.Repeat([]byte{0xA5}, 300)
+	ref, err := s.Put(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref.Size != len(data) || ref.URI != URIScheme+ref.SHA256 || len(ref.SHA256) != 64 {
+		t.Errorf("引用 %+v 不符", ref)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ref.SHA256[:2], ref.SHA256)); err != nil {
+		t.Errorf("对象文件不在 <前两位>/<哈希> 下: %v", err)
+	}
+	got, err := s.Get(strings.ToUpper(ref.SHA256))
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("Get = %d 字节, %v", len(got), err)
+	}
+
+	// 相同内容只保存一份，不留临时文件
+	again, err := s.Put(data)
+	if err != nil || again != ref {
+		t.Errorf("重复 Put = %+v, %v", again, err)
+	}
+	entries, _ := os.ReadDir(filepath.Join(dir, ref.SHA256[:2]))
+	if len(entries) != 1 {
+		t.Errorf("对象目录中有 %d 个文件，期望 1", len(entries))
+	}
+}
+
+func TestGetNotFound(t *testing.T) {
+	s, err := New(Config{Threshold: 1, Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sum := range []string{
+		"",
+		"../../etc/passwd",
+		strings.Repeat("z", 64),
+		strings.Repeat("ab", 32),
+	} {
+		if _, err := s.Get(sum); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%q) err=%v，期望 ErrNotFound", sum, err)
+		}
+	}
+}
+
+func TestConfigFromDriver(t *testing.T) {
+	cfg, err := ConfigFromDriver(map[string]string{})
+	if err != nil || cfg.Enabled() || cfg.Dir != "./objects" {
+		t.Errorf("默认配置 %+v, %v", cfg, err)
+	}
+	cfg, err = ConfigFromDriver(map[string]string{"LargeSDUThreshold": "4096", "ObjectStoreDir": "/var/lpmp"})
+	if err != nil || !cfg.Enabled() || cfg.Threshold != 4096 || cfg.Dir != "/var/lpmp" {
+		t.Errorf("配置 %+v, %v", cfg, err)
+	}
+	for _, v := range []string{"-1", "big"} {
+		if _, err := ConfigFromDriver(map[string]string{"LargeSDUThreshold": v}); err == nil {
+			t.Errorf("LargeSDUThreshold=%q 未报错", v)
+		}
+	}
+}
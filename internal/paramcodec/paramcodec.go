@@ -0,0 +1,185 @@
+// Package paramcodec 编解码报文中的参量：ParamType(14bit)+LengthFlag(2bit) 小端参数头、
+// 可选长度字段和数据。上行解析、下行构造与参数表共用同一套 LengthFlag 语义，见 LengthTable。
+package paramcodec
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrHeadOverflow 剩余字节不足以容纳参数头或长度字段
+	ErrHeadOverflow = errors.New("参数头越界")
+	// ErrDataOverflow 参数数据长度超出报文
+	ErrDataOverflow = errors.New("参数数据越界")
+	// ErrInvalidType 参量类型超出 14bit
+	ErrInvalidType = errors.New("参量类型超出 14bit 范围")
+	// ErrLength 数据长度无法用长度表中的任何 LengthFlag 表示
+	ErrLength = errors.New("参量数据长度无法编码")
+)
+
+// Param 报文中的一个参量：14bit 类型码 + 原始数据
+type Param struct {
+	Type uint16
+	Data []byte
+}
+
+// LengthTable 为每个 LengthFlag 规定数据长度的表示方式：大于 0 表示固定数据长度、没有长度字段；
+// 0 表示参数头后跟 LengthFlag 个字节（大端）的长度字段。LengthFlag=0 必须为固定长度。
+type LengthTable [4]int
+
+// Spec 为 Q/GDW 12184 附录 D 的规定：LengthFlag=0 默认 4 字节，1/2/3 后跟 1/2/3 字节长度字段
+var Spec = LengthTable{4, 0, 0, 0}
+
+// lengthField 长度字段的记法
+const lengthField = "len"
+
+// ParseLengthTable 解析 4 项逗号分隔的长度表，依次对应 LengthFlag 0~3：
+// 数字为固定数据长度，"len" 为长度字段，如规范 "4,len,len,len"、部分厂家 "4,1,2,3"；"spec" 或空串为规范
+func ParseLengthTable(s string) (LengthTable, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.EqualFold(s, "spec") {
+		return Spec, nil
+	}
+	items := strings.Split(s, ",")
+	if len(items) != 4 {
+		return LengthTable{}, fmt.Errorf("长度表须为 4 项（LengthFlag 0~3），得到 %q", s)
+	}
+	var t LengthTable
+	for flag, item := range items {
+		item = strings.TrimSpace(item)
+		if strings.EqualFold(item, lengthField) {
+			if flag == 0 {
+				return LengthTable{}, fmt.Errorf("LengthFlag=0 没有长度字段，须为固定长度")
+			}
+			continue
+		}
+		n, err := strconv.Atoi(item)
+		if err != nil || n <= 0 || n > 0xFFFFFF {
+			return LengthTable{}, fmt.Errorf("LengthFlag=%d 的长度须为正整数或 %q，得到 %q", flag, lengthField, item)
+		}
+		t[flag] = n
+	}
+	return t, nil
+}
+
+// String 按 ParseLengthTable 的格式输出
+func (t LengthTable) String() string {
+	items := make([]string, len(t))
+	for flag, n := range t {
+		if n == 0 {
+			items[flag] = lengthField
+		} else {
+			items[flag] = strconv.Itoa(n)
+		}
+	}
+	return strings.Join(items, ",")
+}
+
+// Decode 依次解出 count 个参量，返回已解出的参量、消耗的字节数和错误（出错时为出错前的结果）
+func (t LengthTable) Decode(data []byte, count int) ([]Param, int, error) {
+	params := make([]Param, 0, count)
+	idx := 0
+	for len(params) < count {
+		// 1. 参数头 2 字节（小端）
+		if idx+2 > len(data) {
+			return params, idx, ErrHeadOverflow
+		}
+		head16 := binary.LittleEndian.Uint16(data[idx : idx+2])
+		idx += 2
+		paramType := head16 >> 2
+		lenFlag := int(head16 & 0x3)
+
+		// 2. 数据长度：固定长度，或读取长度字段
+		dataLen := t[lenFlag]
+		if dataLen == 0 {
+			if idx+lenFlag > len(data) {
+				return params, idx - 2, fmt.Errorf("%w: type=0x%04X 缺少 %d 字节长度字段", ErrHeadOverflow, paramType, lenFlag)
+			}
+			for _, b := range data[idx : idx+lenFlag] {
+				dataLen = dataLen<<8 | int(b)
+			}
+			idx += lenFlag
+		}
+
+		// 3. 数据
+		if idx+dataLen > len(data) {
+			return params, idx, fmt.Errorf("%w: type=0x%04X 需要 %d 字节，剩余 %d 字节", ErrDataOverflow, paramType, dataLen, len(data)-idx)
+		}
+		params = append(params, Param{Type: paramType, Data: data[idx : idx+dataLen]})
+		idx += dataLen
+	}
+	return params, idx, nil
+}
+
+// lengthFlag 选择表示 n 字节数据的 LengthFlag：优先与 n 相等的固定长度（从 0 开始），
+// 否则选能容纳 n 的最短长度字段
+func (t LengthTable) lengthFlag(n int) (int, bool) {
+	for flag, fixed := range t {
+		if fixed > 0 && fixed == n {
+			return flag, true
+		}
+	}
+	for flag := 1; flag < len(t); flag++ {
+		if t[flag] == 0 && n < 1<<(8*flag) {
+			return flag, true
+		}
+	}
+	return 0, false
+}
+
+// Append 编码一个参量并追加到 buf，Decode 可原样解出
+func (t LengthTable) Append(buf []byte, p Param) ([]byte, error) {
+	if p.Type > 0x3FFF {
+		return nil, fmt.Errorf("%w: 0x%X", ErrInvalidType, p.Type)
+	}
+	n := len(p.Data)
+	lenFlag, ok := t.lengthFlag(n)
+	if !ok {
+		return nil, fmt.Errorf("%w: 参量 0x%04X 数据 %d 字节，长度表 %s", ErrLength, p.Type, n, t)
+	}
+	return t.appendHead(buf, p, lenFlag), nil
+}
+
+// AppendFlag 按指定的 LengthFlag 编码一个参量并追加到 buf，用于参数表尚未描述、须按厂家要求的
+// 长度表示下发的参量；LengthFlag 为固定长度时数据须恰为该长度，为长度字段时须能容纳数据长度
+func (t LengthTable) AppendFlag(buf []byte, p Param, lenFlag int) ([]byte, error) {
+	if p.Type > 0x3FFF {
+		return nil, fmt.Errorf("%w: 0x%X", ErrInvalidType, p.Type)
+	}
+	if lenFlag < 0 || lenFlag >= len(t) {
+		return nil, fmt.Errorf("%w: LengthFlag=%d 超出 0~%d", ErrLength, lenFlag, len(t)-1)
+	}
+	n := len(p.Data)
+	if fixed := t[lenFlag]; fixed > 0 && n != fixed {
+		return nil, fmt.Errorf("%w: 参量 0x%04X LengthFlag=%d 固定 %d 字节，数据 %d 字节", ErrLength, p.Type, lenFlag, fixed, n)
+	}
+	if t[lenFlag] == 0 && n >= 1<<(8*lenFlag) {
+		return nil, fmt.Errorf("%w: 参量 0x%04X 数据 %d 字节超出 LengthFlag=%d 的 %d 字节长度字段", ErrLength, p.Type, n, lenFlag, lenFlag)
+	}
+	return t.appendHead(buf, p, lenFlag), nil
+}
+
+// appendHead 写出参数头、长度字段（LengthFlag 不是固定长度时）和数据，调用方已检查 lenFlag 能表示数据长度
+func (t LengthTable) appendHead(buf []byte, p Param, lenFlag int) []byte {
+	n := len(p.Data)
+	buf = binary.LittleEndian.AppendUint16(buf, p.Type<<2|uint16(lenFlag))
+	if t[lenFlag] == 0 {
+		for i := lenFlag - 1; i >= 0; i-- {
+			buf = append(buf, byte(n>>(8*i)))
+		}
+	}
+	return append(buf, p.Data...)
+}
+
+// Head 返回按长度表编码 n 字节数据时的参数头和长度字段（不含数据）
+func (t LengthTable) Head(paramType uint16, n int) ([]byte, error) {
+	buf, err := t.Append(nil, Param{Type: paramType, Data: make([]byte, n)})
+	if err != nil {
+		return nil, err
+	}
+	return buf[:len(buf)-n], nil
+}
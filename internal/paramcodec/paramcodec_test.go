@@ -0,0 +1,221 @@
+package paramcodec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// vendor 部分厂家的长度表：LengthFlag 1/2/3 为固定 1/2/3 字节
+var vendor = LengthTable{4, 1, 2, 3}
+
+// data 返回 n 字节的测试数据
+func data(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i + 1)
+	}
+	return b
+}
+
+// TestRoundTrip 每个 LengthFlag 编码的参量都能原样解出
+func TestRoundTrip(t *testing.T) {
+	cases := []struct {
+		table LengthTable
+		sizes []int
+	}{
+		{Spec, []int{0, 1, 4, 5, 255, 256, 70000}},
+		{vendor, []int{1, 2, 3, 4}}, // 固定长度表只能表示表中的长度
+	}
+	for _, c := range cases {
+		for _, n := range c.sizes {
+			p := Param{Type: 0x3FFF, Data: bytes.Repeat([]byte{0xA5}, n)}
+			buf, err := c.table.Append(nil, p)
+			if err != nil {
+				t.Fatalf("长度表 %s Append(%d 字节): %v", c.table, n, err)
+			}
+			got, used, err := c.table.Decode(buf, 1)
+			if err != nil || used != len(buf) || len(got) != 1 || got[0].Type != p.Type || !bytes.Equal(got[0].Data, p.Data) {
+				t.Errorf("长度表 %s %d 字节: Decode=%v/%d/%v", c.table, n, got, used, err)
+			}
+		}
+	}
+	if _, err := vendor.Append(nil, Param{Type: 1, Data: make([]byte, 5)}); !errors.Is(err, ErrLength) {
+		t.Errorf("长度表 %s 编码 5 字节 err=%v，期望 ErrLength", vendor, err)
+	}
+}
+
+// TestSpecLengthField 规范长度表下 LengthFlag=1~3 后跟大端长度字段
+func TestSpecLengthField(t *testing.T) {
+	cases := []struct {
+		n    int
+		head []byte
+	}{
+		{4, []byte{0x04, 0x00}},               // type=1 LengthFlag=0
+		{2, []byte{0x05, 0x00, 0x02}},         // LengthFlag=1
+		{300, []byte{0x06, 0x00, 0x01, 0x2C}}, // LengthFlag=2
+	}
+	for _, c := range cases {
+		head, err := Spec.Head(1, c.n)
+		if err != nil || !bytes.Equal(head, c.head) {
+			t.Errorf("Head(1, %d)=% X %v，期望 % X", c.n, head, err, c.head)
+		}
+	}
+}
+
+func TestDecodeOverflow(t *testing.T) {
+	cases := []struct {
+		data []byte
+		want error
+	}{
+		{[]byte{0x04}, ErrHeadOverflow},
+		{[]byte{0x06, 0x00, 0x01}, ErrHeadOverflow},
+		{[]byte{0x04, 0x00, 0x01, 0x02}, ErrDataOverflow},
+	}
+	for _, c := range cases {
+		if _, _, err := Spec.Decode(c.data, 1); !errors.Is(err, c.want) {
+			t.Errorf("Decode(% X) err=%v，期望 %v", c.data, err, c.want)
+		}
+	}
+}
+
+func TestLengthFlagRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		table   LengthTable
+		lenFlag int
+		n       int
+		// field 长度字段（大端），固定长度时为空
+		field []byte
+	}{
+		{"spec/0", Spec, 0, 4, nil},
+		{"spec/1 空数据", Spec, 1, 0, []byte{0x00}},
+		{"spec/1", Spec, 1, 3, []byte{0x03}},
+		{"spec/1 最大", Spec, 1, 0xFF, []byte{0xFF}},
+		{"spec/2", Spec, 2, 0x100, []byte{0x01, 0x00}},
+		{"spec/2 最大", Spec, 2, 0xFFFF, []byte{0xFF, 0xFF}},
+		{"spec/3", Spec, 3, 0x10000, []byte{0x01, 0x00, 0x00}},
+		{"spec/3 短数据", Spec, 3, 2, []byte{0x00, 0x00, 0x02}},
+		{"vendor/0", vendor, 0, 4, nil},
+		{"vendor/1", vendor, 1, 1, nil},
+		{"vendor/2", vendor, 2, 2, nil},
+		{"vendor/3", vendor, 3, 3, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := Param{Type: 0x00A3, Data: data(tc.n)}
+			buf, err := tc.table.AppendFlag([]byte{0xEE}, p, tc.lenFlag)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// 参数头（小端）+ 长度字段 + 数据，追加在已有内容之后
+			wantHead := binary.LittleEndian.AppendUint16([]byte{0xEE}, 0x00A3<<2|uint16(tc.lenFlag))
+			want := append(append(wantHead, tc.field...), p.Data...)
+			if !bytes.Equal(buf, want) {
+				t.Fatalf("编码 % X，期望 % X", buf, want)
+			}
+
+			params, used, err := tc.table.Decode(buf[1:], 1)
+			if err != nil || used != len(buf)-1 || len(params) != 1 {
+				t.Fatalf("解码 %+v，消耗 %d 字节（共 %d）: %v", params, used, len(buf)-1, err)
+			}
+			if params[0].Type != p.Type || !bytes.Equal(params[0].Data, p.Data) {
+				t.Errorf("解出 type=0x%04X %d 字节，期望 type=0x%04X %d 字节", params[0].Type, len(params[0].Data), p.Type, tc.n)
+			}
+
+			// 截断任一字节都报越界，不会多读
+			for cut := 1; cut <= min(len(buf)-1, 4); cut++ {
+				if _, _, err := tc.table.Decode(buf[1:len(buf)-cut], 1); !errors.Is(err, ErrHeadOverflow) && !errors.Is(err, ErrDataOverflow) {
+					t.Errorf("截去 %d 字节: %v", cut, err)
+				}
+			}
+		})
+	}
+}
+
+func TestAppendChoosesLengthFlag(t *testing.T) {
+	for _, tc := range []struct {
+		table LengthTable
+		n     int
+		want  int
+	}{
+		{Spec, 4, 0},
+		{Spec, 0, 1},
+		{Spec, 1, 1},
+		{Spec, 0xFF, 1},
+		{Spec, 0x100, 2},
+		{Spec, 0xFFFF, 2},
+		{Spec, 0x10000, 3},
+		{vendor, 4, 0},
+		{vendor, 1, 1},
+		{vendor, 2, 2},
+		{vendor, 3, 3},
+	} {
+		buf, err := tc.table.Append(nil, Param{Type: 1, Data: data(tc.n)})
+		if err != nil {
+			t.Errorf("%s %d 字节: %v", tc.table, tc.n, err)
+			continue
+		}
+		if got := int(buf[0] & 0x3); got != tc.want {
+			t.Errorf("%s %d 字节: LengthFlag=%d，期望 %d", tc.table, tc.n, got, tc.want)
+		}
+		if params, _, err := tc.table.Decode(buf, 1); err != nil || len(params[0].Data) != tc.n {
+			t.Errorf("%s %d 字节: 解回 %+v, %v", tc.table, tc.n, params, err)
+		}
+	}
+}
+
+func TestLengthFlagErrors(t *testing.T) {
+	if _, err := Spec.Append(nil, Param{Type: 1, Data: make([]byte, 1<<24)}); !errors.Is(err, ErrLength) {
+		t.Errorf("超出 3 字节长度字段: %v", err)
+	}
+	if _, err := vendor.Append(nil, Param{Type: 1, Data: data(5)}); !errors.Is(err, ErrLength) {
+		t.Errorf("没有能表示 5 字节的 LengthFlag: %v", err)
+	}
+	if _, err := Spec.Append(nil, Param{Type: 0x4000}); !errors.Is(err, ErrInvalidType) {
+		t.Errorf("超出 14bit 的类型: %v", err)
+	}
+	for _, tc := range []struct {
+		table   LengthTable
+		lenFlag int
+		n       int
+	}{
+		{Spec, 0, 3},       // 固定 4 字节
+		{Spec, 1, 0x100},   // 1 字节长度字段
+		{Spec, 2, 0x10000}, // 2 字节长度字段
+		{vendor, 2, 3},     // 固定 2 字节
+		{Spec, 4, 1},       // LengthFlag 超出 0~3
+		{Spec, -1, 1},
+	} {
+		if _, err := tc.table.AppendFlag(nil, Param{Type: 1, Data: data(tc.n)}, tc.lenFlag); !errors.Is(err, ErrLength) {
+			t.Errorf("%s LengthFlag=%d %d 字节: %v", tc.table, tc.lenFlag, tc.n, err)
+		}
+	}
+}
+
+func TestParseLengthTable(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want LengthTable
+	}{
+		{"", Spec},
+		{"spec", Spec},
+		{"4,len,len,len", Spec},
+		{" 4, 1, 2, 3 ", vendor},
+		{"2,LEN,8,len", LengthTable{2, 0, 8, 0}},
+	} {
+		got, err := ParseLengthTable(tc.in)
+		if err != nil || got != tc.want {
+			t.Errorf("ParseLengthTable(%q) = %v, %v，期望 %v", tc.in, got, err, tc.want)
+			continue
+		}
+		if again, err := ParseLengthTable(got.String()); err != nil || again != got {
+			t.Errorf("%q → %q → %v, %v", tc.in, got.String(), again, err)
+		}
+	}
+	for _, in := range []string{"4,len,len", "len,1,2,3", "4,0,2,3", "4,x,2,3"} {
+		if got, err := ParseLengthTable(in); err == nil {
+			t.Errorf("ParseLengthTable(%q) = %v，期望错误", in, got)
+		}
+	}
+}
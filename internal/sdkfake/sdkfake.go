@@ -0,0 +1,501 @@
+// Package sdkfake 提供 interfaces.DeviceServiceSDK 的内存实现，供驱动单元测试在没有 EdgeX 环境时使用：
+// 设备和 profile 保存在内存中，日志使用 MockClient，异步读数写入带缓冲的通道，
+// 自定义路由、系统事件和设备运行状态均被记录下来，测试可直接检查或调用。
+package sdkfake
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"sync"
+
+	"github.com/edgexfoundry/device-sdk-go/v4/pkg/interfaces"
+	sdkModels "github.com/edgexfoundry/device-sdk-go/v4/pkg/models"
+	bootstrapInterfaces "github.com/edgexfoundry/go-mod-bootstrap/v4/bootstrap/interfaces"
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/dtos"
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/models"
+	"github.com/labstack/echo/v4"
+)
+
+// asyncBufferSize 异步读数通道的缓冲，测试未及时读取时驱动不至于阻塞
+const asyncBufferSize = 1024
+
+// ErrNotFound 设备、profile 或 ProvisionWatcher 不存在
+var ErrNotFound = errors.New("不存在")
+
+// Event 一次 PublishGenericSystemEvent 调用
+type Event struct {
+	Type    string
+	Action  string
+	Details any
+}
+
+// route 一个已注册的自定义路由
+type route struct {
+	auth    interfaces.Authentication
+	handler func(echo.Context) error
+	methods []string
+}
+
+// SDK interfaces.DeviceServiceSDK 的内存实现，并发安全
+type SDK struct {
+	lc      logger.LoggingClient
+	asyncCh chan *sdkModels.AsyncValues
+	discCh  chan []sdkModels.DiscoveredDevice
+
+	mu            sync.Mutex
+	driverConfigs map[string]string
+	custom        any
+	devices       map[string]models.Device
+	profiles      map[string]models.DeviceProfile
+	watchers      map[string]models.ProvisionWatcher
+	routes        map[string]route
+	events        []Event
+}
+
+var _ interfaces.DeviceServiceSDK = (*SDK)(nil)
+
+// New 创建 SDK，driverConfigs 为 Driver 配置段
+func New(driverConfigs map[string]string) *SDK {
+	cfg := make(map[string]string, len(driverConfigs))
+	for k, v := range driverConfigs {
+		cfg[k] = v
+	}
+	return &SDK{
+		lc:            logger.NewMockClient(),
+		asyncCh:       make(chan *sdkModels.AsyncValues, asyncBufferSize),
+		discCh:        make(chan []sdkModels.DiscoveredDevice, 1),
+		driverConfigs: cfg,
+		devices:       make(map[string]models.Device),
+		profiles:      make(map[string]models.DeviceProfile),
+		watchers:      make(map[string]models.ProvisionWatcher),
+		routes:        make(map[string]route),
+	}
+}
+
+// SetCustomConfig 设置 LoadCustomConfig 返回的自定义配置，raw 须为驱动 UpdateFromRaw 接受的类型
+func (s *SDK) SetCustomConfig(raw any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.custom = raw
+}
+
+// Events 返回已发布的系统事件，eventType 非空时只返回该类型
+func (s *SDK) Events(eventType string) []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []Event
+	for _, e := range s.events {
+		if eventType == "" || e.Type == eventType {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// Routes 返回已注册的自定义路由（已排序）
+func (s *SDK) Routes() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]string, 0, len(s.routes))
+	for r := range s.routes {
+		out = append(out, r)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Serve 以 method 调用已注册的自定义路由，返回响应
+func (s *SDK) Serve(method, path string, query url.Values) (*httptest.ResponseRecorder, error) {
+	return s.ServeBody(method, path, query, nil)
+}
+
+// ServeBody 同 Serve，body 为请求体
+func (s *SDK) ServeBody(method, path string, query url.Values, body []byte) (*httptest.ResponseRecorder, error) {
+	s.mu.Lock()
+	r, ok := s.routes[path]
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("路由 %s %w", path, ErrNotFound)
+	}
+	allowed := false
+	for _, m := range r.methods {
+		allowed = allowed || m == method
+	}
+	rec := httptest.NewRecorder()
+	if !allowed {
+		rec.WriteHeader(http.StatusMethodNotAllowed)
+		return rec, nil
+	}
+	target := path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	c := echo.New().NewContext(httptest.NewRequest(method, target, bytes.NewReader(body)), rec)
+	if err := r.handler(c); err != nil {
+		return rec, err
+	}
+	return rec, nil
+}
+
+// —— 设备
+
+func (s *SDK) AddDevice(device models.Device) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.devices[device.Name]; ok {
+		return "", fmt.Errorf("设备 %s 已存在", device.Name)
+	}
+	if device.Id == "" {
+		device.Id = "fake-" + device.Name
+	}
+	s.devices[device.Name] = device
+	return device.Id, nil
+}
+
+func (s *SDK) Devices() []models.Device {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]models.Device, 0, len(s.devices))
+	for _, d := range s.devices {
+		out = append(out, d)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func (s *SDK) GetDeviceByName(name string) (models.Device, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.devices[name]
+	if !ok {
+		return d, fmt.Errorf("设备 %s %w", name, ErrNotFound)
+	}
+	return d, nil
+}
+
+func (s *SDK) UpdateDevice(device models.Device) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.devices[device.Name]; !ok {
+		return fmt.Errorf("设备 %s %w", device.Name, ErrNotFound)
+	}
+	s.devices[device.Name] = device
+	return nil
+}
+
+func (s *SDK) RemoveDeviceByName(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.devices[name]; !ok {
+		return fmt.Errorf("设备 %s %w", name, ErrNotFound)
+	}
+	delete(s.devices, name)
+	return nil
+}
+
+func (s *SDK) DeviceExistsForName(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.devices[name]
+	return ok
+}
+
+func (s *SDK) UpdateDeviceOperatingState(name string, state models.OperatingState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.devices[name]
+	if !ok {
+		return fmt.Errorf("设备 %s %w", name, ErrNotFound)
+	}
+	d.OperatingState = state
+	s.devices[name] = d
+	return nil
+}
+
+// PatchDevice 只处理名称、描述、管理/运行状态、profile 和标签
+func (s *SDK) PatchDevice(u dtos.UpdateDevice) error {
+	if u.Name == nil {
+		return errors.New("PatchDevice 须指定 Name")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.devices[*u.Name]
+	if !ok {
+		return fmt.Errorf("设备 %s %w", *u.Name, ErrNotFound)
+	}
+	if u.Description != nil {
+		d.Description = *u.Description
+	}
+	if u.AdminState != nil {
+		d.AdminState = models.AdminState(*u.AdminState)
+	}
+	if u.OperatingState != nil {
+		d.OperatingState = models.OperatingState(*u.OperatingState)
+	}
+	if u.ProfileName != nil {
+		d.ProfileName = *u.ProfileName
+	}
+	if u.Labels != nil {
+		d.Labels = u.Labels
+	}
+	s.devices[d.Name] = d
+	return nil
+}
+
+func (s *SDK) AddDeviceAutoEvent(deviceName string, event models.AutoEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.devices[deviceName]
+	if !ok {
+		return fmt.Errorf("设备 %s %w", deviceName, ErrNotFound)
+	}
+	d.AutoEvents = append(d.AutoEvents, event)
+	s.devices[deviceName] = d
+	return nil
+}
+
+func (s *SDK) RemoveDeviceAutoEvent(deviceName string, event models.AutoEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.devices[deviceName]
+	if !ok {
+		return fmt.Errorf("设备 %s %w", deviceName, ErrNotFound)
+	}
+	kept := d.AutoEvents[:0:0]
+	for _, e := range d.AutoEvents {
+		if e.SourceName != event.SourceName {
+			kept = append(kept, e)
+		}
+	}
+	d.AutoEvents = kept
+	s.devices[deviceName] = d
+	return nil
+}
+
+// —— Profile
+
+func (s *SDK) AddDeviceProfile(profile models.DeviceProfile) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.profiles[profile.Name]; ok {
+		return "", fmt.Errorf("profile %s 已存在", profile.Name)
+	}
+	if profile.Id == "" {
+		profile.Id = "fake-" + profile.Name
+	}
+	s.profiles[profile.Name] = profile
+	return profile.Id, nil
+}
+
+func (s *SDK) DeviceProfiles() []models.DeviceProfile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]models.DeviceProfile, 0, len(s.profiles))
+	for _, p := range s.profiles {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func (s *SDK) GetProfileByName(name string) (models.DeviceProfile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.profiles[name]
+	if !ok {
+		return p, fmt.Errorf("profile %s %w", name, ErrNotFound)
+	}
+	return p, nil
+}
+
+func (s *SDK) UpdateDeviceProfile(profile models.DeviceProfile) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.profiles[profile.Name]; !ok {
+		return fmt.Errorf("profile %s %w", profile.Name, ErrNotFound)
+	}
+	s.profiles[profile.Name] = profile
+	return nil
+}
+
+func (s *SDK) RemoveDeviceProfileByName(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.profiles[name]; !ok {
+		return fmt.Errorf("profile %s %w", name, ErrNotFound)
+	}
+	delete(s.profiles, name)
+	return nil
+}
+
+// profileOf 返回设备的 profile，调用方需持有 mu
+func (s *SDK) profileOf(deviceName string) (models.DeviceProfile, bool) {
+	d, ok := s.devices[deviceName]
+	if !ok {
+		return models.DeviceProfile{}, false
+	}
+	p, ok := s.profiles[d.ProfileName]
+	return p, ok
+}
+
+func (s *SDK) DeviceResource(deviceName string, deviceResource string) (models.DeviceResource, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, _ := s.profileOf(deviceName)
+	for _, r := range p.DeviceResources {
+		if r.Name == deviceResource {
+			return r, true
+		}
+	}
+	return models.DeviceResource{}, false
+}
+
+func (s *SDK) DeviceCommand(deviceName string, commandName string) (models.DeviceCommand, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, _ := s.profileOf(deviceName)
+	for _, c := range p.DeviceCommands {
+		if c.Name == commandName {
+			return c, true
+		}
+	}
+	return models.DeviceCommand{}, false
+}
+
+// —— ProvisionWatcher
+
+func (s *SDK) AddProvisionWatcher(watcher models.ProvisionWatcher) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.watchers[watcher.Name]; ok {
+		return "", fmt.Errorf("ProvisionWatcher %s 已存在", watcher.Name)
+	}
+	if watcher.Id == "" {
+		watcher.Id = "fake-" + watcher.Name
+	}
+	s.watchers[watcher.Name] = watcher
+	return watcher.Id, nil
+}
+
+func (s *SDK) ProvisionWatchers() []models.ProvisionWatcher {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]models.ProvisionWatcher, 0, len(s.watchers))
+	for _, w := range s.watchers {
+		out = append(out, w)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func (s *SDK) GetProvisionWatcherByName(name string) (models.ProvisionWatcher, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w, ok := s.watchers[name]
+	if !ok {
+		return w, fmt.Errorf("ProvisionWatcher %s %w", name, ErrNotFound)
+	}
+	return w, nil
+}
+
+func (s *SDK) UpdateProvisionWatcher(watcher models.ProvisionWatcher) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.watchers[watcher.Name]; !ok {
+		return fmt.Errorf("ProvisionWatcher %s %w", watcher.Name, ErrNotFound)
+	}
+	s.watchers[watcher.Name] = watcher
+	return nil
+}
+
+func (s *SDK) RemoveProvisionWatcher(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.watchers[name]; !ok {
+		return fmt.Errorf("ProvisionWatcher %s %w", name, ErrNotFound)
+	}
+	delete(s.watchers, name)
+	return nil
+}
+
+// —— 服务
+
+// Run 测试中不启动服务，直接返回
+func (s *SDK) Run() error { return nil }
+
+func (s *SDK) Name() string { return "device-lpmp-fake" }
+
+func (s *SDK) Version() string { return "0.0.0" }
+
+func (s *SDK) AsyncReadingsEnabled() bool { return true }
+
+func (s *SDK) AsyncValuesChannel() chan *sdkModels.AsyncValues { return s.asyncCh }
+
+func (s *SDK) DiscoveredDeviceChannel() chan []sdkModels.DiscoveredDevice { return s.discCh }
+
+func (s *SDK) DeviceDiscoveryEnabled() bool { return false }
+
+// DriverConfigs 返回 Driver 配置段的副本
+func (s *SDK) DriverConfigs() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]string, len(s.driverConfigs))
+	for k, v := range s.driverConfigs {
+		out[k] = v
+	}
+	return out
+}
+
+func (s *SDK) AddCustomRoute(path string, authentication interfaces.Authentication, handler func(e echo.Context) error, methods ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.routes[path]; ok {
+		return fmt.Errorf("路由 %s 已注册", path)
+	}
+	s.routes[path] = route{auth: authentication, handler: handler, methods: methods}
+	return nil
+}
+
+// LoadCustomConfig 未调用 SetCustomConfig 时保持 customConfig 不变（即全部为零值）
+func (s *SDK) LoadCustomConfig(customConfig interfaces.UpdatableConfig, sectionName string) error {
+	s.mu.Lock()
+	raw := s.custom
+	s.mu.Unlock()
+	if raw == nil {
+		return nil
+	}
+	if !customConfig.UpdateFromRaw(raw) {
+		return fmt.Errorf("自定义配置节 %s 类型不匹配: %T", sectionName, raw)
+	}
+	return nil
+}
+
+// ListenForCustomConfigChanges 测试中配置不会变化，不回调
+func (s *SDK) ListenForCustomConfigChanges(configToWatch interface{}, sectionName string, changedCallback func(interface{})) error {
+	return nil
+}
+
+func (s *SDK) LoggingClient() logger.LoggingClient { return s.lc }
+
+// SecretProvider 测试中没有密钥库
+func (s *SDK) SecretProvider() bootstrapInterfaces.SecretProvider { return nil }
+
+// MetricsManager 测试中不采集指标
+func (s *SDK) MetricsManager() bootstrapInterfaces.MetricsManager { return nil }
+
+func (s *SDK) PublishDeviceDiscoveryProgressSystemEvent(progress, discoveredDeviceCount int, message string) {
+}
+
+func (s *SDK) PublishProfileScanProgressSystemEvent(reqId string, progress int, message string) {}
+
+func (s *SDK) PublishGenericSystemEvent(eventType, action string, details any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, Event{Type: eventType, Action: action, Details: details})
+}
@@ -0,0 +1,110 @@
+package serial
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/trace"
+)
+
+// 抓包文件：每行为收到该行的时间（RFC 3339，纳秒精度）、一个空格和串口原样输出的一行，如
+//
+//	2026-03-01T08:00:00.123456789+08:00 +DRX:0102030405A6,12,0102...
+//
+// 由 lpmp-tail -capture 写出，供服务停机期间记录模组输出、事后以原始时间回补到 EdgeX
+const captureTimeLayout = time.RFC3339Nano
+
+// captureLineMax 抓包文件单行上限：时间戳加上默认单帧上限下的最长 +DRX 行
+const captureLineMax = 2*DefaultMaxFrameSize + drxLineOverhead + len(captureTimeLayout) + 16
+
+// CaptureWriter 把经过的串口输出按行加上接收时间写入抓包文件，配合 io.TeeReader 使用；
+// 不完整的行暂存到换行到达，时间取换行到达的时刻
+type CaptureWriter struct {
+	mu   sync.Mutex
+	w    io.Writer
+	line []byte
+}
+
+// NewCaptureWriter 创建写入 w 的 CaptureWriter
+func NewCaptureWriter(w io.Writer) *CaptureWriter {
+	return &CaptureWriter{w: w}
+}
+
+// Write 实现 io.Writer；空行不写出，写出失败时返回错误
+func (c *CaptureWriter) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rest := p
+	for {
+		i := bytes.IndexByte(rest, '\n')
+		if i < 0 {
+			// 没有换行的乱码不无限累积，超过上限的部分丢弃
+			if len(c.line)+len(rest) <= captureLineMax {
+				c.line = append(c.line, rest...)
+			}
+			return len(p), nil
+		}
+		if len(c.line)+i <= captureLineMax {
+			c.line = append(c.line, rest[:i]...)
+		}
+		line := strings.TrimRight(string(c.line), "\r")
+		c.line = c.line[:0]
+		rest = rest[i+1:]
+		if line == "" {
+			continue
+		}
+		if _, err := fmt.Fprintf(c.w, "%s %s\n", time.Now().Format(captureTimeLayout), line); err != nil {
+			return len(p) - len(rest), err
+		}
+	}
+}
+
+// CaptureReader 按行读取抓包文件，返回其中的 +DRX 帧，Received 为抓包时记录的时间
+type CaptureReader struct {
+	s    *bufio.Scanner
+	line int
+}
+
+// NewCaptureReader 创建读取 r 的 CaptureReader
+func NewCaptureReader(r io.Reader) *CaptureReader {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 4096), captureLineMax)
+	return &CaptureReader{s: s}
+}
+
+// Next 返回下一帧，非 +DRX 行（AT 应答等）跳过；读完时返回 io.EOF。
+// 单行格式错误时返回带行号的错误，调用方可跳过该行继续调用 Next；读取失败后不能继续
+func (c *CaptureReader) Next() (RxFrame, error) {
+	for c.s.Scan() {
+		c.line++
+		text := c.s.Text()
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		ts, line, ok := strings.Cut(text, " ")
+		if !ok {
+			return RxFrame{}, fmt.Errorf("%w：第 %d 行缺少时间戳", ErrMalformedDRXLine, c.line)
+		}
+		at, err := time.Parse(captureTimeLayout, ts)
+		if err != nil {
+			return RxFrame{}, fmt.Errorf("%w：第 %d 行时间戳 %q 无效", ErrMalformedDRXLine, c.line, ts)
+		}
+		if !strings.HasPrefix(line, "+DRX:") {
+			continue
+		}
+		data, err := ParseDRXLine(line)
+		if err != nil {
+			return RxFrame{}, fmt.Errorf("第 %d 行：%w", c.line, err)
+		}
+		return RxFrame{TraceID: trace.New(), Data: data, Received: at}, nil
+	}
+	if err := c.s.Err(); err != nil {
+		return RxFrame{}, fmt.Errorf("读取抓包文件第 %d 行之后失败：%w", c.line, err)
+	}
+	return RxFrame{}, io.EOF
+}
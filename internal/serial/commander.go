@@ -0,0 +1,141 @@
+package serial
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrCommandRejected 模组对指令应答 ERROR（或 +CME ERROR / +CMS ERROR）
+	ErrCommandRejected = errors.New("模组拒绝指令")
+	// ErrResponseTimeout 指令写出后在应答超时内没有收到模组的最终应答
+	ErrResponseTimeout = errors.New("等待模组应答超时")
+)
+
+// commandReq 排队中的一条指令
+type commandReq struct {
+	data []byte
+	done chan error
+}
+
+// Commander 一条链路连接上唯一的写入者。各协程的 Write 经队列由一个写协程逐条写出，
+// 每次 Write 须为一条完整指令（如 WriteFrame / WriteCommand 的一次调用），整条写出，不会与其它指令交错。
+// 应答超时大于 0 时，每条指令写出后等到模组的最终应答（OK / ERROR）或超时才写下一条，
+// 应答由 Listen 从同一连接读出；为 0 时只保证写入互斥，不等待应答（模组不回显应答时）。
+type Commander struct {
+	conn    io.ReadWriteCloser
+	timeout time.Duration
+
+	queue chan commandReq
+	// responses 读协程收到的最终应答，nil 表示 OK，否则为拒绝原因
+	responses chan error
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewCommander 接管 conn 的写入并启动写协程，responseTimeout 为等待每条指令最终应答的时长，0 不等待
+func NewCommander(conn io.ReadWriteCloser, responseTimeout time.Duration) *Commander {
+	c := &Commander{
+		conn:      conn,
+		timeout:   responseTimeout,
+		queue:     make(chan commandReq),
+		responses: make(chan error, 1),
+		stop:      make(chan struct{}),
+	}
+	go c.run()
+	return c
+}
+
+// Write 把 p 作为一条指令排队，等待其写出（以及应答，见 NewCommander）后返回
+func (c *Commander) Write(p []byte) (int, error) {
+	req := commandReq{data: append([]byte(nil), p...), done: make(chan error, 1)}
+	select {
+	case c.queue <- req:
+	case <-c.stop:
+		return 0, ErrPortClosed
+	}
+	if err := <-req.done; err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Read 直接读取底层连接；读取 +DRX 数据时应使用 Listen，以便把指令应答交给写协程
+func (c *Commander) Read(p []byte) (int, error) {
+	return c.conn.Read(p)
+}
+
+// Close 停止写协程并关闭底层连接，排队中的指令以 ErrPortClosed 结束
+func (c *Commander) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.stop)
+		err = c.conn.Close()
+	})
+	return err
+}
+
+// Listen 与 ListenDRX 相同，按 opts 限制单帧大小和读取期限，另把非 +DRX 行中的最终应答交给写协程
+func (c *Commander) Listen(ctx context.Context, frameCh chan<- RxFrame, opts ReaderOptions) <-chan error {
+	r := NewDRXReaderOptions(c.conn, opts)
+	r.onResponse = c.handleResponse
+	return listenDRX(ctx, r, frameCh)
+}
+
+// handleResponse 识别最终应答；不在等待应答时收到的（如超时后才到的）在下一条指令写出前丢弃
+func (c *Commander) handleResponse(line string) {
+	var res error
+	switch {
+	case line == "OK":
+	case line == "ERROR" || strings.HasPrefix(line, "+CME ERROR") || strings.HasPrefix(line, "+CMS ERROR"):
+		res = fmt.Errorf("%w：%s", ErrCommandRejected, line)
+	default:
+		return
+	}
+	select {
+	case c.responses <- res:
+	default:
+	}
+}
+
+// run 写协程：逐条写出指令并等待应答，直到 Close
+func (c *Commander) run() {
+	for {
+		select {
+		case <-c.stop:
+			return
+		case req := <-c.queue:
+			req.done <- c.exec(req.data)
+		}
+	}
+}
+
+// exec 写出一条指令，应答超时大于 0 时等待最终应答
+func (c *Commander) exec(data []byte) error {
+	// 丢弃上一条指令超时后才到的应答，避免被当作本条的应答
+	select {
+	case <-c.responses:
+	default:
+	}
+	if _, err := c.conn.Write(data); err != nil {
+		return classify(err)
+	}
+	if c.timeout <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+	select {
+	case err := <-c.responses:
+		return err
+	case <-timer.C:
+		return fmt.Errorf("%w（%s）：%s", ErrResponseTimeout, c.timeout, strings.TrimSpace(string(data)))
+	case <-c.stop:
+		return ErrPortClosed
+	}
+}
@@ -0,0 +1,242 @@
+package serial
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeModule 内存中的 LPMP 模组：记录写入的指令，按 reply 异步回复应答行
+type fakeModule struct {
+	rx *io.PipeReader
+	tx *io.PipeWriter
+
+	// reply 返回对一条指令的应答（含换行），为空不应答
+	reply func(cmd string) string
+
+	mu     sync.Mutex
+	writes []string
+	// writing 正在执行的 Write 数，overlap 记录是否出现过并发写入
+	writing atomic.Int32
+	overlap atomic.Bool
+	// pending 已写出、应答尚未送出的指令，未等应答就写下一条时 early 置位
+	pending atomic.Bool
+	early   atomic.Bool
+}
+
+func newFakeModule(reply func(cmd string) string) *fakeModule {
+	m := &fakeModule{reply: reply}
+	m.rx, m.tx = io.Pipe()
+	return m
+}
+
+func (m *fakeModule) Read(p []byte) (int, error) { return m.rx.Read(p) }
+
+func (m *fakeModule) Write(p []byte) (int, error) {
+	if m.writing.Add(1) > 1 {
+		m.overlap.Store(true)
+	}
+	defer m.writing.Add(-1)
+	if m.pending.Load() {
+		m.early.Store(true)
+	}
+	cmd := string(p)
+	m.mu.Lock()
+	m.writes = append(m.writes, cmd)
+	m.mu.Unlock()
+	// 放大并发写入的窗口
+	time.Sleep(time.Millisecond)
+	if m.reply != nil {
+		if r := m.reply(cmd); r != "" {
+			m.pending.Store(true)
+			go func() {
+				time.Sleep(5 * time.Millisecond)
+				m.pending.Store(false)
+				m.send(r)
+			}()
+		}
+	}
+	return len(p), nil
+}
+
+func (m *fakeModule) Close() error {
+	m.tx.Close()
+	return m.rx.Close()
+}
+
+// send 模组输出一行，返回时已被读协程读走
+func (m *fakeModule) send(line string) {
+	_, _ = io.WriteString(m.tx, line)
+}
+
+func (m *fakeModule) written() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.writes...)
+}
+
+// startCommander 创建 Commander 并启动 Listen，测试结束时 Close
+func startCommander(t *testing.T, m *fakeModule, timeout time.Duration) (*Commander, chan RxFrame) {
+	t.Helper()
+	c := NewCommander(m, timeout)
+	frames := make(chan RxFrame, 16)
+	ctx, cancel := context.WithCancel(context.Background())
+	c.Listen(ctx, frames, ReaderOptions{})
+	t.Cleanup(func() {
+		cancel()
+		c.Close()
+	})
+	return c, frames
+}
+
+// TestCommanderSingleWriter 多个协程并发 Write 时指令逐条整条写出；等待应答时上一条的应答送出前不写下一条
+func TestCommanderSingleWriter(t *testing.T) {
+	for _, timeout := range []time.Duration{0, time.Second} {
+		m := newFakeModule(func(string) string { return "OK\r\n" })
+		c, _ := startCommander(t, m, timeout)
+
+		const n = 20
+		var wg sync.WaitGroup
+		errs := make(chan error, n)
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				cmd := fmt.Sprintf("AT+CMD=%02d\r\n", i)
+				if k, err := c.Write([]byte(cmd)); err != nil || k != len(cmd) {
+					errs <- fmt.Errorf("Write(%q)=%d, %v", cmd, k, err)
+				}
+			}(i)
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Errorf("timeout=%s: %v", timeout, err)
+		}
+
+		writes := m.written()
+		seen := make(map[string]bool)
+		for _, w := range writes {
+			if !strings.HasPrefix(w, "AT+CMD=") || !strings.HasSuffix(w, "\r\n") || len(w) != len("AT+CMD=00\r\n") {
+				t.Errorf("timeout=%s: 写出不完整的指令 %q", timeout, w)
+			}
+			seen[w] = true
+		}
+		if len(writes) != n || len(seen) != n {
+			t.Errorf("timeout=%s: 写出 %d 条（%d 条不同），期望 %d", timeout, len(writes), len(seen), n)
+		}
+		if m.overlap.Load() {
+			t.Errorf("timeout=%s: 出现并发写入", timeout)
+		}
+		if timeout > 0 && m.early.Load() {
+			t.Errorf("timeout=%s: 上一条指令的应答送出前写出了下一条", timeout)
+		}
+	}
+}
+
+// TestCommanderResponses 最终应答按 OK / ERROR / +CME ERROR / +CMS ERROR 匹配，中间行和 +DRX 数据行不算应答；
+// 超时后才到的应答不被当作下一条指令的应答
+func TestCommanderResponses(t *testing.T) {
+	m := newFakeModule(func(cmd string) string {
+		switch strings.TrimSpace(cmd) {
+		case "AT+OK":
+			return "OK\r\n"
+		case "AT+ERR":
+			return "ERROR\r\n"
+		case "AT+CME":
+			return "+CME ERROR: 10\r\n"
+		case "AT+CMS":
+			return "+CMS ERROR: 500\r\n"
+		case "AT+CSQ":
+			return "\r\n+CSQ: 20,99\r\nOKAY\r\n" + testDRXLine + "OK\r\n"
+		}
+		return ""
+	})
+	c, frames := startCommander(t, m, 200*time.Millisecond)
+
+	cases := []struct {
+		cmd  string
+		want error
+	}{
+		{"AT+OK", nil},
+		{"AT+ERR", ErrCommandRejected},
+		{"AT+CME", ErrCommandRejected},
+		{"AT+CMS", ErrCommandRejected},
+		{"AT+CSQ", nil},
+		{"AT+SILENT", ErrResponseTimeout},
+	}
+	for _, tc := range cases {
+		_, err := c.Write([]byte(tc.cmd + "\r\n"))
+		if tc.want == nil && err != nil || tc.want != nil && !errors.Is(err, tc.want) {
+			t.Errorf("%s: 返回 %v，期望 %v", tc.cmd, err, tc.want)
+		}
+	}
+	select {
+	case f := <-frames:
+		if string(f.Data) != "\x11\x11\x11" {
+			t.Errorf("应答中的 +DRX 行解析为 % X", f.Data)
+		}
+	case <-time.After(time.Second):
+		t.Error("应答中的 +DRX 行未交给 frameCh")
+	}
+
+	// AT+SILENT 超时后才到的 OK，随后的数据行送达说明 OK 已被读协程处理
+	m.send("OK\r\n" + testDRXLine)
+	<-frames
+	if _, err := c.Write([]byte("AT+ERR\r\n")); !errors.Is(err, ErrCommandRejected) {
+		t.Errorf("迟到的 OK 之后 AT+ERR 返回 %v，期望 ErrCommandRejected", err)
+	}
+}
+
+// TestCommanderCloseDuringExec 等待应答期间 Close，正在执行和排队中的 Write 都以 ErrPortClosed 结束，之后的 Write 同样
+func TestCommanderCloseDuringExec(t *testing.T) {
+	m := newFakeModule(nil)
+	c, _ := startCommander(t, m, time.Minute)
+
+	errs := make(chan error, 2)
+	go func() {
+		_, err := c.Write([]byte("AT+WAIT\r\n"))
+		errs <- err
+	}()
+	deadline := time.Now().Add(time.Second)
+	for len(m.written()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("指令未写出")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// 写协程在等待 AT+WAIT 的应答，这条在队列中等待
+	go func() {
+		_, err := c.Write([]byte("AT+QUEUED\r\n"))
+		errs <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			if !errors.Is(err, ErrPortClosed) {
+				t.Errorf("Close 后 Write 返回 %v，期望 ErrPortClosed", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Close 后 Write 未返回")
+		}
+	}
+	if _, err := c.Write([]byte("AT+AFTER\r\n")); !errors.Is(err, ErrPortClosed) {
+		t.Errorf("关闭后 Write 返回 %v，期望 ErrPortClosed", err)
+	}
+	if got := m.written(); len(got) != 1 {
+		t.Errorf("写出 %q，期望只有 AT+WAIT", got)
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("重复 Close: %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package serial
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// serialByIDDir 为 udev 按设备唯一标识建立的稳定链接目录，不受 ttyUSB 编号变化影响
+const serialByIDDir = "/dev/serial/by-id"
+
+// usbID USB 转串口芯片的 VID/PID
+type usbID struct {
+	VID, PID string
+}
+
+// modemModels 常见模组所用 USB 转串口芯片，可通过型号名代替手工填写 VID/PID
+var modemModels = map[string]usbID{
+	"ch340":  {VID: "1A86", PID: "7523"},
+	"cp2102": {VID: "10C4", PID: "EA60"},
+	"ft232":  {VID: "0403", PID: "6001"},
+	"pl2303": {VID: "067B", PID: "2303"},
+}
+
+// PortSpec 描述如何找到模组所在的串口，按以下优先级匹配：
+// 1. ByIDPattern：匹配 /dev/serial/by-id 下的链接名（filepath.Match 语法，仅 Linux）
+// 2. VID/PID（或 Model 对应的 VID/PID）：枚举 USB 串口，各平台通用
+// 3. Name：固定端口名（Windows 为 COMx，macOS 为 /dev/cu.*）
+type PortSpec struct {
+	Name        string
+	ByIDPattern string
+	Model       string
+	VID         string
+	PID         string
+}
+
+// usb 返回生效的 VID/PID，显式配置优先于型号
+func (s PortSpec) usb() (usbID, error) {
+	id := usbID{VID: s.VID, PID: s.PID}
+	if s.Model != "" && id.VID == "" && id.PID == "" {
+		m, ok := modemModels[strings.ToLower(s.Model)]
+		if !ok {
+			return id, fmt.Errorf("未知模组型号 %q", s.Model)
+		}
+		id = m
+	}
+	return id, nil
+}
+
+// Validate 检查配置本身是否有效（型号是否已知、匹配模式语法、端口名是否符合当前平台），不访问设备
+func (s PortSpec) Validate() error {
+	if s.Name != "" {
+		if err := ValidatePortName(s.Name); err != nil {
+			return err
+		}
+	}
+	if s.ByIDPattern != "" {
+		if !byIDSupported {
+			return fmt.Errorf("当前平台不支持 %s，请改用 USB VID/PID 或模组型号", serialByIDDir)
+		}
+		if _, err := filepath.Match(s.ByIDPattern, ""); err != nil {
+			return fmt.Errorf("by-id 匹配模式 %q 无效：%w", s.ByIDPattern, err)
+		}
+	}
+	_, err := s.usb()
+	return err
+}
+
+// Discover 按 PortSpec 查找串口，返回可直接打开的端口名
+func Discover(spec PortSpec) (string, error) {
+	// 1. /dev/serial/by-id 稳定链接
+	if spec.ByIDPattern != "" {
+		matches, err := filepath.Glob(filepath.Join(serialByIDDir, spec.ByIDPattern))
+		if err != nil {
+			return "", fmt.Errorf("by-id 匹配模式 %q 无效：%w", spec.ByIDPattern, err)
+		}
+		sort.Strings(matches)
+		for _, m := range matches {
+			if target, err := filepath.EvalSymlinks(m); err == nil {
+				return target, nil
+			}
+		}
+		return "", fmt.Errorf("%w：%s 下没有匹配 %q 的串口", ErrPortNotFound, serialByIDDir, spec.ByIDPattern)
+	}
+
+	// 2. USB VID/PID
+	id, err := spec.usb()
+	if err != nil {
+		return "", err
+	}
+	if id.VID != "" || id.PID != "" {
+		ports, err := ListPorts()
+		if err != nil {
+			return "", err
+		}
+		var names []string
+		for _, p := range ports {
+			if !p.IsUSB {
+				continue
+			}
+			if (id.VID == "" || strings.EqualFold(p.VID, id.VID)) && (id.PID == "" || strings.EqualFold(p.PID, id.PID)) {
+				names = append(names, p.Name)
+			}
+		}
+		if len(names) == 0 {
+			return "", fmt.Errorf("%w：VID=%s PID=%s 的 USB 串口", ErrPortNotFound, id.VID, id.PID)
+		}
+		return names[0], nil
+	}
+
+	// 3. 固定端口名
+	if spec.Name == "" {
+		return "", fmt.Errorf("%w：未配置串口", ErrPortNotFound)
+	}
+	return normalizePortName(spec.Name), nil
+}
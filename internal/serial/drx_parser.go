@@ -4,37 +4,62 @@ package serial
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net"
+	"os"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 
+	"github.com/linjuya-lu/device-lpmp-go/internal/trace"
 	goserial "go.bug.st/serial.v1"
 )
 
 // Open 打开一个串口，并以 io.ReadWriteCloser 的形式返回
 func Open(portName string, baudRate int) (io.ReadWriteCloser, error) {
 	mode := &goserial.Mode{BaudRate: baudRate}
-	return goserial.Open(portName, mode)
+	return goserial.Open(normalizePortName(portName), mode)
 }
 
+// DefaultMaxFrameSize 未配置时单帧的最大字节数，远大于 LPMP 空口帧的实际长度
+const DefaultMaxFrameSize = 2048
+
 // ParseDRXLine 解析一行形如 "+DRX:<deviceId>,<length>,<hexPayload>"
 // 的串口输出，提取出 hexPayload 并将其解码为字节切片。
 // 例如："+DRX:238A08262319,3,111111" → []byte{0x11,0x11,0x11}
+// 声明长度或 payload 超过 DefaultMaxFrameSize 时返回 ErrFrameTooLarge。
 func ParseDRXLine(line string) ([]byte, error) {
+	return parseDRXLine(line, DefaultMaxFrameSize)
+}
+
+// parseDRXLine 同 ParseDRXLine，单帧上限为 maxSize 字节；超限时在解码之前拒绝
+func parseDRXLine(line string, maxSize int) ([]byte, error) {
 	// 只处理以 +DRX: 开头的行
 	if !strings.HasPrefix(line, "+DRX:") {
-		return nil, fmt.Errorf("不是 DRX 数据行：%s", line)
+		return nil, fmt.Errorf("%w：%s", ErrNotDRXLine, line)
 	}
 	// 分割成三部分：prefix、length、payload
 	parts := strings.SplitN(line, ",", 3)
 	if len(parts) != 3 {
-		return nil, fmt.Errorf("DRX 行字段数不对：%s", line)
+		return nil, fmt.Errorf("%w：字段数不对：%s", ErrMalformedDRXLine, line)
+	}
+	// 声明长度不合理时不必再看 payload
+	declared, err := strconv.Atoi(parts[1])
+	if err != nil || declared < 0 {
+		return nil, fmt.Errorf("%w：长度字段 %q 无效", ErrMalformedDRXLine, parts[1])
 	}
 	payload := parts[2]
+	if declared > maxSize || len(payload) > 2*maxSize {
+		return nil, fmt.Errorf("%w：声明 %d 字节、payload %d 字节，上限 %d", ErrFrameTooLarge, declared, len(payload)/2, maxSize)
+	}
 	// payload 必须是偶数长度，每两个字符表示一个字节
 	if len(payload)%2 != 0 {
-		return nil, fmt.Errorf("payload 长度不是偶数：%s", payload)
+		return nil, fmt.Errorf("%w：payload 长度不是偶数：%s", ErrMalformedDRXLine, payload)
 	}
 	// 解码 hexPayload
 	n := len(payload) / 2
@@ -43,71 +68,334 @@ func ParseDRXLine(line string) ([]byte, error) {
 		hexByte := payload[i*2 : i*2+2]
 		v, err := strconv.ParseUint(hexByte, 16, 8)
 		if err != nil {
-			return nil, fmt.Errorf("解析 hex %s 失败：%w", hexByte, err)
+			return nil, fmt.Errorf("%w：解析 hex %s 失败：%w", ErrMalformedDRXLine, hexByte, err)
 		}
 		buf[i] = byte(v)
 	}
 	return buf, nil
 }
 
+const (
+	// maxTransientReadErrors 连续暂时性读错误的上限，超过后视为链路故障返回错误
+	maxTransientReadErrors = 5
+	// transientReadBackoff 第 n 次连续暂时性读错误后等待 n 倍该时长再读
+	transientReadBackoff = 100 * time.Millisecond
+	// drxLineOverhead 一条 +DRX 行除 payload 外的最大长度（前缀、SensorID、长度字段、行尾）
+	drxLineOverhead = 64
+)
+
+var (
+	// readerTooLong / readerTransient 启动（或 ResetReaderStats）以来 DRXReader 恢复的错误次数
+	readerTooLong   atomic.Uint64
+	readerTransient atomic.Uint64
+	// readerOversize 超过单帧上限被丢弃的帧；readerInvalidData / readerReadTimeout 因持续无效数据、
+	// 链路无数据超时而交由上层重建链路的次数
+	readerOversize     atomic.Uint64
+	readerInvalidData  atomic.Uint64
+	readerReadTimeouts atomic.Uint64
+)
+
+// ReaderStats DRXReader 从中恢复的错误计数
+type ReaderStats struct {
+	// TooLong 超过行缓冲（由单帧上限决定）被丢弃的行
+	TooLong uint64 `json:"tooLong"`
+	// TransientErrors 重试后恢复的暂时性读错误（读超时、EINTR 等）
+	TransientErrors uint64 `json:"transientErrors"`
+	// Oversize 声明长度或 payload 超过单帧上限被丢弃的 +DRX 行
+	Oversize uint64 `json:"oversize"`
+	// InvalidDataTimeouts 超过 InvalidDataTimeout 只收到无效数据，交由上层重建链路的次数
+	InvalidDataTimeouts uint64 `json:"invalidDataTimeouts"`
+	// ReadTimeouts 超过 ReadTimeout 链路上没有任何数据，交由上层重建链路的次数
+	ReadTimeouts uint64 `json:"readTimeouts"`
+}
+
+// SnapshotReaderStats 返回 DRXReader 恢复的错误计数
+func SnapshotReaderStats() ReaderStats {
+	return ReaderStats{
+		TooLong:             readerTooLong.Load(),
+		TransientErrors:     readerTransient.Load(),
+		Oversize:            readerOversize.Load(),
+		InvalidDataTimeouts: readerInvalidData.Load(),
+		ReadTimeouts:        readerReadTimeouts.Load(),
+	}
+}
+
+// ResetReaderStats 清零 DRXReader 的错误计数
+func ResetReaderStats() {
+	readerTooLong.Store(0)
+	readerTransient.Store(0)
+	readerOversize.Store(0)
+	readerInvalidData.Store(0)
+	readerReadTimeouts.Store(0)
+}
+
+// ReaderOptions DRXReader 的资源限制，零值使用默认值
+type ReaderOptions struct {
+	// MaxFrameSize 单帧最大字节数，默认 DefaultMaxFrameSize；行缓冲随之限定，超长行直接丢弃
+	MaxFrameSize int
+	// InvalidDataTimeout 连续只收到无效数据（格式错误、超限、超长或含不可打印字符的行）超过该时长时，
+	// ReadFrame 返回 ErrInvalidDataTimeout，由上层重建链路，避免异常的模组持续输出乱码占用 CPU；
+	// 从第一条无效数据起计时，收到有效输出（+DRX 帧或可打印的模组应答）清零，链路安静时不计时。0 不检查
+	InvalidDataTimeout time.Duration
+	// ReadTimeout 链路的读超时：超过该时长没有收到任何字节时 ReadFrame 返回 ErrReadTimeout，由上层重建链路
+	// （如 TCP 透传的对端已失联但连接未断开）。链路实现了 SetReadDeadline（net.Conn）或
+	// SetReadTimeout（串口）时生效，否则忽略。0 不设置
+	ReadTimeout time.Duration
+}
+
 // DRXReader 从 io.Reader 按行读取串口输出，过滤 +DRX 响应，
 // 并将 payload 解码后通过 ReadFrame 返回。
 // ReadFrame 会阻塞直到读取到下一条完整 DRX 行或遇到 io.EOF / 错误。
+// bufio.Scanner 遇到超长行或读错误后不再工作，可恢复的错误（超长行、暂时性读错误）
+// 会重建 Scanner 继续读取，只有链路关闭、持续的读错误和超过读取期限才返回
 type DRXReader struct {
-	s *bufio.Scanner
+	r    io.Reader
+	s    *bufio.Scanner
+	opts ReaderOptions
+	// skipLine 超长行之后重建 Scanner 时，丢弃该行未读完的剩余部分
+	skipLine bool
+	// transient 连续的暂时性读错误次数，读到一行后清零
+	transient int
+	// invalidSince 自上一条有效输出以来第一条无效数据的时间，零值表示尚无无效数据，InvalidDataTimeout 由此计时
+	invalidSince time.Time
+	// onResponse 非 +DRX 行（AT 指令应答等）的处理函数，为 nil 时跳过
+	onResponse func(line string)
 }
 
-// NewDRXReader 创建一个 DRXReader，对给定的 io.Reader 进行封装
+// NewDRXReader 创建一个 DRXReader，对给定的 io.Reader 进行封装，使用默认限制
 func NewDRXReader(r io.Reader) *DRXReader {
-	return &DRXReader{s: bufio.NewScanner(r)}
+	return NewDRXReaderOptions(r, ReaderOptions{})
+}
+
+// NewDRXReaderOptions 同 NewDRXReader，按 opts 限制单帧大小、无效数据时长和读超时
+func NewDRXReaderOptions(r io.Reader, opts ReaderOptions) *DRXReader {
+	if opts.MaxFrameSize <= 0 {
+		opts.MaxFrameSize = DefaultMaxFrameSize
+	}
+	if opts.ReadTimeout > 0 {
+		r = newTimeoutReader(r, opts.ReadTimeout)
+	}
+	dr := &DRXReader{r: r, opts: opts}
+	dr.s = dr.newScanner()
+	return dr
+}
+
+// newScanner 创建行缓冲受单帧上限约束的 Scanner
+func (r *DRXReader) newScanner() *bufio.Scanner {
+	s := bufio.NewScanner(r.r)
+	maxLine := 2*r.opts.MaxFrameSize + drxLineOverhead
+	s.Buffer(make([]byte, 0, min(maxLine, 4096)), maxLine)
+	return s
 }
 
 // ReadFrame 读取下一条 DRX 响应，返回解码后的字节切片
 func (r *DRXReader) ReadFrame() ([]byte, error) {
+	for {
+		data, err := r.scanFrame()
+		if err == nil || !r.recover(err) {
+			return data, err
+		}
+		// 持续输出没有换行的乱码时，超长行也计为无效数据
+		if r.skipLine && r.invalidTooLong() {
+			return nil, r.invalidDataErr()
+		}
+	}
+}
+
+// recover 判断错误是否可恢复，可恢复时重建 Scanner
+func (r *DRXReader) recover(err error) bool {
+	switch {
+	case errors.Is(err, bufio.ErrTooLong):
+		// 同一超长行可能多次超出缓冲，只计一次
+		if !r.skipLine {
+			readerTooLong.Add(1)
+		}
+		r.skipLine = true
+	case isTransient(err) && r.transient < maxTransientReadErrors:
+		r.transient++
+		readerTransient.Add(1)
+		time.Sleep(time.Duration(r.transient) * transientReadBackoff)
+	default:
+		return false
+	}
+	r.s = r.newScanner()
+	return true
+}
+
+// scanFrame 读到下一条 DRX 行或 Scanner 停止为止
+func (r *DRXReader) scanFrame() ([]byte, error) {
 	for r.s.Scan() {
+		r.transient = 0
 		line := r.s.Text()
+		if r.skipLine {
+			r.skipLine = false
+			continue
+		}
 		if !strings.HasPrefix(line, "+DRX:") {
+			line = strings.TrimSpace(line)
+			if !printable(line) {
+				if r.invalidTooLong() {
+					return nil, r.invalidDataErr()
+				}
+				continue
+			}
+			if line != "" {
+				r.invalidSince = time.Time{}
+			}
+			if r.onResponse != nil {
+				r.onResponse(line)
+			}
 			continue
 		}
-		data, err := ParseDRXLine(line)
+		data, err := parseDRXLine(line, r.opts.MaxFrameSize)
 		if err != nil {
+			if errors.Is(err, ErrFrameTooLarge) {
+				readerOversize.Add(1)
+			}
 			// 出错也跳过本行，继续读取下一行
+			if r.invalidTooLong() {
+				return nil, r.invalidDataErr()
+			}
 			continue
 		}
+		r.invalidSince = time.Time{}
 		return data, nil
 	}
 	if err := r.s.Err(); err != nil {
-		return nil, err
+		return nil, classify(err)
 	}
 	return nil, io.EOF
 }
 
-// StartDRXListener 启动一个 goroutine，从 io.Reader 读取 AT+DRX 响应帧，
-// 并将解码后的二进制帧推送到 frameCh。
-// 调用示例（在初始化时）：
-//
-//	frameCh := make(chan []byte, 100)
-//	serial.StartDRXListener(port, frameCh)
-//
-// 后续可在其他协程中：
-//
-//	for frame := range frameCh {
-//	    // 处理 frame
-//	}
-func StartDRXListener(port io.Reader, frameCh chan<- []byte) {
+// invalidTooLong 记录一次无效数据，判断连续无效数据是否已超过 InvalidDataTimeout
+func (r *DRXReader) invalidTooLong() bool {
+	if r.opts.InvalidDataTimeout <= 0 {
+		return false
+	}
+	if r.invalidSince.IsZero() {
+		r.invalidSince = time.Now()
+		return false
+	}
+	return time.Since(r.invalidSince) > r.opts.InvalidDataTimeout
+}
+
+// invalidDataErr 计数并返回 ErrInvalidDataTimeout
+func (r *DRXReader) invalidDataErr() error {
+	readerInvalidData.Add(1)
+	return fmt.Errorf("%w：%s 内只收到无效数据", ErrInvalidDataTimeout, r.opts.InvalidDataTimeout)
+}
+
+// timeoutReader 按 ReadTimeout 为链路设置读超时，超时的 Read 返回 ErrReadTimeout
+type timeoutReader struct {
+	r       io.Reader
+	timeout time.Duration
+	// deadline 链路支持 SetReadDeadline 时每次 Read 前设置截止时间；否则 polled 表示
+	// 已通过 SetReadTimeout 设置，Read 超时返回 0 字节且无错误
+	deadline interface{ SetReadDeadline(time.Time) error }
+	polled   bool
+}
+
+// newTimeoutReader 链路不支持读超时时原样返回 r
+func newTimeoutReader(r io.Reader, timeout time.Duration) io.Reader {
+	t := &timeoutReader{r: r, timeout: timeout}
+	if d, ok := r.(interface{ SetReadDeadline(time.Time) error }); ok {
+		t.deadline = d
+		return t
+	}
+	if p, ok := r.(interface{ SetReadTimeout(time.Duration) error }); ok && p.SetReadTimeout(timeout) == nil {
+		t.polled = true
+		return t
+	}
+	return r
+}
+
+func (t *timeoutReader) Read(p []byte) (int, error) {
+	if t.deadline != nil {
+		if err := t.deadline.SetReadDeadline(time.Now().Add(t.timeout)); err != nil {
+			return 0, err
+		}
+	}
+	n, err := t.r.Read(p)
+	if n == 0 && len(p) > 0 && ((t.polled && err == nil) || isTimeout(err)) {
+		readerReadTimeouts.Add(1)
+		return 0, fmt.Errorf("%w：%s 内未收到任何数据", ErrReadTimeout, t.timeout)
+	}
+	return n, err
+}
+
+// printable 判断一行是否只含可打印的 ASCII 字符
+func printable(line string) bool {
+	for i := 0; i < len(line); i++ {
+		if c := line[i]; c < 0x20 || c > 0x7E {
+			return false
+		}
+	}
+	return true
+}
+
+// isTransient 判断读错误是否为暂时性的（读超时、被信号中断、暂无数据）
+func isTransient(err error) bool {
+	if isClosedErr(err) {
+		return false
+	}
+	return isTimeout(err) || errors.Is(err, syscall.EINTR) || errors.Is(err, syscall.EAGAIN)
+}
+
+// isTimeout 判断读错误是否为读超时
+func isTimeout(err error) bool {
+	if err == nil {
+		return false
+	}
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return true
+	}
+	return errors.Is(err, os.ErrDeadlineExceeded)
+}
+
+// RxFrame 为从一条 +DRX 行解码出的二进制帧，附带追踪 ID
+type RxFrame struct {
+	TraceID trace.ID
+	Data    []byte
+	// Received 从串口读出该帧的时间（含单调时钟读数），作为读数的 Origin；
+	// 下游队列积压时与处理时刻可能相差数秒
+	Received time.Time
+}
+
+// ListenDRX 启动监听协程，从 port 读取 AT+DRX 响应帧，为每帧分配追踪 ID 后推送到 frameCh。
+// frameCh 由调用方创建和所有，监听协程从不关闭它，链路重连或切换后可继续交给新的监听协程；
+// 监听结束时把原因写入返回的通道（只写一次）：读到 EOF 为 io.EOF，读错误为该错误，ctx 取消为 ctx.Err()。
+// ctx 取消后阻塞在发送上的监听协程立即退出；阻塞在读取上的须由调用方关闭 port 唤醒。
+func ListenDRX(ctx context.Context, port io.Reader, frameCh chan<- RxFrame) <-chan error {
+	return listenDRX(ctx, NewDRXReader(port), frameCh)
+}
+
+func listenDRX(ctx context.Context, r *DRXReader, frameCh chan<- RxFrame) <-chan error {
+	done := make(chan error, 1)
 	go func() {
-		r := NewDRXReader(port)
 		for {
 			frame, err := r.ReadFrame()
+			if ctx.Err() != nil {
+				done <- ctx.Err()
+				return
+			}
 			if err != nil {
-				if err == io.EOF {
-					close(frameCh)
-					return
-				}
-				// 解析错误或临时错误，跳过本次
-				continue
+				done <- err
+				return
+			}
+			received := time.Now()
+			// receive 阶段记录入队等待时间，下游处理慢时可据此发现积压
+			id := trace.New()
+			end := trace.Begin(id, trace.StageReceive)
+			select {
+			case frameCh <- RxFrame{TraceID: id, Data: frame, Received: received}:
+			case <-ctx.Done():
+				end(nil, ctx.Err())
+				done <- ctx.Err()
+				return
 			}
-			frameCh <- frame
+			end(map[string]any{"bytes": len(frame)}, nil)
 		}
 	}()
+	return done
 }
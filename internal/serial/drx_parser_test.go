@@ -0,0 +1,231 @@
+package serial
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+const testDRXLine = "+DRX:238A0821BEF2,3,111111\r\n"
+
+// readFrame 在协程中调用 ReadFrame，超过 limit 未返回则失败
+func readFrame(t *testing.T, r *DRXReader, limit time.Duration) ([]byte, error) {
+	t.Helper()
+	type result struct {
+		data []byte
+		err  error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		data, err := r.ReadFrame()
+		ch <- result{data, err}
+	}()
+	select {
+	case res := <-ch:
+		return res.data, res.err
+	case <-time.After(limit):
+		t.Fatalf("ReadFrame %s 内未返回", limit)
+		return nil, nil
+	}
+}
+
+// TestReadTimeoutDeadline 链路实现 SetReadDeadline（net.Conn）时，ReadTimeout 内有数据照常读取，
+// 超过 ReadTimeout 没有任何字节时返回 ErrReadTimeout
+func TestReadTimeoutDeadline(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	r := NewDRXReaderOptions(client, ReaderOptions{ReadTimeout: 100 * time.Millisecond})
+	before := SnapshotReaderStats().ReadTimeouts
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_, _ = io.WriteString(server, testDRXLine)
+	}()
+	if data, err := readFrame(t, r, time.Second); err != nil || !bytes.Equal(data, []byte{0x11, 0x11, 0x11}) {
+		t.Fatalf("ReadTimeout 内收到的帧 % X, %v", data, err)
+	}
+	if _, err := readFrame(t, r, time.Second); !errors.Is(err, ErrReadTimeout) {
+		t.Errorf("无数据时 ReadFrame 返回 %v，期望 ErrReadTimeout", err)
+	}
+	if n := SnapshotReaderStats().ReadTimeouts - before; n != 1 {
+		t.Errorf("ReadTimeouts 增加 %d，期望 1", n)
+	}
+}
+
+// pollPort 模拟以 SetReadTimeout 设置读超时的串口：无数据时等待超时后返回 0 字节
+type pollPort struct {
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	timeout time.Duration
+}
+
+func (p *pollPort) SetReadTimeout(d time.Duration) error {
+	p.timeout = d
+	return nil
+}
+
+func (p *pollPort) Read(b []byte) (int, error) {
+	deadline := time.Now().Add(p.timeout)
+	for time.Now().Before(deadline) {
+		p.mu.Lock()
+		n, _ := p.buf.Read(b)
+		p.mu.Unlock()
+		if n > 0 {
+			return n, nil
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return 0, nil
+}
+
+func (p *pollPort) write(s string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.buf.WriteString(s)
+}
+
+func TestReadTimeoutPolled(t *testing.T) {
+	p := &pollPort{}
+	r := NewDRXReaderOptions(p, ReaderOptions{ReadTimeout: 80 * time.Millisecond})
+	if p.timeout != 80*time.Millisecond {
+		t.Fatalf("未调用 SetReadTimeout，timeout=%s", p.timeout)
+	}
+	p.write(testDRXLine)
+	if _, err := readFrame(t, r, time.Second); err != nil {
+		t.Fatalf("有数据时读取失败: %v", err)
+	}
+	if _, err := readFrame(t, r, time.Second); !errors.Is(err, ErrReadTimeout) {
+		t.Errorf("无数据时 ReadFrame 返回 %v，期望 ErrReadTimeout", err)
+	}
+}
+
+// TestReadTimeoutUnsupported 链路不支持读超时时 ReadTimeout 被忽略，不影响读取
+func TestReadTimeoutUnsupported(t *testing.T) {
+	r := NewDRXReaderOptions(bytes.NewBufferString(testDRXLine), ReaderOptions{ReadTimeout: time.Millisecond})
+	if _, err := readFrame(t, r, time.Second); err != nil {
+		t.Fatalf("读取失败: %v", err)
+	}
+	if _, err := readFrame(t, r, time.Second); !errors.Is(err, io.EOF) {
+		t.Errorf("读完后返回 %v，期望 io.EOF", err)
+	}
+}
+
+// scriptLink 内存中的链路：Read 依次返回 steps 中的数据（每次最多 chunk 字节）或错误，读完后返回 io.EOF；
+// Write 记录写入内容
+type scriptLink struct {
+	steps []any
+	chunk int
+	bytes.Buffer
+}
+
+// timeoutError 实现 net.Error 的读超时
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func (l *scriptLink) Read(p []byte) (int, error) {
+	if len(l.steps) == 0 {
+		return 0, io.EOF
+	}
+	switch s := l.steps[0].(type) {
+	case error:
+		l.steps = l.steps[1:]
+		return 0, s
+	case string:
+		n := copy(p[:min(len(p), l.chunk)], s)
+		if n == len(s) {
+			l.steps = l.steps[1:]
+		} else {
+			l.steps[0] = s[n:]
+		}
+		return n, nil
+	}
+	panic("scriptLink: 未知的步骤")
+}
+
+var _ io.ReadWriter = (*scriptLink)(nil)
+
+// readAll 读出全部帧，直到 ReadFrame 返回错误
+func readAll(t *testing.T, r *DRXReader) ([][]byte, error) {
+	t.Helper()
+	var frames [][]byte
+	for {
+		data, err := readFrame(t, r, 5*time.Second)
+		if err != nil {
+			return frames, err
+		}
+		frames = append(frames, data)
+	}
+}
+
+// TestReaderRecoversTooLong 超过行缓冲的行（含跨多个缓冲的行）丢弃后继续读取后续帧，
+// 超长行的剩余部分不被当作新的一行，同一行只计一次
+func TestReaderRecoversTooLong(t *testing.T) {
+	// MaxFrameSize=8 时行缓冲为 2*8+64=80 字节
+	long := "+DRX:238A0821BEF2,3," + strings.Repeat("22", 45) + "\r\n"
+	huge := strings.Repeat("garbage-", 100) + testDRXLine
+	link := &scriptLink{chunk: 16, steps: []any{testDRXLine + long + testDRXLine + huge + "+DRX:238A0821BEF2,1,33\r\n"}}
+	before := SnapshotReaderStats()
+
+	frames, err := readAll(t, NewDRXReaderOptions(link, ReaderOptions{MaxFrameSize: 8}))
+	if !errors.Is(err, io.EOF) {
+		t.Fatalf("读完后返回 %v，期望 io.EOF", err)
+	}
+	want := [][]byte{{0x11, 0x11, 0x11}, {0x11, 0x11, 0x11}, {0x33}}
+	if len(frames) != len(want) {
+		t.Fatalf("读出 % X，期望 % X", frames, want)
+	}
+	for i := range want {
+		if !bytes.Equal(frames[i], want[i]) {
+			t.Errorf("第 %d 帧 % X，期望 % X", i, frames[i], want[i])
+		}
+	}
+	after := SnapshotReaderStats()
+	if n := after.TooLong - before.TooLong; n != 2 {
+		t.Errorf("TooLong 增加 %d，期望 2", n)
+	}
+	if n := after.Oversize - before.Oversize; n != 0 {
+		t.Errorf("Oversize 增加 %d，期望 0（超长行未经解析即丢弃）", n)
+	}
+}
+
+// TestReaderRecoversTransient 暂时性读错误（EINTR、读超时）后重建 Scanner 继续读取；
+// 连续超过 maxTransientReadErrors 次或链路关闭时返回错误
+func TestReaderRecoversTransient(t *testing.T) {
+	before := SnapshotReaderStats().TransientErrors
+	link := &scriptLink{chunk: 7, steps: []any{
+		testDRXLine, syscall.EINTR, timeoutError{}, testDRXLine, syscall.EAGAIN, "+DRX:238A0821BEF2,1,33\r\n",
+	}}
+	frames, err := readAll(t, NewDRXReader(link))
+	if !errors.Is(err, io.EOF) || len(frames) != 3 || !bytes.Equal(frames[2], []byte{0x33}) {
+		t.Fatalf("读出 % X, %v，期望 3 帧后 io.EOF", frames, err)
+	}
+	if n := SnapshotReaderStats().TransientErrors - before; n != 3 {
+		t.Errorf("TransientErrors 增加 %d，期望 3", n)
+	}
+
+	// 连续的暂时性错误超过上限
+	steps := []any{testDRXLine}
+	for i := 0; i <= maxTransientReadErrors; i++ {
+		steps = append(steps, syscall.EINTR)
+	}
+	frames, err = readAll(t, NewDRXReader(&scriptLink{chunk: 64, steps: append(steps, testDRXLine)}))
+	if len(frames) != 1 || !errors.Is(err, syscall.EINTR) {
+		t.Errorf("连续 %d 次 EINTR: 读出 %d 帧, %v，期望 1 帧后返回 EINTR", maxTransientReadErrors+1, len(frames), err)
+	}
+
+	// 链路关闭不重试
+	frames, err = readAll(t, NewDRXReader(&scriptLink{chunk: 64, steps: []any{testDRXLine, io.ErrClosedPipe, testDRXLine}}))
+	if len(frames) != 1 || !errors.Is(err, ErrPortClosed) {
+		t.Errorf("链路关闭: 读出 %d 帧, %v，期望 1 帧后返回 ErrPortClosed", len(frames), err)
+	}
+}
@@ -0,0 +1,37 @@
+package serial
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// FormatDTXCommand 将二进制帧编码为模组发送指令
+// "AT+DTX=<length>,<hexPayload>\r\n"，length 为帧字节数。
+// 例如：[]byte{0x11,0x22} → "AT+DTX=2,1122\r\n"
+func FormatDTXCommand(frame []byte) string {
+	return fmt.Sprintf("AT+DTX=%d,%s\r\n", len(frame), strings.ToUpper(fmt.Sprintf("%x", frame)))
+}
+
+// WriteFrame 通过 AT+DTX 指令把一帧下行报文写入串口
+func WriteFrame(w io.Writer, frame []byte) error {
+	if len(frame) == 0 {
+		return fmt.Errorf("%w：下行帧", ErrEmptyFrame)
+	}
+	if _, err := io.WriteString(w, FormatDTXCommand(frame)); err != nil {
+		return fmt.Errorf("写入 AT+DTX 指令失败：%w", classify(err))
+	}
+	return nil
+}
+
+// WriteCommand 向模组写入一条 AT 指令，自动补齐 "\r\n"
+func WriteCommand(w io.Writer, cmd string) error {
+	cmd = strings.TrimSpace(cmd)
+	if cmd == "" {
+		return fmt.Errorf("%w：AT 指令", ErrEmptyFrame)
+	}
+	if _, err := io.WriteString(w, cmd+"\r\n"); err != nil {
+		return fmt.Errorf("写入 AT 指令 %s 失败：%w", cmd, classify(err))
+	}
+	return nil
+}
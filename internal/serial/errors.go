@@ -0,0 +1,46 @@
+package serial
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+)
+
+// 串口/链路相关的错误类别，调用方用 errors.Is 判断，指标可按类别分桶
+var (
+	// ErrPortClosed 链路已关闭（串口被拔出、TCP 连接断开后继续读写）
+	ErrPortClosed = errors.New("链路已关闭")
+	// ErrPortNotFound 按配置找不到可用的串口
+	ErrPortNotFound = errors.New("未找到串口")
+	// ErrInvalidPortName 端口名不符合当前平台的格式
+	ErrInvalidPortName = errors.New("串口名无效")
+	// ErrInvalidTransport 链路地址为空、格式错误或类型不支持
+	ErrInvalidTransport = errors.New("链路地址无效")
+	// ErrNotDRXLine 串口输出行不是 +DRX 数据行
+	ErrNotDRXLine = errors.New("不是 DRX 数据行")
+	// ErrMalformedDRXLine +DRX 数据行字段数或十六进制内容错误
+	ErrMalformedDRXLine = errors.New("DRX 数据行格式错误")
+	// ErrEmptyFrame 下行帧或 AT 指令为空
+	ErrEmptyFrame = errors.New("下行内容为空")
+	// ErrFrameTooLarge +DRX 数据行声明的长度或 payload 超过单帧上限
+	ErrFrameTooLarge = errors.New("DRX 帧超过长度上限")
+	// ErrInvalidDataTimeout 超过 ReaderOptions.InvalidDataTimeout 只收到无效数据，模组可能异常
+	ErrInvalidDataTimeout = errors.New("持续只收到无效数据")
+	// ErrReadTimeout 超过 ReaderOptions.ReadTimeout 链路上没有任何数据
+	ErrReadTimeout = errors.New("链路读超时")
+)
+
+// isClosedErr 判断读写错误是否由链路关闭引起
+func isClosedErr(err error) bool {
+	return errors.Is(err, os.ErrClosed) || errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, io.ErrClosedPipe) || errors.Is(err, io.EOF)
+}
+
+// classify 链路关闭引起的错误额外包装 ErrPortClosed
+func classify(err error) error {
+	if err != nil && isClosedErr(err) && !errors.Is(err, ErrPortClosed) {
+		return errors.Join(ErrPortClosed, err)
+	}
+	return err
+}
@@ -0,0 +1,37 @@
+//go:build darwin
+
+package serial
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultPortName 未配置 SerialPort 时使用的端口（CH340 等芯片在 macOS 下的常见名称）
+const DefaultPortName = "/dev/cu.usbserial-0001"
+
+// byIDSupported macOS 没有 /dev/serial/by-id，按 USB VID/PID 发现
+const byIDSupported = false
+
+// normalizePortName 把 /dev/tty.* 换成对应的 /dev/cu.*：
+// tty.* 打开时会等待 DCD 信号，USB 转串口模组上通常永远阻塞
+func normalizePortName(name string) string {
+	name = strings.TrimSpace(name)
+	if rest, ok := strings.CutPrefix(name, "/dev/tty."); ok {
+		return "/dev/cu." + rest
+	}
+	return name
+}
+
+// ValidatePortName 检查端口名是否为 /dev 下的设备
+func ValidatePortName(name string) error {
+	if !strings.HasPrefix(normalizePortName(name), "/dev/") {
+		return fmt.Errorf("%w：%q，macOS 下应为 /dev/cu.* 设备", ErrInvalidPortName, name)
+	}
+	return nil
+}
+
+// listablePort 只列出 cu.* 设备，同一串口的 tty.* 设备不重复列出
+func listablePort(name string) bool {
+	return !strings.HasPrefix(name, "/dev/tty.")
+}
@@ -0,0 +1,32 @@
+//go:build !windows && !darwin
+
+package serial
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultPortName 未配置 SerialPort 时使用的端口
+const DefaultPortName = "/dev/ttyUSB0"
+
+// byIDSupported udev 提供 /dev/serial/by-id 稳定链接
+const byIDSupported = true
+
+// normalizePortName Linux 下端口名原样使用
+func normalizePortName(name string) string {
+	return strings.TrimSpace(name)
+}
+
+// ValidatePortName 检查端口名是否为 /dev 下的设备
+func ValidatePortName(name string) error {
+	if !strings.HasPrefix(normalizePortName(name), "/dev/") {
+		return fmt.Errorf("%w：%q，应为 /dev/ttyUSB0 等设备路径", ErrInvalidPortName, name)
+	}
+	return nil
+}
+
+// listablePort 判断枚举到的端口是否应列出
+func listablePort(name string) bool {
+	return true
+}
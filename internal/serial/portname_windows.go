@@ -0,0 +1,36 @@
+//go:build windows
+
+package serial
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultPortName 未配置 SerialPort 时使用的端口
+const DefaultPortName = "COM3"
+
+// byIDSupported Windows 没有 /dev/serial/by-id，按 USB VID/PID 发现
+const byIDSupported = false
+
+var comPortRe = regexp.MustCompile(`^COM[1-9][0-9]*$`)
+
+// normalizePortName 统一为大写的 COMx；底层库会自动补 \\.\ 前缀，这里去掉用户填写的前缀避免重复
+func normalizePortName(name string) string {
+	name = strings.TrimPrefix(strings.TrimSpace(name), `\\.\`)
+	return strings.ToUpper(name)
+}
+
+// ValidatePortName 检查端口名是否为 COMx 形式
+func ValidatePortName(name string) error {
+	if !comPortRe.MatchString(normalizePortName(name)) {
+		return fmt.Errorf("%w：%q，Windows 下应为 COM1、COM12 等", ErrInvalidPortName, name)
+	}
+	return nil
+}
+
+// listablePort 判断枚举到的端口是否应列出
+func listablePort(name string) bool {
+	return true
+}
@@ -0,0 +1,41 @@
+package serial
+
+import (
+	"fmt"
+	"sort"
+
+	"go.bug.st/serial.v1/enumerator"
+)
+
+// PortInfo 本机的一个串口
+type PortInfo struct {
+	Name         string
+	IsUSB        bool
+	VID          string
+	PID          string
+	SerialNumber string
+}
+
+// ListPorts 列出本机串口并按名称排序（Linux 为 /dev/tty*，Windows 为 COMx，macOS 为 /dev/cu.*），
+// 供端口发现和开发机上的调试工具共用
+func ListPorts() ([]PortInfo, error) {
+	ports, err := enumerator.GetDetailedPortsList()
+	if err != nil {
+		return nil, fmt.Errorf("枚举串口失败：%w", err)
+	}
+	out := make([]PortInfo, 0, len(ports))
+	for _, p := range ports {
+		if !listablePort(p.Name) {
+			continue
+		}
+		out = append(out, PortInfo{
+			Name:         normalizePortName(p.Name),
+			IsUSB:        p.IsUSB,
+			VID:          p.VID,
+			PID:          p.PID,
+			SerialNumber: p.SerialNumber,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
@@ -0,0 +1,121 @@
+package serial
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// defaultDialTimeout TCP 透传链路的连接超时
+const defaultDialTimeout = 5 * time.Second
+
+// Transport 为模组的上下行链路：本地串口，或经 ser2net 等工具透传的 TCP 连接
+type Transport interface {
+	// Open 打开链路，返回连接及实际地址（用于日志和事件）
+	Open() (io.ReadWriteCloser, string, error)
+	String() string
+}
+
+// SerialTransport 本地串口链路，每次打开都重新发现端口
+type SerialTransport struct {
+	Spec     PortSpec
+	BaudRate int
+}
+
+// Open 实现 Transport
+func (t SerialTransport) Open() (io.ReadWriteCloser, string, error) {
+	name, err := Discover(t.Spec)
+	if err != nil {
+		return nil, "", err
+	}
+	p, err := Open(name, t.BaudRate)
+	if err != nil {
+		return nil, name, fmt.Errorf("打开串口 %s 失败：%w", name, err)
+	}
+	return p, name, nil
+}
+
+func (t SerialTransport) String() string {
+	switch {
+	case t.Spec.ByIDPattern != "":
+		return "serial(by-id " + t.Spec.ByIDPattern + ")"
+	case t.Spec.Model != "" || t.Spec.VID != "" || t.Spec.PID != "":
+		return "serial(usb)"
+	}
+	return "serial(" + t.Spec.Name + ")"
+}
+
+// TCPTransport 通过 TCP 连接访问远端串口服务器（如 ser2net），Secure 时经 TLS 连接
+type TCPTransport struct {
+	Addr        string
+	DialTimeout time.Duration
+	Secure      bool
+	// TLS 为 Secure 时的客户端配置，为 nil 时使用系统根证书校验服务端
+	TLS *tls.Config
+}
+
+// Open 实现 Transport
+func (t TCPTransport) Open() (io.ReadWriteCloser, string, error) {
+	timeout := t.DialTimeout
+	if timeout <= 0 {
+		timeout = defaultDialTimeout
+	}
+	if t.Secure {
+		cfg := t.TLS
+		if cfg == nil {
+			host, _, _ := net.SplitHostPort(t.Addr)
+			cfg = &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+		}
+		conn, err := tls.DialWithDialer(&net.Dialer{Timeout: timeout}, "tcp", t.Addr, cfg)
+		if err != nil {
+			return nil, t.Addr, fmt.Errorf("TLS 连接 %s 失败：%w", t.Addr, err)
+		}
+		return conn, t.Addr, nil
+	}
+	conn, err := net.DialTimeout("tcp", t.Addr, timeout)
+	if err != nil {
+		return nil, t.Addr, fmt.Errorf("连接 %s 失败：%w", t.Addr, err)
+	}
+	return conn, t.Addr, nil
+}
+
+// Host 返回连接地址中的主机名，用于校验服务端证书
+func (t TCPTransport) Host() string {
+	host, _, _ := net.SplitHostPort(t.Addr)
+	return host
+}
+
+func (t TCPTransport) String() string {
+	if t.Secure {
+		return "tls://" + t.Addr
+	}
+	return "tcp://" + t.Addr
+}
+
+// ParseTransport 解析链路地址：
+//
+//	tcp://host:port          — TCP 透传
+//	tls://host:port          — 经 TLS 的 TCP 透传
+//	serial:///dev/ttyUSB1    — 本地串口
+//	/dev/ttyUSB1、COM3       — 本地串口（省略 scheme）
+func ParseTransport(s string, baudRate int) (Transport, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case s == "":
+		return nil, fmt.Errorf("%w：地址为空", ErrInvalidTransport)
+	case strings.HasPrefix(s, "tcp://"), strings.HasPrefix(s, "tls://"):
+		scheme, addr, _ := strings.Cut(s, "://")
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return nil, fmt.Errorf("%w：TCP 地址 %q：%w", ErrInvalidTransport, s, err)
+		}
+		return TCPTransport{Addr: addr, Secure: scheme == "tls"}, nil
+	case strings.HasPrefix(s, "serial://"):
+		s = strings.TrimPrefix(s, "serial://")
+	case strings.Contains(s, "://"):
+		return nil, fmt.Errorf("%w：不支持的链路类型 %q", ErrInvalidTransport, s)
+	}
+	return SerialTransport{Spec: PortSpec{Name: s}, BaudRate: baudRate}, nil
+}
@@ -0,0 +1,89 @@
+// Package sessionlog 为每个传感器保留一份滚动的活动记录（最近的帧摘要、错误和配置变更），
+// 随支持包导出，便于向厂家提交问题时还原现场。
+package sessionlog
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Kind 记录类别，各类别分别保留最近 N 条
+type Kind string
+
+const (
+	KindFrame  Kind = "frame"  // 收到的帧摘要
+	KindError  Kind = "error"  // 解析或下发错误
+	KindConfig Kind = "config" // 参数写入、回读差异等配置变更
+)
+
+// DefaultSize 每个传感器每个类别默认保留的条数
+const DefaultSize = 50
+
+// Entry 一条活动记录
+type Entry struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+// Session 一个传感器的活动记录快照，按时间先后排列
+type Session struct {
+	SensorID string  `json:"sensorId"`
+	Frames   []Entry `json:"frames"`
+	Errors   []Entry `json:"errors"`
+	Configs  []Entry `json:"configs"`
+}
+
+// Log 按 SensorID 保存活动记录，并发安全
+type Log struct {
+	size int
+
+	mu       sync.Mutex
+	sessions map[string]map[Kind][]Entry
+}
+
+// Default 解析器和驱动共用的活动记录
+var Default = New(DefaultSize)
+
+// New 创建活动记录，size 为每个传感器每个类别保留的条数
+func New(size int) *Log {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	return &Log{size: size, sessions: make(map[string]map[Kind][]Entry)}
+}
+
+// Record 追加一条记录，超出条数时丢弃最旧的
+func (l *Log) Record(sensorID string, kind Kind, format string, args ...any) {
+	if sensorID == "" {
+		return
+	}
+	e := Entry{Time: time.Now(), Message: fmt.Sprintf(format, args...)}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s, ok := l.sessions[sensorID]
+	if !ok {
+		s = make(map[Kind][]Entry, 3)
+		l.sessions[sensorID] = s
+	}
+	list := append(s[kind], e)
+	if len(list) > l.size {
+		// 复制到新切片，避免底层数组无限增长
+		list = append([]Entry(nil), list[len(list)-l.size:]...)
+	}
+	s[kind] = list
+}
+
+// Snapshot 返回传感器活动记录的副本
+func (l *Log) Snapshot(sensorID string) Session {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := l.sessions[sensorID]
+	return Session{
+		SensorID: sensorID,
+		Frames:   append([]Entry{}, s[KindFrame]...),
+		Errors:   append([]Entry{}, s[KindError]...),
+		Configs:  append([]Entry{}, s[KindConfig]...),
+	}
+}
@@ -0,0 +1,77 @@
+// Package simulator 生成合成的传感器上行流量：业务数据帧、心跳、分片帧以及
+// 串口模块输出的 +DRX 行，供集成测试和 lpmp-bench 压测工具在进程内驱动解析链路。
+package simulator
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/linjuya-lu/device-lpmp-go/internal/frameparser"
+)
+
+// Sensor 一个模拟传感器，维护自己的分片报文序号 SSEQ；非并发安全
+type Sensor struct {
+	ID    [6]byte
+	HexID string
+	sseq  uint8
+}
+
+// NewSensor 按 12 位十六进制 SensorID 创建模拟传感器
+func NewSensor(hexID string) (*Sensor, error) {
+	id, err := frameparser.ParseSensorID(hexID)
+	if err != nil {
+		return nil, err
+	}
+	return SensorFromID(id), nil
+}
+
+// SensorFromID 按 6 字节 SensorID 创建模拟传感器
+func SensorFromID(id [6]byte) *Sensor {
+	return &Sensor{ID: id, HexID: strings.ToUpper(hex.EncodeToString(id[:]))}
+}
+
+// Monitoring 构造一帧监测数据报文
+func (s *Sensor) Monitoring(params ...frameparser.ParamValue) ([]byte, error) {
+	return frameparser.BuildBusinessFrame(s.ID, frameparser.PacketTypeMonitoring, params)
+}
+
+// Alarm 构造一帧告警数据报文
+func (s *Sensor) Alarm(params ...frameparser.ParamValue) ([]byte, error) {
+	return frameparser.BuildBusinessFrame(s.ID, frameparser.PacketTypeAlarm, params)
+}
+
+// Heartbeat 构造一帧心跳：不携带参量（DataLen=0）的监测数据报文
+func (s *Sensor) Heartbeat() []byte {
+	buf := make([]byte, 0, 6+1+2)
+	buf = append(buf, s.ID[:]...)
+	buf = append(buf, frameparser.PacketTypeMonitoring&0x07)
+	return frameparser.StandardDialect.Seal(buf)
+}
+
+// ControlResponse 构造一帧设置类控制响应（RequestSetFlag=1），只携带 1 字节执行状态，
+// 如 frameparser.ControlStatusSuccess
+func (s *Sensor) ControlResponse(ctrlType uint8, status byte) []byte {
+	buf := make([]byte, 0, 6+1+1+1+2)
+	buf = append(buf, s.ID[:]...)
+	buf = append(buf, frameparser.PacketTypeControlResp&0x07)
+	buf = append(buf, (ctrlType&0x7F)<<1|0x01, status)
+	return frameparser.StandardDialect.Seal(buf)
+}
+
+// Line 把一帧报文包装为该传感器的 +DRX 行
+func (s *Sensor) Line(frame []byte) []byte {
+	return DRXLine(s.HexID, frame)
+}
+
+// MonitoringFragments 取下一个 SSEQ，把一帧监测数据报文的内容切成最多 n 片分片帧（见
+// frameparser.BuildFragmentFrames）；返回的分片帧按 PSEQ 顺序排列，可逐帧用 Line 写入串口
+func (s *Sensor) MonitoringFragments(n int, params ...frameparser.ParamValue) ([][]byte, error) {
+	s.sseq = (s.sseq + 1) & 0x3F
+	return frameparser.BuildFragmentFrames(s.ID, frameparser.PacketTypeMonitoring, s.sseq, params, n)
+}
+
+// DRXLine 构造串口模块输出的一行 "+DRX:<SensorID>,<长度>,<十六进制报文>\r\n"
+func DRXLine(hexID string, frame []byte) []byte {
+	return []byte(fmt.Sprintf("+DRX:%s,%d,%X\r\n", hexID, len(frame), frame))
+}
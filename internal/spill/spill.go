@@ -0,0 +1,354 @@
+// Package spill 是驱动的磁盘溢出队列：core-data / 消息总线或外部 MQTT Broker 暂时不可用时，
+// 本应丢弃的读数按到达顺序写入本地目录，恢复后由驱动按同样的顺序取出重新发布。
+// 队列由若干分段文件组成，每行一条记录（写入时间 + 记录内容），总大小超过上限时删除最旧的分段，
+// 超过保留时长的记录在取出时丢弃。
+package spill
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Driver 配置段中与磁盘溢出队列相关的键名
+const (
+	KeyEnabled   = "SpillEnabled"
+	KeyDir       = "SpillDir"
+	KeyMaxSizeMB = "SpillMaxSizeMB"
+	KeyRetention = "SpillRetention"
+)
+
+const (
+	fileSuffix = ".spill"
+	// maxSegment 单个分段文件的大小上限，取出时整段读入内存
+	maxSegment = 1 << 20
+	// minSegment 总大小上限很小时分段也不小于此值
+	minSegment = 4 << 10
+)
+
+// ErrTooLarge 单条记录超过队列总大小上限
+var ErrTooLarge = errors.New("记录超过溢出队列大小上限")
+
+// Config 保存磁盘溢出队列配置
+type Config struct {
+	Enabled bool
+	Dir     string
+	// MaxSize 每个队列（EdgeX、MQTT 各一个子目录）的总字节数上限
+	MaxSize int64
+	// Retention 记录写入后保留的时长，超过后不再重新发布
+	Retention time.Duration
+}
+
+// ConfigFromDriver 从 Driver 配置段读取磁盘溢出队列配置，未配置项使用默认值
+func ConfigFromDriver(driverCfg map[string]string) (Config, error) {
+	cfg := Config{
+		Dir:       "./spill",
+		MaxSize:   64 << 20,
+		Retention: 72 * time.Hour,
+	}
+	if v := driverCfg[KeyEnabled]; v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return cfg, fmt.Errorf("%s 配置无效 %q：%w", KeyEnabled, v, err)
+		}
+		cfg.Enabled = b
+	}
+	if v := driverCfg[KeyDir]; v != "" {
+		cfg.Dir = v
+	}
+	if v := driverCfg[KeyMaxSizeMB]; v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			return cfg, fmt.Errorf("%s 配置无效 %q", KeyMaxSizeMB, v)
+		}
+		cfg.MaxSize = n << 20
+	}
+	if v := driverCfg[KeyRetention]; v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return cfg, fmt.Errorf("%s 配置无效 %q", KeyRetention, v)
+		}
+		cfg.Retention = d
+	}
+	return cfg, nil
+}
+
+// Stats 队列的当前积压和启动以来的累计计数
+type Stats struct {
+	Pending  int   `json:"pending"`
+	Bytes    int64 `json:"bytes"`
+	Spilled  int64 `json:"spilled"`
+	Replayed int64 `json:"replayed"`
+	// Dropped 因总大小超限被删除的记录
+	Dropped int64 `json:"dropped"`
+	// Expired 超过保留时长未能重新发布的记录
+	Expired int64 `json:"expired"`
+}
+
+type segment struct {
+	seq   uint64
+	size  int64
+	count int
+}
+
+// Queue 一个目录下的 FIFO 队列，并发安全
+type Queue struct {
+	dir       string
+	maxSize   int64
+	segSize   int64
+	retention time.Duration
+
+	mu       sync.Mutex
+	segments []segment // 按 seq 升序，最后一个为正在写入的分段
+	tail     *os.File
+	stats    Stats
+	// drainMu 串行化 Drain；重新发布记录期间不持有 mu，Push、Len 不被阻塞
+	drainMu sync.Mutex
+}
+
+// Open 打开（必要时创建）dir 下的队列，统计上次运行遗留的记录
+func Open(dir string, cfg Config) (*Queue, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("创建溢出队列目录 %s 失败：%w", dir, err)
+	}
+	q := &Queue{
+		dir:       dir,
+		maxSize:   cfg.MaxSize,
+		segSize:   min(maxSegment, max(minSegment, cfg.MaxSize/16)),
+		retention: cfg.Retention,
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, "*"+fileSuffix))
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range matches {
+		seq, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(m), fileSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(m)
+		if err != nil {
+			return nil, fmt.Errorf("读取溢出队列分段 %s 失败：%w", m, err)
+		}
+		seg := segment{seq: seq, size: int64(len(data)), count: bytes.Count(data, []byte{'\n'})}
+		q.segments = append(q.segments, seg)
+		q.stats.Pending += seg.count
+		q.stats.Bytes += seg.size
+	}
+	sort.Slice(q.segments, func(i, j int) bool { return q.segments[i].seq < q.segments[j].seq })
+	return q, nil
+}
+
+func (q *Queue) path(seq uint64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d%s", seq, fileSuffix))
+}
+
+// Len 返回积压的记录数
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.stats.Pending
+}
+
+// Stats 返回当前统计
+func (q *Queue) Stats() Stats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.stats
+}
+
+// Dir 返回队列所在目录
+func (q *Queue) Dir() string {
+	return q.dir
+}
+
+// ResetStats 清零累计计数，积压数和字节数不变
+func (q *Queue) ResetStats() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.stats = Stats{Pending: q.stats.Pending, Bytes: q.stats.Bytes}
+}
+
+// Push 在队尾追加一条记录，record 不能包含换行；总大小超过上限时先删除最旧的分段
+func (q *Queue) Push(record []byte, at time.Time) error {
+	if bytes.IndexByte(record, '\n') >= 0 {
+		return fmt.Errorf("溢出队列记录不能包含换行")
+	}
+	line := make([]byte, 0, len(record)+24)
+	line = strconv.AppendInt(line, at.UnixNano(), 10)
+	line = append(line, ' ')
+	line = append(line, record...)
+	line = append(line, '\n')
+	if int64(len(line)) > q.maxSize {
+		return ErrTooLarge
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for q.stats.Bytes+int64(len(line)) > q.maxSize && len(q.segments) > 0 {
+		q.removeOldest()
+	}
+	if q.tail == nil || q.segments[len(q.segments)-1].size+int64(len(line)) > q.segSize {
+		if err := q.rotate(); err != nil {
+			return err
+		}
+	}
+	if _, err := q.tail.Write(line); err != nil {
+		return fmt.Errorf("写入溢出队列 %s 失败：%w", q.dir, err)
+	}
+	seg := &q.segments[len(q.segments)-1]
+	seg.size += int64(len(line))
+	seg.count++
+	q.stats.Pending++
+	q.stats.Bytes += int64(len(line))
+	q.stats.Spilled++
+	return nil
+}
+
+// rotate 关闭当前分段，新建下一个分段用于写入，调用方持有锁
+func (q *Queue) rotate() error {
+	q.closeTail()
+	var seq uint64 = 1
+	if n := len(q.segments); n > 0 {
+		seq = q.segments[n-1].seq + 1
+	}
+	f, err := os.OpenFile(q.path(seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("创建溢出队列分段失败：%w", err)
+	}
+	q.tail = f
+	q.segments = append(q.segments, segment{seq: seq})
+	return nil
+}
+
+func (q *Queue) closeTail() {
+	if q.tail != nil {
+		_ = q.tail.Close()
+		q.tail = nil
+	}
+}
+
+// removeOldest 删除最旧的分段，其中的记录计入 Dropped，调用方持有锁
+func (q *Queue) removeOldest() {
+	seg := q.segments[0]
+	if len(q.segments) == 1 {
+		q.closeTail()
+	}
+	_ = os.Remove(q.path(seg.seq))
+	q.segments = q.segments[1:]
+	q.stats.Pending -= seg.count
+	q.stats.Bytes -= seg.size
+	q.stats.Dropped += int64(seg.count)
+}
+
+// Drain 从队头起按顺序把记录交给 fn，fn 返回 false 时停止，该记录及之后的记录留在队列中；
+// 超过保留时长的记录直接丢弃。返回交给 fn 并被接受的记录数。
+// 逐个分段处理，调用 fn 期间不持有队列锁，Push 和 Len 不等待重新发布；
+// 期间该分段因总大小超限被删除时，其记录（包括已交给 fn 的）计入 Dropped
+func (q *Queue) Drain(now time.Time, fn func(record []byte) bool) (int, error) {
+	q.drainMu.Lock()
+	defer q.drainMu.Unlock()
+	accepted := 0
+	for {
+		q.mu.Lock()
+		if len(q.segments) == 0 {
+			q.mu.Unlock()
+			return accepted, nil
+		}
+		seg := q.segments[0]
+		if len(q.segments) == 1 {
+			// 正在写入的分段：之后的记录写入新分段
+			q.closeTail()
+		}
+		q.mu.Unlock()
+
+		data, err := os.ReadFile(q.path(seg.seq))
+		if err != nil && !os.IsNotExist(err) {
+			return accepted, fmt.Errorf("读取溢出队列分段失败：%w", err)
+		}
+		rest, n, expired, stopped := q.consume(data, now, fn)
+		accepted += n
+
+		q.mu.Lock()
+		q.stats.Replayed += int64(n)
+		q.stats.Expired += int64(expired)
+		if len(q.segments) == 0 || q.segments[0].seq != seg.seq {
+			// 已被 removeOldest 删除
+			q.mu.Unlock()
+			if stopped {
+				return accepted, nil
+			}
+			continue
+		}
+		if !stopped {
+			_ = os.Remove(q.path(seg.seq))
+			q.segments = q.segments[1:]
+			q.stats.Pending -= seg.count
+			q.stats.Bytes -= seg.size
+			q.mu.Unlock()
+			continue
+		}
+		// 未取完：剩余记录写回分段
+		err = writeFileAtomic(q.path(seg.seq), rest)
+		if err == nil {
+			left := bytes.Count(rest, []byte{'\n'})
+			q.stats.Pending -= seg.count - left
+			q.stats.Bytes -= seg.size - int64(len(rest))
+			q.segments[0].count, q.segments[0].size = left, int64(len(rest))
+		}
+		q.mu.Unlock()
+		return accepted, err
+	}
+}
+
+// consume 逐行处理一个分段，返回未处理的部分（从 fn 拒绝的记录开始）、被接受和已过期的记录数
+func (q *Queue) consume(data []byte, now time.Time, fn func([]byte) bool) (rest []byte, accepted, expired int, stopped bool) {
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64<<10), len(data)+1)
+	offset := 0
+	for sc.Scan() {
+		line := sc.Bytes()
+		next := offset + len(line) + 1
+		ts, record, ok := bytes.Cut(line, []byte{' '})
+		nanos, err := strconv.ParseInt(string(ts), 10, 64)
+		switch {
+		case !ok || err != nil || next > len(data):
+			// 损坏的行和没有换行的末行（如异常断电时写了一半）跳过
+		case now.Sub(time.Unix(0, nanos)) > q.retention:
+			expired++
+		case !fn(record):
+			return data[offset:], accepted, expired, true
+		default:
+			accepted++
+		}
+		offset = next
+	}
+	return nil, accepted, expired, false
+}
+
+// writeFileAtomic 先写临时文件再改名，避免断电时留下半截分段
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return fmt.Errorf("写回溢出队列分段失败：%w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("写回溢出队列分段失败：%w", err)
+	}
+	return nil
+}
+
+// Close 关闭正在写入的分段，积压的记录留在目录中，下次 Open 时继续
+func (q *Queue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closeTail()
+}
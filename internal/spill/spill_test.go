@@ -0,0 +1,303 @@
+package spill
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueue(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{MaxSize: 10 << 10, Retention: time.Hour}
+	q, err := Open(dir, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := q.Push([]byte("r"+strconv.Itoa(i)), now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.Push([]byte("a\nb"), now); err == nil {
+		t.Error("含换行的记录未被拒绝")
+	}
+	// fn 拒绝后停止，剩余记录留在队列中
+	var got []string
+	n, err := q.Drain(now, func(b []byte) bool {
+		if len(got) == 1 {
+			return false
+		}
+		got = append(got, string(b))
+		return true
+	})
+	if err != nil || n != 1 || q.Len() != 2 {
+		t.Fatalf("部分取出 n=%d len=%d err=%v", n, q.Len(), err)
+	}
+	// 重新打开后继续
+	q.Close()
+	if q, err = Open(dir, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Push([]byte("r3"), now.Add(-2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Drain(now, func(b []byte) bool { got = append(got, string(b)); return true }); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, []string{"r0", "r1", "r2"}) || q.Len() != 0 || q.Stats().Expired != 1 {
+		t.Errorf("取出 %v，统计 %+v", got, q.Stats())
+	}
+
+	// 超过大小上限时删除最旧的分段，保留最新的记录
+	record := strings.Repeat("x", 90)
+	for i := 0; i < 200; i++ {
+		if err := q.Push([]byte(strconv.Itoa(i)+record), now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	st := q.Stats()
+	if st.Dropped == 0 || st.Bytes > cfg.MaxSize || st.Pending+int(st.Dropped) != 200 {
+		t.Errorf("超限后统计 %+v", st)
+	}
+	var last string
+	if _, err := q.Drain(now, func(b []byte) bool { last = string(b); return true }); err != nil || last != "199"+record {
+		t.Errorf("最后一条 %q，err=%v", last, err)
+	}
+}
+
+func TestDrainUnlocked(t *testing.T) {
+	q, err := Open(t.TempDir(), Config{MaxSize: 1 << 20, Retention: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for i := range 3 {
+		if err := q.Push([]byte("r"+strconv.Itoa(i)), now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 重新发布期间（如 pushAsync 判断积压、新读数排队）Len 和 Push 不等待 Drain
+	var got []string
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err = q.Drain(now, func(b []byte) bool {
+			got = append(got, string(b))
+			if q.Len() == 0 {
+				t.Error("重新发布期间积压数为 0")
+			}
+			return len(got) > 1 || q.Push([]byte("new"), now) == nil
+		})
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Drain 期间 Len/Push 被阻塞")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 重新发布期间追加的记录排在原有记录之后
+	if want := []string{"r0", "r1", "r2", "new"}; !reflect.DeepEqual(got, want) || q.Len() != 0 {
+		t.Errorf("取出 %v、积压 %d，期望 %v、0", got, q.Len(), want)
+	}
+}
+
+func TestConfigFromDriver(t *testing.T) {
+	cfg, err := ConfigFromDriver(map[string]string{})
+	if err != nil || cfg.Enabled || cfg.Dir != "./spill" || cfg.MaxSize != 64<<20 || cfg.Retention != 72*time.Hour {
+		t.Errorf("默认配置 %+v, %v", cfg, err)
+	}
+	cfg, err = ConfigFromDriver(map[string]string{KeyEnabled: "true", KeyDir: "/var/spill", KeyMaxSizeMB: "8", KeyRetention: "1h"})
+	if err != nil || !cfg.Enabled || cfg.Dir != "/var/spill" || cfg.MaxSize != 8<<20 || cfg.Retention != time.Hour {
+		t.Errorf("配置 %+v, %v", cfg, err)
+	}
+	for _, bad := range []map[string]string{
+		{KeyEnabled: "yes-please"},
+		{KeyMaxSizeMB: "0"},
+		{KeyMaxSizeMB: "many"},
+		{KeyRetention: "-1h"},
+		{KeyRetention: "forever"},
+	} {
+		if _, err := ConfigFromDriver(bad); err == nil {
+			t.Errorf("%v 未报错", bad)
+		}
+	}
+}
+
+// TestReplayOrder 跨多个分段的记录按写入顺序取出；分段中途停止后，下次 Drain 和重新打开后都从停止处继续
+func TestReplayOrder(t *testing.T) {
+	dir := t.TempDir()
+	// 分段 4KB，约 80 条一段
+	cfg := Config{MaxSize: 64 << 10, Retention: time.Hour}
+	q, err := Open(dir, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	const n = 500
+	for i := range n {
+		if err := q.Push(fmt.Appendf(nil, "record-%03d-%s", i, strings.Repeat("x", 20)), now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if segs, _ := filepath.Glob(filepath.Join(dir, "*"+fileSuffix)); len(segs) < 3 {
+		t.Fatalf("只写了 %d 个分段，测试需要跨分段", len(segs))
+	}
+
+	var got []string
+	// drain 取出记录，取满 limit 条后拒绝
+	drain := func(q *Queue, limit int) {
+		t.Helper()
+		taken := 0
+		if _, err := q.Drain(now, func(b []byte) bool {
+			if taken == limit {
+				return false
+			}
+			taken++
+			got = append(got, string(b[:10]))
+			return true
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	drain(q, 150)
+	drain(q, 50)
+	if q.Len() != n-200 {
+		t.Errorf("取出 200 条后积压 %d，期望 %d", q.Len(), n-200)
+	}
+	q.Close()
+	if q, err = Open(dir, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if q.Len() != n-200 {
+		t.Errorf("重新打开后积压 %d，期望 %d", q.Len(), n-200)
+	}
+	if err := q.Push([]byte("record-new-"), now); err != nil {
+		t.Fatal(err)
+	}
+	drain(q, -1)
+
+	if len(got) != n+1 {
+		t.Fatalf("共取出 %d 条，期望 %d", len(got), n+1)
+	}
+	for i := range n {
+		if want := fmt.Sprintf("record-%03d", i); got[i] != want {
+			t.Fatalf("第 %d 条为 %s，期望 %s", i, got[i], want)
+		}
+	}
+	if got[n] != "record-new" {
+		t.Errorf("重新打开后追加的记录取出为 %s", got[n])
+	}
+	if st := q.Stats(); st.Pending != 0 || st.Bytes != 0 {
+		t.Errorf("取完后统计 %+v", st)
+	}
+	if segs, _ := filepath.Glob(filepath.Join(dir, "*")); len(segs) != 0 {
+		t.Errorf("取完后遗留文件 %v", segs)
+	}
+}
+
+// TestCorruptSegment 损坏的行（缺时间戳、时间戳无效）和异常断电留下的没有换行的末行跳过，其余记录照常取出
+func TestCorruptSegment(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	ts := strconv.FormatInt(now.UnixNano(), 10)
+	segs := map[string]string{
+		"00000000000000000001" + fileSuffix: ts + " a\n" + "garbage\n" + "17x b\n" + ts + " c\n" + ts + " trunc",
+		"00000000000000000002" + fileSuffix: ts[:8],
+		"00000000000000000003" + fileSuffix: ts + " d\n",
+		// 不是分段文件
+		"notes.txt":                ts + " ignored\n",
+		"x" + fileSuffix:           ts + " ignored\n",
+		"00000000000000000004.tmp": ts + " ignored\n",
+	}
+	for name, data := range segs {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o640); err != nil {
+			t.Fatal(err)
+		}
+	}
+	q, err := Open(dir, Config{MaxSize: 1 << 20, Retention: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 按换行计数：损坏的行也计入积压，取出时跳过
+	if q.Len() != 5 {
+		t.Errorf("积压 %d，期望 5", q.Len())
+	}
+	var got []string
+	n, err := q.Drain(now, func(b []byte) bool { got = append(got, string(b)); return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "c", "d"}; n != len(want) || !reflect.DeepEqual(got, want) {
+		t.Errorf("取出 %d 条 %q，期望 %q", n, got, want)
+	}
+	if st := q.Stats(); st.Pending != 0 || st.Bytes != 0 || st.Replayed != 3 {
+		t.Errorf("取完后统计 %+v", st)
+	}
+	// 截断的分段取完后删除，新记录写入新的分段
+	if err := q.Push([]byte("e"), now); err != nil {
+		t.Fatal(err)
+	}
+	got = nil
+	if _, err := q.Drain(now, func(b []byte) bool { got = append(got, string(b)); return true }); err != nil || !reflect.DeepEqual(got, []string{"e"}) {
+		t.Errorf("新记录取出 %q, %v", got, err)
+	}
+}
+
+// TestDrainSegmentDropped 重新发布期间 Push 超出大小上限、删除了正在取出的分段时，Drain 跳到下一个分段继续，
+// 统计与文件一致
+func TestDrainSegmentDropped(t *testing.T) {
+	dir := t.TempDir()
+	// 总大小 16KB，分段 4KB
+	q, err := Open(dir, Config{MaxSize: 16 << 10, Retention: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	record := strings.Repeat("x", 100)
+	push := func(prefix string, n int) {
+		t.Helper()
+		for i := range n {
+			if err := q.Push([]byte(prefix+strconv.Itoa(i)+record), now); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	push("old", 60)
+
+	var got []string
+	pushed := false
+	if _, err := q.Drain(now, func(b []byte) bool {
+		got = append(got, string(b))
+		if !pushed {
+			// 填满队列，删除包括正在取出的分段在内的全部旧分段
+			pushed = true
+			push("new", 150)
+		}
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	st := q.Stats()
+	if st.Dropped == 0 || st.Pending != 0 || st.Bytes != 0 {
+		t.Errorf("统计 %+v，期望有 Dropped、取完后无积压", st)
+	}
+	if last := got[len(got)-1]; last != "new149"+record {
+		t.Errorf("最后取出 %.10s…，期望 new149", last)
+	}
+	for i := 1; i < len(got); i++ {
+		if strings.HasPrefix(got[i], "old") && strings.HasPrefix(got[i-1], "new") {
+			t.Fatalf("新记录 %.10s 之后取出了旧记录 %.10s", got[i-1], got[i])
+		}
+	}
+	if segs, _ := filepath.Glob(filepath.Join(dir, "*")); len(segs) != 0 {
+		t.Errorf("取完后遗留文件 %v", segs)
+	}
+}
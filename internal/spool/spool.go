@@ -0,0 +1,162 @@
+// Package spool 将拼接完成的业务数据单元（SDU）以及超时/被替换丢弃的未完成 SDU
+// 原样写入本地目录，供与厂家排查异常载荷时取证，目录总大小超过上限时删除最旧的文件。
+package spool
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Driver 配置段中与 SDU 导出相关的键名，驱动的 LpmpCustom 配置节映射到同名键
+const (
+	KeyEnabled   = "SpoolEnabled"
+	KeyDir       = "SpoolDir"
+	KeyMaxSizeMB = "SpoolMaxSizeMB"
+)
+
+const (
+	fileSuffix = ".bin"
+	// droppedDir 未完成即被丢弃的 SDU 所在子目录
+	droppedDir = "dropped"
+)
+
+// Config 保存 SDU 导出配置
+type Config struct {
+	Enabled bool
+	Dir     string
+	MaxSize int64 // 目录（含 dropped 子目录）总字节数上限
+}
+
+// ConfigFromDriver 从 Driver 配置段读取 SDU 导出配置，未配置项使用默认值
+func ConfigFromDriver(driverCfg map[string]string) (Config, error) {
+	cfg := Config{
+		Dir:     "./spool",
+		MaxSize: 256 << 20,
+	}
+	if v := driverCfg[KeyEnabled]; v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return cfg, fmt.Errorf("%s 配置无效 %q：%w", KeyEnabled, v, err)
+		}
+		cfg.Enabled = b
+	}
+	if v := driverCfg[KeyDir]; v != "" {
+		cfg.Dir = v
+	}
+	if v := driverCfg[KeyMaxSizeMB]; v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			return cfg, fmt.Errorf("%s 配置无效 %q", KeyMaxSizeMB, v)
+		}
+		cfg.MaxSize = n << 20
+	}
+	return cfg, nil
+}
+
+// Spool 写 SDU 文件并维护目录大小，并发安全
+type Spool struct {
+	cfg Config
+
+	mu   sync.Mutex
+	size int64 // 当前目录总大小
+}
+
+// New 创建导出目录并统计已有文件大小
+func New(cfg Config) (*Spool, error) {
+	if err := os.MkdirAll(filepath.Join(cfg.Dir, droppedDir), 0o750); err != nil {
+		return nil, fmt.Errorf("创建 SDU 导出目录 %s 失败：%w", cfg.Dir, err)
+	}
+	s := &Spool{cfg: cfg}
+	for _, f := range s.files() {
+		s.size += f.size
+	}
+	return s, nil
+}
+
+// Write 写入一个 SDU，文件名为 <SensorID>_<SSEQ>_<时间戳>.bin；
+// complete 为 false 时写入 dropped 子目录
+func (s *Spool) Write(sensorID string, sseq uint8, data []byte, complete bool) error {
+	dir := s.cfg.Dir
+	if !complete {
+		dir = filepath.Join(dir, droppedDir)
+	}
+	name := filepath.Join(dir, fmt.Sprintf("%s_%d_%s%s", sensorID, sseq, time.Now().Format("20060102T150405.000000"), fileSuffix))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.WriteFile(name, data, 0o640); err != nil {
+		return fmt.Errorf("写入 SDU 文件 %s 失败：%w", name, err)
+	}
+	s.size += int64(len(data))
+	if s.size > s.cfg.MaxSize {
+		s.purgeOldest()
+	}
+	return nil
+}
+
+// Recent 返回该传感器最近的 n 个 SDU 文件路径（含 dropped 子目录），按时间从新到旧
+func (s *Spool) Recent(sensorID string, n int) []string {
+	s.mu.Lock()
+	files := s.files()
+	s.mu.Unlock()
+
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.After(files[j].modTime) })
+	var out []string
+	for _, f := range files {
+		if len(out) >= n {
+			break
+		}
+		if strings.HasPrefix(filepath.Base(f.path), sensorID+"_") {
+			out = append(out, f.path)
+		}
+	}
+	return out
+}
+
+type spoolFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// files 列出目录及 dropped 子目录下的全部 SDU 文件
+func (s *Spool) files() []spoolFile {
+	var out []spoolFile
+	for _, pattern := range []string{
+		filepath.Join(s.cfg.Dir, "*"+fileSuffix),
+		filepath.Join(s.cfg.Dir, droppedDir, "*"+fileSuffix),
+	} {
+		matches, _ := filepath.Glob(pattern)
+		for _, m := range matches {
+			if st, err := os.Stat(m); err == nil {
+				out = append(out, spoolFile{path: m, size: st.Size(), modTime: st.ModTime()})
+			}
+		}
+	}
+	return out
+}
+
+// purgeOldest 从最旧的文件开始删除，直到总大小降到上限的 90% 以下，调用方需持有锁
+func (s *Spool) purgeOldest() {
+	files := s.files()
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	s.size = 0
+	for _, f := range files {
+		s.size += f.size
+	}
+	target := s.cfg.MaxSize / 10 * 9
+	for _, f := range files {
+		if s.size <= target {
+			break
+		}
+		if err := os.Remove(f.path); err == nil {
+			s.size -= f.size
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package spool
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestConfigFromDriver(t *testing.T) {
+	cfg, err := ConfigFromDriver(map[string]string{})
+	if err != nil || cfg.Enabled || cfg.Dir != "./spool" || cfg.MaxSize != 256<<20 {
+		t.Errorf("默认配置 %+v, %v", cfg, err)
+	}
+	cfg, err = ConfigFromDriver(map[string]string{KeyEnabled: "true", KeyDir: "/var/lpmp/spool", KeyMaxSizeMB: "4"})
+	if err != nil || !cfg.Enabled || cfg.Dir != "/var/lpmp/spool" || cfg.MaxSize != 4<<20 {
+		t.Errorf("配置 %+v, %v", cfg, err)
+	}
+	for _, bad := range []map[string]string{{KeyEnabled: "on"}, {KeyMaxSizeMB: "0"}, {KeyMaxSizeMB: "1.5"}} {
+		if _, err := ConfigFromDriver(bad); err == nil {
+			t.Errorf("%v 未报错", bad)
+		}
+	}
+}
+
+// age 把文件的修改时间设为 base 之后第 i 秒，使按时间排序的结果确定
+func age(t *testing.T, base time.Time, i int, path string) {
+	t.Helper()
+	at := base.Add(time.Duration(i) * time.Second)
+	if err := os.Chtimes(path, at, at); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestWriteAndRecent 完整的 SDU 写入导出目录，丢弃的写入 dropped 子目录；Recent 只返回该传感器的文件，
+// 按时间从新到旧，最多 n 个
+func TestWriteAndRecent(t *testing.T) {
+	dir := t.TempDir()
+	s, err := New(Config{Dir: dir, MaxSize: 1 << 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writes := []struct {
+		sensor   string
+		sseq     uint8
+		complete bool
+	}{
+		{"238A0821BEF2", 1, true},
+		{"238A0821BEF2", 2, false},
+		{"AABBCCDDEEFF", 3, true},
+		{"238A0821BEF2", 4, true},
+	}
+	base := time.Now().Add(-time.Hour)
+	for i, w := range writes {
+		if err := s.Write(w.sensor, w.sseq, []byte{byte(i)}, w.complete); err != nil {
+			t.Fatal(err)
+		}
+		// 刚写入的文件是目录中唯一没有设过时间的
+		for _, f := range s.files() {
+			if f.modTime.After(base.Add(time.Minute)) {
+				age(t, base, i, f.path)
+			}
+		}
+	}
+
+	name := regexp.MustCompile(`^238A0821BEF2_(\d+)_\d{8}T\d{6}\.\d{6}\.bin$`)
+	recent := s.Recent("238A0821BEF2", 10)
+	var sseqs []string
+	for _, p := range recent {
+		m := name.FindStringSubmatch(filepath.Base(p))
+		if m == nil {
+			t.Fatalf("文件名 %s 格式不符", p)
+		}
+		sseqs = append(sseqs, m[1])
+		wantDir := dir
+		if m[1] == "2" {
+			wantDir = filepath.Join(dir, droppedDir)
+		}
+		if filepath.Dir(p) != wantDir {
+			t.Errorf("SSEQ %s 写入 %s，期望 %s", m[1], filepath.Dir(p), wantDir)
+		}
+	}
+	if want := []string{"4", "2", "1"}; !reflect.DeepEqual(sseqs, want) {
+		t.Errorf("Recent 的 SSEQ %v，期望 %v", sseqs, want)
+	}
+	if got := s.Recent("238A0821BEF2", 2); !reflect.DeepEqual(got, recent[:2]) {
+		t.Errorf("Recent(n=2)=%v，期望 %v", got, recent[:2])
+	}
+	if got := s.Recent("238A0821BEF", 10); len(got) != 0 {
+		t.Errorf("SensorID 前缀匹配到了 %v", got)
+	}
+}
+
+// TestPurgeOldest 总大小超过上限时从最旧的文件（含 dropped 子目录）开始删除，直到不超过上限的 90%；
+// New 统计已有文件的大小
+func TestPurgeOldest(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{Dir: dir, MaxSize: 1000}
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Now().Add(-time.Hour)
+	data := make([]byte, 100)
+	for i := 0; i < 9; i++ {
+		if err := s.Write("238A0821BEF2", uint8(i), data, i%2 == 0); err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range s.files() {
+			if f.modTime.After(base.Add(time.Minute)) {
+				age(t, base, i, f.path)
+			}
+		}
+	}
+	if s.size != 900 || len(s.files()) != 9 {
+		t.Fatalf("未超限时大小 %d、文件 %d 个，期望 900、9", s.size, len(s.files()))
+	}
+
+	// 重新打开后继续按已有大小计算
+	if s, err = New(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if s.size != 900 {
+		t.Fatalf("New 统计已有大小 %d，期望 900", s.size)
+	}
+	if err := s.Write("238A0821BEF2", 9, make([]byte, 150), true); err != nil {
+		t.Fatal(err)
+	}
+	// 1050 > 1000：删除最旧的 SSEQ 0、1 后 850 ≤ 900
+	if s.size != 850 {
+		t.Errorf("清理后大小 %d，期望 850", s.size)
+	}
+	left := make(map[string]bool)
+	for _, f := range s.files() {
+		left[filepath.Base(f.path)[:len("238A0821BEF2_0")]] = true
+	}
+	for i, want := range []bool{false, false, true, true, true, true, true, true, true, true} {
+		key := "238A0821BEF2_" + string(rune('0'+i))
+		if left[key] != want {
+			t.Errorf("SSEQ %d 保留=%v，期望 %v", i, left[key], want)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+// Package sseqstore 记录各传感器最近拼接完成的业务单元序号（SSEQ）及时间，用于丢弃空口重传的重复 SDU；
+// 记录定期写入本地 JSON 文件，服务重启后仍能识别重启前刚收过的 SDU，避免重复发布。
+package sseqstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Driver 配置段中与 SSEQ 去重相关的键名
+const (
+	KeyWindow        = "SSEQDedupWindow"
+	KeyFile          = "SSEQStateFile"
+	KeyFlushInterval = "SSEQFlushInterval"
+)
+
+// Config 保存 SSEQ 去重配置
+type Config struct {
+	// Window 同一传感器同一 SSEQ 在该时长内再次拼接完成视为重复，0 表示不去重。
+	// SSEQ 只有 6bit，窗口应小于传感器发送 64 个 SDU 的时间
+	Window time.Duration
+	// File 记录文件，为空只在内存中记录（重启后丢失）
+	File string
+	// FlushInterval 有新记录时写入文件的周期
+	FlushInterval time.Duration
+}
+
+// Enabled 是否启用去重
+func (c Config) Enabled() bool {
+	return c.Window > 0
+}
+
+// ConfigFromDriver 从 Driver 配置段读取 SSEQ 去重配置，未配置项使用默认值
+func ConfigFromDriver(driverCfg map[string]string) (Config, error) {
+	cfg := Config{Window: 10 * time.Minute, FlushInterval: 5 * time.Second, File: driverCfg[KeyFile]}
+	if v := driverCfg[KeyWindow]; v == "0" {
+		cfg.Window = 0
+	} else if v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return cfg, fmt.Errorf("%s 配置无效 %q", KeyWindow, v)
+		}
+		cfg.Window = d
+	}
+	if v := driverCfg[KeyFlushInterval]; v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return cfg, fmt.Errorf("%s 配置无效 %q", KeyFlushInterval, v)
+		}
+		cfg.FlushInterval = d
+	}
+	return cfg, nil
+}
+
+// Store 各传感器各 SSEQ 最近一次拼接完成的时间，并发安全
+type Store struct {
+	cfg Config
+
+	mu sync.Mutex
+	// seen SensorID → SSEQ → 时间
+	seen  map[string]map[uint8]time.Time
+	dirty bool
+}
+
+// fileFormat 记录文件的内容：SensorID → SSEQ（十进制字符串）→ 时间
+type fileFormat map[string]map[string]time.Time
+
+// New 创建记录，配置了文件时加载其中仍在窗口内的记录；文件不存在时从空记录开始
+func New(cfg Config) (*Store, error) {
+	s := &Store{cfg: cfg, seen: make(map[string]map[uint8]time.Time)}
+	if cfg.File == "" {
+		return s, nil
+	}
+	b, err := os.ReadFile(cfg.File)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取 SSEQ 记录 %s 失败：%w", cfg.File, err)
+	}
+	var ff fileFormat
+	if err := json.Unmarshal(b, &ff); err != nil {
+		return nil, fmt.Errorf("SSEQ 记录 %s 格式错误：%w", cfg.File, err)
+	}
+	now := time.Now()
+	for sid, m := range ff {
+		for k, t := range m {
+			n, err := strconv.ParseUint(k, 10, 6)
+			if err != nil || now.Sub(t) >= cfg.Window {
+				continue
+			}
+			if s.seen[sid] == nil {
+				s.seen[sid] = make(map[uint8]time.Time)
+			}
+			s.seen[sid][uint8(n)] = t
+		}
+	}
+	return s, nil
+}
+
+// Accept 记录一个拼接完成的 SDU：同一传感器同一 SSEQ 在窗口内已出现过时返回 false（重复），
+// 否则记录并返回 true。重复的 SDU 不刷新时间，持续重传不会无限延长窗口
+func (s *Store) Accept(sensorID string, sseq uint8, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := s.seen[sensorID]
+	if m == nil {
+		m = make(map[uint8]time.Time)
+		s.seen[sensorID] = m
+	}
+	if t, ok := m[sseq]; ok && now.Sub(t) < s.cfg.Window {
+		return false
+	}
+	m[sseq] = now
+	s.dirty = true
+	return true
+}
+
+// Len 返回有记录的传感器数
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.seen)
+}
+
+// Flush 清理过期记录，有新记录时写入文件（先写临时文件再改名，避免断电留下半个文件）
+func (s *Store) Flush() error {
+	s.mu.Lock()
+	now := time.Now()
+	ff := make(fileFormat, len(s.seen))
+	for sid, m := range s.seen {
+		for sseq, t := range m {
+			if now.Sub(t) >= s.cfg.Window {
+				delete(m, sseq)
+				continue
+			}
+			if ff[sid] == nil {
+				ff[sid] = make(map[string]time.Time)
+			}
+			ff[sid][strconv.Itoa(int(sseq))] = t
+		}
+		if len(m) == 0 {
+			delete(s.seen, sid)
+		}
+	}
+	dirty := s.dirty
+	s.dirty = false
+	s.mu.Unlock()
+
+	if s.cfg.File == "" || !dirty {
+		return nil
+	}
+	b, err := json.Marshal(ff)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.cfg.File), 0o750); err != nil {
+		return fmt.Errorf("创建 SSEQ 记录目录失败：%w", err)
+	}
+	tmp := s.cfg.File + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o640); err != nil {
+		return fmt.Errorf("写入 SSEQ 记录 %s 失败：%w", tmp, err)
+	}
+	if err := os.Rename(tmp, s.cfg.File); err != nil {
+		return fmt.Errorf("替换 SSEQ 记录 %s 失败：%w", s.cfg.File, err)
+	}
+	return nil
+}
+
+// Run 按 FlushInterval 周期写入文件，stop 关闭时返回；onErr 接收写入失败，可为 nil
+func (s *Store) Run(stop <-chan struct{}, onErr func(error)) {
+	ticker := time.NewTicker(s.cfg.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := s.Flush(); err != nil && onErr != nil {
+				onErr(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package sseqstore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestConfigFromDriver(t *testing.T) {
+	cfg, err := ConfigFromDriver(map[string]string{})
+	if err != nil || !cfg.Enabled() || cfg.Window != 10*time.Minute || cfg.FlushInterval != 5*time.Second {
+		t.Errorf("默认配置 %+v, %v", cfg, err)
+	}
+	if cfg, err := ConfigFromDriver(map[string]string{KeyWindow: "0"}); err != nil || cfg.Enabled() {
+		t.Errorf("Window=0 得到 %+v, %v，期望关闭", cfg, err)
+	}
+	for _, bad := range []map[string]string{
+		{KeyWindow: "-1s"},
+		{KeyWindow: "abc"},
+		{KeyFlushInterval: "0s"},
+	} {
+		if _, err := ConfigFromDriver(bad); err == nil {
+			t.Errorf("%v 未报错", bad)
+		}
+	}
+}
+
+// TestAccept 窗口内同一 SSEQ 重复；重复不刷新时间，窗口从首次拼接完成算起；不同传感器互不影响
+func TestAccept(t *testing.T) {
+	s, err := New(Config{Window: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t0 := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	steps := []struct {
+		sensor string
+		sseq   uint8
+		at     time.Duration
+		want   bool
+	}{
+		{"238A0821BEF2", 5, 0, true},
+		{"238A0821BEF2", 5, 30 * time.Second, false},
+		{"238A0821BEF2", 6, 30 * time.Second, true},
+		{"238A0821BEF3", 5, 30 * time.Second, true},
+		{"238A0821BEF2", 5, 59 * time.Second, false},
+		{"238A0821BEF2", 5, time.Minute, true},
+	}
+	for _, st := range steps {
+		if got := s.Accept(st.sensor, st.sseq, t0.Add(st.at)); got != st.want {
+			t.Errorf("Accept(%s, %d, +%s) = %v，期望 %v", st.sensor, st.sseq, st.at, got, st.want)
+		}
+	}
+	if s.Len() != 2 {
+		t.Errorf("Len() = %d，期望 2", s.Len())
+	}
+}
+
+// TestFlushReload 落盘后重新加载仍识别窗口内的 SSEQ，过期记录写入时清理
+func TestFlushReload(t *testing.T) {
+	cfg := Config{Window: time.Minute, File: filepath.Join(t.TempDir(), "state", "sseq.json")}
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	s.Accept("238A0821BEF2", 5, now)
+	s.Accept("238A0821BEF3", 7, now.Add(-2*time.Minute))
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Len() != 1 {
+		t.Errorf("Flush 后 Len() = %d，过期记录未清理", s.Len())
+	}
+
+	restarted, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restarted.Accept("238A0821BEF2", 5, now.Add(time.Second)) {
+		t.Error("重启后未识别窗口内的 SSEQ")
+	}
+	if !restarted.Accept("238A0821BEF3", 7, now.Add(time.Second)) {
+		t.Error("过期的记录在重启后仍生效")
+	}
+	if _, err := os.Stat(cfg.File + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("临时文件未改名: %v", err)
+	}
+}
+
+func TestNewFile(t *testing.T) {
+	dir := t.TempDir()
+	if s, err := New(Config{Window: time.Minute, File: filepath.Join(dir, "missing.json")}); err != nil || s.Len() != 0 {
+		t.Errorf("文件不存在时 %v, %v，期望空记录", s, err)
+	}
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(Config{Window: time.Minute, File: bad}); err == nil {
+		t.Error("格式错误的文件未报错")
+	}
+}
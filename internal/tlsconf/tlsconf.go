@@ -0,0 +1,303 @@
+// Package tlsconf 为 TCP 透传链路和 MQTT 转发构造 TLS 客户端配置：服务端证书校验、
+// 可选的双向认证（客户端证书），证书来自文件或密钥库（secret store）。
+// 证书在每次握手时按需重新加载（文件修改时间变化或密钥库更新），轮换证书无需重启服务；
+// 最近一次握手的状态可供网关统计查询。
+package tlsconf
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Driver 配置段中的键名后缀，加上前缀（TCP 链路为 "Transport"，MQTT 转发为 "Mqtt"）即为完整键名，
+// 如 TransportTLSCAFile、MqttTLSCertFile
+const (
+	keyEnabled            = "TLSEnabled"
+	keyCAFile             = "TLSCAFile"
+	keyCertFile           = "TLSCertFile"
+	keyKeyFile            = "TLSKeyFile"
+	keyServerName         = "TLSServerName"
+	keyInsecureSkipVerify = "TLSInsecureSkipVerify"
+	keySecretName         = "TLSSecretName"
+)
+
+// 密钥库中证书的键名，值为 PEM
+const (
+	SecretKeyCA   = "ca"
+	SecretKeyCert = "cert"
+	SecretKeyKey  = "key"
+)
+
+// Config TLS 客户端配置
+type Config struct {
+	Enabled bool
+	// CAFile 校验服务端证书的 CA，为空时使用系统根证书
+	CAFile string
+	// CertFile / KeyFile 客户端证书和私钥，双向认证时填写
+	CertFile string
+	KeyFile  string
+	// ServerName 校验服务端证书的主机名，为空时使用连接地址中的主机名
+	ServerName string
+	// InsecureSkipVerify 不校验服务端证书，仅用于调试
+	InsecureSkipVerify bool
+	// SecretName 密钥库中的证书（键 ca / cert / key），填写后优先于文件
+	SecretName string
+}
+
+// ConfigFromDriver 读取前缀为 prefix 的 TLS 配置；填写了任一证书或密钥库时视为启用
+func ConfigFromDriver(driverCfg map[string]string, prefix string) (Config, error) {
+	cfg := Config{
+		CAFile:     driverCfg[prefix+keyCAFile],
+		CertFile:   driverCfg[prefix+keyCertFile],
+		KeyFile:    driverCfg[prefix+keyKeyFile],
+		ServerName: driverCfg[prefix+keyServerName],
+		SecretName: driverCfg[prefix+keySecretName],
+	}
+	cfg.Enabled = cfg.CAFile != "" || cfg.CertFile != "" || cfg.SecretName != ""
+	for key, dst := range map[string]*bool{
+		prefix + keyEnabled:            &cfg.Enabled,
+		prefix + keyInsecureSkipVerify: &cfg.InsecureSkipVerify,
+	} {
+		v := driverCfg[key]
+		if v == "" {
+			continue
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return cfg, fmt.Errorf("%s 配置无效 %q", key, v)
+		}
+		*dst = b
+	}
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return cfg, fmt.Errorf("%s 和 %s 须同时配置", prefix+keyCertFile, prefix+keyKeyFile)
+	}
+	return cfg, nil
+}
+
+// SecretSource 从密钥库读取证书，通常为 SDK SecretProvider 的 GetSecret
+type SecretSource func(secretName string, keys ...string) (map[string]string, error)
+
+// State 最近一次 TLS 握手的状态
+type State struct {
+	Enabled bool `json:"enabled"`
+	// Handshakes 握手次数（含证书校验失败的）
+	Handshakes    uint64    `json:"handshakes"`
+	LastHandshake time.Time `json:"lastHandshake"`
+	Version       string    `json:"version,omitempty"`
+	CipherSuite   string    `json:"cipherSuite,omitempty"`
+	PeerSubject   string    `json:"peerSubject,omitempty"`
+	PeerNotAfter  time.Time `json:"peerNotAfter"`
+	// ClientCertNotAfter 当前客户端证书的到期时间，未启用双向认证时为零值
+	ClientCertNotAfter time.Time `json:"clientCertNotAfter"`
+	// Reloads 证书重新加载的次数（含首次加载）
+	Reloads   uint64 `json:"reloads"`
+	LastError string `json:"lastError,omitempty"`
+}
+
+// material 一次加载得到的证书
+type material struct {
+	roots      *x509.CertPool
+	clientCert *tls.Certificate
+	// 文件修改时间或密钥库内容，用于判断是否需要重新加载
+	stamp string
+}
+
+// Loader 按需加载证书并记录握手状态
+type Loader struct {
+	cfg     Config
+	secrets SecretSource
+
+	mu    sync.Mutex
+	mat   *material
+	state State
+}
+
+// NewLoader 创建证书加载器并立即加载一次，证书无效时返回错误
+func NewLoader(cfg Config, secrets SecretSource) (*Loader, error) {
+	l := &Loader{cfg: cfg, secrets: secrets, state: State{Enabled: cfg.Enabled}}
+	if cfg.SecretName != "" && secrets == nil {
+		return nil, errors.New("配置了 TLS 密钥库证书，但密钥库不可用")
+	}
+	if _, err := l.current(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// stamp 返回证书来源的当前版本：文件为修改时间，密钥库为内容本身
+func (l *Loader) stamp() (string, map[string]string, error) {
+	if l.cfg.SecretName != "" {
+		s, err := l.secrets(l.cfg.SecretName)
+		if err != nil {
+			return "", nil, fmt.Errorf("读取密钥库证书 %s 失败: %w", l.cfg.SecretName, err)
+		}
+		return s[SecretKeyCA] + "\x00" + s[SecretKeyCert] + "\x00" + s[SecretKeyKey], s, nil
+	}
+	stamp := ""
+	for _, f := range []string{l.cfg.CAFile, l.cfg.CertFile, l.cfg.KeyFile} {
+		if f == "" {
+			continue
+		}
+		st, err := os.Stat(f)
+		if err != nil {
+			return "", nil, fmt.Errorf("读取证书文件失败: %w", err)
+		}
+		stamp += f + "@" + st.ModTime().String() + ";"
+	}
+	return stamp, nil, nil
+}
+
+// load 按配置读取证书
+func (l *Loader) load(secret map[string]string) (*material, error) {
+	var caPEM, certPEM, keyPEM []byte
+	if secret != nil {
+		caPEM, certPEM, keyPEM = []byte(secret[SecretKeyCA]), []byte(secret[SecretKeyCert]), []byte(secret[SecretKeyKey])
+	} else {
+		var err error
+		if l.cfg.CAFile != "" {
+			if caPEM, err = os.ReadFile(l.cfg.CAFile); err != nil {
+				return nil, fmt.Errorf("读取 CA 证书失败: %w", err)
+			}
+		}
+		if l.cfg.CertFile != "" {
+			if certPEM, err = os.ReadFile(l.cfg.CertFile); err != nil {
+				return nil, fmt.Errorf("读取客户端证书失败: %w", err)
+			}
+			if keyPEM, err = os.ReadFile(l.cfg.KeyFile); err != nil {
+				return nil, fmt.Errorf("读取客户端私钥失败: %w", err)
+			}
+		}
+	}
+
+	m := &material{}
+	if len(caPEM) > 0 {
+		m.roots = x509.NewCertPool()
+		if !m.roots.AppendCertsFromPEM(caPEM) {
+			return nil, errors.New("CA 证书中没有有效的 PEM 证书")
+		}
+	}
+	if len(certPEM) > 0 || len(keyPEM) > 0 {
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("客户端证书或私钥无效: %w", err)
+		}
+		m.clientCert = &cert
+	}
+	return m, nil
+}
+
+// current 返回当前证书，来源变化时重新加载；重新加载失败时继续使用上一版证书
+func (l *Loader) current() (*material, error) {
+	stamp, secret, err := l.stamp()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err != nil {
+		l.state.LastError = err.Error()
+		if l.mat != nil {
+			return l.mat, nil
+		}
+		return nil, err
+	}
+	if l.mat != nil && l.mat.stamp == stamp {
+		return l.mat, nil
+	}
+	m, err := l.load(secret)
+	if err != nil {
+		l.state.LastError = err.Error()
+		if l.mat != nil {
+			return l.mat, nil
+		}
+		return nil, err
+	}
+	m.stamp = stamp
+	l.mat = m
+	l.state.Reloads++
+	l.state.ClientCertNotAfter = time.Time{}
+	if m.clientCert != nil {
+		if leaf, err := x509.ParseCertificate(m.clientCert.Certificate[0]); err == nil {
+			l.state.ClientCertNotAfter = leaf.NotAfter
+		}
+	}
+	return m, nil
+}
+
+// TLSConfig 返回用于连接 serverName 的 TLS 配置。服务端证书由 VerifyConnection 按当前 CA 校验、
+// 客户端证书由 GetClientCertificate 按需提供，因此同一配置在证书轮换后仍然有效
+func (l *Loader) TLSConfig(serverName string) *tls.Config {
+	if l.cfg.ServerName != "" {
+		serverName = l.cfg.ServerName
+	}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+		// 证书链在 VerifyConnection 中按最新加载的 CA 校验
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			return l.verify(cs, serverName)
+		},
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			m, err := l.current()
+			if err != nil || m.clientCert == nil {
+				// 没有客户端证书时返回空证书，由服务端决定是否拒绝
+				return &tls.Certificate{}, nil
+			}
+			return m.clientCert, nil
+		},
+	}
+}
+
+// verify 校验服务端证书链并记录握手状态
+func (l *Loader) verify(cs tls.ConnectionState, serverName string) error {
+	err := func() error {
+		if len(cs.PeerCertificates) == 0 {
+			return errors.New("服务端未提供证书")
+		}
+		if l.cfg.InsecureSkipVerify {
+			return nil
+		}
+		m, err := l.current()
+		if err != nil {
+			return err
+		}
+		opts := x509.VerifyOptions{
+			DNSName:       serverName,
+			Roots:         m.roots,
+			Intermediates: x509.NewCertPool(),
+		}
+		for _, c := range cs.PeerCertificates[1:] {
+			opts.Intermediates.AddCert(c)
+		}
+		_, err = cs.PeerCertificates[0].Verify(opts)
+		return err
+	}()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.state.Handshakes++
+	l.state.LastHandshake = time.Now()
+	l.state.Version = tls.VersionName(cs.Version)
+	l.state.CipherSuite = tls.CipherSuiteName(cs.CipherSuite)
+	if len(cs.PeerCertificates) > 0 {
+		l.state.PeerSubject = cs.PeerCertificates[0].Subject.String()
+		l.state.PeerNotAfter = cs.PeerCertificates[0].NotAfter
+	}
+	if err != nil {
+		l.state.LastError = "服务端证书校验失败: " + err.Error()
+		return err
+	}
+	l.state.LastError = ""
+	return nil
+}
+
+// State 返回最近一次握手的状态
+func (l *Loader) State() State {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.state
+}
@@ -0,0 +1,297 @@
+package tlsconf
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCA 测试用 CA，issue 签发叶子证书
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+var serial int64
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial++
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue 签发 CN 为 name 的证书，返回证书和 PEM 格式的证书、私钥
+func (ca *testCA) issue(t *testing.T, name string, notAfter time.Time) (tls.Certificate, []byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial++
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, certPEM, keyPEM
+}
+
+// handshake 在内存连接上用 client 配置与 server 配置握手，返回客户端的错误。
+// 握手后直接关闭底层连接：net.Pipe 没有缓冲，tls.Conn.Close 发送 close_notify 会等待对端读取
+func handshake(t *testing.T, client, server *tls.Config) error {
+	t.Helper()
+	c, s := net.Pipe()
+	srvErr := make(chan error, 1)
+	go func() {
+		srvErr <- tls.Server(s, server).Handshake()
+		s.Close()
+	}()
+	err := tls.Client(c, client).Handshake()
+	c.Close()
+	<-srvErr
+	return err
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfigFromDriver(t *testing.T) {
+	cases := []struct {
+		cfg     map[string]string
+		enabled bool
+		err     bool
+	}{
+		{cfg: map[string]string{}},
+		{cfg: map[string]string{"TransportTLSEnabled": "true"}, enabled: true},
+		{cfg: map[string]string{"TransportTLSCAFile": "/etc/ca.pem"}, enabled: true},
+		{cfg: map[string]string{"TransportTLSSecretName": "lpmp-tls"}, enabled: true},
+		{cfg: map[string]string{"TransportTLSCAFile": "/etc/ca.pem", "TransportTLSEnabled": "false"}},
+		// 其它前缀的键不影响
+		{cfg: map[string]string{"MqttTLSCAFile": "/etc/ca.pem"}},
+		{cfg: map[string]string{"TransportTLSCertFile": "/etc/client.pem"}, err: true},
+		{cfg: map[string]string{"TransportTLSKeyFile": "/etc/client.key"}, err: true},
+		{cfg: map[string]string{"TransportTLSInsecureSkipVerify": "maybe"}, err: true},
+	}
+	for _, c := range cases {
+		cfg, err := ConfigFromDriver(c.cfg, "Transport")
+		if (err != nil) != c.err || err == nil && cfg.Enabled != c.enabled {
+			t.Errorf("%v: %+v, %v", c.cfg, cfg, err)
+		}
+	}
+	cfg, err := ConfigFromDriver(map[string]string{
+		"MqttTLSCertFile": "c.pem", "MqttTLSKeyFile": "c.key", "MqttTLSServerName": "broker", "MqttTLSInsecureSkipVerify": "1",
+	}, "Mqtt")
+	if err != nil || !cfg.Enabled || cfg.CertFile != "c.pem" || cfg.KeyFile != "c.key" || cfg.ServerName != "broker" || !cfg.InsecureSkipVerify {
+		t.Errorf("Mqtt 配置 %+v, %v", cfg, err)
+	}
+}
+
+func TestNewLoaderErrors(t *testing.T) {
+	dir := t.TempDir()
+	bad := filepath.Join(dir, "bad.pem")
+	writeFile(t, bad, []byte("not a certificate"))
+	for name, cfg := range map[string]Config{
+		"密钥库不可用":    {Enabled: true, SecretName: "lpmp-tls"},
+		"读取证书文件失败":  {Enabled: true, CAFile: filepath.Join(dir, "missing.pem")},
+		"没有有效的 PEM": {Enabled: true, CAFile: bad},
+		"证书或私钥无效":   {Enabled: true, CertFile: bad, KeyFile: bad},
+	} {
+		if _, err := NewLoader(cfg, nil); err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("%s: err=%v", name, err)
+		}
+	}
+}
+
+// TestVerifyServer 服务端证书按配置的 CA 和主机名校验，握手状态记录在 State 中
+func TestVerifyServer(t *testing.T) {
+	dir := t.TempDir()
+	ca, other := newTestCA(t, "lpmp-ca"), newTestCA(t, "other-ca")
+	notAfter := time.Now().Add(12 * time.Hour).Truncate(time.Second)
+	serverCert, _, _ := ca.issue(t, "gateway.local", notAfter)
+	server := &tls.Config{Certificates: []tls.Certificate{serverCert}}
+	caFile, otherFile := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "other.pem")
+	writeFile(t, caFile, ca.pem)
+	writeFile(t, otherFile, other.pem)
+
+	cases := []struct {
+		name   string
+		cfg    Config
+		dial   string
+		wantOK bool
+	}{
+		{"CA 匹配", Config{CAFile: caFile}, "gateway.local", true},
+		{"主机名不符", Config{CAFile: caFile}, "10.0.0.1", false},
+		{"ServerName 覆盖连接地址", Config{CAFile: caFile, ServerName: "gateway.local"}, "10.0.0.1", true},
+		{"CA 不符", Config{CAFile: otherFile}, "gateway.local", false},
+		{"不校验", Config{CAFile: otherFile, InsecureSkipVerify: true}, "gateway.local", true},
+	}
+	for _, c := range cases {
+		c.cfg.Enabled = true
+		l, err := NewLoader(c.cfg, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		err = handshake(t, l.TLSConfig(c.dial), server)
+		if (err == nil) != c.wantOK {
+			t.Errorf("%s: 握手 err=%v", c.name, err)
+		}
+		st := l.State()
+		if !st.Enabled || st.Handshakes != 1 || st.Reloads != 1 || st.PeerSubject != "CN=gateway.local" || !st.PeerNotAfter.Equal(notAfter) ||
+			st.Version == "" || st.CipherSuite == "" || (st.LastError == "") != c.wantOK {
+			t.Errorf("%s: 状态 %+v", c.name, st)
+		}
+	}
+}
+
+// TestClientCertFromSecret 双向认证时客户端证书来自密钥库，密钥库内容变化后下次握手使用新证书
+func TestClientCertFromSecret(t *testing.T) {
+	ca := newTestCA(t, "lpmp-ca")
+	serverCert, _, _ := ca.issue(t, "broker", time.Now().Add(time.Hour))
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	server := &tls.Config{Certificates: []tls.Certificate{serverCert}, ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+
+	first := time.Now().Add(2 * time.Hour).Truncate(time.Second)
+	_, certPEM, keyPEM := ca.issue(t, "lpmp-gw", first)
+	secret := map[string]string{SecretKeyCA: string(ca.pem), SecretKeyCert: string(certPEM), SecretKeyKey: string(keyPEM)}
+	var secretErr error
+	source := func(name string, _ ...string) (map[string]string, error) {
+		if name != "lpmp-tls" {
+			t.Errorf("读取密钥 %q", name)
+		}
+		return secret, secretErr
+	}
+	l, err := NewLoader(Config{Enabled: true, SecretName: "lpmp-tls"}, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := handshake(t, l.TLSConfig("broker"), server); err != nil {
+		t.Fatalf("双向认证握手失败: %v", err)
+	}
+	if st := l.State(); !st.ClientCertNotAfter.Equal(first) || st.Reloads != 1 {
+		t.Errorf("状态 %+v", st)
+	}
+
+	// 轮换客户端证书
+	second := time.Now().Add(48 * time.Hour).Truncate(time.Second)
+	_, certPEM, keyPEM = ca.issue(t, "lpmp-gw", second)
+	secret = map[string]string{SecretKeyCA: string(ca.pem), SecretKeyCert: string(certPEM), SecretKeyKey: string(keyPEM)}
+	if err := handshake(t, l.TLSConfig("broker"), server); err != nil {
+		t.Fatalf("轮换后握手失败: %v", err)
+	}
+	if st := l.State(); !st.ClientCertNotAfter.Equal(second) || st.Reloads != 2 {
+		t.Errorf("轮换后状态 %+v", st)
+	}
+
+	// 密钥库暂时不可用时沿用上一版证书
+	secretErr = errors.New("secret store down")
+	if err := handshake(t, l.TLSConfig("broker"), server); err != nil {
+		t.Fatalf("密钥库不可用时握手失败: %v", err)
+	}
+	if st := l.State(); st.Reloads != 2 || st.Handshakes != 3 {
+		t.Errorf("密钥库不可用时状态 %+v", st)
+	}
+}
+
+// TestReloadCAFile CA 文件修改后下次握手按新 CA 校验；新文件无效时沿用上一版并记录错误
+func TestReloadCAFile(t *testing.T) {
+	dir := t.TempDir()
+	oldCA, newCA := newTestCA(t, "old-ca"), newTestCA(t, "new-ca")
+	oldCert, _, _ := oldCA.issue(t, "gateway.local", time.Now().Add(time.Hour))
+	newCert, _, _ := newCA.issue(t, "gateway.local", time.Now().Add(time.Hour))
+	caFile := filepath.Join(dir, "ca.pem")
+	writeFile(t, caFile, oldCA.pem)
+
+	l, err := NewLoader(Config{Enabled: true, CAFile: caFile}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := l.TLSConfig("gateway.local")
+	if err := handshake(t, cfg, &tls.Config{Certificates: []tls.Certificate{oldCert}}); err != nil {
+		t.Fatalf("旧 CA 握手失败: %v", err)
+	}
+
+	// 同一 tls.Config 在 CA 轮换后生效
+	writeFile(t, caFile, newCA.pem)
+	touch := time.Now().Add(time.Minute)
+	if err := os.Chtimes(caFile, touch, touch); err != nil {
+		t.Fatal(err)
+	}
+	if err := handshake(t, cfg, &tls.Config{Certificates: []tls.Certificate{newCert}}); err != nil {
+		t.Fatalf("新 CA 握手失败: %v", err)
+	}
+	if err := handshake(t, cfg, &tls.Config{Certificates: []tls.Certificate{oldCert}}); err == nil {
+		t.Error("CA 轮换后旧 CA 签发的服务端证书仍通过校验")
+	}
+	if st := l.State(); st.Reloads != 2 {
+		t.Errorf("Reloads=%d，期望 2", st.Reloads)
+	}
+
+	writeFile(t, caFile, []byte("broken"))
+	touch = touch.Add(time.Minute)
+	if err := os.Chtimes(caFile, touch, touch); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.current(); err != nil {
+		t.Fatalf("新文件无效时未沿用上一版: %v", err)
+	}
+	if st := l.State(); st.Reloads != 2 || !strings.Contains(st.LastError, "PEM") {
+		t.Errorf("新文件无效时状态 %+v", st)
+	}
+	if err := handshake(t, cfg, &tls.Config{Certificates: []tls.Certificate{newCert}}); err != nil {
+		t.Errorf("沿用上一版 CA 握手失败: %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+// Package trace 为每一帧上行报文分配追踪 ID，并在接收、拼接、解析、发布各阶段
+// 记录耗时（Span），以便端到端定位某一帧的处理过程。
+package trace
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ID 一帧报文在处理流水线中的追踪 ID，形如 "120305-153"，前缀为进程启动时刻
+type ID string
+
+// Stage 流水线阶段
+type Stage string
+
+const (
+	StageReceive    Stage = "receive"    // 串口收到 +DRX 行并解码
+	StageReassemble Stage = "reassemble" // 分片拼接
+	StageParse      Stage = "parse"      // 业务报文解析
+	StagePublish    Stage = "publish"    // 读数写入值表并通知订阅者
+)
+
+var (
+	prefix = time.Now().Format("150405")
+	seq    atomic.Uint64
+)
+
+// New 生成一个新的追踪 ID
+func New() ID {
+	return ID(fmt.Sprintf("%s-%d", prefix, seq.Add(1)))
+}
+
+// Span 记录某一帧在一个阶段的处理区间
+type Span struct {
+	TraceID ID
+	Stage   Stage
+	Start   time.Time
+	End     time.Time
+	Attrs   map[string]any
+	Err     error
+}
+
+// Exporter 接收已结束的 Span，可对接日志或 OpenTelemetry 等追踪后端
+type Exporter func(Span)
+
+var (
+	exporterMu sync.RWMutex
+	exporter   Exporter
+)
+
+// SetExporter 设置 Span 导出器，传 nil 关闭导出
+func SetExporter(e Exporter) {
+	exporterMu.Lock()
+	defer exporterMu.Unlock()
+	exporter = e
+}
+
+// Begin 开始一个阶段，返回的函数在阶段结束时调用；未设置导出器时开销可忽略
+func Begin(id ID, stage Stage) func(attrs map[string]any, err error) {
+	exporterMu.RLock()
+	e := exporter
+	exporterMu.RUnlock()
+	if e == nil {
+		return func(map[string]any, error) {}
+	}
+	start := time.Now()
+	return func(attrs map[string]any, err error) {
+		e(Span{TraceID: id, Stage: stage, Start: start, End: time.Now(), Attrs: attrs, Err: err})
+	}
+}